@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"os"
 	"psm-backend/internal/database"
@@ -47,25 +48,137 @@ func main() {
 	marketDataService := services.NewMarketDataService(db)
 	taService := services.NewTechnicalAnalysisService(db, redisClient)
 	realtimeService := services.NewRealtimeService(db)
-	newsService := services.NewNewsService(db)
 	sentimentService := services.NewSentimentService(db)
-	aiService := services.NewAIService(db)
+	sentimentQueue := services.NewSentimentQueue(sentimentService)
+	newsService := services.NewNewsService(db, redisClient, sentimentQueue)
+	watchlistService := services.NewWatchlistService(db)
+	embeddingService := services.NewEmbeddingService(db)
+	revenueService := services.NewRevenueService(db)
+	institutionalService := services.NewInstitutionalService(db)
+	restrictionService := services.NewTradingRestrictionService(db)
+	aiService := services.NewAIService(db, sentimentService, embeddingService)
 	alertService := services.NewAlertService(db)
-	screenerService := services.NewScreenerService(db)
+	expressionService := services.NewExpressionService(db, taService)
+	screenerService := services.NewScreenerService(db, expressionService)
+	strategyService := services.NewStrategyService(db)
+	backtestService := services.NewBacktestService(db, strategyService)
+	paperTradingService := services.NewPaperTradingService(db, ledgerService, realtimeService)
+	newsWebhookService := services.NewNewsWebhookService(db, sentimentService, watchlistService)
+	alertNotificationService := services.NewAlertNotificationService(db)
+
+	// Background workers
+	precomputeWorker := services.NewIndicatorPrecomputeWorker(db, taService)
+	if getEnv("INDICATOR_PRECOMPUTE_ENABLED", "true") == "true" {
+		precomputeWorker.Start()
+		defer precomputeWorker.Stop()
+	}
+	paperTradingService.Start()
+	defer paperTradingService.Stop()
+	newsFetchWorker := services.NewNewsFetchWorker(newsService, watchlistService)
+	if getEnv("NEWS_FETCH_ENABLED", "true") == "true" {
+		newsFetchWorker.Start()
+		defer newsFetchWorker.Stop()
+	}
+	newsRetentionWorker := services.NewNewsRetentionWorker(newsService)
+	if getEnv("NEWS_RETENTION_ENABLED", "true") == "true" {
+		newsRetentionWorker.Start()
+		defer newsRetentionWorker.Stop()
+	}
+	if getEnv("NEWS_WEBHOOKS_ENABLED", "true") == "true" {
+		newsWebhookService.Start()
+		defer newsWebhookService.Stop()
+	}
+	if getEnv("SENTIMENT_AUTO_ENABLED", "true") == "true" {
+		sentimentQueue.Start()
+		defer sentimentQueue.Stop()
+	}
+	aiDigestWorker := services.NewAIDigestWorker(aiService, newsWebhookService, alertNotificationService)
+	if getEnv("AI_DIGEST_ENABLED", "true") == "true" {
+		aiDigestWorker.Start()
+		defer aiDigestWorker.Stop()
+	}
+	revenueFetchWorker := services.NewRevenueFetchWorker(revenueService)
+	if getEnv("REVENUE_FETCH_ENABLED", "true") == "true" {
+		revenueFetchWorker.Start()
+		defer revenueFetchWorker.Stop()
+	}
+	institutionalFetchWorker := services.NewInstitutionalFetchWorker(institutionalService)
+	if getEnv("INSTITUTIONAL_FETCH_ENABLED", "true") == "true" {
+		institutionalFetchWorker.Start()
+		defer institutionalFetchWorker.Stop()
+	}
+	restrictionFetchWorker := services.NewRestrictionFetchWorker(restrictionService)
+	if getEnv("RESTRICTION_FETCH_ENABLED", "true") == "true" {
+		restrictionFetchWorker.Start()
+		defer restrictionFetchWorker.Stop()
+	}
+	alertScanWorker := services.NewAlertScanWorker(alertService, realtimeService)
+	if getEnv("ALERT_SCAN_ENABLED", "true") == "true" {
+		alertScanWorker.Start()
+		defer alertScanWorker.Stop()
+	}
+	alertRetentionWorker := services.NewAlertRetentionWorker(alertService)
+	if getEnv("ALERT_RETENTION_ENABLED", "true") == "true" {
+		alertRetentionWorker.Start()
+		defer alertRetentionWorker.Stop()
+	}
+	screenerDailyWorker := services.NewScreenerDailyWorker(screenerService)
+	if getEnv("SCREENER_DAILY_ENABLED", "true") == "true" {
+		screenerDailyWorker.Start()
+		defer screenerDailyWorker.Stop()
+	}
+	screenerIntradayWorker := services.NewScreenerIntradayWorker(screenerService, realtimeService)
+	if getEnv("SCREENER_INTRADAY_ENABLED", "true") == "true" {
+		screenerIntradayWorker.Start()
+		defer screenerIntradayWorker.Stop()
+	}
+	screenScheduleWorker := services.NewScreenScheduleWorker(screenerService, alertService)
+	if getEnv("SCREEN_SCHEDULE_ENABLED", "true") == "true" {
+		screenScheduleWorker.Start()
+		defer screenScheduleWorker.Stop()
+	}
+	if getEnv("ALERT_NOTIFY_ENABLED", "true") == "true" {
+		alertNotificationService.Start()
+		defer alertNotificationService.Stop()
+	}
+	stockSyncWorker := services.NewStockSyncWorker(stockSyncService)
+	if getEnv("STOCK_SYNC_ENABLED", "true") == "true" {
+		stockSyncWorker.Start()
+		defer stockSyncWorker.Stop()
+	}
+	embeddingWorker := services.NewEmbeddingWorker(embeddingService)
+	if getEnv("EMBEDDINGS_ENABLED", "true") == "true" {
+		embeddingWorker.Start()
+		defer embeddingWorker.Stop()
+	}
+	if err := backtestService.ResumeJobs(context.Background()); err != nil {
+		log.Printf("Failed to resume backtest jobs: %v", err)
+	}
 
 	// Initialize handlers
 	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
 	stockHandler := handlers.NewStockHandler(stockService)
 	stockSyncHandler := handlers.NewStockSyncHandler(stockSyncService)
-	marketDataHandler := handlers.NewMarketDataHandler(marketDataService)
-	indicatorHandler := handlers.NewIndicatorHandler(taService)
-	bulkSyncHandler := handlers.NewBulkSyncHandler(marketDataService, db)
-	realtimeHandler := handlers.NewRealtimeHandler(realtimeService)
-	newsHandler := handlers.NewNewsHandler(newsService)
-	sentimentHandler := handlers.NewSentimentHandler(sentimentService)
-	aiHandler := handlers.NewAIHandler(aiService)
-	alertHandler := handlers.NewAlertHandler(alertService)
-	screenerHandler := handlers.NewScreenerHandler(screenerService)
+	marketDataHandler := handlers.NewMarketDataHandler(marketDataService, taService)
+	indicatorHandler := handlers.NewIndicatorHandler(taService, precomputeWorker)
+	expressionHandler := handlers.NewExpressionHandler(expressionService)
+	bulkSyncHandler := handlers.NewBulkSyncHandler(marketDataService, taService, db)
+	realtimeHandler := handlers.NewRealtimeHandler(realtimeService, taService)
+	newsHandler := handlers.NewNewsHandler(newsService, newsFetchWorker, newsRetentionWorker)
+	sentimentHandler := handlers.NewSentimentHandler(sentimentService, sentimentQueue)
+	aiHandler := handlers.NewAIHandler(aiService, aiDigestWorker)
+	semanticSearchHandler := handlers.NewSemanticSearchHandler(embeddingService, embeddingWorker)
+	revenueHandler := handlers.NewRevenueHandler(revenueService, revenueFetchWorker)
+	institutionalHandler := handlers.NewInstitutionalHandler(institutionalService)
+	restrictionHandler := handlers.NewTradingRestrictionHandler(restrictionService)
+	alertHandler := handlers.NewAlertHandler(alertService, alertScanWorker, alertRetentionWorker)
+	alertNotificationHandler := handlers.NewAlertNotificationHandler(alertNotificationService)
+	screenerHandler := handlers.NewScreenerHandler(screenerService, aiService)
+	backtestHandler := handlers.NewBacktestHandler(backtestService)
+	strategyHandler := handlers.NewStrategyHandler(strategyService, backtestService)
+	watchlistHandler := handlers.NewWatchlistHandler(watchlistService)
+	paperTradingHandler := handlers.NewPaperTradingHandler(paperTradingService)
+	newsWebhookHandler := handlers.NewNewsWebhookHandler(newsWebhookService)
 
 	// Create Fiber app
 	app := fiber.New(fiber.Config{
@@ -107,19 +220,56 @@ func main() {
 	api.Get("/portfolios/:portfolio_id/positions", ledgerHandler.GetPositions)
 	api.Get("/portfolios/:portfolio_id/positions/:symbol", ledgerHandler.GetPosition)
 	api.Get("/portfolios/:portfolio_id/positions/:symbol/pnl", ledgerHandler.CalculateUnrealizedPnL)
+	api.Get("/portfolios/:portfolio_id/positions/:symbol/alert-levels", alertHandler.GetPositionLevels)
+	api.Put("/portfolios/:portfolio_id/positions/:symbol/alert-levels", alertHandler.SetPositionLevels)
+	api.Delete("/portfolios/:portfolio_id/positions/:symbol/alert-levels", alertHandler.DeletePositionLevels)
 
 	// Portfolio routes
 	api.Get("/portfolios/:portfolio_id", ledgerHandler.GetPortfolio)
 	api.Get("/portfolios", ledgerHandler.GetUserPortfolios)
+	api.Post("/portfolios/:portfolio_id/news/fetch", newsHandler.FetchPortfolioNews)
+
+	// Paper trading routes
+	api.Post("/paper/portfolios", paperTradingHandler.CreatePortfolio)
+	api.Get("/paper/portfolios", paperTradingHandler.ListPortfolios)
+	api.Get("/paper/portfolios/:portfolio_id", paperTradingHandler.GetAccount)
+	api.Post("/paper/portfolios/:portfolio_id/orders", paperTradingHandler.PlaceOrder)
+	api.Get("/paper/portfolios/:portfolio_id/orders", paperTradingHandler.ListOrders)
+	api.Delete("/paper/portfolios/:portfolio_id/orders/:order_id", paperTradingHandler.CancelOrder)
+
+	// Watchlist routes
+	api.Get("/watchlists", watchlistHandler.ListWatchlists)
+	api.Post("/watchlists", watchlistHandler.CreateWatchlist)
+	api.Get("/watchlists/:id", watchlistHandler.GetWatchlist)
+	api.Delete("/watchlists/:id", watchlistHandler.DeleteWatchlist)
+	api.Post("/watchlists/:id/symbols", watchlistHandler.AddSymbols)
+	api.Delete("/watchlists/:id/symbols/:symbol", watchlistHandler.RemoveSymbol)
 
 	// Stock routes
 	api.Get("/stocks/search", stockHandler.SearchStocks)
+	api.Get("/stocks/sync/history", stockSyncHandler.GetSyncHistory)
+	api.Get("/stocks/restrictions", restrictionHandler.ListRestrictions)
+	api.Put("/stocks/restrictions", restrictionHandler.SetRestriction)
+	api.Post("/stocks/restrictions/fetch", restrictionHandler.FetchDispositions)
+	api.Delete("/stocks/restrictions/:symbol/:restriction/:start_date", restrictionHandler.DeleteRestriction)
 	api.Get("/stocks/:symbol", stockHandler.GetStock)
 	api.Post("/stocks/sync", stockSyncHandler.SyncStocks)
+	api.Get("/industries", stockHandler.ListIndustries)
+	api.Get("/industries/:name/stocks", stockHandler.ListIndustryStocks)
+
+	// Monthly revenue routes (月營收)
+	api.Get("/stocks/:symbol/revenue", revenueHandler.GetMonthlyRevenue)
+	api.Post("/revenue/fetch", revenueHandler.FetchMonthlyRevenue)
+	api.Get("/revenue/worker/status", revenueHandler.GetFetchWorkerStatus)
+
+	// Institutional trading routes (三大法人)
+	api.Get("/stocks/:symbol/institutional", institutionalHandler.GetInstitutionalTrades)
+	api.Post("/institutional/fetch", institutionalHandler.FetchInstitutionalTrades)
 
 	// Market data routes (Phase 2.1)
 	api.Get("/stocks/:symbol/ohlcv", marketDataHandler.GetOHLCV)
 	api.Post("/market/sync", marketDataHandler.SyncMarketData)
+	api.Post("/market/sync-index", marketDataHandler.SyncIndexData)
 	api.Post("/market/refresh-aggregates", marketDataHandler.RefreshAggregates)
 
 	// Technical indicator routes (Phase 2.2)
@@ -128,7 +278,22 @@ func main() {
 	api.Get("/indicators/:symbol/macd", indicatorHandler.GetMACD)
 	api.Get("/indicators/:symbol/bb", indicatorHandler.GetBollingerBands)
 	api.Get("/indicators/:symbol/kdj", indicatorHandler.GetKDJ)
+	api.Get("/indicators/:symbol/pivots", indicatorHandler.GetPivotPoints)
+	api.Get("/indicators/:symbol/bb-squeeze", indicatorHandler.GetBBSqueeze)
+	api.Get("/indicators/:symbol/beta", indicatorHandler.GetBeta)
 	api.Post("/indicators/:symbol/batch", indicatorHandler.GetBatchIndicators)
+	api.Post("/indicators/batch", indicatorHandler.GetMultiSymbolIndicators)
+	api.Get("/indicators/:symbol/snapshot", indicatorHandler.GetSnapshot)
+	api.Post("/indicators/snapshots/refresh", indicatorHandler.RefreshSnapshots)
+	api.Get("/indicators/precompute/status", indicatorHandler.GetPrecomputeStatus)
+	api.Post("/indicators/precompute/run", indicatorHandler.RunPrecompute)
+
+	// Custom indicator expressions
+	api.Get("/indicators/expressions", expressionHandler.ListExpressions)
+	api.Post("/indicators/expressions", expressionHandler.CreateExpression)
+	api.Post("/indicators/expressions/validate", expressionHandler.ValidateExpression)
+	api.Delete("/indicators/expressions/:id", expressionHandler.DeleteExpression)
+	api.Get("/indicators/:symbol/expression", expressionHandler.EvaluateExpression)
 
 	// Bulk sync routes (Phase 2.5)
 	api.Get("/market/bulk-sync/status", bulkSyncHandler.GetSyncStatus)
@@ -143,19 +308,81 @@ func main() {
 
 	// News routes (Phase 4.1)
 	api.Get("/news", newsHandler.GetRecentNews)
+	api.Get("/news/trending", newsHandler.GetTrending)
+	api.Get("/news/sources", newsHandler.GetSources)
+	api.Get("/news/sources/stats", newsHandler.GetSourceStats)
+	api.Get("/news/feeds", newsHandler.ListFeeds)
+	api.Post("/news/feeds", newsHandler.RegisterFeed)
+	api.Post("/news/feeds/fetch", newsHandler.FetchFeeds)
+	api.Put("/news/feeds/:id", newsHandler.UpdateFeed)
+	api.Delete("/news/feeds/:id", newsHandler.DeleteFeed)
+	api.Get("/news/articles/:id/duplicates", newsHandler.GetDuplicates)
+	api.Post("/news/articles/:id/content", newsHandler.FetchArticleContent)
+	api.Post("/news/content/fetch", newsHandler.EnrichTruncatedNews)
+	api.Get("/news/webhooks", newsWebhookHandler.ListWebhooks)
+	api.Post("/news/webhooks", newsWebhookHandler.CreateWebhook)
+	api.Put("/news/webhooks/:id", newsWebhookHandler.UpdateWebhook)
+	api.Delete("/news/webhooks/:id", newsWebhookHandler.DeleteWebhook)
+	api.Post("/news/webhooks/:id/test", newsWebhookHandler.TestWebhook)
+	api.Get("/news/retention", newsHandler.GetRetentionStatus)
+	api.Post("/news/cleanup", newsHandler.RunCleanup)
+	api.Get("/news/worker/status", newsHandler.GetFetchWorkerStatus)
+	api.Post("/news/worker/run", newsHandler.RunFetchWorker)
 	api.Get("/news/:symbol", newsHandler.GetNews)
 	api.Post("/news/fetch", newsHandler.FetchGeneralNews)
 	api.Post("/news/:symbol/fetch", newsHandler.FetchNews)
 
 	// Sentiment routes (Phase 4.2)
+	api.Get("/sentiment/industries", sentimentHandler.GetIndustrySentiment)
+	api.Get("/sentiment/queue", sentimentHandler.GetQueueStatus)
+	api.Get("/sentiment/versions", sentimentHandler.GetAnalyzerVersions)
+	api.Get("/sentiment/sources", sentimentHandler.GetSourceBias)
+	api.Post("/sentiment/reanalyze", sentimentHandler.ReanalyzeOutdated)
+	api.Get("/sentiment/market", sentimentHandler.GetMarketSentiment)
+	api.Get("/sentiment/backtest", sentimentHandler.GetPooledSentimentBacktest)
+	api.Post("/sentiment/market/rebuild", sentimentHandler.RebuildMarketSentiment)
+	api.Get("/sentiment/dictionary", sentimentHandler.GetDictionary)
+	api.Post("/sentiment/dictionary", sentimentHandler.CreateKeyword)
+	api.Post("/sentiment/dictionary/reload", sentimentHandler.ReloadDictionary)
+	api.Put("/sentiment/dictionary/:id", sentimentHandler.UpdateKeyword)
+	api.Delete("/sentiment/dictionary/:id", sentimentHandler.DeleteKeyword)
 	api.Get("/sentiment/:symbol", sentimentHandler.GetSentimentSummary)
+	api.Get("/sentiment/:symbol/aspects", sentimentHandler.GetAspectBreakdown)
+	api.Get("/sentiment/:symbol/backtest", sentimentHandler.GetSentimentBacktest)
 	api.Post("/sentiment/analyze", sentimentHandler.AnalyzeUnanalyzedNews)
 	api.Post("/sentiment/article/:id", sentimentHandler.AnalyzeSingleArticle)
 	api.Post("/sentiment/text", sentimentHandler.AnalyzeText)
 
+	// Semantic search over news and AI analyses (pgvector embeddings)
+	api.Get("/search/semantic", semanticSearchHandler.Search)
+	api.Get("/search/semantic/context", semanticSearchHandler.GetContext)
+	api.Get("/search/semantic/status", semanticSearchHandler.GetStatus)
+	api.Post("/search/semantic/index", semanticSearchHandler.RunIndexer)
+
 	// AI analysis routes (Phase 4.3)
 	api.Get("/ai/status", aiHandler.GetStatus)
+	api.Get("/ai/usage", aiHandler.GetTokenUsage)
+	api.Get("/ai/usage/daily", aiHandler.GetDailyUsage)
+	api.Get("/ai/jobs/:id", aiHandler.GetAnalysisJob)
+	api.Post("/ai/analyze-batch", aiHandler.AnalyzeBatch)
+	api.Get("/ai/batches/:id", aiHandler.GetAnalysisBatch)
+	api.Get("/ai/budgets", aiHandler.ListBudgets)
+	api.Put("/ai/budgets", aiHandler.SetBudget)
+	api.Delete("/ai/budgets/:id", aiHandler.DeleteBudget)
+	api.Get("/ai/compare", aiHandler.CompareSymbols)
+	api.Post("/ai/alerts/:id/explain", aiHandler.ExplainAlert)
+	api.Post("/ai/chat", aiHandler.Chat)
+	api.Get("/ai/digest/today", aiHandler.GetTodayDigest)
+	api.Post("/ai/digest/generate", aiHandler.GenerateDigest)
+	api.Get("/ai/prompts", aiHandler.ListPromptTemplates)
+	api.Get("/ai/prompts/:type", aiHandler.GetPromptTemplateHistory)
+	api.Post("/ai/prompts/:type", aiHandler.CreatePromptTemplate)
+	api.Post("/ai/prompts/:type/activate", aiHandler.ActivatePromptTemplate)
+	api.Get("/ai/chat/sessions", aiHandler.ListChatSessions)
+	api.Get("/ai/chat/sessions/:id", aiHandler.GetChatSession)
+	api.Delete("/ai/chat/sessions/:id", aiHandler.DeleteChatSession)
 	api.Get("/ai/:symbol/analysis", aiHandler.GetAnalysis)
+	api.Get("/ai/:symbol/analysis/stream", aiHandler.StreamAnalysis)
 	api.Get("/ai/:symbol/daily", aiHandler.GetDailySummary)
 	api.Get("/ai/:symbol/advice", aiHandler.GetInvestmentAdvice)
 	api.Get("/ai/:symbol/history", aiHandler.GetCachedAnalyses)
@@ -165,16 +392,91 @@ func main() {
 	api.Get("/alerts", alertHandler.GetAlerts)
 	api.Get("/alerts/stats", alertHandler.GetAlertStats)
 	api.Post("/alerts/scan", alertHandler.ScanAll)
+	api.Post("/alerts/ack-all", alertHandler.AcknowledgeAll)
+	api.Get("/alerts/volume", alertHandler.GetAlertVolume)
+	api.Get("/alerts/retention", alertHandler.GetRetentionStatus)
+	api.Post("/alerts/cleanup", alertHandler.RunCleanup)
+	api.Get("/alerts/thresholds", alertHandler.GetThresholds)
+	api.Put("/alerts/thresholds", alertHandler.UpdateThresholds)
+	api.Get("/alerts/position-levels", alertHandler.ListPositionLevels)
+	api.Get("/alerts/rules", alertHandler.ListAlertRules)
+	api.Post("/alerts/rules", alertHandler.CreateAlertRule)
+	api.Get("/alerts/rules/:id", alertHandler.GetAlertRule)
+	api.Put("/alerts/rules/:id", alertHandler.UpdateAlertRule)
+	api.Delete("/alerts/rules/:id", alertHandler.DeleteAlertRule)
+	api.Post("/alerts/rules/:id/enable", alertHandler.EnableAlertRule)
+	api.Post("/alerts/rules/:id/disable", alertHandler.DisableAlertRule)
+	api.Get("/alerts/notifications/email", alertNotificationHandler.GetEmailSettings)
+	api.Put("/alerts/notifications/email", alertNotificationHandler.UpdateEmailSettings)
+	api.Post("/alerts/notifications/email/test", alertNotificationHandler.TestEmail)
+	api.Get("/alerts/notifications/line", alertNotificationHandler.GetLineSettings)
+	api.Put("/alerts/notifications/line", alertNotificationHandler.UpdateLineSettings)
+	api.Delete("/alerts/notifications/line", alertNotificationHandler.DeleteLineSettings)
+	api.Post("/alerts/notifications/line/test", alertNotificationHandler.TestLine)
+	api.Get("/alerts/notifications/telegram", alertNotificationHandler.GetTelegramSettings)
+	api.Put("/alerts/notifications/telegram", alertNotificationHandler.UpdateTelegramSettings)
+	api.Delete("/alerts/notifications/telegram", alertNotificationHandler.DeleteTelegramSettings)
+	api.Get("/alerts/notifications/webhooks", alertNotificationHandler.ListWebhooks)
+	api.Post("/alerts/notifications/webhooks", alertNotificationHandler.CreateWebhook)
+	api.Put("/alerts/notifications/webhooks/:id", alertNotificationHandler.UpdateWebhook)
+	api.Delete("/alerts/notifications/webhooks/:id", alertNotificationHandler.DeleteWebhook)
+	api.Post("/alerts/notifications/webhooks/:id/test", alertNotificationHandler.TestWebhook)
+	api.Get("/alerts/notifications/webhooks/:id/deliveries", alertNotificationHandler.ListWebhookDeliveries)
+	api.Post("/alerts/notifications/webhooks/:id/deliveries/:deliveryId/redeliver", alertNotificationHandler.RedeliverWebhook)
+	api.Get("/alerts/notifications/deliveries", alertNotificationHandler.ListDeliveries)
+	api.Get("/alerts/notifications/schedule", alertNotificationHandler.GetSchedule)
+	api.Put("/alerts/notifications/schedule", alertNotificationHandler.UpdateSchedule)
+	api.Get("/alerts/notifications/escalation", alertNotificationHandler.GetEscalationPolicy)
+	api.Put("/alerts/notifications/escalation", alertNotificationHandler.UpdateEscalationPolicy)
 	api.Get("/alerts/:symbol", alertHandler.GetAlertsBySymbol)
 	api.Get("/alerts/:symbol/volume", alertHandler.DetectVolumeSpike)
 	api.Get("/alerts/:symbol/price", alertHandler.DetectPriceBreakout)
+	api.Get("/alerts/:symbol/ma", alertHandler.DetectMABreakout)
+	api.Get("/alerts/:symbol/rsi", alertHandler.DetectRSIExtreme)
 	api.Post("/alerts/:id/ack", alertHandler.AcknowledgeAlert)
+	api.Post("/alerts/:id/snooze", alertHandler.SnoozeAlert)
+	api.Delete("/alerts/:id/snooze", alertHandler.UnsnoozeAlert)
 
 	// Screener routes (Phase 4.5)
 	api.Get("/screener/presets", screenerHandler.GetPresets)
+	api.Post("/screener/presets/evaluate", screenerHandler.EvaluatePresets)
 	api.Get("/screener/preset/:name", screenerHandler.RunPreset)
+	api.Get("/screener/preset/:name/history", screenerHandler.GetPresetHistory)
+	api.Get("/screener/preset/:name/history/diff", screenerHandler.GetPresetHistoryDiff)
+	api.Get("/screener/preset/:name/performance", screenerHandler.GetPresetPerformance)
 	api.Get("/screener/quick/:type", screenerHandler.QuickScreen)
+	api.Get("/screener/realtime", screenerHandler.GetRealtimeStatus)
 	api.Post("/screener/screen", screenerHandler.ScreenStocks)
+	api.Post("/screener/natural", screenerHandler.NaturalLanguageScreen)
+	api.Post("/screener/combine", screenerHandler.CombineScreens)
+	api.Get("/screener/screens", screenerHandler.ListSavedScreens)
+	api.Post("/screener/screens", screenerHandler.CreateSavedScreen)
+	api.Get("/screener/screens/:id", screenerHandler.GetSavedScreen)
+	api.Put("/screener/screens/:id", screenerHandler.UpdateSavedScreen)
+	api.Delete("/screener/screens/:id", screenerHandler.DeleteSavedScreen)
+	api.Get("/screener/screens/:id/run", screenerHandler.RunSavedScreen)
+	api.Get("/screener/screens/:id/history", screenerHandler.GetSavedScreenHistory)
+	api.Get("/screener/screens/:id/history/diff", screenerHandler.GetSavedScreenHistoryDiff)
+	api.Get("/screener/screens/:id/performance", screenerHandler.GetSavedScreenPerformance)
+	api.Post("/screener/screens/:id/share", screenerHandler.ShareSavedScreen)
+	api.Delete("/screener/screens/:id/share", screenerHandler.UnshareSavedScreen)
+	api.Put("/screener/screens/:id/schedule", screenerHandler.ScheduleSavedScreen)
+	api.Get("/screener/shared/:token", screenerHandler.RunSharedScreen)
+
+	// Backtest routes
+	api.Post("/backtest", backtestHandler.SubmitBacktest)
+	api.Get("/backtest", backtestHandler.ListBacktests)
+	api.Get("/backtest/:id", backtestHandler.GetBacktest)
+
+	// Strategy routes
+	api.Get("/strategies", strategyHandler.ListStrategies)
+	api.Post("/strategies", strategyHandler.CreateStrategy)
+	api.Get("/strategies/:id", strategyHandler.GetStrategy)
+	api.Put("/strategies/:id", strategyHandler.UpdateStrategy)
+	api.Delete("/strategies/:id", strategyHandler.DeleteStrategy)
+	api.Get("/strategies/:id/versions", strategyHandler.ListVersions)
+	api.Get("/strategies/:id/versions/:version", strategyHandler.GetVersion)
+	api.Get("/strategies/:id/backtests", strategyHandler.ListBacktests)
 
 	// WebSocket endpoint for real-time updates
 	app.Use("/ws", realtimeHandler.WebSocketUpgrade)
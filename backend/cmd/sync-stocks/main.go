@@ -34,7 +34,7 @@ func main() {
 	fmt.Println("")
 
 	// Sync all stocks
-	result, err := syncService.SyncAll(context.Background())
+	result, err := syncService.SyncAll(context.Background(), "cli")
 	if err != nil {
 		log.Fatalf("❌ Synchronization failed: %v", err)
 	}
@@ -45,7 +45,11 @@ func main() {
 	fmt.Printf("📊 Results:\n")
 	fmt.Printf("   - TSE (上市): %d stocks\n", result["tse"])
 	fmt.Printf("   - OTC (上櫃): %d stocks\n", result["otc"])
+	fmt.Printf("   - ETF/ETN:    %d securities\n", result["etf"])
 	fmt.Printf("   - Total:      %d stocks\n", result["total"])
+	fmt.Printf("   - New:        %d stocks\n", result["new"])
+	fmt.Printf("   - Updated:    %d stocks\n", result["updated"])
+	fmt.Printf("   - Delisted:   %d stocks\n", result["deactivated"])
 	fmt.Println("")
 	fmt.Println("✨ Stock database is now up to date!")
 }
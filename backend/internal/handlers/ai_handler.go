@@ -1,25 +1,35 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
 	"psm-backend/internal/services"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // AIHandler handles AI analysis endpoints
 type AIHandler struct {
-	aiService *services.AIService
+	aiService    *services.AIService
+	digestWorker *services.AIDigestWorker
 }
 
-func NewAIHandler(aiService *services.AIService) *AIHandler {
+func NewAIHandler(aiService *services.AIService, digestWorker *services.AIDigestWorker) *AIHandler {
 	return &AIHandler{
-		aiService: aiService,
+		aiService:    aiService,
+		digestWorker: digestWorker,
 	}
 }
 
-// GetAnalysis returns AI analysis for a symbol
-// GET /api/v1/ai/:symbol/analysis
+// GetAnalysis returns AI analysis for a symbol. When the provider queue is
+// busy the analysis is queued instead: 202 with a job to poll at /ai/jobs/:id.
+// GET /api/v1/ai/:symbol/analysis?type=daily_summary&language=en
 func (h *AIHandler) GetAnalysis(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
 	if symbol == "" {
@@ -29,43 +39,121 @@ func (h *AIHandler) GetAnalysis(c *fiber.Ctx) error {
 	}
 
 	// Check if API key is configured
-	if !h.aiService.HasAPIKey() {
+	if !h.aiService.IsConfigured() {
 		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
 			"error":   "AI service not configured",
-			"message": "請設定 GEMINI_API_KEY 環境變數以啟用 AI 分析功能",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
 		})
 	}
 
 	// Get analysis type (default: daily_summary)
-	analysisTypeStr := c.Query("type", "daily_summary")
-	var analysisType services.AnalysisType
-	switch analysisTypeStr {
-	case "daily_summary":
-		analysisType = services.AnalysisTypeDailySummary
-	case "investment_advice":
-		analysisType = services.AnalysisTypeInvestmentAdvice
-	case "risk_assessment":
-		analysisType = services.AnalysisTypeRiskAssessment
-	case "news_digest":
-		analysisType = services.AnalysisTypeNewsDigest
-	default:
+	analysisType, ok := parseAnalysisType(c.Query("type", "daily_summary"))
+	if !ok {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "invalid analysis type",
-			"valid":   []string{"daily_summary", "investment_advice", "risk_assessment", "news_digest"},
+			"valid":   validAnalysisTypes,
 		})
 	}
 
-	result, err := h.aiService.GetAnalysis(c.Context(), symbol, analysisType)
+	language, err := services.ParseAILanguage(c.Query("language"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "分析失敗: " + err.Error(),
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    result,
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	result, job, err := h.aiService.SubmitAnalysis(c.Context(), userID, symbol, analysisType, language)
+	return analysisResponse(c, result, job, err)
+}
+
+// StreamAnalysis streams AI analysis for a symbol as server-sent events:
+// "chunk" events carry {"text": ...} as Gemini generates it, followed by a
+// "done" event with the full result or an "error" event
+// GET /api/v1/ai/:symbol/analysis/stream?type=daily_summary&language=en
+func (h *AIHandler) StreamAnalysis(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	analysisType, ok := parseAnalysisType(c.Query("type", "daily_summary"))
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid analysis type",
+			"valid": validAnalysisTypes,
+		})
+	}
+
+	language, err := services.ParseAILanguage(c.Query("language"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("X-Accel-Buffering", "no") // Disable proxy buffering (nginx)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		// The request context is not usable once the handler has returned
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Minute)
+		defer cancel()
+
+		result, err := h.aiService.StreamAnalysis(ctx, userID, symbol, analysisType, language, func(text string) error {
+			return writeSSE(w, "chunk", fiber.Map{"text": text})
+		})
+		if err != nil {
+			writeSSE(w, "error", fiber.Map{"error": "分析失敗: " + err.Error()})
+			return
+		}
+		writeSSE(w, "done", result)
 	})
+	return nil
+}
+
+// writeSSE writes one server-sent event and flushes it; a flush error means
+// the client disconnected
+func writeSSE(w *bufio.Writer, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+var validAnalysisTypes = []string{"daily_summary", "investment_advice", "risk_assessment", "news_digest"}
+
+func parseAnalysisType(value string) (services.AnalysisType, bool) {
+	switch value {
+	case "daily_summary":
+		return services.AnalysisTypeDailySummary, true
+	case "investment_advice":
+		return services.AnalysisTypeInvestmentAdvice, true
+	case "risk_assessment":
+		return services.AnalysisTypeRiskAssessment, true
+	case "news_digest":
+		return services.AnalysisTypeNewsDigest, true
+	}
+	return "", false
 }
 
 // GetDailySummary returns daily summary for a symbol
@@ -78,24 +166,24 @@ func (h *AIHandler) GetDailySummary(c *fiber.Ctx) error {
 		})
 	}
 
-	if !h.aiService.HasAPIKey() {
+	if !h.aiService.IsConfigured() {
 		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
 			"error":   "AI service not configured",
-			"message": "請設定 GEMINI_API_KEY 環境變數以啟用 AI 分析功能",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
 		})
 	}
 
-	result, err := h.aiService.GetAnalysis(c.Context(), symbol, services.AnalysisTypeDailySummary)
+	language, err := services.ParseAILanguage(c.Query("language"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-			"error": "分析失敗: " + err.Error(),
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
 		})
 	}
 
-	return c.JSON(fiber.Map{
-		"success": true,
-		"data":    result,
-	})
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	result, job, err := h.aiService.SubmitAnalysis(c.Context(), userID, symbol, services.AnalysisTypeDailySummary, language)
+	return analysisResponse(c, result, job, err)
 }
 
 // GetInvestmentAdvice returns investment advice for a symbol
@@ -108,19 +196,113 @@ func (h *AIHandler) GetInvestmentAdvice(c *fiber.Ctx) error {
 		})
 	}
 
-	if !h.aiService.HasAPIKey() {
+	if !h.aiService.IsConfigured() {
 		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
 			"error":   "AI service not configured",
-			"message": "請設定 GEMINI_API_KEY 環境變數以啟用 AI 分析功能",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
 		})
 	}
 
-	result, err := h.aiService.GetAnalysis(c.Context(), symbol, services.AnalysisTypeInvestmentAdvice)
+	language, err := services.ParseAILanguage(c.Query("language"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	result, job, err := h.aiService.SubmitAnalysis(c.Context(), userID, symbol, services.AnalysisTypeInvestmentAdvice, language)
+	return analysisResponse(c, result, job, err)
+}
+
+// CompareSymbols returns a head-to-head AI comparison of two symbols
+// (valuation, momentum, sentiment), cached per pair and day
+// GET /api/v1/ai/compare?symbols=2330,2454&language=en
+func (h *AIHandler) CompareSymbols(c *fiber.Ctx) error {
+	if c.Query("symbols") == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbols is required, e.g. symbols=2330,2454",
+		})
+	}
+
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	language, err := services.ParseAILanguage(c.Query("language"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	result, err := h.aiService.CompareSymbols(c.Context(), userID, strings.Split(c.Query("symbols"), ","), language)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": "比較失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// ExplainAlert returns a short AI explanation of what likely caused an alert,
+// generated once from the alert payload, price action and related news
+// POST /api/v1/ai/alerts/:id/explain?language=en&refresh=true
+func (h *AIHandler) ExplainAlert(c *fiber.Ctx) error {
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	language, err := services.ParseAILanguage(c.Query("language"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	result, err := h.aiService.ExplainAlert(c.Context(), userID, c.Params("id"), language, c.QueryBool("refresh", false))
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": "說明失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// analysisResponse writes an analysis, or 202 with the job when it was queued
+func analysisResponse(c *fiber.Ctx, result *services.AIAnalysisResult, job *services.AIAnalysisJob, err error) error {
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
 			"error": "分析失敗: " + err.Error(),
 		})
 	}
+	if job != nil {
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"success": true,
+			"message": "analysis queued",
+			"poll":    "/api/v1/ai/jobs/" + job.ID,
+			"data":    job,
+		})
+	}
 
 	return c.JSON(fiber.Map{
 		"success": true,
@@ -128,6 +310,102 @@ func (h *AIHandler) GetInvestmentAdvice(c *fiber.Ctx) error {
 	})
 }
 
+// GetAnalysisJob returns a queued analysis with its queue position and, once
+// completed, its result
+// GET /api/v1/ai/jobs/:id
+func (h *AIHandler) GetAnalysisJob(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	job, err := h.aiService.GetAnalysisJob(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// AnalyzeBatch analyses several symbols (e.g. a watchlist) with one analysis
+// type. Cached analyses are returned at once; the rest run through the queue
+// and are polled with GetAnalysisBatch.
+// POST /api/v1/ai/analyze-batch
+func (h *AIHandler) AnalyzeBatch(c *fiber.Ctx) error {
+	var req struct {
+		Symbols  []string `json:"symbols"`
+		Type     string   `json:"type"`
+		Language string   `json:"language"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+	if req.Type == "" {
+		req.Type = "daily_summary"
+	}
+
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	analysisType, ok := parseAnalysisType(req.Type)
+	if !ok {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid analysis type",
+			"valid": validAnalysisTypes,
+		})
+	}
+
+	language, err := services.ParseAILanguage(req.Language)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+			"valid": services.AILanguages,
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	batch, err := h.aiService.SubmitAnalysisBatch(c.Context(), userID, req.Symbols, analysisType, language)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": "批次分析失敗: " + err.Error(),
+		})
+	}
+
+	status := fiber.StatusAccepted
+	if batch.Status == "completed" {
+		status = fiber.StatusOK
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"success": true,
+		"poll":    "/api/v1/ai/batches/" + batch.ID,
+		"data":    batch,
+	})
+}
+
+// GetAnalysisBatch returns a batch analysis with each symbol's status and result
+// GET /api/v1/ai/batches/:id
+func (h *AIHandler) GetAnalysisBatch(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	batch, err := h.aiService.GetAnalysisBatch(c.Params("id"), userID)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    batch,
+	})
+}
+
 // GetCachedAnalyses returns all cached analyses for a symbol
 // GET /api/v1/ai/:symbol/history
 func (h *AIHandler) GetCachedAnalyses(c *fiber.Ctx) error {
@@ -186,12 +464,387 @@ func (h *AIHandler) ClearCache(c *fiber.Ctx) error {
 func (h *AIHandler) GetStatus(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success":    true,
-		"configured": h.aiService.HasAPIKey(),
+		"configured": h.aiService.IsConfigured(),
+		"provider":   h.aiService.ProviderName(),
+		"model":      h.aiService.ModelName(),
+		"queue":      h.aiService.QueueStats(),
+		"policy":     h.aiService.ContentPolicy(),
 		"message": func() string {
-			if h.aiService.HasAPIKey() {
+			if h.aiService.IsConfigured() {
 				return "AI 服務已啟用"
 			}
-			return "AI 服務未設定。請設定 GEMINI_API_KEY 環境變數。"
+			return "AI 服務未設定。請設定 AI_PROVIDER (gemini / openai / claude / ollama) 或對應的 API 金鑰。"
 		}(),
 	})
 }
+
+// GetTokenUsage returns token usage per provider, model and feature
+// GET /api/v1/ai/usage?days=30
+func (h *AIHandler) GetTokenUsage(c *fiber.Ctx) error {
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+	if days <= 0 || days > 366 {
+		days = 30
+	}
+
+	usage, err := h.aiService.GetTokenUsage(c.Context(), days)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "查詢失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"days":    days,
+		"count":   len(usage),
+		"data":    usage,
+	})
+}
+
+// GetDailyUsage returns token usage and estimated cost per day and user
+// GET /api/v1/ai/usage/daily?days=30&user_id=...
+func (h *AIHandler) GetDailyUsage(c *fiber.Ctx) error {
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+	if days <= 0 || days > 366 {
+		days = 30
+	}
+	var userID *uuid.UUID
+	if v := c.Query("user_id"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid user_id",
+			})
+		}
+		userID = &id
+	}
+
+	usage, err := h.aiService.GetDailyUsage(c.Context(), days, userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "查詢失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"days":    days,
+		"count":   len(usage),
+		"data":    usage,
+	})
+}
+
+// ListBudgets returns the monthly AI budgets with this month's usage
+// GET /api/v1/ai/budgets
+func (h *AIHandler) ListBudgets(c *fiber.Ctx) error {
+	budgets, err := h.aiService.ListBudgets(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(budgets),
+		"data":    budgets,
+	})
+}
+
+// SetBudget creates or replaces a monthly budget; omit user_id for the whole installation
+// PUT /api/v1/ai/budgets
+// Body: {"user_id": "...", "monthly_tokens": 2000000, "monthly_cost_usd": 5}
+func (h *AIHandler) SetBudget(c *fiber.Ctx) error {
+	var req services.AIUsageBudgetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	budget, err := h.aiService.SetBudget(c.Context(), req)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    budget,
+	})
+}
+
+// DeleteBudget removes a monthly budget
+// DELETE /api/v1/ai/budgets/:id
+func (h *AIHandler) DeleteBudget(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid budget id",
+		})
+	}
+
+	if err := h.aiService.DeleteBudget(c.Context(), id.String()); err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "budget deleted",
+	})
+}
+
+// Chat answers a question in a conversation about a symbol and/or portfolio;
+// omit session_id to start a new conversation
+// POST /api/v1/ai/chat
+// Body: {"session_id": "...", "message": "法說會後該續抱嗎？", "symbol": "2330", "portfolio_id": "..."}
+func (h *AIHandler) Chat(c *fiber.Ctx) error {
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	var req services.AIChatRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	// For demo, use hardcoded user ID
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	reply, err := h.aiService.Chat(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    reply,
+	})
+}
+
+// ListChatSessions returns the user's conversations, most recent first
+// GET /api/v1/ai/chat/sessions?limit=20
+func (h *AIHandler) ListChatSessions(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+
+	sessions, err := h.aiService.ListChatSessions(c.Context(), userID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(sessions),
+		"data":    sessions,
+	})
+}
+
+// GetChatSession returns a conversation with its latest messages
+// GET /api/v1/ai/chat/sessions/:id
+func (h *AIHandler) GetChatSession(c *fiber.Ctx) error {
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid session id",
+		})
+	}
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	session, err := h.aiService.GetChatSession(c.Context(), userID, sessionID)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    session,
+	})
+}
+
+// DeleteChatSession deletes a conversation
+// DELETE /api/v1/ai/chat/sessions/:id
+func (h *AIHandler) DeleteChatSession(c *fiber.Ctx) error {
+	sessionID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid session id",
+		})
+	}
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.aiService.DeleteChatSession(c.Context(), userID, sessionID); err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "chat session deleted",
+	})
+}
+
+// ListPromptTemplates returns the prompt template in use for each analysis type
+// GET /api/v1/ai/prompts
+func (h *AIHandler) ListPromptTemplates(c *fiber.Ctx) error {
+	templates := h.aiService.ListActivePromptTemplates(c.Context())
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(templates),
+		"data":    templates,
+	})
+}
+
+// GetPromptTemplateHistory returns every version of an analysis type's prompts, newest first
+// GET /api/v1/ai/prompts/:type
+func (h *AIHandler) GetPromptTemplateHistory(c *fiber.Ctx) error {
+	templates, err := h.aiService.GetPromptTemplateHistory(c.Context(), services.AnalysisType(c.Params("type")))
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(templates),
+		"data":    templates,
+	})
+}
+
+// CreatePromptTemplate stores a new version of an analysis type's prompts
+// POST /api/v1/ai/prompts/:type
+// Body: {"system_prompt": "...", "user_prompt": "{{context}}\n---\n請...", "note": "...", "activate": true}
+func (h *AIHandler) CreatePromptTemplate(c *fiber.Ctx) error {
+	var req services.AIPromptTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	template, err := h.aiService.CreatePromptTemplate(c.Context(), services.AnalysisType(c.Params("type")), req)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// ActivatePromptTemplate switches an analysis type to another stored version;
+// version 0 reverts to the built-in prompts
+// POST /api/v1/ai/prompts/:type/activate
+// Body: {"version": 2}
+func (h *AIHandler) ActivatePromptTemplate(c *fiber.Ctx) error {
+	var req struct {
+		Version *int `json:"version"`
+	}
+	if err := c.BodyParser(&req); err != nil || req.Version == nil || *req.Version < 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "version is required",
+		})
+	}
+
+	template, err := h.aiService.ActivatePromptTemplate(c.Context(), services.AnalysisType(c.Params("type")), *req.Version)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    template,
+	})
+}
+
+// GetTodayDigest returns today's daily digest, writing it on demand when the
+// post-close job has not run yet
+// GET /api/v1/ai/digest/today
+func (h *AIHandler) GetTodayDigest(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	today := services.TaipeiToday()
+
+	digest, err := h.aiService.GetDigest(c.Context(), userID, today)
+	if err != nil && strings.Contains(err.Error(), "not found") && h.aiService.IsConfigured() {
+		digest, err = h.aiService.GenerateDigest(c.Context(), userID, today)
+	}
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    digest,
+	})
+}
+
+// GenerateDigest rewrites today's daily digest and optionally sends it to the
+// user's webhooks
+// POST /api/v1/ai/digest/generate?deliver=true
+func (h *AIHandler) GenerateDigest(c *fiber.Ctx) error {
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	digest, err := h.aiService.GenerateDigest(c.Context(), userID, services.TaipeiToday())
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if c.QueryBool("deliver", false) {
+		if err := h.digestWorker.Deliver(c.Context(), digest); err != nil {
+			digest.DeliveryError = err.Error()
+		} else {
+			now := time.Now()
+			digest.DeliveredAt = &now
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    digest,
+	})
+}
+
+func aiErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "budget exceeded"), strings.Contains(msg, "too many AI requests"):
+		return fiber.StatusTooManyRequests
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
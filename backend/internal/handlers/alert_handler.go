@@ -3,22 +3,29 @@ package handlers
 import (
 	"psm-backend/internal/services"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // AlertHandler handles alert endpoints
 type AlertHandler struct {
-	alertService *services.AlertService
+	alertService    *services.AlertService
+	scanWorker      *services.AlertScanWorker
+	retentionWorker *services.AlertRetentionWorker
 }
 
-func NewAlertHandler(alertService *services.AlertService) *AlertHandler {
+func NewAlertHandler(alertService *services.AlertService, scanWorker *services.AlertScanWorker, retentionWorker *services.AlertRetentionWorker) *AlertHandler {
 	return &AlertHandler{
-		alertService: alertService,
+		alertService:    alertService,
+		scanWorker:      scanWorker,
+		retentionWorker: retentionWorker,
 	}
 }
 
-// GetAlerts retrieves alerts
+// GetAlerts retrieves alerts; snoozed alerts only with include_snoozed=true
 // GET /api/v1/alerts
 func (h *AlertHandler) GetAlerts(c *fiber.Ctx) error {
 	symbol := c.Query("symbol", "")
@@ -31,7 +38,8 @@ func (h *AlertHandler) GetAlerts(c *fiber.Ctx) error {
 		}
 	}
 
-	alerts, err := h.alertService.GetAlerts(c.Context(), symbol, unacknowledgedOnly, limit)
+	includeSnoozed := c.Query("include_snoozed", "false") == "true"
+	alerts, err := h.alertService.GetAlerts(c.Context(), symbol, unacknowledgedOnly, includeSnoozed, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "查詢警報失敗: " + err.Error(),
@@ -63,7 +71,8 @@ func (h *AlertHandler) GetAlertsBySymbol(c *fiber.Ctx) error {
 		}
 	}
 
-	alerts, err := h.alertService.GetAlerts(c.Context(), symbol, unacknowledgedOnly, limit)
+	includeSnoozed := c.Query("include_snoozed", "false") == "true"
+	alerts, err := h.alertService.GetAlerts(c.Context(), symbol, unacknowledgedOnly, includeSnoozed, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "查詢警報失敗: " + err.Error(),
@@ -99,8 +108,104 @@ func (h *AlertHandler) AcknowledgeAlert(c *fiber.Ctx) error {
 	})
 }
 
-// GetAlertStats returns alert statistics
-// GET /api/v1/alerts/stats
+// AcknowledgeAll acknowledges every unacknowledged alert matching the filters
+// POST /api/v1/alerts/ack-all
+// Body: {"symbol": "2330", "alert_type": "volume_spike", "severity": "info", "before": "2026-01-02T00:00:00+08:00"}
+func (h *AlertHandler) AcknowledgeAll(c *fiber.Ctx) error {
+	var filter services.AlertAckFilter
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&filter); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	count, err := h.alertService.AcknowledgeAlerts(c.Context(), filter)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "failed to") {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": "確認警報失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   count,
+		"message": "警報已確認",
+	})
+}
+
+// SnoozeAlert hides an alert, and new alerts for its symbol and type, until a
+// time (default 24 hours from now)
+// POST /api/v1/alerts/:id/snooze
+// Body: {"until": "2026-01-02T09:00:00+08:00"} or {"hours": 24}
+func (h *AlertHandler) SnoozeAlert(c *fiber.Ctx) error {
+	var req struct {
+		Until *time.Time `json:"until"`
+		Hours float64    `json:"hours"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	until := time.Now().Add(24 * time.Hour)
+	if req.Until != nil {
+		until = *req.Until
+	} else if req.Hours > 0 {
+		until = time.Now().Add(time.Duration(req.Hours * float64(time.Hour)))
+	}
+
+	alert, err := h.alertService.SnoozeAlert(c.Context(), c.Params("id"), until)
+	if err != nil {
+		return c.Status(alertSnoozeErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    alert,
+	})
+}
+
+// UnsnoozeAlert ends an alert's snooze
+// DELETE /api/v1/alerts/:id/snooze
+func (h *AlertHandler) UnsnoozeAlert(c *fiber.Ctx) error {
+	alert, err := h.alertService.UnsnoozeAlert(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(alertSnoozeErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    alert,
+	})
+}
+
+func alertSnoozeErrorStatus(err error) int {
+	switch msg := err.Error(); {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
+
+// GetAlertStats returns alert statistics, broken down by type, symbol and
+// rule, and the background scanner's last runs
+// GET /api/v1/alerts/stats?days=7&top=10
 func (h *AlertHandler) GetAlertStats(c *fiber.Ctx) error {
 	days := 7
 	if daysStr := c.Query("days"); daysStr != "" {
@@ -108,8 +213,9 @@ func (h *AlertHandler) GetAlertStats(c *fiber.Ctx) error {
 			days = d
 		}
 	}
+	top, _ := strconv.Atoi(c.Query("top", "10"))
 
-	stats, err := h.alertService.GetAlertStats(c.Context(), days)
+	stats, err := h.alertService.GetAlertStats(c.Context(), days, top)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "查詢統計失敗: " + err.Error(),
@@ -119,6 +225,7 @@ func (h *AlertHandler) GetAlertStats(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    stats,
+		"scanner": h.scanWorker.GetStatus(c.Context()),
 	})
 }
 
@@ -132,7 +239,7 @@ func (h *AlertHandler) DetectVolumeSpike(c *fiber.Ctx) error {
 		})
 	}
 
-	threshold := 2.0
+	threshold := 0.0 // Configured volume_spike_ratio
 	if threshStr := c.Query("threshold"); threshStr != "" {
 		if t, err := strconv.ParseFloat(threshStr, 64); err == nil && t > 0 {
 			threshold = t
@@ -175,19 +282,89 @@ func (h *AlertHandler) DetectPriceBreakout(c *fiber.Ctx) error {
 	})
 }
 
-// ScanAll scans all symbols for anomalies
-// POST /api/v1/alerts/scan
+// DetectMABreakout detects the close crossing MA20/MA60 for a symbol
+// GET /api/v1/alerts/:symbol/ma
+func (h *AlertHandler) DetectMABreakout(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	analysis, err := h.alertService.DetectMABreakout(c.Context(), symbol)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "分析失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    analysis,
+	})
+}
+
+// DetectRSIExtreme detects RSI(14) overbought/oversold for a symbol
+// GET /api/v1/alerts/:symbol/rsi?overbought=70&oversold=30
+func (h *AlertHandler) DetectRSIExtreme(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	// 0 = the configured default
+	overbought, _ := strconv.ParseFloat(c.Query("overbought"), 64)
+	oversold, _ := strconv.ParseFloat(c.Query("oversold"), 64)
+	if overbought < 0 || overbought > 100 || oversold < 0 || oversold > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "thresholds must be between 0 and 100",
+		})
+	}
+
+	analysis, err := h.alertService.DetectRSIExtreme(c.Context(), symbol, overbought, oversold)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.Contains(err.Error(), "threshold") {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": "分析失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    analysis,
+	})
+}
+
+// ScanAll scans the symbols in scope for anomalies
+// POST /api/v1/alerts/scan?scope=portfolio:<id>&threshold=2
 func (h *AlertHandler) ScanAll(c *fiber.Ctx) error {
-	threshold := 2.0
+	threshold := 0.0 // Configured volume_spike_ratio
 	if threshStr := c.Query("threshold"); threshStr != "" {
 		if t, err := strconv.ParseFloat(threshStr, 64); err == nil && t > 0 {
 			threshold = t
 		}
 	}
 
-	result, err := h.alertService.ScanAllSymbols(c.Context(), threshold)
+	scope, err := services.ParseAlertScanScope(c.Query("scope"))
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	result, err := h.alertService.ScanAllSymbols(c.Context(), threshold, scope)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
 			"error": "掃描失敗: " + err.Error(),
 		})
 	}
@@ -197,3 +374,359 @@ func (h *AlertHandler) ScanAll(c *fiber.Ctx) error {
 		"data":    result,
 	})
 }
+
+// GetAlertVolume returns alerts raised per day, including archived ones
+// GET /api/v1/alerts/volume?days=30
+func (h *AlertHandler) GetAlertVolume(c *fiber.Ctx) error {
+	days, _ := strconv.Atoi(c.Query("days", "30"))
+
+	volume, err := h.alertService.GetAlertVolume(c.Context(), days)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "查詢統計失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    volume,
+	})
+}
+
+// GetRetentionStatus returns the alert retention policy and the last cleanup run
+// GET /api/v1/alerts/retention
+func (h *AlertHandler) GetRetentionStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.retentionWorker.GetStatus(),
+	})
+}
+
+// RunCleanup applies the alert retention policy now; body fields override the configured policy
+// POST /api/v1/alerts/cleanup
+// Body: {"retention_days": 90, "unacknowledged_days": 0, "archive": true, "dry_run": true}
+func (h *AlertHandler) RunCleanup(c *fiber.Ctx) error {
+	return runRetentionCleanup(c, h.retentionWorker)
+}
+
+// GetThresholds returns the detector thresholds
+// GET /api/v1/alerts/thresholds
+func (h *AlertHandler) GetThresholds(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.alertService.Thresholds(c.Context()),
+	})
+}
+
+// UpdateThresholds changes detector thresholds
+// PUT /api/v1/alerts/thresholds
+// Body: {"volume_warning_ratio": 4, "price_52w_proximity_pct": 2}
+func (h *AlertHandler) UpdateThresholds(c *fiber.Ctx) error {
+	var changes map[string]float64
+	if err := c.BodyParser(&changes); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	thresholds, err := h.alertService.UpdateThresholds(c.Context(), changes)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "failed to") {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    thresholds,
+	})
+}
+
+// alertRuleRequest is the body of rule create and update requests
+type alertRuleRequest struct {
+	Name       string                   `json:"name"`
+	Symbol     string                   `json:"symbol"`
+	Metric     string                   `json:"metric"`
+	Operator   string                   `json:"operator"`
+	Threshold  float64                  `json:"threshold"`
+	Conditions *services.AlertCondition `json:"conditions"` // Replaces metric, operator and threshold
+	StrategyID string                   `json:"strategy_id"`
+	Version    int                      `json:"strategy_version"` // Default the strategy's current version
+	Severity   string                   `json:"severity"`
+	Enabled    *bool                    `json:"enabled"` // Default true
+}
+
+func (r *alertRuleRequest) rule() *services.AlertRule {
+	rule := &services.AlertRule{
+		Name:            r.Name,
+		Symbol:          r.Symbol,
+		Metric:          r.Metric,
+		Operator:        r.Operator,
+		Threshold:       r.Threshold,
+		Conditions:      r.Conditions,
+		StrategyID:      r.StrategyID,
+		StrategyVersion: r.Version,
+		Severity:        services.AlertSeverity(r.Severity),
+		Enabled:         true,
+	}
+	if r.Enabled != nil {
+		rule.Enabled = *r.Enabled
+	}
+	return rule
+}
+
+// ListAlertRules returns the user's alert rules, optionally those of one strategy
+// GET /api/v1/alerts/rules?strategy_id=...
+func (h *AlertHandler) ListAlertRules(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	rules, err := h.alertService.ListAlertRules(c.Context(), userID, c.Query("strategy_id"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(rules),
+		"data":    rules,
+	})
+}
+
+// GetAlertRule returns one alert rule
+// GET /api/v1/alerts/rules/:id
+func (h *AlertHandler) GetAlertRule(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	rule, err := h.alertService.GetAlertRule(c.Context(), userID, c.Params("id"))
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// CreateAlertRule creates a threshold rule evaluated by the alert scanner
+// POST /api/v1/alerts/rules
+// Body: {"symbol": "2330", "metric": "price", "operator": ">=", "threshold": 1100}
+// or {"symbol": "2330", "conditions": {"op": "and", "conditions": [{"metric": "change_percent", "operator": ">", "threshold": 3}, ...]}}
+// (metric: price, change_percent, volume, volume_ratio, ma20_distance, ma60_distance, rsi14;
+// no symbol = held and watched symbols)
+func (h *AlertHandler) CreateAlertRule(c *fiber.Ctx) error {
+	var req alertRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	rule, err := h.alertService.CreateAlertRule(c.Context(), userID, req.rule())
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// UpdateAlertRule replaces an alert rule's definition
+// PUT /api/v1/alerts/rules/:id
+func (h *AlertHandler) UpdateAlertRule(c *fiber.Ctx) error {
+	var req alertRuleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	rule, err := h.alertService.UpdateAlertRule(c.Context(), userID, c.Params("id"), req.rule())
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// EnableAlertRule turns a rule on
+// POST /api/v1/alerts/rules/:id/enable
+func (h *AlertHandler) EnableAlertRule(c *fiber.Ctx) error {
+	return h.setAlertRuleEnabled(c, true)
+}
+
+// DisableAlertRule turns a rule off without deleting it
+// POST /api/v1/alerts/rules/:id/disable
+func (h *AlertHandler) DisableAlertRule(c *fiber.Ctx) error {
+	return h.setAlertRuleEnabled(c, false)
+}
+
+func (h *AlertHandler) setAlertRuleEnabled(c *fiber.Ctx, enabled bool) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	rule, err := h.alertService.SetAlertRuleEnabled(c.Context(), userID, c.Params("id"), enabled)
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    rule,
+	})
+}
+
+// DeleteAlertRule removes an alert rule
+// DELETE /api/v1/alerts/rules/:id
+func (h *AlertHandler) DeleteAlertRule(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.alertService.DeleteAlertRule(c.Context(), userID, c.Params("id")); err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "規則已刪除",
+	})
+}
+
+// ListPositionLevels returns the stop-loss and take-profit levels set on the user's positions
+// GET /api/v1/alerts/position-levels?portfolio_id=...
+func (h *AlertHandler) ListPositionLevels(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	levels, err := h.alertService.ListPositionAlertLevels(c.Context(), userID, c.Query("portfolio_id"))
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(levels),
+		"data":    levels,
+	})
+}
+
+// GetPositionLevels returns the levels set on one position
+// GET /api/v1/portfolios/:portfolio_id/positions/:symbol/alert-levels
+func (h *AlertHandler) GetPositionLevels(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid portfolio_id",
+		})
+	}
+
+	level, err := h.alertService.GetPositionAlertLevel(c.Context(), userID, portfolioID, c.Params("symbol"))
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    level,
+	})
+}
+
+// SetPositionLevels sets a position's stop-loss and take-profit prices; the
+// intraday scanner raises a critical alert when the price touches one
+// PUT /api/v1/portfolios/:portfolio_id/positions/:symbol/alert-levels
+// Body: {"stop_loss": 520, "take_profit": 680, "notify": true}
+func (h *AlertHandler) SetPositionLevels(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid portfolio_id",
+		})
+	}
+
+	var req struct {
+		StopLoss   *float64 `json:"stop_loss"`
+		TakeProfit *float64 `json:"take_profit"`
+		Notify     *bool    `json:"notify"` // Default true
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+	notify := req.Notify == nil || *req.Notify
+
+	level, err := h.alertService.SetPositionAlertLevel(c.Context(), userID, portfolioID, c.Params("symbol"),
+		req.StopLoss, req.TakeProfit, notify)
+	if err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    level,
+	})
+}
+
+// DeletePositionLevels removes a position's stop-loss and take-profit levels
+// DELETE /api/v1/portfolios/:portfolio_id/positions/:symbol/alert-levels
+func (h *AlertHandler) DeletePositionLevels(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid portfolio_id",
+		})
+	}
+
+	if err := h.alertService.DeletePositionAlertLevel(c.Context(), userID, portfolioID, c.Params("symbol")); err != nil {
+		return c.Status(alertRuleErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+func alertRuleErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
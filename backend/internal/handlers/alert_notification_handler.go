@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"strconv"
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// AlertNotificationHandler handles alert notification settings endpoints
+type AlertNotificationHandler struct {
+	service *services.AlertNotificationService
+}
+
+func NewAlertNotificationHandler(service *services.AlertNotificationService) *AlertNotificationHandler {
+	return &AlertNotificationHandler{service: service}
+}
+
+// GetEmailSettings returns the user's alert email settings
+// GET /api/v1/alerts/notifications/email
+func (h *AlertNotificationHandler) GetEmailSettings(c *fiber.Ctx) error {
+	// For demo, use hardcoded user ID
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	settings, err := h.service.Email().GetSettings(c.Context(), userID)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// UpdateEmailSettings saves the user's alert email settings
+// PUT /api/v1/alerts/notifications/email
+// Body: {"enabled": true, "email": "me@example.com", "severities": ["warning", "critical"], "alert_types": []}
+func (h *AlertNotificationHandler) UpdateEmailSettings(c *fiber.Ctx) error {
+	var req services.AlertEmailSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	settings, err := h.service.Email().UpdateSettings(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// TestEmail sends a sample alert email to the user's address
+// POST /api/v1/alerts/notifications/email/test
+func (h *AlertNotificationHandler) TestEmail(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.service.Email().SendTest(c.Context(), userID); err != nil {
+		status := alertNotificationErrorStatus(err)
+		if status == fiber.StatusBadRequest {
+			// The mail server rejected or could not be reached
+			status = fiber.StatusBadGateway
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "test email sent",
+	})
+}
+
+// GetLineSettings returns the user's LINE Notify settings
+// GET /api/v1/alerts/notifications/line
+func (h *AlertNotificationHandler) GetLineSettings(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	settings, err := h.service.Line().GetSettings(c.Context(), userID)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// UpdateLineSettings registers a LINE Notify token or changes the settings
+// PUT /api/v1/alerts/notifications/line
+// Body: {"token": "...", "severities": ["critical"], "alert_types": [], "digest": true}
+func (h *AlertNotificationHandler) UpdateLineSettings(c *fiber.Ctx) error {
+	var req services.AlertLineSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	settings, err := h.service.Line().UpdateSettings(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// DeleteLineSettings removes the user's LINE Notify token
+// DELETE /api/v1/alerts/notifications/line
+func (h *AlertNotificationHandler) DeleteLineSettings(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.service.Line().DeleteSettings(c.Context(), userID); err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "LINE Notify token removed",
+	})
+}
+
+// TestLine sends a test message to the user's LINE
+// POST /api/v1/alerts/notifications/line/test
+func (h *AlertNotificationHandler) TestLine(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.service.Line().SendTest(c.Context(), userID); err != nil {
+		status := alertNotificationErrorStatus(err)
+		if status == fiber.StatusBadRequest {
+			status = fiber.StatusBadGateway
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "test message sent",
+	})
+}
+
+// GetTelegramSettings returns the user's Telegram settings
+// GET /api/v1/alerts/notifications/telegram
+func (h *AlertNotificationHandler) GetTelegramSettings(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	settings, err := h.service.Telegram().GetSettings(c.Context(), userID)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// UpdateTelegramSettings links a Telegram chat or changes the settings. A new
+// chat receives a confirmation message from the bot.
+// PUT /api/v1/alerts/notifications/telegram
+// Body: {"chat_id": "123456789", "severities": ["warning", "critical"], "alert_types": ["user_rule"], "digest": true}
+func (h *AlertNotificationHandler) UpdateTelegramSettings(c *fiber.Ctx) error {
+	var req services.AlertTelegramSettingsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	settings, err := h.service.Telegram().UpdateSettings(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    settings,
+	})
+}
+
+// DeleteTelegramSettings unlinks the user's Telegram chat
+// DELETE /api/v1/alerts/notifications/telegram
+func (h *AlertNotificationHandler) DeleteTelegramSettings(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.service.Telegram().DeleteSettings(c.Context(), userID); err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "Telegram chat unlinked",
+	})
+}
+
+// ListWebhooks returns the user's alert webhooks
+// GET /api/v1/alerts/notifications/webhooks
+func (h *AlertNotificationHandler) ListWebhooks(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	hooks, err := h.service.Webhooks().ListWebhooks(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(hooks),
+		"data":    hooks,
+	})
+}
+
+// CreateWebhook registers an alert webhook. The response holds the signing
+// secret, which is not shown again.
+// POST /api/v1/alerts/notifications/webhooks
+// Body: {"url": "https://example.com/hook", "secret": "optional", "severities": ["critical"], "alert_types": []}
+func (h *AlertNotificationHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req services.AlertWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	hook, err := h.service.Webhooks().CreateWebhook(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    hook,
+	})
+}
+
+// UpdateWebhook changes an alert webhook; "secret": "" rotates the secret
+// PUT /api/v1/alerts/notifications/webhooks/:id
+// Body: {"url": "...", "secret": "", "severities": [...], "alert_types": [...], "is_active": false}
+func (h *AlertNotificationHandler) UpdateWebhook(c *fiber.Ctx) error {
+	var req services.AlertWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	hook, err := h.service.Webhooks().UpdateWebhook(c.Context(), userID, c.Params("id"), req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    hook,
+	})
+}
+
+// DeleteWebhook removes an alert webhook and its delivery log
+// DELETE /api/v1/alerts/notifications/webhooks/:id
+func (h *AlertNotificationHandler) DeleteWebhook(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.service.Webhooks().DeleteWebhook(c.Context(), userID, c.Params("id")); err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "webhook deleted",
+	})
+}
+
+// TestWebhook sends a signed ping to the webhook and returns the logged
+// delivery, including the endpoint's response
+// POST /api/v1/alerts/notifications/webhooks/:id/test
+func (h *AlertNotificationHandler) TestWebhook(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	delivery, err := h.service.Webhooks().TestWebhook(c.Context(), userID, c.Params("id"))
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": delivery.Status == "delivered",
+		"data":    delivery,
+	})
+}
+
+// ListWebhookDeliveries returns a webhook's delivery log
+// GET /api/v1/alerts/notifications/webhooks/:id/deliveries?status=failed&limit=50
+func (h *AlertNotificationHandler) ListWebhookDeliveries(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+
+	deliveries, err := h.service.Webhooks().ListDeliveries(c.Context(), userID, c.Params("id"), c.Query("status"), limit)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(deliveries),
+		"data":    deliveries,
+	})
+}
+
+// RedeliverWebhook sends a logged delivery again right away
+// POST /api/v1/alerts/notifications/webhooks/:id/deliveries/:deliveryId/redeliver
+func (h *AlertNotificationHandler) RedeliverWebhook(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	delivery, err := h.service.Webhooks().Redeliver(c.Context(), userID, c.Params("id"), c.Params("deliveryId"))
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": delivery.Status == "delivered",
+		"data":    delivery,
+	})
+}
+
+// GetSchedule returns the user's notification schedule
+// GET /api/v1/alerts/notifications/schedule
+func (h *AlertNotificationHandler) GetSchedule(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	schedule, err := h.service.GetSchedule(c.Context(), userID)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    schedule,
+	})
+}
+
+// UpdateSchedule saves the user's notification schedule; alerts outside the
+// window are held until it opens
+// PUT /api/v1/alerts/notifications/schedule
+// Body: {"enabled": true, "window_start": "08:30", "window_end": "14:00", "trading_days_only": true, "allow_critical": true, "digest": true}
+func (h *AlertNotificationHandler) UpdateSchedule(c *fiber.Ctx) error {
+	var req services.AlertNotificationScheduleRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	schedule, err := h.service.UpdateSchedule(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    schedule,
+	})
+}
+
+// ListDeliveries returns the user's recent alert notifications
+// GET /api/v1/alerts/notifications/deliveries?channel=email&status=retrying&limit=50
+func (h *AlertNotificationHandler) ListDeliveries(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	limit, _ := strconv.Atoi(c.Query("limit", "50"))
+
+	deliveries, err := h.service.ListDeliveries(c.Context(), userID, c.Query("channel"), c.Query("status"), limit)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(deliveries),
+		"data":    deliveries,
+	})
+}
+
+func alertNotificationErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not configured"):
+		return fiber.StatusServiceUnavailable
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.Contains(msg, "already exists"):
+		return fiber.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
+
+// GetEscalationPolicy returns the user's alert escalation policy
+// GET /api/v1/alerts/notifications/escalation
+func (h *AlertNotificationHandler) GetEscalationPolicy(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	policy, err := h.service.GetEscalationPolicy(c.Context(), userID)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    policy,
+	})
+}
+
+// UpdateEscalationPolicy saves how each severity is pushed across all channels
+// PUT /api/v1/alerts/notifications/escalation
+// Body: {"enabled": true, "critical": "immediate", "warning": "hourly", "info": "in_app"}
+func (h *AlertNotificationHandler) UpdateEscalationPolicy(c *fiber.Ctx) error {
+	var req services.AlertEscalationPolicyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	policy, err := h.service.UpdateEscalationPolicy(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(alertNotificationErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    policy,
+	})
+}
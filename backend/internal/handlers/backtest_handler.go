@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"psm-backend/internal/services"
+)
+
+// BacktestHandler handles strategy backtesting endpoints
+type BacktestHandler struct {
+	service *services.BacktestService
+}
+
+func NewBacktestHandler(service *services.BacktestService) *BacktestHandler {
+	return &BacktestHandler{service: service}
+}
+
+// SubmitBacktest queues a backtest job
+// POST /api/v1/backtest
+// Body: {"symbol": "2330", "start_date": "2023-01-01", "end_date": "2024-12-31",
+// "entry": {"left": "MA(5)", "op": "crosses_above", "right": "MA(20)"},
+// "exit": {"expression": "close < MA(20)"}, "stop_loss_pct": 8}
+// Rules may instead come from a saved strategy: {"strategy_id": "...", "strategy_version": 2, ...}
+func (h *BacktestHandler) SubmitBacktest(c *fiber.Ctx) error {
+	var req services.BacktestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	job, err := h.service.SubmitBacktest(c.Context(), &req)
+	if err != nil {
+		return c.Status(backtestErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "backtest queued",
+		"data":    job,
+	})
+}
+
+// GetBacktest returns the status and, once completed, the result of a backtest job
+// GET /api/v1/backtest/:id
+func (h *BacktestHandler) GetBacktest(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "id is required",
+		})
+	}
+
+	job, err := h.service.GetJob(c.Context(), id)
+	if err != nil {
+		return c.Status(backtestErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    job,
+	})
+}
+
+// backtestErrorStatus maps backtest service errors to HTTP status codes
+func backtestErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrBacktestInvalid):
+		return fiber.StatusBadRequest
+	case errors.Is(err, services.ErrBacktestJobNotFound), errors.Is(err, services.ErrStrategyVersionNotFound):
+		return fiber.StatusNotFound
+	}
+	return fiber.StatusInternalServerError
+}
+
+// ListBacktests returns recent backtest jobs
+// GET /api/v1/backtest?symbol=2330&strategy_id=...&limit=20
+func (h *BacktestHandler) ListBacktests(c *fiber.Ctx) error {
+	symbol := strings.ToUpper(c.Query("symbol"))
+	strategyID := c.Query("strategy_id")
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	jobs, err := h.service.ListJobs(c.Context(), symbol, strategyID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to list backtests",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(jobs),
+		"data":    jobs,
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"sync"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 type BulkSyncHandler struct {
 	service         *services.MarketDataService
 	bulkSyncService *services.BulkSyncService
+	taService       *services.TechnicalAnalysisService
 	db              *database.DB
 	syncStatus      *SyncStatus
 	mu              sync.RWMutex
@@ -39,10 +41,11 @@ type SyncStatus struct {
 	EstimatedTime   string    `json:"estimated_time,omitempty"`
 }
 
-func NewBulkSyncHandler(service *services.MarketDataService, db *database.DB) *BulkSyncHandler {
+func NewBulkSyncHandler(service *services.MarketDataService, taService *services.TechnicalAnalysisService, db *database.DB) *BulkSyncHandler {
 	return &BulkSyncHandler{
 		service:         service,
 		bulkSyncService: services.NewBulkSyncService(db),
+		taService:       taService,
 		db:              db,
 		syncStatus: &SyncStatus{
 			IsRunning: false,
@@ -316,9 +319,29 @@ func (h *BulkSyncHandler) runDateBasedBulkSync(startDate, endDate time.Time, ski
 		time.Sleep(rateLimitDelay)
 	}
 
+	// Keep the TAIEX benchmark in step with the synced range (used for beta)
+	if indexData, err := h.service.FetchIndexDaily(ctx, startDate, endDate); err != nil {
+		log.Printf("TAIEX index sync failed: %v", err)
+	} else if err := h.service.SaveOHLCV(ctx, indexData); err != nil {
+		log.Printf("TAIEX index save failed: %v", err)
+	}
+
 	// Refresh aggregates at the end
 	h.service.RefreshContinuousAggregates(ctx)
 
+	// Persist daily indicator snapshots for the screener
+	if h.taService != nil {
+		h.mu.Lock()
+		h.syncStatus.EstimatedTime = "computing indicator snapshots"
+		h.mu.Unlock()
+
+		if result, err := h.taService.RefreshSnapshots(ctx); err != nil {
+			log.Printf("Indicator snapshot refresh failed: %v", err)
+		} else {
+			log.Printf("Indicator snapshots refreshed: %d saved, %d failed", result.Saved, result.Failed)
+		}
+	}
+
 	h.mu.Lock()
 	h.syncStatus.IsRunning = false
 	h.syncStatus.CompletedAt = time.Now()
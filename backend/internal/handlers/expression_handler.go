@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"psm-backend/internal/services"
+)
+
+// ExpressionHandler handles custom indicator expression endpoints
+type ExpressionHandler struct {
+	service *services.ExpressionService
+}
+
+func NewExpressionHandler(service *services.ExpressionService) *ExpressionHandler {
+	return &ExpressionHandler{service: service}
+}
+
+// EvaluateExpression evaluates an ad-hoc or saved expression for a symbol
+// GET /api/v1/indicators/:symbol/expression?expr=close>MA(20)&limit=100
+// GET /api/v1/indicators/:symbol/expression?id=<saved expression id>
+func (h *ExpressionHandler) EvaluateExpression(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	expr := c.Query("expr")
+	id := c.Query("id")
+	limit := c.QueryInt("limit", 100)
+
+	if expr == "" && id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "expr or id is required",
+		})
+	}
+	if limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	var result *services.ExpressionResult
+	var err error
+	if id != "" {
+		result, err = h.service.EvaluateByID(c.Context(), symbol, id, limit)
+	} else {
+		result, err = h.service.Evaluate(c.Context(), symbol, expr, limit)
+	}
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "invalid expression") {
+			status = fiber.StatusBadRequest
+		} else if strings.Contains(err.Error(), "not found") {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "failed to evaluate expression",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"symbol":    symbol,
+		"indicator": "EXPRESSION",
+		"count":     len(result.Values),
+		"data":      result,
+	})
+}
+
+// ValidateExpression checks the syntax of an expression without evaluating it
+// POST /api/v1/indicators/expressions/validate
+func (h *ExpressionHandler) ValidateExpression(c *fiber.Ctx) error {
+	var req struct {
+		Expression string `json:"expression"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	parsed, err := services.ParseExpression(req.Expression)
+	if err != nil {
+		return c.JSON(fiber.Map{
+			"success": true,
+			"valid":   false,
+			"error":   err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":    true,
+		"valid":      true,
+		"is_boolean": parsed.IsBoolean(),
+		"lookback":   parsed.Lookback(),
+	})
+}
+
+// ListExpressions returns all saved expressions
+// GET /api/v1/indicators/expressions
+func (h *ExpressionHandler) ListExpressions(c *fiber.Ctx) error {
+	expressions, err := h.service.ListExpressions(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to list expressions",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(expressions),
+		"data":    expressions,
+	})
+}
+
+// CreateExpression saves a named expression
+// POST /api/v1/indicators/expressions
+func (h *ExpressionHandler) CreateExpression(c *fiber.Ctx) error {
+	var req struct {
+		Name        string `json:"name"`
+		Expression  string `json:"expression"`
+		Description string `json:"description"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	if req.Name == "" || req.Expression == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "name and expression are required",
+		})
+	}
+
+	saved, err := h.service.CreateExpression(c.Context(), req.Name, req.Expression, req.Description)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "invalid expression") {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error":   "failed to save expression",
+			"details": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    saved,
+	})
+}
+
+// DeleteExpression removes a saved expression
+// DELETE /api/v1/indicators/expressions/:id
+func (h *ExpressionHandler) DeleteExpression(c *fiber.Ctx) error {
+	id := c.Params("id")
+	if id == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "id is required",
+		})
+	}
+
+	if err := h.service.DeleteExpression(c.Context(), id); err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "failed to delete expression",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "expression deleted",
+	})
+}
@@ -3,17 +3,19 @@ package handlers
 import (
 	"context"
 	"strconv"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"psm-backend/internal/services"
 )
 
 type IndicatorHandler struct {
-	service *services.TechnicalAnalysisService
+	service          *services.TechnicalAnalysisService
+	precomputeWorker *services.IndicatorPrecomputeWorker
 }
 
-func NewIndicatorHandler(service *services.TechnicalAnalysisService) *IndicatorHandler {
-	return &IndicatorHandler{service: service}
+func NewIndicatorHandler(service *services.TechnicalAnalysisService, precomputeWorker *services.IndicatorPrecomputeWorker) *IndicatorHandler {
+	return &IndicatorHandler{service: service, precomputeWorker: precomputeWorker}
 }
 
 // GetMA calculates Moving Average
@@ -223,6 +225,168 @@ func (h *IndicatorHandler) GetKDJ(c *fiber.Ctx) error {
 	})
 }
 
+// GetBBSqueeze calculates Bollinger band width and squeeze state
+// GET /api/v1/indicators/:symbol/bb-squeeze?period=20&stddev=2&lookback=120&threshold=10&limit=100
+func (h *IndicatorHandler) GetBBSqueeze(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	period := c.QueryInt("period", 20)
+	lookback := c.QueryInt("lookback", 120)
+	limit := c.QueryInt("limit", 100)
+	stdDev, err := strconv.ParseFloat(c.Query("stddev", "2.0"), 64)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid stddev parameter",
+		})
+	}
+	threshold, err := strconv.ParseFloat(c.Query("threshold", "10"), 64)
+	if err != nil || threshold < 0 || threshold > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "threshold must be a percentile between 0 and 100",
+		})
+	}
+
+	if period < 2 || period > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "period must be between 2 and 100",
+		})
+	}
+	if lookback < 20 || lookback > 500 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "lookback must be between 20 and 500",
+		})
+	}
+	if stdDev <= 0 || stdDev > 10 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "stddev must be greater than 0 and at most 10",
+		})
+	}
+	if limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	ctx := context.Background()
+	results, err := h.service.CalculateBBSqueeze(ctx, symbol, period, stdDev, lookback, threshold, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to calculate Bollinger squeeze",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"symbol":    symbol,
+		"indicator": "BB_SQUEEZE",
+		"params": fiber.Map{
+			"period":    period,
+			"stddev":    stdDev,
+			"lookback":  lookback,
+			"threshold": threshold,
+		},
+		"count": len(results),
+		"data":  results,
+	})
+}
+
+// GetBeta calculates rolling beta and correlation against the TAIEX
+// GET /api/v1/indicators/:symbol/beta?window=60&limit=100
+func (h *IndicatorHandler) GetBeta(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	window := c.QueryInt("window", 60)
+	limit := c.QueryInt("limit", 100)
+
+	if window < 10 || window > 250 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "window must be between 10 and 250",
+		})
+	}
+	if limit < 1 || limit > 1000 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "limit must be between 1 and 1000",
+		})
+	}
+
+	ctx := context.Background()
+	results, err := h.service.CalculateBeta(ctx, symbol, window, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to calculate beta",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"symbol":    symbol,
+		"indicator": "BETA",
+		"params": fiber.Map{
+			"window":    window,
+			"benchmark": services.IndexSymbolTAIEX,
+		},
+		"count": len(results),
+		"data":  results,
+	})
+}
+
+// GetPivotPoints calculates pivot point levels
+// GET /api/v1/indicators/:symbol/pivots?method=classic&timeframe=daily
+func (h *IndicatorHandler) GetPivotPoints(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	method := strings.ToLower(c.Query("method", "classic"))
+	timeframe := strings.ToLower(c.Query("timeframe", "daily"))
+
+	if method != "classic" && method != "fibonacci" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "method must be classic or fibonacci",
+		})
+	}
+	if timeframe != "daily" && timeframe != "weekly" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "timeframe must be daily or weekly",
+		})
+	}
+
+	ctx := context.Background()
+	pivots, err := h.service.CalculatePivotPoints(ctx, symbol, method, timeframe)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to calculate pivot points",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"symbol":    symbol,
+		"indicator": "PIVOT",
+		"params": fiber.Map{
+			"method":    method,
+			"timeframe": timeframe,
+		},
+		"data": pivots,
+	})
+}
+
 // GetBatchIndicators calculates multiple indicators at once
 // POST /api/v1/indicators/:symbol/batch
 // Body: {"indicators": ["MA", "RSI", "MACD"], "params": {...}}
@@ -235,9 +399,9 @@ func (h *IndicatorHandler) GetBatchIndicators(c *fiber.Ctx) error {
 	}
 
 	var req struct {
-		Indicators []string               `json:"indicators"`
-		Params     map[string]interface{} `json:"params"`
-		Limit      int                    `json:"limit"`
+		Indicators []string                      `json:"indicators"`
+		Params     services.BatchIndicatorParams `json:"params"`
+		Limit      int                           `json:"limit"`
 	}
 
 	if err := c.BodyParser(&req); err != nil {
@@ -251,61 +415,154 @@ func (h *IndicatorHandler) GetBatchIndicators(c *fiber.Ctx) error {
 	}
 
 	ctx := context.Background()
+	result := h.service.CalculateIndicatorSet(ctx, symbol, req.Indicators, req.Params, req.Limit)
+
 	response := fiber.Map{
 		"success": true,
 		"symbol":  symbol,
-		"data":    fiber.Map{},
-	}
-
-	// Calculate each requested indicator
-	for _, indicator := range req.Indicators {
-		switch indicator {
-		case "MA":
-			period := 20
-			maType := "SMA"
-			if p, ok := req.Params["ma_period"].(float64); ok {
-				period = int(p)
-			}
-			if t, ok := req.Params["ma_type"].(string); ok {
-				maType = t
-			}
-			if results, err := h.service.CalculateMA(ctx, symbol, period, maType, req.Limit); err == nil {
-				response["data"].(fiber.Map)["MA"] = results
-			}
-
-		case "RSI":
-			period := 14
-			if p, ok := req.Params["rsi_period"].(float64); ok {
-				period = int(p)
-			}
-			if results, err := h.service.CalculateRSI(ctx, symbol, period, req.Limit); err == nil {
-				response["data"].(fiber.Map)["RSI"] = results
-			}
-
-		case "MACD":
-			if results, err := h.service.CalculateMACD(ctx, symbol, 12, 26, 9, req.Limit); err == nil {
-				response["data"].(fiber.Map)["MACD"] = results
-			}
-
-		case "BB":
-			period := 20
-			if p, ok := req.Params["bb_period"].(float64); ok {
-				period = int(p)
-			}
-			if results, err := h.service.CalculateBollingerBands(ctx, symbol, period, 2.0, req.Limit); err == nil {
-				response["data"].(fiber.Map)["BB"] = results
-			}
-
-		case "KDJ":
-			period := 9
-			if p, ok := req.Params["kdj_period"].(float64); ok {
-				period = int(p)
-			}
-			if results, err := h.service.CalculateKDJ(ctx, symbol, period, req.Limit); err == nil {
-				response["data"].(fiber.Map)["KDJ"] = results
-			}
-		}
+		"data":    result.Data,
+	}
+	if len(result.Errors) > 0 {
+		response["errors"] = result.Errors
 	}
 
 	return c.JSON(response)
 }
+
+// GetMultiSymbolIndicators calculates the same indicators for many symbols in one request
+// POST /api/v1/indicators/batch
+// Body: {"symbols": ["2330", "2317"], "indicators": ["MA", "RSI"], "params": {...}, "limit": 30}
+func (h *IndicatorHandler) GetMultiSymbolIndicators(c *fiber.Ctx) error {
+	var req struct {
+		Symbols    []string                      `json:"symbols"`
+		Indicators []string                      `json:"indicators"`
+		Params     services.BatchIndicatorParams `json:"params"`
+		Limit      int                           `json:"limit"`
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	// Normalize and de-duplicate symbols
+	seen := make(map[string]bool)
+	symbols := make([]string, 0, len(req.Symbols))
+	for _, sym := range req.Symbols {
+		sym = strings.ToUpper(strings.TrimSpace(sym))
+		if sym == "" || seen[sym] {
+			continue
+		}
+		seen[sym] = true
+		symbols = append(symbols, sym)
+	}
+
+	if len(symbols) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbols is required",
+		})
+	}
+	if len(symbols) > 100 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "at most 100 symbols per request",
+		})
+	}
+	if len(req.Indicators) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "indicators is required",
+		})
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+	if req.Limit > 1000 {
+		req.Limit = 1000
+	}
+
+	results := h.service.CalculateMultiSymbolIndicators(c.Context(), symbols, req.Indicators, req.Params, req.Limit)
+
+	return c.JSON(fiber.Map{
+		"success":    true,
+		"indicators": req.Indicators,
+		"count":      len(results),
+		"data":       results,
+	})
+}
+
+// GetSnapshot returns the latest persisted indicator snapshot for a symbol
+// GET /api/v1/indicators/:symbol/snapshot
+func (h *IndicatorHandler) GetSnapshot(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	if symbol == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol is required",
+		})
+	}
+
+	ctx := context.Background()
+	snapshot, err := h.service.GetLatestSnapshot(ctx, symbol)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "snapshot not found",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"symbol":  symbol,
+		"data":    snapshot,
+	})
+}
+
+// RefreshSnapshots recomputes indicator snapshots for all recently traded symbols
+// POST /api/v1/indicators/snapshots/refresh
+func (h *IndicatorHandler) RefreshSnapshots(c *fiber.Ctx) error {
+	ctx := context.Background()
+	result, err := h.service.RefreshSnapshots(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to refresh snapshots",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"result":  result,
+	})
+}
+
+// GetPrecomputeStatus returns the progress of the latest nightly precompute run
+// GET /api/v1/indicators/precompute/status
+func (h *IndicatorHandler) GetPrecomputeStatus(c *fiber.Ctx) error {
+	run, err := h.precomputeWorker.GetStatus(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to get precompute status",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    run,
+	})
+}
+
+// RunPrecompute starts (or resumes) the precompute run for the latest trading day
+// POST /api/v1/indicators/precompute/run
+func (h *IndicatorHandler) RunPrecompute(c *fiber.Ctx) error {
+	if err := h.precomputeWorker.RunNow(); err != nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "indicator precompute started",
+	})
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"psm-backend/internal/services"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// InstitutionalHandler handles institutional trading (三大法人) endpoints
+type InstitutionalHandler struct {
+	institutionalService *services.InstitutionalService
+}
+
+func NewInstitutionalHandler(institutionalService *services.InstitutionalService) *InstitutionalHandler {
+	return &InstitutionalHandler{institutionalService: institutionalService}
+}
+
+// GetInstitutionalTrades returns a symbol's daily institutional net buying, newest first
+// GET /api/v1/stocks/:symbol/institutional?days=20
+func (h *InstitutionalHandler) GetInstitutionalTrades(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	trades, err := h.institutionalService.GetInstitutionalTrades(c.Context(), symbol, c.QueryInt("days", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(trades),
+		"data":    trades,
+	})
+}
+
+// FetchInstitutionalTrades downloads the institutional trading reports for a day
+// POST /api/v1/institutional/fetch?date=2024-01-02 (default today)
+func (h *InstitutionalHandler) FetchInstitutionalTrades(c *fiber.Ctx) error {
+	date := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600))
+	if d := c.Query("date"); d != "" {
+		parsed, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid date, expected YYYY-MM-DD",
+			})
+		}
+		date = parsed
+	}
+
+	results, err := h.institutionalService.FetchInstitutionalTrades(c.Context(), date)
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": err.Error(),
+			"data":  results,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+	})
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"log"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -9,11 +10,12 @@ import (
 )
 
 type MarketDataHandler struct {
-	service *services.MarketDataService
+	service   *services.MarketDataService
+	taService *services.TechnicalAnalysisService
 }
 
-func NewMarketDataHandler(service *services.MarketDataService) *MarketDataHandler {
-	return &MarketDataHandler{service: service}
+func NewMarketDataHandler(service *services.MarketDataService, taService *services.TechnicalAnalysisService) *MarketDataHandler {
+	return &MarketDataHandler{service: service, taService: taService}
 }
 
 // GetOHLCVRequest represents query parameters for OHLCV data
@@ -167,6 +169,14 @@ func (h *MarketDataHandler) SyncMarketData(c *fiber.Ctx) error {
 		// Aggregates will be refreshed automatically by policy
 	}
 
+	// Keep the symbol's indicator snapshot in step with the synced bars, as the
+	// bulk sync does for every symbol
+	if snap, err := h.taService.ComputeSnapshot(ctx, req.Symbol); err != nil {
+		log.Printf("Indicator snapshot for %s failed: %v", req.Symbol, err)
+	} else if err := h.taService.SaveSnapshot(ctx, snap); err != nil {
+		log.Printf("Indicator snapshot save for %s failed: %v", req.Symbol, err)
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"message": "market data synced successfully",
@@ -179,6 +189,66 @@ func (h *MarketDataHandler) SyncMarketData(c *fiber.Ctx) error {
 	})
 }
 
+// SyncIndexData fetches TAIEX daily history from TWSE and saves it as symbol TAIEX
+// POST /api/v1/market/sync-index
+// Body: {"start_date": "2023-01-01", "end_date": "2024-12-31"}
+func (h *MarketDataHandler) SyncIndexData(c *fiber.Ctx) error {
+	var req SyncMarketDataRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body",
+		})
+	}
+
+	startDate, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid start_date format, use YYYY-MM-DD",
+		})
+	}
+
+	endDate, err := time.Parse("2006-01-02", req.EndDate)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid end_date format, use YYYY-MM-DD",
+		})
+	}
+
+	if startDate.After(endDate) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "start_date must be before end_date",
+		})
+	}
+
+	ctx := context.Background()
+
+	data, err := h.service.FetchIndexDaily(ctx, startDate, endDate)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to fetch index data from TWSE",
+			"details": err.Error(),
+		})
+	}
+
+	if err := h.service.SaveOHLCV(ctx, data); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to save index data to database",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "index data synced successfully",
+		"result": fiber.Map{
+			"symbol":     services.IndexSymbolTAIEX,
+			"start_date": req.StartDate,
+			"end_date":   req.EndDate,
+			"records":    len(data),
+		},
+	})
+}
+
 // RefreshAggregates manually triggers continuous aggregate refresh
 // POST /api/v1/market/refresh-aggregates
 func (h *MarketDataHandler) RefreshAggregates(c *fiber.Ctx) error {
@@ -1,22 +1,28 @@
 package handlers
 
 import (
+	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 	"psm-backend/internal/services"
 )
 
 type NewsHandler struct {
-	newsService *services.NewsService
+	newsService     *services.NewsService
+	fetchWorker     *services.NewsFetchWorker
+	retentionWorker *services.NewsRetentionWorker
 }
 
-func NewNewsHandler(newsService *services.NewsService) *NewsHandler {
-	return &NewsHandler{newsService: newsService}
+func NewNewsHandler(newsService *services.NewsService, fetchWorker *services.NewsFetchWorker, retentionWorker *services.NewsRetentionWorker) *NewsHandler {
+	return &NewsHandler{newsService: newsService, fetchWorker: fetchWorker, retentionWorker: retentionWorker}
 }
 
 // GetNews retrieves news for a specific symbol
-// GET /api/v1/news/:symbol
+// GET /api/v1/news/:symbol?language=en
 func (h *NewsHandler) GetNews(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
 	if symbol == "" {
@@ -28,7 +34,7 @@ func (h *NewsHandler) GetNews(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "20"))
 	offset, _ := strconv.Atoi(c.Query("offset", "0"))
 
-	articles, err := h.newsService.GetNewsForSymbol(c.Context(), symbol, limit, offset)
+	articles, err := h.newsService.GetNewsForSymbol(c.Context(), symbol, c.Query("language"), limit, offset)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -43,26 +49,75 @@ func (h *NewsHandler) GetNews(c *fiber.Ctx) error {
 	})
 }
 
-// GetRecentNews retrieves recent news across all symbols
-// GET /api/v1/news
+// GetRecentNews retrieves recent news across all symbols, paged and filtered
+// GET /api/v1/news?source=cnyes&category=台股&sentiment=positive&language=zh-TW&min_score=0.5&from=2024-01-01&to=2024-01-31&limit=30&offset=0
+// Pass the returned next_cursor as ?cursor= to continue instead of using offset
 func (h *NewsHandler) GetRecentNews(c *fiber.Ctx) error {
 	limit, _ := strconv.Atoi(c.Query("limit", "30"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+
+	filter := services.NewsFilter{
+		Source:    c.Query("source"),
+		Category:  c.Query("category"),
+		Sentiment: c.Query("sentiment"),
+		Language:  c.Query("language"),
+		Limit:     limit,
+		Offset:    offset,
+		Cursor:    c.Query("cursor"),
+	}
+	if v := c.Query("min_score"); v != "" {
+		score, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid min_score",
+			})
+		}
+		filter.MinScore = &score
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		v := c.Query(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid " + p.name + " date, expected YYYY-MM-DD",
+			})
+		}
+		if p.name == "to" {
+			// Inclusive of the whole end day
+			t = t.Add(24*time.Hour - time.Nanosecond)
+		}
+		*p.dst = &t
+	}
 
-	articles, err := h.newsService.GetRecentNews(c.Context(), limit)
+	page, err := h.newsService.GetRecentNews(c.Context(), filter)
 	if err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		status := fiber.StatusInternalServerError
+		if err.Error() == "invalid cursor" {
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
 	return c.JSON(fiber.Map{
-		"success": true,
-		"count":   len(articles),
-		"news":    articles,
+		"success":     true,
+		"count":       len(page.Articles),
+		"total":       page.Total,
+		"limit":       page.Limit,
+		"offset":      page.Offset,
+		"next_cursor": page.NextCursor,
+		"news":        page.Articles,
 	})
 }
 
-// FetchNews fetches new articles from Cnyes for a specific symbol
+// FetchNews fetches new articles from every enabled source for a specific symbol
 // POST /api/v1/news/:symbol/fetch
 func (h *NewsHandler) FetchNews(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
@@ -109,3 +164,347 @@ func (h *NewsHandler) FetchGeneralNews(c *fiber.Ctx) error {
 		"news":    articles,
 	})
 }
+
+// GetFetchWorkerStatus returns the scheduled news fetcher's recent activity
+// GET /api/v1/news/worker/status
+func (h *NewsHandler) GetFetchWorkerStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.fetchWorker.GetStatus(),
+	})
+}
+
+// RunFetchWorker triggers an immediate general and tracked-symbol news fetch
+// POST /api/v1/news/worker/run
+func (h *NewsHandler) RunFetchWorker(c *fiber.Ctx) error {
+	h.fetchWorker.RunNow()
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "news fetch started",
+	})
+}
+
+// GetSources lists the registered news sources and whether each is enabled
+// GET /api/v1/news/sources
+func (h *NewsHandler) GetSources(c *fiber.Ctx) error {
+	sources := h.newsService.ListSources()
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(sources),
+		"data":    sources,
+	})
+}
+
+// ListFeeds returns the registered RSS/Atom feeds
+// GET /api/v1/news/feeds
+func (h *NewsHandler) ListFeeds(c *fiber.Ctx) error {
+	feeds, err := h.newsService.ListFeeds(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(feeds),
+		"data":    feeds,
+	})
+}
+
+// RegisterFeed registers an RSS/Atom feed for ingestion
+// POST /api/v1/news/feeds
+// Body: {"name": "台積電IR", "url": "https://...", "category": "公司新聞", "default_symbol": "2330"}
+func (h *NewsHandler) RegisterFeed(c *fiber.Ctx) error {
+	var req services.NewsFeedRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	feed, err := h.newsService.RegisterFeed(c.Context(), req)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if strings.Contains(err.Error(), "already registered") {
+			status = fiber.StatusConflict
+		} else if strings.HasPrefix(err.Error(), "failed to") {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    feed,
+	})
+}
+
+// UpdateFeed updates a registered feed (e.g. {"is_active": false})
+// PUT /api/v1/news/feeds/:id
+func (h *NewsHandler) UpdateFeed(c *fiber.Ctx) error {
+	var req services.NewsFeedRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	if err := h.newsService.UpdateFeed(c.Context(), c.Params("id"), req); err != nil {
+		status := fiber.StatusInternalServerError
+		if err.Error() == "feed not found" {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "feed updated",
+	})
+}
+
+// DeleteFeed unregisters a feed
+// DELETE /api/v1/news/feeds/:id
+func (h *NewsHandler) DeleteFeed(c *fiber.Ctx) error {
+	if err := h.newsService.DeleteFeed(c.Context(), c.Params("id")); err != nil {
+		status := fiber.StatusInternalServerError
+		if err.Error() == "feed not found" {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "feed deleted",
+	})
+}
+
+// FetchFeeds ingests every active registered feed now
+// POST /api/v1/news/feeds/fetch
+func (h *NewsHandler) FetchFeeds(c *fiber.Ctx) error {
+	fetched, err := h.newsService.FetchAllFeeds(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"fetched": fetched,
+	})
+}
+
+// GetDuplicates lists the other outlets' copies of a story
+// GET /api/v1/news/articles/:id/duplicates
+func (h *NewsHandler) GetDuplicates(c *fiber.Ctx) error {
+	duplicates, err := h.newsService.GetDuplicates(c.Context(), c.Params("id"))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if err.Error() == "article not found" {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(duplicates),
+		"data":    duplicates,
+	})
+}
+
+// GetRetentionStatus returns the news retention policy and the last cleanup run
+// GET /api/v1/news/retention
+func (h *NewsHandler) GetRetentionStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.retentionWorker.GetStatus(),
+	})
+}
+
+// RunCleanup applies the retention policy now; body fields override the configured policy
+// POST /api/v1/news/cleanup
+// Body: {"retention_months": 12, "keep_held_symbols": true, "archive": true, "dry_run": true}
+func (h *NewsHandler) RunCleanup(c *fiber.Ctx) error {
+	return runRetentionCleanup(c, h.retentionWorker)
+}
+
+// FetchArticleContent fetches and stores the full text of a truncated article
+// POST /api/v1/news/articles/:id/content
+func (h *NewsHandler) FetchArticleContent(c *fiber.Ctx) error {
+	content, err := h.newsService.FetchFullContent(c.Context(), c.Params("id"))
+	if err != nil {
+		status := fiber.StatusBadGateway
+		switch {
+		case err.Error() == "article not found":
+			status = fiber.StatusNotFound
+		case strings.Contains(err.Error(), "disabled"):
+			status = fiber.StatusBadRequest
+		case strings.Contains(err.Error(), "not permitted"):
+			status = fiber.StatusForbidden
+		case strings.HasPrefix(err.Error(), "failed to"):
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"length":  len([]rune(content)),
+		"content": content,
+	})
+}
+
+// EnrichTruncatedNews fetches full text for recent truncated articles
+// POST /api/v1/news/content/fetch?limit=20
+func (h *NewsHandler) EnrichTruncatedNews(c *fiber.Ctx) error {
+	if !h.newsService.ContentFetchEnabled() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "content fetching is disabled (set NEWS_CONTENT_FETCH_ENABLED=true)",
+		})
+	}
+
+	enriched, err := h.newsService.EnrichTruncatedArticles(c.Context(), c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"enriched": enriched,
+	})
+}
+
+// GetTrending returns the hottest tags/keywords and most-mentioned symbols
+// GET /api/v1/news/trending?window=24h&limit=20 (window: hours "24h" or days "7d")
+func (h *NewsHandler) GetTrending(c *fiber.Ctx) error {
+	window, err := parseNewsWindow(c.Query("window", "24h"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	trending, err := h.newsService.GetTrending(c.Context(), window, c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    trending,
+	})
+}
+
+// parseNewsWindow accepts "<n>h" or "<n>d", up to 90 days
+func parseNewsWindow(v string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid window %q, expected e.g. 24h or 7d", v)
+	if len(v) < 2 {
+		return 0, invalid
+	}
+	n, err := strconv.Atoi(v[:len(v)-1])
+	if err != nil || n <= 0 {
+		return 0, invalid
+	}
+
+	var d time.Duration
+	switch v[len(v)-1] {
+	case 'h':
+		d = time.Duration(n) * time.Hour
+	case 'd':
+		d = time.Duration(n) * 24 * time.Hour
+	default:
+		return 0, invalid
+	}
+	if d > 90*24*time.Hour {
+		return 0, fmt.Errorf("window may not exceed 90d")
+	}
+	return d, nil
+}
+
+// FetchPortfolioNews fetches news for every holding of a portfolio in one call,
+// paced per source like the scheduled fetcher
+// POST /api/v1/portfolios/:portfolio_id/news/fetch
+func (h *NewsHandler) FetchPortfolioNews(c *fiber.Ctx) error {
+	portfolioID := c.Params("portfolio_id")
+	if _, err := uuid.Parse(portfolioID); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid portfolio ID",
+		})
+	}
+
+	symbols, err := h.newsService.PortfolioSymbols(c.Context(), portfolioID)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if err.Error() == "portfolio not found" {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	start := time.Now()
+	results := h.fetchWorker.FetchSymbols(c.Context(), symbols)
+
+	totalArticles, failedSymbols := 0, 0
+	for _, r := range results {
+		totalArticles += r.Articles
+		if len(r.Errors) > 0 && r.Articles == 0 {
+			failedSymbols++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"success":        true,
+		"portfolio_id":   portfolioID,
+		"symbols":        len(symbols),
+		"total_articles": totalArticles,
+		"failed_symbols": failedSymbols,
+		"duration":       time.Since(start).Round(time.Millisecond).String(),
+		"data":           results,
+	})
+}
+
+// GetSourceStats summarises fetch success, new-article counts, latency and the last
+// error per news source
+// GET /api/v1/news/sources/stats?window=7d
+func (h *NewsHandler) GetSourceStats(c *fiber.Ctx) error {
+	window, err := parseNewsWindow(c.Query("window", "7d"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	stats, err := h.newsService.GetSourceStats(c.Context(), window)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"window":  c.Query("window", "7d"),
+		"count":   len(stats),
+		"data":    stats,
+	})
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// NewsWebhookHandler handles news webhook registration endpoints
+type NewsWebhookHandler struct {
+	service *services.NewsWebhookService
+}
+
+func NewNewsWebhookHandler(service *services.NewsWebhookService) *NewsWebhookHandler {
+	return &NewsWebhookHandler{service: service}
+}
+
+// ListWebhooks returns the user's news webhooks
+// GET /api/v1/news/webhooks
+func (h *NewsWebhookHandler) ListWebhooks(c *fiber.Ctx) error {
+	// For demo, use hardcoded user ID
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	hooks, err := h.service.ListWebhooks(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(hooks),
+		"data":    hooks,
+	})
+}
+
+// CreateWebhook registers a webhook for new articles on held/watched symbols
+// POST /api/v1/news/webhooks
+// Body: {"url": "https://example.com/hook", "secret": "...", "scope": "all", "min_abs_sentiment": 0.3}
+func (h *NewsWebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	var req services.NewsWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	hook, err := h.service.CreateWebhook(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(newsWebhookErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    hook,
+	})
+}
+
+// UpdateWebhook changes a webhook's settings
+// PUT /api/v1/news/webhooks/:id
+// Body: {"is_active": true} re-enables a webhook switched off after repeated failures
+func (h *NewsWebhookHandler) UpdateWebhook(c *fiber.Ctx) error {
+	var req services.NewsWebhookRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	hook, err := h.service.UpdateWebhook(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return c.Status(newsWebhookErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    hook,
+	})
+}
+
+// DeleteWebhook removes a webhook
+// DELETE /api/v1/news/webhooks/:id
+func (h *NewsWebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	if err := h.service.DeleteWebhook(c.Context(), c.Params("id")); err != nil {
+		return c.Status(newsWebhookErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "webhook deleted",
+	})
+}
+
+// TestWebhook sends a sample "ping" payload to the webhook URL
+// POST /api/v1/news/webhooks/:id/test
+func (h *NewsWebhookHandler) TestWebhook(c *fiber.Ctx) error {
+	if err := h.service.TestWebhook(c.Context(), c.Params("id")); err != nil {
+		status := newsWebhookErrorStatus(err)
+		if status == fiber.StatusBadRequest {
+			// The endpoint itself rejected or could not be reached
+			status = fiber.StatusBadGateway
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "test payload delivered",
+	})
+}
+
+func newsWebhookErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.Contains(msg, "already exists"):
+		return fiber.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// PaperTradingHandler handles simulated portfolio endpoints
+type PaperTradingHandler struct {
+	service *services.PaperTradingService
+}
+
+func NewPaperTradingHandler(service *services.PaperTradingService) *PaperTradingHandler {
+	return &PaperTradingHandler{service: service}
+}
+
+// CreatePortfolio creates a paper trading portfolio
+// POST /api/v1/paper/portfolios
+// Body: {"name": "均線策略測試", "initial_cash": 1000000}
+func (h *PaperTradingHandler) CreatePortfolio(c *fiber.Ctx) error {
+	var req services.CreatePaperPortfolioRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	// For demo, use hardcoded user ID
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	portfolio, err := h.service.CreatePortfolio(c.Context(), userID, req)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "failed to") {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    portfolio,
+	})
+}
+
+// ListPortfolios returns the user's paper portfolios
+// GET /api/v1/paper/portfolios
+func (h *PaperTradingHandler) ListPortfolios(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	portfolios, err := h.service.ListPortfolios(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(portfolios),
+		"data":    portfolios,
+	})
+}
+
+// GetAccount returns cash, holdings and performance of a paper portfolio
+// GET /api/v1/paper/portfolios/:portfolio_id
+func (h *PaperTradingHandler) GetAccount(c *fiber.Ctx) error {
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid portfolio ID",
+		})
+	}
+
+	account, err := h.service.GetAccount(c.Context(), portfolioID)
+	if err != nil {
+		return c.Status(paperErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    account,
+	})
+}
+
+// PlaceOrder places a virtual order
+// POST /api/v1/paper/portfolios/:portfolio_id/orders
+// Body: {"symbol": "2330", "side": "BUY", "order_type": "limit", "quantity": 1000, "limit_price": 580}
+func (h *PaperTradingHandler) PlaceOrder(c *fiber.Ctx) error {
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid portfolio ID",
+		})
+	}
+
+	var req services.PaperOrderRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	order, err := h.service.PlaceOrder(c.Context(), portfolioID, req)
+	if err != nil {
+		return c.Status(paperErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    order,
+	})
+}
+
+// ListOrders returns a paper portfolio's orders
+// GET /api/v1/paper/portfolios/:portfolio_id/orders?status=pending&limit=100
+func (h *PaperTradingHandler) ListOrders(c *fiber.Ctx) error {
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid portfolio ID",
+		})
+	}
+
+	limit := c.QueryInt("limit", 100)
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+
+	orders, err := h.service.ListOrders(c.Context(), portfolioID, c.Query("status"), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(orders),
+		"data":    orders,
+	})
+}
+
+// CancelOrder cancels a pending virtual order
+// DELETE /api/v1/paper/portfolios/:portfolio_id/orders/:order_id
+func (h *PaperTradingHandler) CancelOrder(c *fiber.Ctx) error {
+	portfolioID, err := uuid.Parse(c.Params("portfolio_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid portfolio ID",
+		})
+	}
+	orderID, err := uuid.Parse(c.Params("order_id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid order ID",
+		})
+	}
+
+	if err := h.service.CancelOrder(c.Context(), portfolioID, orderID); err != nil {
+		return c.Status(paperErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "order cancelled",
+	})
+}
+
+func paperErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.HasSuffix(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
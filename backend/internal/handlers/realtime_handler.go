@@ -17,6 +17,7 @@ import (
 // RealtimeHandler handles real-time stock data endpoints
 type RealtimeHandler struct {
 	realtimeService *services.RealtimeService
+	taService       *services.TechnicalAnalysisService
 	clients         map[*websocket.Conn]*clientInfo
 	mu              sync.RWMutex
 }
@@ -37,9 +38,10 @@ type wsResponse struct {
 	Message string      `json:"message,omitempty"`
 }
 
-func NewRealtimeHandler(realtimeService *services.RealtimeService) *RealtimeHandler {
+func NewRealtimeHandler(realtimeService *services.RealtimeService, taService *services.TechnicalAnalysisService) *RealtimeHandler {
 	h := &RealtimeHandler{
 		realtimeService: realtimeService,
+		taService:       taService,
 		clients:         make(map[*websocket.Conn]*clientInfo),
 	}
 	
@@ -59,6 +61,7 @@ func (h *RealtimeHandler) GetMarketStatus(c *fiber.Ctx) error {
 }
 
 // GetRealtimeQuote returns real-time quote for a single stock
+// Optional: ?pivots=classic|fibonacci&pivot_timeframe=daily|weekly
 func (h *RealtimeHandler) GetRealtimeQuote(c *fiber.Ctx) error {
 	symbol := strings.ToUpper(c.Params("symbol"))
 	if symbol == "" {
@@ -79,6 +82,8 @@ func (h *RealtimeHandler) GetRealtimeQuote(c *fiber.Ctx) error {
 		})
 	}
 
+	h.attachPivots(ctx, c, quote)
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    quote,
@@ -86,6 +91,7 @@ func (h *RealtimeHandler) GetRealtimeQuote(c *fiber.Ctx) error {
 }
 
 // GetBatchQuotes returns real-time quotes for multiple stocks
+// Optional: ?pivots=classic|fibonacci&pivot_timeframe=daily|weekly
 func (h *RealtimeHandler) GetBatchQuotes(c *fiber.Ctx) error {
 	symbolsParam := c.Query("symbols")
 	if symbolsParam == "" {
@@ -119,6 +125,10 @@ func (h *RealtimeHandler) GetBatchQuotes(c *fiber.Ctx) error {
 		})
 	}
 
+	for _, quote := range quotes {
+		h.attachPivots(ctx, c, quote)
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    quotes,
@@ -126,6 +136,22 @@ func (h *RealtimeHandler) GetBatchQuotes(c *fiber.Ctx) error {
 	})
 }
 
+// attachPivots adds pivot levels to a quote when the request asks for them via ?pivots=
+func (h *RealtimeHandler) attachPivots(ctx context.Context, c *fiber.Ctx, quote *services.RealtimeQuote) {
+	method := strings.ToLower(c.Query("pivots"))
+	if method == "" || quote == nil || h.taService == nil {
+		return
+	}
+	timeframe := strings.ToLower(c.Query("pivot_timeframe", "daily"))
+
+	pivots, err := h.taService.CalculatePivotPoints(ctx, quote.Symbol, method, timeframe)
+	if err != nil {
+		log.Printf("Failed to calculate pivots for %s: %v", quote.Symbol, err)
+		return
+	}
+	quote.Pivots = pivots
+}
+
 // WebSocketUpgrade middleware to allow WebSocket connections
 func (h *RealtimeHandler) WebSocketUpgrade(c *fiber.Ctx) error {
 	if websocket.IsWebSocketUpgrade(c) {
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"errors"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// runRetentionCleanup applies a retention worker's policy now; body fields
+// override the configured policy
+func runRetentionCleanup[P services.RetentionPolicy, R any](c *fiber.Ctx, worker *services.RetentionWorker[P, R]) error {
+	policy := worker.Policy()
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&policy); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	result, err := worker.RunNow(c.Context(), policy)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrCleanupRunning):
+			status = fiber.StatusConflict
+		case errors.Is(err, services.ErrInvalidRetentionPolicy):
+			status = fiber.StatusBadRequest
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+			"data":  result,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RevenueHandler handles monthly revenue endpoints
+type RevenueHandler struct {
+	revenueService *services.RevenueService
+	fetchWorker    *services.RevenueFetchWorker
+}
+
+func NewRevenueHandler(revenueService *services.RevenueService, fetchWorker *services.RevenueFetchWorker) *RevenueHandler {
+	return &RevenueHandler{revenueService: revenueService, fetchWorker: fetchWorker}
+}
+
+// GetMonthlyRevenue returns a symbol's monthly revenue reports, newest first
+// GET /api/v1/stocks/:symbol/revenue?months=12
+func (h *RevenueHandler) GetMonthlyRevenue(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	reports, err := h.revenueService.GetMonthlyRevenue(c.Context(), symbol, c.QueryInt("months", 12))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(reports),
+		"data":    reports,
+	})
+}
+
+// FetchMonthlyRevenue downloads the latest monthly revenue of all listed companies
+// POST /api/v1/revenue/fetch
+func (h *RevenueHandler) FetchMonthlyRevenue(c *fiber.Ctx) error {
+	results, err := h.revenueService.FetchMonthlyRevenue(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": err.Error(),
+			"data":  results,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+	})
+}
+
+// GetFetchWorkerStatus returns the revenue fetch worker state and last results
+// GET /api/v1/revenue/worker/status
+func (h *RevenueHandler) GetFetchWorkerStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.fetchWorker.GetStatus(),
+	})
+}
@@ -1,35 +1,110 @@
 package handlers
 
 import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
 	"psm-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
 )
 
 // ScreenerHandler handles stock screening endpoints
 type ScreenerHandler struct {
 	screenerService *services.ScreenerService
+	aiService       *services.AIService
 }
 
-func NewScreenerHandler(screenerService *services.ScreenerService) *ScreenerHandler {
+func NewScreenerHandler(screenerService *services.ScreenerService, aiService *services.AIService) *ScreenerHandler {
 	return &ScreenerHandler{
 		screenerService: screenerService,
+		aiService:       aiService,
+	}
+}
+
+// wantsCSV reports whether a screen was requested with ?format=csv
+func wantsCSV(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Query("format"), "csv")
+}
+
+// wantsRealtime reports whether a screen was requested with ?mode=realtime,
+// i.e. against the intraday snapshot rather than the last close
+func wantsRealtime(c *fiber.Ctx) bool {
+	return strings.EqualFold(c.Query("mode"), "realtime")
+}
+
+// screenPaging returns the offset and limit to run a screen with: the ?offset
+// page at the screen's own size, or every match for a CSV export
+func screenPaging(c *fiber.Ctx) (offset, limit int) {
+	if wantsCSV(c) {
+		return 0, services.MaxScreenerExportRows
 	}
+	return c.QueryInt("offset", 0), 0
+}
+
+// unsafeFilenameChars matches what may not appear in a download filename
+var unsafeFilenameChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// sendScreenerCSV sends results as a CSV download; total is the number of
+// matches, so an export cut at MaxScreenerExportRows is flagged as truncated
+func sendScreenerCSV(c *fiber.Ctx, name string, results []services.ScreenerResult, total int) error {
+	setExportTotal(c, len(results), total)
+	var buf bytes.Buffer
+	if err := services.WriteScreenerCSV(&buf, results); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "failed to export csv: " + err.Error(),
+		})
+	}
+
+	return sendCSV(c, name, buf.Bytes())
+}
+
+// setExportTotal reports the match count of an export and whether rows were cut
+func setExportTotal(c *fiber.Ctx, rows, total int) {
+	c.Set("X-Total-Count", strconv.Itoa(total))
+	if rows < total {
+		c.Set("X-Export-Truncated", "true")
+	}
+}
+
+// sendCSV sends a CSV download named after the screen and the Taipei date.
+// The name may come from the URL, so anything outside [A-Za-z0-9_-] is replaced.
+func sendCSV(c *fiber.Ctx, name string, data []byte) error {
+	date := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600)).Format("20060102")
+	name = unsafeFilenameChars.ReplaceAllString(name, "_")
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="screener_%s_%s.csv"`, name, date))
+	return c.Send(data)
 }
 
-// GetPresets returns available screening presets
+// GetPresets returns available screening presets along with the user's saved screens
 // GET /api/v1/screener/presets
 func (h *ScreenerHandler) GetPresets(c *fiber.Ctx) error {
 	presets := h.screenerService.GetPresets()
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	saved, err := h.screenerService.ListSavedScreens(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"count":   len(presets),
 		"data":    presets,
+		"saved":   saved,
 	})
 }
 
 // RunPreset runs a preset screening
-// GET /api/v1/screener/preset/:name
+// GET /api/v1/screener/preset/:name?offset=20&mode=realtime&format=csv
 func (h *ScreenerHandler) RunPreset(c *fiber.Ctx) error {
 	presetName := c.Params("name")
 	if presetName == "" {
@@ -38,23 +113,32 @@ func (h *ScreenerHandler) RunPreset(c *fiber.Ctx) error {
 		})
 	}
 
-	results, err := h.screenerService.RunPreset(c.Context(), presetName)
+	offset, limit := screenPaging(c)
+	page, err := h.screenerService.RunPreset(c.Context(), presetName, offset, limit, wantsRealtime(c))
 	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": err.Error(),
 		})
 	}
 
+	if wantsCSV(c) {
+		return sendScreenerCSV(c, presetName, page.Results, page.Total)
+	}
+
 	return c.JSON(fiber.Map{
-		"success": true,
-		"preset":  presetName,
-		"count":   len(results),
-		"data":    results,
+		"success":  true,
+		"preset":      presetName,
+		"count":       len(page.Results),
+		"total":       page.Total,
+		"offset":      page.Offset,
+		"has_more":    page.HasMore,
+		"realtime_at": page.RealtimeAt,
+		"data":        page.Results,
 	})
 }
 
-// ScreenStocks screens stocks with custom criteria
-// POST /api/v1/screener/screen
+// ScreenStocks screens stocks with custom criteria; offset and limit select the page
+// POST /api/v1/screener/screen?mode=realtime&format=csv
 func (h *ScreenerHandler) ScreenStocks(c *fiber.Ctx) error {
 	var criteria services.ScreenerCriteria
 	if err := c.BodyParser(&criteria); err != nil {
@@ -70,24 +154,121 @@ func (h *ScreenerHandler) ScreenStocks(c *fiber.Ctx) error {
 	if criteria.SortBy == "" {
 		criteria.SortBy = "score"
 	}
+	if wantsRealtime(c) {
+		criteria.Realtime = true
+	}
+	if wantsCSV(c) {
+		criteria.Offset, criteria.Limit = screenPaging(c)
+	}
 
-	results, err := h.screenerService.ScreenStocks(c.Context(), &criteria)
+	page, err := h.screenerService.ScreenStocksPage(c.Context(), &criteria)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "篩選失敗: " + err.Error(),
 		})
 	}
 
+	if wantsCSV(c) {
+		return sendScreenerCSV(c, "custom", page.Results, page.Total)
+	}
+
 	return c.JSON(fiber.Map{
 		"success":  true,
-		"criteria": criteria,
-		"count":    len(results),
-		"data":     results,
+		"criteria":    criteria,
+		"count":       len(page.Results),
+		"total":       page.Total,
+		"offset":      page.Offset,
+		"has_more":    page.HasMore,
+		"realtime_at": page.RealtimeAt,
+		"data":        page.Results,
+	})
+}
+
+// NaturalLanguageScreen translates a sentence such as "找出量能放大且站上月線的半導體股"
+// into screener criteria with the LLM, validates them and runs the screen
+// POST /api/v1/screener/natural?mode=realtime&format=csv
+func (h *ScreenerHandler) NaturalLanguageScreen(c *fiber.Ctx) error {
+	var req struct {
+		Query string `json:"query"`
+		Limit int    `json:"limit"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "query is required",
+		})
+	}
+
+	if !h.aiService.IsConfigured() {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":   "AI service not configured",
+			"message": "請設定 AI_PROVIDER 或 GEMINI_API_KEY / OPENAI_API_KEY / ANTHROPIC_API_KEY 以啟用 AI 分析功能",
+		})
+	}
+
+	industries, err := h.screenerService.Industries(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	parsed, err := h.aiService.ParseScreenerQuery(c.Context(), userID, req.Query, industries)
+	if err != nil {
+		return c.Status(aiErrorStatus(err)).JSON(fiber.Map{
+			"error": "條件轉換失敗: " + err.Error(),
+		})
+	}
+
+	criteria := parsed.Criteria
+	if req.Limit > 0 {
+		criteria.Limit = req.Limit
+	}
+	if criteria.Limit <= 0 {
+		criteria.Limit = 50
+	}
+	if criteria.SortBy == "" {
+		criteria.SortBy = "score"
+		criteria.SortDesc = true
+	}
+	criteria.Realtime = wantsRealtime(c)
+	if wantsCSV(c) {
+		criteria.Offset, criteria.Limit = screenPaging(c)
+		page, err := h.screenerService.ScreenStocksPage(c.Context(), criteria)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "篩選失敗: " + err.Error(),
+			})
+		}
+		return sendScreenerCSV(c, "natural", page.Results, page.Total)
+	}
+
+	results, err := h.screenerService.ScreenStocks(c.Context(), criteria)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "篩選失敗: " + err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":     true,
+		"query":       parsed.Query,
+		"criteria":    criteria,
+		"summary":     parsed.Summary,
+		"unsupported": parsed.Unsupported,
+		"model":       parsed.Model,
+		"count":       len(results),
+		"data":        results,
 	})
 }
 
-// QuickScreen provides quick screening shortcuts
-// GET /api/v1/screener/quick/:type
+// QuickScreen provides quick screening shortcuts, optionally limited to one market
+// GET /api/v1/screener/quick/:type?market=TSE&mode=realtime&format=csv
 func (h *ScreenerHandler) QuickScreen(c *fiber.Ctx) error {
 	screenType := c.Params("type")
 	
@@ -95,6 +276,13 @@ func (h *ScreenerHandler) QuickScreen(c *fiber.Ctx) error {
 	criteria.Limit = 20
 	criteria.SortDesc = true
 	criteria.MinPrice = 10
+	criteria.ExcludeInactive = true
+	criteria.ExcludeETFs = true
+	criteria.ExcludeWarrants = true
+	criteria.ExcludeFullCashDelivery = true
+	criteria.ExcludeDisposition = true
+	criteria.Market = c.Query("market")
+	criteria.Realtime = wantsRealtime(c)
 
 	switch screenType {
 	case "gainers":
@@ -119,13 +307,32 @@ func (h *ScreenerHandler) QuickScreen(c *fiber.Ctx) error {
 		// 突破股
 		criteria.Near52WeekHigh = true
 		criteria.SortBy = "change_percent"
+	case "limit_up":
+		// 漲停股
+		criteria.LimitUp = true
+		criteria.SortBy = "volume_ratio"
+	case "limit_down":
+		// 跌停股
+		criteria.LimitDown = true
+		criteria.SortBy = "volume_ratio"
 	default:
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "invalid screen type",
-			"valid":   []string{"gainers", "losers", "volume", "momentum", "breakout"},
+			"valid":   []string{"gainers", "losers", "volume", "momentum", "breakout", "limit_up", "limit_down"},
 		})
 	}
 
+	if wantsCSV(c) {
+		criteria.Offset, criteria.Limit = screenPaging(c)
+		page, err := h.screenerService.ScreenStocksPage(c.Context(), &criteria)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "篩選失敗: " + err.Error(),
+			})
+		}
+		return sendScreenerCSV(c, screenType, page.Results, page.Total)
+	}
+
 	results, err := h.screenerService.ScreenStocks(c.Context(), &criteria)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -140,3 +347,428 @@ func (h *ScreenerHandler) QuickScreen(c *fiber.Ctx) error {
 		"data":    results,
 	})
 }
+
+// GetRealtimeStatus reports the intraday snapshot that ?mode=realtime screens run
+// against: the top stocks by turnover with live quotes, refreshed while the market is open
+// GET /api/v1/screener/realtime
+func (h *ScreenerHandler) GetRealtimeStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.screenerService.IntradayStatus(),
+	})
+}
+
+// CombineScreens runs several presets and saved screens and returns the
+// intersection or union of their matches, each annotated with the screens it matched
+// POST /api/v1/screener/combine?format=csv
+// Body: {"screens": [{"preset": "golden_cross"}, {"screen_id": "..."}], "operation": "intersection", "limit": 50}
+func (h *ScreenerHandler) CombineScreens(c *fiber.Ctx) error {
+	var req services.CombinedScreenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	if wantsCSV(c) {
+		req.Offset, req.Limit = screenPaging(c)
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	page, err := h.screenerService.CombineScreens(c.Context(), userID, &req)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	if wantsCSV(c) {
+		var buf bytes.Buffer
+		if err := services.WriteCombinedScreenCSV(&buf, page.Results); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error": "failed to export csv: " + err.Error(),
+			})
+		}
+		setExportTotal(c, len(page.Results), page.Total)
+		return sendCSV(c, "combined", buf.Bytes())
+	}
+
+	return c.JSON(fiber.Map{
+		"success":   true,
+		"screens":   page.Screens,
+		"operation": page.Operation,
+		"count":     len(page.Results),
+		"total":     page.Total,
+		"offset":    page.Offset,
+		"has_more":  page.HasMore,
+		"data":      page.Results,
+	})
+}
+
+// EvaluatePresets replays presets on historical dates and reports forward returns of their matches
+// POST /api/v1/screener/presets/evaluate
+// Body: {"presets": ["golden_cross"], "start_date": "2024-01-01", "end_date": "2024-12-31", "step": 5, "horizons": [5, 20, 60]}
+func (h *ScreenerHandler) EvaluatePresets(c *fiber.Ctx) error {
+	var req services.PresetEvaluationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	result, err := h.screenerService.EvaluatePresets(c.Context(), &req)
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "failed to") {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+func savedScreenErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.Contains(msg, "already exists"):
+		return fiber.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
+
+// ListSavedScreens returns the user's saved screens
+// GET /api/v1/screener/screens
+func (h *ScreenerHandler) ListSavedScreens(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screens, err := h.screenerService.ListSavedScreens(c.Context(), userID)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(screens),
+		"data":    screens,
+	})
+}
+
+// GetSavedScreen returns one saved screen
+// GET /api/v1/screener/screens/:id
+func (h *ScreenerHandler) GetSavedScreen(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.GetSavedScreen(c.Context(), userID, c.Params("id"))
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    screen,
+	})
+}
+
+// CreateSavedScreen saves screener criteria under a name
+// POST /api/v1/screener/screens
+// Body: {"name": "半導體量增", "description": "", "criteria": {"industries": ["半導體業"], "min_volume_ratio": 1.5}}
+func (h *ScreenerHandler) CreateSavedScreen(c *fiber.Ctx) error {
+	var req services.SavedScreenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.CreateSavedScreen(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    screen,
+	})
+}
+
+// UpdateSavedScreen replaces a saved screen's name, description and criteria
+// PUT /api/v1/screener/screens/:id
+func (h *ScreenerHandler) UpdateSavedScreen(c *fiber.Ctx) error {
+	var req services.SavedScreenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.UpdateSavedScreen(c.Context(), userID, c.Params("id"), req)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    screen,
+	})
+}
+
+// DeleteSavedScreen removes a saved screen
+// DELETE /api/v1/screener/screens/:id
+func (h *ScreenerHandler) DeleteSavedScreen(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	if err := h.screenerService.DeleteSavedScreen(c.Context(), userID, c.Params("id")); err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// RunSavedScreen runs a saved screen
+// GET /api/v1/screener/screens/:id/run?offset=20&mode=realtime&format=csv
+func (h *ScreenerHandler) RunSavedScreen(c *fiber.Ctx) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.GetSavedScreen(c.Context(), userID, c.Params("id"))
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.runSavedScreen(c, screen)
+}
+
+// ShareSavedScreen turns on the screen's read-only share link
+// POST /api/v1/screener/screens/:id/share
+func (h *ScreenerHandler) ShareSavedScreen(c *fiber.Ctx) error {
+	return h.setSavedScreenSharing(c, true)
+}
+
+// UnshareSavedScreen revokes the screen's share link
+// DELETE /api/v1/screener/screens/:id/share
+func (h *ScreenerHandler) UnshareSavedScreen(c *fiber.Ctx) error {
+	return h.setSavedScreenSharing(c, false)
+}
+
+func (h *ScreenerHandler) setSavedScreenSharing(c *fiber.Ctx, shared bool) error {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.SetSavedScreenSharing(c.Context(), userID, c.Params("id"), shared)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    screen,
+	})
+}
+
+// ScheduleSavedScreen turns the screen's run after every daily sync on or off;
+// a run with matches raises a screen_match alert pushed over the user's channels
+// PUT /api/v1/screener/screens/:id/schedule
+// Body: {"enabled": true}
+func (h *ScreenerHandler) ScheduleSavedScreen(c *fiber.Ctx) error {
+	var req struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.SetSavedScreenScheduled(c.Context(), userID, c.Params("id"), req.Enabled)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    screen,
+	})
+}
+
+// RunSharedScreen shows and runs a screen shared by link, read-only
+// GET /api/v1/screener/shared/:token?mode=realtime&format=csv
+func (h *ScreenerHandler) RunSharedScreen(c *fiber.Ctx) error {
+	screen, err := h.screenerService.GetSharedScreen(c.Context(), c.Params("token"))
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.runSavedScreen(c, screen)
+}
+
+func (h *ScreenerHandler) runSavedScreen(c *fiber.Ctx, screen *services.SavedScreen) error {
+	offset, limit := screenPaging(c)
+	page, err := h.screenerService.RunSavedScreen(c.Context(), screen, offset, limit, wantsRealtime(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "篩選失敗: " + err.Error(),
+		})
+	}
+
+	if wantsCSV(c) {
+		return sendScreenerCSV(c, "screen_"+screen.ID, page.Results, page.Total)
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"screen":      screen,
+		"count":       len(page.Results),
+		"total":       page.Total,
+		"offset":      page.Offset,
+		"has_more":    page.HasMore,
+		"realtime_at": page.RealtimeAt,
+		"data":        page.Results,
+	})
+}
+
+// GetPresetHistory returns the preset's daily matches, newest first, with the
+// symbols not matched the day before flagged is_new
+// GET /api/v1/screener/preset/:name/history?days=20
+func (h *ScreenerHandler) GetPresetHistory(c *fiber.Ctx) error {
+	return h.hitHistory(c, services.CombinedScreenRef{Preset: c.Params("name")})
+}
+
+// GetPresetHistoryDiff lists the preset's new, dropped and kept matches on a
+// day versus the day before
+// GET /api/v1/screener/preset/:name/history/diff?date=2024-01-03
+func (h *ScreenerHandler) GetPresetHistoryDiff(c *fiber.Ctx) error {
+	return h.hitDiff(c, services.CombinedScreenRef{Preset: c.Params("name")})
+}
+
+// GetPresetPerformance returns the forward returns of the preset's past matches
+// GET /api/v1/screener/preset/:name/performance?days=60
+func (h *ScreenerHandler) GetPresetPerformance(c *fiber.Ctx) error {
+	return h.hitPerformance(c, services.CombinedScreenRef{Preset: c.Params("name")})
+}
+
+// GetSavedScreenHistory returns the saved screen's daily matches, newest first
+// GET /api/v1/screener/screens/:id/history?days=20
+func (h *ScreenerHandler) GetSavedScreenHistory(c *fiber.Ctx) error {
+	ref, err := h.savedScreenRef(c)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return h.hitHistory(c, ref)
+}
+
+// GetSavedScreenHistoryDiff lists the saved screen's new, dropped and kept matches
+// GET /api/v1/screener/screens/:id/history/diff?date=2024-01-03
+func (h *ScreenerHandler) GetSavedScreenHistoryDiff(c *fiber.Ctx) error {
+	ref, err := h.savedScreenRef(c)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return h.hitDiff(c, ref)
+}
+
+// GetSavedScreenPerformance returns the forward returns of the saved screen's past matches
+// GET /api/v1/screener/screens/:id/performance?days=60
+func (h *ScreenerHandler) GetSavedScreenPerformance(c *fiber.Ctx) error {
+	ref, err := h.savedScreenRef(c)
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	return h.hitPerformance(c, ref)
+}
+
+// savedScreenRef resolves the user's saved screen in :id
+func (h *ScreenerHandler) savedScreenRef(c *fiber.Ctx) (services.CombinedScreenRef, error) {
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	screen, err := h.screenerService.GetSavedScreen(c.Context(), userID, c.Params("id"))
+	if err != nil {
+		return services.CombinedScreenRef{}, err
+	}
+	return services.CombinedScreenRef{ScreenID: screen.ID}, nil
+}
+
+func (h *ScreenerHandler) hitHistory(c *fiber.Ctx, ref services.CombinedScreenRef) error {
+	history, err := h.screenerService.HitHistory(c.Context(), ref, c.QueryInt("days", 20))
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(history),
+		"data":    history,
+	})
+}
+
+func (h *ScreenerHandler) hitDiff(c *fiber.Ctx, ref services.CombinedScreenRef) error {
+	diff, err := h.screenerService.HitDiff(c.Context(), ref, c.Query("date"))
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    diff,
+	})
+}
+
+func (h *ScreenerHandler) hitPerformance(c *fiber.Ctx, ref services.CombinedScreenRef) error {
+	perf, err := h.screenerService.HitPerformance(c.Context(), ref, c.QueryInt("days", 60))
+	if err != nil {
+		return c.Status(savedScreenErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    perf,
+	})
+}
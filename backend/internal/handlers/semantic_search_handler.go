@@ -0,0 +1,102 @@
+package handlers
+
+import (
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// SemanticSearchHandler handles embedding-based search over news and AI analyses
+type SemanticSearchHandler struct {
+	embeddingService *services.EmbeddingService
+	worker           *services.EmbeddingWorker
+}
+
+func NewSemanticSearchHandler(embeddingService *services.EmbeddingService, worker *services.EmbeddingWorker) *SemanticSearchHandler {
+	return &SemanticSearchHandler{embeddingService: embeddingService, worker: worker}
+}
+
+// Search returns the news articles and AI analyses closest in meaning to q
+// GET /api/v1/search/semantic?q=先進封裝產能&symbol=2330&type=news&days=90&limit=20
+func (h *SemanticSearchHandler) Search(c *fiber.Ctx) error {
+	hits, err := h.embeddingService.Search(c.Context(), services.SemanticSearchRequest{
+		Query:  c.Query("q"),
+		Symbol: c.Query("symbol"),
+		Type:   c.Query("type"),
+		Days:   c.QueryInt("days", 90),
+		Limit:  c.QueryInt("limit", 20),
+	})
+	if err != nil {
+		status := fiber.StatusBadRequest
+		if strings.HasPrefix(err.Error(), "semantic search disabled") {
+			status = fiber.StatusServiceUnavailable
+		} else if strings.HasPrefix(err.Error(), "failed to") {
+			status = fiber.StatusInternalServerError
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(hits),
+		"data":    hits,
+	})
+}
+
+// GetContext returns the passages of earlier analyses, filings and revenue
+// reports that AI prompts for the symbol are augmented with
+// GET /api/v1/search/semantic/context?symbol=2330&q=營收衰退&limit=6
+func (h *SemanticSearchHandler) GetContext(c *fiber.Ctx) error {
+	symbol := c.Query("symbol")
+	query := c.Query("q")
+	if symbol == "" || query == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbol and q are required",
+		})
+	}
+
+	chunks, err := h.embeddingService.Retrieve(c.Context(), symbol, query, c.QueryInt("days", 400), c.QueryInt("limit", 6))
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.HasPrefix(err.Error(), "semantic search disabled") {
+			status = fiber.StatusServiceUnavailable
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(chunks),
+		"data":    chunks,
+	})
+}
+
+// GetStatus returns the embedding provider, indexing coverage and worker state
+// GET /api/v1/search/semantic/status
+func (h *SemanticSearchHandler) GetStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.worker.GetStatus(c.Context()),
+	})
+}
+
+// RunIndexer triggers an immediate embedding of pending articles and analyses
+// POST /api/v1/search/semantic/index
+func (h *SemanticSearchHandler) RunIndexer(c *fiber.Ctx) error {
+	if !h.embeddingService.Enabled(c.Context()) {
+		return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": h.embeddingService.GetStatus(c.Context()).Reason,
+		})
+	}
+	h.worker.RunNow()
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"success": true,
+		"message": "embedding indexing started",
+	})
+}
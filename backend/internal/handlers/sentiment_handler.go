@@ -1,8 +1,11 @@
 package handlers
 
 import (
+	"fmt"
 	"psm-backend/internal/services"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -10,16 +13,19 @@ import (
 // SentimentHandler handles sentiment analysis endpoints
 type SentimentHandler struct {
 	sentimentService *services.SentimentService
+	sentimentQueue   *services.SentimentQueue
 }
 
-func NewSentimentHandler(sentimentService *services.SentimentService) *SentimentHandler {
+func NewSentimentHandler(sentimentService *services.SentimentService, sentimentQueue *services.SentimentQueue) *SentimentHandler {
 	return &SentimentHandler{
 		sentimentService: sentimentService,
+		sentimentQueue:   sentimentQueue,
 	}
 }
 
-// GetSentimentSummary returns sentiment summary for a symbol
-// GET /api/v1/sentiment/:symbol
+// GetSentimentSummary returns the confidence-weighted sentiment summary for a symbol;
+// normalize=source removes each news source's typical bias from the scores
+// GET /api/v1/sentiment/:symbol?days=7&min_confidence=0.5&normalize=source
 func (h *SentimentHandler) GetSentimentSummary(c *fiber.Ctx) error {
 	symbol := c.Params("symbol")
 	if symbol == "" {
@@ -36,7 +42,30 @@ func (h *SentimentHandler) GetSentimentSummary(c *fiber.Ctx) error {
 		}
 	}
 
-	summary, err := h.sentimentService.GetSentimentSummary(c.Context(), symbol, days)
+	// Leave out weak matches, e.g. ?min_confidence=0.5
+	minConfidence := 0.0
+	if v := c.Query("min_confidence"); v != "" {
+		mc, err := strconv.ParseFloat(v, 64)
+		if err != nil || mc < 0 || mc > 1 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "min_confidence must be between 0 and 1",
+			})
+		}
+		minConfidence = mc
+	}
+
+	normalizeSource := false
+	switch c.Query("normalize") {
+	case "":
+	case "source":
+		normalizeSource = true
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "normalize must be source",
+		})
+	}
+
+	summary, err := h.sentimentService.GetSentimentSummary(c.Context(), symbol, days, minConfidence, normalizeSource)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "failed to get sentiment summary: " + err.Error(),
@@ -49,6 +78,243 @@ func (h *SentimentHandler) GetSentimentSummary(c *fiber.Ctx) error {
 	})
 }
 
+// GetSourceBias returns how much more bullish or bearish each news source scores
+// than all sources together, most bullish first
+// GET /api/v1/sentiment/sources?days=90&min_articles=20
+func (h *SentimentHandler) GetSourceBias(c *fiber.Ctx) error {
+	days, _ := strconv.Atoi(c.Query("days", "90"))
+	if days <= 0 || days > 365 {
+		days = 90
+	}
+	minArticles, _ := strconv.Atoi(c.Query("min_articles", "1"))
+
+	sources, err := h.sentimentService.GetSourceBias(c.Context(), days, minArticles)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(sources),
+		"data":    sources,
+	})
+}
+
+// GetIndustrySentiment returns sentiment aggregated by industry, most negative first,
+// with the change from the previous period of the same length
+// GET /api/v1/sentiment/industries?days=7&min_articles=3
+func (h *SentimentHandler) GetIndustrySentiment(c *fiber.Ctx) error {
+	days := 7
+	if d, err := strconv.Atoi(c.Query("days")); err == nil && d > 0 {
+		days = d
+	}
+	if days > 90 {
+		days = 90
+	}
+	minArticles, _ := strconv.Atoi(c.Query("min_articles", "1"))
+
+	industries, err := h.sentimentService.GetIndustrySentiment(c.Context(), days, minArticles)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"days":    days,
+		"count":   len(industries),
+		"data":    industries,
+	})
+}
+
+// GetAspectBreakdown returns per-aspect sentiment (revenue, orders, lawsuits,
+// management, supply chain) over the symbol's recent articles
+// GET /api/v1/sentiment/:symbol/aspects?days=30
+func (h *SentimentHandler) GetAspectBreakdown(c *fiber.Ctx) error {
+	symbol := c.Params("symbol")
+	days := 30
+	if d, err := strconv.Atoi(c.Query("days")); err == nil && d > 0 {
+		days = d
+	}
+
+	aspects, err := h.sentimentService.GetAspectBreakdown(c.Context(), symbol, days)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"symbol":  symbol,
+		"days":    days,
+		"count":   len(aspects),
+		"data":    aspects,
+	})
+}
+
+// GetMarketSentiment returns the daily market sentiment index with TAIEX closes for overlaying
+// GET /api/v1/sentiment/market?from=2024-01-01&to=2024-03-31&weighting=articles
+// weighting: articles (default) or turnover; the range defaults to the last 90 days
+func (h *SentimentHandler) GetMarketSentiment(c *fiber.Ctx) error {
+	from, to, err := parseSentimentRange(c, 90)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	points, err := h.sentimentService.GetMarketSentimentIndex(c.Context(), from, to, c.Query("weighting"))
+	if err != nil {
+		return c.Status(sentimentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"count":   len(points),
+		"data":    points,
+	})
+}
+
+// RebuildMarketSentiment recomputes the market sentiment index, e.g. after a backfill
+// POST /api/v1/sentiment/market/rebuild?from=2024-01-01&to=2024-03-31
+func (h *SentimentHandler) RebuildMarketSentiment(c *fiber.Ctx) error {
+	from, to, err := parseSentimentRange(c, 30)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	written, err := h.sentimentService.ComputeMarketSentimentIndex(c.Context(), from, to)
+	if err != nil {
+		return c.Status(sentimentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"from":    from.Format("2006-01-02"),
+		"to":      to.Format("2006-01-02"),
+		"rows":    written,
+	})
+}
+
+// parseSentimentRange reads from/to (YYYY-MM-DD); to defaults to today and from to defaultDays earlier
+func parseSentimentRange(c *fiber.Ctx, defaultDays int) (time.Time, time.Time, error) {
+	to := time.Now()
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to date, expected YYYY-MM-DD")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -defaultDays)
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date, expected YYYY-MM-DD")
+		}
+		from = t
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to must not be before from")
+	}
+	return from, to, nil
+}
+
+// GetSentimentBacktest reports the correlation / IC between a symbol's daily sentiment and
+// its forward return, so users can judge how far to trust the signal
+// GET /api/v1/sentiment/:symbol/backtest?days=365&horizon=5
+func (h *SentimentHandler) GetSentimentBacktest(c *fiber.Ctx) error {
+	return h.sentimentBacktest(c, c.Params("symbol"))
+}
+
+// GetPooledSentimentBacktest is GetSentimentBacktest pooled across every symbol
+// GET /api/v1/sentiment/backtest?days=365&horizon=5
+func (h *SentimentHandler) GetPooledSentimentBacktest(c *fiber.Ctx) error {
+	return h.sentimentBacktest(c, "")
+}
+
+func (h *SentimentHandler) sentimentBacktest(c *fiber.Ctx, symbol string) error {
+	days, _ := strconv.Atoi(c.Query("days", "365"))
+	horizon, _ := strconv.Atoi(c.Query("horizon", "5"))
+
+	result, err := h.sentimentService.BacktestSentiment(c.Context(), symbol, days, horizon)
+	if err != nil {
+		return c.Status(sentimentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetAnalyzerVersions returns the current analyzer version and article counts per version
+// GET /api/v1/sentiment/versions
+func (h *SentimentHandler) GetAnalyzerVersions(c *fiber.Ctx) error {
+	versions, err := h.sentimentService.GetAnalyzerVersions(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":         true,
+		"current_version": h.sentimentService.CurrentAnalyzerVersion(c.Context()),
+		"count":           len(versions),
+		"data":            versions,
+	})
+}
+
+// ReanalyzeOutdated re-scores articles analysed with an older dictionary, prompt or model
+// POST /api/v1/sentiment/reanalyze
+// Body: {"days": 30, "limit": 500, "dry_run": true}
+func (h *SentimentHandler) ReanalyzeOutdated(c *fiber.Ctx) error {
+	var opts services.SentimentReanalysisOptions
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&opts); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	result, err := h.sentimentService.ReanalyzeOutdated(c.Context(), opts)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    result,
+	})
+}
+
+// GetQueueStatus returns the background analysis queue's depth and counters
+// GET /api/v1/sentiment/queue
+func (h *SentimentHandler) GetQueueStatus(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    h.sentimentQueue.GetStatus(),
+	})
+}
+
 // AnalyzeUnanalyzedNews triggers batch analysis of unanalyzed news
 // POST /api/v1/sentiment/analyze
 func (h *SentimentHandler) AnalyzeUnanalyzedNews(c *fiber.Ctx) error {
@@ -116,10 +382,121 @@ func (h *SentimentHandler) AnalyzeText(c *fiber.Ctx) error {
 		})
 	}
 
-	result := h.sentimentService.AnalyzeSentiment(req.Text)
+	result := h.sentimentService.Analyze(c.Context(), req.Text)
 
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    result,
 	})
 }
+
+// GetDictionary returns the keyword dictionary version in use and its terms
+// GET /api/v1/sentiment/dictionary?kind=positive
+func (h *SentimentHandler) GetDictionary(c *fiber.Ctx) error {
+	keywords, err := h.sentimentService.ListKeywords(c.Context(), c.Query("kind"))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":    true,
+		"dictionary": h.sentimentService.GetDictionaryInfo(c.Context()),
+		"count":      len(keywords),
+		"data":       keywords,
+	})
+}
+
+// CreateKeyword adds a dictionary term; it is used immediately
+// POST /api/v1/sentiment/dictionary
+// Body: {"term": "擴產", "kind": "positive", "weight": 1.2, "note": "capacity expansion"}
+func (h *SentimentHandler) CreateKeyword(c *fiber.Ctx) error {
+	var req services.SentimentKeywordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	keyword, err := h.sentimentService.CreateKeyword(c.Context(), req)
+	if err != nil {
+		return c.Status(sentimentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    keyword,
+	})
+}
+
+// UpdateKeyword changes a term's weight, active flag or note
+// PUT /api/v1/sentiment/dictionary/:id
+// Body: {"weight": 0.5, "is_active": false}
+func (h *SentimentHandler) UpdateKeyword(c *fiber.Ctx) error {
+	var req services.SentimentKeywordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	keyword, err := h.sentimentService.UpdateKeyword(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return c.Status(sentimentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    keyword,
+	})
+}
+
+// DeleteKeyword removes a dictionary term
+// DELETE /api/v1/sentiment/dictionary/:id
+func (h *SentimentHandler) DeleteKeyword(c *fiber.Ctx) error {
+	if err := h.sentimentService.DeleteKeyword(c.Context(), c.Params("id")); err != nil {
+		return c.Status(sentimentErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "keyword deleted",
+	})
+}
+
+// ReloadDictionary reloads the dictionary from the database, e.g. after editing the table directly
+// POST /api/v1/sentiment/dictionary/reload
+func (h *SentimentHandler) ReloadDictionary(c *fiber.Ctx) error {
+	info, err := h.sentimentService.ReloadDictionary(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    info,
+	})
+}
+
+func sentimentErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.Contains(msg, "already exists"):
+		return fiber.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
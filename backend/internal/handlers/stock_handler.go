@@ -1,6 +1,9 @@
 package handlers
 
 import (
+	"net/url"
+	"strings"
+
 	"psm-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -16,7 +19,8 @@ func NewStockHandler(stockService *services.StockService) *StockHandler {
 	}
 }
 
-// SearchStocks handles GET /api/v1/stocks/search?q=2330
+// SearchStocks handles GET /api/v1/stocks/search?q=2330 (or a name, e.g. q=台積);
+// type=etf limits the results to one security type
 func (h *StockHandler) SearchStocks(c *fiber.Ctx) error {
 	query := c.Query("q")
 	if query == "" {
@@ -26,8 +30,14 @@ func (h *StockHandler) SearchStocks(c *fiber.Ctx) error {
 	}
 
 	limit := c.QueryInt("limit", 20)
+	securityType := c.Query("type")
+	if securityType != "" && !services.IsSecurityType(securityType) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid type: must be stock, etf, etn, warrant, tdr, preferred or other",
+		})
+	}
 
-	stocks, err := h.stockService.SearchStocks(c.Context(), query, limit)
+	stocks, err := h.stockService.SearchStocks(c.Context(), query, securityType, limit)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -55,3 +65,61 @@ func (h *StockHandler) GetStock(c *fiber.Ctx) error {
 
 	return c.JSON(stock)
 }
+
+// ListIndustries handles GET /api/v1/industries?market=TSE, returning each
+// industry of active securities with its stock counts
+func (h *StockHandler) ListIndustries(c *fiber.Ctx) error {
+	market := strings.ToUpper(c.Query("market"))
+	if market != "" && market != "TSE" && market != "OTC" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid market: must be TSE or OTC",
+		})
+	}
+
+	industries, err := h.stockService.ListIndustries(c.Context(), market)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(industries),
+		"data":    industries,
+	})
+}
+
+// ListIndustryStocks handles GET /api/v1/industries/:name/stocks?market=OTC
+func (h *StockHandler) ListIndustryStocks(c *fiber.Ctx) error {
+	industry, err := url.PathUnescape(c.Params("name"))
+	if err != nil || industry == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "industry name is required",
+		})
+	}
+	market := strings.ToUpper(c.Query("market"))
+	if market != "" && market != "TSE" && market != "OTC" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid market: must be TSE or OTC",
+		})
+	}
+
+	stocks, err := h.stockService.ListStocksByIndustry(c.Context(), industry, market)
+	if err != nil {
+		status := fiber.StatusInternalServerError
+		if strings.Contains(err.Error(), "not found") {
+			status = fiber.StatusNotFound
+		}
+		return c.Status(status).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success":  true,
+		"industry": industry,
+		"count":    len(stocks),
+		"data":     stocks,
+	})
+}
@@ -18,7 +18,7 @@ func NewStockSyncHandler(syncService *services.StockSyncService) *StockSyncHandl
 
 // SyncStocks handles POST /api/v1/stocks/sync
 func (h *StockSyncHandler) SyncStocks(c *fiber.Ctx) error {
-	result, err := h.syncService.SyncAll(c.Context())
+	result, err := h.syncService.SyncAll(c.Context(), "manual")
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": err.Error(),
@@ -31,3 +31,20 @@ func (h *StockSyncHandler) SyncStocks(c *fiber.Ctx) error {
 		"result":  result,
 	})
 }
+
+// GetSyncHistory handles GET /api/v1/stocks/sync/history?limit=20, listing
+// recent syncs with the symbols each added, changed or deactivated
+func (h *StockSyncHandler) GetSyncHistory(c *fiber.Ctx) error {
+	runs, err := h.syncService.SyncHistory(c.Context(), c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(runs),
+		"data":    runs,
+	})
+}
@@ -0,0 +1,203 @@
+package handlers
+
+import (
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// StrategyHandler handles saved strategy endpoints
+type StrategyHandler struct {
+	strategyService *services.StrategyService
+	backtestService *services.BacktestService
+}
+
+func NewStrategyHandler(strategyService *services.StrategyService, backtestService *services.BacktestService) *StrategyHandler {
+	return &StrategyHandler{
+		strategyService: strategyService,
+		backtestService: backtestService,
+	}
+}
+
+// ListStrategies returns the user's strategies
+// GET /api/v1/strategies?include_archived=true
+func (h *StrategyHandler) ListStrategies(c *fiber.Ctx) error {
+	// For demo, use hardcoded user ID
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	strategies, err := h.strategyService.ListStrategies(c.Context(), userID, c.QueryBool("include_archived", false))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to list strategies",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(strategies),
+		"data":    strategies,
+	})
+}
+
+// CreateStrategy saves a new strategy
+// POST /api/v1/strategies
+// Body: {"name": "均線交叉", "definition": {"entry": {"left": "MA(5)", "op": "crosses_above", "right": "MA(20)"},
+// "exit": {"expression": "close < MA(20)"}, "stop_loss_pct": 8, "universe": {"symbols": ["2330"]}}}
+func (h *StrategyHandler) CreateStrategy(c *fiber.Ctx) error {
+	var req services.SaveStrategyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	strategy, err := h.strategyService.CreateStrategy(c.Context(), userID, req)
+	if err != nil {
+		return c.Status(strategyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    strategy,
+	})
+}
+
+// GetStrategy returns a strategy with its current definition
+// GET /api/v1/strategies/:id
+func (h *StrategyHandler) GetStrategy(c *fiber.Ctx) error {
+	strategy, err := h.strategyService.GetStrategy(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(strategyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    strategy,
+	})
+}
+
+// UpdateStrategy updates a strategy; a changed definition creates a new version
+// PUT /api/v1/strategies/:id
+// Body: {"definition": {...}, "change_note": "tighten stop loss"}
+func (h *StrategyHandler) UpdateStrategy(c *fiber.Ctx) error {
+	var req services.SaveStrategyRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	strategy, err := h.strategyService.UpdateStrategy(c.Context(), c.Params("id"), req)
+	if err != nil {
+		return c.Status(strategyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    strategy,
+	})
+}
+
+// DeleteStrategy archives a strategy; its versions stay available to linked backtests
+// DELETE /api/v1/strategies/:id
+func (h *StrategyHandler) DeleteStrategy(c *fiber.Ctx) error {
+	if err := h.strategyService.ArchiveStrategy(c.Context(), c.Params("id")); err != nil {
+		return c.Status(strategyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "strategy archived",
+	})
+}
+
+// ListVersions returns every revision of a strategy
+// GET /api/v1/strategies/:id/versions
+func (h *StrategyHandler) ListVersions(c *fiber.Ctx) error {
+	versions, err := h.strategyService.ListVersions(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(strategyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(versions),
+		"data":    versions,
+	})
+}
+
+// GetVersion returns a specific revision of a strategy
+// GET /api/v1/strategies/:id/versions/:version
+func (h *StrategyHandler) GetVersion(c *fiber.Ctx) error {
+	version, err := c.ParamsInt("version")
+	if err != nil || version <= 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid version",
+		})
+	}
+
+	v, err := h.strategyService.GetVersion(c.Context(), c.Params("id"), version)
+	if err != nil {
+		return c.Status(strategyErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    v,
+	})
+}
+
+// ListBacktests returns backtests that ran this strategy
+// GET /api/v1/strategies/:id/backtests?limit=20
+func (h *StrategyHandler) ListBacktests(c *fiber.Ctx) error {
+	limit := c.QueryInt("limit", 20)
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	jobs, err := h.backtestService.ListJobs(c.Context(), "", c.Params("id"), limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "failed to list backtests",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(jobs),
+		"data":    jobs,
+	})
+}
+
+func strategyErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.Contains(msg, "already exists"):
+		return fiber.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TradingRestrictionHandler handles disposition and full-cash-delivery endpoints
+type TradingRestrictionHandler struct {
+	restrictionService *services.TradingRestrictionService
+}
+
+func NewTradingRestrictionHandler(restrictionService *services.TradingRestrictionService) *TradingRestrictionHandler {
+	return &TradingRestrictionHandler{restrictionService: restrictionService}
+}
+
+func restrictionErrorStatus(err error) int {
+	switch {
+	case strings.Contains(err.Error(), "not found"):
+		return fiber.StatusNotFound
+	case strings.HasPrefix(err.Error(), "failed to"):
+		return fiber.StatusInternalServerError
+	}
+	return fiber.StatusBadRequest
+}
+
+// ListRestrictions returns the restrictions in effect on a date
+// GET /api/v1/stocks/restrictions?date=2024-01-02&symbol=2330
+func (h *TradingRestrictionHandler) ListRestrictions(c *fiber.Ctx) error {
+	restrictions, err := h.restrictionService.ListRestrictions(c.Context(), c.Query("symbol"), c.Query("date"))
+	if err != nil {
+		return c.Status(restrictionErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(restrictions),
+		"data":    restrictions,
+	})
+}
+
+// SetRestriction records a restriction by hand, e.g. a change to full-cash-delivery
+// PUT /api/v1/stocks/restrictions
+// Body: {"symbol": "1234", "restriction": "full_cash_delivery", "start_date": "2024-01-02", "end_date": "", "reason": "..."}
+func (h *TradingRestrictionHandler) SetRestriction(c *fiber.Ctx) error {
+	var req services.TradingRestrictionRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	restriction, err := h.restrictionService.SetRestriction(c.Context(), &req)
+	if err != nil {
+		return c.Status(restrictionErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    restriction,
+	})
+}
+
+// DeleteRestriction removes a restriction period
+// DELETE /api/v1/stocks/restrictions/:symbol/:restriction/:start_date
+func (h *TradingRestrictionHandler) DeleteRestriction(c *fiber.Ctx) error {
+	err := h.restrictionService.DeleteRestriction(c.Context(), c.Params("symbol"), c.Params("restriction"), c.Params("start_date"))
+	if err != nil {
+		return c.Status(restrictionErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+	})
+}
+
+// FetchDispositions downloads the current disposition announcements
+// POST /api/v1/stocks/restrictions/fetch
+func (h *TradingRestrictionHandler) FetchDispositions(c *fiber.Ctx) error {
+	results, err := h.restrictionService.FetchDispositions(c.Context())
+	if err != nil {
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+			"error": err.Error(),
+			"data":  results,
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+	})
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"strings"
+
+	"psm-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// WatchlistHandler handles watchlist endpoints
+type WatchlistHandler struct {
+	service *services.WatchlistService
+}
+
+func NewWatchlistHandler(service *services.WatchlistService) *WatchlistHandler {
+	return &WatchlistHandler{service: service}
+}
+
+// ListWatchlists returns the user's watchlists
+// GET /api/v1/watchlists
+func (h *WatchlistHandler) ListWatchlists(c *fiber.Ctx) error {
+	// For demo, use hardcoded user ID
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	watchlists, err := h.service.ListWatchlists(c.Context(), userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"count":   len(watchlists),
+		"data":    watchlists,
+	})
+}
+
+// CreateWatchlist creates a watchlist
+// POST /api/v1/watchlists
+// Body: {"name": "半導體", "symbols": ["2330", "2454"]}
+func (h *WatchlistHandler) CreateWatchlist(c *fiber.Ctx) error {
+	var req struct {
+		Name    string   `json:"name"`
+		Symbols []string `json:"symbols"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "invalid request body: " + err.Error(),
+		})
+	}
+
+	userID := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	watchlist, err := h.service.CreateWatchlist(c.Context(), userID, req.Name, req.Symbols)
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"success": true,
+		"data":    watchlist,
+	})
+}
+
+// GetWatchlist returns a watchlist with its symbols
+// GET /api/v1/watchlists/:id
+func (h *WatchlistHandler) GetWatchlist(c *fiber.Ctx) error {
+	watchlist, err := h.service.GetWatchlist(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    watchlist,
+	})
+}
+
+// DeleteWatchlist deletes a watchlist
+// DELETE /api/v1/watchlists/:id
+func (h *WatchlistHandler) DeleteWatchlist(c *fiber.Ctx) error {
+	if err := h.service.DeleteWatchlist(c.Context(), c.Params("id")); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "watchlist deleted",
+	})
+}
+
+// AddSymbols adds symbols to a watchlist
+// POST /api/v1/watchlists/:id/symbols
+// Body: {"symbols": ["2317"]}
+func (h *WatchlistHandler) AddSymbols(c *fiber.Ctx) error {
+	var req struct {
+		Symbols []string `json:"symbols"`
+	}
+	if err := c.BodyParser(&req); err != nil || len(req.Symbols) == 0 {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "symbols array is required",
+		})
+	}
+
+	if err := h.service.AddSymbols(c.Context(), c.Params("id"), req.Symbols); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return h.GetWatchlist(c)
+}
+
+// RemoveSymbol removes a symbol from a watchlist
+// DELETE /api/v1/watchlists/:id/symbols/:symbol
+func (h *WatchlistHandler) RemoveSymbol(c *fiber.Ctx) error {
+	if err := h.service.RemoveSymbol(c.Context(), c.Params("id"), c.Params("symbol")); err != nil {
+		return c.Status(watchlistErrorStatus(err)).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"message": "symbol removed",
+	})
+}
+
+func watchlistErrorStatus(err error) int {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "not found"):
+		return fiber.StatusNotFound
+	case strings.Contains(msg, "already exists"):
+		return fiber.StatusConflict
+	case strings.HasPrefix(msg, "failed to"):
+		return fiber.StatusInternalServerError
+	default:
+		return fiber.StatusBadRequest
+	}
+}
@@ -114,12 +114,21 @@ type RealizedPnL struct {
 
 // TaiwanStock represents a Taiwan stock symbol
 type TaiwanStock struct {
-	Symbol    string     `json:"symbol" db:"symbol"`
-	Name      string     `json:"name" db:"name"`
-	NameEn    *string    `json:"name_en,omitempty" db:"name_en"`
-	Market    string     `json:"market" db:"market"`
-	Industry  *string    `json:"industry,omitempty" db:"industry"`
-	IsActive  bool       `json:"is_active" db:"is_active"`
-	CreatedAt time.Time  `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at" db:"updated_at"`
+	Symbol       string     `json:"symbol" db:"symbol"`
+	Name         string     `json:"name" db:"name"`
+	NameEn       *string    `json:"name_en,omitempty" db:"name_en"`
+	Market       string     `json:"market" db:"market"`
+	Industry     *string    `json:"industry,omitempty" db:"industry"`
+	SecurityType string     `json:"security_type" db:"security_type"` // stock, etf, etn, warrant, tdr, preferred or other
+	IsActive     bool       `json:"is_active" db:"is_active"`
+	DelistedAt   *time.Time `json:"delisted_at,omitempty" db:"delisted_at"` // Set when the symbol disappeared from the sync sources
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
+
+	// Company profile, returned with the stock details only
+	PaidInCapital *int64     `json:"paid_in_capital,omitempty" db:"paid_in_capital"` // 實收資本額 (TWD)
+	ListingDate   *time.Time `json:"listing_date,omitempty" db:"listing_date"`
+	Chairman      *string    `json:"chairman,omitempty" db:"chairman"`
+	Website       *string    `json:"website,omitempty" db:"website"`
+	Address       *string    `json:"address,omitempty" db:"address"`
 }
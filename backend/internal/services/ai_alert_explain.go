@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// aiAlertSystemPrompt asks for a short, plain-language cause of an alert
+const aiAlertSystemPrompt = `你是一位台股市場分析師，負責向一般投資人解釋一則股票警示可能的成因。請：
+1. 使用繁體中文，以 3 到 5 句白話說明，不要使用標題或條列
+2. 依據提供的價量資料與相關新聞，指出最可能的原因，並引用具體新聞或數字
+3. 找不到明確消息時，直接說明可能是技術面或資金面因素，不要臆測未提供的事件
+4. 最後一句提醒這只是可能原因，不構成投資建議`
+
+// AIAlertExplanation is an AI explanation of what likely caused an alert
+type AIAlertExplanation struct {
+	AlertID      string             `json:"alert_id"`
+	Symbol       string             `json:"symbol"`
+	AlertType    AlertType          `json:"alert_type"`
+	Title        string             `json:"title"`
+	Language     AILanguage         `json:"language"`
+	Explanation  string             `json:"explanation"`
+	RelatedNews  []AlertRelatedNews `json:"related_news"`
+	Model        string             `json:"model"`
+	InputTokens  int                `json:"input_tokens,omitempty"`
+	OutputTokens int                `json:"output_tokens,omitempty"`
+	FallbackUsed bool               `json:"fallback_used,omitempty"`
+	ExplainedAt  time.Time          `json:"explained_at"`
+	Cached       bool               `json:"cached"`
+}
+
+// AlertRelatedNews is an article published around an alert
+type AlertRelatedNews struct {
+	ID          string    `json:"id"`
+	Title       string    `json:"title"`
+	Summary     string    `json:"summary,omitempty"`
+	Source      string    `json:"source"`
+	URL         string    `json:"url,omitempty"`
+	Sentiment   string    `json:"sentiment"`
+	IsFiling    bool      `json:"is_filing"` // MOPS material announcement
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// ExplainAlert returns the stored explanation of an alert, or generates one
+// from the alert payload, the price action around it and related news.
+// refresh, or a language other than the stored one, regenerates it.
+func (s *AIService) ExplainAlert(ctx context.Context, userID uuid.UUID, alertID string, language AILanguage, refresh bool) (*AIAlertExplanation, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+	if _, err := uuid.Parse(alertID); err != nil {
+		return nil, fmt.Errorf("alert %s not found", alertID)
+	}
+
+	var alert StockAlert
+	var explanation, model, explanationLanguage sql.NullString
+	var explanationData []byte
+	var explainedAt sql.NullTime
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, symbol, alert_type, severity, title, message, COALESCE(data, '{}'), triggered_at,
+		       COALESCE(reference_price, 0), COALESCE(reference_volume, 0), COALESCE(threshold_value, 0),
+		       ai_explanation, ai_explanation_data, ai_explanation_model, ai_explained_at,
+		       COALESCE(ai_explanation_language, 'zh-TW')
+		FROM stock_alerts
+		WHERE id = $1
+	`, alertID).Scan(&alert.ID, &alert.Symbol, &alert.AlertType, &alert.Severity, &alert.Title, &alert.Message, &alert.Data,
+		&alert.TriggeredAt, &alert.ReferencePrice, &alert.ReferenceVolume, &alert.ThresholdValue,
+		&explanation, &explanationData, &model, &explainedAt, &explanationLanguage)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert %s not found", alertID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert: %w", err)
+	}
+
+	result := &AIAlertExplanation{
+		AlertID:     alert.ID,
+		Symbol:      alert.Symbol,
+		AlertType:   alert.AlertType,
+		Title:       alert.Title,
+		Language:    language,
+		RelatedNews: []AlertRelatedNews{},
+	}
+	if explanation.Valid && explanationLanguage.String == string(language) && !refresh {
+		result.Explanation = explanation.String
+		result.Model = model.String
+		result.ExplainedAt = explainedAt.Time
+		result.Cached = true
+		if len(explanationData) > 0 {
+			json.Unmarshal(explanationData, &result.RelatedNews)
+		}
+		return result, nil
+	}
+
+	news, err := s.alertRelatedNews(ctx, &alert)
+	if err != nil {
+		return nil, err
+	}
+	result.RelatedNews = news
+
+	resp, err := s.generate(ctx, userID, "alert_explain", LLMRequest{
+		System:      aiAlertSystemPrompt + s.policy.instruction() + language.instruction(),
+		Messages:    []LLMMessage{{Role: "user", Content: s.formatAlertPrompt(ctx, &alert, news)}},
+		Temperature: 0.3,
+		MaxTokens:   600,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate explanation: %w", err)
+	}
+
+	result.Explanation = s.policy.apply(strings.TrimSpace(resp.Content), language)
+	result.Model = resp.Model
+	result.InputTokens = resp.InputTokens
+	result.OutputTokens = resp.OutputTokens
+	result.FallbackUsed = resp.FallbackUsed
+	result.ExplainedAt = time.Now()
+
+	newsJSON, _ := json.Marshal(news)
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE stock_alerts
+		SET ai_explanation = $2, ai_explanation_data = $3, ai_explanation_model = $4, ai_explanation_language = $5,
+		    ai_explained_at = NOW()
+		WHERE id = $1
+	`, alert.ID, result.Explanation, newsJSON, result.Model, string(language)); err != nil {
+		return nil, fmt.Errorf("failed to save explanation: %w", err)
+	}
+	return result, nil
+}
+
+// alertRelatedNews returns the symbol's articles from three days before to
+// one day after the alert, filings first
+func (s *AIService) alertRelatedNews(ctx context.Context, alert *StockAlert) ([]AlertRelatedNews, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT n.id, n.title, COALESCE(n.summary, ''), n.source, COALESCE(n.source_url, ''), COALESCE(n.sentiment, 'neutral'),
+		       COALESCE(n.category, '') = $3, n.published_at
+		FROM stock_news n
+		WHERE n.id IN (SELECT article_id FROM article_symbols WHERE symbol = $1)
+		  AND n.published_at BETWEEN $2::timestamptz - INTERVAL '3 days' AND $2::timestamptz + INTERVAL '1 day'
+		ORDER BY (COALESCE(n.category, '') = $3) DESC, ABS(COALESCE(n.sentiment_score, 0)) DESC, n.published_at DESC
+		LIMIT 8
+	`, alert.Symbol, alert.TriggeredAt, MaterialAnnouncementCategory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query related news: %w", err)
+	}
+	defer rows.Close()
+
+	news := []AlertRelatedNews{}
+	for rows.Next() {
+		var n AlertRelatedNews
+		if err := rows.Scan(&n.ID, &n.Title, &n.Summary, &n.Source, &n.URL, &n.Sentiment, &n.IsFiling, &n.PublishedAt); err != nil {
+			continue
+		}
+		n.Summary = truncateRunes(n.Summary, 200)
+		news = append(news, n)
+	}
+	return news, nil
+}
+
+// formatAlertPrompt renders the alert, the daily bars up to it and related news
+func (s *AIService) formatAlertPrompt(ctx context.Context, alert *StockAlert, news []AlertRelatedNews) string {
+	var sb strings.Builder
+	taipei := time.FixedZone("Asia/Taipei", 8*3600)
+
+	var name string
+	s.db.QueryRowContext(ctx, `SELECT COALESCE(name, name_en, symbol) FROM taiwan_stocks WHERE symbol = $1`, alert.Symbol).Scan(&name)
+
+	sb.WriteString("## 警示\n")
+	sb.WriteString(fmt.Sprintf("- 股票: %s %s\n", alert.Symbol, name))
+	sb.WriteString(fmt.Sprintf("- 類型: %s (%s)\n", alert.AlertType, alert.Severity))
+	sb.WriteString(fmt.Sprintf("- 觸發時間: %s\n", alert.TriggeredAt.In(taipei).Format("2006-01-02 15:04")))
+	sb.WriteString(fmt.Sprintf("- 標題: %s\n", alert.Title))
+	sb.WriteString(fmt.Sprintf("- 內容: %s\n", alert.Message))
+	if len(alert.Data) > 2 {
+		sb.WriteString(fmt.Sprintf("- 資料: %s\n", truncateRunes(string(alert.Data), 1000)))
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT timestamp, open, high, low, close, volume,
+		       AVG(volume) OVER (ORDER BY timestamp ROWS BETWEEN 20 PRECEDING AND 1 PRECEDING)
+		FROM stock_ohlcv
+		WHERE symbol = $1 AND timestamp <= $2::timestamptz + INTERVAL '1 day'
+		  AND timestamp >= $2::timestamptz - INTERVAL '45 days'
+		ORDER BY timestamp
+	`, alert.Symbol, alert.TriggeredAt)
+	if err == nil {
+		type bar struct {
+			date                   time.Time
+			open, high, low, close float64
+			volume                 int64
+			avgVolume              sql.NullFloat64
+		}
+		var bars []bar
+		for rows.Next() {
+			var b bar
+			if err := rows.Scan(&b.date, &b.open, &b.high, &b.low, &b.close, &b.volume, &b.avgVolume); err == nil {
+				bars = append(bars, b)
+			}
+		}
+		rows.Close()
+		if len(bars) > 6 {
+			bars = bars[len(bars)-6:]
+		}
+		if len(bars) > 0 {
+			sb.WriteString("\n## 警示前後日線\n")
+			for i, b := range bars {
+				line := fmt.Sprintf("- %s 開 %.2f 高 %.2f 低 %.2f 收 %.2f 量 %d", b.date.In(taipei).Format("01/02"), b.open, b.high, b.low, b.close, b.volume)
+				if i > 0 && bars[i-1].close > 0 {
+					line += fmt.Sprintf(" (漲跌 %.2f%%)", (b.close-bars[i-1].close)/bars[i-1].close*100)
+				}
+				if b.avgVolume.Valid && b.avgVolume.Float64 > 0 {
+					line += fmt.Sprintf(" (量為20日均量 %.1f 倍)", float64(b.volume)/b.avgVolume.Float64)
+				}
+				sb.WriteString(line + "\n")
+			}
+		}
+	}
+
+	sb.WriteString("\n## 相關新聞 (警示前3日至後1日)\n")
+	if len(news) == 0 {
+		sb.WriteString("- 無\n")
+	}
+	for i, n := range news {
+		label := n.Sentiment
+		if n.IsFiling {
+			label = "重大訊息"
+		}
+		sb.WriteString(fmt.Sprintf("%d. [%s] %s (%s, %s)\n", i+1, label, n.Title, n.Source, n.PublishedAt.In(taipei).Format("01/02 15:04")))
+		if n.Summary != "" {
+			sb.WriteString(fmt.Sprintf("   %s\n", n.Summary))
+		}
+	}
+
+	sb.WriteString("\n---\n請說明這則警示最可能的成因。")
+	return sb.String()
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Per-symbol statuses of a batch analysis
+const (
+	AIBatchItemCached    = "cached" // Served from today's cache when submitted
+	AIBatchItemQueued    = "queued"
+	AIBatchItemRunning   = "running"
+	AIBatchItemCompleted = "completed"
+	AIBatchItemFailed    = "failed"
+)
+
+// AIAnalysisBatchItem is one symbol of a batch analysis
+type AIAnalysisBatchItem struct {
+	Symbol string            `json:"symbol"`
+	Status string            `json:"status"`
+	Result *AIAnalysisResult `json:"result,omitempty"`
+	Error  string            `json:"error,omitempty"`
+}
+
+// AIAnalysisBatch analyses several symbols with one analysis type, e.g. a
+// watchlist. Cached analyses are reused; the rest run through the provider
+// queue a few at a time.
+type AIAnalysisBatch struct {
+	ID           string                `json:"id"`
+	AnalysisType AnalysisType          `json:"analysis_type"`
+	Language     AILanguage            `json:"language"`
+	Status       string                `json:"status"` // running, completed
+	Total        int                   `json:"total"`
+	Done         int                   `json:"done"` // Cached, completed or failed
+	Failed       int                   `json:"failed"`
+	Items        []AIAnalysisBatchItem `json:"items"`
+	CreatedAt    time.Time             `json:"created_at"`
+	CompletedAt  *time.Time            `json:"completed_at,omitempty"`
+
+	userID uuid.UUID
+}
+
+// aiBatchMaxSymbols reads AI_BATCH_MAX_SYMBOLS (default 20)
+func aiBatchMaxSymbols() int {
+	if v, err := strconv.Atoi(os.Getenv("AI_BATCH_MAX_SYMBOLS")); err == nil && v > 0 {
+		return v
+	}
+	return 20
+}
+
+// SubmitAnalysisBatch starts a batch analysis of up to AI_BATCH_MAX_SYMBOLS
+// symbols and returns it at once; poll it with GetAnalysisBatch
+func (s *AIService) SubmitAnalysisBatch(ctx context.Context, userID uuid.UUID, symbols []string, analysisType AnalysisType, language AILanguage) (*AIAnalysisBatch, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+
+	var unique []string
+	seen := make(map[string]bool)
+	for _, sym := range symbols {
+		if sym = strings.ToUpper(strings.TrimSpace(sym)); sym != "" && !seen[sym] {
+			seen[sym] = true
+			unique = append(unique, sym)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("symbols is required")
+	}
+	if max := aiBatchMaxSymbols(); len(unique) > max {
+		return nil, fmt.Errorf("at most %d symbols per batch", max)
+	}
+
+	batch := &AIAnalysisBatch{
+		ID:           uuid.New().String(),
+		AnalysisType: analysisType,
+		Language:     language,
+		Status:       "running",
+		Total:        len(unique),
+		Items:        make([]AIAnalysisBatchItem, len(unique)),
+		CreatedAt:    time.Now(),
+		userID:       userID,
+	}
+
+	today := time.Now().Format("2006-01-02")
+	template := s.activePromptTemplate(ctx, analysisType)
+	var pending []int
+	for i, sym := range unique {
+		batch.Items[i] = AIAnalysisBatchItem{Symbol: sym, Status: AIBatchItemQueued}
+		if cached, err := s.getCachedAnalysis(ctx, sym, analysisType, language, today); err == nil && cached.PromptVersion == template.Version {
+			cached.Cached = true
+			batch.Items[i].Status = AIBatchItemCached
+			batch.Items[i].Result = cached
+			batch.Done++
+			continue
+		}
+		pending = append(pending, i)
+	}
+	if len(pending) == 0 {
+		now := time.Now()
+		batch.Status = "completed"
+		batch.CompletedAt = &now
+	}
+
+	s.jobsMu.Lock()
+	s.pruneJobsLocked()
+	s.batches[batch.ID] = batch
+	s.jobsMu.Unlock()
+
+	if len(pending) > 0 {
+		go s.runAnalysisBatch(batch, pending)
+	}
+	return s.GetAnalysisBatch(batch.ID, userID)
+}
+
+// runAnalysisBatch analyses the pending items with one worker fewer than the
+// per-user limit, so the user can still make interactive requests meanwhile
+func (s *AIService) runAnalysisBatch(batch *AIAnalysisBatch, pending []int) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	workers := s.limiter.stats().MaxPerUser - 1
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				s.runAnalysisBatchItem(ctx, batch, i)
+			}
+		}()
+	}
+	for _, i := range pending {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	s.jobsMu.Lock()
+	now := time.Now()
+	batch.Status = "completed"
+	batch.CompletedAt = &now
+	s.jobsMu.Unlock()
+	log.Printf("AI batch %s (%s): %d symbols, %d failed", batch.ID, batch.AnalysisType, batch.Total, batch.Failed)
+}
+
+func (s *AIService) runAnalysisBatchItem(ctx context.Context, batch *AIAnalysisBatch, i int) {
+	symbol := batch.Items[i].Symbol
+
+	// Once the budget is exhausted the remaining items would only fail the same way
+	s.jobsMu.Lock()
+	budgetErr := ""
+	for _, item := range batch.Items {
+		if item.Status == AIBatchItemFailed && strings.Contains(item.Error, "budget exceeded") {
+			budgetErr = item.Error
+			break
+		}
+	}
+	s.jobsMu.Unlock()
+	if budgetErr != "" {
+		s.setBatchItem(batch, i, AIBatchItemFailed, nil, fmt.Errorf("%s", budgetErr))
+		return
+	}
+
+	// Wait for a slot while another of the user's requests holds the per-user limit
+	var ticket *aiTicket
+	for {
+		var err error
+		if ticket, err = s.limiter.enter(batch.userID); err == nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			s.setBatchItem(batch, i, AIBatchItemFailed, nil, ctx.Err())
+			return
+		case <-time.After(2 * time.Second):
+		}
+	}
+	defer ticket.release()
+	if err := ticket.wait(ctx); err != nil {
+		s.setBatchItem(batch, i, AIBatchItemFailed, nil, err)
+		return
+	}
+
+	s.setBatchItem(batch, i, AIBatchItemRunning, nil, nil)
+	result, err := s.GetAnalysis(context.WithValue(ctx, aiTicketKey{}, ticket), batch.userID, symbol, batch.AnalysisType, batch.Language)
+	if err != nil {
+		log.Printf("AI batch %s: %s failed: %v", batch.ID, symbol, err)
+		s.setBatchItem(batch, i, AIBatchItemFailed, nil, err)
+		return
+	}
+	s.setBatchItem(batch, i, AIBatchItemCompleted, result, nil)
+}
+
+func (s *AIService) setBatchItem(batch *AIAnalysisBatch, i int, status string, result *AIAnalysisResult, err error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	item := &batch.Items[i]
+	item.Status = status
+	item.Result = result
+	if err != nil {
+		item.Error = err.Error()
+	}
+	switch status {
+	case AIBatchItemCompleted:
+		batch.Done++
+	case AIBatchItemFailed:
+		batch.Done++
+		batch.Failed++
+	}
+}
+
+// GetAnalysisBatch returns a batch analysis of userID with each symbol's status
+func (s *AIService) GetAnalysisBatch(id string, userID uuid.UUID) (*AIAnalysisBatch, error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	batch, ok := s.batches[id]
+	if !ok || batch.userID != userID {
+		return nil, fmt.Errorf("analysis batch not found")
+	}
+	snapshot := *batch
+	snapshot.Items = append([]AIAnalysisBatchItem(nil), batch.Items...)
+	return &snapshot, nil
+}
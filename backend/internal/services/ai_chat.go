@@ -0,0 +1,367 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// aiChatHistoryMessages is how many earlier turns are replayed to the model
+const aiChatHistoryMessages = 20
+
+// aiChatMaxMessageRunes bounds a single user message
+const aiChatMaxMessageRunes = 4000
+
+const aiChatSystemPrompt = `你是一位專業的台股分析師，正在與投資人對話。你的回答應該：
+1. 使用繁體中文回答
+2. 以下方提供的最新資料為依據，資料不足時直接說明，不要臆測數字
+3. 客觀專業，避免過度樂觀或悲觀
+4. 回答追問時延續先前的對話脈絡
+5. 在適當時候提醒投資風險
+`
+
+// AIChatRequest is one user turn. Without SessionID a new session is started;
+// Symbol and PortfolioID set (or change) what the session is grounded on.
+type AIChatRequest struct {
+	SessionID   *uuid.UUID `json:"session_id,omitempty"`
+	Message     string     `json:"message"`
+	Symbol      string     `json:"symbol,omitempty"`
+	PortfolioID *uuid.UUID `json:"portfolio_id,omitempty"`
+}
+
+// AIChatSession is one conversation
+type AIChatSession struct {
+	ID          uuid.UUID       `json:"id"`
+	UserID      uuid.UUID       `json:"user_id"`
+	Title       string          `json:"title"`
+	Symbol      *string         `json:"symbol,omitempty"`
+	PortfolioID *uuid.UUID      `json:"portfolio_id,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Messages    []AIChatMessage `json:"messages,omitempty"`
+}
+
+// AIChatMessage is one turn of a conversation
+type AIChatMessage struct {
+	ID           uuid.UUID `json:"id"`
+	Role         string    `json:"role"` // user, assistant
+	Content      string    `json:"content"`
+	Provider     string    `json:"provider,omitempty"`
+	Model        string    `json:"model,omitempty"`
+	InputTokens  int       `json:"input_tokens,omitempty"`
+	OutputTokens int       `json:"output_tokens,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// AIChatReply is the assistant's answer to one turn
+type AIChatReply struct {
+	Session AIChatSession `json:"session"`
+	Message AIChatMessage `json:"message"`
+}
+
+// Chat answers a user message, replaying the session's earlier turns and
+// grounding the model on the current data of the session's symbol and/or
+// portfolio. Both turns are stored only when generation succeeds.
+func (s *AIService) Chat(ctx context.Context, userID uuid.UUID, req AIChatRequest) (*AIChatReply, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		return nil, fmt.Errorf("message is required")
+	}
+	if utf8.RuneCountInString(req.Message) > aiChatMaxMessageRunes {
+		return nil, fmt.Errorf("message must be at most %d characters", aiChatMaxMessageRunes)
+	}
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+
+	// Resolve the session; a new one is only inserted once the answer exists
+	session := &AIChatSession{UserID: userID}
+	var history []LLMMessage
+	if req.SessionID != nil {
+		existing, err := s.GetChatSession(ctx, userID, *req.SessionID)
+		if err != nil {
+			return nil, err
+		}
+		session = existing
+		history = chatHistory(existing.Messages)
+		session.Messages = nil
+	} else {
+		session.Title = truncateRunes(req.Message, 50)
+	}
+	if req.Symbol != "" {
+		session.Symbol = &req.Symbol
+	}
+	if req.PortfolioID != nil {
+		session.PortfolioID = req.PortfolioID
+	}
+
+	grounding, err := s.chatGrounding(ctx, userID, session)
+	if err != nil {
+		return nil, err
+	}
+
+	system := aiChatSystemPrompt + s.policy.instruction()
+	if grounding != "" {
+		system += "\n# 最新資料 (" + time.Now().Format("2006-01-02 15:04") + ")\n" + grounding
+	}
+	messages := append(history, LLMMessage{Role: "user", Content: req.Message})
+
+	resp, err := s.generate(ctx, userID, "chat", LLMRequest{
+		System:      system,
+		Messages:    messages,
+		Temperature: 0.5,
+		MaxTokens:   2048,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate reply: %w", err)
+	}
+
+	reply := AIChatMessage{
+		Role:         "assistant",
+		Content:      s.policy.apply(resp.Content, AILanguageZhTW),
+		Provider:     s.provider.Name(),
+		Model:        resp.Model,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+	}
+	if err := s.saveChatTurn(ctx, session, req.Message, &reply); err != nil {
+		return nil, err
+	}
+
+	return &AIChatReply{Session: *session, Message: reply}, nil
+}
+
+// saveChatTurn inserts (or updates) the session and stores both messages
+func (s *AIService) saveChatTurn(ctx context.Context, session *AIChatSession, question string, reply *AIChatMessage) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if session.ID == uuid.Nil {
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO ai_chat_sessions (user_id, title, symbol, portfolio_id)
+			VALUES ($1, $2, $3, $4)
+			RETURNING id, created_at, updated_at
+		`, session.UserID, session.Title, session.Symbol, session.PortfolioID).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
+	} else {
+		err = tx.QueryRowContext(ctx, `
+			UPDATE ai_chat_sessions SET symbol = $2, portfolio_id = $3, updated_at = NOW()
+			WHERE id = $1
+			RETURNING updated_at
+		`, session.ID, session.Symbol, session.PortfolioID).Scan(&session.UpdatedAt)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save chat session: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO ai_chat_messages (session_id, role, content) VALUES ($1, 'user', $2)
+	`, session.ID, question); err != nil {
+		return fmt.Errorf("failed to save chat message: %w", err)
+	}
+
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO ai_chat_messages (session_id, role, content, provider, model, input_tokens, output_tokens)
+		VALUES ($1, 'assistant', $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, session.ID, reply.Content, reply.Provider, reply.Model, reply.InputTokens, reply.OutputTokens).Scan(&reply.ID, &reply.CreatedAt); err != nil {
+		return fmt.Errorf("failed to save chat reply: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// chatGrounding renders the session's symbol and portfolio data for the system prompt
+func (s *AIService) chatGrounding(ctx context.Context, userID uuid.UUID, session *AIChatSession) (string, error) {
+	var sb strings.Builder
+
+	if session.PortfolioID != nil {
+		holdings, err := s.formatPortfolioContext(ctx, userID, *session.PortfolioID)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(holdings)
+	}
+
+	if session.Symbol != nil && *session.Symbol != "" {
+		stockContext, err := s.buildStockContext(ctx, *session.Symbol)
+		if err != nil {
+			return "", fmt.Errorf("failed to build stock context: %w", err)
+		}
+		if sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(formatStockContext(stockContext))
+	}
+
+	return sb.String(), nil
+}
+
+// formatPortfolioContext lists the holdings of one of the user's portfolios
+// with their latest close and unrealised P&L
+func (s *AIService) formatPortfolioContext(ctx context.Context, userID, portfolioID uuid.UUID) (string, error) {
+	var name string
+	err := s.db.QueryRowContext(ctx, `SELECT name FROM portfolios WHERE id = $1 AND user_id = $2`, portfolioID, userID).Scan(&name)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("portfolio not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query portfolio: %w", err)
+	}
+
+	query := `
+		SELECT split_part(pc.symbol, '.', 1), COALESCE(ts.name, ''), pc.total_quantity::float8,
+		       pc.avg_cost_per_share::float8, px.close::float8
+		FROM positions_current pc
+		LEFT JOIN taiwan_stocks ts ON ts.symbol = split_part(pc.symbol, '.', 1)
+		LEFT JOIN LATERAL (
+			SELECT close FROM stock_ohlcv
+			WHERE symbol = split_part(pc.symbol, '.', 1)
+			ORDER BY timestamp DESC
+			LIMIT 1
+		) px ON true
+		WHERE pc.portfolio_id = $1 AND pc.total_quantity > 0
+		ORDER BY pc.total_cost DESC
+		LIMIT 30
+	`
+	rows, err := s.db.QueryContext(ctx, query, portfolioID)
+	if err != nil {
+		return "", fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("## 投資組合: %s\n", name))
+	count := 0
+	for rows.Next() {
+		var symbol, stockName string
+		var quantity, avgCost float64
+		var close sql.NullFloat64
+		if err := rows.Scan(&symbol, &stockName, &quantity, &avgCost, &close); err != nil {
+			continue
+		}
+		count++
+		line := fmt.Sprintf("- %s %s: %.0f 股, 均價 %.2f", symbol, stockName, quantity, avgCost)
+		if close.Valid && avgCost > 0 {
+			line += fmt.Sprintf(", 收盤 %.2f, 未實現損益 %.2f%%", close.Float64, (close.Float64/avgCost-1)*100)
+		}
+		sb.WriteString(line + "\n")
+	}
+	if count == 0 {
+		sb.WriteString("- 目前無持股\n")
+	}
+	return sb.String(), nil
+}
+
+// ListChatSessions returns the user's conversations, most recently active first
+func (s *AIService) ListChatSessions(ctx context.Context, userID uuid.UUID, limit int) ([]AIChatSession, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, user_id, title, symbol, portfolio_id, created_at, updated_at
+		FROM ai_chat_sessions
+		WHERE user_id = $1
+		ORDER BY updated_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := []AIChatSession{}
+	for rows.Next() {
+		var cs AIChatSession
+		if err := rows.Scan(&cs.ID, &cs.UserID, &cs.Title, &cs.Symbol, &cs.PortfolioID, &cs.CreatedAt, &cs.UpdatedAt); err != nil {
+			continue
+		}
+		sessions = append(sessions, cs)
+	}
+	return sessions, nil
+}
+
+// GetChatSession returns one of the user's conversations with its latest messages, oldest first
+func (s *AIService) GetChatSession(ctx context.Context, userID, sessionID uuid.UUID) (*AIChatSession, error) {
+	var cs AIChatSession
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, title, symbol, portfolio_id, created_at, updated_at
+		FROM ai_chat_sessions
+		WHERE id = $1 AND user_id = $2
+	`, sessionID, userID).Scan(&cs.ID, &cs.UserID, &cs.Title, &cs.Symbol, &cs.PortfolioID, &cs.CreatedAt, &cs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("chat session not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat session: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, role, content, COALESCE(provider, ''), COALESCE(model, ''),
+		       COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), created_at
+		FROM (
+			SELECT * FROM ai_chat_messages
+			WHERE session_id = $1
+			ORDER BY created_at DESC
+			LIMIT $2
+		) m
+		ORDER BY created_at
+	`, sessionID, aiChatHistoryMessages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query chat messages: %w", err)
+	}
+	defer rows.Close()
+
+	cs.Messages = []AIChatMessage{}
+	for rows.Next() {
+		var m AIChatMessage
+		if err := rows.Scan(&m.ID, &m.Role, &m.Content, &m.Provider, &m.Model, &m.InputTokens, &m.OutputTokens, &m.CreatedAt); err != nil {
+			continue
+		}
+		cs.Messages = append(cs.Messages, m)
+	}
+	return &cs, nil
+}
+
+// DeleteChatSession deletes one of the user's conversations
+func (s *AIService) DeleteChatSession(ctx context.Context, userID, sessionID uuid.UUID) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM ai_chat_sessions WHERE id = $1 AND user_id = $2`, sessionID, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete chat session: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("chat session not found")
+	}
+	return nil
+}
+
+// chatHistory converts stored messages into provider turns. The history must
+// start with a user turn, so a leading assistant message cut off by the
+// history limit is dropped.
+func chatHistory(messages []AIChatMessage) []LLMMessage {
+	history := make([]LLMMessage, 0, len(messages))
+	for _, m := range messages {
+		if len(history) == 0 && m.Role != "user" {
+			continue
+		}
+		history = append(history, LLMMessage{Role: m.Role, Content: m.Content})
+	}
+	return history
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n]) + "…"
+}
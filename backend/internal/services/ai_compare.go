@@ -0,0 +1,292 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// aiComparisonSystemPrompt instructs the model to compare two symbols and end
+// with a machine-readable verdict per dimension
+const aiComparisonSystemPrompt = `你是一位專業的台股分析師，負責比較兩檔股票。你的分析應該：
+1. 使用繁體中文回答
+2. 依序比較估值、動能、市場情緒三個面向，每個面向說明哪一檔較佳及理由
+3. 估值以資料中的價格相對位置 (52週區間位置、與季線乖離) 判斷，資料未提供本益比時不要自行假設
+4. 引用資料中的具體數字，最後總結較適合的標的與主要風險
+5. 客觀專業，並提醒投資風險
+
+在分析內容之後，請另外附上一個 JSON 區塊（以 ` + "```json" + ` 開頭、` + "```" + ` 結尾），格式如下，winner 與 preferred 填入股票代碼，難分高下時填 "tie"：
+` + "```json" + `
+{"valuation": {"winner": "", "summary": ""}, "momentum": {"winner": "", "summary": ""}, "sentiment": {"winner": "", "summary": ""}, "preferred": "", "confidence": 0.0}
+` + "```" + `
+summary 為一句繁體中文，confidence 為 0 到 1 之間的數字。`
+
+// AIComparisonDimension is the verdict on one dimension of a comparison
+type AIComparisonDimension struct {
+	Winner  string `json:"winner"` // Symbol, or "tie"
+	Summary string `json:"summary"`
+}
+
+// AIComparisonOutput holds the machine-readable verdicts of a comparison
+type AIComparisonOutput struct {
+	Valuation  AIComparisonDimension `json:"valuation"`
+	Momentum   AIComparisonDimension `json:"momentum"`
+	Sentiment  AIComparisonDimension `json:"sentiment"`
+	Preferred  string                `json:"preferred"`  // Symbol, or "tie"
+	Confidence float64               `json:"confidence"` // 0 to 1
+}
+
+// AIComparisonMetrics are the figures a comparison was written from
+type AIComparisonMetrics struct {
+	Symbol         string  `json:"symbol"`
+	Name           string  `json:"name"`
+	Close          float64 `json:"close"`
+	Return20D      float64 `json:"return_20d"`     // Percent
+	Return60D      float64 `json:"return_60d"`     // Percent
+	High52W        float64 `json:"high_52w"`       // Highest close
+	Low52W         float64 `json:"low_52w"`        // Lowest close
+	RangePosition  float64 `json:"range_position"` // 0 = 52-week low, 1 = 52-week high
+	MA60Deviation  float64 `json:"ma60_deviation"` // Percent above (below) the 60-day average
+	RSI            float64 `json:"rsi"`
+	SentimentScore float64 `json:"sentiment_score"` // 7-day confidence-weighted, -1 to 1
+	NewsArticles   int     `json:"news_articles"`   // Articles behind the sentiment score
+}
+
+// AIComparison is a head-to-head AI comparison of two symbols
+type AIComparison struct {
+	Symbols      []string              `json:"symbols"` // In symbol order
+	Language     AILanguage            `json:"language"`
+	Content      string                `json:"content"`
+	Structured   *AIComparisonOutput   `json:"structured,omitempty"` // nil when the model returned no valid block
+	Metrics      []AIComparisonMetrics `json:"metrics"`
+	Provider     string                `json:"provider"`
+	Model        string                `json:"model"`
+	InputTokens  int                   `json:"input_tokens"`
+	OutputTokens int                   `json:"output_tokens"`
+	FallbackUsed bool                  `json:"fallback_used"`
+	CreatedAt    time.Time             `json:"created_at"`
+	Cached       bool                  `json:"cached"`
+}
+
+// CompareSymbols retrieves or generates today's comparison of two symbols in
+// the given language; a generated comparison counts towards userID's usage
+func (s *AIService) CompareSymbols(ctx context.Context, userID uuid.UUID, symbols []string, language AILanguage) (*AIComparison, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+
+	pair := []string{}
+	for _, sym := range symbols {
+		if sym = strings.ToUpper(strings.TrimSpace(sym)); sym != "" {
+			pair = append(pair, sym)
+		}
+	}
+	if len(pair) != 2 || pair[0] == pair[1] {
+		return nil, fmt.Errorf("symbols must name exactly two different symbols, e.g. 2330,2454")
+	}
+	sort.Strings(pair)
+
+	today := time.Now().Format("2006-01-02")
+	if cached, err := s.getCachedComparison(ctx, pair, language, today); err == nil {
+		cached.Cached = true
+		return cached, nil
+	}
+
+	var sections strings.Builder
+	metrics := make([]AIComparisonMetrics, 0, 2)
+	for _, sym := range pair {
+		stockContext, err := s.buildStockContext(ctx, sym)
+		if err != nil {
+			return nil, fmt.Errorf("failed to build stock context: %w", err)
+		}
+		if stockContext.CurrentPrice == 0 {
+			return nil, fmt.Errorf("price data for %s not found", sym)
+		}
+		m := s.comparisonMetrics(ctx, stockContext)
+		metrics = append(metrics, m)
+
+		sections.WriteString(fmt.Sprintf("# %s %s\n", sym, stockContext.Name))
+		sections.WriteString(formatStockContext(stockContext))
+		sections.WriteString(formatComparisonMetrics(m))
+		sections.WriteString("\n")
+	}
+	sections.WriteString(fmt.Sprintf("---\n請根據以上資訊，比較 %s 與 %s 的估值、動能與市場情緒。", pair[0], pair[1]))
+
+	resp, err := s.generate(ctx, userID, "comparison", LLMRequest{
+		System:      aiComparisonSystemPrompt + s.policy.instruction() + language.instruction(),
+		Messages:    []LLMMessage{{Role: "user", Content: sections.String()}},
+		Temperature: 0.4,
+		MaxTokens:   2048,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate comparison: %w", err)
+	}
+
+	content, structured := parseComparisonOutput(resp.Content, pair)
+	result := &AIComparison{
+		Symbols:      pair,
+		Language:     language,
+		Content:      s.policy.apply(content, language),
+		Structured:   structured,
+		Metrics:      metrics,
+		Provider:     s.provider.Name(),
+		Model:        resp.Model,
+		InputTokens:  resp.InputTokens,
+		OutputTokens: resp.OutputTokens,
+		FallbackUsed: resp.FallbackUsed,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := s.cacheComparison(ctx, result, today); err != nil {
+		log.Printf("Warning: failed to cache comparison: %v", err)
+	}
+	return result, nil
+}
+
+// comparisonMetrics adds returns and the 52-week range to a stock context
+func (s *AIService) comparisonMetrics(ctx context.Context, stockContext *StockContext) AIComparisonMetrics {
+	m := AIComparisonMetrics{
+		Symbol: stockContext.Symbol,
+		Name:   stockContext.Name,
+		Close:  stockContext.CurrentPrice,
+		RSI:    roundTo(stockContext.RSI, 2),
+	}
+	if stockContext.SentimentSummary != nil {
+		m.SentimentScore = roundTo(stockContext.SentimentSummary.WeightedScore, 3)
+		m.NewsArticles = stockContext.SentimentSummary.TotalArticles
+	}
+
+	var close20, close60, ma60 sql.NullFloat64
+	s.db.QueryRowContext(ctx, `
+		WITH d AS (
+			SELECT close, ROW_NUMBER() OVER (ORDER BY timestamp DESC) AS rn
+			FROM stock_ohlcv
+			WHERE symbol = $1 AND timestamp >= NOW() - INTERVAL '365 days'
+		)
+		SELECT MAX(close) FILTER (WHERE rn = 21), MAX(close) FILTER (WHERE rn = 61),
+		       AVG(close) FILTER (WHERE rn <= 60), COALESCE(MAX(close), 0), COALESCE(MIN(close), 0)
+		FROM d
+	`, stockContext.Symbol).Scan(&close20, &close60, &ma60, &m.High52W, &m.Low52W)
+
+	pct := func(from sql.NullFloat64) float64 {
+		if !from.Valid || from.Float64 <= 0 {
+			return 0
+		}
+		return roundTo((m.Close-from.Float64)/from.Float64*100, 2)
+	}
+	m.Return20D = pct(close20)
+	m.Return60D = pct(close60)
+	m.MA60Deviation = pct(ma60)
+	if m.High52W > m.Low52W {
+		m.RangePosition = roundTo(clampFloat((m.Close-m.Low52W)/(m.High52W-m.Low52W), 0, 1), 3)
+	}
+	return m
+}
+
+// formatComparisonMetrics renders the comparison figures as prompt markdown
+func formatComparisonMetrics(m AIComparisonMetrics) string {
+	var sb strings.Builder
+	sb.WriteString("\n## 比較指標\n")
+	sb.WriteString(fmt.Sprintf("- 20日報酬: %.2f%%, 60日報酬: %.2f%%\n", m.Return20D, m.Return60D))
+	if m.High52W > 0 {
+		sb.WriteString(fmt.Sprintf("- 52週收盤區間: %.2f ~ %.2f (目前位於區間 %.0f%%)\n", m.Low52W, m.High52W, m.RangePosition*100))
+	}
+	sb.WriteString(fmt.Sprintf("- 與60日均價乖離: %.2f%%\n", m.MA60Deviation))
+	if m.RSI > 0 {
+		sb.WriteString(fmt.Sprintf("- RSI(14): %.1f\n", m.RSI))
+	}
+	return sb.String()
+}
+
+// parseComparisonOutput splits a comparison into its text and verdict block.
+// Winners that are not one of the pair are treated as a tie.
+func parseComparisonOutput(content string, pair []string) (string, *AIComparisonOutput) {
+	matches := aiStructuredBlockPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content, nil
+	}
+	last := matches[len(matches)-1]
+
+	var output AIComparisonOutput
+	if err := json.Unmarshal([]byte(content[last[2]:last[3]]), &output); err != nil {
+		return content, nil
+	}
+
+	winner := func(w string) string {
+		w = strings.ToUpper(strings.TrimSpace(w))
+		for _, sym := range pair {
+			if w == sym {
+				return sym
+			}
+		}
+		return "tie"
+	}
+	for _, d := range []*AIComparisonDimension{&output.Valuation, &output.Momentum, &output.Sentiment} {
+		d.Winner = winner(d.Winner)
+		d.Summary = truncateRunes(strings.TrimSpace(d.Summary), 300)
+	}
+	output.Preferred = winner(output.Preferred)
+	output.Confidence = roundTo(clampFloat(output.Confidence, 0, 1), 3)
+
+	text := strings.TrimSpace(content[:last[0]] + content[last[1]:])
+	return text, &output
+}
+
+func (s *AIService) getCachedComparison(ctx context.Context, pair []string, language AILanguage, date string) (*AIComparison, error) {
+	var r AIComparison
+	var structured, metrics []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT content, structured, metrics, provider, model, COALESCE(input_tokens, 0), COALESCE(output_tokens, 0),
+		       fallback_used, created_at
+		FROM ai_comparison_cache
+		WHERE symbol_a = $1 AND symbol_b = $2 AND comparison_date = $3 AND language = $4
+		  AND (expires_at IS NULL OR expires_at > NOW())
+	`, pair[0], pair[1], date, string(language)).Scan(&r.Content, &structured, &metrics, &r.Provider, &r.Model,
+		&r.InputTokens, &r.OutputTokens, &r.FallbackUsed, &r.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Symbols = pair
+	r.Language = language
+	if len(structured) > 0 {
+		json.Unmarshal(structured, &r.Structured)
+	}
+	json.Unmarshal(metrics, &r.Metrics)
+	return &r, nil
+}
+
+func (s *AIService) cacheComparison(ctx context.Context, result *AIComparison, date string) error {
+	var structured interface{}
+	if result.Structured != nil {
+		data, err := json.Marshal(result.Structured)
+		if err != nil {
+			return err
+		}
+		structured = data
+	}
+	metrics, err := json.Marshal(result.Metrics)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO ai_comparison_cache (symbol_a, symbol_b, comparison_date, content, structured, metrics, provider, model,
+		                                 input_tokens, output_tokens, fallback_used, language, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, NOW() + INTERVAL '24 hours')
+		ON CONFLICT (symbol_a, symbol_b, comparison_date, language) DO UPDATE SET
+			content = EXCLUDED.content, structured = EXCLUDED.structured, metrics = EXCLUDED.metrics,
+			provider = EXCLUDED.provider, model = EXCLUDED.model,
+			input_tokens = EXCLUDED.input_tokens, output_tokens = EXCLUDED.output_tokens,
+			fallback_used = EXCLUDED.fallback_used, created_at = NOW(), expires_at = NOW() + INTERVAL '24 hours'
+	`, result.Symbols[0], result.Symbols[1], date, result.Content, structured, metrics, result.Provider, result.Model,
+		result.InputTokens, result.OutputTokens, result.FallbackUsed, string(result.Language))
+	return err
+}
@@ -0,0 +1,528 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const aiDigestSystemPrompt = `你是一位專業的台股投資組合助理，負責在收盤後為投資人撰寫每日摘要。請：
+1. 使用繁體中文，以 Markdown 撰寫，全文約 300 到 600 字
+2. 依序包含：今日重點、持股表現、自選股動態、重要新聞、警示事項、明日觀察
+3. 只根據提供的資料撰寫，沒有資料的段落直接略過，不要臆測數字
+4. 客觀專業，避免給出明確的買賣指示，並在最後簡短提醒投資風險
+`
+
+// AIDigest is one user's AI-written digest for a trading day
+type AIDigest struct {
+	ID            string       `json:"id"`
+	UserID        uuid.UUID    `json:"user_id"`
+	Date          string       `json:"date"`
+	Content       string       `json:"content"`
+	Data          AIDigestData `json:"data"`
+	Provider      string       `json:"provider"`
+	Model         string       `json:"model"`
+	InputTokens   int          `json:"input_tokens"`
+	OutputTokens  int          `json:"output_tokens"`
+	DeliveredAt   *time.Time   `json:"delivered_at,omitempty"`
+	DeliveryError string       `json:"delivery_error,omitempty"`
+	CreatedAt     time.Time    `json:"created_at"`
+}
+
+// AIDigestData is the structured input a digest is written from
+type AIDigestData struct {
+	Holdings  []DigestMover `json:"holdings"`
+	Watchlist []DigestMover `json:"watchlist"`
+	News      []DigestNews  `json:"news"`
+	Alerts    []DigestAlert `json:"alerts"`
+}
+
+// DigestMover is one tracked symbol's move on the digest day
+type DigestMover struct {
+	Symbol      string   `json:"symbol"`
+	Name        string   `json:"name"`
+	Quantity    float64  `json:"quantity,omitempty"` // Shares held
+	Close       *float64 `json:"close,omitempty"`
+	ChangePct   *float64 `json:"change_pct,omitempty"`
+	VolumeRatio *float64 `json:"volume_ratio,omitempty"` // Volume relative to the 20-day average
+}
+
+// DigestNews is a notable article about a tracked symbol
+type DigestNews struct {
+	Title       string    `json:"title"`
+	Source      string    `json:"source"`
+	Symbols     []string  `json:"symbols"`
+	Sentiment   string    `json:"sentiment"`
+	Score       float64   `json:"score"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// DigestAlert is an alert raised on the digest day for a tracked symbol
+type DigestAlert struct {
+	Symbol   string `json:"symbol"`
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Title    string `json:"title"`
+}
+
+// TaipeiToday returns the current Asia/Taipei date as YYYY-MM-DD
+func TaipeiToday() string {
+	return time.Now().In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02")
+}
+
+// GetDigest returns a stored digest, or an error containing "not found"
+func (s *AIService) GetDigest(ctx context.Context, userID uuid.UUID, date string) (*AIDigest, error) {
+	var d AIDigest
+	var data []byte
+	var deliveryError sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, user_id, to_char(digest_date, 'YYYY-MM-DD'), content, data, provider, model,
+		       COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), delivered_at, delivery_error, created_at
+		FROM ai_daily_digests
+		WHERE user_id = $1 AND digest_date = $2::date
+	`, userID, date).Scan(
+		&d.ID, &d.UserID, &d.Date, &d.Content, &data, &d.Provider, &d.Model,
+		&d.InputTokens, &d.OutputTokens, &d.DeliveredAt, &deliveryError, &d.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("digest for %s not found", date)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest: %w", err)
+	}
+	json.Unmarshal(data, &d.Data)
+	d.DeliveryError = deliveryError.String
+	return &d, nil
+}
+
+// GenerateDigest writes (or rewrites) a user's digest for a Taipei date from
+// their holdings, watchlists, the day's news and alerts
+func (s *AIService) GenerateDigest(ctx context.Context, userID uuid.UUID, date string) (*AIDigest, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("date must be YYYY-MM-DD")
+	}
+
+	data, err := s.collectDigestData(ctx, userID, date)
+	if err != nil {
+		return nil, err
+	}
+	if len(data.Holdings) == 0 && len(data.Watchlist) == 0 {
+		return nil, fmt.Errorf("no holdings or watchlist symbols to summarise")
+	}
+
+	resp, err := s.generate(ctx, userID, "digest", LLMRequest{
+		System:      aiDigestSystemPrompt + s.policy.instruction(),
+		Messages:    []LLMMessage{{Role: "user", Content: formatDigestPrompt(date, data)}},
+		Temperature: 0.4,
+		MaxTokens:   2048,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate digest: %w", err)
+	}
+
+	dataJSON, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode digest data: %w", err)
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO ai_daily_digests (user_id, digest_date, content, data, provider, model, input_tokens, output_tokens)
+		VALUES ($1, $2::date, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id, digest_date) DO UPDATE SET
+			content = EXCLUDED.content, data = EXCLUDED.data,
+			provider = EXCLUDED.provider, model = EXCLUDED.model,
+			input_tokens = EXCLUDED.input_tokens, output_tokens = EXCLUDED.output_tokens,
+			delivered_at = NULL, delivery_error = NULL, created_at = NOW()
+	`, userID, date, s.policy.apply(resp.Content, AILanguageZhTW), dataJSON, s.provider.Name(), resp.Model, resp.InputTokens, resp.OutputTokens)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save digest: %w", err)
+	}
+
+	return s.GetDigest(ctx, userID, date)
+}
+
+// MarkDigestDelivered records the outcome of sending a digest to the user's webhooks
+func (s *AIService) MarkDigestDelivered(ctx context.Context, digestID string, deliveryErr error) {
+	if deliveryErr != nil {
+		s.db.ExecContext(ctx, `UPDATE ai_daily_digests SET delivery_error = $2 WHERE id = $1`, digestID, deliveryErr.Error())
+		return
+	}
+	s.db.ExecContext(ctx, `UPDATE ai_daily_digests SET delivered_at = NOW(), delivery_error = NULL WHERE id = $1`, digestID)
+}
+
+// collectDigestData gathers the movers, news and alerts for a user's tracked symbols
+func (s *AIService) collectDigestData(ctx context.Context, userID uuid.UUID, date string) (*AIDigestData, error) {
+	data := &AIDigestData{
+		Holdings:  []DigestMover{},
+		Watchlist: []DigestMover{},
+		News:      []DigestNews{},
+		Alerts:    []DigestAlert{},
+	}
+
+	// Real (non-paper) holdings and watchlist items; a held symbol is reported once, as a holding
+	moversQuery := `
+		WITH tracked AS (
+			SELECT split_part(pc.symbol, '.', 1) AS symbol, pc.total_quantity::float8 AS quantity, true AS held
+			FROM positions_current pc
+			JOIN portfolios p ON p.id = pc.portfolio_id
+			WHERE p.user_id = $1 AND COALESCE(p.is_paper, false) = false AND pc.total_quantity > 0
+			UNION ALL
+			SELECT i.symbol, 0, false
+			FROM watchlist_items i
+			JOIN watchlists w ON w.id = i.watchlist_id
+			WHERE w.user_id = $1
+		),
+		merged AS (
+			SELECT symbol, SUM(quantity) AS quantity, bool_or(held) AS held
+			FROM tracked
+			GROUP BY symbol
+		)
+		SELECT m.symbol, COALESCE(ts.name, ''), m.held, m.quantity,
+		       b.closes[1]::float8, b.closes[2]::float8, b.volumes[1]::float8, b.avg_volume::float8
+		FROM merged m
+		LEFT JOIN taiwan_stocks ts ON ts.symbol = m.symbol
+		LEFT JOIN LATERAL (
+			SELECT array_agg(close ORDER BY timestamp DESC) AS closes,
+			       array_agg(volume ORDER BY timestamp DESC) AS volumes,
+			       AVG(volume) AS avg_volume
+			FROM (
+				SELECT close, volume, timestamp FROM stock_ohlcv
+				WHERE symbol = m.symbol AND timestamp < $2::date + 1
+				ORDER BY timestamp DESC
+				LIMIT 20
+			) o
+		) b ON true
+	`
+	rows, err := s.db.QueryContext(ctx, moversQuery, userID, date)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracked symbols: %w", err)
+	}
+	var symbols []string
+	for rows.Next() {
+		var m DigestMover
+		var held bool
+		var closePrice, prevClose, volume, avgVolume sql.NullFloat64
+		if err := rows.Scan(&m.Symbol, &m.Name, &held, &m.Quantity, &closePrice, &prevClose, &volume, &avgVolume); err != nil {
+			continue
+		}
+		if closePrice.Valid {
+			c := closePrice.Float64
+			m.Close = &c
+			if prevClose.Valid && prevClose.Float64 > 0 {
+				pct := roundTo((c/prevClose.Float64-1)*100, 2)
+				m.ChangePct = &pct
+			}
+		}
+		if volume.Valid && avgVolume.Valid && avgVolume.Float64 > 0 {
+			ratio := roundTo(volume.Float64/avgVolume.Float64, 2)
+			m.VolumeRatio = &ratio
+		}
+		symbols = append(symbols, m.Symbol)
+		if held {
+			data.Holdings = append(data.Holdings, m)
+		} else {
+			data.Watchlist = append(data.Watchlist, m)
+		}
+	}
+	rows.Close()
+
+	// Biggest moves first
+	for _, movers := range [][]DigestMover{data.Holdings, data.Watchlist} {
+		sort.SliceStable(movers, func(i, j int) bool {
+			return absChange(movers[i]) > absChange(movers[j])
+		})
+	}
+	if len(symbols) == 0 {
+		return data, nil
+	}
+
+	// The day's strongest-sentiment articles about tracked symbols
+	newsQuery := `
+		SELECT n.title, n.source, COALESCE(n.sentiment, 'neutral'), COALESCE(n.sentiment_score, 0)::float8, n.published_at,
+		       ARRAY(SELECT a.symbol FROM article_symbols a WHERE a.article_id = n.id AND a.symbol = ANY($2) ORDER BY a.symbol)
+		FROM stock_news n
+		WHERE (n.published_at AT TIME ZONE 'Asia/Taipei')::date = $1::date
+		  AND EXISTS (SELECT 1 FROM article_symbols a WHERE a.article_id = n.id AND a.symbol = ANY($2))
+		ORDER BY ABS(COALESCE(n.sentiment_score, 0)) DESC, n.published_at DESC
+		LIMIT 15
+	`
+	newsRows, err := s.db.QueryContext(ctx, newsQuery, date, pq.Array(symbols))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest news: %w", err)
+	}
+	for newsRows.Next() {
+		var n DigestNews
+		if err := newsRows.Scan(&n.Title, &n.Source, &n.Sentiment, &n.Score, &n.PublishedAt, pq.Array(&n.Symbols)); err != nil {
+			continue
+		}
+		n.Score = roundTo(n.Score, 2)
+		data.News = append(data.News, n)
+	}
+	newsRows.Close()
+
+	alertsQuery := `
+		SELECT symbol, alert_type, severity, title
+		FROM stock_alerts
+		WHERE symbol = ANY($2)
+		  AND (triggered_at AT TIME ZONE 'Asia/Taipei')::date = $1::date
+		ORDER BY CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, triggered_at DESC
+		LIMIT 20
+	`
+	alertRows, err := s.db.QueryContext(ctx, alertsQuery, date, pq.Array(symbols))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query digest alerts: %w", err)
+	}
+	defer alertRows.Close()
+	for alertRows.Next() {
+		var a DigestAlert
+		if err := alertRows.Scan(&a.Symbol, &a.Type, &a.Severity, &a.Title); err != nil {
+			continue
+		}
+		data.Alerts = append(data.Alerts, a)
+	}
+
+	return data, nil
+}
+
+func absChange(m DigestMover) float64 {
+	if m.ChangePct == nil {
+		return -1
+	}
+	return math.Abs(*m.ChangePct)
+}
+
+// formatDigestPrompt renders the digest data as prompt markdown
+func formatDigestPrompt(date string, data *AIDigestData) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# %s 收盤資料\n", date))
+
+	writeMovers := func(title string, movers []DigestMover, limit int) {
+		if len(movers) == 0 {
+			return
+		}
+		sb.WriteString(fmt.Sprintf("\n## %s\n", title))
+		for i, m := range movers {
+			if i >= limit {
+				sb.WriteString(fmt.Sprintf("- 其餘 %d 檔略\n", len(movers)-limit))
+				break
+			}
+			line := fmt.Sprintf("- %s %s", m.Symbol, m.Name)
+			if m.Quantity > 0 {
+				line += fmt.Sprintf(" (持有 %.0f 股)", m.Quantity)
+			}
+			if m.Close != nil {
+				line += fmt.Sprintf(": 收盤 %.2f", *m.Close)
+			} else {
+				line += ": 無今日報價"
+			}
+			if m.ChangePct != nil {
+				line += fmt.Sprintf(", 漲跌 %+.2f%%", *m.ChangePct)
+			}
+			if m.VolumeRatio != nil {
+				line += fmt.Sprintf(", 量比 %.2f", *m.VolumeRatio)
+			}
+			sb.WriteString(line + "\n")
+		}
+	}
+	writeMovers("持股", data.Holdings, 30)
+	writeMovers("自選股", data.Watchlist, 20)
+
+	if len(data.News) > 0 {
+		sb.WriteString("\n## 今日新聞\n")
+		for _, n := range data.News {
+			sb.WriteString(fmt.Sprintf("- [%s %.2f] %s (%s; %s)\n", n.Sentiment, n.Score, n.Title, strings.Join(n.Symbols, ", "), n.Source))
+		}
+	}
+
+	if len(data.Alerts) > 0 {
+		sb.WriteString("\n## 今日警示\n")
+		for _, a := range data.Alerts {
+			sb.WriteString(fmt.Sprintf("- [%s] %s %s\n", a.Severity, a.Symbol, a.Title))
+		}
+	}
+
+	sb.WriteString("\n---\n請根據以上資料撰寫今日收盤摘要。")
+	return sb.String()
+}
+
+// AIDigestWorker writes every user's daily digest once the trading day's
+// market data has been synced, and optionally delivers it to their webhooks
+// and notification channels
+type AIDigestWorker struct {
+	aiService          *AIService
+	newsWebhookService *NewsWebhookService
+	notifier           *AlertNotificationService
+	runAfterHour       int  // Taipei local hour after which digests are written
+	deliver            bool // Send digests to the users' news webhooks and channels
+	checkInterval      time.Duration
+	mu                 sync.Mutex
+	isRunning          bool
+	isBusy             bool
+	stopChan           chan struct{}
+}
+
+// NewAIDigestWorker reads AI_DIGEST_HOUR (earliest Taipei hour, default 15)
+// and AI_DIGEST_DELIVER (send to webhooks and channels, default true)
+func NewAIDigestWorker(aiService *AIService, newsWebhookService *NewsWebhookService, notifier *AlertNotificationService) *AIDigestWorker {
+	hour := 15
+	if v, err := strconv.Atoi(os.Getenv("AI_DIGEST_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+
+	return &AIDigestWorker{
+		aiService:          aiService,
+		newsWebhookService: newsWebhookService,
+		notifier:           notifier,
+		runAfterHour:       hour,
+		deliver:            os.Getenv("AI_DIGEST_DELIVER") != "false",
+		checkInterval:      10 * time.Minute,
+		stopChan:           make(chan struct{}),
+	}
+}
+
+// Start launches the scheduler loop
+func (w *AIDigestWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("AI digest worker started (after %02d:00, delivery %v)", w.runAfterHour, w.deliver)
+}
+
+// Stop stops the scheduler loop
+func (w *AIDigestWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+// Deliver sends a digest to the user's webhooks and opted-in notification
+// channels, such as LINE, and records the outcome
+func (w *AIDigestWorker) Deliver(ctx context.Context, digest *AIDigest) error {
+	_, err := w.newsWebhookService.NotifyUser(ctx, digest.UserID, "ai.digest", digest)
+	if _, channelErr := w.notifier.DeliverDigest(ctx, digest); err == nil {
+		err = channelErr
+	}
+	w.aiService.MarkDigestDelivered(ctx, digest.ID, err)
+	return err
+}
+
+func (w *AIDigestWorker) loop() {
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+
+	w.checkAndRun()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun writes missing digests when it is past the digest hour and today's
+// bars have landed, so weekends and holidays are skipped
+func (w *AIDigestWorker) checkAndRun() {
+	if !w.aiService.IsConfigured() {
+		return
+	}
+	loc := time.FixedZone("Asia/Taipei", 8*3600)
+	now := time.Now().In(loc)
+	if now.Hour() < w.runAfterHour {
+		return
+	}
+
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	today := now.Format("2006-01-02")
+	var hasBars bool
+	if err := w.aiService.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM stock_ohlcv WHERE timestamp >= $1::date AND timestamp < $1::date + 1 AND `+notIndexSymbolSQL+`)`, today,
+	).Scan(&hasBars); err != nil || !hasBars {
+		return
+	}
+
+	// Users with something to summarise and no digest yet
+	rows, err := w.aiService.db.QueryContext(ctx, `
+		SELECT u.id FROM users u
+		WHERE (
+			EXISTS (
+				SELECT 1 FROM portfolios p JOIN positions_current pc ON pc.portfolio_id = p.id
+				WHERE p.user_id = u.id AND COALESCE(p.is_paper, false) = false AND pc.total_quantity > 0
+			)
+			OR EXISTS (
+				SELECT 1 FROM watchlists w JOIN watchlist_items i ON i.watchlist_id = w.id
+				WHERE w.user_id = u.id
+			)
+		)
+		AND NOT EXISTS (SELECT 1 FROM ai_daily_digests d WHERE d.user_id = u.id AND d.digest_date = $1::date)
+	`, today)
+	if err != nil {
+		log.Printf("AI digest: failed to query users: %v", err)
+		return
+	}
+	var userIDs []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, userID := range userIDs {
+		digest, err := w.aiService.GenerateDigest(ctx, userID, today)
+		if err != nil {
+			log.Printf("AI digest for %s: %v", userID, err)
+			continue
+		}
+		if w.deliver {
+			if err := w.Deliver(ctx, digest); err != nil {
+				log.Printf("AI digest for %s: delivery failed: %v", userID, err)
+			}
+		}
+	}
+	if len(userIDs) > 0 {
+		log.Printf("AI digest: wrote %d digests for %s", len(userIDs), today)
+	}
+}
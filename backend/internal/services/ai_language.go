@@ -0,0 +1,41 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AILanguage is the language AI output is written in
+type AILanguage string
+
+const (
+	AILanguageZhTW AILanguage = "zh-TW" // Traditional Chinese, the default
+	AILanguageEN   AILanguage = "en"
+)
+
+// AILanguages lists the supported output languages
+var AILanguages = []AILanguage{AILanguageZhTW, AILanguageEN}
+
+// ParseAILanguage maps a language parameter to a supported language; "" is
+// the default zh-TW, and regional variants of English are accepted
+func ParseAILanguage(value string) (AILanguage, error) {
+	switch v := strings.ToLower(strings.TrimSpace(value)); {
+	case v == "", v == "zh-tw", v == "zh_tw", v == "zh-hant", v == "zh":
+		return AILanguageZhTW, nil
+	case v == "en" || strings.HasPrefix(v, "en-") || strings.HasPrefix(v, "en_"):
+		return AILanguageEN, nil
+	}
+	return "", fmt.Errorf("unsupported language %q (expected zh-TW or en)", value)
+}
+
+// instruction is appended to a system prompt, after any structured-output
+// instruction, to override the Traditional Chinese the prompts ask for.
+// Prompt templates stay in Chinese either way.
+func (l AILanguage) instruction() string {
+	if l != AILanguageEN {
+		return ""
+	}
+	return `
+
+IMPORTANT: Write the entire response in English, including every text value in the JSON block, even though these instructions and the data are in Chinese. Keep stock symbols as given and give company names in English where well known. Translate the stance and risk wording, but keep the JSON keys and the stance values (bullish, neutral, bearish) exactly as specified.`
+}
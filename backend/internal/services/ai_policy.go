@@ -0,0 +1,236 @@
+package services
+
+import (
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Default risk disclaimers appended to AI output
+const (
+	aiDefaultDisclaimerZhTW = "⚠️ 本內容由 AI 依公開資料自動產生，僅供參考，不構成任何投資建議。投資有風險，請審慎評估並自負盈虧。"
+	aiDefaultDisclaimerEN   = "⚠️ This content was generated by AI from public data for reference only and is not investment advice. Investing involves risk; evaluate carefully."
+)
+
+// aiTradeCommandPattern matches explicit buy/sell instructions, as opposed to
+// describing what a stance or indicator implies
+var aiTradeCommandPattern = regexp.MustCompile(`(?i)` +
+	`(建議|立即|立刻|馬上|現在|趕快|趕緊|應該|可以)\s*(買進|買入|賣出|賣掉|加碼|減碼|出清|進場|出場|做多|做空|放空|停損|停利|獲利了結)` +
+	`|強力(買進|賣出)` +
+	`|\b(buy|sell|short)\s+(it\s+)?(now|immediately|today|right away)\b` +
+	`|\b(we|i)\s+(strongly\s+)?recommend\s+(buying|selling|shorting)\b` +
+	`|\byou\s+should\s+(buy|sell|short)\b` +
+	`|\bstrong\s+(buy|sell)\b`)
+
+// aiContentPolicy is applied to every piece of generated text before it is
+// returned or stored: blocklisted phrases are masked, explicit buy/sell
+// instructions are dropped when AI_ALLOW_TRADE_COMMANDS=false, and a risk
+// disclaimer is appended. Configured with AI_DISCLAIMER / AI_DISCLAIMER_EN
+// ("off" for none), AI_ALLOW_TRADE_COMMANDS and AI_BLOCKLIST (comma-separated).
+type aiContentPolicy struct {
+	disclaimers        map[AILanguage]string // Empty = no disclaimer
+	allowTradeCommands bool
+	blocklist          []string
+	blocklistPattern   *regexp.Regexp // nil = no blocklist
+}
+
+// AIContentPolicy describes the active content policy
+type AIContentPolicy struct {
+	Disclaimer         bool     `json:"disclaimer"`
+	AllowTradeCommands bool     `json:"allow_trade_commands"`
+	Blocklist          []string `json:"blocklist"`
+}
+
+func newAIContentPolicy() *aiContentPolicy {
+	p := &aiContentPolicy{
+		disclaimers: map[AILanguage]string{
+			AILanguageZhTW: aiDefaultDisclaimerZhTW,
+			AILanguageEN:   aiDefaultDisclaimerEN,
+		},
+		allowTradeCommands: !strings.EqualFold(os.Getenv("AI_ALLOW_TRADE_COMMANDS"), "false"),
+		blocklist:          []string{},
+	}
+
+	zh := strings.TrimSpace(os.Getenv("AI_DISCLAIMER"))
+	en := strings.TrimSpace(os.Getenv("AI_DISCLAIMER_EN"))
+	if strings.EqualFold(zh, "off") {
+		p.disclaimers = map[AILanguage]string{}
+	} else {
+		if zh != "" {
+			p.disclaimers[AILanguageZhTW] = zh
+			p.disclaimers[AILanguageEN] = zh
+		}
+		if en != "" {
+			p.disclaimers[AILanguageEN] = en
+		}
+	}
+
+	var quoted []string
+	for _, phrase := range strings.Split(os.Getenv("AI_BLOCKLIST"), ",") {
+		if phrase = strings.TrimSpace(phrase); phrase != "" {
+			p.blocklist = append(p.blocklist, phrase)
+			quoted = append(quoted, regexp.QuoteMeta(phrase))
+		}
+	}
+	if len(quoted) > 0 {
+		p.blocklistPattern = regexp.MustCompile(`(?i)` + strings.Join(quoted, "|"))
+	}
+	return p
+}
+
+// Status describes the policy for the status endpoint
+func (p *aiContentPolicy) Status() AIContentPolicy {
+	return AIContentPolicy{
+		Disclaimer:         len(p.disclaimers) > 0,
+		AllowTradeCommands: p.allowTradeCommands,
+		Blocklist:          p.blocklist,
+	}
+}
+
+// instruction is appended to system prompts so the model avoids what the
+// policy would otherwise have to strip
+func (p *aiContentPolicy) instruction() string {
+	if p.allowTradeCommands {
+		return ""
+	}
+	return "\n\n請勿給出明確的買進、賣出、加碼、減碼或停損指令，只描述數據代表的意義與風險。"
+}
+
+// filters reports whether the policy changes text beyond appending the disclaimer
+func (p *aiContentPolicy) filters() bool {
+	return !p.allowTradeCommands || p.blocklistPattern != nil
+}
+
+// apply enforces the policy on a complete piece of generated text
+func (p *aiContentPolicy) apply(content string, language AILanguage) string {
+	text, removed := content, false
+	if p.filters() {
+		text, removed = p.filterSentences(content)
+	}
+	return strings.TrimRight(text, " \n") + p.footer(text, language, removed)
+}
+
+// filterSentences drops sentences with trade instructions and masks blocklisted phrases
+func (p *aiContentPolicy) filterSentences(text string) (string, bool) {
+	var sb strings.Builder
+	removed := false
+	for _, sentence := range splitPolicySentences(text) {
+		if !p.allowTradeCommands && aiTradeCommandPattern.MatchString(sentence) {
+			removed = true
+			// Keep the line structure of markdown lists and paragraphs
+			if strings.HasSuffix(sentence, "\n") {
+				sb.WriteString("\n")
+			}
+			continue
+		}
+		if p.blocklistPattern != nil {
+			sentence = p.blocklistPattern.ReplaceAllString(sentence, "***")
+		}
+		sb.WriteString(sentence)
+	}
+	return sb.String(), removed
+}
+
+// footer is the text appended after the (filtered) content
+func (p *aiContentPolicy) footer(text string, language AILanguage, removed bool) string {
+	var notes []string
+	if removed {
+		if language == AILanguageEN {
+			notes = append(notes, "(Explicit buy/sell instructions were removed under the content policy.)")
+		} else {
+			notes = append(notes, "（已依內容政策移除明確的買賣指令）")
+		}
+	}
+	if d := p.disclaimers[language]; d != "" && !strings.Contains(text, d) {
+		notes = append(notes, d)
+	}
+	if len(notes) == 0 {
+		return ""
+	}
+	return "\n\n---\n" + strings.Join(notes, "\n")
+}
+
+// splitPolicySentences splits text after sentence terminators and newlines; joining
+// the parts gives back the original text
+func splitPolicySentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		end := -1
+		switch r {
+		case '。', '！', '？', '!', '?', '\n':
+			end = i + len(string(r))
+		case '.':
+			// An English full stop, not a decimal point
+			if i+1 == len(text) || text[i+1] == ' ' || text[i+1] == '\n' {
+				end = i + 1
+			}
+		}
+		if end > 0 {
+			sentences = append(sentences, text[start:end])
+			start = end
+		}
+	}
+	if start < len(text) {
+		sentences = append(sentences, text[start:])
+	}
+	return sentences
+}
+
+// policyStreamFilter applies the policy to streamed text a sentence at a
+// time, so filtered phrases never reach the client, and streams the footer
+// once generation ends
+type policyStreamFilter struct {
+	policy   *aiContentPolicy
+	language AILanguage
+	onChunk  func(text string) error
+	pending  string
+	emitted  strings.Builder // Filtered text, to detect a disclaimer the model wrote itself
+	removed  bool
+}
+
+func (f *policyStreamFilter) write(text string) error {
+	if !f.policy.filters() {
+		f.emitted.WriteString(text)
+		return f.onChunk(text)
+	}
+
+	f.pending += text
+	sentences := splitPolicySentences(f.pending)
+	// Hold back the last part unless it ends a sentence; a trailing '.' may
+	// still turn out to be a decimal point
+	n := len(sentences)
+	if n > 0 {
+		if r, _ := utf8.DecodeLastRuneInString(sentences[n-1]); !strings.ContainsRune("。！？!?\n", r) {
+			n--
+		}
+	}
+	f.pending = strings.Join(sentences[n:], "")
+	return f.emit(strings.Join(sentences[:n], ""))
+}
+
+// flush forwards the held-back sentence and the footer
+func (f *policyStreamFilter) flush() error {
+	if err := f.emit(f.pending); err != nil {
+		return err
+	}
+	f.pending = ""
+	if footer := f.policy.footer(f.emitted.String(), f.language, f.removed); footer != "" {
+		return f.onChunk(footer)
+	}
+	return nil
+}
+
+func (f *policyStreamFilter) emit(text string) error {
+	if text == "" {
+		return nil
+	}
+	filtered, removed := f.policy.filterSentences(text)
+	f.removed = f.removed || removed
+	if filtered == "" {
+		return nil
+	}
+	f.emitted.WriteString(filtered)
+	return f.onChunk(filtered)
+}
@@ -0,0 +1,242 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// aiPromptMaxRunes bounds each stored prompt
+const aiPromptMaxRunes = 20000
+
+// AnalysisTypes lists every analysis type, in display order
+var AnalysisTypes = []AnalysisType{
+	AnalysisTypeDailySummary,
+	AnalysisTypeInvestmentAdvice,
+	AnalysisTypeRiskAssessment,
+	AnalysisTypeNewsDigest,
+}
+
+// AIPromptTemplate is one version of the prompts for an analysis type.
+// Version 0 is the built-in prompt, used when no version is active.
+type AIPromptTemplate struct {
+	ID           string       `json:"id,omitempty"`
+	AnalysisType AnalysisType `json:"analysis_type"`
+	Version      int          `json:"version"`
+	SystemPrompt string       `json:"system_prompt"`
+	UserPrompt   string       `json:"user_prompt"` // {{context}}, {{symbol}} and {{name}} are substituted
+	IsActive     bool         `json:"is_active"`
+	Note         string       `json:"note,omitempty"`
+	CreatedAt    *time.Time   `json:"created_at,omitempty"`
+}
+
+// AIPromptTemplateRequest creates a new template version
+type AIPromptTemplateRequest struct {
+	SystemPrompt string `json:"system_prompt"`
+	UserPrompt   string `json:"user_prompt"`
+	Note         string `json:"note"`
+	Activate     *bool  `json:"activate"` // Default true
+}
+
+// render fills in the user prompt. Without a {{context}} placeholder the stock
+// data is put before the template, so a template cannot silently drop it.
+func (t *AIPromptTemplate) render(stockContext *StockContext) string {
+	data := formatStockContext(stockContext)
+	prompt := t.UserPrompt
+	if !strings.Contains(prompt, "{{context}}") {
+		prompt = "{{context}}\n---\n" + prompt
+	}
+	return strings.NewReplacer(
+		"{{context}}", data,
+		"{{symbol}}", stockContext.Symbol,
+		"{{name}}", stockContext.Name,
+	).Replace(prompt)
+}
+
+func builtinPromptTemplate(analysisType AnalysisType) *AIPromptTemplate {
+	return &AIPromptTemplate{
+		AnalysisType: analysisType,
+		SystemPrompt: builtinSystemPrompt(analysisType),
+		UserPrompt:   builtinUserPrompt(analysisType),
+		Note:         "built-in",
+	}
+}
+
+func validAnalysisType(analysisType AnalysisType) bool {
+	for _, t := range AnalysisTypes {
+		if t == analysisType {
+			return true
+		}
+	}
+	return false
+}
+
+// activePromptTemplate returns the active stored version, or the built-in
+// prompts when none is active or the table is unavailable
+func (s *AIService) activePromptTemplate(ctx context.Context, analysisType AnalysisType) *AIPromptTemplate {
+	templates, err := s.queryPromptTemplates(ctx, `WHERE analysis_type = $1 AND is_active`, string(analysisType))
+	if err != nil || len(templates) == 0 {
+		return builtinPromptTemplate(analysisType)
+	}
+	return &templates[0]
+}
+
+// ListActivePromptTemplates returns the template in use for every analysis type
+func (s *AIService) ListActivePromptTemplates(ctx context.Context) []AIPromptTemplate {
+	templates := make([]AIPromptTemplate, 0, len(AnalysisTypes))
+	for _, t := range AnalysisTypes {
+		template := s.activePromptTemplate(ctx, t)
+		if template.Version == 0 {
+			template.IsActive = true
+		}
+		templates = append(templates, *template)
+	}
+	return templates
+}
+
+// GetPromptTemplateHistory returns every version of an analysis type's
+// template, newest first, ending with the built-in version 0
+func (s *AIService) GetPromptTemplateHistory(ctx context.Context, analysisType AnalysisType) ([]AIPromptTemplate, error) {
+	if !validAnalysisType(analysisType) {
+		return nil, fmt.Errorf("unknown analysis type %q", analysisType)
+	}
+
+	templates, err := s.queryPromptTemplates(ctx, `WHERE analysis_type = $1 ORDER BY version DESC`, string(analysisType))
+	if err != nil {
+		return nil, err
+	}
+
+	builtin := builtinPromptTemplate(analysisType)
+	builtin.IsActive = true
+	for _, t := range templates {
+		if t.IsActive {
+			builtin.IsActive = false
+		}
+	}
+	return append(templates, *builtin), nil
+}
+
+// CreatePromptTemplate stores the next version of an analysis type's prompts,
+// activating it unless req.Activate is false
+func (s *AIService) CreatePromptTemplate(ctx context.Context, analysisType AnalysisType, req AIPromptTemplateRequest) (*AIPromptTemplate, error) {
+	if !validAnalysisType(analysisType) {
+		return nil, fmt.Errorf("unknown analysis type %q", analysisType)
+	}
+	req.SystemPrompt = strings.TrimSpace(req.SystemPrompt)
+	req.UserPrompt = strings.TrimSpace(req.UserPrompt)
+	if req.SystemPrompt == "" || req.UserPrompt == "" {
+		return nil, fmt.Errorf("system_prompt and user_prompt are required")
+	}
+	if utf8.RuneCountInString(req.SystemPrompt) > aiPromptMaxRunes || utf8.RuneCountInString(req.UserPrompt) > aiPromptMaxRunes {
+		return nil, fmt.Errorf("prompts must be at most %d characters", aiPromptMaxRunes)
+	}
+	activate := req.Activate == nil || *req.Activate
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Serialise version numbering per analysis type
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('ai_prompt_templates:' || $1))`, string(analysisType)); err != nil {
+		return nil, fmt.Errorf("failed to lock prompt templates: %w", err)
+	}
+	if activate {
+		if _, err := tx.ExecContext(ctx, `UPDATE ai_prompt_templates SET is_active = false WHERE analysis_type = $1 AND is_active`, string(analysisType)); err != nil {
+			return nil, fmt.Errorf("failed to deactivate prompt template: %w", err)
+		}
+	}
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO ai_prompt_templates (analysis_type, version, system_prompt, user_prompt, is_active, note)
+		SELECT $1, COALESCE(MAX(version), 0) + 1, $2, $3, $4, NULLIF($5, '')
+		FROM ai_prompt_templates WHERE analysis_type = $1
+		RETURNING id
+	`, string(analysisType), req.SystemPrompt, req.UserPrompt, activate, req.Note).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create prompt template: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit prompt template: %w", err)
+	}
+
+	templates, err := s.queryPromptTemplates(ctx, `WHERE id = $1`, id)
+	if err != nil || len(templates) == 0 {
+		return nil, fmt.Errorf("failed to load prompt template: %v", err)
+	}
+	return &templates[0], nil
+}
+
+// ActivatePromptTemplate makes one version active; version 0 reverts to the built-in prompts
+func (s *AIService) ActivatePromptTemplate(ctx context.Context, analysisType AnalysisType, version int) (*AIPromptTemplate, error) {
+	if !validAnalysisType(analysisType) {
+		return nil, fmt.Errorf("unknown analysis type %q", analysisType)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if version > 0 {
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM ai_prompt_templates WHERE analysis_type = $1 AND version = $2)`,
+			string(analysisType), version).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to query prompt template: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("prompt template version %d not found", version)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE ai_prompt_templates SET is_active = false WHERE analysis_type = $1 AND is_active`, string(analysisType)); err != nil {
+		return nil, fmt.Errorf("failed to deactivate prompt template: %w", err)
+	}
+	if version > 0 {
+		if _, err := tx.ExecContext(ctx, `UPDATE ai_prompt_templates SET is_active = true WHERE analysis_type = $1 AND version = $2`,
+			string(analysisType), version); err != nil {
+			return nil, fmt.Errorf("failed to activate prompt template: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit prompt template: %w", err)
+	}
+
+	template := s.activePromptTemplate(ctx, analysisType)
+	if template.Version == 0 {
+		template.IsActive = true
+	}
+	return template, nil
+}
+
+func (s *AIService) queryPromptTemplates(ctx context.Context, where string, args ...interface{}) ([]AIPromptTemplate, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, analysis_type, version, system_prompt, user_prompt, is_active, COALESCE(note, ''), created_at
+		FROM ai_prompt_templates
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query prompt templates: %w", err)
+	}
+	defer rows.Close()
+
+	templates := []AIPromptTemplate{}
+	for rows.Next() {
+		var t AIPromptTemplate
+		var createdAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.AnalysisType, &t.Version, &t.SystemPrompt, &t.UserPrompt, &t.IsActive, &t.Note, &createdAt); err != nil {
+			continue
+		}
+		if createdAt.Valid {
+			t.CreatedAt = &createdAt.Time
+		}
+		templates = append(templates, t)
+	}
+	return templates, nil
+}
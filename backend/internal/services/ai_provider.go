@@ -0,0 +1,190 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// AI providers selectable with AI_PROVIDER
+const (
+	AIProviderGemini = "gemini"
+	AIProviderOpenAI = "openai"
+	AIProviderClaude = "claude"
+	AIProviderOllama = "ollama"
+)
+
+// LLMProvider generates text with one hosted or local model
+type LLMProvider interface {
+	Name() string
+	Model() string
+	Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error)
+	// Stream passes text to onChunk as it is generated; an error from onChunk aborts generation
+	Stream(ctx context.Context, req LLMRequest, onChunk func(text string) error) (*LLMResponse, error)
+	// WithModel returns the same provider using another model
+	WithModel(model string) LLMProvider
+}
+
+// LLMRequest is a provider-neutral generation request
+type LLMRequest struct {
+	System      string
+	Messages    []LLMMessage
+	Temperature float64
+	MaxTokens   int
+}
+
+// LLMMessage is one conversation turn; Role is user or assistant
+type LLMMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// LLMResponse is the generated text with the provider's token counts
+type LLMResponse struct {
+	Content      string
+	InputTokens  int
+	OutputTokens int
+	Model        string // Model that produced the text
+	FallbackUsed bool   // The primary model failed and the fallback model answered
+}
+
+// newLLMProvider reads AI_PROVIDER (gemini, openai, claude or ollama; by default
+// the first provider with an API key: GEMINI_API_KEY, OPENAI_API_KEY, then
+// ANTHROPIC_API_KEY) and AI_MODEL, which overrides the provider's own model
+// variable (GEMINI_MODEL, OPENAI_MODEL, ANTHROPIC_MODEL, OLLAMA_MODEL). Ollama
+// needs no key and is reached at OLLAMA_URL (default http://localhost:11434).
+// Returns nil when no provider is usable.
+func newLLMProvider() (LLMProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("AI_PROVIDER")))
+	if name == "" {
+		switch {
+		case os.Getenv("GEMINI_API_KEY") != "":
+			name = AIProviderGemini
+		case os.Getenv("OPENAI_API_KEY") != "":
+			name = AIProviderOpenAI
+		case os.Getenv("ANTHROPIC_API_KEY") != "":
+			name = AIProviderClaude
+		default:
+			return nil, nil
+		}
+	}
+
+	model := func(envVar, fallback string) string {
+		if m := os.Getenv("AI_MODEL"); m != "" {
+			return m
+		}
+		if m := os.Getenv(envVar); m != "" {
+			return m
+		}
+		return fallback
+	}
+
+	switch name {
+	case AIProviderGemini:
+		key := os.Getenv("GEMINI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("AI_PROVIDER=gemini requires GEMINI_API_KEY")
+		}
+		return &geminiProvider{llmHTTP: newLLMHTTP(60 * time.Second), apiKey: key, model: model("GEMINI_MODEL", "gemini-2.0-flash-exp")}, nil
+	case AIProviderOpenAI:
+		key := os.Getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("AI_PROVIDER=openai requires OPENAI_API_KEY")
+		}
+		return &openAIProvider{llmHTTP: newLLMHTTP(60 * time.Second), apiKey: key, model: model("OPENAI_MODEL", "gpt-4o-mini")}, nil
+	case AIProviderClaude:
+		key := os.Getenv("ANTHROPIC_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("AI_PROVIDER=claude requires ANTHROPIC_API_KEY")
+		}
+		return &claudeProvider{llmHTTP: newLLMHTTP(60 * time.Second), apiKey: key, model: model("ANTHROPIC_MODEL", "claude-3-5-haiku-latest")}, nil
+	case AIProviderOllama:
+		baseURL := strings.TrimRight(os.Getenv("OLLAMA_URL"), "/")
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		// Local models on modest hardware are much slower than hosted APIs
+		return &ollamaProvider{llmHTTP: newLLMHTTP(5 * time.Minute), baseURL: baseURL, model: model("OLLAMA_MODEL", "llama3.1")}, nil
+	default:
+		return nil, fmt.Errorf("unknown AI_PROVIDER %q (expected gemini, openai, claude or ollama)", name)
+	}
+}
+
+// llmHTTP holds the HTTP clients shared by the provider implementations
+type llmHTTP struct {
+	client *http.Client
+	// streamClient has no overall timeout: streamed generations are bounded by
+	// the caller's context instead
+	streamClient *http.Client
+}
+
+func newLLMHTTP(timeout time.Duration) llmHTTP {
+	return llmHTTP{
+		client:       &http.Client{Timeout: timeout},
+		streamClient: &http.Client{},
+	}
+}
+
+// post sends a JSON request. Rate-limit and server error responses are
+// returned as an llmStatusError; otherwise the caller closes the response body.
+func (h llmHTTP) post(ctx context.Context, stream bool, apiURL string, payload interface{}, headers map[string]string) (*http.Response, error) {
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := h.client
+	if stream {
+		client = h.streamClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if retryableStatus(resp.StatusCode) {
+		defer resp.Body.Close()
+		return nil, statusError(resp)
+	}
+	return resp, nil
+}
+
+// scanLines calls fn for each line of a streamed response body
+func scanLines(r io.Reader, fn func(line string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := fn(scanner.Text()); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil
+}
+
+// scanSSE calls fn with the data of each server-sent event
+func scanSSE(r io.Reader, fn func(data string) error) error {
+	return scanLines(r, func(line string) error {
+		if !strings.HasPrefix(line, "data:") {
+			return nil
+		}
+		return fn(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+	})
+}
@@ -0,0 +1,630 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Gemini API types
+type geminiRequest struct {
+	Contents          []geminiContent         `json:"contents"`
+	SystemInstruction *geminiContent          `json:"systemInstruction,omitempty"`
+	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+type geminiContent struct {
+	Parts []geminiPart `json:"parts"`
+	Role  string       `json:"role,omitempty"`
+}
+
+type geminiPart struct {
+	Text string `json:"text"`
+}
+
+type geminiGenerationConfig struct {
+	Temperature      float64 `json:"temperature,omitempty"`
+	MaxOutputTokens  int     `json:"maxOutputTokens,omitempty"`
+	ResponseMimeType string  `json:"responseMimeType,omitempty"`
+}
+
+type geminiResponse struct {
+	Candidates []struct {
+		Content struct {
+			Parts []struct {
+				Text string `json:"text"`
+			} `json:"parts"`
+		} `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+	Error *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+		Status  string `json:"status"`
+	} `json:"error"`
+}
+
+// geminiProvider calls the Google Gemini generateContent API
+type geminiProvider struct {
+	llmHTTP
+	apiKey string
+	model  string
+}
+
+func (p *geminiProvider) Name() string  { return AIProviderGemini }
+func (p *geminiProvider) Model() string { return p.model }
+
+func (p *geminiProvider) WithModel(model string) LLMProvider {
+	c := *p
+	c.model = model
+	return &c
+}
+
+func (p *geminiProvider) request(req LLMRequest) geminiRequest {
+	body := geminiRequest{
+		GenerationConfig: &geminiGenerationConfig{
+			Temperature:     req.Temperature,
+			MaxOutputTokens: req.MaxTokens,
+		},
+	}
+	if req.System != "" {
+		body.SystemInstruction = &geminiContent{Parts: []geminiPart{{Text: req.System}}}
+	}
+	for _, m := range req.Messages {
+		role := "user"
+		if m.Role == "assistant" {
+			role = "model"
+		}
+		body.Contents = append(body.Contents, geminiContent{Role: role, Parts: []geminiPart{{Text: m.Content}}})
+	}
+	return body
+}
+
+func (p *geminiProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", p.model, p.apiKey)
+
+	resp, err := p.post(ctx, false, apiURL, p.request(req), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var geminiResp geminiResponse
+	if err := json.Unmarshal(body, &geminiResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if geminiResp.Error != nil {
+		return nil, fmt.Errorf("Gemini API error: %s (code: %d)", geminiResp.Error.Message, geminiResp.Error.Code)
+	}
+	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
+		return nil, fmt.Errorf("no response from Gemini")
+	}
+
+	return &LLMResponse{
+		Content:      geminiResp.Candidates[0].Content.Parts[0].Text,
+		InputTokens:  geminiResp.UsageMetadata.PromptTokenCount,
+		OutputTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
+	}, nil
+}
+
+func (p *geminiProvider) Stream(ctx context.Context, req LLMRequest, onChunk func(text string) error) (*LLMResponse, error) {
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:streamGenerateContent?alt=sse&key=%s", p.model, p.apiKey)
+
+	resp, err := p.post(ctx, true, apiURL, p.request(req), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	// Errors are returned as a plain JSON body rather than an event stream
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var geminiResp geminiResponse
+		if json.Unmarshal(body, &geminiResp) == nil && geminiResp.Error != nil {
+			return nil, fmt.Errorf("Gemini API error: %s (code: %d)", geminiResp.Error.Message, geminiResp.Error.Code)
+		}
+		return nil, fmt.Errorf("Gemini API returned status %d", resp.StatusCode)
+	}
+
+	result := &LLMResponse{}
+	var content strings.Builder
+	err = scanSSE(resp.Body, func(data string) error {
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("Gemini API error: %s (code: %d)", chunk.Error.Message, chunk.Error.Code)
+		}
+		// Usage is cumulative; the last event carries the totals
+		if chunk.UsageMetadata.PromptTokenCount > 0 {
+			result.InputTokens = chunk.UsageMetadata.PromptTokenCount
+		}
+		if chunk.UsageMetadata.CandidatesTokenCount > 0 {
+			result.OutputTokens = chunk.UsageMetadata.CandidatesTokenCount
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		for _, part := range chunk.Candidates[0].Content.Parts {
+			if part.Text == "" {
+				continue
+			}
+			content.WriteString(part.Text)
+			if err := onChunk(part.Text); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response from Gemini")
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// OpenAI chat completions types
+type openAIChatRequest struct {
+	Model         string              `json:"model"`
+	Messages      []openAIChatMessage `json:"messages"`
+	Temperature   float64             `json:"temperature"`
+	MaxTokens     int                 `json:"max_tokens,omitempty"`
+	Stream        bool                `json:"stream,omitempty"`
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+	ResponseFormat *struct {
+		Type string `json:"type"`
+	} `json:"response_format,omitempty"`
+}
+
+type openAIChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIChatResponse struct {
+	Choices []struct {
+		Message openAIChatMessage `json:"message"`
+		Delta   openAIChatMessage `json:"delta"` // Streamed responses
+	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+	} `json:"usage"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+	} `json:"error"`
+}
+
+// openAIProvider calls the OpenAI chat completions API
+type openAIProvider struct {
+	llmHTTP
+	apiKey string
+	model  string
+}
+
+func (p *openAIProvider) Name() string  { return AIProviderOpenAI }
+func (p *openAIProvider) Model() string { return p.model }
+
+func (p *openAIProvider) WithModel(model string) LLMProvider {
+	c := *p
+	c.model = model
+	return &c
+}
+
+func (p *openAIProvider) request(req LLMRequest, stream bool) openAIChatRequest {
+	body := openAIChatRequest{
+		Model:       p.model,
+		Temperature: req.Temperature,
+		MaxTokens:   req.MaxTokens,
+		Stream:      stream,
+	}
+	if stream {
+		body.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	if req.System != "" {
+		body.Messages = append(body.Messages, openAIChatMessage{Role: "system", Content: req.System})
+	}
+	for _, m := range req.Messages {
+		body.Messages = append(body.Messages, openAIChatMessage{Role: m.Role, Content: m.Content})
+	}
+	return body
+}
+
+func (p *openAIProvider) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
+
+func (p *openAIProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.post(ctx, false, "https://api.openai.com/v1/chat/completions", p.request(req, false), p.headers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call OpenAI API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var chatResp openAIChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if chatResp.Error != nil {
+		return nil, fmt.Errorf("OpenAI API error: %s (%s)", chatResp.Error.Message, chatResp.Error.Type)
+	}
+	if len(chatResp.Choices) == 0 {
+		return nil, fmt.Errorf("no response from OpenAI")
+	}
+
+	result := &LLMResponse{Content: chatResp.Choices[0].Message.Content}
+	if chatResp.Usage != nil {
+		result.InputTokens = chatResp.Usage.PromptTokens
+		result.OutputTokens = chatResp.Usage.CompletionTokens
+	}
+	return result, nil
+}
+
+func (p *openAIProvider) Stream(ctx context.Context, req LLMRequest, onChunk func(text string) error) (*LLMResponse, error) {
+	resp, err := p.post(ctx, true, "https://api.openai.com/v1/chat/completions", p.request(req, true), p.headers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call OpenAI API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var chatResp openAIChatResponse
+		if json.Unmarshal(body, &chatResp) == nil && chatResp.Error != nil {
+			return nil, fmt.Errorf("OpenAI API error: %s (%s)", chatResp.Error.Message, chatResp.Error.Type)
+		}
+		return nil, fmt.Errorf("OpenAI API returned status %d", resp.StatusCode)
+	}
+
+	result := &LLMResponse{}
+	var content strings.Builder
+	err = scanSSE(resp.Body, func(data string) error {
+		if data == "[DONE]" {
+			return nil
+		}
+		var chunk openAIChatResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("OpenAI API error: %s (%s)", chunk.Error.Message, chunk.Error.Type)
+		}
+		// Sent in a final chunk without choices
+		if chunk.Usage != nil {
+			result.InputTokens = chunk.Usage.PromptTokens
+			result.OutputTokens = chunk.Usage.CompletionTokens
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			return nil
+		}
+		content.WriteString(chunk.Choices[0].Delta.Content)
+		return onChunk(chunk.Choices[0].Delta.Content)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response from OpenAI")
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// Anthropic messages API types
+type claudeRequest struct {
+	Model       string       `json:"model"`
+	System      string       `json:"system,omitempty"`
+	Messages    []LLMMessage `json:"messages"`
+	MaxTokens   int          `json:"max_tokens"`
+	Temperature float64      `json:"temperature"`
+	Stream      bool         `json:"stream,omitempty"`
+}
+
+type claudeUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type claudeError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+type claudeResponse struct {
+	Content []struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"content"`
+	Usage claudeUsage  `json:"usage"`
+	Error *claudeError `json:"error"`
+}
+
+// claudeStreamEvent covers the message_start, content_block_delta,
+// message_delta and error events of a streamed response
+type claudeStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Usage claudeUsage `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage claudeUsage  `json:"usage"`
+	Error *claudeError `json:"error"`
+}
+
+// claudeProvider calls the Anthropic messages API
+type claudeProvider struct {
+	llmHTTP
+	apiKey string
+	model  string
+}
+
+func (p *claudeProvider) Name() string  { return AIProviderClaude }
+func (p *claudeProvider) Model() string { return p.model }
+
+func (p *claudeProvider) WithModel(model string) LLMProvider {
+	c := *p
+	c.model = model
+	return &c
+}
+
+func (p *claudeProvider) request(req LLMRequest, stream bool) claudeRequest {
+	maxTokens := req.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = 2048 // Required by the API
+	}
+	return claudeRequest{
+		Model:       p.model,
+		System:      req.System,
+		Messages:    req.Messages,
+		MaxTokens:   maxTokens,
+		Temperature: req.Temperature,
+		Stream:      stream,
+	}
+}
+
+func (p *claudeProvider) headers() map[string]string {
+	return map[string]string{
+		"x-api-key":         p.apiKey,
+		"anthropic-version": "2023-06-01",
+	}
+}
+
+func (p *claudeProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.post(ctx, false, "https://api.anthropic.com/v1/messages", p.request(req, false), p.headers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Claude API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var claudeResp claudeResponse
+	if err := json.Unmarshal(body, &claudeResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if claudeResp.Error != nil {
+		return nil, fmt.Errorf("Claude API error: %s (%s)", claudeResp.Error.Message, claudeResp.Error.Type)
+	}
+
+	var content strings.Builder
+	for _, block := range claudeResp.Content {
+		if block.Type == "text" {
+			content.WriteString(block.Text)
+		}
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response from Claude")
+	}
+
+	return &LLMResponse{
+		Content:      content.String(),
+		InputTokens:  claudeResp.Usage.InputTokens,
+		OutputTokens: claudeResp.Usage.OutputTokens,
+	}, nil
+}
+
+func (p *claudeProvider) Stream(ctx context.Context, req LLMRequest, onChunk func(text string) error) (*LLMResponse, error) {
+	resp, err := p.post(ctx, true, "https://api.anthropic.com/v1/messages", p.request(req, true), p.headers())
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Claude API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		var claudeResp claudeResponse
+		if json.Unmarshal(body, &claudeResp) == nil && claudeResp.Error != nil {
+			return nil, fmt.Errorf("Claude API error: %s (%s)", claudeResp.Error.Message, claudeResp.Error.Type)
+		}
+		return nil, fmt.Errorf("Claude API returned status %d", resp.StatusCode)
+	}
+
+	result := &LLMResponse{}
+	var content strings.Builder
+	err = scanSSE(resp.Body, func(data string) error {
+		var event claudeStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("failed to parse stream event: %w", err)
+		}
+		switch event.Type {
+		case "error":
+			if event.Error != nil {
+				return fmt.Errorf("Claude API error: %s (%s)", event.Error.Message, event.Error.Type)
+			}
+			return fmt.Errorf("Claude API error")
+		case "message_start":
+			result.InputTokens = event.Message.Usage.InputTokens
+		case "message_delta":
+			result.OutputTokens = event.Usage.OutputTokens
+		case "content_block_delta":
+			if event.Delta.Type != "text_delta" || event.Delta.Text == "" {
+				return nil
+			}
+			content.WriteString(event.Delta.Text)
+			return onChunk(event.Delta.Text)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response from Claude")
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
+
+// Ollama chat API types
+type ollamaChatRequest struct {
+	Model    string       `json:"model"`
+	Messages []LLMMessage `json:"messages"`
+	Stream   bool         `json:"stream"`
+	Options  struct {
+		Temperature float64 `json:"temperature"`
+		NumPredict  int     `json:"num_predict,omitempty"`
+	} `json:"options"`
+}
+
+type ollamaChatResponse struct {
+	Message         LLMMessage `json:"message"`
+	Done            bool       `json:"done"`
+	PromptEvalCount int        `json:"prompt_eval_count"`
+	EvalCount       int        `json:"eval_count"`
+	Error           string     `json:"error"`
+}
+
+// ollamaProvider calls a self-hosted Ollama server, so prompts and portfolio
+// data never leave the machine
+type ollamaProvider struct {
+	llmHTTP
+	baseURL string
+	model   string
+}
+
+func (p *ollamaProvider) Name() string  { return AIProviderOllama }
+func (p *ollamaProvider) Model() string { return p.model }
+
+func (p *ollamaProvider) WithModel(model string) LLMProvider {
+	c := *p
+	c.model = model
+	return &c
+}
+
+func (p *ollamaProvider) request(req LLMRequest, stream bool) ollamaChatRequest {
+	body := ollamaChatRequest{Model: p.model, Stream: stream}
+	body.Options.Temperature = req.Temperature
+	body.Options.NumPredict = req.MaxTokens
+	if req.System != "" {
+		body.Messages = append(body.Messages, LLMMessage{Role: "system", Content: req.System})
+	}
+	body.Messages = append(body.Messages, req.Messages...)
+	return body
+}
+
+func (p *ollamaProvider) Generate(ctx context.Context, req LLMRequest) (*LLMResponse, error) {
+	resp, err := p.post(ctx, false, p.baseURL+"/api/chat", p.request(req, false), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Ollama at %s: %w", p.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var chatResp ollamaChatResponse
+	if err := json.Unmarshal(body, &chatResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if chatResp.Error != "" {
+		return nil, fmt.Errorf("Ollama error: %s", chatResp.Error)
+	}
+	if chatResp.Message.Content == "" {
+		return nil, fmt.Errorf("no response from Ollama")
+	}
+
+	return &LLMResponse{
+		Content:      chatResp.Message.Content,
+		InputTokens:  chatResp.PromptEvalCount,
+		OutputTokens: chatResp.EvalCount,
+	}, nil
+}
+
+// Stream reads Ollama's newline-delimited JSON stream
+func (p *ollamaProvider) Stream(ctx context.Context, req LLMRequest, onChunk func(text string) error) (*LLMResponse, error) {
+	resp, err := p.post(ctx, true, p.baseURL+"/api/chat", p.request(req, true), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Ollama at %s: %w", p.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	result := &LLMResponse{}
+	var content strings.Builder
+	err = scanLines(resp.Body, func(line string) error {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("failed to parse stream line: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("Ollama error: %s", chunk.Error)
+		}
+		if chunk.Done {
+			result.InputTokens = chunk.PromptEvalCount
+			result.OutputTokens = chunk.EvalCount
+		}
+		if chunk.Message.Content == "" {
+			return nil
+		}
+		content.WriteString(chunk.Message.Content)
+		return onChunk(chunk.Message.Content)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if content.Len() == 0 {
+		return nil, fmt.Errorf("no response from Ollama")
+	}
+
+	result.Content = content.String()
+	return result, nil
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// aiJobRetention is how long finished analysis jobs can still be polled
+const aiJobRetention = 30 * time.Minute
+
+// aiLimiter bounds concurrent provider calls and each user's in-flight
+// requests. Waiting requests are served first in, first out.
+type aiLimiter struct {
+	maxConcurrent int
+	maxPerUser    int
+
+	mu      sync.Mutex
+	running int
+	waiting []*aiTicket
+	perUser map[uuid.UUID]int // Running plus waiting
+}
+
+// aiTicket is one request's place in the limiter
+type aiTicket struct {
+	limiter *aiLimiter
+	userID  uuid.UUID
+	ready   chan struct{} // Closed once the request may call the provider
+	once    sync.Once
+}
+
+// aiTicketKey marks a context whose request already holds a ticket
+type aiTicketKey struct{}
+
+// AIQueueStats describes the limiter's current load
+type AIQueueStats struct {
+	Running       int `json:"running"`
+	Queued        int `json:"queued"`
+	MaxConcurrent int `json:"max_concurrent"`
+	MaxPerUser    int `json:"max_per_user"`
+}
+
+// AIAnalysisJob is an analysis accepted while the queue was busy
+type AIAnalysisJob struct {
+	ID            string            `json:"id"`
+	Symbol        string            `json:"symbol"`
+	AnalysisType  AnalysisType      `json:"analysis_type"`
+	Language      AILanguage        `json:"language"`
+	Status        string            `json:"status"`                   // queued, running, completed, failed
+	QueuePosition int               `json:"queue_position,omitempty"` // 1 = next to run
+	Result        *AIAnalysisResult `json:"result,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+	CompletedAt   *time.Time        `json:"completed_at,omitempty"`
+
+	userID uuid.UUID
+	ticket *aiTicket
+}
+
+// newAILimiter reads AI_MAX_CONCURRENCY (default 4) and AI_MAX_PER_USER (default 3)
+func newAILimiter() *aiLimiter {
+	limiter := &aiLimiter{maxConcurrent: 4, maxPerUser: 3, perUser: make(map[uuid.UUID]int)}
+	if v, err := strconv.Atoi(os.Getenv("AI_MAX_CONCURRENCY")); err == nil && v > 0 {
+		limiter.maxConcurrent = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("AI_MAX_PER_USER")); err == nil && v > 0 {
+		limiter.maxPerUser = v
+	}
+	return limiter
+}
+
+// enter takes a slot for userID, or a place in the queue when all slots are
+// busy. Background work (uuid.Nil) is not subject to the per-user limit.
+func (l *aiLimiter) enter(userID uuid.UUID) (*aiTicket, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if userID != uuid.Nil && l.perUser[userID] >= l.maxPerUser {
+		return nil, fmt.Errorf("too many AI requests in progress (limit %d per user)", l.maxPerUser)
+	}
+	l.perUser[userID]++
+
+	t := &aiTicket{limiter: l, userID: userID, ready: make(chan struct{})}
+	if l.running < l.maxConcurrent && len(l.waiting) == 0 {
+		l.running++
+		close(t.ready)
+	} else {
+		l.waiting = append(l.waiting, t)
+	}
+	return t, nil
+}
+
+// wait blocks until the ticket's turn; on cancellation the ticket is released
+func (t *aiTicket) wait(ctx context.Context) error {
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		t.release()
+		return ctx.Err()
+	}
+}
+
+// release frees the ticket's slot (or queue place) and admits the next waiter
+func (t *aiTicket) release() {
+	t.once.Do(func() {
+		l := t.limiter
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		if l.perUser[t.userID]--; l.perUser[t.userID] <= 0 {
+			delete(l.perUser, t.userID)
+		}
+		for i, w := range l.waiting {
+			if w == t {
+				l.waiting = append(l.waiting[:i], l.waiting[i+1:]...)
+				return
+			}
+		}
+		if len(l.waiting) > 0 {
+			next := l.waiting[0]
+			l.waiting = l.waiting[1:]
+			close(next.ready)
+			return
+		}
+		l.running--
+	})
+}
+
+// position returns the ticket's 1-based place in the queue, or 0 once it runs
+func (l *aiLimiter) position(t *aiTicket) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i, w := range l.waiting {
+		if w == t {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func (l *aiLimiter) stats() AIQueueStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return AIQueueStats{
+		Running:       l.running,
+		Queued:        len(l.waiting),
+		MaxConcurrent: l.maxConcurrent,
+		MaxPerUser:    l.maxPerUser,
+	}
+}
+
+// busy reports whether a new request would have to wait
+func (l *aiLimiter) busy() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.running >= l.maxConcurrent || len(l.waiting) > 0
+}
+
+// acquire waits for a provider slot unless ctx already holds one. The returned
+// function releases the slot.
+func (s *AIService) acquire(ctx context.Context, userID uuid.UUID) (func(), error) {
+	if _, held := ctx.Value(aiTicketKey{}).(*aiTicket); held {
+		return func() {}, nil
+	}
+	ticket, err := s.limiter.enter(userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := ticket.wait(ctx); err != nil {
+		return nil, err
+	}
+	return ticket.release, nil
+}
+
+// QueueStats returns the current provider queue load
+func (s *AIService) QueueStats() AIQueueStats {
+	return s.limiter.stats()
+}
+
+// SubmitAnalysis returns the analysis directly when it is cached or a provider
+// slot is free. Otherwise the analysis is queued as a job to poll with
+// GetAnalysisJob, and the job is returned instead.
+func (s *AIService) SubmitAnalysis(ctx context.Context, userID uuid.UUID, symbol string, analysisType AnalysisType, language AILanguage) (*AIAnalysisResult, *AIAnalysisJob, error) {
+	if !s.IsConfigured() {
+		return nil, nil, s.notConfiguredError()
+	}
+
+	today := time.Now().Format("2006-01-02")
+	template := s.activePromptTemplate(ctx, analysisType)
+	if cached, err := s.getCachedAnalysis(ctx, symbol, analysisType, language, today); err == nil && cached.PromptVersion == template.Version {
+		cached.Cached = true
+		return cached, nil, nil
+	}
+
+	if !s.limiter.busy() {
+		result, err := s.GetAnalysis(ctx, userID, symbol, analysisType, language)
+		return result, nil, err
+	}
+
+	ticket, err := s.limiter.enter(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	job := &AIAnalysisJob{
+		ID:           uuid.New().String(),
+		Symbol:       symbol,
+		AnalysisType: analysisType,
+		Language:     language,
+		Status:       "queued",
+		CreatedAt:    time.Now(),
+		userID:       userID,
+		ticket:       ticket,
+	}
+
+	s.jobsMu.Lock()
+	s.pruneJobsLocked()
+	s.jobs[job.ID] = job
+	s.jobsMu.Unlock()
+
+	go s.runAnalysisJob(job)
+
+	snapshot, _ := s.GetAnalysisJob(job.ID, userID)
+	return nil, snapshot, nil
+}
+
+func (s *AIService) runAnalysisJob(job *AIAnalysisJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	defer job.ticket.release()
+
+	err := job.ticket.wait(ctx)
+	var result *AIAnalysisResult
+	if err == nil {
+		s.setJobStatus(job, "running", nil, nil)
+		result, err = s.GetAnalysis(context.WithValue(ctx, aiTicketKey{}, job.ticket), job.userID, job.Symbol, job.AnalysisType, job.Language)
+	}
+	if err != nil {
+		log.Printf("AI analysis job %s (%s %s) failed: %v", job.ID, job.Symbol, job.AnalysisType, err)
+		s.setJobStatus(job, "failed", nil, err)
+		return
+	}
+	s.setJobStatus(job, "completed", result, nil)
+}
+
+func (s *AIService) setJobStatus(job *AIAnalysisJob, status string, result *AIAnalysisResult, err error) {
+	s.jobsMu.Lock()
+	defer s.jobsMu.Unlock()
+	job.Status = status
+	job.Result = result
+	if err != nil {
+		job.Error = err.Error()
+	}
+	if status == "completed" || status == "failed" {
+		now := time.Now()
+		job.CompletedAt = &now
+	}
+}
+
+// GetAnalysisJob returns a queued analysis job of userID with its current
+// queue position
+func (s *AIService) GetAnalysisJob(id string, userID uuid.UUID) (*AIAnalysisJob, error) {
+	s.jobsMu.Lock()
+	job, ok := s.jobs[id]
+	var snapshot AIAnalysisJob
+	if ok {
+		snapshot = *job
+	}
+	s.jobsMu.Unlock()
+	if !ok || snapshot.userID != userID {
+		return nil, fmt.Errorf("analysis job not found")
+	}
+
+	if snapshot.Status == "queued" {
+		snapshot.QueuePosition = s.limiter.position(snapshot.ticket)
+	}
+	return &snapshot, nil
+}
+
+// pruneJobsLocked drops finished jobs and batches past their retention;
+// jobsMu must be held
+func (s *AIService) pruneJobsLocked() {
+	for id, job := range s.jobs {
+		if job.CompletedAt != nil && time.Since(*job.CompletedAt) > aiJobRetention {
+			delete(s.jobs, id)
+		}
+	}
+	for id, batch := range s.batches {
+		if batch.CompletedAt != nil && time.Since(*batch.CompletedAt) > aiJobRetention {
+			delete(s.batches, id)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Retrieval source types
+const (
+	RetrievalSourceAnalysis = "analysis"
+	RetrievalSourceFiling   = "filing"
+	RetrievalSourceRevenue  = "revenue"
+)
+
+// retrievalChunkRunes is the target chunk length; chunks overlap by a sentence
+// or so, which keeps figures together with what they describe
+const (
+	retrievalChunkRunes   = 600
+	retrievalChunkOverlap = 80
+)
+
+// retrievalDoc is a source document to chunk and embed
+type retrievalDoc struct {
+	sourceType  string
+	sourceID    string
+	symbol      string
+	text        string
+	publishedAt time.Time
+}
+
+// RetrievedChunk is a passage of an earlier analysis, filing or revenue report
+// retrieved for a prompt
+type RetrievedChunk struct {
+	SourceType  string    `json:"source_type"` // analysis, filing or revenue
+	SourceID    string    `json:"source_id"`
+	Symbol      string    `json:"symbol"`
+	Content     string    `json:"content"`
+	PublishedAt time.Time `json:"published_at"`
+	Similarity  float64   `json:"similarity"`
+}
+
+// retrievalReady reports whether the retrieval store exists (migration 034 with pgvector)
+func (s *EmbeddingService) retrievalReady(ctx context.Context) bool {
+	if !s.Enabled(ctx) {
+		return false
+	}
+	var ready bool
+	s.db.QueryRowContext(ctx, `SELECT to_regclass('ai_retrieval_chunks') IS NOT NULL`).Scan(&ready)
+	return ready
+}
+
+// IndexRetrievalDocs chunks and embeds up to batch documents of each source
+// type that are new, changed or embedded with another model. Returns the
+// number of documents indexed.
+func (s *EmbeddingService) IndexRetrievalDocs(ctx context.Context, batch int) (int, error) {
+	if !s.retrievalReady(ctx) {
+		return 0, nil
+	}
+	if batch <= 0 {
+		batch = 64
+	}
+	model := s.provider.Model()
+
+	indexed := 0
+	for _, sourceType := range []string{RetrievalSourceRevenue, RetrievalSourceFiling, RetrievalSourceAnalysis} {
+		docs, err := s.pendingRetrievalDocs(ctx, sourceType, model, batch)
+		if err != nil {
+			return indexed, err
+		}
+		for _, doc := range docs {
+			if err := s.indexRetrievalDoc(ctx, doc, model); err != nil {
+				return indexed, err
+			}
+			indexed++
+		}
+	}
+	return indexed, nil
+}
+
+// pendingRetrievalDocs lists documents without up-to-date chunks from model, newest first
+func (s *EmbeddingService) pendingRetrievalDocs(ctx context.Context, sourceType, model string, limit int) ([]retrievalDoc, error) {
+	var query string
+	switch sourceType {
+	case RetrievalSourceAnalysis:
+		query = `
+			SELECT c.id::text, c.symbol, c.analysis_type || E'\n' || c.content, c.created_at
+			FROM ai_analysis_cache c
+			WHERE c.created_at >= NOW() - INTERVAL '400 days'
+			  AND NOT EXISTS (SELECT 1 FROM ai_retrieval_chunks r
+			                  WHERE r.source_type = 'analysis' AND r.source_id = c.id::text
+			                    AND r.model = $1 AND r.created_at >= c.created_at)
+			ORDER BY c.created_at DESC
+			LIMIT $2
+		`
+	case RetrievalSourceFiling:
+		query = `
+			SELECT n.id::text, n.symbol, n.title || E'\n' || COALESCE(NULLIF(n.content, ''), n.summary, ''), n.published_at
+			FROM stock_news n
+			WHERE n.category = $3 AND n.published_at >= NOW() - INTERVAL '400 days'
+			  AND NOT EXISTS (SELECT 1 FROM ai_retrieval_chunks r
+			                  WHERE r.source_type = 'filing' AND r.source_id = n.id::text AND r.model = $1)
+			ORDER BY n.published_at DESC
+			LIMIT $2
+		`
+	case RetrievalSourceRevenue:
+		return s.pendingRevenueDocs(ctx, model, limit)
+	default:
+		return nil, fmt.Errorf("unknown retrieval source %q", sourceType)
+	}
+
+	args := []interface{}{model, limit}
+	if sourceType == RetrievalSourceFiling {
+		args = append(args, MaterialAnnouncementCategory)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending %s documents: %w", sourceType, err)
+	}
+	defer rows.Close()
+
+	docs := []retrievalDoc{}
+	for rows.Next() {
+		doc := retrievalDoc{sourceType: sourceType}
+		if err := rows.Scan(&doc.sourceID, &doc.symbol, &doc.text, &doc.publishedAt); err == nil {
+			docs = append(docs, doc)
+		}
+	}
+	return docs, nil
+}
+
+// pendingRevenueDocs renders new or revised revenue reports as documents
+func (s *EmbeddingService) pendingRevenueDocs(ctx context.Context, model string, limit int) ([]retrievalDoc, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT m.symbol, TO_CHAR(m.revenue_month, 'YYYY-MM'), m.revenue, COALESCE(m.cumulative_revenue, 0),
+		       m.mom_pct::float8, m.yoy_pct::float8, m.cumulative_yoy_pct::float8, COALESCE(m.note, ''),
+		       COALESCE(t.name, m.symbol), m.updated_at
+		FROM stock_monthly_revenue m
+		LEFT JOIN taiwan_stocks t ON t.symbol = m.symbol
+		WHERE m.revenue_month >= CURRENT_DATE - INTERVAL '400 days'
+		  AND NOT EXISTS (SELECT 1 FROM ai_retrieval_chunks r
+		                  WHERE r.source_type = 'revenue' AND r.source_id = m.symbol || ':' || TO_CHAR(m.revenue_month, 'YYYY-MM')
+		                    AND r.model = $1 AND r.created_at >= m.updated_at)
+		ORDER BY m.revenue_month DESC, m.symbol
+		LIMIT $2
+	`, model, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending revenue documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []retrievalDoc{}
+	for rows.Next() {
+		var r MonthlyRevenue
+		var mom, yoy, cumYoY sql.NullFloat64
+		var name string
+		if err := rows.Scan(&r.Symbol, &r.Month, &r.Revenue, &r.CumulativeRevenue, &mom, &yoy, &cumYoY, &r.Note, &name, &r.UpdatedAt); err != nil {
+			continue
+		}
+		r.MoMPercent, r.YoYPercent, r.CumulativeYoY = mom.Float64, yoy.Float64, cumYoY.Float64
+
+		// Reports are published by the 10th of the following month
+		month, _ := time.Parse("2006-01", r.Month)
+		docs = append(docs, retrievalDoc{
+			sourceType:  RetrievalSourceRevenue,
+			sourceID:    r.Symbol + ":" + r.Month,
+			symbol:      r.Symbol,
+			text:        fmt.Sprintf("%s %s %s", r.Symbol, name, formatRevenueReport(r)),
+			publishedAt: month.AddDate(0, 1, 9),
+		})
+	}
+	return docs, nil
+}
+
+// indexRetrievalDoc replaces a document's chunks
+func (s *EmbeddingService) indexRetrievalDoc(ctx context.Context, doc retrievalDoc, model string) error {
+	chunks := chunkText(doc.text, retrievalChunkRunes, retrievalChunkOverlap)
+	if len(chunks) == 0 {
+		return nil
+	}
+	vectors, err := s.provider.Embed(ctx, chunks, false)
+	if err != nil {
+		return fmt.Errorf("failed to embed: %w", err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM ai_retrieval_chunks WHERE source_type = $1 AND source_id = $2`,
+		doc.sourceType, doc.sourceID); err != nil {
+		return fmt.Errorf("failed to replace chunks: %w", err)
+	}
+	for i, chunk := range chunks {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO ai_retrieval_chunks (source_type, source_id, symbol, chunk_index, content, published_at, model, embedding)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8::vector)
+		`, doc.sourceType, doc.sourceID, doc.symbol, i, chunk, doc.publishedAt, model, vectorLiteral(vectors[i]))
+		if err != nil {
+			return fmt.Errorf("failed to store chunk: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// chunkText splits text into chunks of about size runes along paragraph and
+// sentence boundaries; each chunk repeats up to overlap runes of the previous one
+func chunkText(text string, size, overlap int) []string {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	if utf8.RuneCountInString(text) <= size {
+		return []string{text}
+	}
+
+	// Sentences, keeping their terminators
+	var sentences []string
+	var current strings.Builder
+	for _, r := range text {
+		current.WriteRune(r)
+		if r == '\n' || r == '。' || r == '；' || r == '！' || r == '？' {
+			sentences = append(sentences, current.String())
+			current.Reset()
+		}
+	}
+	if current.Len() > 0 {
+		sentences = append(sentences, current.String())
+	}
+
+	var chunks []string
+	var chunk []rune
+	for _, sentence := range sentences {
+		runes := []rune(sentence)
+		if len(chunk) > 0 && len(chunk)+len(runes) > size {
+			chunks = append(chunks, strings.TrimSpace(string(chunk)))
+			tail := overlap
+			if tail > len(chunk) {
+				tail = len(chunk)
+			}
+			chunk = append([]rune{}, chunk[len(chunk)-tail:]...)
+		}
+		// Sentences longer than a chunk are cut
+		for len(runes) > size {
+			chunks = append(chunks, strings.TrimSpace(string(append(chunk, runes[:size-len(chunk)]...))))
+			runes = runes[size-len(chunk):]
+			chunk = nil
+		}
+		chunk = append(chunk, runes...)
+	}
+	if s := strings.TrimSpace(string(chunk)); s != "" {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
+
+// Retrieve returns the passages of a symbol's earlier analyses, filings and
+// revenue reports most relevant to the query. Filings from the last 14 days
+// and analyses from the last day are left out: the prompt already carries them.
+func (s *EmbeddingService) Retrieve(ctx context.Context, symbol, query string, days, limit int) ([]RetrievedChunk, error) {
+	if !s.retrievalReady(ctx) {
+		return nil, fmt.Errorf("semantic search disabled: retrieval store missing (install pgvector and run migration 034)")
+	}
+	if days <= 0 {
+		days = 400
+	}
+	if limit <= 0 || limit > 20 {
+		limit = 6
+	}
+
+	vector, err := s.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT source_type, source_id, symbol, content, published_at, 1 - (embedding <=> $1::vector)
+		FROM ai_retrieval_chunks
+		WHERE model = $2 AND symbol = $3
+		  AND published_at >= NOW() - make_interval(days => $4)
+		  AND NOT (source_type = 'filing' AND published_at >= NOW() - INTERVAL '14 days')
+		  AND NOT (source_type = 'analysis' AND published_at >= NOW() - INTERVAL '1 day')
+		ORDER BY embedding <=> $1::vector
+		LIMIT $5
+	`, vector, s.provider.Model(), symbol, days, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve chunks: %w", err)
+	}
+	defer rows.Close()
+
+	chunks := []RetrievedChunk{}
+	for rows.Next() {
+		var c RetrievedChunk
+		if err := rows.Scan(&c.SourceType, &c.SourceID, &c.Symbol, &c.Content, &c.PublishedAt, &c.Similarity); err != nil {
+			continue
+		}
+		c.Similarity = roundTo(c.Similarity, 4)
+		chunks = append(chunks, c)
+	}
+	return chunks, nil
+}
+
+// retrieveForPrompt is Retrieve for prompt building: failures only disable the section
+func (s *EmbeddingService) retrieveForPrompt(ctx context.Context, symbol, query string) []RetrievedChunk {
+	if !s.retrievalReady(ctx) {
+		return nil
+	}
+	chunks, err := s.Retrieve(ctx, symbol, query, 400, 6)
+	if err != nil {
+		log.Printf("Warning: retrieval for %s failed: %v", symbol, err)
+		return nil
+	}
+	return chunks
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// llmStatusError is a rate-limit or server error response from a provider
+type llmStatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *llmStatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("API returned status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Message)
+}
+
+// retryableStatus reports whether a response status is worth retrying
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusRequestTimeout || code >= 500
+}
+
+// statusError reads the body of a retryable error response into an
+// llmStatusError. The providers' error bodies carry {"error": {"message"}}
+// (Gemini, OpenAI, Claude) or {"error": "..."} (Ollama).
+func statusError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	message := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &parsed) == nil && len(parsed.Error) > 0 {
+		var detail struct {
+			Message string `json:"message"`
+		}
+		var text string
+		if json.Unmarshal(parsed.Error, &detail) == nil && detail.Message != "" {
+			message = detail.Message
+		} else if json.Unmarshal(parsed.Error, &text) == nil && text != "" {
+			message = text
+		}
+	}
+	return &llmStatusError{StatusCode: resp.StatusCode, Message: truncateRunes(message, 300)}
+}
+
+// isRetryableLLMError reports whether a failed call may succeed when repeated:
+// rate limits, server errors and network failures, but not cancellation
+func isRetryableLLMError(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	var statusErr *llmStatusError
+	if errors.As(err, &statusErr) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// llmRetryPolicy controls retries of one model before falling back
+type llmRetryPolicy struct {
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration
+}
+
+// newLLMRetryPolicy reads AI_MAX_RETRIES (default 2)
+func newLLMRetryPolicy() llmRetryPolicy {
+	policy := llmRetryPolicy{maxRetries: 2, baseDelay: time.Second, maxDelay: 10 * time.Second}
+	if v, err := strconv.Atoi(os.Getenv("AI_MAX_RETRIES")); err == nil && v >= 0 {
+		policy.maxRetries = v
+	}
+	return policy
+}
+
+// delay is the exponential backoff before retry n (0-based), with up to 25% jitter
+func (p llmRetryPolicy) delay(n int) time.Duration {
+	d := p.baseDelay << uint(n)
+	if d > p.maxDelay || d <= 0 {
+		d = p.maxDelay
+	}
+	return d + time.Duration(rand.Int63n(int64(d)/4+1))
+}
+
+// fallbackProvider reads AI_FALLBACK_MODEL, the model used once the primary
+// model keeps failing. Gemini falls back to gemini-1.5-flash by default;
+// "none" disables the fallback.
+func fallbackProvider(primary LLMProvider) LLMProvider {
+	if primary == nil {
+		return nil
+	}
+	model := strings.TrimSpace(os.Getenv("AI_FALLBACK_MODEL"))
+	if model == "" && primary.Name() == AIProviderGemini {
+		model = "gemini-1.5-flash"
+	}
+	if model == "" || model == "none" || model == primary.Model() {
+		return nil
+	}
+	return primary.WithModel(model)
+}
+
+// callProvider runs call against the primary model, retrying retryable errors
+// with backoff, then against the fallback model. Each model's outcome is
+// recorded as usage. canRetry reports whether the call may still be repeated
+// (a stream that has already produced text cannot).
+func (s *AIService) callProvider(ctx context.Context, userID uuid.UUID, feature string, call func(p LLMProvider) (*LLMResponse, error), canRetry func() bool) (*LLMResponse, error) {
+	providers := []LLMProvider{s.provider}
+	if s.fallback != nil {
+		providers = append(providers, s.fallback)
+	}
+
+	var lastErr error
+	for i, p := range providers {
+		if i > 0 {
+			log.Printf("AI %s: falling back from %s to %s after: %v", feature, s.provider.Model(), p.Model(), lastErr)
+		}
+
+		resp, err := call(p)
+		for n := 0; n < s.retry.maxRetries && isRetryableLLMError(ctx, err) && canRetry(); n++ {
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.retry.delay(n)):
+			}
+			resp, err = call(p)
+		}
+		s.recordUsage(userID, feature, p.Model(), resp, err)
+
+		if err == nil {
+			resp.Model = p.Model()
+			resp.FallbackUsed = i > 0
+			return resp, nil
+		}
+		lastErr = err
+		if !isRetryableLLMError(ctx, err) || !canRetry() {
+			break
+		}
+	}
+	return nil, lastErr
+}
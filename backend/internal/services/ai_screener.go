@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+// aiScreenerSystemPrompt describes the ScreenerCriteria schema the model must
+// translate a screening request into
+const aiScreenerSystemPrompt = `你是台股選股條件轉換器，負責把使用者的中文或英文選股描述轉換成 JSON 篩選條件。
+只輸出一個 JSON 物件（可用 ` + "```json" + ` 區塊包住），不要輸出其他文字，格式如下：
+{"criteria": {...}, "summary": "", "unsupported": []}
+
+criteria 只能使用下列欄位，未提及的條件請省略：
+- industry (字串): 產業，必須完全等於提供的產業清單中的一項
+- industries (字串陣列): 多個產業擇一符合時使用，每一項都必須在產業清單中
+- market ("TSE"|"OTC"): 上市或上櫃
+- exclude_etfs, exclude_warrants (布林): 排除 ETF/ETN、權證
+- security_type ("stock"|"etf"|"etn"|"warrant"|"tdr"|"preferred"): 只篩選此類證券，例如「ETF」用 "etf"
+- exclude_full_cash_delivery, exclude_disposition (布林): 排除全額交割股、處置股
+- min_price, max_price (數字): 收盤價區間
+- min_volume (整數): 最低成交量 (股)
+- min_volume_ratio (數字): 成交量相對20日均量倍數，「量能放大」「爆量」約為 1.5 到 2
+- min_turnover, min_avg_turnover (數字): 最近一日成交金額、20日平均成交金額下限 (元)，「流動性佳」約 min_avg_turnover 50000000
+- above_ma20 (布林): 站上月線 (20日均線)
+- above_ma60 (布林): 站上季線 (60日均線)
+- rsi_min, rsi_max (數字, 0-100): RSI(14) 區間，超賣約 rsi_max 30，超買約 rsi_min 70
+- golden_cross (布林): 5日均線在20日均線之上
+- bb_squeeze (布林), bb_squeeze_percentile (數字, 0-100): 布林通道壓縮
+- macd_turn_positive (布林): MACD 柱狀體由負轉正
+- kdj_golden_cross (布林): KD 黃金交叉 (K 值向上穿越 D 值)
+- touch_lower_bb (布林): 股價觸及布林通道下軌
+- limit_up, limit_down (布林): 收盤漲停/跌停；limit_intraday (布林) 搭配使用時盤中觸及即可
+- exclude_limit_up (布林): 排除收盤漲停股 (避免追高)
+- foreign_buy_days, trust_buy_days (整數): 外資/投信連續買超至少幾日，「外資連買」未指明天數約 3
+- min_foreign_net_lots, min_trust_net_lots (整數): 外資/投信最近一日買超至少幾張
+- min_change_percent, max_change_percent (數字): 當日漲跌幅 (%)
+- near_52_week_high, near_52_week_low (布林): 距52週高/低點 3% 以內
+- outperform_taiex (布林): 報酬強於加權指數；rs_days (20|60, 預設 20) 期間，rs_margin (數字, 百分點) 至少超越幅度
+- positive_sentiment (布林): 近7日新聞情緒正面
+- ai_stance ("bullish"|"neutral"|"bearish"), min_ai_confidence (0-1): 近期 AI 分析立場
+- expression (字串): 其他欄位無法表達的技術條件，語法如 close > MA(20) AND RSI(14) < 40，
+  可用 open/high/low/close/volume、MA(n)、EMA(n)、RSI(n)、MACD()、MACD_SIGNAL()、MACD_HIST()、
+  BB_UPPER(n, k)、BB_LOWER(n, k)、KDJ_K(n)、KDJ_D(n)、VOL_MA(n)、HIGHEST(n)、LOWEST(n)、REF(field, n)
+  及 + - * / > >= < <= AND OR NOT，結果必須是條件
+- sort_by ("score"|"volume_ratio"|"turnover"|"change_percent"|"sentiment_score"|"rsi"|"bb_bandwidth_pct"|"beta"|"relative_strength"|"foreign_streak"|"trust_streak"), sort_desc (布林)
+- limit (整數, 1-100)
+
+summary 以一句繁體中文說明採用的條件；無法以上述欄位表達的條件 (例如本益比、營收) 請以繁體中文列在 unsupported，不要自行猜測。`
+
+// Bounds for natural-language screening
+const (
+	aiScreenerMaxQueryRunes = 300
+	aiScreenerMaxLimit      = 100
+)
+
+// aiScreenerSortFields are the sort_by values ScreenerService understands
+var aiScreenerSortFields = map[string]bool{
+	"": true, "score": true, "volume_ratio": true, "turnover": true, "change_percent": true,
+	"sentiment_score": true, "rsi": true, "bb_bandwidth_pct": true, "beta": true,
+	"relative_strength": true, "foreign_streak": true, "trust_streak": true,
+}
+
+// AIScreenerQuery is a natural-language screening request translated into criteria
+type AIScreenerQuery struct {
+	Query        string            `json:"query"`
+	Criteria     *ScreenerCriteria `json:"criteria"`
+	Summary      string            `json:"summary"`
+	Unsupported  []string          `json:"unsupported"` // Conditions the criteria cannot express
+	Provider     string            `json:"provider"`
+	Model        string            `json:"model"`
+	InputTokens  int               `json:"input_tokens"`
+	OutputTokens int               `json:"output_tokens"`
+	FallbackUsed bool              `json:"fallback_used"`
+}
+
+// ParseScreenerQuery translates a screening request such as
+// "找出量能放大且站上月線的半導體股" into validated ScreenerCriteria.
+// industries lists the valid industry values; the call counts towards userID's usage.
+func (s *AIService) ParseScreenerQuery(ctx context.Context, userID uuid.UUID, query string, industries []string) (*AIScreenerQuery, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if utf8.RuneCountInString(query) > aiScreenerMaxQueryRunes {
+		return nil, fmt.Errorf("query must be at most %d characters", aiScreenerMaxQueryRunes)
+	}
+
+	var prompt strings.Builder
+	if len(industries) > 0 {
+		prompt.WriteString("產業清單: " + strings.Join(industries, "、") + "\n\n")
+	}
+	prompt.WriteString("選股描述: " + query)
+
+	resp, err := s.generate(ctx, userID, "screener_nl", LLMRequest{
+		System:      aiScreenerSystemPrompt,
+		Messages:    []LLMMessage{{Role: "user", Content: prompt.String()}},
+		Temperature: 0.1,
+		MaxTokens:   1024,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate query: %w", err)
+	}
+
+	result, err := parseScreenerQueryOutput(resp.Content, industries)
+	if err != nil {
+		return nil, err
+	}
+	result.Query = query
+	result.Provider = s.provider.Name()
+	result.Model = resp.Model
+	result.InputTokens = resp.InputTokens
+	result.OutputTokens = resp.OutputTokens
+	result.FallbackUsed = resp.FallbackUsed
+	return result, nil
+}
+
+// parseScreenerQueryOutput decodes the model's JSON strictly, so misspelled or
+// invented fields are rejected rather than silently ignored, then validates it
+func parseScreenerQueryOutput(content string, industries []string) (*AIScreenerQuery, error) {
+	raw := strings.TrimSpace(content)
+	if matches := aiStructuredBlockPattern.FindStringSubmatch(raw); matches != nil {
+		raw = matches[1]
+	} else if start, end := strings.Index(raw, "{"), strings.LastIndex(raw, "}"); start >= 0 && end > start {
+		raw = raw[start : end+1]
+	}
+
+	var output struct {
+		Criteria    json.RawMessage `json:"criteria"`
+		Summary     string          `json:"summary"`
+		Unsupported []string        `json:"unsupported"`
+	}
+	decoder := json.NewDecoder(strings.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&output); err != nil {
+		return nil, fmt.Errorf("invalid criteria from model: %v", err)
+	}
+	if len(output.Criteria) == 0 {
+		return nil, fmt.Errorf("invalid criteria from model: criteria is missing")
+	}
+
+	criteria := &ScreenerCriteria{}
+	decoder = json.NewDecoder(bytes.NewReader(output.Criteria))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(criteria); err != nil {
+		return nil, fmt.Errorf("invalid criteria from model: %v", err)
+	}
+	if err := validateScreenerCriteria(criteria, industries); err != nil {
+		return nil, fmt.Errorf("invalid criteria from model: %v", err)
+	}
+	criteria.ExcludeInactive = true
+
+	result := &AIScreenerQuery{
+		Criteria:    criteria,
+		Summary:     truncateRunes(strings.TrimSpace(output.Summary), 300),
+		Unsupported: []string{},
+	}
+	for _, u := range output.Unsupported {
+		if u = strings.TrimSpace(u); u != "" && len(result.Unsupported) < 10 {
+			result.Unsupported = append(result.Unsupported, truncateRunes(u, 100))
+		}
+	}
+	return result, nil
+}
+
+// validateScreenerCriteria checks generated criteria before they are executed
+func validateScreenerCriteria(c *ScreenerCriteria, industries []string) error {
+	if err := c.normalizeUniverse(); err != nil {
+		return err
+	}
+	if len(industries) > 0 {
+		for _, industry := range c.industryList() {
+			known := false
+			for _, valid := range industries {
+				if valid == industry {
+					known = true
+					break
+				}
+			}
+			if !known {
+				return fmt.Errorf("unknown industry %q", industry)
+			}
+		}
+	}
+
+	if c.MinPrice < 0 || c.MaxPrice < 0 || c.MinVolume < 0 || c.MinVolumeRatio < 0 || c.MinTurnover < 0 || c.MinAvgTurnover < 0 {
+		return fmt.Errorf("price and volume criteria must not be negative")
+	}
+	if c.MaxPrice > 0 && c.MinPrice > c.MaxPrice {
+		return fmt.Errorf("min_price must not exceed max_price")
+	}
+	if c.RSIMin < 0 || c.RSIMin > 100 || c.RSIMax < 0 || c.RSIMax > 100 {
+		return fmt.Errorf("rsi_min and rsi_max must be between 0 and 100")
+	}
+	if c.RSIMax > 0 && c.RSIMin > c.RSIMax {
+		return fmt.Errorf("rsi_min must not exceed rsi_max")
+	}
+	if c.BBSqueezePercentile < 0 || c.BBSqueezePercentile > 100 {
+		return fmt.Errorf("bb_squeeze_percentile must be between 0 and 100")
+	}
+	if c.MinChangePercent < -100 || c.MinChangePercent > 100 || c.MaxChangePercent < -100 || c.MaxChangePercent > 100 {
+		return fmt.Errorf("change percent criteria must be between -100 and 100")
+	}
+	if c.MinChangePercent != 0 && c.MaxChangePercent != 0 && c.MinChangePercent > c.MaxChangePercent {
+		return fmt.Errorf("min_change_percent must not exceed max_change_percent")
+	}
+	if c.RSDays != 0 && c.RSDays != 20 && c.RSDays != 60 {
+		return fmt.Errorf("rs_days must be 20 or 60")
+	}
+	if c.SecurityType != "" && !IsSecurityType(c.SecurityType) {
+		return fmt.Errorf("unknown security_type %q", c.SecurityType)
+	}
+	if c.Near52WeekHigh && c.Near52WeekLow {
+		return fmt.Errorf("near_52_week_high and near_52_week_low are mutually exclusive")
+	}
+
+	if c.AIStance != "" && normalizeStance(c.AIStance) != c.AIStance {
+		return fmt.Errorf("ai_stance must be bullish, neutral or bearish")
+	}
+	if c.MinAIConfidence < 0 || c.MinAIConfidence > 1 {
+		return fmt.Errorf("min_ai_confidence must be between 0 and 1")
+	}
+	if c.MinAIConfidence > 0 && c.AIStance == "" {
+		return fmt.Errorf("min_ai_confidence requires ai_stance")
+	}
+
+	if c.ExpressionID != "" {
+		return fmt.Errorf("expression_id is not allowed")
+	}
+	if c.Expression != "" {
+		parsed, err := ParseExpression(c.Expression)
+		if err != nil {
+			return fmt.Errorf("expression: %v", err)
+		}
+		if !parsed.IsBoolean() {
+			return fmt.Errorf("expression must be a condition")
+		}
+	}
+
+	if !aiScreenerSortFields[c.SortBy] {
+		return fmt.Errorf("unknown sort_by %q", c.SortBy)
+	}
+	if c.Limit < 0 || c.Limit > aiScreenerMaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", aiScreenerMaxLimit)
+	}
+
+	filters := *c
+	filters.SortBy, filters.SortDesc, filters.Limit = "", false, 0
+	filters.ExcludeInactive = false
+	filters.ExcludeETFs, filters.ExcludeWarrants = false, false
+	filters.ExcludeFullCashDelivery, filters.ExcludeDisposition = false, false
+	if reflect.DeepEqual(filters, ScreenerCriteria{}) {
+		return fmt.Errorf("the query did not map to any supported criteria")
+	}
+	return nil
+}
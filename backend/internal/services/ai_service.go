@@ -1,46 +1,93 @@
 package services
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
-	"os"
+	"log"
 	"psm-backend/internal/database"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
-// AIService handles Google Gemini integration for stock analysis
+// AIService generates stock analysis with the configured LLM provider
+// (Gemini, OpenAI, Claude or a local Ollama model; see newLLMProvider)
 type AIService struct {
-	db         *database.DB
-	apiKey     string
-	model      string
-	httpClient *http.Client
+	db          *database.DB
+	provider    LLMProvider
+	providerErr error // Invalid AI_PROVIDER configuration, reported instead of "not configured"
+
+	sentimentService *SentimentService // Aspect breakdown for prompts
+	embeddingService *EmbeddingService // Relevance-ranked news for prompts (nil = most recent)
+
+	fallback LLMProvider    // Model tried once the primary model keeps failing (nil = none)
+	retry    llmRetryPolicy // Retries per model of rate-limited and failed calls
+	policy   *aiContentPolicy
+
+	limiter *aiLimiter // Bounds concurrent provider calls
+	jobsMu  sync.Mutex
+	jobs    map[string]*AIAnalysisJob   // Analyses queued while the limiter was busy
+	batches map[string]*AIAnalysisBatch // Guarded by jobsMu
 }
 
-func NewAIService(db *database.DB) *AIService {
-	model := os.Getenv("GEMINI_MODEL")
-	if model == "" {
-		model = "gemini-2.0-flash-exp"
+func NewAIService(db *database.DB, sentimentService *SentimentService, embeddingService *EmbeddingService) *AIService {
+	provider, err := newLLMProvider()
+	if err != nil {
+		log.Printf("Warning: AI provider disabled: %v", err)
+	} else if provider != nil {
+		log.Printf("AI provider: %s (%s)", provider.Name(), provider.Model())
 	}
 
 	return &AIService{
-		db:     db,
-		apiKey: os.Getenv("GEMINI_API_KEY"),
-		model:  model,
-		httpClient: &http.Client{
-			Timeout: 60 * time.Second,
-		},
+		db:               db,
+		provider:         provider,
+		providerErr:      err,
+		sentimentService: sentimentService,
+		embeddingService: embeddingService,
+		fallback:         fallbackProvider(provider),
+		retry:            newLLMRetryPolicy(),
+		policy:           newAIContentPolicy(),
+		limiter:          newAILimiter(),
+		jobs:             make(map[string]*AIAnalysisJob),
+		batches:          make(map[string]*AIAnalysisBatch),
 	}
 }
 
-// HasAPIKey returns whether Gemini API key is configured
-func (s *AIService) HasAPIKey() bool {
-	return s.apiKey != ""
+// ContentPolicy describes the rules applied to generated text
+func (s *AIService) ContentPolicy() AIContentPolicy {
+	return s.policy.Status()
+}
+
+// IsConfigured returns whether an AI provider is usable
+func (s *AIService) IsConfigured() bool {
+	return s.provider != nil
+}
+
+// ProviderName returns the configured provider, or "" when none is configured
+func (s *AIService) ProviderName() string {
+	if s.provider == nil {
+		return ""
+	}
+	return s.provider.Name()
+}
+
+// ModelName returns the configured model, or "" when no provider is configured
+func (s *AIService) ModelName() string {
+	if s.provider == nil {
+		return ""
+	}
+	return s.provider.Model()
+}
+
+func (s *AIService) notConfiguredError() error {
+	if s.providerErr != nil {
+		return s.providerErr
+	}
+	return fmt.Errorf("AI provider not configured. Set AI_PROVIDER, or GEMINI_API_KEY, OPENAI_API_KEY or ANTHROPIC_API_KEY.")
 }
 
 // AnalysisType defines the type of AI analysis
@@ -55,14 +102,52 @@ const (
 
 // AIAnalysisResult represents the result of AI analysis
 type AIAnalysisResult struct {
-	Symbol       string       `json:"symbol"`
-	AnalysisType AnalysisType `json:"analysis_type"`
-	Content      string       `json:"content"`
-	Model        string       `json:"model"`
-	InputTokens  int          `json:"input_tokens"`
-	OutputTokens int          `json:"output_tokens"`
-	CreatedAt    time.Time    `json:"created_at"`
-	Cached       bool         `json:"cached"`
+	Symbol        string       `json:"symbol"`
+	AnalysisType  AnalysisType `json:"analysis_type"`
+	Language      AILanguage   `json:"language"`
+	Content       string       `json:"content"`
+	Provider      string       `json:"provider"`
+	Model         string       `json:"model"`
+	PromptVersion int          `json:"prompt_version"` // 0 = built-in prompt
+	InputTokens   int          `json:"input_tokens"`
+	OutputTokens  int          `json:"output_tokens"`
+	CreatedAt     time.Time    `json:"created_at"`
+	Cached        bool         `json:"cached"`
+	FallbackUsed  bool         `json:"fallback_used"` // Generated by the fallback model
+
+	// Stance, key levels and risks; nil when the model returned no valid block
+	Structured *AIStructuredOutput `json:"structured,omitempty"`
+}
+
+// aiAnalysisColumns are the ai_analysis_cache columns read by scanAnalysis
+const aiAnalysisColumns = `symbol, analysis_type, content, COALESCE(provider, 'gemini'), model, COALESCE(prompt_version, 0),
+		       COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), created_at, COALESCE(fallback_used, false),
+		       stance, COALESCE(confidence, 0)::float8, support_levels, resistance_levels, risk_factors, language`
+
+func scanAnalysis(scanner interface{ Scan(...interface{}) error }) (*AIAnalysisResult, error) {
+	var r AIAnalysisResult
+	var stance sql.NullString
+	var confidence float64
+	var support, resistance []float64
+	var risks []string
+	err := scanner.Scan(
+		&r.Symbol, &r.AnalysisType, &r.Content, &r.Provider, &r.Model, &r.PromptVersion,
+		&r.InputTokens, &r.OutputTokens, &r.CreatedAt, &r.FallbackUsed,
+		&stance, &confidence, pq.Array(&support), pq.Array(&resistance), pq.Array(&risks), &r.Language,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if stance.Valid {
+		r.Structured = &AIStructuredOutput{
+			Stance:           stance.String,
+			Confidence:       confidence,
+			SupportLevels:    append([]float64{}, support...),
+			ResistanceLevels: append([]float64{}, resistance...),
+			RiskFactors:      append([]string{}, risks...),
+		}
+	}
+	return &r, nil
 }
 
 // StockContext holds all the context data for AI analysis
@@ -85,6 +170,9 @@ type StockContext struct {
 	BB_Lower           float64
 	RecentNews         []NewsItem
 	SentimentSummary   *SentimentSummary
+	Aspects            []AspectSummary  // Aspect sentiment over the last 30 days
+	Revenue            []MonthlyRevenue // Latest monthly revenue reports, newest first
+	History            []RetrievedChunk // Earlier analyses, filings and revenue reports relevant to the symbol
 }
 
 type NewsItem struct {
@@ -93,19 +181,22 @@ type NewsItem struct {
 	Sentiment string
 	Score     float64
 	Date      time.Time
+	IsFiling  bool // MOPS material announcement
 }
 
-// GetAnalysis retrieves or generates AI analysis for a symbol
-func (s *AIService) GetAnalysis(ctx context.Context, symbol string, analysisType AnalysisType) (*AIAnalysisResult, error) {
-	if !s.HasAPIKey() {
-		return nil, fmt.Errorf("Gemini API key not configured. Set GEMINI_API_KEY environment variable.")
+// GetAnalysis retrieves or generates AI analysis for a symbol in the given
+// language; a generated analysis counts towards userID's usage
+func (s *AIService) GetAnalysis(ctx context.Context, userID uuid.UUID, symbol string, analysisType AnalysisType, language AILanguage) (*AIAnalysisResult, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
 	}
 
 	today := time.Now().Format("2006-01-02")
+	template := s.activePromptTemplate(ctx, analysisType)
 
-	// Check cache first
-	cached, err := s.getCachedAnalysis(ctx, symbol, analysisType, today)
-	if err == nil && cached != nil {
+	// Check cache first; analyses from an older prompt version are regenerated
+	cached, err := s.getCachedAnalysis(ctx, symbol, analysisType, language, today)
+	if err == nil && cached != nil && cached.PromptVersion == template.Version {
 		cached.Cached = true
 		return cached, nil
 	}
@@ -117,7 +208,7 @@ func (s *AIService) GetAnalysis(ctx context.Context, symbol string, analysisType
 	}
 
 	// Generate analysis
-	result, err := s.generateAnalysis(ctx, stockContext, analysisType)
+	result, err := s.generateAnalysis(ctx, userID, stockContext, analysisType, template, language)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate analysis: %w", err)
 	}
@@ -132,40 +223,36 @@ func (s *AIService) GetAnalysis(ctx context.Context, symbol string, analysisType
 }
 
 // getCachedAnalysis retrieves cached analysis from database
-func (s *AIService) getCachedAnalysis(ctx context.Context, symbol string, analysisType AnalysisType, date string) (*AIAnalysisResult, error) {
+func (s *AIService) getCachedAnalysis(ctx context.Context, symbol string, analysisType AnalysisType, language AILanguage, date string) (*AIAnalysisResult, error) {
 	query := `
-		SELECT symbol, analysis_type, content, model, COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), created_at
+		SELECT ` + aiAnalysisColumns + `
 		FROM ai_analysis_cache
-		WHERE symbol = $1 AND analysis_type = $2 AND analysis_date = $3
+		WHERE symbol = $1 AND analysis_type = $2 AND analysis_date = $3 AND language = $4
 		AND (expires_at IS NULL OR expires_at > NOW())
 	`
 
-	var result AIAnalysisResult
-	err := s.db.QueryRowContext(ctx, query, symbol, string(analysisType), date).Scan(
-		&result.Symbol,
-		&result.AnalysisType,
-		&result.Content,
-		&result.Model,
-		&result.InputTokens,
-		&result.OutputTokens,
-		&result.CreatedAt,
-	)
-	if err != nil {
-		return nil, err
-	}
-
-	return &result, nil
+	return scanAnalysis(s.db.QueryRowContext(ctx, query, symbol, string(analysisType), date, string(language)))
 }
 
 // cacheAnalysis saves analysis to database
 func (s *AIService) cacheAnalysis(ctx context.Context, result *AIAnalysisResult, date string) error {
 	query := `
-		INSERT INTO ai_analysis_cache (symbol, analysis_type, analysis_date, content, model, input_tokens, output_tokens, expires_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW() + INTERVAL '24 hours')
-		ON CONFLICT (symbol, analysis_type, analysis_date) 
-		DO UPDATE SET content = $4, model = $5, input_tokens = $6, output_tokens = $7, created_at = NOW(), expires_at = NOW() + INTERVAL '24 hours'
+		INSERT INTO ai_analysis_cache (symbol, analysis_type, analysis_date, content, model, input_tokens, output_tokens, expires_at, provider, prompt_version,
+		                               stance, confidence, support_levels, resistance_levels, risk_factors, fallback_used, language)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW() + INTERVAL '24 hours', $8, NULLIF($9, 0), $10, $11, $12, $13, $14, $15, $16)
+		ON CONFLICT (symbol, analysis_type, analysis_date, language) 
+		DO UPDATE SET content = $4, model = $5, input_tokens = $6, output_tokens = $7, provider = $8, prompt_version = NULLIF($9, 0),
+		              stance = $10, confidence = $11, support_levels = $12, resistance_levels = $13, risk_factors = $14, fallback_used = $15,
+		              created_at = NOW(), expires_at = NOW() + INTERVAL '24 hours'
 	`
 
+	var stance, confidence interface{}
+	var support, resistance, risks interface{}
+	if st := result.Structured; st != nil {
+		stance, confidence = st.Stance, st.Confidence
+		support, resistance, risks = pq.Array(st.SupportLevels), pq.Array(st.ResistanceLevels), pq.Array(st.RiskFactors)
+	}
+
 	_, err := s.db.ExecContext(ctx, query,
 		result.Symbol,
 		string(result.AnalysisType),
@@ -174,6 +261,15 @@ func (s *AIService) cacheAnalysis(ctx context.Context, result *AIAnalysisResult,
 		result.Model,
 		result.InputTokens,
 		result.OutputTokens,
+		result.Provider,
+		result.PromptVersion,
+		stance,
+		confidence,
+		support,
+		resistance,
+		risks,
+		result.FallbackUsed,
+		string(result.Language),
 	)
 	return err
 }
@@ -220,81 +316,115 @@ func (s *AIService) buildStockContext(ctx context.Context, symbol string) (*Stoc
 	s.db.QueryRowContext(ctx, avgVolQuery, symbol).Scan(&stockContext.AvgVolume)
 
 	// Get recent news with sentiment
+	// Material announcements (重大訊息) from the last 14 days come first: filings move
+	// prices more than media coverage
 	newsQuery := `
-		SELECT title, COALESCE(summary, ''), COALESCE(sentiment, 'neutral'), COALESCE(sentiment_score, 0), published_at
+		SELECT title, COALESCE(summary, ''), COALESCE(sentiment, 'neutral'), COALESCE(sentiment_score, 0), published_at,
+		       COALESCE(category, '') = $2 AS is_filing
 		FROM stock_news
-		WHERE symbol = $1
-		ORDER BY published_at DESC
+		WHERE id IN (SELECT article_id FROM article_symbols WHERE symbol = $1)
+		ORDER BY (COALESCE(category, '') = $2 AND published_at >= NOW() - INTERVAL '14 days') DESC, published_at DESC
 		LIMIT 10
 	`
-	newsRows, err := s.db.QueryContext(ctx, newsQuery, symbol)
+	newsArgs := []interface{}{symbol, MaterialAnnouncementCategory}
+
+	// With embeddings, the rest are the articles most relevant to the company's
+	// outlook rather than simply the newest
+	if s.embeddingService != nil && s.embeddingService.Enabled(ctx) {
+		query := fmt.Sprintf("%s %s 營運展望 財報 營收 訂單 產能 法說會 股價影響", symbol, stockContext.Name)
+		if ids, err := s.embeddingService.RelevantArticles(ctx, symbol, query, 30, 10); err == nil && len(ids) >= 3 {
+			newsQuery = `
+				SELECT title, COALESCE(summary, ''), COALESCE(sentiment, 'neutral'), COALESCE(sentiment_score, 0), published_at,
+				       COALESCE(category, '') = $2 AS is_filing
+				FROM stock_news
+				WHERE id IN (SELECT article_id FROM article_symbols WHERE symbol = $1)
+				  AND ((COALESCE(category, '') = $2 AND published_at >= NOW() - INTERVAL '14 days') OR id = ANY($3::uuid[]))
+				ORDER BY (COALESCE(category, '') = $2 AND published_at >= NOW() - INTERVAL '14 days') DESC,
+				         array_position($3::uuid[], id)
+				LIMIT 10
+			`
+			newsArgs = append(newsArgs, pq.Array(ids))
+		} else if err != nil {
+			log.Printf("Warning: relevance ranking for %s failed, using most recent news: %v", symbol, err)
+		}
+	}
+
+	newsRows, err := s.db.QueryContext(ctx, newsQuery, newsArgs...)
 	if err == nil {
 		defer newsRows.Close()
 		for newsRows.Next() {
 			var item NewsItem
-			newsRows.Scan(&item.Title, &item.Summary, &item.Sentiment, &item.Score, &item.Date)
+			newsRows.Scan(&item.Title, &item.Summary, &item.Sentiment, &item.Score, &item.Date, &item.IsFiling)
 			stockContext.RecentNews = append(stockContext.RecentNews, item)
 		}
 	}
 
-	// Get sentiment summary
-	sentimentQuery := `
-		SELECT 
-			COUNT(*) as total,
-			COUNT(CASE WHEN sentiment = 'positive' THEN 1 END) as positive,
-			COUNT(CASE WHEN sentiment = 'negative' THEN 1 END) as negative,
-			COALESCE(AVG(sentiment_score), 0) as avg_score
-		FROM stock_news
-		WHERE symbol = $1 AND published_at >= NOW() - INTERVAL '7 days' AND sentiment IS NOT NULL
-	`
-	var summary SentimentSummary
-	summary.Symbol = symbol
-	summary.Days = 7
-	err = s.db.QueryRowContext(ctx, sentimentQuery, symbol).Scan(
-		&summary.TotalArticles,
-		&summary.PositiveCount,
-		&summary.NegativeCount,
-		&summary.AverageScore,
-	)
-	if err == nil {
-		summary.NeutralCount = summary.TotalArticles - summary.PositiveCount - summary.NegativeCount
-		if summary.AverageScore > 0.15 {
-			summary.OverallSentiment = "positive"
-		} else if summary.AverageScore < -0.15 {
-			summary.OverallSentiment = "negative"
-		} else {
-			summary.OverallSentiment = "neutral"
+	// Get sentiment summary (confidence-weighted)
+	if s.sentimentService != nil {
+		if summary, err := s.sentimentService.GetSentimentSummary(ctx, symbol, 7, 0, false); err == nil {
+			stockContext.SentimentSummary = summary
+		}
+	}
+
+	// Aspect breakdown (revenue, orders, lawsuits, ...) from analysed articles
+	if s.sentimentService != nil {
+		if aspects, err := s.sentimentService.GetAspectBreakdown(ctx, symbol, 30); err == nil {
+			stockContext.Aspects = aspects
 		}
-		stockContext.SentimentSummary = &summary
+	}
+
+	if revenue, err := queryMonthlyRevenue(ctx, s.db, symbol, 3); err == nil {
+		stockContext.Revenue = revenue
+	}
+
+	// Passages from earlier analyses, filings and revenue reports, so the
+	// model can refer back beyond the latest headlines
+	if s.embeddingService != nil {
+		query := fmt.Sprintf("%s %s 營收表現 財報 重大訊息 過去分析觀點與風險", symbol, stockContext.Name)
+		stockContext.History = s.embeddingService.retrieveForPrompt(ctx, symbol, query)
 	}
 
 	return stockContext, nil
 }
 
-// generateAnalysis calls Gemini API to generate analysis
-func (s *AIService) generateAnalysis(ctx context.Context, stockContext *StockContext, analysisType AnalysisType) (*AIAnalysisResult, error) {
-	prompt := s.buildPrompt(stockContext, analysisType)
-	systemPrompt := s.getSystemPrompt(analysisType)
+// analysisRequest builds the provider request from a prompt template
+func (s *AIService) analysisRequest(stockContext *StockContext, template *AIPromptTemplate, language AILanguage) LLMRequest {
+	return LLMRequest{
+		System:      template.SystemPrompt + s.policy.instruction() + aiStructuredInstruction + language.instruction(),
+		Messages:    []LLMMessage{{Role: "user", Content: template.render(stockContext)}},
+		Temperature: 0.7,
+		MaxTokens:   2048,
+	}
+}
 
-	// Call Gemini API
-	response, err := s.callGemini(ctx, systemPrompt, prompt)
+// generateAnalysis calls the configured provider to generate analysis
+func (s *AIService) generateAnalysis(ctx context.Context, userID uuid.UUID, stockContext *StockContext, analysisType AnalysisType, template *AIPromptTemplate, language AILanguage) (*AIAnalysisResult, error) {
+	response, err := s.generate(ctx, userID, string(analysisType), s.analysisRequest(stockContext, template, language))
 	if err != nil {
 		return nil, err
 	}
+	content, structured := parseStructuredOutput(response.Content)
+	content = s.policy.apply(content, language)
 
 	return &AIAnalysisResult{
-		Symbol:       stockContext.Symbol,
-		AnalysisType: analysisType,
-		Content:      response.Content,
-		Model:        s.model,
-		InputTokens:  response.InputTokens,
-		OutputTokens: response.OutputTokens,
-		CreatedAt:    time.Now(),
-		Cached:       false,
+		Symbol:        stockContext.Symbol,
+		AnalysisType:  analysisType,
+		Language:      language,
+		Content:       content,
+		Provider:      s.provider.Name(),
+		Model:         response.Model,
+		PromptVersion: template.Version,
+		InputTokens:   response.InputTokens,
+		OutputTokens:  response.OutputTokens,
+		CreatedAt:     time.Now(),
+		Cached:        false,
+		Structured:    structured,
+		FallbackUsed:  response.FallbackUsed,
 	}, nil
 }
 
-func (s *AIService) getSystemPrompt(analysisType AnalysisType) string {
+// builtinSystemPrompt is the system prompt used until a template is stored
+func builtinSystemPrompt(analysisType AnalysisType) string {
 	base := `你是一位專業的台股分析師，專精於技術分析和基本面分析。你的分析應該：
 1. 使用繁體中文回答
 2. 客觀專業，避免過度樂觀或悲觀
@@ -317,7 +447,24 @@ func (s *AIService) getSystemPrompt(analysisType AnalysisType) string {
 	}
 }
 
-func (s *AIService) buildPrompt(ctx *StockContext, analysisType AnalysisType) string {
+// builtinUserPrompt is the user prompt template used until a template is stored
+func builtinUserPrompt(analysisType AnalysisType) string {
+	prompt := "{{context}}\n---\n"
+	switch analysisType {
+	case AnalysisTypeDailySummary:
+		prompt += "請根據以上資訊，提供今日行情摘要分析。"
+	case AnalysisTypeInvestmentAdvice:
+		prompt += "請根據以上資訊，提供投資建議和操作策略。"
+	case AnalysisTypeRiskAssessment:
+		prompt += "請根據以上資訊，進行風險評估。"
+	case AnalysisTypeNewsDigest:
+		prompt += "請根據以上新聞，分析對股價的可能影響。"
+	}
+	return prompt
+}
+
+// formatStockContext renders price, sentiment and news data as prompt markdown
+func formatStockContext(ctx *StockContext) string {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("## 股票資訊\n"))
@@ -345,145 +492,75 @@ func (s *AIService) buildPrompt(ctx *StockContext, analysisType AnalysisType) st
 			ctx.SentimentSummary.PositiveCount,
 			ctx.SentimentSummary.NegativeCount,
 			ctx.SentimentSummary.NeutralCount))
-		sb.WriteString(fmt.Sprintf("- 平均情緒分數: %.2f (範圍 -1 到 1)\n", ctx.SentimentSummary.AverageScore))
+		sb.WriteString(fmt.Sprintf("- 平均情緒分數: %.2f (依信心加權, 範圍 -1 到 1)\n", ctx.SentimentSummary.WeightedScore))
 		sb.WriteString(fmt.Sprintf("- 整體情緒: %s\n", ctx.SentimentSummary.OverallSentiment))
 	}
 
-	// Recent news
-	if len(ctx.RecentNews) > 0 {
-		sb.WriteString(fmt.Sprintf("\n## 近期新聞\n"))
-		for i, news := range ctx.RecentNews {
-			if i >= 5 {
-				break
+	if len(ctx.Aspects) > 0 {
+		sb.WriteString("\n## 近30日面向情緒\n")
+		for _, a := range ctx.Aspects {
+			sb.WriteString(fmt.Sprintf("- %s: %s (%.2f, %d篇, 正面 %d / 負面 %d)\n",
+				a.Label, a.Sentiment, a.AverageScore, a.Articles, a.PositiveCount, a.NegativeCount))
+			if a.LatestSnippet != "" && a.Sentiment != "neutral" {
+				sb.WriteString(fmt.Sprintf("  例: %s\n", a.LatestSnippet))
 			}
-			sb.WriteString(fmt.Sprintf("%d. [%s] %s (%s)\n", i+1, news.Sentiment, news.Title, news.Date.Format("01/02")))
 		}
 	}
 
-	// Analysis request
-	sb.WriteString(fmt.Sprintf("\n---\n"))
-	switch analysisType {
-	case AnalysisTypeDailySummary:
-		sb.WriteString("請根據以上資訊，提供今日行情摘要分析。")
-	case AnalysisTypeInvestmentAdvice:
-		sb.WriteString("請根據以上資訊，提供投資建議和操作策略。")
-	case AnalysisTypeRiskAssessment:
-		sb.WriteString("請根據以上資訊，進行風險評估。")
-	case AnalysisTypeNewsDigest:
-		sb.WriteString("請根據以上新聞，分析對股價的可能影響。")
-	}
-
-	return sb.String()
-}
-
-// Gemini API types
-type geminiRequest struct {
-	Contents         []geminiContent        `json:"contents"`
-	SystemInstruction *geminiContent        `json:"systemInstruction,omitempty"`
-	GenerationConfig *geminiGenerationConfig `json:"generationConfig,omitempty"`
-}
-
-type geminiContent struct {
-	Parts []geminiPart `json:"parts"`
-	Role  string       `json:"role,omitempty"`
-}
-
-type geminiPart struct {
-	Text string `json:"text"`
-}
-
-type geminiGenerationConfig struct {
-	Temperature     float64 `json:"temperature,omitempty"`
-	MaxOutputTokens int     `json:"maxOutputTokens,omitempty"`
-}
-
-type geminiResponse struct {
-	Candidates []struct {
-		Content struct {
-			Parts []struct {
-				Text string `json:"text"`
-			} `json:"parts"`
-		} `json:"content"`
-	} `json:"candidates"`
-	UsageMetadata struct {
-		PromptTokenCount     int `json:"promptTokenCount"`
-		CandidatesTokenCount int `json:"candidatesTokenCount"`
-		TotalTokenCount      int `json:"totalTokenCount"`
-	} `json:"usageMetadata"`
-	Error *struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Status  string `json:"status"`
-	} `json:"error"`
-}
-
-type geminiResult struct {
-	Content      string
-	InputTokens  int
-	OutputTokens int
-}
-
-func (s *AIService) callGemini(ctx context.Context, systemPrompt, userPrompt string) (*geminiResult, error) {
-	// Build Gemini API URL
-	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", s.model, s.apiKey)
-
-	reqBody := geminiRequest{
-		SystemInstruction: &geminiContent{
-			Parts: []geminiPart{{Text: systemPrompt}},
-		},
-		Contents: []geminiContent{
-			{
-				Role:  "user",
-				Parts: []geminiPart{{Text: userPrompt}},
-			},
-		},
-		GenerationConfig: &geminiGenerationConfig{
-			Temperature:     0.7,
-			MaxOutputTokens: 2048,
-		},
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
-
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(jsonBody))
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := s.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call Gemini API: %w", err)
+	if len(ctx.Revenue) > 0 {
+		sb.WriteString("\n## 近期月營收\n")
+		for _, r := range ctx.Revenue {
+			sb.WriteString(fmt.Sprintf("- %s\n", formatRevenueReport(r)))
+		}
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	// Material announcements, then media news
+	var filings, media []NewsItem
+	for _, news := range ctx.RecentNews {
+		if news.IsFiling {
+			filings = append(filings, news)
+		} else {
+			media = append(media, news)
+		}
 	}
-
-	var geminiResp geminiResponse
-	if err := json.Unmarshal(body, &geminiResp); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if len(filings) > 0 {
+		sb.WriteString("\n## 重大訊息 (公開資訊觀測站)\n")
+		for i, news := range filings {
+			if i >= 5 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%d. %s (%s)\n", i+1, news.Title, news.Date.Format("01/02")))
+			if news.Summary != "" {
+				sb.WriteString(fmt.Sprintf("   %s\n", news.Summary))
+			}
+		}
 	}
 
-	if geminiResp.Error != nil {
-		return nil, fmt.Errorf("Gemini API error: %s (code: %d)", geminiResp.Error.Message, geminiResp.Error.Code)
+	// Recent news
+	if len(media) > 0 {
+		sb.WriteString(fmt.Sprintf("\n## 近期新聞\n"))
+		for i, news := range media {
+			if i >= 5 {
+				break
+			}
+			sb.WriteString(fmt.Sprintf("%d. [%s] %s (%s)\n", i+1, news.Sentiment, news.Title, news.Date.Format("01/02")))
+		}
 	}
 
-	if len(geminiResp.Candidates) == 0 || len(geminiResp.Candidates[0].Content.Parts) == 0 {
-		return nil, fmt.Errorf("no response from Gemini")
+	if len(ctx.History) > 0 {
+		labels := map[string]string{
+			RetrievalSourceAnalysis: "過去分析",
+			RetrievalSourceFiling:   "重大訊息",
+			RetrievalSourceRevenue:  "月營收",
+		}
+		sb.WriteString("\n## 歷史資料參考 (依相關性檢索)\n")
+		for i, chunk := range ctx.History {
+			sb.WriteString(fmt.Sprintf("%d. [%s %s] %s\n", i+1, labels[chunk.SourceType],
+				chunk.PublishedAt.Format("2006-01-02"), strings.ReplaceAll(chunk.Content, "\n", " ")))
+		}
 	}
 
-	return &geminiResult{
-		Content:      geminiResp.Candidates[0].Content.Parts[0].Text,
-		InputTokens:  geminiResp.UsageMetadata.PromptTokenCount,
-		OutputTokens: geminiResp.UsageMetadata.CandidatesTokenCount,
-	}, nil
+	return sb.String()
 }
 
 // GetCachedAnalyses returns all cached analyses for a symbol
@@ -493,7 +570,7 @@ func (s *AIService) GetCachedAnalyses(ctx context.Context, symbol string, limit
 	}
 
 	query := `
-		SELECT symbol, analysis_type, content, model, COALESCE(input_tokens, 0), COALESCE(output_tokens, 0), created_at
+		SELECT ` + aiAnalysisColumns + `
 		FROM ai_analysis_cache
 		WHERE symbol = $1
 		ORDER BY created_at DESC
@@ -508,12 +585,12 @@ func (s *AIService) GetCachedAnalyses(ctx context.Context, symbol string, limit
 
 	var results []AIAnalysisResult
 	for rows.Next() {
-		var r AIAnalysisResult
-		if err := rows.Scan(&r.Symbol, &r.AnalysisType, &r.Content, &r.Model, &r.InputTokens, &r.OutputTokens, &r.CreatedAt); err != nil {
+		r, err := scanAnalysis(rows)
+		if err != nil {
 			continue
 		}
 		r.Cached = true
-		results = append(results, r)
+		results = append(results, *r)
 	}
 
 	return results, nil
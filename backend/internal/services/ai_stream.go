@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// StreamAnalysis works like GetAnalysis but passes generated text to onChunk as
+// the provider produces it. A cached analysis is delivered as a single chunk.
+// The complete result is cached and returned once generation finishes; an
+// error from onChunk (e.g. the client went away) aborts generation.
+func (s *AIService) StreamAnalysis(ctx context.Context, userID uuid.UUID, symbol string, analysisType AnalysisType, language AILanguage, onChunk func(text string) error) (*AIAnalysisResult, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+
+	today := time.Now().Format("2006-01-02")
+	template := s.activePromptTemplate(ctx, analysisType)
+
+	if cached, err := s.getCachedAnalysis(ctx, symbol, analysisType, language, today); err == nil && cached != nil && cached.PromptVersion == template.Version {
+		cached.Cached = true
+		if err := onChunk(cached.Content); err != nil {
+			return nil, err
+		}
+		return cached, nil
+	}
+
+	stockContext, err := s.buildStockContext(ctx, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build stock context: %w", err)
+	}
+
+	// The structured block is parsed from the full text rather than streamed,
+	// and the content policy is applied a sentence at a time
+	policy := &policyStreamFilter{policy: s.policy, language: language, onChunk: onChunk}
+	filter := &structuredStreamFilter{onChunk: policy.write}
+	response, err := s.stream(ctx, userID, string(analysisType), s.analysisRequest(stockContext, template, language), filter.write)
+	if err == nil {
+		err = filter.flush()
+	}
+	if err == nil {
+		err = policy.flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate analysis: %w", err)
+	}
+	content, structured := parseStructuredOutput(response.Content)
+	content = s.policy.apply(content, language)
+
+	result := &AIAnalysisResult{
+		Symbol:        symbol,
+		AnalysisType:  analysisType,
+		Language:      language,
+		Content:       content,
+		Provider:      s.provider.Name(),
+		Model:         response.Model,
+		PromptVersion: template.Version,
+		InputTokens:   response.InputTokens,
+		OutputTokens:  response.OutputTokens,
+		CreatedAt:     time.Now(),
+		Structured:    structured,
+		FallbackUsed:  response.FallbackUsed,
+	}
+	if err := s.cacheAnalysis(ctx, result, today); err != nil {
+		fmt.Printf("Warning: failed to cache analysis: %v\n", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// AI stances parsed from the structured block of an analysis
+const (
+	AIStanceBullish = "bullish"
+	AIStanceNeutral = "neutral"
+	AIStanceBearish = "bearish"
+)
+
+// aiStructuredInstruction is appended to every analysis system prompt, built-in
+// or stored, so the structured fields do not depend on the template
+const aiStructuredInstruction = `
+
+在分析內容之後，請另外附上一個 JSON 區塊（以 ` + "```json" + ` 開頭、` + "```" + ` 結尾），格式如下，不要加入其他欄位：
+` + "```json" + `
+{"stance": "bullish|neutral|bearish", "confidence": 0.0, "support_levels": [0.0], "resistance_levels": [0.0], "risk_factors": ["..."]}
+` + "```" + `
+confidence 為 0 到 1 之間的數字，價位請使用資料中的價格尺度，risk_factors 最多 5 項並使用繁體中文。`
+
+// aiStructuredFence marks the start of the structured block in generated text
+const aiStructuredFence = "```json"
+
+var aiStructuredBlockPattern = regexp.MustCompile("(?s)```json\\s*(\\{.*?\\})\\s*```")
+
+// AIStructuredOutput holds the machine-readable fields of an analysis
+type AIStructuredOutput struct {
+	Stance           string    `json:"stance"`     // bullish, neutral or bearish
+	Confidence       float64   `json:"confidence"` // 0 to 1
+	SupportLevels    []float64 `json:"support_levels"`
+	ResistanceLevels []float64 `json:"resistance_levels"`
+	RiskFactors      []string  `json:"risk_factors"`
+}
+
+// parseStructuredOutput splits generated text into the free-text analysis and
+// its structured block. The text is returned unchanged with a nil output when
+// there is no valid block.
+func parseStructuredOutput(content string) (string, *AIStructuredOutput) {
+	matches := aiStructuredBlockPattern.FindAllStringSubmatchIndex(content, -1)
+	if len(matches) == 0 {
+		return content, nil
+	}
+	last := matches[len(matches)-1]
+
+	var raw AIStructuredOutput
+	if err := json.Unmarshal([]byte(content[last[2]:last[3]]), &raw); err != nil {
+		return content, nil
+	}
+
+	output := &AIStructuredOutput{
+		Stance:           normalizeStance(raw.Stance),
+		Confidence:       roundTo(clampFloat(raw.Confidence, 0, 1), 3),
+		SupportLevels:    positiveLevels(raw.SupportLevels),
+		ResistanceLevels: positiveLevels(raw.ResistanceLevels),
+		RiskFactors:      []string{},
+	}
+	if output.Stance == "" {
+		return content, nil
+	}
+	for _, r := range raw.RiskFactors {
+		if r = strings.TrimSpace(r); r != "" && len(output.RiskFactors) < 5 {
+			output.RiskFactors = append(output.RiskFactors, truncateRunes(r, 200))
+		}
+	}
+
+	text := strings.TrimSpace(content[:last[0]] + content[last[1]:])
+	return text, output
+}
+
+// normalizeStance maps the model's stance to bullish/neutral/bearish, or "" if unrecognised
+func normalizeStance(stance string) string {
+	switch strings.ToLower(strings.TrimSpace(stance)) {
+	case "bullish", "positive", "看多", "偏多":
+		return AIStanceBullish
+	case "neutral", "中性", "觀望":
+		return AIStanceNeutral
+	case "bearish", "negative", "看空", "偏空":
+		return AIStanceBearish
+	}
+	return ""
+}
+
+func positiveLevels(levels []float64) []float64 {
+	result := []float64{}
+	for _, l := range levels {
+		if l > 0 && len(result) < 5 {
+			result = append(result, roundTo(l, 2))
+		}
+	}
+	return result
+}
+
+// structuredStreamFilter forwards streamed text until the structured block
+// starts, so clients only see the free-text analysis. Text that could be the
+// start of the fence is held back until it is known not to be.
+type structuredStreamFilter struct {
+	onChunk func(text string) error
+	pending string
+	done    bool
+}
+
+func (f *structuredStreamFilter) write(text string) error {
+	if f.done {
+		return nil
+	}
+	f.pending += text
+	if i := strings.Index(f.pending, aiStructuredFence); i >= 0 {
+		f.done = true
+		return f.emit(strings.TrimRight(f.pending[:i], " \n"))
+	}
+
+	// Keep any suffix that is a prefix of the fence
+	keep := 0
+	for n := len(aiStructuredFence) - 1; n > 0; n-- {
+		if strings.HasSuffix(f.pending, aiStructuredFence[:n]) {
+			keep = n
+			break
+		}
+	}
+	out := f.pending[:len(f.pending)-keep]
+	f.pending = f.pending[len(f.pending)-keep:]
+	return f.emit(out)
+}
+
+// flush forwards held-back text once the stream has ended without a block
+func (f *structuredStreamFilter) flush() error {
+	if f.done {
+		return nil
+	}
+	f.done = true
+	return f.emit(f.pending)
+}
+
+func (f *structuredStreamFilter) emit(text string) error {
+	if text == "" {
+		return nil
+	}
+	return f.onChunk(text)
+}
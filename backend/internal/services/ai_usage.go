@@ -0,0 +1,316 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AITokenUsage is the token usage of one provider, model and feature over a period
+type AITokenUsage struct {
+	Provider     string  `json:"provider"`
+	Model        string  `json:"model"`
+	Feature      string  `json:"feature"` // Analysis type, chat, digest, ...
+	Requests     int     `json:"requests"`
+	Failures     int     `json:"failures"`
+	InputTokens  int64   `json:"input_tokens"`
+	OutputTokens int64   `json:"output_tokens"`
+	CostUSD      float64 `json:"cost_usd"` // Estimated from ai_model_prices
+}
+
+// AIDailyUsage is one user's token usage on one day
+type AIDailyUsage struct {
+	Date         string    `json:"date"`
+	UserID       uuid.UUID `json:"user_id"` // Nil UUID = background work
+	Requests     int       `json:"requests"`
+	Failures     int       `json:"failures"`
+	InputTokens  int64     `json:"input_tokens"`
+	OutputTokens int64     `json:"output_tokens"`
+	CostUSD      float64   `json:"cost_usd"`
+}
+
+// AIUsageBudget caps a user's (or, without a user, the installation's) usage
+// per Asia/Taipei calendar month
+type AIUsageBudget struct {
+	ID             string     `json:"id"`
+	UserID         *uuid.UUID `json:"user_id"` // nil = whole installation
+	MonthlyTokens  *int64     `json:"monthly_tokens"`
+	MonthlyCostUSD *float64   `json:"monthly_cost_usd"`
+	MonthTokens    int64      `json:"month_tokens"` // Used so far this month
+	MonthCostUSD   float64    `json:"month_cost_usd"`
+	Exceeded       bool       `json:"exceeded"`
+}
+
+// AIUsageBudgetRequest creates or replaces a budget
+type AIUsageBudgetRequest struct {
+	UserID         *uuid.UUID `json:"user_id"`
+	MonthlyTokens  *int64     `json:"monthly_tokens"`
+	MonthlyCostUSD *float64   `json:"monthly_cost_usd"`
+}
+
+// generate calls the provider for userID (uuid.Nil for background work) once
+// the budgets allow it and a provider slot is free, retrying and falling back
+// as callProvider does, and records token usage under feature
+func (s *AIService) generate(ctx context.Context, userID uuid.UUID, feature string, req LLMRequest) (*LLMResponse, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+	if err := s.checkBudget(ctx, userID); err != nil {
+		return nil, err
+	}
+	release, err := s.acquire(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	return s.callProvider(ctx, userID, feature, func(p LLMProvider) (*LLMResponse, error) {
+		return p.Generate(ctx, req)
+	}, func() bool { return true })
+}
+
+// stream is generate for streamed responses
+func (s *AIService) stream(ctx context.Context, userID uuid.UUID, feature string, req LLMRequest, onChunk func(text string) error) (*LLMResponse, error) {
+	if !s.IsConfigured() {
+		return nil, s.notConfiguredError()
+	}
+	if err := s.checkBudget(ctx, userID); err != nil {
+		return nil, err
+	}
+	release, err := s.acquire(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	// Once text has reached the client the call can no longer be repeated
+	emitted := false
+	return s.callProvider(ctx, userID, feature, func(p LLMProvider) (*LLMResponse, error) {
+		return p.Stream(ctx, req, func(text string) error {
+			emitted = true
+			return onChunk(text)
+		})
+	}, func() bool { return !emitted })
+}
+
+// checkBudget refuses a request once the user's or the installation's budget
+// for the month is used up. Budgets that cannot be read do not block requests.
+func (s *AIService) checkBudget(ctx context.Context, userID uuid.UUID) error {
+	budgets, err := s.queryBudgets(ctx, `WHERE b.user_id IS NULL OR b.user_id = $1`, userID)
+	if err != nil {
+		log.Printf("Warning: failed to check AI budgets: %v", err)
+		return nil
+	}
+	for _, b := range budgets {
+		if !b.Exceeded {
+			continue
+		}
+		scope := "installation"
+		if b.UserID != nil {
+			scope = "user"
+		}
+		return fmt.Errorf("monthly AI budget exceeded for this %s (%d tokens, $%.2f used)", scope, b.MonthTokens, b.MonthCostUSD)
+	}
+	return nil
+}
+
+// recordUsage adds one request to model's usage row for today. It runs on its
+// own context so usage is recorded even when the request was cancelled.
+func (s *AIService) recordUsage(userID uuid.UUID, feature, model string, resp *LLMResponse, callErr error) {
+	var input, output, failures int
+	if resp != nil {
+		input, output = resp.InputTokens, resp.OutputTokens
+	}
+	if callErr != nil {
+		failures = 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Cost uses the model's price, else the provider's '*' price
+	query := `
+		INSERT INTO ai_token_usage (usage_date, user_id, provider, model, feature, requests, failures, input_tokens, output_tokens, cost_usd)
+		SELECT (NOW() AT TIME ZONE 'Asia/Taipei')::date, $1::uuid, $2, $3, $4, 1, $5::int, $6::bigint, $7::bigint,
+		       COALESCE(($6::bigint * p.input_per_million + $7::bigint * p.output_per_million) / 1000000, 0)
+		FROM (SELECT 1) one
+		LEFT JOIN LATERAL (
+			SELECT input_per_million, output_per_million FROM ai_model_prices
+			WHERE provider = $2 AND model IN ($3, '*')
+			ORDER BY model = '*'
+			LIMIT 1
+		) p ON true
+		ON CONFLICT (usage_date, user_id, provider, model, feature) DO UPDATE SET
+			requests = ai_token_usage.requests + 1,
+			failures = ai_token_usage.failures + EXCLUDED.failures,
+			input_tokens = ai_token_usage.input_tokens + EXCLUDED.input_tokens,
+			output_tokens = ai_token_usage.output_tokens + EXCLUDED.output_tokens,
+			cost_usd = ai_token_usage.cost_usd + EXCLUDED.cost_usd,
+			updated_at = NOW()
+	`
+	if _, err := s.db.ExecContext(ctx, query, userID, s.provider.Name(), model, feature, failures, input, output); err != nil {
+		log.Printf("Warning: failed to record AI token usage: %v", err)
+	}
+}
+
+// GetTokenUsage totals token usage per provider, model and feature over the last days
+func (s *AIService) GetTokenUsage(ctx context.Context, days int) ([]AITokenUsage, error) {
+	if days <= 0 {
+		days = 30
+	}
+
+	query := `
+		SELECT provider, model, feature, SUM(requests), SUM(failures), SUM(input_tokens), SUM(output_tokens), SUM(cost_usd)::float8
+		FROM ai_token_usage
+		WHERE usage_date > (NOW() AT TIME ZONE 'Asia/Taipei')::date - $1::int
+		GROUP BY provider, model, feature
+		ORDER BY provider, model, SUM(input_tokens) + SUM(output_tokens) DESC
+	`
+	rows, err := s.db.QueryContext(ctx, query, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query token usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []AITokenUsage{}
+	for rows.Next() {
+		var u AITokenUsage
+		if err := rows.Scan(&u.Provider, &u.Model, &u.Feature, &u.Requests, &u.Failures, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			continue
+		}
+		u.CostUSD = roundTo(u.CostUSD, 4)
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// GetDailyUsage totals usage per day and user over the last days, newest
+// first, optionally for one user
+func (s *AIService) GetDailyUsage(ctx context.Context, days int, userID *uuid.UUID) ([]AIDailyUsage, error) {
+	if days <= 0 {
+		days = 30
+	}
+
+	query := `
+		SELECT to_char(usage_date, 'YYYY-MM-DD'), user_id, SUM(requests), SUM(failures),
+		       SUM(input_tokens), SUM(output_tokens), SUM(cost_usd)::float8
+		FROM ai_token_usage
+		WHERE usage_date > (NOW() AT TIME ZONE 'Asia/Taipei')::date - $1::int
+		  AND ($2::uuid IS NULL OR user_id = $2)
+		GROUP BY usage_date, user_id
+		ORDER BY usage_date DESC, SUM(cost_usd) DESC
+	`
+	rows, err := s.db.QueryContext(ctx, query, days, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query daily usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := []AIDailyUsage{}
+	for rows.Next() {
+		var u AIDailyUsage
+		if err := rows.Scan(&u.Date, &u.UserID, &u.Requests, &u.Failures, &u.InputTokens, &u.OutputTokens, &u.CostUSD); err != nil {
+			continue
+		}
+		u.CostUSD = roundTo(u.CostUSD, 4)
+		usage = append(usage, u)
+	}
+	return usage, nil
+}
+
+// ListBudgets returns every budget with this month's usage against it
+func (s *AIService) ListBudgets(ctx context.Context) ([]AIUsageBudget, error) {
+	return s.queryBudgets(ctx, ``)
+}
+
+// SetBudget creates or replaces the budget for req.UserID (nil = installation)
+func (s *AIService) SetBudget(ctx context.Context, req AIUsageBudgetRequest) (*AIUsageBudget, error) {
+	if req.MonthlyTokens == nil && req.MonthlyCostUSD == nil {
+		return nil, fmt.Errorf("monthly_tokens or monthly_cost_usd is required")
+	}
+	if (req.MonthlyTokens != nil && *req.MonthlyTokens <= 0) || (req.MonthlyCostUSD != nil && *req.MonthlyCostUSD <= 0) {
+		return nil, fmt.Errorf("budgets must be positive")
+	}
+
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO ai_usage_budgets (user_id, monthly_tokens, monthly_cost_usd)
+		VALUES ($1, $2, $3)
+		ON CONFLICT ((COALESCE(user_id, '00000000-0000-0000-0000-000000000000'::uuid))) DO UPDATE SET
+			monthly_tokens = EXCLUDED.monthly_tokens, monthly_cost_usd = EXCLUDED.monthly_cost_usd
+		RETURNING id
+	`, req.UserID, req.MonthlyTokens, req.MonthlyCostUSD).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "foreign key") {
+			return nil, fmt.Errorf("user not found")
+		}
+		return nil, fmt.Errorf("failed to save budget: %w", err)
+	}
+
+	budgets, err := s.queryBudgets(ctx, `WHERE b.id = $1`, id)
+	if err != nil || len(budgets) == 0 {
+		return nil, fmt.Errorf("failed to load budget: %v", err)
+	}
+	return &budgets[0], nil
+}
+
+// DeleteBudget removes a budget
+func (s *AIService) DeleteBudget(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM ai_usage_budgets WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete budget: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("budget not found")
+	}
+	return nil
+}
+
+// queryBudgets loads budgets with month-to-date usage; an installation budget
+// counts every user's usage
+func (s *AIService) queryBudgets(ctx context.Context, where string, args ...interface{}) ([]AIUsageBudget, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.id, b.user_id, b.monthly_tokens, b.monthly_cost_usd::float8,
+		       COALESCE(SUM(u.input_tokens + u.output_tokens), 0), COALESCE(SUM(u.cost_usd), 0)::float8
+		FROM ai_usage_budgets b
+		LEFT JOIN ai_token_usage u
+		       ON u.usage_date >= date_trunc('month', NOW() AT TIME ZONE 'Asia/Taipei')::date
+		      AND (b.user_id IS NULL OR u.user_id = b.user_id)
+		`+where+`
+		GROUP BY b.id
+		ORDER BY b.user_id NULLS FIRST
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query budgets: %w", err)
+	}
+	defer rows.Close()
+
+	budgets := []AIUsageBudget{}
+	for rows.Next() {
+		var b AIUsageBudget
+		var userID uuid.NullUUID
+		var tokens sql.NullInt64
+		var cost sql.NullFloat64
+		if err := rows.Scan(&b.ID, &userID, &tokens, &cost, &b.MonthTokens, &b.MonthCostUSD); err != nil {
+			continue
+		}
+		if userID.Valid {
+			b.UserID = &userID.UUID
+		}
+		if tokens.Valid {
+			b.MonthlyTokens = &tokens.Int64
+			b.Exceeded = b.MonthTokens >= tokens.Int64
+		}
+		if cost.Valid {
+			b.MonthlyCostUSD = &cost.Float64
+			b.Exceeded = b.Exceeded || b.MonthCostUSD >= cost.Float64
+		}
+		b.MonthCostUSD = roundTo(b.MonthCostUSD, 4)
+		budgets = append(budgets, b)
+	}
+	return budgets, nil
+}
@@ -0,0 +1,434 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AlertEmailChannel emails triggered alerts over SMTP
+type AlertEmailChannel struct {
+	db       *database.DB
+	host     string
+	port     string
+	username string
+	password string
+	from     string
+}
+
+// AlertEmailSettings is a user's email notification settings
+type AlertEmailSettings struct {
+	Enabled      bool       `json:"enabled"`
+	Email        string     `json:"email,omitempty"` // Empty sends to the account email
+	AccountEmail string     `json:"account_email"`
+	Severities   []string   `json:"severities"`
+	AlertTypes   []string   `json:"alert_types"` // Empty = every type
+	Configured   bool       `json:"configured"`  // SMTP is set up on the server
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+}
+
+// AlertEmailSettingsRequest updates email settings; omitted fields are unchanged
+type AlertEmailSettingsRequest struct {
+	Enabled    *bool    `json:"enabled"`
+	Email      *string  `json:"email"`
+	Severities []string `json:"severities"`
+	AlertTypes []string `json:"alert_types"`
+}
+
+// NewAlertEmailChannel reads SMTP_HOST, SMTP_PORT (default 587), SMTP_USERNAME,
+// SMTP_PASSWORD and SMTP_FROM
+func NewAlertEmailChannel(db *database.DB) *AlertEmailChannel {
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+	return &AlertEmailChannel{
+		db:       db,
+		host:     os.Getenv("SMTP_HOST"),
+		port:     port,
+		username: os.Getenv("SMTP_USERNAME"),
+		password: os.Getenv("SMTP_PASSWORD"),
+		from:     os.Getenv("SMTP_FROM"),
+	}
+}
+
+func (c *AlertEmailChannel) Name() string { return "email" }
+
+func (c *AlertEmailChannel) Configured() bool {
+	return c.host != "" && c.from != ""
+}
+
+// Subscribers returns the users with email notifications enabled
+func (c *AlertEmailChannel) Subscribers(ctx context.Context) ([]AlertSubscriber, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT s.user_id, COALESCE(NULLIF(s.email, ''), u.email), s.severities, s.alert_types, s.created_at
+		FROM alert_email_settings s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.enabled
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email settings: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []AlertSubscriber
+	for rows.Next() {
+		var sub AlertSubscriber
+		if err := rows.Scan(&sub.UserID, &sub.Target, pq.Array(&sub.Severities), pq.Array(&sub.AlertTypes), &sub.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan email settings: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// GetSettings returns a user's email settings; users who never saved any have
+// notifications disabled
+func (c *AlertEmailChannel) GetSettings(ctx context.Context, userID uuid.UUID) (*AlertEmailSettings, error) {
+	settings := AlertEmailSettings{
+		Severities: []string{string(AlertSeverityWarning), string(AlertSeverityCritical)},
+		AlertTypes: []string{},
+		Configured: c.Configured(),
+	}
+	var email sql.NullString
+	var enabled sql.NullBool
+	var severities, alertTypes []string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT u.email, s.email, s.enabled, s.severities, s.alert_types, s.updated_at
+		FROM users u
+		LEFT JOIN alert_email_settings s ON s.user_id = u.id
+		WHERE u.id = $1
+	`, userID).Scan(&settings.AccountEmail, &email, &enabled, pq.Array(&severities), pq.Array(&alertTypes), &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("user not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query email settings: %w", err)
+	}
+
+	if enabled.Valid {
+		settings.Enabled = enabled.Bool
+		settings.Email = email.String
+		settings.Severities = severities
+		if alertTypes != nil {
+			settings.AlertTypes = alertTypes
+		}
+	}
+	return &settings, nil
+}
+
+// UpdateSettings saves a user's email settings
+func (c *AlertEmailChannel) UpdateSettings(ctx context.Context, userID uuid.UUID, req AlertEmailSettingsRequest) (*AlertEmailSettings, error) {
+	settings, err := c.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := true // Saving settings opts in unless told otherwise
+	if settings.UpdatedAt != nil {
+		enabled = settings.Enabled
+	}
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	email := settings.Email
+	if req.Email != nil {
+		email = strings.TrimSpace(*req.Email)
+		if email != "" {
+			addr, err := mail.ParseAddress(email)
+			if err != nil {
+				return nil, fmt.Errorf("invalid email address %q", email)
+			}
+			email = addr.Address
+		}
+	}
+	severities, alertTypes := settings.Severities, settings.AlertTypes
+	if req.Severities != nil {
+		severities = req.Severities
+	}
+	if req.AlertTypes != nil {
+		alertTypes = req.AlertTypes
+	}
+	if severities, alertTypes, err = normalizeAlertFilters(severities, alertTypes); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.db.ExecContext(ctx, `
+		INSERT INTO alert_email_settings (user_id, email, enabled, severities, alert_types)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			email = EXCLUDED.email,
+			enabled = EXCLUDED.enabled,
+			severities = EXCLUDED.severities,
+			alert_types = EXCLUDED.alert_types
+	`, userID, email, enabled, pq.Array(severities), pq.Array(alertTypes)); err != nil {
+		return nil, fmt.Errorf("failed to save email settings: %w", err)
+	}
+	return c.GetSettings(ctx, userID)
+}
+
+// SendTest emails a sample alert to the user's address, whether or not
+// notifications are enabled
+func (c *AlertEmailChannel) SendTest(ctx context.Context, userID uuid.UUID) error {
+	if !c.Configured() {
+		return fmt.Errorf("email notifications are not configured (set SMTP_HOST and SMTP_FROM)")
+	}
+	settings, err := c.GetSettings(ctx, userID)
+	if err != nil {
+		return err
+	}
+	to := settings.Email
+	if to == "" {
+		to = settings.AccountEmail
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"current_volume": 52000000, "volume_ratio": 3.2})
+	alert := &StockAlert{
+		ID:             "test",
+		Symbol:         "2330",
+		AlertType:      AlertTypeVolumeSpike,
+		Severity:       AlertSeverityWarning,
+		Title:          "2330 成交量異常 (測試)",
+		Message:        "這是一封測試通知，確認警示郵件可以正常寄達。",
+		Data:           data,
+		TriggeredAt:    time.Now(),
+		ReferencePrice: 1050,
+	}
+	return c.Send(ctx, &AlertSubscriber{UserID: userID, Target: to}, alert)
+}
+
+// Send emails one alert to the subscriber
+func (c *AlertEmailChannel) Send(ctx context.Context, sub *AlertSubscriber, alert *StockAlert) error {
+	var body bytes.Buffer
+	if err := alertEmailTemplate.Execute(&body, c.templateData(alert)); err != nil {
+		return fmt.Errorf("failed to render email: %w", err)
+	}
+	subject := fmt.Sprintf("[PSM %s] %s", alertSeverityLabels[alert.Severity], alert.Title)
+	return c.sendMail(ctx, sub.Target, subject, body.String())
+}
+
+// SendBatch emails several alerts, e.g. those held by a schedule, as one list
+func (c *AlertEmailChannel) SendBatch(ctx context.Context, sub *AlertSubscriber, alerts []StockAlert) error {
+	items := make([]alertEmailData, len(alerts))
+	for i := range alerts {
+		items[i] = c.templateData(&alerts[i])
+	}
+	var body bytes.Buffer
+	if err := alertEmailBatchTemplate.Execute(&body, items); err != nil {
+		return fmt.Errorf("failed to render email: %w", err)
+	}
+	subject := fmt.Sprintf("[PSM] %d 則警示摘要", len(alerts))
+	return c.sendMail(ctx, sub.Target, subject, body.String())
+}
+
+var alertSeverityLabels = map[AlertSeverity]string{
+	AlertSeverityInfo:     "提示",
+	AlertSeverityWarning:  "警告",
+	AlertSeverityCritical: "嚴重",
+}
+
+var alertSeverityColors = map[AlertSeverity]string{
+	AlertSeverityInfo:     "#1976d2",
+	AlertSeverityWarning:  "#f57c00",
+	AlertSeverityCritical: "#d32f2f",
+}
+
+type alertEmailField struct {
+	Name  string
+	Value string
+}
+
+type alertEmailData struct {
+	Alert         *StockAlert
+	SeverityLabel string
+	SeverityColor string
+	TriggeredAt   string
+	Fields        []alertEmailField
+	SymbolURL     string
+}
+
+func (c *AlertEmailChannel) templateData(alert *StockAlert) alertEmailData {
+	d := alertEmailData{
+		Alert:         alert,
+		SeverityLabel: alertSeverityLabels[alert.Severity],
+		SeverityColor: alertSeverityColors[alert.Severity],
+		TriggeredAt:   alert.TriggeredAt.In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02 15:04"),
+		SymbolURL:     alertSymbolURL(alert.Symbol),
+	}
+	if alert.ReferencePrice > 0 {
+		d.Fields = append(d.Fields, alertEmailField{"reference_price", fmt.Sprintf("%.2f", alert.ReferencePrice)})
+	}
+
+	// The alert's structured data, minus internal identifiers
+	var data map[string]interface{}
+	if json.Unmarshal(alert.Data, &data) == nil {
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			if !strings.HasSuffix(k, "_id") {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			value := fmt.Sprint(data[k])
+			if f, ok := data[k].(float64); ok {
+				value = formatAlertValue(f)
+			}
+			d.Fields = append(d.Fields, alertEmailField{k, value})
+		}
+	}
+	return d
+}
+
+// formatAlertValue prints whole numbers without a fraction and others with two decimals
+func formatAlertValue(f float64) string {
+	if f == float64(int64(f)) {
+		return fmt.Sprintf("%d", int64(f))
+	}
+	return fmt.Sprintf("%.2f", f)
+}
+
+var alertEmailTemplate = template.Must(template.New("alert").Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f5f5f5;font-family:-apple-system,'Segoe UI','Noto Sans TC',sans-serif;color:#212121">
+<div style="max-width:560px;margin:0 auto;background:#ffffff;border-radius:8px;overflow:hidden">
+  <div style="background:{{.SeverityColor}};color:#ffffff;padding:16px 24px">
+    <div style="font-size:13px">{{.SeverityLabel}} · {{.Alert.AlertType}}</div>
+    <div style="font-size:20px;font-weight:bold;margin-top:4px">{{.Alert.Title}}</div>
+  </div>
+  <div style="padding:24px">
+    <p style="margin:0 0 16px;line-height:1.6">{{.Alert.Message}}</p>
+    <table style="width:100%;border-collapse:collapse;font-size:14px">
+      <tr><td style="padding:6px 0;color:#757575">symbol</td><td style="padding:6px 0;text-align:right">{{.Alert.Symbol}}</td></tr>
+      <tr><td style="padding:6px 0;color:#757575">triggered_at</td><td style="padding:6px 0;text-align:right">{{.TriggeredAt}}</td></tr>
+      {{- range .Fields}}
+      <tr><td style="padding:6px 0;color:#757575">{{.Name}}</td><td style="padding:6px 0;text-align:right">{{.Value}}</td></tr>
+      {{- end}}
+    </table>
+    {{- if .Alert.AIExplanation}}
+    <p style="margin:16px 0 0;padding:12px;background:#f5f5f5;border-radius:4px;line-height:1.6;font-size:14px">{{.Alert.AIExplanation}}</p>
+    {{- end}}
+    <p style="margin:24px 0 0"><a href="{{.SymbolURL}}" style="display:inline-block;padding:10px 20px;background:#1976d2;color:#ffffff;text-decoration:none;border-radius:4px">查看 {{.Alert.Symbol}}</a></p>
+  </div>
+  <div style="padding:12px 24px;font-size:12px;color:#9e9e9e;border-top:1px solid #eeeeee">PSM 警示通知 · 可於警示設定中調整或停用郵件通知</div>
+</div>
+</body>
+</html>
+`))
+
+var alertEmailBatchTemplate = template.Must(template.New("alert_batch").Parse(`<!DOCTYPE html>
+<html>
+<body style="margin:0;padding:24px;background:#f5f5f5;font-family:-apple-system,'Segoe UI','Noto Sans TC',sans-serif;color:#212121">
+<div style="max-width:560px;margin:0 auto;background:#ffffff;border-radius:8px;overflow:hidden">
+  <div style="background:#455a64;color:#ffffff;padding:16px 24px">
+    <div style="font-size:13px">通知時段外累積的警示</div>
+    <div style="font-size:20px;font-weight:bold;margin-top:4px">{{len .}} 則警示摘要</div>
+  </div>
+  <div style="padding:8px 24px 24px">
+    {{- range .}}
+    <div style="padding:16px 0;border-bottom:1px solid #eeeeee">
+      <div style="font-size:12px;color:{{.SeverityColor}}">{{.SeverityLabel}} · {{.Alert.AlertType}} · {{.TriggeredAt}}</div>
+      <div style="font-size:16px;font-weight:bold;margin-top:4px"><a href="{{.SymbolURL}}" style="color:#212121;text-decoration:none">{{.Alert.Title}}</a></div>
+      <div style="margin-top:4px;line-height:1.6;font-size:14px">{{.Alert.Message}}</div>
+    </div>
+    {{- end}}
+  </div>
+  <div style="padding:12px 24px;font-size:12px;color:#9e9e9e;border-top:1px solid #eeeeee">PSM 警示通知 · 可於警示設定中調整通知時段</div>
+</div>
+</body>
+</html>
+`))
+
+// sendMail delivers an HTML email. Port 465 uses implicit TLS; other ports
+// upgrade with STARTTLS when the server offers it.
+func (c *AlertEmailChannel) sendMail(ctx context.Context, to, subject, htmlBody string) error {
+	from, err := mail.ParseAddress(c.from)
+	if err != nil {
+		return fmt.Errorf("invalid SMTP_FROM: %w", err)
+	}
+	rcpt, err := mail.ParseAddress(to)
+	if err != nil {
+		return fmt.Errorf("%w: invalid recipient %q", ErrAlertUndeliverable, to)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", from.String())
+	fmt.Fprintf(&msg, "To: %s\r\n", rcpt.String())
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/html; charset=UTF-8\r\n")
+	msg.WriteString("Content-Transfer-Encoding: base64\r\n\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(htmlBody))
+	for len(encoded) > 76 {
+		msg.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	msg.WriteString(encoded + "\r\n")
+
+	addr := net.JoinHostPort(c.host, c.port)
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	if c.port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: c.host})
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("SMTP connection failed: %w", err)
+	}
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	client, err := smtp.NewClient(conn, c.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake failed: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && c.port != "465" {
+		if err := client.StartTLS(&tls.Config{ServerName: c.host}); err != nil {
+			return fmt.Errorf("SMTP STARTTLS failed: %w", err)
+		}
+	}
+	if c.username != "" {
+		if err := client.Auth(smtp.PlainAuth("", c.username, c.password, c.host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("SMTP MAIL FROM rejected: %w", err)
+	}
+	if err := client.Rcpt(rcpt.Address); err != nil {
+		return fmt.Errorf("SMTP RCPT TO rejected: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP DATA rejected: %w", err)
+	}
+	if _, err := w.Write(msg.Bytes()); err != nil {
+		return fmt.Errorf("SMTP write failed: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected the email: %w", err)
+	}
+	return client.Quit()
+}
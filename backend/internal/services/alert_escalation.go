@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// How an escalation policy delivers alerts of a severity
+const (
+	AlertEscalationImmediate = "immediate" // Every enabled channel at once
+	AlertEscalationHourly    = "hourly"    // One digest per channel an hour after the first alert in it
+	AlertEscalationInApp     = "in_app"    // Not pushed
+)
+
+// alertEscalationDigestDelay is how long the hourly digest collects alerts
+const alertEscalationDigestDelay = time.Hour
+
+// AlertEscalationPolicy routes a user's pushed alerts by severity. When
+// enabled it replaces the channels' severity filters.
+type AlertEscalationPolicy struct {
+	Enabled   bool       `json:"enabled"`
+	Critical  string     `json:"critical"`
+	Warning   string     `json:"warning"`
+	Info      string     `json:"info"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// AlertEscalationPolicyRequest updates a policy; omitted fields are unchanged
+type AlertEscalationPolicyRequest struct {
+	Enabled  *bool   `json:"enabled"`
+	Critical *string `json:"critical"`
+	Warning  *string `json:"warning"`
+	Info     *string `json:"info"`
+}
+
+// mode returns how the policy delivers a severity
+func (p *AlertEscalationPolicy) mode(severity AlertSeverity) string {
+	switch severity {
+	case AlertSeverityCritical:
+		return p.Critical
+	case AlertSeverityWarning:
+		return p.Warning
+	}
+	return p.Info
+}
+
+// pushed returns the severities the policy pushes at all
+func (p *AlertEscalationPolicy) pushed() []string {
+	severities := []string{}
+	for _, sev := range []AlertSeverity{AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical} {
+		if p.mode(sev) != AlertEscalationInApp {
+			severities = append(severities, string(sev))
+		}
+	}
+	return severities
+}
+
+// parseEscalationMode validates a delivery mode
+func parseEscalationMode(field, mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case AlertEscalationImmediate, AlertEscalationHourly, AlertEscalationInApp:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid %s mode %q (expected immediate, hourly or in_app)", field, mode)
+}
+
+// GetEscalationPolicy returns a user's escalation policy; without one the
+// channels' own severity filters apply, shown as a disabled default policy
+func (s *AlertNotificationService) GetEscalationPolicy(ctx context.Context, userID uuid.UUID) (*AlertEscalationPolicy, error) {
+	p := &AlertEscalationPolicy{
+		Critical: AlertEscalationImmediate,
+		Warning:  AlertEscalationHourly,
+		Info:     AlertEscalationInApp,
+	}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT enabled, critical, warning, info, updated_at
+		FROM alert_escalation_policies
+		WHERE user_id = $1
+	`, userID).Scan(&p.Enabled, &p.Critical, &p.Warning, &p.Info, &p.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query escalation policy: %w", err)
+	}
+	return p, nil
+}
+
+// UpdateEscalationPolicy saves a user's escalation policy
+func (s *AlertNotificationService) UpdateEscalationPolicy(ctx context.Context, userID uuid.UUID, req AlertEscalationPolicyRequest) (*AlertEscalationPolicy, error) {
+	p, err := s.GetEscalationPolicy(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.UpdatedAt == nil {
+		p.Enabled = true // Saving a policy turns it on unless told otherwise
+	}
+	if req.Enabled != nil {
+		p.Enabled = *req.Enabled
+	}
+	if req.Critical != nil {
+		if p.Critical, err = parseEscalationMode("critical", *req.Critical); err != nil {
+			return nil, err
+		}
+	}
+	if req.Warning != nil {
+		if p.Warning, err = parseEscalationMode("warning", *req.Warning); err != nil {
+			return nil, err
+		}
+	}
+	if req.Info != nil {
+		if p.Info, err = parseEscalationMode("info", *req.Info); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO alert_escalation_policies (user_id, enabled, critical, warning, info)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			critical = EXCLUDED.critical,
+			warning = EXCLUDED.warning,
+			info = EXCLUDED.info
+	`, userID, p.Enabled, p.Critical, p.Warning, p.Info); err != nil {
+		return nil, fmt.Errorf("failed to save escalation policy: %w", err)
+	}
+	return s.GetEscalationPolicy(ctx, userID)
+}
+
+// activeEscalationPolicies returns the enabled policies by user
+func (s *AlertNotificationService) activeEscalationPolicies(ctx context.Context) (map[uuid.UUID]*AlertEscalationPolicy, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, critical, warning, info FROM alert_escalation_policies WHERE enabled
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query escalation policies: %w", err)
+	}
+	defer rows.Close()
+
+	policies := map[uuid.UUID]*AlertEscalationPolicy{}
+	for rows.Next() {
+		var userID uuid.UUID
+		p := &AlertEscalationPolicy{Enabled: true}
+		if err := rows.Scan(&userID, &p.Critical, &p.Warning, &p.Info); err != nil {
+			continue
+		}
+		policies[userID] = p
+	}
+	return policies, rows.Err()
+}
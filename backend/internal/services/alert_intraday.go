@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// cachedQuoteFetcher wraps fetchQuotes so one intraday scan requests each
+// symbol once, however many checks need it
+func cachedQuoteFetcher(fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error) {
+	var mu sync.Mutex
+	cache := map[string]*RealtimeQuote{}
+	return func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error) {
+		mu.Lock()
+		var missing []string
+		for _, sym := range symbols {
+			if _, ok := cache[sym]; !ok {
+				missing = append(missing, sym)
+			}
+		}
+		mu.Unlock()
+
+		if len(missing) > 0 {
+			quotes, err := fetchQuotes(ctx, missing)
+			if err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			for _, sym := range missing {
+				cache[sym] = nil // Not quoted; don't ask again
+			}
+			for _, q := range quotes {
+				if q != nil {
+					cache[q.Symbol] = q
+				}
+			}
+			mu.Unlock()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		quotes := make([]*RealtimeQuote, 0, len(symbols))
+		for _, sym := range symbols {
+			if q := cache[sym]; q != nil {
+				quotes = append(quotes, q)
+			}
+		}
+		return quotes, nil
+	}
+}
+
+// DetectIntraday runs the volume spike and 52-week detectors on realtime
+// quotes of the held and watched symbols. Volume is the day's running total
+// against the 20-day average, so a spike reported here is a floor on the
+// final ratio. The alerts share their dedup keys with the end-of-day scan,
+// which therefore won't repeat them; a symbol raises at most one intraday
+// detector alert per cooldown.
+func (s *AlertService) DetectIntraday(ctx context.Context, fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) ([]VolumeAnalysis, []PriceAnalysis, int, error) {
+	symbols, err := s.scopeSymbols(ctx, AlertScanScopeTracked)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	spikes, breakouts := []VolumeAnalysis{}, []PriceAnalysis{}
+	if len(symbols) == 0 {
+		return spikes, breakouts, 0, nil
+	}
+
+	quotes, err := fetchQuotes(ctx, symbols)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to fetch quotes: %w", err)
+	}
+
+	// Daily history up to yesterday
+	taipei := time.FixedZone("Asia/Taipei", 8*3600)
+	today := time.Now().In(taipei).Format("2006-01-02")
+	type history struct {
+		avgVolume, high52, low52, prevClose float64
+	}
+	hist := make(map[string]history, len(symbols))
+	rows, err := s.db.QueryContext(ctx, `
+		WITH d AS (
+			SELECT symbol, volume, high, low, close, timestamp,
+			       ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp DESC) AS rn
+			FROM stock_ohlcv
+			WHERE symbol = ANY($1) AND timestamp >= NOW() - INTERVAL '365 days' AND timestamp < $2::date
+		)
+		SELECT symbol,
+		       COALESCE(AVG(volume) FILTER (WHERE rn <= 20), 0)::float8,
+		       COALESCE(MAX(high), 0)::float8,
+		       COALESCE(MIN(low), 0)::float8,
+		       COALESCE(MAX(close) FILTER (WHERE rn = 1), 0)::float8
+		FROM d
+		GROUP BY symbol
+	`, pq.Array(symbols), today)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to query daily history: %w", err)
+	}
+	for rows.Next() {
+		var sym string
+		var h history
+		if err := rows.Scan(&sym, &h.avgVolume, &h.high52, &h.low52, &h.prevClose); err == nil {
+			hist[sym] = h
+		}
+	}
+	rows.Close()
+
+	thresholds := s.Thresholds(ctx)
+	nearThreshold := thresholds.Price52wProximityPct / 100
+	suppressed := 0
+
+	for _, q := range quotes {
+		h, ok := hist[q.Symbol]
+		price := q.Price.InexactFloat64()
+		if !ok || price <= 0 {
+			continue
+		}
+		barDate := time.Now()
+		if !q.TradeTime.IsZero() {
+			barDate = q.TradeTime
+		}
+
+		var candidates []StockAlert
+		var spike *VolumeAnalysis
+		var breakout *PriceAnalysis
+
+		if h.avgVolume > 0 {
+			ratio := float64(q.Volume) / h.avgVolume
+			if ratio >= thresholds.VolumeSpikeRatio {
+				spike = &VolumeAnalysis{
+					Symbol:          q.Symbol,
+					CurrentVolume:   q.Volume,
+					AvgVolume20Days: int64(h.avgVolume),
+					VolumeRatio:     ratio,
+					IsSpike:         true,
+					SpikeThreshold:  thresholds.VolumeSpikeRatio,
+					Source:          "intraday",
+				}
+				severity := AlertSeverityInfo
+				if ratio >= thresholds.VolumeWarningRatio {
+					severity = AlertSeverityWarning
+				}
+				if ratio >= thresholds.VolumeCriticalRatio {
+					severity = AlertSeverityCritical
+				}
+				data, _ := json.Marshal(spike)
+				candidates = append(candidates, StockAlert{
+					Symbol:          q.Symbol,
+					AlertType:       AlertTypeVolumeSpike,
+					Severity:        severity,
+					Title:           fmt.Sprintf("%s 盤中成交量異常", q.Symbol),
+					Message:         fmt.Sprintf("盤中累計成交量已達20日均量的 %.1f 倍", ratio),
+					Data:            data,
+					ReferenceVolume: q.Volume,
+					ThresholdValue:  thresholds.VolumeSpikeRatio,
+					DedupKey:        alertDedupKey(q.Symbol, AlertTypeVolumeSpike, "", barDate),
+				})
+			}
+		}
+
+		isNearHigh := h.high52 > 0 && (h.high52-price)/h.high52 <= nearThreshold
+		isNearLow := h.low52 > 0 && (price-h.low52)/h.low52 <= nearThreshold
+		if isNearHigh || isNearLow {
+			breakout = &PriceAnalysis{
+				Symbol:           q.Symbol,
+				CurrentPrice:     price,
+				PreviousClose:    h.prevClose,
+				High52Week:       h.high52,
+				Low52Week:        h.low52,
+				IsNear52WeekHigh: isNearHigh,
+				IsNear52WeekLow:  isNearLow,
+				Source:           "intraday",
+			}
+			if h.prevClose > 0 {
+				breakout.Change = price - h.prevClose
+				breakout.ChangePercent = breakout.Change / h.prevClose * 100
+			}
+			data, _ := json.Marshal(breakout)
+			if isNearHigh {
+				candidates = append(candidates, StockAlert{
+					Symbol:         q.Symbol,
+					AlertType:      AlertTypePriceBreakout,
+					Severity:       AlertSeverityInfo,
+					Title:          fmt.Sprintf("%s 盤中接近52週新高", q.Symbol),
+					Message:        fmt.Sprintf("盤中價格 %.2f 接近52週高點 %.2f", price, h.high52),
+					Data:           data,
+					ReferencePrice: price,
+					DedupKey:       alertDedupKey(q.Symbol, AlertTypePriceBreakout, "high", barDate),
+				})
+			}
+			if isNearLow {
+				candidates = append(candidates, StockAlert{
+					Symbol:         q.Symbol,
+					AlertType:      AlertTypePriceBreakout,
+					Severity:       AlertSeverityWarning,
+					Title:          fmt.Sprintf("%s 盤中接近52週新低", q.Symbol),
+					Message:        fmt.Sprintf("盤中價格 %.2f 接近52週低點 %.2f", price, h.low52),
+					Data:           data,
+					ReferencePrice: price,
+					DedupKey:       alertDedupKey(q.Symbol, AlertTypePriceBreakout, "low", barDate),
+				})
+			}
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		if s.inIntradayCooldown(ctx, q.Symbol) {
+			suppressed += len(candidates)
+			continue
+		}
+		raisedSpike, raisedBreakout := false, false
+		for i := range candidates {
+			alert := &candidates[i]
+			if err := s.CreateAlert(ctx, alert); err != nil {
+				if err == ErrAlertDuplicate {
+					suppressed++
+				}
+				continue
+			}
+			if alert.AlertType == AlertTypeVolumeSpike {
+				raisedSpike = true
+			} else {
+				raisedBreakout = true
+			}
+		}
+		if raisedSpike {
+			spikes = append(spikes, *spike)
+		}
+		if raisedBreakout {
+			breakouts = append(breakouts, *breakout)
+		}
+	}
+	return spikes, breakouts, suppressed, nil
+}
+
+// inIntradayCooldown reports whether the symbol raised an intraday detector
+// alert within the cooldown
+func (s *AlertService) inIntradayCooldown(ctx context.Context, symbol string) bool {
+	if s.intradayCooldown <= 0 {
+		return false
+	}
+	var recent bool
+	s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM stock_alerts
+			WHERE symbol = $1 AND data->>'source' = 'intraday'
+			  AND triggered_at > NOW() - make_interval(secs => $2::float8)
+		)
+	`, symbol, s.intradayCooldown.Seconds()).Scan(&recent)
+	return recent
+}
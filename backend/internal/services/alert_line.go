@@ -0,0 +1,339 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// lineMessageMaxRunes is LINE Notify's message length limit
+const lineMessageMaxRunes = 1000
+
+// AlertLineChannel pushes triggered alerts and AI daily digests to LINE via
+// each user's LINE Notify token
+type AlertLineChannel struct {
+	db         *database.DB
+	client     *http.Client
+	apiURL     string
+	maxPerHour int // Alerts per user per hour; the rest wait for the next hour
+}
+
+// AlertLineSettings is a user's LINE Notify settings; the token is never returned
+type AlertLineSettings struct {
+	Registered bool       `json:"registered"`
+	Enabled    bool       `json:"enabled"`
+	Severities []string   `json:"severities"`
+	AlertTypes []string   `json:"alert_types"` // Empty = every type
+	Digest     bool       `json:"digest"`      // Also send the AI daily digest
+	LastError  string     `json:"last_error,omitempty"`
+	MaxPerHour int        `json:"max_per_hour"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// AlertLineSettingsRequest registers a token or updates settings; omitted
+// fields are unchanged
+type AlertLineSettingsRequest struct {
+	Token      *string  `json:"token"`
+	Enabled    *bool    `json:"enabled"`
+	Severities []string `json:"severities"`
+	AlertTypes []string `json:"alert_types"`
+	Digest     *bool    `json:"digest"`
+}
+
+// NewAlertLineChannel reads LINE_NOTIFY_URL (default the LINE Notify API) and
+// LINE_NOTIFY_MAX_PER_HOUR (alerts per user, default 20)
+func NewAlertLineChannel(db *database.DB) *AlertLineChannel {
+	apiURL := os.Getenv("LINE_NOTIFY_URL")
+	if apiURL == "" {
+		apiURL = "https://notify-api.line.me/api/notify"
+	}
+	maxPerHour := 20
+	if v, err := strconv.Atoi(os.Getenv("LINE_NOTIFY_MAX_PER_HOUR")); err == nil && v > 0 {
+		maxPerHour = v
+	}
+	return &AlertLineChannel{
+		db:         db,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		apiURL:     apiURL,
+		maxPerHour: maxPerHour,
+	}
+}
+
+func (c *AlertLineChannel) Name() string { return "line" }
+
+// Configured is always true; each user brings their own token
+func (c *AlertLineChannel) Configured() bool { return true }
+
+// Subscribers returns the users with LINE notifications enabled
+func (c *AlertLineChannel) Subscribers(ctx context.Context) ([]AlertSubscriber, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT user_id, token, severities, alert_types, created_at
+		FROM alert_line_settings
+		WHERE enabled
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query LINE settings: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []AlertSubscriber
+	for rows.Next() {
+		var sub AlertSubscriber
+		if err := rows.Scan(&sub.UserID, &sub.Target, pq.Array(&sub.Severities), pq.Array(&sub.AlertTypes), &sub.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan LINE settings: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// GetSettings returns a user's LINE settings
+func (c *AlertLineChannel) GetSettings(ctx context.Context, userID uuid.UUID) (*AlertLineSettings, error) {
+	settings := AlertLineSettings{
+		Severities: []string{string(AlertSeverityWarning), string(AlertSeverityCritical)},
+		AlertTypes: []string{},
+		Digest:     true,
+		MaxPerHour: c.maxPerHour,
+	}
+	var lastError sql.NullString
+	var alertTypes []string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT enabled, severities, alert_types, digest, last_error, updated_at
+		FROM alert_line_settings
+		WHERE user_id = $1
+	`, userID).Scan(&settings.Enabled, pq.Array(&settings.Severities), pq.Array(&alertTypes), &settings.Digest,
+		&lastError, &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query LINE settings: %w", err)
+	}
+
+	settings.Registered = true
+	settings.LastError = lastError.String
+	if alertTypes != nil {
+		settings.AlertTypes = alertTypes
+	}
+	return &settings, nil
+}
+
+// UpdateSettings registers a token or changes a user's LINE settings. A new
+// token re-enables notifications unless enabled is given.
+func (c *AlertLineChannel) UpdateSettings(ctx context.Context, userID uuid.UUID, req AlertLineSettingsRequest) (*AlertLineSettings, error) {
+	settings, err := c.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	token := ""
+	if req.Token != nil {
+		if token = strings.TrimSpace(*req.Token); token == "" {
+			return nil, fmt.Errorf("token must not be empty (delete the registration instead)")
+		}
+	} else if !settings.Registered {
+		return nil, fmt.Errorf("token is required")
+	}
+
+	enabled := settings.Enabled || token != ""
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	digest := settings.Digest
+	if req.Digest != nil {
+		digest = *req.Digest
+	}
+	severities, alertTypes := settings.Severities, settings.AlertTypes
+	if req.Severities != nil {
+		severities = req.Severities
+	}
+	if req.AlertTypes != nil {
+		alertTypes = req.AlertTypes
+	}
+	if severities, alertTypes, err = normalizeAlertFilters(severities, alertTypes); err != nil {
+		return nil, err
+	}
+
+	if _, err := c.db.ExecContext(ctx, `
+		INSERT INTO alert_line_settings (user_id, token, enabled, severities, alert_types, digest)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			token = COALESCE(NULLIF(EXCLUDED.token, ''), alert_line_settings.token),
+			enabled = EXCLUDED.enabled,
+			severities = EXCLUDED.severities,
+			alert_types = EXCLUDED.alert_types,
+			digest = EXCLUDED.digest,
+			last_error = CASE WHEN EXCLUDED.token <> '' THEN NULL ELSE alert_line_settings.last_error END
+	`, userID, token, enabled, pq.Array(severities), pq.Array(alertTypes), digest); err != nil {
+		return nil, fmt.Errorf("failed to save LINE settings: %w", err)
+	}
+	return c.GetSettings(ctx, userID)
+}
+
+// DeleteSettings removes a user's token and settings
+func (c *AlertLineChannel) DeleteSettings(ctx context.Context, userID uuid.UUID) error {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM alert_line_settings WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete LINE settings: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("LINE Notify token not found")
+	}
+	return nil
+}
+
+// SendTest sends a sample message to the user's LINE
+func (c *AlertLineChannel) SendTest(ctx context.Context, userID uuid.UUID) error {
+	token, err := c.token(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return c.post(ctx, userID, token, "\nPSM 測試通知：LINE Notify 已設定完成，之後的警示會傳送到這裡。")
+}
+
+// Send pushes one alert, unless the user reached the hourly limit
+func (c *AlertLineChannel) Send(ctx context.Context, sub *AlertSubscriber, alert *StockAlert) error {
+	var sent int
+	if err := c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM alert_deliveries
+		WHERE user_id = $1 AND channel = $2 AND status = 'sent' AND delivered_at > NOW() - INTERVAL '1 hour'
+	`, sub.UserID, c.Name()).Scan(&sent); err != nil {
+		return fmt.Errorf("failed to count LINE deliveries: %w", err)
+	}
+	if sent >= c.maxPerHour {
+		return ErrAlertRateLimited
+	}
+	return c.post(ctx, sub.UserID, sub.Target, lineAlertMessage(alert))
+}
+
+// SendBatch pushes several alerts as one message, unless the user reached the
+// hourly limit
+func (c *AlertLineChannel) SendBatch(ctx context.Context, sub *AlertSubscriber, alerts []StockAlert) error {
+	var sent int
+	if err := c.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM alert_deliveries
+		WHERE user_id = $1 AND channel = $2 AND status = 'sent' AND delivered_at > NOW() - INTERVAL '1 hour'
+	`, sub.UserID, c.Name()).Scan(&sent); err != nil {
+		return fmt.Errorf("failed to count LINE deliveries: %w", err)
+	}
+	if sent >= c.maxPerHour {
+		return ErrAlertRateLimited
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n【PSM 警示摘要】%d 則", len(alerts))
+	for i := range alerts {
+		if i == alertBatchMaxListed {
+			fmt.Fprintf(&b, "\n…另有 %d 則", len(alerts)-i)
+			break
+		}
+		fmt.Fprintf(&b, "\n・[%s] %s", alertSeverityLabels[alerts[i].Severity], alerts[i].Title)
+	}
+	return c.post(ctx, sub.UserID, sub.Target, b.String())
+}
+
+// SendDigest sends the AI daily digest if the user opted in
+func (c *AlertLineChannel) SendDigest(ctx context.Context, digest *AIDigest) (bool, error) {
+	var token string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT token FROM alert_line_settings WHERE user_id = $1 AND enabled AND digest
+	`, digest.UserID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query LINE settings: %w", err)
+	}
+
+	message := fmt.Sprintf("\n【PSM 每日摘要 %s】\n%s", digest.Date, plainDigestText(digest.Content))
+	if err := c.post(ctx, digest.UserID, token, message); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (c *AlertLineChannel) token(ctx context.Context, userID uuid.UUID) (string, error) {
+	var token string
+	err := c.db.QueryRowContext(ctx, `SELECT token FROM alert_line_settings WHERE user_id = $1`, userID).Scan(&token)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("LINE Notify token not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query LINE settings: %w", err)
+	}
+	return token, nil
+}
+
+// post sends a message with the token. A rejected token switches the user's
+// notifications off until a new token is registered.
+func (c *AlertLineChannel) post(ctx context.Context, userID uuid.UUID, token, message string) error {
+	if runes := []rune(message); len(runes) > lineMessageMaxRunes {
+		message = string(runes[:lineMessageMaxRunes-1]) + "…"
+	}
+
+	form := url.Values{"message": {message}}
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("LINE Notify request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		c.db.ExecContext(ctx, `
+			UPDATE alert_line_settings SET enabled = false, last_error = 'token rejected by LINE Notify'
+			WHERE user_id = $1
+		`, userID)
+		return fmt.Errorf("%w: LINE Notify rejected the token; register a new one", ErrAlertUndeliverable)
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return ErrAlertRateLimited
+	case resp.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("%w: LINE Notify returned status %d", ErrAlertUndeliverable, resp.StatusCode)
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return fmt.Errorf("LINE Notify returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// lineAlertMessage formats an alert as plain text. LINE Notify prefixes the
+// token's name, so the message starts on a new line.
+func lineAlertMessage(alert *StockAlert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n【%s】%s\n%s", alertSeverityLabels[alert.Severity], alert.Title, alert.Message)
+	if alert.ReferencePrice > 0 {
+		fmt.Fprintf(&b, "\n參考價 %.2f", alert.ReferencePrice)
+	}
+	b.WriteString("\n" + alertSymbolURL(alert.Symbol))
+	return b.String()
+}
+
+// plainDigestText strips the Markdown headings and emphasis of a digest for
+// plain-text channels
+func plainDigestText(content string) string {
+	lines := strings.Split(content, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			line = strings.TrimLeft(line, "# ")
+		}
+		lines[i] = strings.ReplaceAll(line, "**", "")
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,520 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/textproto"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// Alerts older than this are not sent to new or re-enabled subscribers
+	alertNotifyLookback = 24 * time.Hour
+	// A failed delivery waits this long before its first retry, doubling after
+	// each further failure up to alertNotifyMaxBackoff
+	alertNotifyBaseBackoff = time.Minute
+	alertNotifyMaxBackoff  = time.Hour
+)
+
+// ErrAlertRateLimited is returned by a channel's Send when the subscriber has
+// reached the channel's rate limit; the alert stays pending for a later pass
+var ErrAlertRateLimited = errors.New("rate limited")
+
+// alertOwnedTypes are the alert types that concern only the user in their
+// data's user_id rather than everyone holding or watching the symbol
+var alertOwnedTypes = []string{string(AlertTypeUserRule), string(AlertTypePositionLevel), string(AlertTypeScreenMatch)}
+
+// ErrAlertUndeliverable marks a Send error that retrying won't fix, e.g. a
+// revoked token or a rejected recipient
+var ErrAlertUndeliverable = errors.New("undeliverable")
+
+// AlertChannel is a notification channel for triggered alerts, e.g. email
+type AlertChannel interface {
+	Name() string
+	Configured() bool
+	// Subscribers returns the users with the channel enabled
+	Subscribers(ctx context.Context) ([]AlertSubscriber, error)
+	Send(ctx context.Context, sub *AlertSubscriber, alert *StockAlert) error
+}
+
+// alertBatchChannel is a channel that can send several alerts as one message,
+// used for the alerts a user's schedule held back
+type alertBatchChannel interface {
+	SendBatch(ctx context.Context, sub *AlertSubscriber, alerts []StockAlert) error
+}
+
+// alertDigestChannel is a channel that also delivers the AI daily digest
+type alertDigestChannel interface {
+	Name() string
+	// SendDigest sends the digest if the user opted in; sent reports whether it did
+	SendDigest(ctx context.Context, digest *AIDigest) (sent bool, err error)
+}
+
+// AlertSubscriber is a user's settings for one channel
+type AlertSubscriber struct {
+	UserID     uuid.UUID
+	Target     string    // Where the channel delivers, e.g. the email address
+	Severities []string  // Severities to send
+	AlertTypes []string  // Alert types to send; empty means all
+	Since      time.Time // Alerts triggered before the subscription are not sent
+}
+
+// AlertDelivery is one alert sent, or attempted, to a user over a channel
+type AlertDelivery struct {
+	ID          string     `json:"id"`
+	AlertID     string     `json:"alert_id"`
+	Symbol      string     `json:"symbol"`
+	Title       string     `json:"title"`
+	Channel     string     `json:"channel"`
+	Status      string     `json:"status"` // sent, retrying, failed
+	Attempts    int        `json:"attempts"`
+	Error       string     `json:"error,omitempty"`
+	NextAttempt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// AlertNotificationService pushes triggered alerts to the users they concern:
+// rule alerts to the rule's owner and all others to users holding or watching
+// the symbol, over every channel the user enabled. Position level alerts set
+// not to notify are skipped. Transient send failures are
+// retried with exponential backoff until ALERT_NOTIFY_MAX_ATTEMPTS.
+type AlertNotificationService struct {
+	db          *database.DB
+	email       *AlertEmailChannel
+	line        *AlertLineChannel
+	telegram    *AlertTelegramChannel
+	webhook     *AlertWebhookChannel
+	channels    []AlertChannel
+	interval    time.Duration
+	maxAttempts int
+	mu          sync.Mutex
+	isRunning   bool
+	stopChan    chan struct{}
+}
+
+func NewAlertNotificationService(db *database.DB) *AlertNotificationService {
+	email := NewAlertEmailChannel(db)
+	line := NewAlertLineChannel(db)
+	telegram := NewAlertTelegramChannel(db)
+	webhook := NewAlertWebhookChannel(db)
+	maxAttempts := 5
+	if v, err := strconv.Atoi(os.Getenv("ALERT_NOTIFY_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+	return &AlertNotificationService{
+		db:          db,
+		email:       email,
+		line:        line,
+		telegram:    telegram,
+		webhook:     webhook,
+		channels:    []AlertChannel{email, line, telegram, webhook},
+		interval:    time.Minute,
+		maxAttempts: maxAttempts,
+		stopChan:    make(chan struct{}),
+	}
+}
+
+// Email returns the email channel
+func (s *AlertNotificationService) Email() *AlertEmailChannel {
+	return s.email
+}
+
+// Line returns the LINE Notify channel
+func (s *AlertNotificationService) Line() *AlertLineChannel {
+	return s.line
+}
+
+// Telegram returns the Telegram bot channel
+func (s *AlertNotificationService) Telegram() *AlertTelegramChannel {
+	return s.telegram
+}
+
+// Webhooks returns the signed webhook channel
+func (s *AlertNotificationService) Webhooks() *AlertWebhookChannel {
+	return s.webhook
+}
+
+// DeliverDigest sends an AI daily digest over every channel the user opted in
+// to, returning how many channels it was sent over
+func (s *AlertNotificationService) DeliverDigest(ctx context.Context, digest *AIDigest) (int, error) {
+	sent := 0
+	var lastErr error
+	for _, ch := range s.channels {
+		dc, ok := ch.(alertDigestChannel)
+		if !ok || !ch.Configured() {
+			continue
+		}
+		ok, err := dc.SendDigest(ctx, digest)
+		if err != nil {
+			log.Printf("Alert notifier (%s): digest %s failed: %v", dc.Name(), digest.ID, err)
+			lastErr = err
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, lastErr
+}
+
+// Start launches the dispatch loop
+func (s *AlertNotificationService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.loop()
+	var names []string
+	for _, ch := range s.channels {
+		if ch.Configured() {
+			names = append(names, ch.Name())
+		}
+	}
+	log.Printf("Alert notifier started (every %v, channels: %s)", s.interval, strings.Join(names, ", "))
+}
+
+// Stop stops the dispatch loop
+func (s *AlertNotificationService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+}
+
+func (s *AlertNotificationService) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			s.DispatchPending(ctx)
+			// Queued webhook deliveries, including retries of earlier failures
+			s.webhook.DeliverDue(ctx)
+			cancel()
+		}
+	}
+}
+
+// DispatchPending sends every pending alert over each configured channel and
+// returns how many were delivered
+func (s *AlertNotificationService) DispatchPending(ctx context.Context) int {
+	schedules, err := s.activeSchedules(ctx)
+	if err != nil {
+		log.Printf("Alert notifier: %v", err)
+		return 0 // Rather than pushing during anyone's quiet hours
+	}
+	policies, err := s.activeEscalationPolicies(ctx)
+	if err != nil {
+		log.Printf("Alert notifier: %v", err)
+		return 0
+	}
+
+	delivered := 0
+	for _, ch := range s.channels {
+		if !ch.Configured() {
+			continue
+		}
+		subs, err := ch.Subscribers(ctx)
+		if err != nil {
+			log.Printf("Alert notifier (%s): %v", ch.Name(), err)
+			continue
+		}
+		for i := range subs {
+			sched, policy := schedules[subs[i].UserID], policies[subs[i].UserID]
+			if _, ok := ch.(*AlertWebhookChannel); ok {
+				sched, policy = nil, nil // Webhooks feed other systems, not people
+			}
+			delivered += s.dispatch(ctx, ch, &subs[i], sched, policy)
+		}
+	}
+	return delivered
+}
+
+// dispatch sends the subscriber's pending alerts over the channel. With a
+// schedule, alerts wait for its window (critical ones too unless allowed), and
+// those held from before the window opened go out as one batch if the
+// schedule asks for a digest. An escalation policy then decides the severities
+// pushed and collects those it delivers hourly into one batch, sent once the
+// oldest of them is an hour old.
+func (s *AlertNotificationService) dispatch(ctx context.Context, ch AlertChannel, sub *AlertSubscriber, sched *AlertNotificationSchedule, policy *AlertEscalationPolicy) int {
+	lookback := alertNotifyLookback
+	open, openedAt := true, time.Time{}
+	if sched != nil {
+		lookback = alertNotifyDeferLookback
+		open, openedAt = sched.window(time.Now())
+	}
+	if policy != nil {
+		scoped := *sub
+		scoped.Severities = policy.pushed()
+		sub = &scoped
+	}
+	alerts, err := s.pendingAlerts(ctx, ch.Name(), sub, lookback)
+	if err != nil {
+		log.Printf("Alert notifier (%s) for %s: %v", ch.Name(), sub.UserID, err)
+		return 0
+	}
+
+	var held, hourly, send []StockAlert
+	for _, a := range alerts {
+		switch {
+		case policy != nil && policy.mode(a.Severity) == AlertEscalationHourly && (sched == nil || open):
+			hourly = append(hourly, a)
+		case sched == nil:
+			send = append(send, a)
+		case !open:
+			if sched.AllowCritical && a.Severity == AlertSeverityCritical {
+				send = append(send, a)
+			}
+		case a.TriggeredAt.Before(openedAt) && sched.Digest:
+			held = append(held, a)
+		default:
+			send = append(send, a)
+		}
+	}
+
+	delivered := 0
+	if len(hourly) > 0 {
+		oldest := hourly[0].TriggeredAt
+		for _, a := range hourly[1:] {
+			if a.TriggeredAt.Before(oldest) {
+				oldest = a.TriggeredAt
+			}
+		}
+		if time.Since(oldest) >= alertEscalationDigestDelay {
+			held = append(held, hourly...)
+		}
+	}
+
+	if batcher, ok := ch.(alertBatchChannel); ok && len(held) > 1 {
+		err := batcher.SendBatch(ctx, sub, held)
+		if errors.Is(err, ErrAlertRateLimited) {
+			return 0
+		}
+		for i := range held {
+			s.recordDelivery(ctx, held[i].ID, sub.UserID, ch.Name(), err)
+		}
+		if err != nil {
+			log.Printf("Alert notifier (%s): batch of %d alerts to %s failed: %v", ch.Name(), len(held), sub.UserID, err)
+		} else {
+			delivered += len(held)
+		}
+	} else {
+		send = append(held, send...)
+	}
+
+	for i := range send {
+		err := ch.Send(ctx, sub, &send[i])
+		if errors.Is(err, ErrAlertRateLimited) {
+			break // The rest stay pending until the limit resets
+		}
+		s.recordDelivery(ctx, send[i].ID, sub.UserID, ch.Name(), err)
+		if err != nil {
+			log.Printf("Alert notifier (%s): alert %s to %s failed: %v", ch.Name(), send[i].ID, sub.UserID, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered
+}
+
+// pendingAlerts returns the subscriber's recent alerts that pass its filters
+// and have not been delivered over the channel yet, nor given up on or waiting
+// for a retry, oldest first
+func (s *AlertNotificationService) pendingAlerts(ctx context.Context, channel string, sub *AlertSubscriber, lookback time.Duration) ([]StockAlert, error) {
+	since := sub.Since
+	if cutoff := time.Now().Add(-lookback); since.Before(cutoff) {
+		since = cutoff
+	}
+
+	query := `
+		SELECT a.id, a.symbol, a.alert_type, a.severity, a.title, a.message, COALESCE(a.data, '{}'), a.triggered_at,
+		       COALESCE(a.reference_price, 0), COALESCE(a.reference_volume, 0), COALESCE(a.threshold_value, 0),
+		       COALESCE(a.ai_explanation, '')
+		FROM stock_alerts a
+		WHERE a.triggered_at >= $2
+		  AND a.snoozed_until IS NULL
+		  AND a.severity = ANY($3)
+		  AND (cardinality($4::text[]) = 0 OR a.alert_type = ANY($4))
+		  AND COALESCE(a.data->>'notify', 'true') <> 'false'
+		  AND CASE WHEN a.alert_type = ANY($5) THEN a.data->>'user_id' = $1::uuid::text
+		      ELSE a.symbol IN (
+				SELECT split_part(pc.symbol, '.', 1)
+				FROM positions_current pc
+				JOIN portfolios p ON p.id = pc.portfolio_id
+				WHERE p.user_id = $1 AND COALESCE(p.is_paper, false) = false AND pc.total_quantity > 0
+				UNION
+				SELECT i.symbol
+				FROM watchlist_items i
+				JOIN watchlists w ON w.id = i.watchlist_id
+				WHERE w.user_id = $1
+		      ) END
+		  AND NOT EXISTS (
+			SELECT 1 FROM alert_deliveries d
+			WHERE d.alert_id = a.id AND d.user_id = $1 AND d.channel = $6
+			  AND (d.status <> 'retrying' OR d.next_attempt_at > NOW())
+		  )
+		ORDER BY a.triggered_at ASC
+		LIMIT 50
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, sub.UserID, since, pq.Array(sub.Severities), pq.Array(sub.AlertTypes),
+		pq.Array(alertOwnedTypes), channel)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []StockAlert
+	for rows.Next() {
+		var a StockAlert
+		if err := rows.Scan(
+			&a.ID, &a.Symbol, &a.AlertType, &a.Severity, &a.Title, &a.Message, &a.Data, &a.TriggeredAt,
+			&a.ReferencePrice, &a.ReferenceVolume, &a.ThresholdValue, &a.AIExplanation,
+		); err != nil {
+			continue
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
+
+// recordDelivery records an attempt. A transient failure is scheduled for a
+// retry after the backoff of its attempt count; a permanent one, or the last
+// allowed attempt, is failed for good.
+func (s *AlertNotificationService) recordDelivery(ctx context.Context, alertID string, userID uuid.UUID, channel string, sendErr error) {
+	status, errMsg := "sent", ""
+	if sendErr != nil {
+		status, errMsg = "retrying", sendErr.Error()
+		if alertDeliveryPermanent(sendErr) || s.maxAttempts <= 1 {
+			status = "failed"
+		}
+	}
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO alert_deliveries (alert_id, user_id, channel, status, error, delivered_at, next_attempt_at)
+		VALUES ($1, $2, $3, $4::text, NULLIF($5::text, ''), CASE WHEN $4::text = 'sent' THEN NOW() END,
+		        CASE WHEN $4::text = 'retrying' THEN NOW() + make_interval(secs => $6::float8) END)
+		ON CONFLICT (alert_id, user_id, channel) DO UPDATE SET
+			status = CASE WHEN EXCLUDED.status = 'retrying' AND alert_deliveries.attempts + 1 >= $7
+			              THEN 'failed' ELSE EXCLUDED.status END,
+			error = EXCLUDED.error,
+			attempts = alert_deliveries.attempts + 1,
+			delivered_at = EXCLUDED.delivered_at,
+			next_attempt_at = CASE WHEN EXCLUDED.status = 'retrying' AND alert_deliveries.attempts + 1 < $7
+			                       THEN NOW() + make_interval(secs => LEAST($6::float8 * power(2, alert_deliveries.attempts), $8::float8))
+			                  END
+	`, alertID, userID, channel, status, errMsg, alertNotifyBaseBackoff.Seconds(), s.maxAttempts,
+		alertNotifyMaxBackoff.Seconds()); err != nil {
+		log.Printf("Alert notifier: failed to record delivery of %s: %v", alertID, err)
+	}
+}
+
+// alertDeliveryPermanent reports whether a Send error won't go away on retry:
+// the channel marked it undeliverable, or the SMTP server replied with a 5xx
+func alertDeliveryPermanent(err error) bool {
+	if errors.Is(err, ErrAlertUndeliverable) {
+		return true
+	}
+	var smtpErr *textproto.Error
+	return errors.As(err, &smtpErr) && smtpErr.Code >= 500
+}
+
+// ListDeliveries returns a user's most recent alert deliveries, optionally for
+// one channel and status
+func (s *AlertNotificationService) ListDeliveries(ctx context.Context, userID uuid.UUID, channel, status string, limit int) ([]AlertDelivery, error) {
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	switch status {
+	case "", "sent", "retrying", "failed":
+	default:
+		return nil, fmt.Errorf("invalid status %q (expected sent, retrying or failed)", status)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT d.id, d.alert_id, a.symbol, a.title, d.channel, d.status, d.attempts, COALESCE(d.error, ''),
+		       d.next_attempt_at, d.created_at, d.delivered_at
+		FROM alert_deliveries d
+		JOIN stock_alerts a ON a.id = d.alert_id
+		WHERE d.user_id = $1 AND ($2 = '' OR d.channel = $2) AND ($3 = '' OR d.status = $3)
+		ORDER BY d.created_at DESC
+		LIMIT $4
+	`, userID, channel, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []AlertDelivery{}
+	for rows.Next() {
+		var d AlertDelivery
+		if err := rows.Scan(&d.ID, &d.AlertID, &d.Symbol, &d.Title, &d.Channel, &d.Status, &d.Attempts, &d.Error,
+			&d.NextAttempt, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
+
+// alertSymbolURL links to the symbol's page on the frontend at APP_BASE_URL
+// (default http://localhost:3000)
+func alertSymbolURL(symbol string) string {
+	baseURL := os.Getenv("APP_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:3000"
+	}
+	return strings.TrimRight(baseURL, "/") + "/analysis?symbol=" + url.QueryEscape(symbol)
+}
+
+// normalizeAlertFilters validates a channel's severity and alert type filters
+func normalizeAlertFilters(severities, alertTypes []string) ([]string, []string, error) {
+	sevs := []string{}
+	for _, sev := range severities {
+		sev = strings.ToLower(strings.TrimSpace(sev))
+		switch AlertSeverity(sev) {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+		default:
+			return nil, nil, fmt.Errorf("invalid severity %q (expected info, warning or critical)", sev)
+		}
+		if !slices.Contains(sevs, sev) {
+			sevs = append(sevs, sev)
+		}
+	}
+	if len(sevs) == 0 {
+		return nil, nil, fmt.Errorf("at least one severity is required")
+	}
+
+	types := []string{}
+	for _, t := range alertTypes {
+		if t = strings.ToLower(strings.TrimSpace(t)); t != "" && !slices.Contains(types, t) {
+			types = append(types, t)
+		}
+	}
+	return sevs, types, nil
+}
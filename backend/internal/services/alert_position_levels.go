@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlertTypePositionLevel marks alerts raised by a position's stop-loss or
+// take-profit level; like rule alerts they concern only the position's owner
+const AlertTypePositionLevel AlertType = "position_level"
+
+// PositionAlertLevel is the stop-loss and take-profit prices set on a position.
+// A level fires once, and is re-armed when its price is changed.
+type PositionAlertLevel struct {
+	ID                    string     `json:"id"`
+	UserID                uuid.UUID  `json:"user_id"`
+	PortfolioID           uuid.UUID  `json:"portfolio_id"`
+	Symbol                string     `json:"symbol"`
+	StopLoss              *float64   `json:"stop_loss,omitempty"`
+	TakeProfit            *float64   `json:"take_profit,omitempty"`
+	Notify                bool       `json:"notify"` // Push over the user's notification channels
+	StopLossTriggeredAt   *time.Time `json:"stop_loss_triggered_at,omitempty"`
+	TakeProfitTriggeredAt *time.Time `json:"take_profit_triggered_at,omitempty"`
+	Quantity              float64    `json:"quantity"` // 0 once the position is closed
+	AvgCost               float64    `json:"avg_cost"`
+	CreatedAt             time.Time  `json:"created_at"`
+	UpdatedAt             time.Time  `json:"updated_at"`
+}
+
+const positionAlertLevelColumns = `l.id, l.user_id, l.portfolio_id, l.symbol, l.stop_loss::float8, l.take_profit::float8,
+	l.notify, l.stop_loss_triggered_at, l.take_profit_triggered_at,
+	COALESCE(pc.total_quantity, 0)::float8, COALESCE(pc.avg_cost_per_share, 0)::float8, l.created_at, l.updated_at`
+
+// positionAlertLevelFrom joins each level with its open position
+const positionAlertLevelFrom = `
+	FROM position_alert_levels l
+	LEFT JOIN positions_current pc ON pc.portfolio_id = l.portfolio_id AND split_part(pc.symbol, '.', 1) = l.symbol`
+
+func scanPositionAlertLevel(scanner interface{ Scan(...interface{}) error }) (*PositionAlertLevel, error) {
+	var l PositionAlertLevel
+	err := scanner.Scan(&l.ID, &l.UserID, &l.PortfolioID, &l.Symbol, &l.StopLoss, &l.TakeProfit, &l.Notify,
+		&l.StopLossTriggeredAt, &l.TakeProfitTriggeredAt, &l.Quantity, &l.AvgCost, &l.CreatedAt, &l.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ListPositionAlertLevels returns a user's position levels, optionally for one portfolio
+func (s *AlertService) ListPositionAlertLevels(ctx context.Context, userID uuid.UUID, portfolioID string) ([]PositionAlertLevel, error) {
+	if portfolioID != "" {
+		if _, err := uuid.Parse(portfolioID); err != nil {
+			return nil, fmt.Errorf("invalid portfolio id %q", portfolioID)
+		}
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+positionAlertLevelColumns+positionAlertLevelFrom+`
+		WHERE l.user_id = $1 AND ($2 = '' OR l.portfolio_id::text = $2)
+		ORDER BY l.symbol
+	`, userID, portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query position levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []PositionAlertLevel{}
+	for rows.Next() {
+		l, err := scanPositionAlertLevel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan position level: %w", err)
+		}
+		levels = append(levels, *l)
+	}
+	return levels, rows.Err()
+}
+
+// GetPositionAlertLevel returns the levels set on one of a user's positions
+func (s *AlertService) GetPositionAlertLevel(ctx context.Context, userID, portfolioID uuid.UUID, symbol string) (*PositionAlertLevel, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(strings.Split(symbol, ".")[0]))
+	l, err := scanPositionAlertLevel(s.db.QueryRowContext(ctx, `
+		SELECT `+positionAlertLevelColumns+positionAlertLevelFrom+`
+		WHERE l.user_id = $1 AND l.portfolio_id = $2 AND l.symbol = $3
+	`, userID, portfolioID, symbol))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("position levels not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query position levels: %w", err)
+	}
+	return l, nil
+}
+
+// SetPositionAlertLevel sets the stop-loss and take-profit prices of a
+// position the user holds; nil clears a level. A changed level is re-armed.
+func (s *AlertService) SetPositionAlertLevel(ctx context.Context, userID, portfolioID uuid.UUID, symbol string, stopLoss, takeProfit *float64, notify bool) (*PositionAlertLevel, error) {
+	symbol = strings.ToUpper(strings.TrimSpace(strings.Split(symbol, ".")[0]))
+	if symbol == "" || len(symbol) > 10 {
+		return nil, fmt.Errorf("invalid symbol %q", symbol)
+	}
+	if stopLoss == nil && takeProfit == nil {
+		return nil, fmt.Errorf("at least one of stop_loss and take_profit is required")
+	}
+	if (stopLoss != nil && *stopLoss <= 0) || (takeProfit != nil && *takeProfit <= 0) {
+		return nil, fmt.Errorf("levels must be positive")
+	}
+	if stopLoss != nil && takeProfit != nil && *stopLoss >= *takeProfit {
+		return nil, fmt.Errorf("stop_loss must be below take_profit")
+	}
+
+	var held bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM positions_current pc
+			JOIN portfolios p ON p.id = pc.portfolio_id
+			WHERE p.id = $1 AND p.user_id = $2 AND split_part(pc.symbol, '.', 1) = $3 AND pc.total_quantity > 0
+		)
+	`, portfolioID, userID, symbol).Scan(&held); err != nil {
+		return nil, fmt.Errorf("failed to check position: %w", err)
+	}
+	if !held {
+		return nil, fmt.Errorf("position %s not found in portfolio", symbol)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO position_alert_levels (user_id, portfolio_id, symbol, stop_loss, take_profit, notify)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (portfolio_id, symbol) DO UPDATE SET
+			stop_loss = EXCLUDED.stop_loss,
+			take_profit = EXCLUDED.take_profit,
+			notify = EXCLUDED.notify,
+			stop_loss_triggered_at = CASE WHEN position_alert_levels.stop_loss IS DISTINCT FROM EXCLUDED.stop_loss
+				THEN NULL ELSE position_alert_levels.stop_loss_triggered_at END,
+			take_profit_triggered_at = CASE WHEN position_alert_levels.take_profit IS DISTINCT FROM EXCLUDED.take_profit
+				THEN NULL ELSE position_alert_levels.take_profit_triggered_at END
+	`, userID, portfolioID, symbol, stopLoss, takeProfit, notify); err != nil {
+		return nil, fmt.Errorf("failed to save position levels: %w", err)
+	}
+	return s.GetPositionAlertLevel(ctx, userID, portfolioID, symbol)
+}
+
+// DeletePositionAlertLevel removes the levels of a position
+func (s *AlertService) DeletePositionAlertLevel(ctx context.Context, userID, portfolioID uuid.UUID, symbol string) error {
+	symbol = strings.ToUpper(strings.TrimSpace(strings.Split(symbol, ".")[0]))
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM position_alert_levels WHERE user_id = $1 AND portfolio_id = $2 AND symbol = $3
+	`, userID, portfolioID, symbol)
+	if err != nil {
+		return fmt.Errorf("failed to delete position levels: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("position levels not found")
+	}
+	return nil
+}
+
+// CheckPositionLevels compares the armed stop-loss and take-profit levels of
+// open positions with realtime quotes from fetchQuotes and raises a critical
+// alert, carrying the position's unrealized P&L at that price, for each level
+// touched
+func (s *AlertService) CheckPositionLevels(ctx context.Context, fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) ([]StockAlert, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+positionAlertLevelColumns+positionAlertLevelFrom+`
+		WHERE pc.total_quantity > 0
+		  AND ((l.stop_loss IS NOT NULL AND l.stop_loss_triggered_at IS NULL)
+		    OR (l.take_profit IS NOT NULL AND l.take_profit_triggered_at IS NULL))
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query position levels: %w", err)
+	}
+	var levels []PositionAlertLevel
+	seen := map[string]bool{}
+	var symbols []string
+	for rows.Next() {
+		if l, err := scanPositionAlertLevel(rows); err == nil {
+			levels = append(levels, *l)
+			if !seen[l.Symbol] {
+				seen[l.Symbol] = true
+				symbols = append(symbols, l.Symbol)
+			}
+		}
+	}
+	rows.Close()
+
+	alerts := []StockAlert{}
+	if len(levels) == 0 {
+		return alerts, nil
+	}
+	quotes, err := fetchQuotes(ctx, symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quotes: %w", err)
+	}
+	prices := make(map[string]*RealtimeQuote, len(quotes))
+	for _, q := range quotes {
+		if q != nil && q.Price.IsPositive() {
+			prices[q.Symbol] = q
+		}
+	}
+
+	for i := range levels {
+		l := &levels[i]
+		q, ok := prices[l.Symbol]
+		if !ok {
+			continue
+		}
+		price := q.Price.InexactFloat64()
+		if l.StopLoss != nil && l.StopLossTriggeredAt == nil && price <= *l.StopLoss {
+			if alert, err := s.raisePositionLevelAlert(ctx, l, "stop_loss", *l.StopLoss, q); err != nil {
+				log.Printf("Warning: position level %s on %s: %v", l.ID, l.Symbol, err)
+			} else {
+				alerts = append(alerts, *alert)
+			}
+		}
+		if l.TakeProfit != nil && l.TakeProfitTriggeredAt == nil && price >= *l.TakeProfit {
+			if alert, err := s.raisePositionLevelAlert(ctx, l, "take_profit", *l.TakeProfit, q); err != nil {
+				log.Printf("Warning: position level %s on %s: %v", l.ID, l.Symbol, err)
+			} else {
+				alerts = append(alerts, *alert)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// raisePositionLevelAlert disarms the level and stores its alert. The level is
+// claimed first so that overlapping checks alert once.
+func (s *AlertService) raisePositionLevelAlert(ctx context.Context, l *PositionAlertLevel, kind string, level float64, q *RealtimeQuote) (*StockAlert, error) {
+	column, label := "stop_loss_triggered_at", "停損"
+	if kind == "take_profit" {
+		column, label = "take_profit_triggered_at", "停利"
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE position_alert_levels SET `+column+` = NOW() WHERE id = $1 AND `+column+` IS NULL
+	`, l.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update position level: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("already triggered")
+	}
+
+	price := q.Price.InexactFloat64()
+	cost := l.Quantity * l.AvgCost
+	pnl := l.Quantity*price - cost
+	pnlPercent := 0.0
+	if cost > 0 {
+		pnlPercent = pnl / cost * 100
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"level_id":               l.ID,
+		"user_id":                l.UserID,
+		"portfolio_id":           l.PortfolioID,
+		"kind":                   kind,
+		"level":                  level,
+		"price":                  price,
+		"quantity":               l.Quantity,
+		"avg_cost":               roundTo(l.AvgCost, 4),
+		"unrealized_pnl":         roundTo(pnl, 2),
+		"unrealized_pnl_percent": roundTo(pnlPercent, 2),
+		"notify":                 l.Notify,
+		"trade_time":             q.TradeTime,
+	})
+	alert := &StockAlert{
+		Symbol:          l.Symbol,
+		AlertType:       AlertTypePositionLevel,
+		Severity:        AlertSeverityCritical,
+		Title:           fmt.Sprintf("%s 觸及%s價 %.2f", l.Symbol, label, level),
+		Message:         fmt.Sprintf("現價 %.2f，持有 %g 股，未實現損益 %+.0f (%+.2f%%)", price, l.Quantity, pnl, pnlPercent),
+		Data:            data,
+		ReferencePrice:  price,
+		ReferenceVolume: q.Volume,
+		ThresholdValue:  level,
+	}
+	if err := s.CreateAlert(ctx, alert); err != nil {
+		// Re-arm so the next check tries again
+		s.db.ExecContext(ctx, `UPDATE position_alert_levels SET `+column+` = NULL WHERE id = $1`, l.ID)
+		return nil, fmt.Errorf("failed to create alert: %w", err)
+	}
+	return alert, nil
+}
@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertCleanupBatch bounds how many alerts are archived/deleted per transaction
+const alertCleanupBatch = 1000
+
+// AlertRetentionPolicy controls which alerts the cleanup job removes
+type AlertRetentionPolicy struct {
+	RetentionDays      int  `json:"retention_days"`      // Acknowledged alerts older than this are removed
+	UnacknowledgedDays int  `json:"unacknowledged_days"` // Unacknowledged ones too after this; 0 keeps them
+	Archive            bool `json:"archive"`             // Move to stock_alerts_archive instead of deleting outright
+	DryRun             bool `json:"dry_run,omitempty"`
+}
+
+// IsDryRun reports whether the policy only counts expired alerts
+func (p AlertRetentionPolicy) IsDryRun() bool {
+	return p.DryRun
+}
+
+// AlertCleanupResult reports one cleanup run
+type AlertCleanupResult struct {
+	Policy    AlertRetentionPolicy `json:"policy"`
+	Cutoff    time.Time            `json:"cutoff"`
+	Expired   int                  `json:"expired"`
+	Archived  int                  `json:"archived"`
+	Deleted   int                  `json:"deleted"`
+	StartedAt time.Time            `json:"started_at"`
+	Duration  string               `json:"duration"`
+}
+
+// alertRetentionPolicyFromEnv reads ALERT_RETENTION_DAYS (default 90),
+// ALERT_RETENTION_UNACKED_DAYS (default 0, keep) and ALERT_RETENTION_ARCHIVE
+// (default true)
+func alertRetentionPolicyFromEnv() AlertRetentionPolicy {
+	policy := AlertRetentionPolicy{RetentionDays: 90, Archive: true}
+	if v, err := strconv.Atoi(os.Getenv("ALERT_RETENTION_DAYS")); err == nil && v > 0 {
+		policy.RetentionDays = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("ALERT_RETENTION_UNACKED_DAYS")); err == nil && v >= 0 {
+		policy.UnacknowledgedDays = v
+	}
+	if strings.EqualFold(os.Getenv("ALERT_RETENTION_ARCHIVE"), "false") {
+		policy.Archive = false
+	}
+	return policy
+}
+
+// CleanupAlerts removes (or archives) acknowledged alerts triggered before the
+// retention window, and unacknowledged ones past their own window if set.
+// Their notification deliveries are removed with them.
+func (s *AlertService) CleanupAlerts(ctx context.Context, policy AlertRetentionPolicy) (*AlertCleanupResult, error) {
+	if policy.RetentionDays <= 0 {
+		return nil, fmt.Errorf("%w: retention_days must be positive", ErrInvalidRetentionPolicy)
+	}
+	if policy.UnacknowledgedDays < 0 {
+		return nil, fmt.Errorf("%w: unacknowledged_days must not be negative", ErrInvalidRetentionPolicy)
+	}
+
+	result := &AlertCleanupResult{
+		Policy:    policy,
+		Cutoff:    time.Now().AddDate(0, 0, -policy.RetentionDays),
+		StartedAt: time.Now(),
+	}
+	// The zero time matches nothing, keeping unacknowledged alerts
+	var unackedCutoff time.Time
+	if policy.UnacknowledgedDays > 0 {
+		unackedCutoff = time.Now().AddDate(0, 0, -policy.UnacknowledgedDays)
+	}
+
+	where := `
+		WHERE (acknowledged_at IS NOT NULL AND triggered_at < $1)
+		   OR (acknowledged_at IS NULL AND triggered_at < $2)
+	`
+
+	if policy.DryRun {
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_alerts`+where,
+			result.Cutoff, unackedCutoff).Scan(&result.Expired); err != nil {
+			return nil, fmt.Errorf("failed to count expired alerts: %w", err)
+		}
+		result.Duration = time.Since(result.StartedAt).String()
+		return result, nil
+	}
+
+	batches := retentionBatches{
+		noun:        "alerts",
+		selectQuery: `SELECT id FROM stock_alerts` + where + ` ORDER BY triggered_at LIMIT $3`,
+		selectArgs:  []interface{}{result.Cutoff, unackedCutoff},
+		archiveQuery: `
+			INSERT INTO stock_alerts_archive (
+				id, symbol, alert_type, severity, title, message, data, triggered_at, acknowledged_at,
+				reference_price, reference_volume, threshold_value, ai_explanation, dedup_key
+			)
+			SELECT id, symbol, alert_type, severity, title, message, data, triggered_at, acknowledged_at,
+			       reference_price, reference_volume, threshold_value, ai_explanation, dedup_key
+			FROM stock_alerts
+			WHERE id = ANY($1::uuid[])
+			ON CONFLICT (id) DO NOTHING
+		`,
+		deleteQuery: `DELETE FROM stock_alerts WHERE id = ANY($1::uuid[])`,
+		batchSize:   alertCleanupBatch,
+	}
+	var err error
+	result.Expired, result.Archived, result.Deleted, err = batches.run(ctx, s.db, policy.Archive)
+	if err != nil {
+		return result, err
+	}
+
+	result.Duration = time.Since(result.StartedAt).String()
+	return result, nil
+}
+
+// AlertVolumeDay is the number of alerts raised on one Taipei calendar day
+type AlertVolumeDay struct {
+	Date           string         `json:"date"`
+	Total          int            `json:"total"`
+	Critical       int            `json:"critical"`
+	Warning        int            `json:"warning"`
+	Info           int            `json:"info"`
+	Unacknowledged int            `json:"unacknowledged"`
+	ByType         map[string]int `json:"by_type"`
+}
+
+// AlertVolume is alert volume over time, for watching noise levels
+type AlertVolume struct {
+	Days       int                `json:"days"`
+	Total      int                `json:"total"`
+	DailyAvg   float64            `json:"daily_avg"`
+	ByType     map[string]int     `json:"by_type"`
+	TopSymbols []AlertSymbolCount `json:"top_symbols"`
+	Daily      []AlertVolumeDay   `json:"daily"`
+}
+
+// AlertSymbolCount is how many alerts a symbol raised
+type AlertSymbolCount struct {
+	Symbol string         `json:"symbol"`
+	Count  int            `json:"count"`
+	ByType map[string]int `json:"by_type,omitempty"`
+}
+
+// GetAlertVolume returns alerts raised per day over the last days, including
+// archived alerts, with the noisiest types and symbols
+func (s *AlertService) GetAlertVolume(ctx context.Context, days int) (*AlertVolume, error) {
+	if days <= 0 || days > 365 {
+		days = 30
+	}
+	since := time.Now().AddDate(0, 0, -days)
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH alerts AS (
+			SELECT symbol, alert_type, severity, triggered_at, acknowledged_at FROM stock_alerts WHERE triggered_at >= $1
+			UNION ALL
+			SELECT symbol, alert_type, severity, triggered_at, acknowledged_at FROM stock_alerts_archive WHERE triggered_at >= $1
+		)
+		SELECT to_char(triggered_at AT TIME ZONE 'Asia/Taipei', 'YYYY-MM-DD') AS day, alert_type,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE severity = 'critical'),
+		       COUNT(*) FILTER (WHERE severity = 'warning'),
+		       COUNT(*) FILTER (WHERE severity = 'info'),
+		       COUNT(*) FILTER (WHERE acknowledged_at IS NULL)
+		FROM alerts
+		GROUP BY day, alert_type
+		ORDER BY day
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert volume: %w", err)
+	}
+	defer rows.Close()
+
+	volume := &AlertVolume{
+		Days:       days,
+		ByType:     map[string]int{},
+		TopSymbols: []AlertSymbolCount{},
+		Daily:      []AlertVolumeDay{},
+	}
+	for rows.Next() {
+		var date, alertType string
+		var total, critical, warning, info, unacked int
+		if err := rows.Scan(&date, &alertType, &total, &critical, &warning, &info, &unacked); err != nil {
+			return nil, fmt.Errorf("failed to scan alert volume: %w", err)
+		}
+		if n := len(volume.Daily); n == 0 || volume.Daily[n-1].Date != date {
+			volume.Daily = append(volume.Daily, AlertVolumeDay{Date: date, ByType: map[string]int{}})
+		}
+		day := &volume.Daily[len(volume.Daily)-1]
+		day.Total += total
+		day.Critical += critical
+		day.Warning += warning
+		day.Info += info
+		day.Unacknowledged += unacked
+		day.ByType[alertType] += total
+		volume.ByType[alertType] += total
+		volume.Total += total
+	}
+	rows.Close()
+	volume.DailyAvg = roundTo(float64(volume.Total)/float64(days), 2)
+
+	symbolRows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, COUNT(*) AS n FROM (
+			SELECT symbol FROM stock_alerts WHERE triggered_at >= $1
+			UNION ALL
+			SELECT symbol FROM stock_alerts_archive WHERE triggered_at >= $1
+		) a
+		GROUP BY symbol
+		ORDER BY n DESC, symbol
+		LIMIT 10
+	`, since)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert volume: %w", err)
+	}
+	defer symbolRows.Close()
+	for symbolRows.Next() {
+		var c AlertSymbolCount
+		if err := symbolRows.Scan(&c.Symbol, &c.Count); err == nil {
+			volume.TopSymbols = append(volume.TopSymbols, c)
+		}
+	}
+	return volume, nil
+}
+
+// AlertRetentionWorker applies the alert retention policy once a day
+type AlertRetentionWorker = RetentionWorker[AlertRetentionPolicy, AlertCleanupResult]
+
+// AlertRetentionStatus reports the alert retention policy and the last run
+type AlertRetentionStatus = RetentionStatus[AlertRetentionPolicy, AlertCleanupResult]
+
+func NewAlertRetentionWorker(alertService *AlertService) *AlertRetentionWorker {
+	return newRetentionWorker("Alert retention", alertRetentionPolicyFromEnv(), alertService.CleanupAlerts,
+		func(r *AlertCleanupResult) string {
+			return fmt.Sprintf("removed %d alerts older than %s (%d archived)",
+				r.Deleted, r.Cutoff.Format("2006-01-02"), r.Archived)
+		})
+}
@@ -0,0 +1,856 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// AlertTypeUserRule marks alerts raised by user-defined rules
+const AlertTypeUserRule AlertType = "user_rule"
+
+// Metrics a rule can compare, from the latest daily bar or, for the MA and RSI
+// metrics, the latest indicator snapshot
+const (
+	AlertRuleMetricPrice         = "price"          // Close
+	AlertRuleMetricChangePercent = "change_percent" // Versus the previous close
+	AlertRuleMetricVolume        = "volume"         // Shares
+	AlertRuleMetricVolumeRatio   = "volume_ratio"   // Versus the previous 20-day average
+	AlertRuleMetricMA20Distance  = "ma20_distance"  // % above (negative: below) MA20
+	AlertRuleMetricMA60Distance  = "ma60_distance"  // % above (negative: below) MA60
+	AlertRuleMetricRSI14         = "rsi14"
+)
+
+// alertRuleMetricLabels names the metrics in alert titles and messages
+var alertRuleMetricLabels = map[string]string{
+	AlertRuleMetricPrice:         "收盤價",
+	AlertRuleMetricChangePercent: "漲跌幅(%)",
+	AlertRuleMetricVolume:        "成交量",
+	AlertRuleMetricVolumeRatio:   "量比",
+	AlertRuleMetricMA20Distance:  "距MA20(%)",
+	AlertRuleMetricMA60Distance:  "距MA60(%)",
+	AlertRuleMetricRSI14:         "RSI(14)",
+}
+
+// Limits on a compound rule's condition tree
+const (
+	alertConditionMaxDepth  = 3
+	alertConditionMaxLeaves = 10
+)
+
+// AlertRuleOperators lists the supported comparison operators
+var AlertRuleOperators = []string{">=", "<=", ">", "<"}
+
+// AlertRule is a user-defined threshold alert such as "2330 price >= 1100".
+// A rule without a symbol applies to the owner's held and watched symbols.
+// A compound rule sets Conditions instead of Metric, Operator and Threshold.
+// A rule may be linked to the saved strategy it watches for, pinned at a version.
+type AlertRule struct {
+	ID              string          `json:"id"`
+	UserID          uuid.UUID       `json:"user_id"`
+	Name            string          `json:"name"`
+	Symbol          string          `json:"symbol,omitempty"`
+	Metric          string          `json:"metric,omitempty"`
+	Operator        string          `json:"operator,omitempty"`
+	Threshold       float64         `json:"threshold,omitempty"`
+	Conditions      *AlertCondition `json:"conditions,omitempty"`
+	StrategyID      string          `json:"strategy_id,omitempty"`
+	StrategyVersion int             `json:"strategy_version,omitempty"` // 0 on create/update means the current version
+	Severity        AlertSeverity   `json:"severity"`
+	Enabled         bool            `json:"enabled"`
+	LastTriggeredAt *time.Time      `json:"last_triggered_at,omitempty"`
+	TriggerCount    int             `json:"trigger_count"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// AlertCondition is a node of a compound rule's condition tree: either a
+// comparison (Metric, Operator, Threshold) or an "and"/"or" group of
+// Conditions
+type AlertCondition struct {
+	Op         string           `json:"op,omitempty"`
+	Conditions []AlertCondition `json:"conditions,omitempty"`
+	Metric     string           `json:"metric,omitempty"`
+	Operator   string           `json:"operator,omitempty"`
+	Threshold  float64          `json:"threshold,omitempty"`
+}
+
+// compareAlertMetric applies a rule operator
+func compareAlertMetric(value float64, operator string, threshold float64) bool {
+	switch operator {
+	case ">=":
+		return value >= threshold
+	case "<=":
+		return value <= threshold
+	case ">":
+		return value > threshold
+	case "<":
+		return value < threshold
+	}
+	return false
+}
+
+// matches evaluates the rule against a bar, recording the metric values it
+// compared in values. A metric the bar lacks fails its comparison.
+func (r *AlertRule) matches(b *alertRuleBar, values map[string]float64) bool {
+	if r.Conditions != nil {
+		return r.Conditions.matches(b, values)
+	}
+	value, ok := b.value(r.Metric)
+	if !ok {
+		return false
+	}
+	values[r.Metric] = value
+	return compareAlertMetric(value, r.Operator, r.Threshold)
+}
+
+func (c *AlertCondition) matches(b *alertRuleBar, values map[string]float64) bool {
+	switch c.Op {
+	case "and":
+		for i := range c.Conditions {
+			if !c.Conditions[i].matches(b, values) {
+				return false
+			}
+		}
+		return true
+	case "or":
+		matched := false
+		for i := range c.Conditions {
+			// No short circuit, so every value shows up in the alert
+			if c.Conditions[i].matches(b, values) {
+				matched = true
+			}
+		}
+		return matched
+	}
+	value, ok := b.value(c.Metric)
+	if !ok {
+		return false
+	}
+	values[c.Metric] = value
+	return compareAlertMetric(value, c.Operator, c.Threshold)
+}
+
+// condition renders the rule as e.g. "收盤價 >= 1100"
+func (r *AlertRule) condition() string {
+	if r.Conditions != nil {
+		return r.Conditions.String()
+	}
+	return fmt.Sprintf("%s %s %g", alertRuleMetricLabels[r.Metric], r.Operator, r.Threshold)
+}
+
+// String renders the tree as e.g. "漲跌幅(%) >= 3 且 (量比 >= 2 或 距MA20(%) > 0)"
+func (c *AlertCondition) String() string {
+	if c.Op == "" {
+		return fmt.Sprintf("%s %s %g", alertRuleMetricLabels[c.Metric], c.Operator, c.Threshold)
+	}
+	sep := " 且 "
+	if c.Op == "or" {
+		sep = " 或 "
+	}
+	parts := make([]string, len(c.Conditions))
+	for i := range c.Conditions {
+		parts[i] = c.Conditions[i].String()
+		if c.Conditions[i].Op != "" && len(c.Conditions[i].Conditions) > 1 {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// normalizeAlertComparison validates one metric comparison
+func normalizeAlertComparison(metric, operator *string, threshold float64) error {
+	*metric = strings.ToLower(strings.TrimSpace(*metric))
+	*operator = strings.TrimSpace(*operator)
+
+	if _, ok := alertRuleMetricLabels[*metric]; !ok {
+		return fmt.Errorf("invalid metric %q (expected price, change_percent, volume, volume_ratio, ma20_distance, ma60_distance or rsi14)", *metric)
+	}
+	valid := false
+	for _, op := range AlertRuleOperators {
+		if *operator == op {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return fmt.Errorf("invalid operator %q (expected >=, <=, > or <)", *operator)
+	}
+
+	switch *metric {
+	case AlertRuleMetricPrice, AlertRuleMetricVolumeRatio:
+		if threshold <= 0 {
+			return fmt.Errorf("threshold must be positive for %s", *metric)
+		}
+	case AlertRuleMetricVolume:
+		if threshold < 0 {
+			return fmt.Errorf("threshold must not be negative for volume")
+		}
+	case AlertRuleMetricChangePercent:
+		if threshold < -100 || threshold > 100 {
+			return fmt.Errorf("threshold must be between -100 and 100 for change_percent")
+		}
+	case AlertRuleMetricMA20Distance, AlertRuleMetricMA60Distance:
+		if threshold <= -100 {
+			return fmt.Errorf("threshold must be above -100 for %s", *metric)
+		}
+	case AlertRuleMetricRSI14:
+		if threshold < 0 || threshold > 100 {
+			return fmt.Errorf("threshold must be between 0 and 100 for rsi14")
+		}
+	}
+	return nil
+}
+
+// normalizeAlertCondition validates a condition tree, counting its comparisons in leaves
+func normalizeAlertCondition(c *AlertCondition, depth int, leaves *int) error {
+	c.Op = strings.ToLower(strings.TrimSpace(c.Op))
+	switch c.Op {
+	case "":
+		if len(c.Conditions) > 0 {
+			return fmt.Errorf("a condition with sub-conditions needs op and or or")
+		}
+		if *leaves++; *leaves > alertConditionMaxLeaves {
+			return fmt.Errorf("conditions may have at most %d comparisons", alertConditionMaxLeaves)
+		}
+		return normalizeAlertComparison(&c.Metric, &c.Operator, c.Threshold)
+	case "and", "or":
+		if depth >= alertConditionMaxDepth {
+			return fmt.Errorf("conditions may be nested at most %d levels deep", alertConditionMaxDepth)
+		}
+		if len(c.Conditions) == 0 {
+			return fmt.Errorf("%s group needs at least one condition", c.Op)
+		}
+		c.Metric, c.Operator, c.Threshold = "", "", 0
+		for i := range c.Conditions {
+			if err := normalizeAlertCondition(&c.Conditions[i], depth+1, leaves); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid op %q (expected and or or)", c.Op)
+	}
+}
+
+// normalizeAlertRule validates a rule and fills in defaults
+func normalizeAlertRule(rule *AlertRule) error {
+	rule.Symbol = strings.ToUpper(strings.TrimSpace(strings.Split(rule.Symbol, ".")[0]))
+	rule.Name = strings.TrimSpace(rule.Name)
+
+	if len(rule.Symbol) > 10 {
+		return fmt.Errorf("invalid symbol %q", rule.Symbol)
+	}
+	if rule.Conditions != nil {
+		leaves := 0
+		if err := normalizeAlertCondition(rule.Conditions, 0, &leaves); err != nil {
+			return err
+		}
+		rule.Metric, rule.Operator, rule.Threshold = "", "", 0
+	} else if err := normalizeAlertComparison(&rule.Metric, &rule.Operator, rule.Threshold); err != nil {
+		return err
+	}
+
+	switch rule.Severity {
+	case "":
+		rule.Severity = AlertSeverityWarning
+	case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+	default:
+		return fmt.Errorf("invalid severity %q (expected info, warning or critical)", rule.Severity)
+	}
+
+	if rule.Name == "" {
+		target := rule.Symbol
+		if target == "" {
+			target = "持股/自選股"
+		}
+		rule.Name = target + " " + rule.condition()
+	}
+	if len([]rune(rule.Name)) > 100 {
+		return fmt.Errorf("name must be at most 100 characters")
+	}
+	return nil
+}
+
+const alertRuleColumns = `id, user_id, name, COALESCE(symbol, ''), COALESCE(metric, ''), COALESCE(operator, ''),
+	COALESCE(threshold, 0), conditions, COALESCE(strategy_id::text, ''), COALESCE(strategy_version, 0),
+	severity, enabled, last_triggered_at, trigger_count, created_at, updated_at`
+
+func scanAlertRule(scanner interface{ Scan(...interface{}) error }) (*AlertRule, error) {
+	var r AlertRule
+	var conditions []byte
+	err := scanner.Scan(&r.ID, &r.UserID, &r.Name, &r.Symbol, &r.Metric, &r.Operator, &r.Threshold, &conditions,
+		&r.StrategyID, &r.StrategyVersion, &r.Severity, &r.Enabled, &r.LastTriggeredAt, &r.TriggerCount, &r.CreatedAt, &r.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if len(conditions) > 0 {
+		r.Conditions = &AlertCondition{}
+		if err := json.Unmarshal(conditions, r.Conditions); err != nil {
+			return nil, fmt.Errorf("invalid conditions: %w", err)
+		}
+	}
+	return &r, nil
+}
+
+// storedColumns returns the metric, operator, threshold and conditions values
+// to store; a compound rule leaves the first three NULL
+func (r *AlertRule) storedColumns() (metric, operator, threshold, conditions interface{}) {
+	if r.Conditions != nil {
+		data, _ := json.Marshal(r.Conditions)
+		return nil, nil, nil, string(data)
+	}
+	return r.Metric, r.Operator, r.Threshold, nil
+}
+
+// ListAlertRules returns a user's alert rules, newest first, optionally only
+// those linked to strategyID
+func (s *AlertService) ListAlertRules(ctx context.Context, userID uuid.UUID, strategyID string) ([]AlertRule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+alertRuleColumns+`
+		FROM alert_rules
+		WHERE user_id = $1 AND ($2 = '' OR strategy_id::text = $2)
+		ORDER BY created_at DESC
+	`, userID, strategyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []AlertRule{}
+	for rows.Next() {
+		r, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan alert rule: %w", err)
+		}
+		rules = append(rules, *r)
+	}
+	return rules, rows.Err()
+}
+
+// GetAlertRule returns one of a user's alert rules
+func (s *AlertService) GetAlertRule(ctx context.Context, userID uuid.UUID, id string) (*AlertRule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	r, err := scanAlertRule(s.db.QueryRowContext(ctx, `
+		SELECT `+alertRuleColumns+` FROM alert_rules WHERE id = $1 AND user_id = $2
+	`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rule: %w", err)
+	}
+	return r, nil
+}
+
+// CreateAlertRule validates and stores a new rule for userID
+func (s *AlertService) CreateAlertRule(ctx context.Context, userID uuid.UUID, rule *AlertRule) (*AlertRule, error) {
+	if err := normalizeAlertRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.resolveRuleStrategy(ctx, userID, rule); err != nil {
+		return nil, err
+	}
+
+	metric, operator, threshold, conditions := rule.storedColumns()
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO alert_rules (user_id, name, symbol, metric, operator, threshold, conditions, strategy_id, strategy_version,
+		                         severity, enabled)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6, $7::jsonb, NULLIF($8, '')::uuid, NULLIF($9, 0), $10, $11)
+		RETURNING id
+	`, userID, rule.Name, rule.Symbol, metric, operator, threshold, conditions, rule.StrategyID, rule.StrategyVersion,
+		string(rule.Severity), rule.Enabled).Scan(&id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return s.GetAlertRule(ctx, userID, id)
+}
+
+// UpdateAlertRule replaces a rule's definition; its trigger history is kept
+func (s *AlertService) UpdateAlertRule(ctx context.Context, userID uuid.UUID, id string, rule *AlertRule) (*AlertRule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	if err := normalizeAlertRule(rule); err != nil {
+		return nil, err
+	}
+	if err := s.resolveRuleStrategy(ctx, userID, rule); err != nil {
+		return nil, err
+	}
+
+	metric, operator, threshold, conditions := rule.storedColumns()
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE alert_rules
+		SET name = $3, symbol = NULLIF($4, ''), metric = $5, operator = $6, threshold = $7, conditions = $8::jsonb,
+		    strategy_id = NULLIF($9, '')::uuid, strategy_version = NULLIF($10, 0), severity = $11, enabled = $12
+		WHERE id = $1 AND user_id = $2
+	`, id, userID, rule.Name, rule.Symbol, metric, operator, threshold, conditions, rule.StrategyID, rule.StrategyVersion,
+		string(rule.Severity), rule.Enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	return s.GetAlertRule(ctx, userID, id)
+}
+
+// resolveRuleStrategy checks that a rule's strategy is one of the user's and
+// pins the rule to the strategy's current version when none is given
+func (s *AlertService) resolveRuleStrategy(ctx context.Context, userID uuid.UUID, rule *AlertRule) error {
+	if rule.StrategyID == "" {
+		rule.StrategyVersion = 0
+		return nil
+	}
+	if _, err := uuid.Parse(rule.StrategyID); err != nil {
+		return fmt.Errorf("invalid strategy_id %q", rule.StrategyID)
+	}
+	if rule.StrategyVersion < 0 {
+		return fmt.Errorf("strategy_version must not be negative")
+	}
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT v.version
+		FROM strategy_versions v
+		JOIN strategies st ON st.id = v.strategy_id
+		WHERE v.strategy_id = $1 AND st.user_id = $2
+		  AND v.version = CASE WHEN $3 > 0 THEN $3 ELSE st.current_version END
+	`, rule.StrategyID, userID, rule.StrategyVersion).Scan(&rule.StrategyVersion)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("unknown strategy %s v%d", rule.StrategyID, rule.StrategyVersion)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to query strategy: %w", err)
+	}
+	return nil
+}
+
+// SetAlertRuleEnabled enables or disables a rule without changing it
+func (s *AlertService) SetAlertRuleEnabled(ctx context.Context, userID uuid.UUID, id string, enabled bool) (*AlertRule, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE alert_rules SET enabled = $3 WHERE id = $1 AND user_id = $2
+	`, id, userID, enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update alert rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("alert rule not found")
+	}
+	return s.GetAlertRule(ctx, userID, id)
+}
+
+// DeleteAlertRule removes a rule; alerts it raised are kept
+func (s *AlertService) DeleteAlertRule(ctx context.Context, userID uuid.UUID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("alert rule not found")
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM alert_rules WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("alert rule not found")
+	}
+	return nil
+}
+
+// alertRuleBar holds the latest daily metrics of a symbol
+type alertRuleBar struct {
+	date          time.Time
+	close         float64
+	changePercent float64
+	volume        int64
+	volumeRatio   float64
+	hasPrevious   bool
+	ma20          sql.NullFloat64 // From the latest indicator snapshot
+	ma60          sql.NullFloat64
+	rsi14         sql.NullFloat64
+}
+
+func (b *alertRuleBar) value(metric string) (float64, bool) {
+	switch metric {
+	case AlertRuleMetricPrice:
+		return b.close, true
+	case AlertRuleMetricChangePercent:
+		return b.changePercent, b.hasPrevious
+	case AlertRuleMetricVolume:
+		return float64(b.volume), true
+	case AlertRuleMetricVolumeRatio:
+		return b.volumeRatio, b.volumeRatio > 0
+	case AlertRuleMetricMA20Distance:
+		return maDistance(b.close, b.ma20)
+	case AlertRuleMetricMA60Distance:
+		return maDistance(b.close, b.ma60)
+	case AlertRuleMetricRSI14:
+		return b.rsi14.Float64, b.rsi14.Valid
+	}
+	return 0, false
+}
+
+// maDistance is how far price is above (negative: below) a moving average, in %
+func maDistance(price float64, ma sql.NullFloat64) (float64, bool) {
+	if !ma.Valid || ma.Float64 <= 0 {
+		return 0, false
+	}
+	return (price - ma.Float64) / ma.Float64 * 100, true
+}
+
+// loadRuleIndicators fills in the MAs and RSI of the bars from each symbol's
+// latest indicator snapshot
+func (s *AlertService) loadRuleIndicators(ctx context.Context, bars map[string]*alertRuleBar) error {
+	if len(bars) == 0 {
+		return nil
+	}
+	symbols := make([]string, 0, len(bars))
+	for sym := range bars {
+		symbols = append(symbols, sym)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (symbol) symbol, ma20::float8, ma60::float8, rsi14::float8
+		FROM indicator_snapshots
+		WHERE symbol = ANY($1) AND snapshot_date >= CURRENT_DATE - 14
+		ORDER BY symbol, snapshot_date DESC
+	`, pq.Array(symbols))
+	if err != nil {
+		return fmt.Errorf("failed to query indicator snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var symbol string
+		var ma20, ma60, rsi14 sql.NullFloat64
+		if err := rows.Scan(&symbol, &ma20, &ma60, &rsi14); err != nil {
+			continue
+		}
+		if b, ok := bars[symbol]; ok {
+			b.ma20, b.ma60, b.rsi14 = ma20, ma60, rsi14
+		}
+	}
+	return rows.Err()
+}
+
+// alertRuleBarSource loads the bars rules are checked against for the needed symbols
+type alertRuleBarSource func(ctx context.Context, needed map[string]bool) (map[string]*alertRuleBar, error)
+
+// EvaluateAlertRules checks every enabled rule against the latest daily bar of
+// its symbols and raises an alert for each match. A rule fires at most once per
+// symbol and trading day.
+func (s *AlertService) EvaluateAlertRules(ctx context.Context) ([]StockAlert, error) {
+	return s.evaluateAlertRules(ctx, s.latestRuleBars)
+}
+
+// EvaluateAlertRulesWithQuotes checks the rules against intraday quotes from
+// fetchQuotes instead of synced bars; the volume ratio compares the volume so
+// far with the previous 20 days' average
+func (s *AlertService) EvaluateAlertRulesWithQuotes(ctx context.Context, fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) ([]StockAlert, error) {
+	return s.evaluateAlertRules(ctx, func(ctx context.Context, needed map[string]bool) (map[string]*alertRuleBar, error) {
+		return s.quoteRuleBars(ctx, needed, fetchQuotes)
+	})
+}
+
+func (s *AlertService) evaluateAlertRules(ctx context.Context, loadBars alertRuleBarSource) ([]StockAlert, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+alertRuleColumns+` FROM alert_rules WHERE enabled`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	var rules []AlertRule
+	for rows.Next() {
+		if r, err := scanAlertRule(rows); err == nil {
+			rules = append(rules, *r)
+		}
+	}
+	rows.Close()
+
+	alerts := []StockAlert{}
+	if len(rules) == 0 {
+		return alerts, nil
+	}
+
+	// Resolve each rule's symbols; symbol-less rules follow the owner's holdings and watchlists
+	ruleSymbols := make(map[string][]string, len(rules))
+	userSymbols := make(map[uuid.UUID][]string)
+	needed := make(map[string]bool)
+	for _, r := range rules {
+		symbols := []string{r.Symbol}
+		if r.Symbol == "" {
+			held, ok := userSymbols[r.UserID]
+			if !ok {
+				if held, err = s.heldAndWatchedSymbols(ctx, r.UserID); err != nil {
+					return nil, err
+				}
+				userSymbols[r.UserID] = held
+			}
+			symbols = held
+		}
+		ruleSymbols[r.ID] = symbols
+		for _, sym := range symbols {
+			needed[sym] = true
+		}
+	}
+
+	bars, err := loadBars(ctx, needed)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		for _, symbol := range ruleSymbols[rule.ID] {
+			bar, ok := bars[symbol]
+			if !ok {
+				continue
+			}
+			values := map[string]float64{}
+			if !rule.matches(bar, values) {
+				continue
+			}
+
+			alert, err := s.raiseRuleAlert(ctx, rule, symbol, bar, values)
+			if err != nil {
+				log.Printf("Warning: alert rule %s on %s: %v", rule.ID, symbol, err)
+				continue
+			}
+			if alert != nil {
+				alerts = append(alerts, *alert)
+			}
+		}
+	}
+	return alerts, nil
+}
+
+// heldAndWatchedSymbols returns the symbols a user holds or watches
+func (s *AlertService) heldAndWatchedSymbols(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT split_part(pc.symbol, '.', 1)
+		FROM positions_current pc
+		JOIN portfolios p ON p.id = pc.portfolio_id
+		WHERE p.user_id = $1 AND COALESCE(p.is_paper, false) = false AND pc.total_quantity > 0
+		UNION
+		SELECT i.symbol
+		FROM watchlist_items i
+		JOIN watchlists w ON w.id = i.watchlist_id
+		WHERE w.user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query held and watched symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols, rows.Err()
+}
+
+// latestRuleBars loads the latest daily bar of each symbol with its change,
+// volume ratio (against the previous 20 bars, as DetectVolumeSpike does) and
+// indicators
+func (s *AlertService) latestRuleBars(ctx context.Context, needed map[string]bool) (map[string]*alertRuleBar, error) {
+	symbols := make([]string, 0, len(needed))
+	for sym := range needed {
+		symbols = append(symbols, sym)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH d AS (
+			SELECT symbol, timestamp, close, volume,
+			       ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp DESC) AS rn
+			FROM stock_ohlcv
+			WHERE symbol = ANY($1) AND timestamp >= NOW() - INTERVAL '60 days'
+		)
+		SELECT symbol,
+		       MAX(timestamp) FILTER (WHERE rn = 1),
+		       MAX(close) FILTER (WHERE rn = 1),
+		       MAX(close) FILTER (WHERE rn = 2),
+		       COALESCE(MAX(volume) FILTER (WHERE rn = 1), 0),
+		       COALESCE(AVG(volume) FILTER (WHERE rn BETWEEN 2 AND 21), 0)::float8
+		FROM d
+		GROUP BY symbol
+	`, pq.Array(symbols))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query latest bars: %w", err)
+	}
+	defer rows.Close()
+
+	bars := make(map[string]*alertRuleBar, len(symbols))
+	for rows.Next() {
+		var symbol string
+		var prevClose sql.NullFloat64
+		var avgVolume float64
+		b := &alertRuleBar{}
+		if err := rows.Scan(&symbol, &b.date, &b.close, &prevClose, &b.volume, &avgVolume); err != nil {
+			continue
+		}
+		if prevClose.Valid && prevClose.Float64 > 0 {
+			b.hasPrevious = true
+			b.changePercent = (b.close - prevClose.Float64) / prevClose.Float64 * 100
+		}
+		if avgVolume > 0 {
+			b.volumeRatio = float64(b.volume) / avgVolume
+		}
+		bars[symbol] = b
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return bars, s.loadRuleIndicators(ctx, bars)
+}
+
+// quoteRuleBars builds today's bars from realtime quotes
+func (s *AlertService) quoteRuleBars(ctx context.Context, needed map[string]bool, fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) (map[string]*alertRuleBar, error) {
+	symbols := make([]string, 0, len(needed))
+	for sym := range needed {
+		symbols = append(symbols, sym)
+	}
+	if len(symbols) == 0 {
+		return map[string]*alertRuleBar{}, nil
+	}
+
+	quotes, err := fetchQuotes(ctx, symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quotes: %w", err)
+	}
+
+	taipei := time.FixedZone("Asia/Taipei", 8*3600)
+	today := time.Now().In(taipei).Format("2006-01-02")
+	avgVolumes := make(map[string]float64, len(symbols))
+	rows, err := s.db.QueryContext(ctx, `
+		WITH d AS (
+			SELECT symbol, volume, ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp DESC) AS rn
+			FROM stock_ohlcv
+			WHERE symbol = ANY($1) AND timestamp >= NOW() - INTERVAL '60 days' AND timestamp < $2::date
+		)
+		SELECT symbol, COALESCE(AVG(volume) FILTER (WHERE rn <= 20), 0)::float8
+		FROM d
+		GROUP BY symbol
+	`, pq.Array(symbols), today)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query average volumes: %w", err)
+	}
+	for rows.Next() {
+		var symbol string
+		var avg float64
+		if err := rows.Scan(&symbol, &avg); err == nil {
+			avgVolumes[symbol] = avg
+		}
+	}
+	rows.Close()
+
+	bars := make(map[string]*alertRuleBar, len(quotes))
+	for _, q := range quotes {
+		if q == nil {
+			continue
+		}
+		price, _ := q.Price.Float64()
+		if price <= 0 {
+			continue // No trade yet
+		}
+		prevClose, _ := q.PrevClose.Float64()
+		b := &alertRuleBar{date: time.Now().In(taipei), close: price, volume: q.Volume}
+		if !q.TradeTime.IsZero() {
+			b.date = q.TradeTime.In(taipei)
+		}
+		if prevClose > 0 {
+			b.hasPrevious = true
+			b.changePercent = (price - prevClose) / prevClose * 100
+		}
+		if avg := avgVolumes[q.Symbol]; avg > 0 {
+			b.volumeRatio = float64(q.Volume) / avg
+		}
+		bars[q.Symbol] = b
+	}
+	// Live prices against the last close's MAs
+	return bars, s.loadRuleIndicators(ctx, bars)
+}
+
+// raiseRuleAlert stores the alert for a matched rule unless it already fired
+// for the symbol on the bar's date; nil means it had
+func (s *AlertService) raiseRuleAlert(ctx context.Context, rule *AlertRule, symbol string, bar *alertRuleBar, values map[string]float64) (*StockAlert, error) {
+	barDate := bar.date.In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02")
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM stock_alerts
+			WHERE alert_type = $1 AND data->>'rule_id' = $2 AND symbol = $3 AND data->>'bar_date' = $4
+		)
+	`, string(AlertTypeUserRule), rule.ID, symbol, barDate).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check previous alerts: %w", err)
+	}
+	if exists {
+		return nil, nil
+	}
+
+	fields := map[string]interface{}{
+		"rule_id":   rule.ID,
+		"user_id":   rule.UserID,
+		"rule_name": rule.Name,
+		"bar_date":  barDate,
+	}
+	if rule.StrategyID != "" {
+		fields["strategy_id"] = rule.StrategyID
+		fields["strategy_version"] = rule.StrategyVersion
+	}
+	var message string
+	if rule.Conditions != nil {
+		rounded := make(map[string]float64, len(values))
+		labels := make([]string, 0, len(values))
+		for metric, v := range values {
+			rounded[metric] = roundTo(v, 4)
+			labels = append(labels, fmt.Sprintf("%s %.2f", alertRuleMetricLabels[metric], v))
+		}
+		sort.Strings(labels)
+		fields["conditions"] = rule.Conditions
+		fields["values"] = rounded
+		message = fmt.Sprintf("%s，符合條件 %s", strings.Join(labels, "、"), rule.condition())
+	} else {
+		value := values[rule.Metric]
+		fields["metric"] = rule.Metric
+		fields["operator"] = rule.Operator
+		fields["threshold"] = rule.Threshold
+		fields["value"] = roundTo(value, 4)
+		message = fmt.Sprintf("%s 為 %.2f，符合條件 %s", alertRuleMetricLabels[rule.Metric], value, rule.condition())
+	}
+	data, _ := json.Marshal(fields)
+	alert := &StockAlert{
+		Symbol:          symbol,
+		AlertType:       AlertTypeUserRule,
+		Severity:        rule.Severity,
+		Title:           fmt.Sprintf("%s %s", symbol, rule.Name),
+		Message:         message,
+		Data:            data,
+		ReferencePrice:  bar.close,
+		ReferenceVolume: bar.volume,
+	}
+	// threshold_value is DECIMAL(10,4); share-count thresholds only go in data
+	if rule.Conditions == nil && rule.Metric != AlertRuleMetricVolume {
+		alert.ThresholdValue = rule.Threshold
+	}
+	if err := s.CreateAlert(ctx, alert); err != nil {
+		return nil, fmt.Errorf("failed to create alert: %w", err)
+	}
+
+	s.db.ExecContext(ctx, `
+		UPDATE alert_rules SET last_triggered_at = NOW(), trigger_count = trigger_count + 1 WHERE id = $1
+	`, rule.ID)
+	return alert, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// alertInsertBatch is how many alerts one CreateAlerts statement inserts
+const alertInsertBatch = 500
+
+// scanVolumeAndPrice runs the volume spike and 52-week detectors over every
+// symbol in scope (nil: all) with one query, instead of two per symbol, and
+// stores the alerts in batches. The analyses match DetectVolumeSpike and
+// DetectPriceBreakout; threshold <= 0 uses the configured volume_spike_ratio.
+func (s *AlertService) scanVolumeAndPrice(ctx context.Context, scoped []string, threshold float64, result *ScanResult) error {
+	thresholds := s.Thresholds(ctx)
+	if threshold <= 0 {
+		threshold = thresholds.VolumeSpikeRatio
+	}
+	nearThreshold := thresholds.Price52wProximityPct / 100
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH d AS (
+			SELECT symbol, timestamp, close, high, low, volume,
+			       ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp DESC) AS rn
+			FROM stock_ohlcv
+			WHERE timestamp >= NOW() - INTERVAL '365 days' AND ($1 OR symbol = ANY($2))
+			  AND `+notIndexSymbolSQL+`
+		)
+		SELECT symbol,
+		       MAX(timestamp) FILTER (WHERE rn = 1),
+		       COALESCE(MAX(close) FILTER (WHERE rn = 1), 0)::float8,
+		       COALESCE(MAX(close) FILTER (WHERE rn = 2), 0)::float8,
+		       COALESCE(MAX(volume) FILTER (WHERE rn = 1), 0),
+		       COALESCE(AVG(volume) FILTER (WHERE rn BETWEEN 2 AND 21), 0)::bigint,
+		       COALESCE(MAX(high), 0)::float8,
+		       COALESCE(MIN(low), 0)::float8
+		FROM d
+		GROUP BY symbol
+		HAVING MAX(timestamp) FILTER (WHERE rn = 1) >= NOW() - INTERVAL '5 days'
+	`, scoped == nil, pq.Array(scoped))
+	if err != nil {
+		return fmt.Errorf("failed to scan volume and price: %w", err)
+	}
+
+	var spikes []*VolumeAnalysis
+	var breakouts []*PriceAnalysis
+	var alerts []*StockAlert
+	owner := map[*StockAlert]interface{}{} // The analysis each alert came from
+	for rows.Next() {
+		var symbol string
+		var barDate time.Time
+		var price, prevClose, high52, low52 float64
+		var volume, avgVolume int64
+		if err := rows.Scan(&symbol, &barDate, &price, &prevClose, &volume, &avgVolume, &high52, &low52); err != nil {
+			continue
+		}
+
+		if avgVolume > 0 {
+			ratio := float64(volume) / float64(avgVolume)
+			if ratio >= threshold {
+				spike := &VolumeAnalysis{
+					Symbol:          symbol,
+					CurrentVolume:   volume,
+					AvgVolume20Days: avgVolume,
+					VolumeRatio:     ratio,
+					IsSpike:         true,
+					SpikeThreshold:  threshold,
+				}
+				spikes = append(spikes, spike)
+				alert := volumeSpikeAlert(spike, thresholds, barDate)
+				alerts = append(alerts, alert)
+				owner[alert] = spike
+			}
+		}
+
+		isNearHigh := high52 > 0 && (high52-price)/high52 <= nearThreshold
+		isNearLow := low52 > 0 && (price-low52)/low52 <= nearThreshold
+		if isNearHigh || isNearLow {
+			breakout := &PriceAnalysis{
+				Symbol:           symbol,
+				CurrentPrice:     price,
+				PreviousClose:    prevClose,
+				High52Week:       high52,
+				Low52Week:        low52,
+				IsNear52WeekHigh: isNearHigh,
+				IsNear52WeekLow:  isNearLow,
+			}
+			if prevClose > 0 {
+				breakout.Change = price - prevClose
+				breakout.ChangePercent = breakout.Change / prevClose * 100
+			}
+			breakouts = append(breakouts, breakout)
+			for _, alert := range priceBreakoutAlerts(breakout, barDate) {
+				alerts = append(alerts, alert)
+				owner[alert] = breakout
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to scan volume and price: %w", err)
+	}
+
+	if err := s.CreateAlerts(ctx, alerts); err != nil {
+		return err
+	}
+	for _, alert := range alerts {
+		if alert.ID != "" {
+			continue
+		}
+		switch a := owner[alert].(type) {
+		case *VolumeAnalysis:
+			a.AlertSuppressed = true
+		case *PriceAnalysis:
+			a.AlertSuppressed = true
+		}
+	}
+
+	for _, spike := range spikes {
+		if spike.AlertSuppressed {
+			result.AlertsSuppressed++
+		} else {
+			result.VolumeSpikes = append(result.VolumeSpikes, *spike)
+		}
+	}
+	for _, breakout := range breakouts {
+		if breakout.AlertSuppressed {
+			result.AlertsSuppressed++
+		} else {
+			result.PriceBreakouts = append(result.PriceBreakouts, *breakout)
+		}
+	}
+	return nil
+}
+
+// CreateAlerts stores alerts like CreateAlert, a batch per statement. Created
+// alerts get their ID; those left without one were duplicates. Alerts without
+// a dedup key are created one by one.
+func (s *AlertService) CreateAlerts(ctx context.Context, alerts []*StockAlert) error {
+	byKey := make(map[string]*StockAlert, len(alerts))
+	var keyed []*StockAlert
+	for _, alert := range alerts {
+		if alert.DedupKey == "" || byKey[alert.DedupKey] != nil {
+			if err := s.CreateAlert(ctx, alert); err != nil && err != ErrAlertDuplicate {
+				return fmt.Errorf("failed to create alert: %w", err)
+			}
+			continue
+		}
+		byKey[alert.DedupKey] = alert
+		keyed = append(keyed, alert)
+	}
+
+	for start := 0; start < len(keyed); start += alertInsertBatch {
+		end := start + alertInsertBatch
+		if end > len(keyed) {
+			end = len(keyed)
+		}
+		if err := s.insertAlertBatch(ctx, keyed[start:end], byKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *AlertService) insertAlertBatch(ctx context.Context, batch []*StockAlert, byKey map[string]*StockAlert) error {
+	n := len(batch)
+	symbols, types, severities := make([]string, n), make([]string, n), make([]string, n)
+	titles, messages, data, keys := make([]string, n), make([]string, n), make([]string, n), make([]string, n)
+	prices, thresholds := make([]float64, n), make([]float64, n)
+	volumes := make([]int64, n)
+	for i, a := range batch {
+		symbols[i], types[i], severities[i] = a.Symbol, string(a.AlertType), string(a.Severity)
+		titles[i], messages[i], keys[i] = a.Title, a.Message, a.DedupKey
+		data[i] = string(a.Data)
+		if len(a.Data) == 0 {
+			data[i] = "{}"
+		}
+		prices[i], volumes[i], thresholds[i] = a.ReferencePrice, a.ReferenceVolume, a.ThresholdValue
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		INSERT INTO stock_alerts (symbol, alert_type, severity, title, message, data, reference_price, reference_volume, threshold_value, dedup_key, snoozed_until)
+		SELECT i.symbol, i.alert_type, i.severity, i.title, i.message, i.data::jsonb, i.reference_price,
+		       i.reference_volume, i.threshold_value, i.dedup_key, (
+			SELECT MAX(a.snoozed_until) FROM stock_alerts a
+			WHERE a.symbol = i.symbol AND a.alert_type = i.alert_type AND a.snoozed_until > NOW()
+		)
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[], $5::text[], $6::text[], $7::float8[], $8::int8[],
+		            $9::float8[], $10::text[])
+			AS i(symbol, alert_type, severity, title, message, data, reference_price, reference_volume, threshold_value, dedup_key)
+		WHERE $11::float8 <= 0 OR NOT EXISTS (
+			SELECT 1 FROM stock_alerts a
+			WHERE a.dedup_key = i.dedup_key AND a.triggered_at > NOW() - make_interval(secs => $11::float8)
+		)
+		RETURNING id, dedup_key, triggered_at, snoozed_until
+	`, pq.Array(symbols), pq.Array(types), pq.Array(severities), pq.Array(titles), pq.Array(messages), pq.Array(data),
+		pq.Array(prices), pq.Array(volumes), pq.Array(thresholds), pq.Array(keys), s.dedupWindow.Seconds())
+	if err != nil {
+		return fmt.Errorf("failed to create alerts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, key string
+		var triggeredAt time.Time
+		var snoozedUntil *time.Time
+		if err := rows.Scan(&id, &key, &triggeredAt, &snoozedUntil); err != nil {
+			return fmt.Errorf("failed to scan created alert: %w", err)
+		}
+		if a := byKey[key]; a != nil {
+			a.ID, a.TriggeredAt, a.SnoozedUntil = id, triggeredAt, snoozedUntil
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Kinds of background alert scans
+const (
+	AlertScanIntraday = "intraday"
+	AlertScanEOD      = "eod"
+)
+
+// alertScanQuoteBatch is how many symbols one realtime quote request covers
+const alertScanQuoteBatch = 50
+
+// AlertScanWorker runs the alert scan in the background: an intraday scan
+// against realtime quotes every few minutes while the market is open, a
+// limit-up/down check of held and watched stocks and of positions' stop-loss
+// and take-profit levels every minute, and a full end-of-day scan once the
+// day's bars have been synced.
+type AlertScanWorker struct {
+	alertService    *AlertService
+	realtimeService *RealtimeService
+	interval        time.Duration
+	eodAfterHour    int // Earliest Taipei hour for the end-of-day scan
+	volumeThreshold float64
+	scope           string // End-of-day scan scope, see ParseAlertScanScope
+	mu              sync.Mutex
+	isRunning       bool
+	isBusy          bool
+	lastIntraday    time.Time
+	stopChan        chan struct{}
+}
+
+// AlertScanRun is one recorded background scan
+type AlertScanRun struct {
+	ID              string     `json:"id"`
+	Kind            string     `json:"kind"`
+	TradingDate     string     `json:"trading_date"`
+	Status          string     `json:"status"` // running, completed, failed
+	SymbolsScanned  int        `json:"symbols_scanned"`
+	AlertsGenerated int        `json:"alerts_generated"`
+	Error           string     `json:"error,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at,omitempty"`
+}
+
+// AlertScanWorkerStatus is the scanner state and its latest runs
+type AlertScanWorkerStatus struct {
+	Running         bool          `json:"running"`
+	Interval        string        `json:"interval"`
+	EODAfterHour    int           `json:"eod_after_hour"`
+	VolumeThreshold float64       `json:"volume_threshold"`
+	Scope           string        `json:"scope"`
+	MarketStatus    string        `json:"market_status"`
+	LastIntraday    *AlertScanRun `json:"last_intraday,omitempty"`
+	LastEOD         *AlertScanRun `json:"last_eod,omitempty"`
+}
+
+// NewAlertScanWorker reads ALERT_SCAN_INTERVAL (minutes, default 5),
+// ALERT_SCAN_EOD_HOUR (earliest Taipei hour, default 15),
+// ALERT_SCAN_VOLUME_THRESHOLD (default the configured volume_spike_ratio) and
+// ALERT_SCAN_SCOPE (default all)
+func NewAlertScanWorker(alertService *AlertService, realtimeService *RealtimeService) *AlertScanWorker {
+	interval := 5
+	if v, err := strconv.Atoi(os.Getenv("ALERT_SCAN_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+	eodHour := 15
+	if v, err := strconv.Atoi(os.Getenv("ALERT_SCAN_EOD_HOUR")); err == nil && v >= 0 && v < 24 {
+		eodHour = v
+	}
+	threshold := 0.0
+	if v, err := strconv.ParseFloat(os.Getenv("ALERT_SCAN_VOLUME_THRESHOLD"), 64); err == nil && v > 0 {
+		threshold = v
+	}
+	scope, err := ParseAlertScanScope(os.Getenv("ALERT_SCAN_SCOPE"))
+	if err != nil {
+		log.Printf("Alert scan worker: %v; scanning all symbols", err)
+		scope = AlertScanScopeAll
+	}
+	return &AlertScanWorker{
+		alertService:    alertService,
+		realtimeService: realtimeService,
+		interval:        time.Duration(interval) * time.Minute,
+		eodAfterHour:    eodHour,
+		volumeThreshold: threshold,
+		scope:           scope,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// Start launches the scan loop
+func (w *AlertScanWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Alert scan worker started (intraday every %v, end of day after %02d:00)", w.interval, w.eodAfterHour)
+}
+
+// Stop stops the scan loop
+func (w *AlertScanWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *AlertScanWorker) loop() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+func (w *AlertScanWorker) checkAndRun() {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	lastIntraday := w.lastIntraday
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	if w.realtimeService.GetMarketStatus().IsOpen {
+		w.checkLimitHits()
+		w.checkPositionLevels()
+		if time.Since(lastIntraday) >= w.interval {
+			w.run(AlertScanIntraday)
+		}
+		return
+	}
+
+	loc := time.FixedZone("Asia/Taipei", 8*3600)
+	now := time.Now().In(loc)
+	if now.Hour() < w.eodAfterHour {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	today := now.Format("2006-01-02")
+	var hasBars, scanned bool
+	if err := w.alertService.db.QueryRowContext(ctx,
+		`SELECT EXISTS (SELECT 1 FROM stock_ohlcv WHERE timestamp >= $1::date AND timestamp < $1::date + 1 AND `+notIndexSymbolSQL+`)`, today,
+	).Scan(&hasBars); err != nil || !hasBars {
+		return
+	}
+	// Done for today, or failed recently enough that a retry should wait
+	if err := w.alertService.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM alert_scan_runs
+			WHERE kind = $1 AND trading_date = $2
+			  AND (status = 'completed' OR started_at > NOW() - INTERVAL '30 minutes')
+		)`, AlertScanEOD, today,
+	).Scan(&scanned); err != nil || scanned {
+		return
+	}
+	w.run(AlertScanEOD)
+}
+
+// run performs one scan of the given kind and records it in alert_scan_runs
+func (w *AlertScanWorker) run(kind string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	if kind == AlertScanIntraday {
+		w.mu.Lock()
+		w.lastIntraday = time.Now()
+		w.mu.Unlock()
+	}
+
+	today := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02")
+	var runID string
+	if err := w.alertService.db.QueryRowContext(ctx,
+		`INSERT INTO alert_scan_runs (kind, trading_date) VALUES ($1, $2) RETURNING id`, kind, today,
+	).Scan(&runID); err != nil {
+		log.Printf("Alert scan worker: failed to record %s scan: %v", kind, err)
+		return
+	}
+
+	var result *ScanResult
+	var err error
+	if kind == AlertScanEOD {
+		result, err = w.alertService.ScanAllSymbols(ctx, w.volumeThreshold, w.scope)
+	} else {
+		result, err = w.alertService.ScanIntraday(ctx, w.fetchQuotes)
+	}
+
+	status, errMsg := "completed", sql.NullString{}
+	symbols, alerts := 0, 0
+	if err != nil {
+		status = "failed"
+		errMsg = sql.NullString{String: err.Error(), Valid: true}
+		log.Printf("Alert scan worker: %s scan failed: %v", kind, err)
+	} else {
+		symbols, alerts = result.TotalSymbols, result.AlertsGenerated
+		if alerts > 0 {
+			log.Printf("Alert scan worker: %s scan of %d symbols raised %d alerts", kind, symbols, alerts)
+		}
+	}
+
+	if _, err := w.alertService.db.ExecContext(ctx, `
+		UPDATE alert_scan_runs
+		SET status = $2, symbols_scanned = $3, alerts_generated = $4, error = $5, completed_at = NOW()
+		WHERE id = $1
+	`, runID, status, symbols, alerts, errMsg); err != nil {
+		log.Printf("Alert scan worker: failed to record %s scan: %v", kind, err)
+	}
+	w.alertService.db.ExecContext(ctx, `DELETE FROM alert_scan_runs WHERE started_at < NOW() - INTERVAL '30 days'`)
+}
+
+// checkLimitHits raises limit hit alerts; it runs every tick so a lock is
+// reported within a minute rather than at the next intraday scan
+func (w *AlertScanWorker) checkLimitHits() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	alerts, err := w.alertService.ScanLimitHits(ctx, w.fetchQuotes)
+	if err != nil {
+		log.Printf("Alert scan worker: limit check failed: %v", err)
+		return
+	}
+	for _, a := range alerts {
+		log.Printf("Alert scan worker: %s", a.Title)
+	}
+}
+
+// checkPositionLevels raises stop-loss and take-profit alerts; like limit hits
+// they are checked every tick
+func (w *AlertScanWorker) checkPositionLevels() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	alerts, err := w.alertService.CheckPositionLevels(ctx, w.fetchQuotes)
+	if err != nil {
+		log.Printf("Alert scan worker: position level check failed: %v", err)
+		return
+	}
+	for _, a := range alerts {
+		log.Printf("Alert scan worker: %s", a.Title)
+	}
+}
+
+// fetchQuotes fetches realtime quotes a batch at a time
+func (w *AlertScanWorker) fetchQuotes(ctx context.Context, symbols []string) ([]*RealtimeQuote, error) {
+	var quotes []*RealtimeQuote
+	for start := 0; start < len(symbols); start += alertScanQuoteBatch {
+		end := start + alertScanQuoteBatch
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		batch, err := w.realtimeService.FetchMultipleQuotes(ctx, symbols[start:end])
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, batch...)
+	}
+	return quotes, nil
+}
+
+// GetStatus returns the scanner state and its latest intraday and end-of-day runs
+func (w *AlertScanWorker) GetStatus(ctx context.Context) AlertScanWorkerStatus {
+	w.mu.Lock()
+	status := AlertScanWorkerStatus{
+		Running:         w.isRunning,
+		Interval:        w.interval.String(),
+		EODAfterHour:    w.eodAfterHour,
+		VolumeThreshold: w.volumeThreshold,
+		Scope:           w.scope,
+	}
+	w.mu.Unlock()
+
+	if status.VolumeThreshold <= 0 {
+		status.VolumeThreshold = w.alertService.Thresholds(ctx).VolumeSpikeRatio
+	}
+	status.MarketStatus = w.realtimeService.GetMarketStatus().Status
+	status.LastIntraday = w.lastRun(ctx, AlertScanIntraday)
+	status.LastEOD = w.lastRun(ctx, AlertScanEOD)
+	return status
+}
+
+func (w *AlertScanWorker) lastRun(ctx context.Context, kind string) *AlertScanRun {
+	var run AlertScanRun
+	var tradingDate time.Time
+	var errMsg sql.NullString
+	var completedAt sql.NullTime
+	err := w.alertService.db.QueryRowContext(ctx, `
+		SELECT id, kind, trading_date, status, symbols_scanned, alerts_generated, error, started_at, completed_at
+		FROM alert_scan_runs
+		WHERE kind = $1
+		ORDER BY started_at DESC
+		LIMIT 1
+	`, kind).Scan(&run.ID, &run.Kind, &tradingDate, &run.Status, &run.SymbolsScanned, &run.AlertsGenerated,
+		&errMsg, &run.StartedAt, &completedAt)
+	if err != nil {
+		return nil
+	}
+	run.TradingDate = tradingDate.Format("2006-01-02")
+	run.Error = errMsg.String
+	if completedAt.Valid {
+		run.CompletedAt = &completedAt.Time
+	}
+	return &run
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// alertNotifyDeferLookback is how far back alerts held by a schedule are still
+// sent; it covers a weekend outside a trading-day window
+const alertNotifyDeferLookback = 4 * 24 * time.Hour
+
+// alertBatchMaxListed is how many alerts a LINE or Telegram batch lists in
+// full, keeping the message within the services' length limits
+const alertBatchMaxListed = 10
+
+// AlertNotificationSchedule is the window, in Taipei time, during which a
+// user's alerts are pushed. Alerts raised outside it wait until it opens.
+type AlertNotificationSchedule struct {
+	Enabled         bool       `json:"enabled"`
+	WindowStart     string     `json:"window_start"` // HH:MM
+	WindowEnd       string     `json:"window_end"`   // At or before the start: the window runs past midnight
+	TradingDaysOnly bool       `json:"trading_days_only"`
+	AllowCritical   bool       `json:"allow_critical"` // Push critical alerts outside the window too
+	Digest          bool       `json:"digest"`         // Send held alerts as one message per channel
+	Open            bool       `json:"open"`           // Whether the window is open now
+	UpdatedAt       *time.Time `json:"updated_at,omitempty"`
+}
+
+// AlertNotificationScheduleRequest updates a schedule; omitted fields are unchanged
+type AlertNotificationScheduleRequest struct {
+	Enabled         *bool   `json:"enabled"`
+	WindowStart     *string `json:"window_start"`
+	WindowEnd       *string `json:"window_end"`
+	TradingDaysOnly *bool   `json:"trading_days_only"`
+	AllowCritical   *bool   `json:"allow_critical"`
+	Digest          *bool   `json:"digest"`
+}
+
+// window reports whether the schedule's window is open at now and, if so,
+// when it opened. Trading days are weekdays.
+func (sc *AlertNotificationSchedule) window(now time.Time) (bool, time.Time) {
+	taipei := time.FixedZone("Asia/Taipei", 8*3600)
+	now = now.In(taipei)
+	start, _ := time.Parse("15:04", sc.WindowStart)
+	end, _ := time.Parse("15:04", sc.WindowEnd)
+
+	// A window past midnight belongs to the day it starts on
+	for _, offset := range []int{0, -1} {
+		day := now.AddDate(0, 0, offset)
+		if sc.TradingDaysOnly && (day.Weekday() == time.Saturday || day.Weekday() == time.Sunday) {
+			continue
+		}
+		opens := time.Date(day.Year(), day.Month(), day.Day(), start.Hour(), start.Minute(), 0, 0, taipei)
+		closes := time.Date(day.Year(), day.Month(), day.Day(), end.Hour(), end.Minute(), 0, 0, taipei)
+		if !closes.After(opens) {
+			closes = closes.AddDate(0, 0, 1)
+		}
+		if !now.Before(opens) && now.Before(closes) {
+			return true, opens
+		}
+	}
+	return false, time.Time{}
+}
+
+// parseScheduleTime validates an HH:MM time of day
+func parseScheduleTime(field, value string) (string, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q (expected HH:MM)", field, value)
+	}
+	return t.Format("15:04"), nil
+}
+
+// GetSchedule returns a user's notification schedule; without one alerts are
+// pushed at any time, shown as a disabled default schedule
+func (s *AlertNotificationService) GetSchedule(ctx context.Context, userID uuid.UUID) (*AlertNotificationSchedule, error) {
+	sc := &AlertNotificationSchedule{
+		WindowStart:     "08:30",
+		WindowEnd:       "14:00",
+		TradingDaysOnly: true,
+		AllowCritical:   true,
+		Digest:          true,
+	}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT enabled, to_char(window_start, 'HH24:MI'), to_char(window_end, 'HH24:MI'), trading_days_only,
+		       allow_critical, digest, updated_at
+		FROM alert_notification_schedules
+		WHERE user_id = $1
+	`, userID).Scan(&sc.Enabled, &sc.WindowStart, &sc.WindowEnd, &sc.TradingDaysOnly, &sc.AllowCritical, &sc.Digest,
+		&sc.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to query notification schedule: %w", err)
+	}
+	sc.Open = !sc.Enabled
+	if sc.Enabled {
+		sc.Open, _ = sc.window(time.Now())
+	}
+	return sc, nil
+}
+
+// UpdateSchedule saves a user's notification schedule
+func (s *AlertNotificationService) UpdateSchedule(ctx context.Context, userID uuid.UUID, req AlertNotificationScheduleRequest) (*AlertNotificationSchedule, error) {
+	sc, err := s.GetSchedule(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if sc.UpdatedAt == nil {
+		sc.Enabled = true // Saving a schedule turns it on unless told otherwise
+	}
+	if req.Enabled != nil {
+		sc.Enabled = *req.Enabled
+	}
+	if req.WindowStart != nil {
+		if sc.WindowStart, err = parseScheduleTime("window_start", *req.WindowStart); err != nil {
+			return nil, err
+		}
+	}
+	if req.WindowEnd != nil {
+		if sc.WindowEnd, err = parseScheduleTime("window_end", *req.WindowEnd); err != nil {
+			return nil, err
+		}
+	}
+	if sc.WindowStart == sc.WindowEnd {
+		return nil, fmt.Errorf("window_start and window_end must differ")
+	}
+	if req.TradingDaysOnly != nil {
+		sc.TradingDaysOnly = *req.TradingDaysOnly
+	}
+	if req.AllowCritical != nil {
+		sc.AllowCritical = *req.AllowCritical
+	}
+	if req.Digest != nil {
+		sc.Digest = *req.Digest
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO alert_notification_schedules (user_id, enabled, window_start, window_end, trading_days_only, allow_critical, digest)
+		VALUES ($1, $2, $3::time, $4::time, $5, $6, $7)
+		ON CONFLICT (user_id) DO UPDATE SET
+			enabled = EXCLUDED.enabled,
+			window_start = EXCLUDED.window_start,
+			window_end = EXCLUDED.window_end,
+			trading_days_only = EXCLUDED.trading_days_only,
+			allow_critical = EXCLUDED.allow_critical,
+			digest = EXCLUDED.digest
+	`, userID, sc.Enabled, sc.WindowStart, sc.WindowEnd, sc.TradingDaysOnly, sc.AllowCritical, sc.Digest); err != nil {
+		return nil, fmt.Errorf("failed to save notification schedule: %w", err)
+	}
+	return s.GetSchedule(ctx, userID)
+}
+
+// activeSchedules returns the enabled schedules by user
+func (s *AlertNotificationService) activeSchedules(ctx context.Context) (map[uuid.UUID]*AlertNotificationSchedule, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT user_id, to_char(window_start, 'HH24:MI'), to_char(window_end, 'HH24:MI'), trading_days_only,
+		       allow_critical, digest
+		FROM alert_notification_schedules
+		WHERE enabled
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notification schedules: %w", err)
+	}
+	defer rows.Close()
+
+	schedules := map[uuid.UUID]*AlertNotificationSchedule{}
+	for rows.Next() {
+		var userID uuid.UUID
+		sc := &AlertNotificationSchedule{Enabled: true}
+		if err := rows.Scan(&userID, &sc.WindowStart, &sc.WindowEnd, &sc.TradingDaysOnly, &sc.AllowCritical,
+			&sc.Digest); err != nil {
+			continue
+		}
+		schedules[userID] = sc
+	}
+	return schedules, rows.Err()
+}
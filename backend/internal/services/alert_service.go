@@ -2,19 +2,47 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"psm-backend/internal/database"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+// ErrAlertDuplicate is returned by CreateAlert when an alert with the same
+// dedup key was raised within the dedup window
+var ErrAlertDuplicate = errors.New("duplicate alert suppressed")
+
 // AlertService handles anomaly detection and alerts
 type AlertService struct {
-	db *database.DB
+	db               *database.DB
+	dedupWindow      time.Duration // 0 disables deduplication
+	intradayCooldown time.Duration // Per symbol, between intraday detector alerts
+	thresholdsMu     sync.Mutex
+	thresholds       *AlertThresholds // See Thresholds
+	thresholdsAt     time.Time
 }
 
+// NewAlertService reads ALERT_DEDUP_WINDOW_HOURS (default 24, 0 disables) and
+// ALERT_INTRADAY_COOLDOWN (minutes, default 60, 0 disables)
 func NewAlertService(db *database.DB) *AlertService {
-	return &AlertService{db: db}
+	dedupWindow := 24 * time.Hour
+	if v, err := strconv.Atoi(os.Getenv("ALERT_DEDUP_WINDOW_HOURS")); err == nil && v >= 0 {
+		dedupWindow = time.Duration(v) * time.Hour
+	}
+	cooldown := time.Hour
+	if v, err := strconv.Atoi(os.Getenv("ALERT_INTRADAY_COOLDOWN")); err == nil && v >= 0 {
+		cooldown = time.Duration(v) * time.Minute
+	}
+	return &AlertService{db: db, dedupWindow: dedupWindow, intradayCooldown: cooldown}
 }
 
 // AlertType defines the type of alert
@@ -27,6 +55,8 @@ const (
 	AlertTypeLimitHit       AlertType = "limit_hit"
 	AlertTypeMABreakout     AlertType = "ma_breakout"
 	AlertTypeRSIExtreme     AlertType = "rsi_extreme"
+	AlertTypeAnnouncement   AlertType = "material_announcement"
+	AlertTypeAspectRisk     AlertType = "aspect_risk"
 )
 
 // AlertSeverity defines the severity level
@@ -49,9 +79,22 @@ type StockAlert struct {
 	Data           json.RawMessage `json:"data,omitempty"`
 	TriggeredAt    time.Time       `json:"triggered_at"`
 	AcknowledgedAt *time.Time      `json:"acknowledged_at,omitempty"`
+	SnoozedUntil   *time.Time      `json:"snoozed_until,omitempty"`
 	ReferencePrice float64         `json:"reference_price,omitempty"`
 	ReferenceVolume int64          `json:"reference_volume,omitempty"`
 	ThresholdValue float64         `json:"threshold_value,omitempty"`
+	AIExplanation  string          `json:"ai_explanation,omitempty"` // See AIService.ExplainAlert
+	DedupKey       string          `json:"dedup_key,omitempty"`      // See alertDedupKey
+}
+
+// alertDedupKey identifies a detector alert by symbol, type, side (e.g. high or
+// low for 52-week breakouts) and the Taipei trading day of the bar it fired on
+func alertDedupKey(symbol string, alertType AlertType, side string, barDate time.Time) string {
+	key := symbol + ":" + string(alertType)
+	if side != "" {
+		key += ":" + side
+	}
+	return key + ":" + barDate.In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02")
 }
 
 // VolumeAnalysis represents volume analysis result
@@ -62,6 +105,8 @@ type VolumeAnalysis struct {
 	VolumeRatio      float64 `json:"volume_ratio"`
 	IsSpike          bool    `json:"is_spike"`
 	SpikeThreshold   float64 `json:"spike_threshold"`
+	Source           string  `json:"source,omitempty"` // intraday when detected on realtime quotes
+	AlertSuppressed  bool    `json:"alert_suppressed,omitempty"` // Already alerted for this trading day
 }
 
 // PriceAnalysis represents price analysis result
@@ -75,12 +120,48 @@ type PriceAnalysis struct {
 	Low52Week       float64 `json:"low_52_week"`
 	IsNear52WeekHigh bool   `json:"is_near_52_week_high"`
 	IsNear52WeekLow  bool   `json:"is_near_52_week_low"`
+	Source           string `json:"source,omitempty"` // intraday when detected on realtime quotes
+	AlertSuppressed  bool   `json:"alert_suppressed,omitempty"` // Already alerted for this trading day
+}
+
+// MABreakout is a close crossing a moving average between two trading days
+type MABreakout struct {
+	Period      int     `json:"period"`    // 20 or 60
+	Direction   string  `json:"direction"` // up (crossed above) or down (crossed below)
+	MAValue     float64 `json:"ma_value"`
+	PrevMAValue float64 `json:"prev_ma_value"`
 }
 
-// DetectVolumeSpike detects abnormal volume for a symbol
+// MABreakoutAnalysis represents moving average breakout analysis result
+type MABreakoutAnalysis struct {
+	Symbol          string       `json:"symbol"`
+	Date            string       `json:"date"` // Trading day of the latest snapshot
+	Close           float64      `json:"close"`
+	PrevClose       float64      `json:"prev_close"`
+	MA20            float64      `json:"ma20"`
+	MA60            float64      `json:"ma60"`
+	Breakouts       []MABreakout `json:"breakouts"`
+	AlertSuppressed bool         `json:"alert_suppressed,omitempty"` // Already alerted for this trading day
+}
+
+// RSIAnalysis represents RSI(14) extreme analysis result
+type RSIAnalysis struct {
+	Symbol          string  `json:"symbol"`
+	Date            string  `json:"date"` // Trading day of the latest snapshot
+	RSI             float64 `json:"rsi"`
+	Overbought      float64 `json:"overbought"`
+	Oversold        float64 `json:"oversold"`
+	Zone            string  `json:"zone,omitempty"`             // overbought, oversold or empty
+	DaysInZone      int     `json:"days_in_zone"`               // Consecutive trading days in the zone, including the latest
+	AlertSuppressed bool    `json:"alert_suppressed,omitempty"` // Already alerted for this trading day
+}
+
+// DetectVolumeSpike detects abnormal volume for a symbol; threshold <= 0 uses
+// the configured volume_spike_ratio
 func (s *AlertService) DetectVolumeSpike(ctx context.Context, symbol string, threshold float64) (*VolumeAnalysis, error) {
+	thresholds := s.Thresholds(ctx)
 	if threshold <= 0 {
-		threshold = 2.0 // Default: 2x average volume
+		threshold = thresholds.VolumeSpikeRatio
 	}
 
 	query := `
@@ -95,19 +176,21 @@ func (s *AlertService) DetectVolumeSpike(ctx context.Context, symbol string, thr
 			LIMIT 21
 		),
 		latest AS (
-			SELECT volume FROM recent_data WHERE rn = 1
+			SELECT volume, timestamp FROM recent_data WHERE rn = 1
 		),
 		avg_volume AS (
 			SELECT COALESCE(AVG(volume)::bigint, 0) as avg_vol FROM recent_data WHERE rn > 1
 		)
 		SELECT 
 			COALESCE(l.volume, 0),
-			COALESCE(a.avg_vol, 0)
+			COALESCE(a.avg_vol, 0),
+			l.timestamp
 		FROM latest l, avg_volume a
 	`
 
 	var currentVolume, avgVolume int64
-	err := s.db.QueryRowContext(ctx, query, symbol).Scan(&currentVolume, &avgVolume)
+	var barDate time.Time
+	err := s.db.QueryRowContext(ctx, query, symbol).Scan(&currentVolume, &avgVolume, &barDate)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze volume: %w", err)
 	}
@@ -128,31 +211,39 @@ func (s *AlertService) DetectVolumeSpike(ctx context.Context, symbol string, thr
 
 	// Create alert if spike detected
 	if analysis.IsSpike {
-		alertData, _ := json.Marshal(analysis)
-		severity := AlertSeverityInfo
-		if ratio >= 3.0 {
-			severity = AlertSeverityWarning
-		}
-		if ratio >= 5.0 {
-			severity = AlertSeverityCritical
-		}
-
-		alert := &StockAlert{
-			Symbol:          symbol,
-			AlertType:       AlertTypeVolumeSpike,
-			Severity:        severity,
-			Title:           fmt.Sprintf("%s 成交量異常", symbol),
-			Message:         fmt.Sprintf("成交量達到20日均量的 %.1f 倍", ratio),
-			Data:            alertData,
-			ReferenceVolume: currentVolume,
-			ThresholdValue:  threshold,
+		if err := s.CreateAlert(ctx, volumeSpikeAlert(analysis, thresholds, barDate)); err == ErrAlertDuplicate {
+			analysis.AlertSuppressed = true
 		}
-		s.CreateAlert(ctx, alert)
 	}
 
 	return analysis, nil
 }
 
+// volumeSpikeAlert builds the alert for a volume spike; the severity rises
+// with the ratio
+func volumeSpikeAlert(analysis *VolumeAnalysis, thresholds AlertThresholds, barDate time.Time) *StockAlert {
+	alertData, _ := json.Marshal(analysis)
+	severity := AlertSeverityInfo
+	if analysis.VolumeRatio >= thresholds.VolumeWarningRatio {
+		severity = AlertSeverityWarning
+	}
+	if analysis.VolumeRatio >= thresholds.VolumeCriticalRatio {
+		severity = AlertSeverityCritical
+	}
+
+	return &StockAlert{
+		Symbol:          analysis.Symbol,
+		AlertType:       AlertTypeVolumeSpike,
+		Severity:        severity,
+		Title:           fmt.Sprintf("%s 成交量異常", analysis.Symbol),
+		Message:         fmt.Sprintf("成交量達到20日均量的 %.1f 倍", analysis.VolumeRatio),
+		Data:            alertData,
+		ReferenceVolume: analysis.CurrentVolume,
+		ThresholdValue:  analysis.SpikeThreshold,
+		DedupKey:        alertDedupKey(analysis.Symbol, AlertTypeVolumeSpike, "", barDate),
+	}
+}
+
 // DetectPriceBreakout detects significant price movements
 func (s *AlertService) DetectPriceBreakout(ctx context.Context, symbol string) (*PriceAnalysis, error) {
 	query := `
@@ -177,12 +268,14 @@ func (s *AlertService) DetectPriceBreakout(ctx context.Context, symbol string) (
 			COALESCE((SELECT close FROM price_data WHERE rn = 1), 0),
 			COALESCE((SELECT close FROM price_data WHERE rn = 2), 0),
 			COALESCE(y.high_52, 0),
-			COALESCE(y.low_52, 0)
+			COALESCE(y.low_52, 0),
+			(SELECT timestamp FROM price_data WHERE rn = 1)
 		FROM yearly_range y
 	`
 
 	var currentPrice, prevClose, high52, low52 float64
-	err := s.db.QueryRowContext(ctx, query, symbol).Scan(&currentPrice, &prevClose, &high52, &low52)
+	var latestBar sql.NullTime
+	err := s.db.QueryRowContext(ctx, query, symbol).Scan(&currentPrice, &prevClose, &high52, &low52, &latestBar)
 	if err != nil {
 		return nil, fmt.Errorf("failed to analyze price: %w", err)
 	}
@@ -193,8 +286,8 @@ func (s *AlertService) DetectPriceBreakout(ctx context.Context, symbol string) (
 		changePct = change / prevClose * 100
 	}
 
-	// Near 52-week high/low threshold: within 3%
-	nearThreshold := 0.03
+	// Near 52-week high/low: within price_52w_proximity_pct
+	nearThreshold := s.Thresholds(ctx).Price52wProximityPct / 100
 	isNearHigh := high52 > 0 && (high52-currentPrice)/high52 <= nearThreshold
 	isNearLow := low52 > 0 && (currentPrice-low52)/low52 <= nearThreshold
 
@@ -210,46 +303,355 @@ func (s *AlertService) DetectPriceBreakout(ctx context.Context, symbol string) (
 		IsNear52WeekLow:  isNearLow,
 	}
 
+	barDate := time.Now()
+	if latestBar.Valid {
+		barDate = latestBar.Time
+	}
+
 	// Create alerts if near 52-week extremes
-	if isNearHigh {
-		alertData, _ := json.Marshal(analysis)
-		s.CreateAlert(ctx, &StockAlert{
-			Symbol:         symbol,
+	for _, alert := range priceBreakoutAlerts(analysis, barDate) {
+		if err := s.CreateAlert(ctx, alert); err == ErrAlertDuplicate {
+			analysis.AlertSuppressed = true
+		}
+	}
+
+	return analysis, nil
+}
+
+// priceBreakoutAlerts builds the alerts for a price near its 52-week high or low
+func priceBreakoutAlerts(analysis *PriceAnalysis, barDate time.Time) []*StockAlert {
+	var alerts []*StockAlert
+	alertData, _ := json.Marshal(analysis)
+	if analysis.IsNear52WeekHigh {
+		alerts = append(alerts, &StockAlert{
+			Symbol:         analysis.Symbol,
 			AlertType:      AlertTypePriceBreakout,
 			Severity:       AlertSeverityInfo,
-			Title:          fmt.Sprintf("%s 接近52週新高", symbol),
-			Message:        fmt.Sprintf("目前價格 %.2f 接近52週高點 %.2f", currentPrice, high52),
+			Title:          fmt.Sprintf("%s 接近52週新高", analysis.Symbol),
+			Message:        fmt.Sprintf("目前價格 %.2f 接近52週高點 %.2f", analysis.CurrentPrice, analysis.High52Week),
 			Data:           alertData,
-			ReferencePrice: currentPrice,
+			ReferencePrice: analysis.CurrentPrice,
+			DedupKey:       alertDedupKey(analysis.Symbol, AlertTypePriceBreakout, "high", barDate),
 		})
 	}
-
-	if isNearLow {
-		alertData, _ := json.Marshal(analysis)
-		s.CreateAlert(ctx, &StockAlert{
-			Symbol:         symbol,
+	if analysis.IsNear52WeekLow {
+		alerts = append(alerts, &StockAlert{
+			Symbol:         analysis.Symbol,
 			AlertType:      AlertTypePriceBreakout,
 			Severity:       AlertSeverityWarning,
-			Title:          fmt.Sprintf("%s 接近52週新低", symbol),
-			Message:        fmt.Sprintf("目前價格 %.2f 接近52週低點 %.2f", currentPrice, low52),
+			Title:          fmt.Sprintf("%s 接近52週新低", analysis.Symbol),
+			Message:        fmt.Sprintf("目前價格 %.2f 接近52週低點 %.2f", analysis.CurrentPrice, analysis.Low52Week),
 			Data:           alertData,
-			ReferencePrice: currentPrice,
+			ReferencePrice: analysis.CurrentPrice,
+			DedupKey:       alertDedupKey(analysis.Symbol, AlertTypePriceBreakout, "low", barDate),
 		})
 	}
+	return alerts
+}
+
+// DetectMABreakout detects the close crossing MA20 or MA60 on the latest trading
+// day, comparing the two latest stored indicator snapshots
+func (s *AlertService) DetectMABreakout(ctx context.Context, symbol string) (*MABreakoutAnalysis, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT snapshot_date, close, ma20, ma60
+		FROM indicator_snapshots
+		WHERE symbol = $1
+		ORDER BY snapshot_date DESC
+		LIMIT 2
+	`, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indicator snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	type snapshot struct {
+		date       time.Time
+		close      float64
+		ma20, ma60 sql.NullFloat64
+	}
+	var snaps []snapshot
+	for rows.Next() {
+		var snap snapshot
+		if err := rows.Scan(&snap.date, &snap.close, &snap.ma20, &snap.ma60); err != nil {
+			return nil, fmt.Errorf("failed to scan indicator snapshot: %w", err)
+		}
+		snaps = append(snaps, snap)
+	}
+
+	analysis := &MABreakoutAnalysis{Symbol: symbol, Breakouts: []MABreakout{}}
+	if len(snaps) < 2 {
+		return analysis, nil
+	}
+	cur, prev := snaps[0], snaps[1]
+	analysis.Date = cur.date.Format("2006-01-02")
+	analysis.Close = cur.close
+	analysis.PrevClose = prev.close
+	analysis.MA20 = cur.ma20.Float64
+	analysis.MA60 = cur.ma60.Float64
+
+	for _, ma := range []struct {
+		period    int
+		cur, prev sql.NullFloat64
+	}{
+		{20, cur.ma20, prev.ma20},
+		{60, cur.ma60, prev.ma60},
+	} {
+		if !ma.cur.Valid || !ma.prev.Valid {
+			continue
+		}
+		b := MABreakout{Period: ma.period, MAValue: ma.cur.Float64, PrevMAValue: ma.prev.Float64}
+		switch {
+		case prev.close <= ma.prev.Float64 && cur.close > ma.cur.Float64:
+			b.Direction = "up"
+		case prev.close >= ma.prev.Float64 && cur.close < ma.cur.Float64:
+			b.Direction = "down"
+		default:
+			continue
+		}
+		analysis.Breakouts = append(analysis.Breakouts, b)
+
+		// Falling below the quarterly line matters more than the monthly one
+		severity := AlertSeverityInfo
+		title := fmt.Sprintf("%s 站上MA%d", symbol, b.Period)
+		message := fmt.Sprintf("收盤價 %.2f 突破 %d 日均線 %.2f", cur.close, b.Period, b.MAValue)
+		if b.Direction == "down" {
+			title = fmt.Sprintf("%s 跌破MA%d", symbol, b.Period)
+			message = fmt.Sprintf("收盤價 %.2f 跌破 %d 日均線 %.2f", cur.close, b.Period, b.MAValue)
+			if b.Period == 60 {
+				severity = AlertSeverityWarning
+			}
+		}
+
+		alertData, _ := json.Marshal(map[string]interface{}{
+			"period":        b.Period,
+			"direction":     b.Direction,
+			"ma_value":      b.MAValue,
+			"prev_ma_value": b.PrevMAValue,
+			"close":         cur.close,
+			"prev_close":    prev.close,
+			"bar_date":      analysis.Date,
+		})
+		err := s.CreateAlert(ctx, &StockAlert{
+			Symbol:         symbol,
+			AlertType:      AlertTypeMABreakout,
+			Severity:       severity,
+			Title:          title,
+			Message:        message,
+			Data:           alertData,
+			ReferencePrice: cur.close,
+			ThresholdValue: b.MAValue,
+			DedupKey:       alertDedupKey(symbol, AlertTypeMABreakout, fmt.Sprintf("ma%d_%s", b.Period, b.Direction), cur.date),
+		})
+		if err == ErrAlertDuplicate {
+			analysis.AlertSuppressed = true
+		}
+	}
+
+	return analysis, nil
+}
+
+// DetectRSIExtreme detects RSI(14) above overbought or below oversold on the
+// latest stored indicator snapshot; thresholds <= 0 use the configured zones
+func (s *AlertService) DetectRSIExtreme(ctx context.Context, symbol string, overbought, oversold float64) (*RSIAnalysis, error) {
+	thresholds := s.Thresholds(ctx)
+	if overbought <= 0 {
+		overbought = thresholds.RSIOverbought
+	}
+	if oversold <= 0 {
+		oversold = thresholds.RSIOversold
+	}
+	if oversold >= overbought {
+		return nil, fmt.Errorf("oversold threshold must be below overbought")
+	}
+
+	// Enough history to count a long stay in the zone
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT snapshot_date, rsi14, close
+		FROM indicator_snapshots
+		WHERE symbol = $1
+		ORDER BY snapshot_date DESC
+		LIMIT 60
+	`, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query indicator snapshots: %w", err)
+	}
+	defer rows.Close()
+
+	analysis := &RSIAnalysis{Symbol: symbol, Overbought: overbought, Oversold: oversold}
+	var latestDate time.Time
+	var latestClose float64
+	for rows.Next() {
+		var date time.Time
+		var rsi sql.NullFloat64
+		var closePrice float64
+		if err := rows.Scan(&date, &rsi, &closePrice); err != nil {
+			return nil, fmt.Errorf("failed to scan indicator snapshot: %w", err)
+		}
+		if analysis.Date == "" {
+			if !rsi.Valid {
+				return analysis, nil
+			}
+			latestDate, latestClose = date, closePrice
+			analysis.Date = date.Format("2006-01-02")
+			analysis.RSI = rsi.Float64
+			switch {
+			case rsi.Float64 > overbought:
+				analysis.Zone = "overbought"
+			case rsi.Float64 < oversold:
+				analysis.Zone = "oversold"
+			default:
+				return analysis, nil
+			}
+		}
+		if !rsi.Valid || (analysis.Zone == "overbought" && rsi.Float64 <= overbought) ||
+			(analysis.Zone == "oversold" && rsi.Float64 >= oversold) {
+			break
+		}
+		analysis.DaysInZone++
+	}
+	if analysis.Zone == "" {
+		return analysis, nil
+	}
+
+	severity := AlertSeverityInfo
+	title := fmt.Sprintf("%s RSI 超買", symbol)
+	threshold := overbought
+	if analysis.Zone == "oversold" {
+		title = fmt.Sprintf("%s RSI 超賣", symbol)
+		threshold = oversold
+	}
+	if analysis.RSI >= thresholds.RSIWarningOverbought || analysis.RSI <= thresholds.RSIWarningOversold {
+		severity = AlertSeverityWarning
+	}
+
+	alertData, _ := json.Marshal(analysis)
+	err = s.CreateAlert(ctx, &StockAlert{
+		Symbol:         symbol,
+		AlertType:      AlertTypeRSIExtreme,
+		Severity:       severity,
+		Title:          title,
+		Message:        fmt.Sprintf("RSI(14) 為 %.1f，已連續 %d 個交易日%s (門檻 %.0f)", analysis.RSI, analysis.DaysInZone, rsiZoneLabels[analysis.Zone], threshold),
+		Data:           alertData,
+		ReferencePrice: latestClose,
+		ThresholdValue: threshold,
+		DedupKey:       alertDedupKey(symbol, AlertTypeRSIExtreme, analysis.Zone, latestDate),
+	})
+	analysis.AlertSuppressed = err == ErrAlertDuplicate
 
 	return analysis, nil
 }
 
-// ScanAllSymbols scans all symbols for anomalies
-func (s *AlertService) ScanAllSymbols(ctx context.Context, volumeThreshold float64) (*ScanResult, error) {
-	// Get all symbols with recent data
+var rsiZoneLabels = map[string]string{"overbought": "超買", "oversold": "超賣"}
+
+// Alert scan scopes: every symbol with data, the symbols held or watched by
+// anyone, or one portfolio's holdings / one watchlist's items
+const (
+	AlertScanScopeAll     = "all"
+	AlertScanScopeTracked = "tracked"
+)
+
+// ParseAlertScanScope validates a scan scope: all, tracked, portfolio:<id> or
+// watchlist:<id>. Empty means all.
+func ParseAlertScanScope(scope string) (string, error) {
+	scope = strings.TrimSpace(scope)
+	switch scope {
+	case "", AlertScanScopeAll:
+		return AlertScanScopeAll, nil
+	case AlertScanScopeTracked:
+		return scope, nil
+	}
+	kind, id, ok := strings.Cut(scope, ":")
+	if ok && (kind == "portfolio" || kind == "watchlist") {
+		if _, err := uuid.Parse(id); err == nil {
+			return kind + ":" + strings.ToLower(id), nil
+		}
+	}
+	return "", fmt.Errorf("invalid scan scope %q (expected all, tracked, portfolio:<id> or watchlist:<id>)", scope)
+}
+
+// scopeSymbols returns the symbols a scan scope covers; nil means every symbol
+func (s *AlertService) scopeSymbols(ctx context.Context, scope string) ([]string, error) {
+	kind, id, _ := strings.Cut(scope, ":")
+	var query string
+	switch kind {
+	case AlertScanScopeAll:
+		return nil, nil
+	case AlertScanScopeTracked:
+		query = `
+			SELECT split_part(pc.symbol, '.', 1)
+			FROM positions_current pc
+			JOIN portfolios p ON p.id = pc.portfolio_id
+			WHERE COALESCE(p.is_paper, false) = false AND pc.total_quantity > 0
+			UNION
+			SELECT symbol FROM watchlist_items
+		`
+	case "portfolio":
+		var exists bool
+		if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM portfolios WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to query portfolio: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("portfolio not found")
+		}
+		query = `SELECT DISTINCT split_part(symbol, '.', 1) FROM positions_current WHERE portfolio_id = $1 AND total_quantity > 0`
+	case "watchlist":
+		var exists bool
+		if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM watchlists WHERE id = $1)`, id).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("failed to query watchlist: %w", err)
+		}
+		if !exists {
+			return nil, fmt.Errorf("watchlist not found")
+		}
+		query = `SELECT DISTINCT symbol FROM watchlist_items WHERE watchlist_id = $1`
+	default:
+		return nil, fmt.Errorf("invalid scan scope %q", scope)
+	}
+
+	var args []interface{}
+	if id != "" {
+		args = append(args, id)
+	}
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query scope symbols: %w", err)
+	}
+	defer rows.Close()
+
+	symbols := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols, nil
+}
+
+// ScanAllSymbols scans the symbols in scope (see ParseAlertScanScope) for
+// anomalies. Volume spikes and 52-week proximity are found for all symbols in
+// one query; the indicator detectors still run per symbol. Announcement, news
+// risk and rule alerts are not scoped; they already only concern held/watched
+// symbols or the rule's owner.
+func (s *AlertService) ScanAllSymbols(ctx context.Context, volumeThreshold float64, scope string) (*ScanResult, error) {
+	scope, err := ParseAlertScanScope(scope)
+	if err != nil {
+		return nil, err
+	}
+	scoped, err := s.scopeSymbols(ctx, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the symbols in scope with recent data
 	query := `
 		SELECT DISTINCT symbol 
 		FROM stock_ohlcv 
 		WHERE timestamp >= NOW() - INTERVAL '5 days'
+		  AND ($1 OR symbol = ANY($2))
+		  AND ` + notIndexSymbolSQL + `
 	`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, scoped == nil, pq.Array(scoped))
 	if err != nil {
 		return nil, err
 	}
@@ -265,47 +667,362 @@ func (s *AlertService) ScanAllSymbols(ctx context.Context, volumeThreshold float
 
 	result := &ScanResult{
 		ScannedAt:     time.Now(),
+		Scope:         scope,
 		TotalSymbols:  len(symbols),
 		VolumeSpikes:  []VolumeAnalysis{},
 		PriceBreakouts: []PriceAnalysis{},
+		MABreakouts:    []MABreakoutAnalysis{},
+		RSIExtremes:    []RSIAnalysis{},
+		Announcements:  []StockAlert{},
+		AspectRisks:    []StockAlert{},
+		RuleAlerts:     []StockAlert{},
+	}
+
+	// Volume spikes and 52-week proximity for every symbol at once
+	if err := s.scanVolumeAndPrice(ctx, scoped, volumeThreshold, result); err != nil {
+		return nil, err
 	}
 
 	for _, symbol := range symbols {
-		// Check volume
-		volAnalysis, err := s.DetectVolumeSpike(ctx, symbol, volumeThreshold)
-		if err == nil && volAnalysis.IsSpike {
-			result.VolumeSpikes = append(result.VolumeSpikes, *volAnalysis)
+		// Check MA20/MA60 crossings
+		maAnalysis, err := s.DetectMABreakout(ctx, symbol)
+		if err == nil && len(maAnalysis.Breakouts) > 0 {
+			if maAnalysis.AlertSuppressed {
+				result.AlertsSuppressed++
+			} else {
+				result.MABreakouts = append(result.MABreakouts, *maAnalysis)
+			}
 		}
 
-		// Check price
-		priceAnalysis, err := s.DetectPriceBreakout(ctx, symbol)
-		if err == nil && (priceAnalysis.IsNear52WeekHigh || priceAnalysis.IsNear52WeekLow) {
-			result.PriceBreakouts = append(result.PriceBreakouts, *priceAnalysis)
+		// Check RSI zones
+		rsiAnalysis, err := s.DetectRSIExtreme(ctx, symbol, 0, 0)
+		if err == nil && rsiAnalysis.Zone != "" {
+			if rsiAnalysis.AlertSuppressed {
+				result.AlertsSuppressed++
+			} else {
+				result.RSIExtremes = append(result.RSIExtremes, *rsiAnalysis)
+			}
 		}
 	}
 
-	result.AlertsGenerated = len(result.VolumeSpikes) + len(result.PriceBreakouts)
+	// Material announcements for held/watched symbols are raised as stored alerts
+	announcements, err := s.CreateAnnouncementAlerts(ctx, 24*time.Hour)
+	if err == nil {
+		result.Announcements = announcements
+	}
+
+	// Strongly negative lawsuit / supply chain / management news likewise
+	aspectRisks, err := s.CreateAspectRiskAlerts(ctx, 24*time.Hour)
+	if err == nil {
+		result.AspectRisks = aspectRisks
+	}
+
+	// User-defined threshold rules
+	ruleAlerts, err := s.EvaluateAlertRules(ctx)
+	if err == nil {
+		result.RuleAlerts = ruleAlerts
+	}
+
+	result.AlertsGenerated = len(result.VolumeSpikes) + len(result.PriceBreakouts) + len(result.MABreakouts) + len(result.RSIExtremes) +
+		len(result.Announcements) +
+		len(result.AspectRisks) + len(result.RuleAlerts)
 	return result, nil
 }
 
+// ScanIntraday is the lighter scan run while the market is open: the rules and
+// the volume spike / 52-week detectors (for held and watched symbols) are
+// checked against realtime quotes from fetchQuotes, and new announcements and
+// news risks are raised. The indicator detectors wait for the end-of-day scan.
+func (s *AlertService) ScanIntraday(ctx context.Context, fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) (*ScanResult, error) {
+	result := &ScanResult{
+		ScannedAt:      time.Now(),
+		VolumeSpikes:   []VolumeAnalysis{},
+		PriceBreakouts: []PriceAnalysis{},
+		MABreakouts:    []MABreakoutAnalysis{},
+		RSIExtremes:    []RSIAnalysis{},
+		Announcements:  []StockAlert{},
+		AspectRisks:    []StockAlert{},
+		RuleAlerts:     []StockAlert{},
+	}
+
+	fetchQuotes = cachedQuoteFetcher(fetchQuotes)
+	quoted := map[string]bool{}
+	countQuotes := func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error) {
+		for _, sym := range symbols {
+			quoted[sym] = true
+		}
+		return fetchQuotes(ctx, symbols)
+	}
+
+	ruleAlerts, err := s.EvaluateAlertRulesWithQuotes(ctx, countQuotes)
+	if err != nil {
+		return nil, err
+	}
+	result.RuleAlerts = ruleAlerts
+
+	spikes, breakouts, suppressed, err := s.DetectIntraday(ctx, countQuotes)
+	if err != nil {
+		return nil, err
+	}
+	result.VolumeSpikes, result.PriceBreakouts, result.AlertsSuppressed = spikes, breakouts, suppressed
+	result.TotalSymbols = len(quoted)
+
+	if announcements, err := s.CreateAnnouncementAlerts(ctx, 24*time.Hour); err == nil {
+		result.Announcements = announcements
+	}
+	if aspectRisks, err := s.CreateAspectRiskAlerts(ctx, 24*time.Hour); err == nil {
+		result.AspectRisks = aspectRisks
+	}
+
+	result.AlertsGenerated = len(result.VolumeSpikes) + len(result.PriceBreakouts) + len(result.Announcements) +
+		len(result.AspectRisks) + len(result.RuleAlerts)
+	return result, nil
+}
+
+// CreateAnnouncementAlerts raises a warning alert for each MOPS material announcement
+// published within the lookback for a symbol held in a portfolio or listed in a
+// watchlist. Each filing alerts once.
+func (s *AlertService) CreateAnnouncementAlerts(ctx context.Context, lookback time.Duration) ([]StockAlert, error) {
+	query := `
+		SELECT n.id, n.symbol, n.title, COALESCE(n.summary, ''), COALESCE(n.source_url, ''), n.published_at
+		FROM stock_news n
+		WHERE n.category = $1
+		  AND n.published_at >= $2
+		  AND n.symbol IN (
+			SELECT split_part(symbol, '.', 1) FROM positions_current WHERE total_quantity > 0
+			UNION
+			SELECT symbol FROM watchlist_items
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM stock_alerts a
+			WHERE a.alert_type = $3 AND a.data->>'article_id' = n.id::text
+		  )
+		ORDER BY n.published_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, MaterialAnnouncementCategory, time.Now().Add(-lookback), string(AlertTypeAnnouncement))
+	if err != nil {
+		return nil, err
+	}
+
+	type filing struct {
+		id, symbol, title, summary, url string
+		publishedAt                     time.Time
+	}
+	var filings []filing
+	for rows.Next() {
+		var f filing
+		if err := rows.Scan(&f.id, &f.symbol, &f.title, &f.summary, &f.url, &f.publishedAt); err == nil {
+			filings = append(filings, f)
+		}
+	}
+	rows.Close()
+
+	alerts := []StockAlert{}
+	for _, f := range filings {
+		data, _ := json.Marshal(map[string]interface{}{
+			"article_id":   f.id,
+			"source_url":   f.url,
+			"published_at": f.publishedAt,
+		})
+		alert := StockAlert{
+			Symbol:    f.symbol,
+			AlertType: AlertTypeAnnouncement,
+			Severity:  AlertSeverityWarning,
+			Title:     f.title,
+			Message:   f.summary,
+			Data:      data,
+		}
+		if err := s.CreateAlert(ctx, &alert); err != nil {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// ScanLimitHits checks the realtime quotes of every held or watched symbol and
+// raises a critical alert for each one locked at limit up or limit down. The
+// dedup key repeats per symbol, side and trading day, so a lock alerts once.
+func (s *AlertService) ScanLimitHits(ctx context.Context, fetchQuotes func(ctx context.Context, symbols []string) ([]*RealtimeQuote, error)) ([]StockAlert, error) {
+	symbols, err := s.scopeSymbols(ctx, AlertScanScopeTracked)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []StockAlert{}
+	if len(symbols) == 0 {
+		return alerts, nil
+	}
+	quotes, err := fetchQuotes(ctx, symbols)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch quotes: %w", err)
+	}
+
+	for _, q := range quotes {
+		// Locked: trading at the band with the opposite side of the book empty
+		side, limit, label := "", 0.0, ""
+		switch {
+		case q.LimitUp.IsPositive() && q.Price.GreaterThanOrEqual(q.LimitUp) && !q.AskPrice.IsPositive():
+			side, limit, label = "up", q.LimitUp.InexactFloat64(), "漲停"
+		case q.LimitDown.IsPositive() && q.Price.IsPositive() && q.Price.LessThanOrEqual(q.LimitDown) && !q.BidPrice.IsPositive():
+			side, limit, label = "down", q.LimitDown.InexactFloat64(), "跌停"
+		default:
+			continue
+		}
+
+		tradeTime := q.TradeTime
+		if tradeTime.IsZero() {
+			tradeTime = time.Now()
+		}
+		data, _ := json.Marshal(map[string]interface{}{
+			"direction":      side,
+			"limit_price":    limit,
+			"price":          q.Price.InexactFloat64(),
+			"prev_close":     q.PrevClose.InexactFloat64(),
+			"change_percent": q.ChangePercent.InexactFloat64(),
+			"volume":         q.Volume,
+			"bid_volume":     q.BidVolume,
+			"ask_volume":     q.AskVolume,
+			"trade_time":     tradeTime,
+		})
+		alert := StockAlert{
+			Symbol:          q.Symbol,
+			AlertType:       AlertTypeLimitHit,
+			Severity:        AlertSeverityCritical,
+			Title:           fmt.Sprintf("%s %s鎖死", q.Symbol, label),
+			Message:         fmt.Sprintf("%s 以%s價 %.2f 鎖住 (%+.2f%%)", q.Name, label, limit, q.ChangePercent.InexactFloat64()),
+			Data:            data,
+			ReferencePrice:  q.Price.InexactFloat64(),
+			ReferenceVolume: q.Volume,
+			ThresholdValue:  limit,
+			DedupKey:        alertDedupKey(q.Symbol, AlertTypeLimitHit, side, tradeTime),
+		}
+		if err := s.CreateAlert(ctx, &alert); err != nil {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// aspectRiskAspects are the aspects whose strongly negative mentions raise alerts;
+// revenue and orders already show up in the overall sentiment and price moves
+var aspectRiskAspects = []string{"lawsuits", "supply_chain", "management"}
+
+// CreateAspectRiskAlerts raises a warning alert when an article published within
+// the lookback is strongly negative (score <= -0.5) on a risk aspect for a held or
+// watched symbol. Each article/aspect pair alerts once.
+func (s *AlertService) CreateAspectRiskAlerts(ctx context.Context, lookback time.Duration) ([]StockAlert, error) {
+	query := `
+		SELECT n.id, t.symbol, a.aspect, a.score, COALESCE(a.snippet, ''), n.title,
+		       COALESCE(n.source_url, ''), n.published_at
+		FROM article_aspect_sentiment a
+		JOIN stock_news n ON n.id = a.article_id
+		JOIN article_symbols t ON t.article_id = n.id
+		WHERE a.aspect = ANY($1)
+		  AND a.score <= -0.5
+		  AND n.published_at >= $2
+		  AND t.symbol IN (
+			SELECT split_part(symbol, '.', 1) FROM positions_current WHERE total_quantity > 0
+			UNION
+			SELECT symbol FROM watchlist_items
+		  )
+		  AND NOT EXISTS (
+			SELECT 1 FROM stock_alerts x
+			WHERE x.alert_type = $3 AND x.symbol = t.symbol
+			  AND x.data->>'article_id' = n.id::text AND x.data->>'aspect' = a.aspect
+		  )
+		ORDER BY n.published_at
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(aspectRiskAspects), time.Now().Add(-lookback), string(AlertTypeAspectRisk))
+	if err != nil {
+		return nil, err
+	}
+
+	type risk struct {
+		id, symbol, aspect, snippet, title, url string
+		score                                   float64
+		publishedAt                             time.Time
+	}
+	var risks []risk
+	for rows.Next() {
+		var r risk
+		if err := rows.Scan(&r.id, &r.symbol, &r.aspect, &r.score, &r.snippet, &r.title, &r.url, &r.publishedAt); err == nil {
+			risks = append(risks, r)
+		}
+	}
+	rows.Close()
+
+	labels := make(map[string]string, len(sentimentAspects))
+	for _, a := range sentimentAspects {
+		labels[a.name] = a.label
+	}
+
+	alerts := []StockAlert{}
+	for _, r := range risks {
+		data, _ := json.Marshal(map[string]interface{}{
+			"article_id":   r.id,
+			"aspect":       r.aspect,
+			"score":        r.score,
+			"source_url":   r.url,
+			"published_at": r.publishedAt,
+		})
+		message := r.snippet
+		if message == "" {
+			message = r.title
+		}
+		alert := StockAlert{
+			Symbol:    r.symbol,
+			AlertType: AlertTypeAspectRisk,
+			Severity:  AlertSeverityWarning,
+			Title:     fmt.Sprintf("%s 負面消息: %s", labels[r.aspect], r.title),
+			Message:   message,
+			Data:      data,
+		}
+		if err := s.CreateAlert(ctx, &alert); err != nil {
+			continue
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
 // ScanResult represents the result of a full scan
 type ScanResult struct {
 	ScannedAt       time.Time       `json:"scanned_at"`
+	Scope           string          `json:"scope,omitempty"` // See ParseAlertScanScope
 	TotalSymbols    int             `json:"total_symbols"`
 	AlertsGenerated int             `json:"alerts_generated"`
+	AlertsSuppressed int            `json:"alerts_suppressed"` // Detector hits already alerted within the dedup window
 	VolumeSpikes    []VolumeAnalysis `json:"volume_spikes"`
 	PriceBreakouts  []PriceAnalysis  `json:"price_breakouts"`
+	MABreakouts     []MABreakoutAnalysis `json:"ma_breakouts"`
+	RSIExtremes     []RSIAnalysis    `json:"rsi_extremes"`
+	Announcements   []StockAlert     `json:"announcements"`
+	AspectRisks     []StockAlert     `json:"aspect_risks"`
+	RuleAlerts      []StockAlert     `json:"rule_alerts"`
 }
 
-// CreateAlert creates a new alert
+// CreateAlert creates a new alert. An alert with a dedup key is skipped with
+// ErrAlertDuplicate if the key was raised within the dedup window, and one
+// raised while its symbol and type are snoozed is created snoozed.
 func (s *AlertService) CreateAlert(ctx context.Context, alert *StockAlert) error {
 	query := `
-		INSERT INTO stock_alerts (symbol, alert_type, severity, title, message, data, reference_price, reference_volume, threshold_value)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		RETURNING id, triggered_at
+		INSERT INTO stock_alerts (symbol, alert_type, severity, title, message, data, reference_price, reference_volume, threshold_value, dedup_key, snoozed_until)
+		SELECT $1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10::text, ''), (
+			SELECT MAX(snoozed_until) FROM stock_alerts
+			WHERE symbol = $1 AND alert_type = $2 AND snoozed_until > NOW()
+		)
+		WHERE $10::text = '' OR $11::float8 <= 0 OR NOT EXISTS (
+			SELECT 1 FROM stock_alerts
+			WHERE dedup_key = $10::text AND triggered_at > NOW() - make_interval(secs => $11::float8)
+		)
+		RETURNING id, triggered_at, snoozed_until
 	`
 
-	return s.db.QueryRowContext(ctx, query,
+	err := s.db.QueryRowContext(ctx, query,
 		alert.Symbol,
 		string(alert.AlertType),
 		string(alert.Severity),
@@ -315,26 +1032,35 @@ func (s *AlertService) CreateAlert(ctx context.Context, alert *StockAlert) error
 		alert.ReferencePrice,
 		alert.ReferenceVolume,
 		alert.ThresholdValue,
-	).Scan(&alert.ID, &alert.TriggeredAt)
+		alert.DedupKey,
+		s.dedupWindow.Seconds(),
+	).Scan(&alert.ID, &alert.TriggeredAt, &alert.SnoozedUntil)
+	if err == sql.ErrNoRows {
+		return ErrAlertDuplicate
+	}
+	return err
 }
 
-// GetAlerts retrieves alerts with optional filters
-func (s *AlertService) GetAlerts(ctx context.Context, symbol string, unacknowledgedOnly bool, limit int) ([]StockAlert, error) {
+// GetAlerts retrieves alerts with optional filters. Snoozed alerts are left
+// out unless includeSnoozed.
+func (s *AlertService) GetAlerts(ctx context.Context, symbol string, unacknowledgedOnly, includeSnoozed bool, limit int) ([]StockAlert, error) {
 	if limit <= 0 {
 		limit = 50
 	}
 
 	query := `
 		SELECT id, symbol, alert_type, severity, title, message, COALESCE(data, '{}'), triggered_at, acknowledged_at,
-		       COALESCE(reference_price, 0), COALESCE(reference_volume, 0), COALESCE(threshold_value, 0)
+		       COALESCE(reference_price, 0), COALESCE(reference_volume, 0), COALESCE(threshold_value, 0),
+		       COALESCE(ai_explanation, ''), snoozed_until
 		FROM stock_alerts
 		WHERE ($1 = '' OR symbol = $1)
 		  AND ($2 = FALSE OR acknowledged_at IS NULL)
+		  AND ($4 OR snoozed_until IS NULL OR snoozed_until <= NOW())
 		ORDER BY triggered_at DESC
 		LIMIT $3
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, symbol, unacknowledgedOnly, limit)
+	rows, err := s.db.QueryContext(ctx, query, symbol, unacknowledgedOnly, limit, includeSnoozed)
 	if err != nil {
 		return nil, err
 	}
@@ -346,6 +1072,7 @@ func (s *AlertService) GetAlerts(ctx context.Context, symbol string, unacknowled
 		if err := rows.Scan(
 			&a.ID, &a.Symbol, &a.AlertType, &a.Severity, &a.Title, &a.Message, &a.Data,
 			&a.TriggeredAt, &a.AcknowledgedAt, &a.ReferencePrice, &a.ReferenceVolume, &a.ThresholdValue,
+			&a.AIExplanation, &a.SnoozedUntil,
 		); err != nil {
 			continue
 		}
@@ -362,11 +1089,99 @@ func (s *AlertService) AcknowledgeAlert(ctx context.Context, alertID string) err
 	return err
 }
 
+// AlertAckFilter selects the alerts AcknowledgeAlerts acknowledges; empty
+// fields match everything
+type AlertAckFilter struct {
+	Symbol    string     `json:"symbol"`
+	AlertType string     `json:"alert_type"`
+	Severity  string     `json:"severity"`
+	Before    *time.Time `json:"before"` // Only alerts triggered before this time
+}
+
+// AcknowledgeAlerts acknowledges every unacknowledged alert matching the
+// filter and returns how many were acknowledged
+func (s *AlertService) AcknowledgeAlerts(ctx context.Context, filter AlertAckFilter) (int64, error) {
+	if filter.Severity != "" {
+		switch AlertSeverity(filter.Severity) {
+		case AlertSeverityInfo, AlertSeverityWarning, AlertSeverityCritical:
+		default:
+			return 0, fmt.Errorf("invalid severity %q (expected info, warning or critical)", filter.Severity)
+		}
+	}
+	var before sql.NullTime
+	if filter.Before != nil {
+		before = sql.NullTime{Time: *filter.Before, Valid: true}
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE stock_alerts SET acknowledged_at = NOW()
+		WHERE acknowledged_at IS NULL
+		  AND ($1 = '' OR symbol = $1)
+		  AND ($2 = '' OR alert_type = $2)
+		  AND ($3 = '' OR severity = $3)
+		  AND ($4::timestamptz IS NULL OR triggered_at < $4)
+	`, filter.Symbol, filter.AlertType, filter.Severity, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to acknowledge alerts: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
+
+// SnoozeAlert hides an alert until the given time. Alerts for the same symbol
+// and type raised before then are created snoozed until the same time.
+func (s *AlertService) SnoozeAlert(ctx context.Context, alertID string, until time.Time) (*StockAlert, error) {
+	if !until.After(time.Now()) {
+		return nil, fmt.Errorf("snooze time must be in the future")
+	}
+	if until.After(time.Now().Add(30 * 24 * time.Hour)) {
+		return nil, fmt.Errorf("snooze time must be within 30 days (acknowledge the alert instead)")
+	}
+	return s.setSnooze(ctx, alertID, sql.NullTime{Time: until, Valid: true})
+}
+
+// UnsnoozeAlert ends an alert's snooze, along with the snooze new alerts for
+// its symbol and type inherited from it
+func (s *AlertService) UnsnoozeAlert(ctx context.Context, alertID string) (*StockAlert, error) {
+	return s.setSnooze(ctx, alertID, sql.NullTime{})
+}
+
+func (s *AlertService) setSnooze(ctx context.Context, alertID string, until sql.NullTime) (*StockAlert, error) {
+	if _, err := uuid.Parse(alertID); err != nil {
+		return nil, fmt.Errorf("alert not found")
+	}
+
+	var a StockAlert
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE stock_alerts SET snoozed_until = $2
+		WHERE id = $1
+		RETURNING id, symbol, alert_type, severity, title, message, triggered_at, acknowledged_at, snoozed_until
+	`, alertID, until).Scan(&a.ID, &a.Symbol, &a.AlertType, &a.Severity, &a.Title, &a.Message,
+		&a.TriggeredAt, &a.AcknowledgedAt, &a.SnoozedUntil)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to snooze alert: %w", err)
+	}
+
+	if !until.Valid {
+		s.db.ExecContext(ctx, `
+			UPDATE stock_alerts SET snoozed_until = NULL
+			WHERE symbol = $1 AND alert_type = $2 AND snoozed_until > NOW() AND triggered_at > $3
+		`, a.Symbol, string(a.AlertType), a.TriggeredAt)
+	}
+	return &a, nil
+}
+
 // GetAlertStats returns alert statistics
-func (s *AlertService) GetAlertStats(ctx context.Context, days int) (*AlertStats, error) {
+func (s *AlertService) GetAlertStats(ctx context.Context, days, top int) (*AlertStats, error) {
 	if days <= 0 {
 		days = 7
 	}
+	if top <= 0 || top > 50 {
+		top = 10
+	}
 
 	query := `
 		SELECT 
@@ -394,15 +1209,128 @@ func (s *AlertService) GetAlertStats(ctx context.Context, days int) (*AlertStats
 		return nil, err
 	}
 
+	if err := s.loadAlertStatsBreakdown(ctx, &stats, interval, top); err != nil {
+		return nil, err
+	}
 	return &stats, nil
 }
 
+// loadAlertStatsBreakdown adds the per-type counts and the symbols and rules
+// raising the most alerts over the period
+func (s *AlertService) loadAlertStatsBreakdown(ctx context.Context, stats *AlertStats, interval string, top int) error {
+	stats.ByType = []AlertTypeStats{}
+	stats.TopSymbols = []AlertSymbolCount{}
+	stats.TopRules = []AlertRuleCount{}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT alert_type,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE severity = 'critical'),
+		       COUNT(*) FILTER (WHERE severity = 'warning'),
+		       COUNT(*) FILTER (WHERE severity = 'info'),
+		       COUNT(*) FILTER (WHERE acknowledged_at IS NULL),
+		       COUNT(DISTINCT symbol)
+		FROM stock_alerts
+		WHERE triggered_at >= NOW() - $1::interval
+		GROUP BY alert_type
+		ORDER BY COUNT(*) DESC, alert_type
+	`, interval)
+	if err != nil {
+		return fmt.Errorf("failed to query alert types: %w", err)
+	}
+	for rows.Next() {
+		var t AlertTypeStats
+		if err := rows.Scan(&t.AlertType, &t.Total, &t.Critical, &t.Warning, &t.Info, &t.Unacknowledged, &t.Symbols); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan alert types: %w", err)
+		}
+		stats.ByType = append(stats.ByType, t)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `
+		WITH counts AS (
+			SELECT symbol, alert_type, COUNT(*) AS n
+			FROM stock_alerts
+			WHERE triggered_at >= NOW() - $1::interval
+			GROUP BY symbol, alert_type
+		), top AS (
+			SELECT symbol, SUM(n) AS total FROM counts GROUP BY symbol ORDER BY total DESC, symbol LIMIT $2
+		)
+		SELECT t.symbol, t.total::int, c.alert_type, c.n
+		FROM top t
+		JOIN counts c ON c.symbol = t.symbol
+		ORDER BY t.total DESC, t.symbol
+	`, interval, top)
+	if err != nil {
+		return fmt.Errorf("failed to query alert symbols: %w", err)
+	}
+	for rows.Next() {
+		var symbol, alertType string
+		var total, n int
+		if err := rows.Scan(&symbol, &total, &alertType, &n); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan alert symbols: %w", err)
+		}
+		if last := len(stats.TopSymbols) - 1; last < 0 || stats.TopSymbols[last].Symbol != symbol {
+			stats.TopSymbols = append(stats.TopSymbols, AlertSymbolCount{Symbol: symbol, Count: total, ByType: map[string]int{}})
+		}
+		stats.TopSymbols[len(stats.TopSymbols)-1].ByType[alertType] = n
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT data->>'rule_id', MAX(data->>'rule_name'), COUNT(*), COUNT(DISTINCT symbol),
+		       COUNT(*) FILTER (WHERE acknowledged_at IS NULL)
+		FROM stock_alerts
+		WHERE alert_type = $3 AND triggered_at >= NOW() - $1::interval
+		GROUP BY data->>'rule_id'
+		ORDER BY COUNT(*) DESC
+		LIMIT $2
+	`, interval, top, string(AlertTypeUserRule))
+	if err != nil {
+		return fmt.Errorf("failed to query alert rules: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r AlertRuleCount
+		if err := rows.Scan(&r.RuleID, &r.Name, &r.Count, &r.Symbols, &r.Unacknowledged); err != nil {
+			return fmt.Errorf("failed to scan alert rules: %w", err)
+		}
+		stats.TopRules = append(stats.TopRules, r)
+	}
+	return rows.Err()
+}
+
 // AlertStats represents alert statistics
 type AlertStats struct {
-	Days           int `json:"days"`
-	Total          int `json:"total"`
-	Critical       int `json:"critical"`
-	Warning        int `json:"warning"`
-	Info           int `json:"info"`
-	Unacknowledged int `json:"unacknowledged"`
+	Days           int                `json:"days"`
+	Total          int                `json:"total"`
+	Critical       int                `json:"critical"`
+	Warning        int                `json:"warning"`
+	Info           int                `json:"info"`
+	Unacknowledged int                `json:"unacknowledged"`
+	ByType         []AlertTypeStats   `json:"by_type"`     // Most alerts first
+	TopSymbols     []AlertSymbolCount `json:"top_symbols"` // With their counts by type
+	TopRules       []AlertRuleCount   `json:"top_rules"`   // User rules that fired most
+}
+
+// AlertTypeStats is the alerts of one type over the stats period
+type AlertTypeStats struct {
+	AlertType      string `json:"alert_type"`
+	Total          int    `json:"total"`
+	Critical       int    `json:"critical"`
+	Warning        int    `json:"warning"`
+	Info           int    `json:"info"`
+	Unacknowledged int    `json:"unacknowledged"`
+	Symbols        int    `json:"symbols"` // Distinct symbols alerted
+}
+
+// AlertRuleCount is how many alerts a user rule raised
+type AlertRuleCount struct {
+	RuleID         string `json:"rule_id"`
+	Name           string `json:"name"`
+	Count          int    `json:"count"`
+	Symbols        int    `json:"symbols"`
+	Unacknowledged int    `json:"unacknowledged"`
 }
@@ -0,0 +1,346 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// telegramMessageMaxRunes is Telegram's message length limit
+const telegramMessageMaxRunes = 4096
+
+// telegramChatIDPattern matches numeric chat IDs and @channel usernames
+var telegramChatIDPattern = regexp.MustCompile(`^(-?\d{1,20}|@[A-Za-z][A-Za-z0-9_]{4,31})$`)
+
+// AlertTelegramChannel sends triggered alerts and AI daily digests through the
+// PSM Telegram bot to the chat each user linked
+type AlertTelegramChannel struct {
+	db     *database.DB
+	client *http.Client
+	token  string
+	apiURL string
+}
+
+// AlertTelegramSettings is a user's Telegram settings
+type AlertTelegramSettings struct {
+	Linked     bool       `json:"linked"`
+	ChatID     string     `json:"chat_id,omitempty"`
+	Enabled    bool       `json:"enabled"`
+	Severities []string   `json:"severities"`
+	AlertTypes []string   `json:"alert_types"` // Empty = every type
+	Digest     bool       `json:"digest"`      // Also send the AI daily digest
+	LastError  string     `json:"last_error,omitempty"`
+	Configured bool       `json:"configured"` // The bot token is set on the server
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+}
+
+// AlertTelegramSettingsRequest links a chat or updates settings; omitted
+// fields are unchanged
+type AlertTelegramSettingsRequest struct {
+	ChatID     *string  `json:"chat_id"`
+	Enabled    *bool    `json:"enabled"`
+	Severities []string `json:"severities"`
+	AlertTypes []string `json:"alert_types"`
+	Digest     *bool    `json:"digest"`
+}
+
+// NewAlertTelegramChannel reads TELEGRAM_BOT_TOKEN and TELEGRAM_API_URL
+// (default https://api.telegram.org)
+func NewAlertTelegramChannel(db *database.DB) *AlertTelegramChannel {
+	apiURL := os.Getenv("TELEGRAM_API_URL")
+	if apiURL == "" {
+		apiURL = "https://api.telegram.org"
+	}
+	return &AlertTelegramChannel{
+		db:     db,
+		client: &http.Client{Timeout: 10 * time.Second},
+		token:  os.Getenv("TELEGRAM_BOT_TOKEN"),
+		apiURL: strings.TrimRight(apiURL, "/"),
+	}
+}
+
+func (c *AlertTelegramChannel) Name() string { return "telegram" }
+
+func (c *AlertTelegramChannel) Configured() bool { return c.token != "" }
+
+// Subscribers returns the users with Telegram notifications enabled
+func (c *AlertTelegramChannel) Subscribers(ctx context.Context) ([]AlertSubscriber, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT user_id, chat_id, severities, alert_types, created_at
+		FROM alert_telegram_settings
+		WHERE enabled
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Telegram settings: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []AlertSubscriber
+	for rows.Next() {
+		var sub AlertSubscriber
+		if err := rows.Scan(&sub.UserID, &sub.Target, pq.Array(&sub.Severities), pq.Array(&sub.AlertTypes), &sub.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan Telegram settings: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// GetSettings returns a user's Telegram settings
+func (c *AlertTelegramChannel) GetSettings(ctx context.Context, userID uuid.UUID) (*AlertTelegramSettings, error) {
+	settings := AlertTelegramSettings{
+		Severities: []string{string(AlertSeverityWarning), string(AlertSeverityCritical)},
+		AlertTypes: []string{},
+		Digest:     true,
+		Configured: c.Configured(),
+	}
+	var lastError sql.NullString
+	var alertTypes []string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT chat_id, enabled, severities, alert_types, digest, last_error, updated_at
+		FROM alert_telegram_settings
+		WHERE user_id = $1
+	`, userID).Scan(&settings.ChatID, &settings.Enabled, pq.Array(&settings.Severities), pq.Array(&alertTypes),
+		&settings.Digest, &lastError, &settings.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return &settings, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query Telegram settings: %w", err)
+	}
+
+	settings.Linked = true
+	settings.LastError = lastError.String
+	if alertTypes != nil {
+		settings.AlertTypes = alertTypes
+	}
+	return &settings, nil
+}
+
+// UpdateSettings links a chat or changes a user's Telegram settings. A newly
+// linked chat gets a confirmation message, so an unreachable chat is rejected.
+func (c *AlertTelegramChannel) UpdateSettings(ctx context.Context, userID uuid.UUID, req AlertTelegramSettingsRequest) (*AlertTelegramSettings, error) {
+	if !c.Configured() {
+		return nil, fmt.Errorf("Telegram notifications are not configured (set TELEGRAM_BOT_TOKEN)")
+	}
+	settings, err := c.GetSettings(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	chatID := ""
+	if req.ChatID != nil {
+		chatID = strings.TrimSpace(*req.ChatID)
+		if !telegramChatIDPattern.MatchString(chatID) {
+			return nil, fmt.Errorf("invalid chat_id %q (expected a numeric chat ID or @channel)", chatID)
+		}
+		if chatID == settings.ChatID {
+			chatID = ""
+		}
+	} else if !settings.Linked {
+		return nil, fmt.Errorf("chat_id is required")
+	}
+
+	enabled := settings.Enabled || chatID != ""
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+	digest := settings.Digest
+	if req.Digest != nil {
+		digest = *req.Digest
+	}
+	severities, alertTypes := settings.Severities, settings.AlertTypes
+	if req.Severities != nil {
+		severities = req.Severities
+	}
+	if req.AlertTypes != nil {
+		alertTypes = req.AlertTypes
+	}
+	if severities, alertTypes, err = normalizeAlertFilters(severities, alertTypes); err != nil {
+		return nil, err
+	}
+
+	if chatID != "" {
+		if err := c.sendMessage(ctx, chatID, "<b>PSM</b> 已連結此聊天室，之後的警示通知會傳送到這裡。"); err != nil {
+			return nil, fmt.Errorf("could not message chat %s: %w", chatID, err)
+		}
+	}
+
+	if _, err := c.db.ExecContext(ctx, `
+		INSERT INTO alert_telegram_settings (user_id, chat_id, enabled, severities, alert_types, digest)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id) DO UPDATE SET
+			chat_id = COALESCE(NULLIF(EXCLUDED.chat_id, ''), alert_telegram_settings.chat_id),
+			enabled = EXCLUDED.enabled,
+			severities = EXCLUDED.severities,
+			alert_types = EXCLUDED.alert_types,
+			digest = EXCLUDED.digest,
+			last_error = CASE WHEN EXCLUDED.chat_id <> '' THEN NULL ELSE alert_telegram_settings.last_error END
+	`, userID, chatID, enabled, pq.Array(severities), pq.Array(alertTypes), digest); err != nil {
+		return nil, fmt.Errorf("failed to save Telegram settings: %w", err)
+	}
+	return c.GetSettings(ctx, userID)
+}
+
+// DeleteSettings unlinks a user's chat
+func (c *AlertTelegramChannel) DeleteSettings(ctx context.Context, userID uuid.UUID) error {
+	result, err := c.db.ExecContext(ctx, `DELETE FROM alert_telegram_settings WHERE user_id = $1`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete Telegram settings: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("Telegram chat not found")
+	}
+	return nil
+}
+
+// Send messages one alert to the subscriber's chat
+func (c *AlertTelegramChannel) Send(ctx context.Context, sub *AlertSubscriber, alert *StockAlert) error {
+	err := c.sendMessage(ctx, sub.Target, telegramAlertMessage(alert))
+	c.recordError(ctx, sub.UserID, err)
+	return err
+}
+
+// SendBatch messages several alerts as one list
+func (c *AlertTelegramChannel) SendBatch(ctx context.Context, sub *AlertSubscriber, alerts []StockAlert) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>PSM 警示摘要 (%d 則)</b>", len(alerts))
+	for i := range alerts {
+		if i == alertBatchMaxListed {
+			fmt.Fprintf(&b, "\n\n…另有 %d 則", len(alerts)-i)
+			break
+		}
+		a := &alerts[i]
+		fmt.Fprintf(&b, "\n\n<b>【%s】<a href=\"%s\">%s</a></b>\n%s", alertSeverityLabels[a.Severity],
+			html.EscapeString(alertSymbolURL(a.Symbol)), html.EscapeString(a.Title), html.EscapeString(a.Message))
+	}
+	err := c.sendMessage(ctx, sub.Target, b.String())
+	c.recordError(ctx, sub.UserID, err)
+	return err
+}
+
+// SendDigest sends the AI daily digest if the user opted in
+func (c *AlertTelegramChannel) SendDigest(ctx context.Context, digest *AIDigest) (bool, error) {
+	var chatID string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT chat_id FROM alert_telegram_settings WHERE user_id = $1 AND enabled AND digest
+	`, digest.UserID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query Telegram settings: %w", err)
+	}
+
+	content := []rune(plainDigestText(digest.Content))
+	if len(content) > 3500 { // Leaves room for the escaping within Telegram's limit
+		content = append(content[:3500], '…')
+	}
+	message := fmt.Sprintf("<b>PSM 每日摘要 %s</b>\n\n%s", digest.Date, html.EscapeString(string(content)))
+	err = c.sendMessage(ctx, chatID, message)
+	c.recordError(ctx, digest.UserID, err)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// recordError switches a user's notifications off when the bot can no longer
+// reach the chat, e.g. it was blocked or removed from the group
+func (c *AlertTelegramChannel) recordError(ctx context.Context, userID uuid.UUID, err error) {
+	var apiErr *telegramAPIError
+	if !errors.As(err, &apiErr) {
+		return
+	}
+	if apiErr.code != http.StatusForbidden && !strings.Contains(apiErr.description, "chat not found") {
+		return
+	}
+	c.db.ExecContext(ctx, `
+		UPDATE alert_telegram_settings SET enabled = false, last_error = $2 WHERE user_id = $1
+	`, userID, apiErr.Error())
+}
+
+// telegramAPIError is an error reply from the Bot API
+type telegramAPIError struct {
+	code        int
+	description string
+}
+
+func (e *telegramAPIError) Error() string {
+	return fmt.Sprintf("Telegram API error %d: %s", e.code, e.description)
+}
+
+// Is makes a bad request or a blocked or missing chat ErrAlertUndeliverable
+func (e *telegramAPIError) Is(target error) bool {
+	return target == ErrAlertUndeliverable && (e.code == http.StatusBadRequest || e.code == http.StatusForbidden)
+}
+
+// sendMessage sends an HTML-formatted message; a 429 reply is ErrAlertRateLimited
+func (c *AlertTelegramChannel) sendMessage(ctx context.Context, chatID, text string) error {
+	if runes := []rune(text); len(runes) > telegramMessageMaxRunes {
+		text = string(runes[:telegramMessageMaxRunes-1]) + "…"
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"parse_mode":               "HTML",
+		"disable_web_page_preview": true,
+	})
+	req, err := http.NewRequestWithContext(ctx, "POST", c.apiURL+"/bot"+c.token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		// The URL contains the bot token; keep it out of the error
+		return fmt.Errorf("Telegram request failed")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		ErrorCode   int    `json:"error_code"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("Telegram returned status %d", resp.StatusCode)
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return ErrAlertRateLimited
+	}
+	if !result.OK {
+		return &telegramAPIError{code: result.ErrorCode, description: result.Description}
+	}
+	return nil
+}
+
+// telegramAlertMessage formats an alert as Telegram HTML
+func telegramAlertMessage(alert *StockAlert) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<b>【%s】%s</b>\n%s", alertSeverityLabels[alert.Severity], html.EscapeString(alert.Title),
+		html.EscapeString(alert.Message))
+	if alert.ReferencePrice > 0 {
+		fmt.Fprintf(&b, "\n參考價 %.2f", alert.ReferencePrice)
+	}
+	fmt.Fprintf(&b, "\n<i>%s · %s</i>", alert.AlertType,
+		alert.TriggeredAt.In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "\n<a href=\"%s\">查看 %s</a>", html.EscapeString(alertSymbolURL(alert.Symbol)), html.EscapeString(alert.Symbol))
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// alertThresholdsTTL is how long loaded thresholds are reused; a scan calls
+// the detectors once per symbol
+const alertThresholdsTTL = time.Minute
+
+// AlertThresholds are the detector cutoffs stored in alert_thresholds
+type AlertThresholds struct {
+	VolumeSpikeRatio     float64 `json:"volume_spike_ratio"`
+	VolumeWarningRatio   float64 `json:"volume_warning_ratio"`
+	VolumeCriticalRatio  float64 `json:"volume_critical_ratio"`
+	Price52wProximityPct float64 `json:"price_52w_proximity_pct"`
+	RSIOverbought        float64 `json:"rsi_overbought"`
+	RSIOversold          float64 `json:"rsi_oversold"`
+	RSIWarningOverbought float64 `json:"rsi_warning_overbought"`
+	RSIWarningOversold   float64 `json:"rsi_warning_oversold"`
+}
+
+// defaultAlertThresholds are used for keys missing from the table
+var defaultAlertThresholds = AlertThresholds{
+	VolumeSpikeRatio:     2,
+	VolumeWarningRatio:   3,
+	VolumeCriticalRatio:  5,
+	Price52wProximityPct: 3,
+	RSIOverbought:        70,
+	RSIOversold:          30,
+	RSIWarningOverbought: 80,
+	RSIWarningOversold:   20,
+}
+
+// fields maps each key to its field
+func (t *AlertThresholds) fields() map[string]*float64 {
+	return map[string]*float64{
+		"volume_spike_ratio":      &t.VolumeSpikeRatio,
+		"volume_warning_ratio":    &t.VolumeWarningRatio,
+		"volume_critical_ratio":   &t.VolumeCriticalRatio,
+		"price_52w_proximity_pct": &t.Price52wProximityPct,
+		"rsi_overbought":          &t.RSIOverbought,
+		"rsi_oversold":            &t.RSIOversold,
+		"rsi_warning_overbought":  &t.RSIWarningOverbought,
+		"rsi_warning_oversold":    &t.RSIWarningOversold,
+	}
+}
+
+func (t *AlertThresholds) validate() error {
+	switch {
+	case t.VolumeSpikeRatio <= 1:
+		return fmt.Errorf("volume_spike_ratio must be above 1")
+	case t.VolumeWarningRatio < t.VolumeSpikeRatio || t.VolumeCriticalRatio < t.VolumeWarningRatio:
+		return fmt.Errorf("volume ratios must satisfy spike <= warning <= critical")
+	case t.Price52wProximityPct <= 0 || t.Price52wProximityPct >= 50:
+		return fmt.Errorf("price_52w_proximity_pct must be between 0 and 50")
+	case t.RSIOversold <= 0 || t.RSIOversold >= t.RSIOverbought || t.RSIOverbought >= 100:
+		return fmt.Errorf("RSI zones must satisfy 0 < oversold < overbought < 100")
+	case t.RSIWarningOverbought < t.RSIOverbought || t.RSIWarningOverbought >= 100:
+		return fmt.Errorf("rsi_warning_overbought must be between rsi_overbought and 100")
+	case t.RSIWarningOversold > t.RSIOversold || t.RSIWarningOversold <= 0:
+		return fmt.Errorf("rsi_warning_oversold must be between 0 and rsi_oversold")
+	}
+	return nil
+}
+
+// Thresholds returns the current detector cutoffs, cached for a minute. If the
+// table cannot be read the defaults are used.
+func (s *AlertService) Thresholds(ctx context.Context) AlertThresholds {
+	s.thresholdsMu.Lock()
+	defer s.thresholdsMu.Unlock()
+	if s.thresholds != nil && time.Since(s.thresholdsAt) < alertThresholdsTTL {
+		return *s.thresholds
+	}
+
+	t, err := s.loadThresholds(ctx)
+	if err != nil {
+		log.Printf("Alert thresholds: %v; using defaults", err)
+		return defaultAlertThresholds
+	}
+	s.thresholds, s.thresholdsAt = &t, time.Now()
+	return t
+}
+
+func (s *AlertService) loadThresholds(ctx context.Context) (AlertThresholds, error) {
+	t := defaultAlertThresholds
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM alert_thresholds`)
+	if err != nil {
+		return t, fmt.Errorf("failed to query alert thresholds: %w", err)
+	}
+	defer rows.Close()
+
+	fields := t.fields()
+	for rows.Next() {
+		var key string
+		var value float64
+		if err := rows.Scan(&key, &value); err != nil {
+			return t, fmt.Errorf("failed to scan alert threshold: %w", err)
+		}
+		if f, ok := fields[key]; ok {
+			*f = value
+		}
+	}
+	return t, rows.Err()
+}
+
+// UpdateThresholds changes the given cutoffs (by key, e.g.
+// {"volume_warning_ratio": 4}) and returns the full set
+func (s *AlertService) UpdateThresholds(ctx context.Context, changes map[string]float64) (*AlertThresholds, error) {
+	if len(changes) == 0 {
+		return nil, fmt.Errorf("no thresholds given")
+	}
+	t, err := s.loadThresholds(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fields := t.fields()
+	keys := make([]string, 0, len(changes))
+	for key, value := range changes {
+		f, ok := fields[key]
+		if !ok {
+			return nil, fmt.Errorf("unknown threshold %q", key)
+		}
+		*f = value
+		keys = append(keys, key)
+	}
+	if err := t.validate(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO alert_thresholds (key, value) VALUES ($1, $2)
+			ON CONFLICT (key) DO UPDATE SET value = EXCLUDED.value
+		`, key, changes[key]); err != nil {
+			return nil, fmt.Errorf("failed to save alert threshold: %w", err)
+		}
+	}
+
+	s.thresholdsMu.Lock()
+	s.thresholds, s.thresholdsAt = &t, time.Now()
+	s.thresholdsMu.Unlock()
+	return &t, nil
+}
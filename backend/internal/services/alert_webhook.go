@@ -0,0 +1,599 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// First retry delay; each further retry waits twice as long
+	alertWebhookBaseBackoff = 30 * time.Second
+	alertWebhookMaxBackoff  = time.Hour
+	// How much of the endpoint's response is kept in the delivery log
+	alertWebhookResponseLimit = 1024
+)
+
+// AlertWebhookChannel POSTs triggered alerts as signed JSON to the webhooks
+// users registered. Deliveries are queued per webhook and retried with
+// exponential backoff until ALERT_WEBHOOK_MAX_ATTEMPTS.
+type AlertWebhookChannel struct {
+	db          *database.DB
+	client      *http.Client
+	maxAttempts int
+	lastPrune   time.Time
+}
+
+// AlertWebhook is a registered alert webhook. The secret is only returned when
+// the webhook is created or its secret is rotated.
+type AlertWebhook struct {
+	ID         string    `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	URL        string    `json:"url"`
+	Secret     string    `json:"secret,omitempty"`
+	Severities []string  `json:"severities"`
+	AlertTypes []string  `json:"alert_types"` // Empty = every type
+	IsActive   bool      `json:"is_active"`
+	Pending    int       `json:"pending"` // Deliveries waiting for a retry
+	Failed24h  int       `json:"failed_24h"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// AlertWebhookRequest registers or updates a webhook; omitted fields are
+// unchanged. An empty secret generates a new one.
+type AlertWebhookRequest struct {
+	URL        string   `json:"url"`
+	Secret     *string  `json:"secret"`
+	Severities []string `json:"severities"`
+	AlertTypes []string `json:"alert_types"`
+	IsActive   *bool    `json:"is_active"`
+}
+
+// AlertWebhookPayload is the JSON body POSTed for each alert
+type AlertWebhookPayload struct {
+	Event     string      `json:"event"` // alert.triggered, ping
+	WebhookID string      `json:"webhook_id"`
+	Alert     *StockAlert `json:"alert"`
+	SymbolURL string      `json:"symbol_url"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+// AlertWebhookDelivery is one entry of a webhook's delivery log
+type AlertWebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	AlertID        *string         `json:"alert_id,omitempty"`
+	Event          string          `json:"event"`
+	Status         string          `json:"status"` // pending, delivered, failed
+	Attempts       int             `json:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty"`
+	ResponseStatus *int            `json:"response_status,omitempty"`
+	ResponseBody   string          `json:"response_body,omitempty"`
+	Error          string          `json:"error,omitempty"`
+	DurationMs     *int            `json:"duration_ms,omitempty"`
+	Payload        json.RawMessage `json:"payload"`
+	CreatedAt      time.Time       `json:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// NewAlertWebhookChannel reads ALERT_WEBHOOK_MAX_ATTEMPTS (default 6)
+func NewAlertWebhookChannel(db *database.DB) *AlertWebhookChannel {
+	maxAttempts := 6
+	if v, err := strconv.Atoi(os.Getenv("ALERT_WEBHOOK_MAX_ATTEMPTS")); err == nil && v > 0 {
+		maxAttempts = v
+	}
+	return &AlertWebhookChannel{
+		db:          db,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		maxAttempts: maxAttempts,
+	}
+}
+
+func (c *AlertWebhookChannel) Name() string { return "webhook" }
+
+func (c *AlertWebhookChannel) Configured() bool { return true }
+
+// Subscribers returns the users with an active webhook. Filters are applied
+// per webhook when the alert is queued.
+func (c *AlertWebhookChannel) Subscribers(ctx context.Context) ([]AlertSubscriber, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT user_id, MIN(created_at) FROM alert_webhooks WHERE is_active GROUP BY user_id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []AlertSubscriber
+	for rows.Next() {
+		sub := AlertSubscriber{
+			Severities: []string{string(AlertSeverityInfo), string(AlertSeverityWarning), string(AlertSeverityCritical)},
+		}
+		if err := rows.Scan(&sub.UserID, &sub.Since); err != nil {
+			return nil, fmt.Errorf("failed to scan alert webhook: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// Send queues the alert for each of the user's active webhooks whose filters
+// it passes; DeliverDue sends the queue
+func (c *AlertWebhookChannel) Send(ctx context.Context, sub *AlertSubscriber, alert *StockAlert) error {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id FROM alert_webhooks
+		WHERE user_id = $1 AND is_active AND created_at <= $2
+		  AND $3 = ANY(severities)
+		  AND (cardinality(alert_types) = 0 OR $4 = ANY(alert_types))
+	`, sub.UserID, alert.TriggeredAt, string(alert.Severity), string(alert.AlertType))
+	if err != nil {
+		return fmt.Errorf("failed to query alert webhooks: %w", err)
+	}
+	var hookIDs []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			hookIDs = append(hookIDs, id)
+		}
+	}
+	rows.Close()
+
+	for _, hookID := range hookIDs {
+		if _, err := c.enqueue(ctx, hookID, "alert.triggered", alert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enqueue stores a pending delivery with its payload, so retries send the same
+// body; an alert is queued once per webhook
+func (c *AlertWebhookChannel) enqueue(ctx context.Context, hookID, event string, alert *StockAlert) (string, error) {
+	payload, err := json.Marshal(AlertWebhookPayload{
+		Event:     event,
+		WebhookID: hookID,
+		Alert:     alert,
+		SymbolURL: alertSymbolURL(alert.Symbol),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	var alertID interface{}
+	if event != "ping" {
+		alertID = alert.ID
+	}
+	var id string
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO alert_webhook_deliveries (webhook_id, alert_id, event, payload)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (webhook_id, alert_id) DO NOTHING
+		RETURNING id
+	`, hookID, alertID, event, payload).Scan(&id)
+	if err != nil && err != sql.ErrNoRows {
+		return "", fmt.Errorf("failed to queue webhook delivery: %w", err)
+	}
+	return id, nil
+}
+
+// alertWebhookAttempt is a queued delivery with its webhook's endpoint
+type alertWebhookAttempt struct {
+	id       string
+	event    string
+	payload  []byte
+	attempts int
+	url      string
+	secret   string
+}
+
+// DeliverDue sends every queued delivery whose next attempt is due and returns
+// how many succeeded
+func (c *AlertWebhookChannel) DeliverDue(ctx context.Context) int {
+	if time.Since(c.lastPrune) > time.Hour {
+		c.lastPrune = time.Now()
+		c.db.ExecContext(ctx, `
+			DELETE FROM alert_webhook_deliveries
+			WHERE status <> 'pending' AND created_at < NOW() - INTERVAL '30 days'
+		`)
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT d.id, d.event, d.payload, d.attempts, w.url, w.secret
+		FROM alert_webhook_deliveries d
+		JOIN alert_webhooks w ON w.id = d.webhook_id
+		WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND w.is_active
+		ORDER BY d.next_attempt_at
+		LIMIT 100
+	`)
+	if err != nil {
+		log.Printf("Alert webhooks: failed to query due deliveries: %v", err)
+		return 0
+	}
+	var due []alertWebhookAttempt
+	for rows.Next() {
+		var a alertWebhookAttempt
+		if err := rows.Scan(&a.id, &a.event, &a.payload, &a.attempts, &a.url, &a.secret); err == nil {
+			due = append(due, a)
+		}
+	}
+	rows.Close()
+
+	delivered := 0
+	for i := range due {
+		if c.attempt(ctx, &due[i]) {
+			delivered++
+		}
+	}
+	return delivered
+}
+
+// attempt POSTs one delivery and records the outcome. A failure schedules the
+// next try after 30s, 1m, 2m, ... (at most an hour) until maxAttempts.
+func (c *AlertWebhookChannel) attempt(ctx context.Context, a *alertWebhookAttempt) bool {
+	start := time.Now()
+	status, body, err := c.post(ctx, a)
+	duration := int(time.Since(start).Milliseconds())
+	attempts := a.attempts + 1
+
+	var statusCode interface{}
+	if status > 0 {
+		statusCode = status
+	}
+	if err == nil {
+		c.db.ExecContext(ctx, `
+			UPDATE alert_webhook_deliveries SET
+				status = 'delivered', attempts = $2, response_status = $3, response_body = $4,
+				error = NULL, duration_ms = $5, delivered_at = NOW(), next_attempt_at = NULL
+			WHERE id = $1
+		`, a.id, attempts, statusCode, body, duration)
+		return true
+	}
+
+	next := alertWebhookBaseBackoff << (attempts - 1)
+	if next > alertWebhookMaxBackoff || next <= 0 {
+		next = alertWebhookMaxBackoff
+	}
+	newStatus, nextAt := "pending", sql.NullTime{Time: time.Now().Add(next), Valid: true}
+	if attempts >= c.maxAttempts {
+		newStatus, nextAt = "failed", sql.NullTime{}
+		log.Printf("Alert webhook delivery %s failed after %d attempts: %v", a.id, attempts, err)
+	}
+	c.db.ExecContext(ctx, `
+		UPDATE alert_webhook_deliveries SET
+			status = $2, attempts = $3, response_status = $4, response_body = $5,
+			error = $6, duration_ms = $7, next_attempt_at = $8
+		WHERE id = $1
+	`, a.id, newStatus, attempts, statusCode, body, err.Error(), duration, nextAt)
+	return false
+}
+
+// post sends the payload signed with the webhook's secret and returns the
+// response status and the start of its body
+func (c *AlertWebhookChannel) post(ctx context.Context, a *alertWebhookAttempt) (int, string, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", a.url, bytes.NewReader(a.payload))
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PSM-Webhook/1.0")
+	req.Header.Set("X-PSM-Event", a.event)
+	req.Header.Set("X-PSM-Delivery", a.id)
+	req.Header.Set("X-PSM-Signature", signWebhookBody(a.secret, a.payload))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, "", fmt.Errorf("delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, alertWebhookResponseLimit))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), nil
+}
+
+func newWebhookSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ListWebhooks returns a user's alert webhooks with their queue state
+func (c *AlertWebhookChannel) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]AlertWebhook, error) {
+	return c.queryWebhooks(ctx, `WHERE w.user_id = $1 ORDER BY w.created_at`, userID)
+}
+
+// GetWebhook returns one of a user's alert webhooks
+func (c *AlertWebhookChannel) GetWebhook(ctx context.Context, userID uuid.UUID, id string) (*AlertWebhook, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	hooks, err := c.queryWebhooks(ctx, `WHERE w.user_id = $1 AND w.id = $2`, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) == 0 {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	return &hooks[0], nil
+}
+
+func (c *AlertWebhookChannel) queryWebhooks(ctx context.Context, where string, args ...interface{}) ([]AlertWebhook, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT w.id, w.user_id, w.url, w.severities, w.alert_types, w.is_active, w.created_at, w.updated_at,
+		       (SELECT COUNT(*) FROM alert_webhook_deliveries d WHERE d.webhook_id = w.id AND d.status = 'pending'),
+		       (SELECT COUNT(*) FROM alert_webhook_deliveries d
+		        WHERE d.webhook_id = w.id AND d.status = 'failed' AND d.created_at > NOW() - INTERVAL '1 day')
+		FROM alert_webhooks w
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query alert webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []AlertWebhook{}
+	for rows.Next() {
+		var h AlertWebhook
+		var alertTypes []string
+		if err := rows.Scan(&h.ID, &h.UserID, &h.URL, pq.Array(&h.Severities), pq.Array(&alertTypes), &h.IsActive,
+			&h.CreatedAt, &h.UpdatedAt, &h.Pending, &h.Failed24h); err != nil {
+			return nil, fmt.Errorf("failed to scan alert webhook: %w", err)
+		}
+		h.AlertTypes = []string{}
+		if alertTypes != nil {
+			h.AlertTypes = alertTypes
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// CreateWebhook registers a webhook for alerts triggered from now on. The
+// returned webhook includes its secret, generated unless given.
+func (c *AlertWebhookChannel) CreateWebhook(ctx context.Context, userID uuid.UUID, req AlertWebhookRequest) (*AlertWebhook, error) {
+	req.URL = strings.TrimSpace(req.URL)
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	severities := req.Severities
+	if severities == nil {
+		severities = []string{string(AlertSeverityInfo), string(AlertSeverityWarning), string(AlertSeverityCritical)}
+	}
+	severities, alertTypes, err := normalizeAlertFilters(severities, req.AlertTypes)
+	if err != nil {
+		return nil, err
+	}
+	secret := ""
+	if req.Secret != nil {
+		secret = strings.TrimSpace(*req.Secret)
+	}
+	if secret == "" {
+		secret = newWebhookSecret()
+	}
+	active := true
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+
+	var id string
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO alert_webhooks (user_id, url, secret, severities, alert_types, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id
+	`, userID, req.URL, secret, pq.Array(severities), pq.Array(alertTypes), active).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("webhook already exists: %s", req.URL)
+		}
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	hook, err := c.GetWebhook(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = secret
+	return hook, nil
+}
+
+// UpdateWebhook changes a webhook's settings. Passing an empty secret rotates
+// it; the new secret is returned.
+func (c *AlertWebhookChannel) UpdateWebhook(ctx context.Context, userID uuid.UUID, id string, req AlertWebhookRequest) (*AlertWebhook, error) {
+	hook, err := c.GetWebhook(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	url := hook.URL
+	if req.URL = strings.TrimSpace(req.URL); req.URL != "" {
+		if err := validateWebhookURL(req.URL); err != nil {
+			return nil, err
+		}
+		url = req.URL
+	}
+	severities, alertTypes := hook.Severities, hook.AlertTypes
+	if req.Severities != nil {
+		severities = req.Severities
+	}
+	if req.AlertTypes != nil {
+		alertTypes = req.AlertTypes
+	}
+	if severities, alertTypes, err = normalizeAlertFilters(severities, alertTypes); err != nil {
+		return nil, err
+	}
+	active := hook.IsActive
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+	secret := ""
+	if req.Secret != nil {
+		if secret = strings.TrimSpace(*req.Secret); secret == "" {
+			secret = newWebhookSecret()
+		}
+	}
+
+	if _, err := c.db.ExecContext(ctx, `
+		UPDATE alert_webhooks SET
+			url = $3, severities = $4, alert_types = $5, is_active = $6,
+			secret = COALESCE(NULLIF($7, ''), secret)
+		WHERE user_id = $1 AND id = $2
+	`, userID, id, url, pq.Array(severities), pq.Array(alertTypes), active, secret); err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("webhook already exists: %s", url)
+		}
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+
+	if hook, err = c.GetWebhook(ctx, userID, id); err != nil {
+		return nil, err
+	}
+	hook.Secret = secret
+	return hook, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func (c *AlertWebhookChannel) DeleteWebhook(ctx context.Context, userID uuid.UUID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("webhook not found")
+	}
+	result, err := c.db.ExecContext(ctx, `DELETE FROM alert_webhooks WHERE user_id = $1 AND id = $2`, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// TestWebhook delivers a sample "ping" alert right away and returns the logged
+// delivery; a failed ping is not retried
+func (c *AlertWebhookChannel) TestWebhook(ctx context.Context, userID uuid.UUID, id string) (*AlertWebhookDelivery, error) {
+	if _, err := c.GetWebhook(ctx, userID, id); err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{"current_volume": 52000000, "volume_ratio": 3.2})
+	sample := &StockAlert{
+		ID:          "ping",
+		Symbol:      "2330",
+		AlertType:   AlertTypeVolumeSpike,
+		Severity:    AlertSeverityWarning,
+		Title:       "2330 成交量異常 (測試)",
+		Message:     "Webhook test",
+		Data:        data,
+		TriggeredAt: time.Now(),
+	}
+	deliveryID, err := c.enqueue(ctx, id, "ping", sample)
+	if err != nil {
+		return nil, err
+	}
+	c.db.ExecContext(ctx, `UPDATE alert_webhook_deliveries SET next_attempt_at = NULL WHERE id = $1`, deliveryID)
+	return c.deliverNow(ctx, userID, id, deliveryID, c.maxAttempts-1)
+}
+
+// Redeliver sends a logged delivery again right away; if that fails it is
+// retried like a new delivery
+func (c *AlertWebhookChannel) Redeliver(ctx context.Context, userID uuid.UUID, hookID, deliveryID string) (*AlertWebhookDelivery, error) {
+	if _, err := c.GetWebhook(ctx, userID, hookID); err != nil {
+		return nil, err
+	}
+	return c.deliverNow(ctx, userID, hookID, deliveryID, 0)
+}
+
+// deliverNow attempts a delivery immediately, counting priorAttempts towards
+// the retry limit, and returns its log entry
+func (c *AlertWebhookChannel) deliverNow(ctx context.Context, userID uuid.UUID, hookID, deliveryID string, priorAttempts int) (*AlertWebhookDelivery, error) {
+	if _, err := uuid.Parse(deliveryID); err != nil {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	a := alertWebhookAttempt{id: deliveryID, attempts: priorAttempts}
+	err := c.db.QueryRowContext(ctx, `
+		SELECT d.event, d.payload, w.url, w.secret
+		FROM alert_webhook_deliveries d
+		JOIN alert_webhooks w ON w.id = d.webhook_id
+		WHERE d.id = $1 AND d.webhook_id = $2
+	`, deliveryID, hookID).Scan(&a.event, &a.payload, &a.url, &a.secret)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query delivery: %w", err)
+	}
+
+	c.attempt(ctx, &a)
+	deliveries, err := c.queryDeliveries(ctx, `WHERE d.id = $1`, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, fmt.Errorf("delivery not found")
+	}
+	return &deliveries[0], nil
+}
+
+// ListDeliveries returns a webhook's delivery log, newest first, optionally
+// only one status
+func (c *AlertWebhookChannel) ListDeliveries(ctx context.Context, userID uuid.UUID, hookID, status string, limit int) ([]AlertWebhookDelivery, error) {
+	if _, err := c.GetWebhook(ctx, userID, hookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+	return c.queryDeliveries(ctx, `WHERE d.webhook_id = $1 AND ($2 = '' OR d.status = $2) ORDER BY d.created_at DESC LIMIT $3`,
+		hookID, status, limit)
+}
+
+func (c *AlertWebhookChannel) queryDeliveries(ctx context.Context, where string, args ...interface{}) ([]AlertWebhookDelivery, error) {
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT d.id, d.webhook_id, d.alert_id, d.event, d.status, d.attempts, d.next_attempt_at,
+		       d.response_status, COALESCE(d.response_body, ''), COALESCE(d.error, ''), d.duration_ms,
+		       d.payload, d.created_at, d.delivered_at
+		FROM alert_webhook_deliveries d
+	`+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []AlertWebhookDelivery{}
+	for rows.Next() {
+		var d AlertWebhookDelivery
+		var responseStatus, durationMs sql.NullInt64
+		if err := rows.Scan(&d.ID, &d.WebhookID, &d.AlertID, &d.Event, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&responseStatus, &d.ResponseBody, &d.Error, &durationMs,
+			&d.Payload, &d.CreatedAt, &d.DeliveredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery: %w", err)
+		}
+		if responseStatus.Valid {
+			v := int(responseStatus.Int64)
+			d.ResponseStatus = &v
+		}
+		if durationMs.Valid {
+			v := int(durationMs.Int64)
+			d.DurationMs = &v
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, nil
+}
@@ -0,0 +1,509 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Backtest rule DSL
+//
+// A rule is either a free-form expression, a single comparison or a group:
+//   {"expression": "close > MA(20) AND RSI(14) < 40"}
+//   {"left": "MA(5)", "op": "crosses_above", "right": "MA(20)"}
+//   {"all": [rule, ...]}  /  {"any": [rule, ...]}
+//
+// Operands use the indicator expression syntax (see indicator_expression.go).
+// Operators: > >= < <= == crosses_above crosses_below
+
+// BacktestRule is a JSON-defined entry or exit condition
+type BacktestRule struct {
+	Expression string         `json:"expression,omitempty"`
+	Left       string         `json:"left,omitempty"`
+	Op         string         `json:"op,omitempty"`
+	Right      string         `json:"right,omitempty"`
+	All        []BacktestRule `json:"all,omitempty"`
+	Any        []BacktestRule `json:"any,omitempty"`
+}
+
+// BacktestRequest defines a strategy backtest
+type BacktestRequest struct {
+	Symbol          string        `json:"symbol"`
+	StartDate       string        `json:"start_date"` // YYYY-MM-DD
+	EndDate         string        `json:"end_date"`   // YYYY-MM-DD
+	InitialCapital  float64       `json:"initial_capital"`
+	Entry           BacktestRule  `json:"entry"`
+	Exit            *BacktestRule `json:"exit,omitempty"`
+	StopLossPct     float64       `json:"stop_loss_pct,omitempty"`    // e.g. 8 = exit when price falls 8% below entry
+	TakeProfitPct   float64       `json:"take_profit_pct,omitempty"`  // e.g. 20 = exit when price rises 20% above entry
+	PositionSizePct float64       `json:"position_size_pct"`          // Share of cash used per entry (default 100)
+	FeeRate         float64       `json:"fee_rate"`                   // Brokerage fee (default 0.1425%)
+	FeeDiscount     float64       `json:"fee_discount"`               // Broker discount multiplier (default 1 = none)
+	MinFee          float64       `json:"min_fee"`                    // Minimum fee per order (default NT$20)
+	TaxRate         float64       `json:"tax_rate"`                   // Securities transaction tax on sells (default 0.3%)
+	LotSize         int64         `json:"lot_size"`                   // Share increment (default 1000, 1 = odd lots)
+	StrategyID      string        `json:"strategy_id,omitempty"`      // Use a saved strategy's rules
+	StrategyVersion int           `json:"strategy_version,omitempty"` // Strategy revision (default: current, pinned on submit)
+}
+
+// BacktestTrade is a completed round trip
+type BacktestTrade struct {
+	EntryDate   time.Time `json:"entry_date"`
+	EntryPrice  float64   `json:"entry_price"`
+	ExitDate    time.Time `json:"exit_date"`
+	ExitPrice   float64   `json:"exit_price"`
+	Shares      int64     `json:"shares"`
+	Fees        float64   `json:"fees"`
+	Tax         float64   `json:"tax"`
+	PnL         float64   `json:"pnl"`
+	ReturnPct   float64   `json:"return_pct"`
+	HoldingDays int       `json:"holding_days"`
+	ExitReason  string    `json:"exit_reason"` // signal, stop_loss, take_profit, end_of_test
+}
+
+// EquityPoint is one point of the equity curve
+type EquityPoint struct {
+	Date        time.Time `json:"date"`
+	Equity      float64   `json:"equity"`
+	DrawdownPct float64   `json:"drawdown_pct"`
+}
+
+// BacktestStats summarizes a backtest
+type BacktestStats struct {
+	InitialCapital      float64 `json:"initial_capital"`
+	FinalEquity         float64 `json:"final_equity"`
+	TotalReturnPct      float64 `json:"total_return_pct"`
+	AnnualizedReturnPct float64 `json:"annualized_return_pct"`
+	BuyHoldReturnPct    float64 `json:"buy_hold_return_pct"`
+	MaxDrawdownPct      float64 `json:"max_drawdown_pct"`
+	TotalTrades         int     `json:"total_trades"`
+	WinningTrades       int     `json:"winning_trades"`
+	LosingTrades        int     `json:"losing_trades"`
+	WinRate             float64 `json:"win_rate"`
+	AvgTradeReturnPct   float64 `json:"avg_trade_return_pct"`
+	ProfitFactor        float64 `json:"profit_factor"`
+	TotalFees           float64 `json:"total_fees"`
+	TotalTax            float64 `json:"total_tax"`
+	ExposurePct         float64 `json:"exposure_pct"` // Share of bars holding a position
+	TradingDays         int     `json:"trading_days"`
+}
+
+// BacktestResult is the output of a backtest run
+type BacktestResult struct {
+	Symbol      string          `json:"symbol"`
+	StartDate   time.Time       `json:"start_date"`
+	EndDate     time.Time       `json:"end_date"`
+	Stats       BacktestStats   `json:"stats"`
+	Trades      []BacktestTrade `json:"trades"`
+	EquityCurve []EquityPoint   `json:"equity_curve"`
+}
+
+// ApplyDefaults fills in Taiwan market cost defaults
+func (r *BacktestRequest) ApplyDefaults() {
+	r.Symbol = strings.ToUpper(strings.TrimSpace(r.Symbol))
+	if r.InitialCapital <= 0 {
+		r.InitialCapital = 1000000
+	}
+	if r.PositionSizePct <= 0 || r.PositionSizePct > 100 {
+		r.PositionSizePct = 100
+	}
+	if r.FeeRate <= 0 {
+		r.FeeRate = 0.001425
+	}
+	if r.FeeDiscount <= 0 {
+		r.FeeDiscount = 1
+	}
+	if r.MinFee < 0 {
+		r.MinFee = 0
+	} else if r.MinFee == 0 {
+		r.MinFee = 20
+	}
+	if r.TaxRate <= 0 {
+		r.TaxRate = 0.003
+	}
+	if r.LotSize <= 0 {
+		r.LotSize = 1000
+	}
+}
+
+// Validate checks dates and rule syntax; it returns the parsed date range
+func (r *BacktestRequest) Validate() (time.Time, time.Time, error) {
+	if r.Symbol == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("symbol is required")
+	}
+	start, err := time.Parse("2006-01-02", r.StartDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start_date format, use YYYY-MM-DD")
+	}
+	end, err := time.Parse("2006-01-02", r.EndDate)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end_date format, use YYYY-MM-DD")
+	}
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, fmt.Errorf("start_date must be before end_date")
+	}
+
+	if _, err := ruleLookback(r.Entry); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid entry rule: %w", err)
+	}
+	if r.Exit != nil {
+		if _, err := ruleLookback(*r.Exit); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid exit rule: %w", err)
+		}
+	} else if r.StopLossPct <= 0 && r.TakeProfitPct <= 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("an exit rule, stop_loss_pct or take_profit_pct is required")
+	}
+
+	return start, end, nil
+}
+
+// Lookback returns the number of warm-up bars the rules need
+func (r *BacktestRequest) Lookback() int {
+	lookback, _ := ruleLookback(r.Entry)
+	if r.Exit != nil {
+		if exitLookback, err := ruleLookback(*r.Exit); err == nil && exitLookback > lookback {
+			lookback = exitLookback
+		}
+	}
+	return lookback
+}
+
+// ruleLookback validates a rule and returns its warm-up requirement
+func ruleLookback(rule BacktestRule) (int, error) {
+	switch {
+	case len(rule.All) > 0 || len(rule.Any) > 0:
+		lookback := 0
+		for _, child := range append(append([]BacktestRule{}, rule.All...), rule.Any...) {
+			l, err := ruleLookback(child)
+			if err != nil {
+				return 0, err
+			}
+			lookback = maxInt(lookback, l)
+		}
+		return lookback, nil
+
+	case rule.Expression != "":
+		parsed, err := ParseExpression(rule.Expression)
+		if err != nil {
+			return 0, err
+		}
+		if !parsed.IsBoolean() {
+			return 0, fmt.Errorf("expression must be a condition: %s", rule.Expression)
+		}
+		return parsed.Lookback(), nil
+
+	case rule.Left != "" && rule.Op != "" && rule.Right != "":
+		switch rule.Op {
+		case ">", ">=", "<", "<=", "==", "crosses_above", "crosses_below":
+		default:
+			return 0, fmt.Errorf("unsupported operator: %s", rule.Op)
+		}
+		left, err := ParseExpression(rule.Left)
+		if err != nil {
+			return 0, err
+		}
+		right, err := ParseExpression(rule.Right)
+		if err != nil {
+			return 0, err
+		}
+		lookback := maxInt(left.Lookback(), right.Lookback())
+		if strings.HasPrefix(rule.Op, "crosses") {
+			lookback++
+		}
+		return lookback, nil
+	}
+
+	return 0, fmt.Errorf("rule must define expression, left/op/right, all or any")
+}
+
+// compileRule turns a rule into a per-bar predicate over the given bars
+func compileRule(rule BacktestRule, bars []OHLCV) (func(i int) bool, error) {
+	switch {
+	case len(rule.All) > 0 || len(rule.Any) > 0:
+		var allOf, anyOf []func(i int) bool
+		for _, child := range rule.All {
+			fn, err := compileRule(child, bars)
+			if err != nil {
+				return nil, err
+			}
+			allOf = append(allOf, fn)
+		}
+		for _, child := range rule.Any {
+			fn, err := compileRule(child, bars)
+			if err != nil {
+				return nil, err
+			}
+			anyOf = append(anyOf, fn)
+		}
+		return func(i int) bool {
+			for _, fn := range allOf {
+				if !fn(i) {
+					return false
+				}
+			}
+			if len(anyOf) == 0 {
+				return true
+			}
+			for _, fn := range anyOf {
+				if fn(i) {
+					return true
+				}
+			}
+			return false
+		}, nil
+
+	case rule.Expression != "":
+		parsed, err := ParseExpression(rule.Expression)
+		if err != nil {
+			return nil, err
+		}
+		values := parsed.Evaluate(bars)
+		return func(i int) bool {
+			return !isNaN(values[i]) && values[i] != 0
+		}, nil
+
+	case rule.Left != "" && rule.Op != "" && rule.Right != "":
+		left, err := ParseExpression(rule.Left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := ParseExpression(rule.Right)
+		if err != nil {
+			return nil, err
+		}
+		l := left.Evaluate(bars)
+		r := right.Evaluate(bars)
+		op := rule.Op
+
+		return func(i int) bool {
+			if isNaN(l[i]) || isNaN(r[i]) {
+				return false
+			}
+			switch op {
+			case ">":
+				return l[i] > r[i]
+			case ">=":
+				return l[i] >= r[i]
+			case "<":
+				return l[i] < r[i]
+			case "<=":
+				return l[i] <= r[i]
+			case "==":
+				return l[i] == r[i]
+			case "crosses_above", "crosses_below":
+				if i == 0 || isNaN(l[i-1]) || isNaN(r[i-1]) {
+					return false
+				}
+				if op == "crosses_above" {
+					return l[i-1] <= r[i-1] && l[i] > r[i]
+				}
+				return l[i-1] >= r[i-1] && l[i] < r[i]
+			}
+			return false
+		}, nil
+	}
+
+	return nil, fmt.Errorf("rule must define expression, left/op/right, all or any")
+}
+
+// runBacktest simulates a long-only strategy. Signals are evaluated on the close and
+// filled at the next bar's open; stop-loss/take-profit are checked intrabar.
+// bars must be chronological and may include warm-up bars before startIdx.
+func runBacktest(req *BacktestRequest, bars []OHLCV, startIdx int) (*BacktestResult, error) {
+	if startIdx >= len(bars) {
+		return nil, fmt.Errorf("no bars in the requested date range")
+	}
+
+	entry, err := compileRule(req.Entry, bars)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entry rule: %w", err)
+	}
+	var exit func(i int) bool
+	if req.Exit != nil {
+		if exit, err = compileRule(*req.Exit, bars); err != nil {
+			return nil, fmt.Errorf("invalid exit rule: %w", err)
+		}
+	}
+
+	price := func(i int, field string) float64 {
+		var v float64
+		switch field {
+		case "open":
+			v, _ = bars[i].Open.Float64()
+		case "high":
+			v, _ = bars[i].High.Float64()
+		case "low":
+			v, _ = bars[i].Low.Float64()
+		default:
+			v, _ = bars[i].Close.Float64()
+		}
+		return v
+	}
+	buyFee := func(amount float64) float64 {
+		return math.Max(req.MinFee, math.Floor(amount*req.FeeRate*req.FeeDiscount))
+	}
+
+	result := &BacktestResult{
+		Symbol:      req.Symbol,
+		StartDate:   bars[startIdx].Timestamp,
+		EndDate:     bars[len(bars)-1].Timestamp,
+		Trades:      []BacktestTrade{},
+		EquityCurve: make([]EquityPoint, 0, len(bars)-startIdx),
+	}
+	stats := &result.Stats
+	stats.InitialCapital = req.InitialCapital
+
+	cash := req.InitialCapital
+	var shares int64
+	var entryPrice, entryFee float64
+	var entryDate time.Time
+	pendingEntry, pendingExit := false, false
+	peak := req.InitialCapital
+	barsInMarket := 0
+
+	closePosition := func(i int, exitPrice float64, reason string) {
+		amount := exitPrice * float64(shares)
+		fee := math.Max(req.MinFee, math.Floor(amount*req.FeeRate*req.FeeDiscount))
+		tax := math.Floor(amount * req.TaxRate)
+		cash += amount - fee - tax
+
+		cost := entryPrice*float64(shares) + entryFee
+		pnl := amount - fee - tax - cost
+		result.Trades = append(result.Trades, BacktestTrade{
+			EntryDate:   entryDate,
+			EntryPrice:  entryPrice,
+			ExitDate:    bars[i].Timestamp,
+			ExitPrice:   exitPrice,
+			Shares:      shares,
+			Fees:        entryFee + fee,
+			Tax:         tax,
+			PnL:         roundTo(pnl, 0),
+			ReturnPct:   roundTo(pnl/cost*100, 2),
+			HoldingDays: int(bars[i].Timestamp.Sub(entryDate).Hours() / 24),
+			ExitReason:  reason,
+		})
+		stats.TotalFees += entryFee + fee
+		stats.TotalTax += tax
+		shares = 0
+	}
+
+	for i := startIdx; i < len(bars); i++ {
+		open := price(i, "open")
+
+		// Fill orders signalled on the previous close
+		if pendingEntry && shares == 0 && open > 0 {
+			budget := cash * req.PositionSizePct / 100
+			qty := int64(budget/(open*(1+req.FeeRate*req.FeeDiscount))) / req.LotSize * req.LotSize
+			if qty > 0 {
+				amount := open * float64(qty)
+				fee := buyFee(amount)
+				if amount+fee <= cash {
+					shares = qty
+					entryPrice = open
+					entryFee = fee
+					entryDate = bars[i].Timestamp
+					cash -= amount + fee
+				}
+			}
+		}
+		if pendingExit && shares > 0 {
+			closePosition(i, open, "signal")
+		}
+		pendingEntry, pendingExit = false, false
+
+		// Intrabar protective exits; the stop is checked first to stay conservative
+		if shares > 0 && req.StopLossPct > 0 {
+			stop := entryPrice * (1 - req.StopLossPct/100)
+			if price(i, "low") <= stop {
+				closePosition(i, math.Min(open, stop), "stop_loss")
+			}
+		}
+		if shares > 0 && req.TakeProfitPct > 0 {
+			target := entryPrice * (1 + req.TakeProfitPct/100)
+			if price(i, "high") >= target {
+				closePosition(i, math.Max(open, target), "take_profit")
+			}
+		}
+
+		// Signals on the close, filled next bar
+		if i < len(bars)-1 {
+			if shares == 0 && entry(i) {
+				pendingEntry = true
+			} else if shares > 0 && exit != nil && exit(i) {
+				pendingExit = true
+			}
+		}
+
+		closePrice := price(i, "close")
+		if shares > 0 {
+			barsInMarket++
+		}
+		equity := cash + float64(shares)*closePrice
+		if equity > peak {
+			peak = equity
+		}
+		drawdown := 0.0
+		if peak > 0 {
+			drawdown = (peak - equity) / peak * 100
+		}
+		if drawdown > stats.MaxDrawdownPct {
+			stats.MaxDrawdownPct = drawdown
+		}
+		result.EquityCurve = append(result.EquityCurve, EquityPoint{
+			Date:        bars[i].Timestamp,
+			Equity:      roundTo(equity, 0),
+			DrawdownPct: roundTo(drawdown, 2),
+		})
+	}
+
+	// Mark any open position to market at the final close, including exit costs
+	last := len(bars) - 1
+	if shares > 0 {
+		closePosition(last, price(last, "close"), "end_of_test")
+		result.EquityCurve[len(result.EquityCurve)-1].Equity = roundTo(cash, 0)
+	}
+
+	// Statistics
+	stats.FinalEquity = roundTo(cash, 0)
+	stats.TotalReturnPct = roundTo((cash/req.InitialCapital-1)*100, 2)
+	days := result.EndDate.Sub(result.StartDate).Hours() / 24
+	if days > 0 && cash > 0 {
+		stats.AnnualizedReturnPct = roundTo((math.Pow(cash/req.InitialCapital, 365/days)-1)*100, 2)
+	}
+	if firstOpen := price(startIdx, "open"); firstOpen > 0 {
+		stats.BuyHoldReturnPct = roundTo((price(last, "close")/firstOpen-1)*100, 2)
+	}
+	stats.MaxDrawdownPct = roundTo(stats.MaxDrawdownPct, 2)
+	stats.TradingDays = len(bars) - startIdx
+	stats.ExposurePct = roundTo(float64(barsInMarket)/float64(stats.TradingDays)*100, 2)
+
+	var grossProfit, grossLoss, sumReturn float64
+	for _, t := range result.Trades {
+		stats.TotalTrades++
+		sumReturn += t.ReturnPct
+		if t.PnL > 0 {
+			stats.WinningTrades++
+			grossProfit += t.PnL
+		} else {
+			stats.LosingTrades++
+			grossLoss -= t.PnL
+		}
+	}
+	if stats.TotalTrades > 0 {
+		stats.WinRate = roundTo(float64(stats.WinningTrades)/float64(stats.TotalTrades)*100, 2)
+		stats.AvgTradeReturnPct = roundTo(sumReturn/float64(stats.TotalTrades), 2)
+	}
+	if grossLoss > 0 {
+		stats.ProfitFactor = roundTo(grossProfit/grossLoss, 2)
+	}
+
+	return result, nil
+}
+
+// roundTo rounds a float to the given number of decimal places
+func roundTo(v float64, places int) float64 {
+	p, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'f', places, 64), 64)
+	return p
+}
@@ -0,0 +1,364 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"psm-backend/internal/database"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrBacktestInvalid wraps errors in a backtest request's rules, dates or symbol
+	ErrBacktestInvalid = errors.New("invalid backtest request")
+	// ErrBacktestJobNotFound is returned for an unknown job ID
+	ErrBacktestJobNotFound = errors.New("backtest job not found")
+)
+
+// BacktestService runs strategy backtests against stored OHLCV data
+type BacktestService struct {
+	db              *database.DB
+	strategyService *StrategyService
+	semaphore       chan struct{} // Limits concurrently running backtest jobs
+}
+
+func NewBacktestService(db *database.DB, strategyService *StrategyService) *BacktestService {
+	return &BacktestService{
+		db:              db,
+		strategyService: strategyService,
+		semaphore:       make(chan struct{}, 2),
+	}
+}
+
+// BacktestJob represents an asynchronous backtest run
+type BacktestJob struct {
+	ID              string          `json:"id"`
+	Symbol          string          `json:"symbol"`
+	Status          string          `json:"status"` // queued, running, completed, failed
+	StrategyID      *string         `json:"strategy_id,omitempty"`
+	StrategyVersion *int            `json:"strategy_version,omitempty"`
+	Request         BacktestRequest `json:"request"`
+	Result          *BacktestResult `json:"result,omitempty"`
+	Error           string          `json:"error,omitempty"`
+	CreatedAt       time.Time       `json:"created_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	CompletedAt     *time.Time      `json:"completed_at,omitempty"`
+}
+
+// RunBacktest runs a backtest synchronously
+func (s *BacktestService) RunBacktest(ctx context.Context, req *BacktestRequest) (*BacktestResult, error) {
+	start, end, err := s.prepareRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	bars, startIdx, err := s.loadBars(ctx, req.Symbol, start, end, req.Lookback()+expressionWarmupBars)
+	if err != nil {
+		return nil, err
+	}
+
+	return runBacktest(req, bars, startIdx)
+}
+
+// SubmitBacktest validates a request, records a job and runs it in the background
+func (s *BacktestService) SubmitBacktest(ctx context.Context, req *BacktestRequest) (*BacktestJob, error) {
+	if _, _, err := s.prepareRequest(ctx, req); err != nil {
+		return nil, err
+	}
+
+	requestJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode backtest request: %w", err)
+	}
+
+	job := &BacktestJob{
+		Symbol:  req.Symbol,
+		Status:  "queued",
+		Request: *req,
+	}
+	if req.StrategyID != "" {
+		job.StrategyID = &req.StrategyID
+		job.StrategyVersion = &req.StrategyVersion
+	}
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO backtest_jobs (symbol, status, request, strategy_id, strategy_version)
+		VALUES ($1, 'queued', $2, $3, $4)
+		RETURNING id, created_at
+	`, req.Symbol, requestJSON, job.StrategyID, job.StrategyVersion).Scan(&job.ID, &job.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backtest job: %w", err)
+	}
+
+	go s.executeJob(job.ID, *req)
+
+	return job, nil
+}
+
+// prepareRequest resolves the strategy, applies defaults and validates a request,
+// wrapping validation errors in ErrBacktestInvalid
+func (s *BacktestService) prepareRequest(ctx context.Context, req *BacktestRequest) (time.Time, time.Time, error) {
+	if err := s.resolveStrategy(ctx, req); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	req.ApplyDefaults()
+	start, end, err := req.Validate()
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %v", ErrBacktestInvalid, err)
+	}
+	return start, end, nil
+}
+
+// resolveStrategy fills the rules of a request that references a saved strategy and
+// pins the strategy version so the job can be reproduced later. Rules given in the
+// request itself take precedence over the strategy's.
+func (s *BacktestService) resolveStrategy(ctx context.Context, req *BacktestRequest) error {
+	if req.StrategyID == "" {
+		return nil
+	}
+	if _, err := uuid.Parse(req.StrategyID); err != nil {
+		return fmt.Errorf("%w: invalid strategy_id %q", ErrBacktestInvalid, req.StrategyID)
+	}
+
+	version, err := s.strategyService.GetVersion(ctx, req.StrategyID, req.StrategyVersion)
+	if err != nil {
+		return err
+	}
+	def := version.Definition
+	req.StrategyVersion = version.Version
+
+	if req.Entry.Expression == "" && req.Entry.Left == "" && len(req.Entry.All) == 0 && len(req.Entry.Any) == 0 {
+		req.Entry = def.Entry
+	}
+	if req.Exit == nil {
+		req.Exit = def.Exit
+	}
+	if req.StopLossPct == 0 {
+		req.StopLossPct = def.StopLossPct
+	}
+	if req.TakeProfitPct == 0 {
+		req.TakeProfitPct = def.TakeProfitPct
+	}
+	if req.PositionSizePct == 0 {
+		req.PositionSizePct = def.PositionSizePct
+	}
+	if req.Symbol == "" && len(def.Universe.Symbols) == 1 {
+		req.Symbol = def.Universe.Symbols[0]
+	}
+
+	return nil
+}
+
+// executeJob runs a queued job once a slot is free and stores the outcome.
+// A panic in the engine fails the job instead of taking down the server.
+func (s *BacktestService) executeJob(jobID string, req BacktestRequest) {
+	s.semaphore <- struct{}{}
+	defer func() { <-s.semaphore }()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Backtest %s panicked: %v\n%s", jobID, r, debug.Stack())
+			s.failJob(jobID, fmt.Sprintf("internal error: %v", r))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	s.db.ExecContext(ctx, `UPDATE backtest_jobs SET status = 'running', started_at = NOW() WHERE id = $1`, jobID)
+
+	result, err := s.RunBacktest(ctx, &req)
+	if err != nil {
+		log.Printf("Backtest %s failed: %v", jobID, err)
+		s.failJob(jobID, err.Error())
+		return
+	}
+
+	resultJSON, err := json.Marshal(result)
+	if err != nil {
+		s.failJob(jobID, err.Error())
+		return
+	}
+
+	s.db.ExecContext(ctx, `
+		UPDATE backtest_jobs SET status = 'completed', result = $2, completed_at = NOW() WHERE id = $1
+	`, jobID, resultJSON)
+}
+
+// failJob marks a job failed; it uses its own context so a timed-out run is still recorded
+func (s *BacktestService) failJob(jobID, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE backtest_jobs SET status = 'failed', error = $2, completed_at = NOW() WHERE id = $1
+	`, jobID, message); err != nil {
+		log.Printf("Failed to mark backtest %s failed: %v", jobID, err)
+	}
+}
+
+// ResumeJobs handles jobs left over from before a restart: running jobs are
+// failed, since they may be what stopped the server, and queued jobs are run again
+func (s *BacktestService) ResumeJobs(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE backtest_jobs
+		SET status = 'failed', error = 'interrupted by a server restart', completed_at = NOW()
+		WHERE status = 'running'
+	`); err != nil {
+		return fmt.Errorf("failed to fail interrupted backtests: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, request FROM backtest_jobs WHERE status = 'queued' ORDER BY created_at
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to load queued backtests: %w", err)
+	}
+	defer rows.Close()
+
+	resumed := 0
+	for rows.Next() {
+		var jobID string
+		var requestJSON []byte
+		if err := rows.Scan(&jobID, &requestJSON); err != nil {
+			return fmt.Errorf("failed to scan queued backtest: %w", err)
+		}
+		var req BacktestRequest
+		if err := json.Unmarshal(requestJSON, &req); err != nil {
+			s.failJob(jobID, fmt.Sprintf("failed to decode request: %v", err))
+			continue
+		}
+		go s.executeJob(jobID, req)
+		resumed++
+	}
+	if resumed > 0 {
+		log.Printf("Resumed %d queued backtests", resumed)
+	}
+	return rows.Err()
+}
+
+// GetJob returns a backtest job including its result
+func (s *BacktestService) GetJob(ctx context.Context, jobID string) (*BacktestJob, error) {
+	query := `
+		SELECT id, symbol, status, strategy_id, strategy_version, request, result, COALESCE(error, ''),
+		       created_at, started_at, completed_at
+		FROM backtest_jobs
+		WHERE id = $1
+	`
+
+	if _, err := uuid.Parse(jobID); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrBacktestJobNotFound, jobID)
+	}
+
+	var job BacktestJob
+	var requestJSON []byte
+	var resultJSON []byte
+	err := s.db.QueryRowContext(ctx, query, jobID).Scan(
+		&job.ID, &job.Symbol, &job.Status, &job.StrategyID, &job.StrategyVersion, &requestJSON, &resultJSON, &job.Error,
+		&job.CreatedAt, &job.StartedAt, &job.CompletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s", ErrBacktestJobNotFound, jobID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal(requestJSON, &job.Request)
+	if len(resultJSON) > 0 {
+		var result BacktestResult
+		if err := json.Unmarshal(resultJSON, &result); err == nil {
+			job.Result = &result
+		}
+	}
+
+	return &job, nil
+}
+
+// ListJobs returns recent backtest jobs without their results, optionally filtered by symbol or strategy
+func (s *BacktestService) ListJobs(ctx context.Context, symbol, strategyID string, limit int) ([]BacktestJob, error) {
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+		SELECT id, symbol, status, strategy_id, strategy_version, request, COALESCE(error, ''),
+		       created_at, started_at, completed_at
+		FROM backtest_jobs
+		WHERE ($1 = '' OR symbol = $1) AND ($2 = '' OR strategy_id::text = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, symbol, strategyID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []BacktestJob{}
+	for rows.Next() {
+		var job BacktestJob
+		var requestJSON []byte
+		if err := rows.Scan(
+			&job.ID, &job.Symbol, &job.Status, &job.StrategyID, &job.StrategyVersion, &requestJSON, &job.Error,
+			&job.CreatedAt, &job.StartedAt, &job.CompletedAt,
+		); err != nil {
+			continue
+		}
+		json.Unmarshal(requestJSON, &job.Request)
+		jobs = append(jobs, job)
+	}
+
+	return jobs, nil
+}
+
+// loadBars loads bars in [start, end] plus up to warmup bars before start.
+// It returns the bars in chronological order and the index of the first bar in range.
+func (s *BacktestService) loadBars(ctx context.Context, symbol string, start, end time.Time, warmup int) ([]OHLCV, int, error) {
+	query := `
+		SELECT symbol, timestamp, open, high, low, close, volume, turnover FROM (
+			(SELECT symbol, timestamp, open, high, low, close, volume, turnover
+			 FROM stock_ohlcv
+			 WHERE symbol = $1 AND timestamp < $2
+			 ORDER BY timestamp DESC
+			 LIMIT $4)
+			UNION ALL
+			(SELECT symbol, timestamp, open, high, low, close, volume, turnover
+			 FROM stock_ohlcv
+			 WHERE symbol = $1 AND timestamp >= $2 AND timestamp < $3)
+		) bars
+		ORDER BY timestamp ASC
+	`
+
+	// end is inclusive: include the whole end day
+	rows, err := s.db.QueryContext(ctx, query, symbol, start, end.AddDate(0, 0, 1), warmup)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to load bars: %w", err)
+	}
+	defer rows.Close()
+
+	var bars []OHLCV
+	startIdx := -1
+	for rows.Next() {
+		var bar OHLCV
+		if err := rows.Scan(
+			&bar.Symbol, &bar.Timestamp, &bar.Open, &bar.High, &bar.Low, &bar.Close, &bar.Volume, &bar.Turnover,
+		); err != nil {
+			return nil, 0, err
+		}
+		if startIdx < 0 && !bar.Timestamp.Before(start) {
+			startIdx = len(bars)
+		}
+		bars = append(bars, bar)
+	}
+
+	if startIdx < 0 {
+		return nil, 0, fmt.Errorf("no data for %s between %s and %s", symbol, start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	return bars, startIdx, nil
+}
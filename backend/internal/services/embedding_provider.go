@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// EmbeddingDimensions is the vector size stored in pgvector. Every embedding
+// model is asked for (or natively produces) this many dimensions.
+const EmbeddingDimensions = 768
+
+// EmbeddingProvider turns text into vectors for semantic search
+type EmbeddingProvider interface {
+	Name() string
+	Model() string
+	// Embed returns one vector per text; query marks search queries, which
+	// some models embed differently from the documents being searched
+	Embed(ctx context.Context, texts []string, query bool) ([][]float32, error)
+}
+
+// newEmbeddingProvider reads AI_EMBEDDING_PROVIDER (gemini, openai, ollama or
+// none) and AI_EMBEDDING_MODEL. By default the AI_PROVIDER is used when it
+// offers embeddings; with Claude, which does not, the Gemini or OpenAI key is
+// used if present. Returns nil when no provider is usable.
+func newEmbeddingProvider() (EmbeddingProvider, error) {
+	name := strings.ToLower(strings.TrimSpace(os.Getenv("AI_EMBEDDING_PROVIDER")))
+	if name == "" {
+		name = strings.ToLower(strings.TrimSpace(os.Getenv("AI_PROVIDER")))
+		if name == "" || name == AIProviderClaude {
+			switch {
+			case os.Getenv("GEMINI_API_KEY") != "":
+				name = AIProviderGemini
+			case os.Getenv("OPENAI_API_KEY") != "":
+				name = AIProviderOpenAI
+			default:
+				return nil, nil
+			}
+		}
+	}
+
+	model := func(fallback string) string {
+		if m := os.Getenv("AI_EMBEDDING_MODEL"); m != "" {
+			return m
+		}
+		return fallback
+	}
+
+	switch name {
+	case "none":
+		return nil, nil
+	case AIProviderGemini:
+		key := os.Getenv("GEMINI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("gemini embeddings require GEMINI_API_KEY")
+		}
+		return &geminiEmbedder{llmHTTP: newLLMHTTP(60 * time.Second), apiKey: key, model: model("text-embedding-004")}, nil
+	case AIProviderOpenAI:
+		key := os.Getenv("OPENAI_API_KEY")
+		if key == "" {
+			return nil, fmt.Errorf("openai embeddings require OPENAI_API_KEY")
+		}
+		return &openAIEmbedder{llmHTTP: newLLMHTTP(60 * time.Second), apiKey: key, model: model("text-embedding-3-small")}, nil
+	case AIProviderOllama:
+		baseURL := strings.TrimRight(os.Getenv("OLLAMA_URL"), "/")
+		if baseURL == "" {
+			baseURL = "http://localhost:11434"
+		}
+		return &ollamaEmbedder{llmHTTP: newLLMHTTP(2 * time.Minute), baseURL: baseURL, model: model("nomic-embed-text")}, nil
+	default:
+		return nil, fmt.Errorf("unknown embedding provider %q (expected gemini, openai, ollama or none)", name)
+	}
+}
+
+// checkEmbeddings validates a provider's vectors against the inputs
+func checkEmbeddings(provider string, vectors [][]float32, texts int) ([][]float32, error) {
+	if len(vectors) != texts {
+		return nil, fmt.Errorf("%s returned %d embeddings for %d texts", provider, len(vectors), texts)
+	}
+	for _, v := range vectors {
+		if len(v) != EmbeddingDimensions {
+			return nil, fmt.Errorf("%s returned %d-dimensional embeddings, expected %d", provider, len(v), EmbeddingDimensions)
+		}
+	}
+	return vectors, nil
+}
+
+// Gemini
+
+type geminiEmbedder struct {
+	llmHTTP
+	apiKey string
+	model  string
+}
+
+func (p *geminiEmbedder) Name() string  { return AIProviderGemini }
+func (p *geminiEmbedder) Model() string { return p.model }
+
+func (p *geminiEmbedder) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	taskType := "RETRIEVAL_DOCUMENT"
+	if query {
+		taskType = "RETRIEVAL_QUERY"
+	}
+	type request struct {
+		Model                string        `json:"model"`
+		Content              geminiContent `json:"content"`
+		TaskType             string        `json:"taskType"`
+		OutputDimensionality int           `json:"outputDimensionality"`
+	}
+	payload := struct {
+		Requests []request `json:"requests"`
+	}{}
+	for _, text := range texts {
+		payload.Requests = append(payload.Requests, request{
+			Model:                "models/" + p.model,
+			Content:              geminiContent{Parts: []geminiPart{{Text: text}}},
+			TaskType:             taskType,
+			OutputDimensionality: EmbeddingDimensions,
+		})
+	}
+
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:batchEmbedContents?key=%s", p.model, p.apiKey)
+	resp, err := p.post(ctx, false, apiURL, payload, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Gemini API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Embeddings []struct {
+			Values []float32 `json:"values"`
+		} `json:"embeddings"`
+		Error *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("Gemini API error: %s (code: %d)", result.Error.Message, result.Error.Code)
+	}
+
+	vectors := make([][]float32, 0, len(result.Embeddings))
+	for _, e := range result.Embeddings {
+		vectors = append(vectors, e.Values)
+	}
+	return checkEmbeddings("Gemini", vectors, len(texts))
+}
+
+// OpenAI
+
+type openAIEmbedder struct {
+	llmHTTP
+	apiKey string
+	model  string
+}
+
+func (p *openAIEmbedder) Name() string  { return AIProviderOpenAI }
+func (p *openAIEmbedder) Model() string { return p.model }
+
+func (p *openAIEmbedder) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	payload := map[string]interface{}{
+		"model":      p.model,
+		"input":      texts,
+		"dimensions": EmbeddingDimensions,
+	}
+	resp, err := p.post(ctx, false, "https://api.openai.com/v1/embeddings", payload, map[string]string{"Authorization": "Bearer " + p.apiKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to call OpenAI API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+		Error *struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+		} `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.Error != nil {
+		return nil, fmt.Errorf("OpenAI API error: %s (%s)", result.Error.Message, result.Error.Type)
+	}
+
+	vectors := make([][]float32, len(texts))
+	for _, d := range result.Data {
+		if d.Index >= 0 && d.Index < len(vectors) {
+			vectors[d.Index] = d.Embedding
+		}
+	}
+	return checkEmbeddings("OpenAI", vectors, len(texts))
+}
+
+// Ollama
+
+type ollamaEmbedder struct {
+	llmHTTP
+	baseURL string
+	model   string
+}
+
+func (p *ollamaEmbedder) Name() string  { return AIProviderOllama }
+func (p *ollamaEmbedder) Model() string { return p.model }
+
+func (p *ollamaEmbedder) Embed(ctx context.Context, texts []string, query bool) ([][]float32, error) {
+	// nomic-embed-text expects task prefixes
+	input := texts
+	if strings.HasPrefix(p.model, "nomic-embed-text") {
+		prefix := "search_document: "
+		if query {
+			prefix = "search_query: "
+		}
+		input = make([]string, len(texts))
+		for i, t := range texts {
+			input[i] = prefix + t
+		}
+	}
+
+	resp, err := p.post(ctx, false, p.baseURL+"/api/embed", map[string]interface{}{"model": p.model, "input": input}, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Ollama at %s: %w", p.baseURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var result struct {
+		Embeddings [][]float32 `json:"embeddings"`
+		Error      string      `json:"error"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+	if result.Error != "" {
+		return nil, fmt.Errorf("Ollama error: %s", result.Error)
+	}
+	return checkEmbeddings("Ollama", result.Embeddings, len(texts))
+}
@@ -0,0 +1,513 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"psm-backend/internal/database"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EmbeddingService embeds news articles and AI analyses into pgvector and
+// answers semantic searches over them
+type EmbeddingService struct {
+	db          *database.DB
+	provider    EmbeddingProvider
+	providerErr error
+
+	mu           sync.Mutex
+	tablesReady  bool
+	tablesCheck  time.Time
+	lastIndexRun *time.Time
+}
+
+// SemanticSearchRequest filters a semantic search
+type SemanticSearchRequest struct {
+	Query  string
+	Symbol string // Optional
+	Type   string // news, analysis or all (default)
+	Days   int    // Look-back window (default 90)
+	Limit  int    // Per type (default 20)
+}
+
+// SemanticSearchHit is one article or analysis ranked by similarity to the query
+type SemanticSearchHit struct {
+	Type         string    `json:"type"` // news or analysis
+	ID           string    `json:"id"`
+	Symbol       string    `json:"symbol"`
+	Title        string    `json:"title"`
+	Snippet      string    `json:"snippet"`
+	Source       string    `json:"source,omitempty"`
+	URL          string    `json:"url,omitempty"`
+	Sentiment    string    `json:"sentiment,omitempty"`
+	AnalysisType string    `json:"analysis_type,omitempty"`
+	Date         time.Time `json:"date"`
+	Similarity   float64   `json:"similarity"` // Cosine similarity, 1 = identical
+}
+
+// EmbeddingStatus reports indexing coverage
+type EmbeddingStatus struct {
+	Enabled         bool       `json:"enabled"`
+	Reason          string     `json:"reason,omitempty"` // Why semantic search is disabled
+	Provider        string     `json:"provider,omitempty"`
+	Model           string     `json:"model,omitempty"`
+	NewsIndexed     int        `json:"news_indexed"`
+	NewsPending     int        `json:"news_pending"`
+	AnalysesIndexed int        `json:"analyses_indexed"`
+	AnalysesPending int        `json:"analyses_pending"`
+	RetrievalChunks int        `json:"retrieval_chunks"` // Chunked analyses, filings and revenue reports for prompts
+	LastIndexRun    *time.Time `json:"last_index_run,omitempty"`
+}
+
+func NewEmbeddingService(db *database.DB) *EmbeddingService {
+	provider, err := newEmbeddingProvider()
+	if err != nil {
+		log.Printf("Warning: embeddings disabled: %v", err)
+	} else if provider != nil {
+		log.Printf("Embedding provider: %s (%s)", provider.Name(), provider.Model())
+	}
+	return &EmbeddingService{db: db, provider: provider, providerErr: err}
+}
+
+// Enabled reports whether a provider is configured and the pgvector tables
+// exist. The table check is repeated every few minutes so installing pgvector
+// and rerunning the migration takes effect without a restart.
+func (s *EmbeddingService) Enabled(ctx context.Context) bool {
+	return s.disabledReason(ctx) == ""
+}
+
+func (s *EmbeddingService) disabledReason(ctx context.Context) string {
+	if s.provider == nil {
+		if s.providerErr != nil {
+			return s.providerErr.Error()
+		}
+		return "no embedding provider configured (AI_EMBEDDING_PROVIDER, GEMINI_API_KEY or OPENAI_API_KEY)"
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.tablesCheck) > 5*time.Minute {
+		err := s.db.QueryRowContext(ctx,
+			`SELECT to_regclass('news_embeddings') IS NOT NULL AND to_regclass('ai_analysis_embeddings') IS NOT NULL`,
+		).Scan(&s.tablesReady)
+		if err == nil {
+			s.tablesCheck = time.Now()
+		}
+	}
+	if !s.tablesReady {
+		return "pgvector embedding tables missing (install pgvector and run migration 032)"
+	}
+	return ""
+}
+
+// vectorLiteral formats a vector in pgvector's text form
+func vectorLiteral(v []float32) string {
+	parts := make([]string, len(v))
+	for i, x := range v {
+		parts[i] = strconv.FormatFloat(float64(x), 'f', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// embedQuery embeds a search query
+func (s *EmbeddingService) embedQuery(ctx context.Context, query string) (string, error) {
+	vectors, err := s.provider.Embed(ctx, []string{truncateRunes(query, 1000)}, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to embed query: %w", err)
+	}
+	return vectorLiteral(vectors[0]), nil
+}
+
+// IndexPending embeds up to batch articles and batch analyses that have no
+// embedding from the current model, newest first
+func (s *EmbeddingService) IndexPending(ctx context.Context, batch int) (int, int, error) {
+	if reason := s.disabledReason(ctx); reason != "" {
+		return 0, 0, fmt.Errorf("semantic search disabled: %s", reason)
+	}
+	if batch <= 0 {
+		batch = 64
+	}
+	defer func() {
+		now := time.Now()
+		s.mu.Lock()
+		s.lastIndexRun = &now
+		s.mu.Unlock()
+	}()
+
+	model := s.provider.Model()
+	news, err := s.indexRows(ctx, `
+		SELECT n.id, n.title || E'\n' || COALESCE(NULLIF(n.summary, ''), LEFT(COALESCE(n.content, ''), 1000))
+		FROM stock_news n
+		LEFT JOIN news_embeddings e ON e.article_id = n.id
+		WHERE (e.article_id IS NULL OR e.model <> $1)
+		  AND n.published_at >= NOW() - INTERVAL '365 days'
+		ORDER BY n.published_at DESC
+		LIMIT $2
+	`, `
+		INSERT INTO news_embeddings (article_id, model, embedding)
+		VALUES ($1, $2, $3::vector)
+		ON CONFLICT (article_id) DO UPDATE SET model = EXCLUDED.model, embedding = EXCLUDED.embedding, created_at = NOW()
+	`, model, batch)
+	if err != nil {
+		return news, 0, err
+	}
+
+	// An analysis regenerated after it was embedded is embedded again
+	analyses, err := s.indexRows(ctx, `
+		SELECT c.id, c.symbol || ' ' || c.analysis_type || E'\n' || c.content
+		FROM ai_analysis_cache c
+		LEFT JOIN ai_analysis_embeddings e ON e.analysis_id = c.id
+		WHERE e.analysis_id IS NULL OR e.model <> $1 OR e.created_at < c.created_at
+		ORDER BY c.created_at DESC
+		LIMIT $2
+	`, `
+		INSERT INTO ai_analysis_embeddings (analysis_id, model, embedding)
+		VALUES ($1, $2, $3::vector)
+		ON CONFLICT (analysis_id) DO UPDATE SET model = EXCLUDED.model, embedding = EXCLUDED.embedding, created_at = NOW()
+	`, model, batch)
+	return news, analyses, err
+}
+
+// indexRows embeds the (id, text) rows of selectQuery and stores them with insertQuery
+func (s *EmbeddingService) indexRows(ctx context.Context, selectQuery, insertQuery, model string, batch int) (int, error) {
+	rows, err := s.db.QueryContext(ctx, selectQuery, model, batch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query pending embeddings: %w", err)
+	}
+	var ids, texts []string
+	for rows.Next() {
+		var id, text string
+		if err := rows.Scan(&id, &text); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+		texts = append(texts, truncateRunes(text, 2000))
+	}
+	rows.Close()
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	vectors, err := s.provider.Embed(ctx, texts, false)
+	if err != nil {
+		return 0, fmt.Errorf("failed to embed: %w", err)
+	}
+
+	stored := 0
+	for i, id := range ids {
+		if _, err := s.db.ExecContext(ctx, insertQuery, id, model, vectorLiteral(vectors[i])); err != nil {
+			log.Printf("Warning: failed to store embedding %s: %v", id, err)
+			continue
+		}
+		stored++
+	}
+	return stored, nil
+}
+
+// Search returns the articles and/or analyses most similar to the query
+func (s *EmbeddingService) Search(ctx context.Context, req SemanticSearchRequest) ([]SemanticSearchHit, error) {
+	if reason := s.disabledReason(ctx); reason != "" {
+		return nil, fmt.Errorf("semantic search disabled: %s", reason)
+	}
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return nil, fmt.Errorf("q is required")
+	}
+	if req.Type == "" {
+		req.Type = "all"
+	}
+	if req.Type != "all" && req.Type != "news" && req.Type != "analysis" {
+		return nil, fmt.Errorf("type must be news, analysis or all")
+	}
+	if req.Days <= 0 {
+		req.Days = 90
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 20
+	}
+
+	vector, err := s.embedQuery(ctx, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	model := s.provider.Model()
+
+	hits := []SemanticSearchHit{}
+	if req.Type != "analysis" {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT n.id, n.symbol, n.title, COALESCE(n.summary, ''), n.source, COALESCE(n.source_url, ''),
+			       COALESCE(n.sentiment, ''), n.published_at, 1 - (e.embedding <=> $1::vector)
+			FROM news_embeddings e
+			JOIN stock_news n ON n.id = e.article_id
+			WHERE e.model = $2
+			  AND ($3 = '' OR EXISTS (SELECT 1 FROM article_symbols a WHERE a.article_id = n.id AND a.symbol = $3))
+			  AND n.published_at >= NOW() - make_interval(days => $4)
+			ORDER BY e.embedding <=> $1::vector
+			LIMIT $5
+		`, vector, model, req.Symbol, req.Days, req.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search news: %w", err)
+		}
+		for rows.Next() {
+			h := SemanticSearchHit{Type: "news"}
+			if err := rows.Scan(&h.ID, &h.Symbol, &h.Title, &h.Snippet, &h.Source, &h.URL, &h.Sentiment, &h.Date, &h.Similarity); err != nil {
+				continue
+			}
+			h.Snippet = truncateRunes(h.Snippet, 200)
+			h.Similarity = roundTo(h.Similarity, 4)
+			hits = append(hits, h)
+		}
+		rows.Close()
+	}
+
+	if req.Type != "news" {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT c.id, c.symbol, c.analysis_type, c.content, c.created_at, 1 - (e.embedding <=> $1::vector)
+			FROM ai_analysis_embeddings e
+			JOIN ai_analysis_cache c ON c.id = e.analysis_id
+			WHERE e.model = $2
+			  AND ($3 = '' OR c.symbol = $3)
+			  AND c.created_at >= NOW() - make_interval(days => $4)
+			ORDER BY e.embedding <=> $1::vector
+			LIMIT $5
+		`, vector, model, req.Symbol, req.Days, req.Limit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search analyses: %w", err)
+		}
+		for rows.Next() {
+			h := SemanticSearchHit{Type: "analysis"}
+			if err := rows.Scan(&h.ID, &h.Symbol, &h.AnalysisType, &h.Snippet, &h.Date, &h.Similarity); err != nil {
+				continue
+			}
+			h.Title = h.Symbol + " " + h.AnalysisType
+			h.Snippet = truncateRunes(h.Snippet, 200)
+			h.Similarity = roundTo(h.Similarity, 4)
+			hits = append(hits, h)
+		}
+		rows.Close()
+	}
+
+	return hits, nil
+}
+
+// RelevantArticles returns up to limit IDs of a symbol's articles from the
+// last days, ranked by similarity to the query discounted by age (half-life
+// of about 10 days), so older but on-topic coverage can beat recent noise
+func (s *EmbeddingService) RelevantArticles(ctx context.Context, symbol, query string, days, limit int) ([]string, error) {
+	if reason := s.disabledReason(ctx); reason != "" {
+		return nil, fmt.Errorf("semantic search disabled: %s", reason)
+	}
+
+	vector, err := s.embedQuery(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT n.id
+		FROM article_symbols a
+		JOIN stock_news n ON n.id = a.article_id
+		JOIN news_embeddings e ON e.article_id = n.id AND e.model = $2
+		WHERE a.symbol = $1 AND n.published_at >= NOW() - make_interval(days => $3)
+		ORDER BY (1 - (e.embedding <=> $4::vector))
+		         * exp(-EXTRACT(EPOCH FROM NOW() - n.published_at) / 86400 / 14) DESC
+		LIMIT $5
+	`, symbol, s.provider.Model(), days, vector, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rank articles: %w", err)
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// GetStatus reports the provider and how much is indexed
+func (s *EmbeddingService) GetStatus(ctx context.Context) EmbeddingStatus {
+	status := EmbeddingStatus{Reason: s.disabledReason(ctx)}
+	status.Enabled = status.Reason == ""
+	if s.provider != nil {
+		status.Provider = s.provider.Name()
+		status.Model = s.provider.Model()
+	}
+	s.mu.Lock()
+	status.LastIndexRun = s.lastIndexRun
+	s.mu.Unlock()
+	if !status.Enabled {
+		return status
+	}
+
+	s.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM news_embeddings WHERE model = $1),
+			(SELECT COUNT(*) FROM stock_news n WHERE n.published_at >= NOW() - INTERVAL '365 days'
+			   AND NOT EXISTS (SELECT 1 FROM news_embeddings e WHERE e.article_id = n.id AND e.model = $1)),
+			(SELECT COUNT(*) FROM ai_analysis_embeddings WHERE model = $1),
+			(SELECT COUNT(*) FROM ai_analysis_cache c
+			   WHERE NOT EXISTS (SELECT 1 FROM ai_analysis_embeddings e
+			                     WHERE e.analysis_id = c.id AND e.model = $1 AND e.created_at >= c.created_at))
+	`, status.Model).Scan(&status.NewsIndexed, &status.NewsPending, &status.AnalysesIndexed, &status.AnalysesPending)
+	if s.retrievalReady(ctx) {
+		s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM ai_retrieval_chunks WHERE model = $1`, status.Model).Scan(&status.RetrievalChunks)
+	}
+	return status
+}
+
+// EmbeddingWorker keeps the embeddings up to date in the background
+type EmbeddingWorker struct {
+	embeddingService *EmbeddingService
+	interval         time.Duration
+	batch            int
+	maxBatches       int // Per run, so a large backlog is worked off gradually
+	mu               sync.Mutex
+	isRunning        bool
+	isBusy           bool
+	stopChan         chan struct{}
+}
+
+// NewEmbeddingWorker reads AI_EMBEDDING_INTERVAL (minutes, default 5) and
+// AI_EMBEDDING_BATCH (texts per provider call, default 64)
+func NewEmbeddingWorker(embeddingService *EmbeddingService) *EmbeddingWorker {
+	interval := 5
+	if v, err := strconv.Atoi(os.Getenv("AI_EMBEDDING_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+	batch := 64
+	if v, err := strconv.Atoi(os.Getenv("AI_EMBEDDING_BATCH")); err == nil && v > 0 && v <= 100 {
+		batch = v
+	}
+
+	return &EmbeddingWorker{
+		embeddingService: embeddingService,
+		interval:         time.Duration(interval) * time.Minute,
+		batch:            batch,
+		maxBatches:       10,
+		stopChan:         make(chan struct{}),
+	}
+}
+
+// Start launches the indexing loop
+func (w *EmbeddingWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Embedding worker started (every %v, batch %d)", w.interval, w.batch)
+}
+
+// Stop stops the indexing loop
+func (w *EmbeddingWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+// EmbeddingWorkerStatus is the worker state with the indexing coverage
+type EmbeddingWorkerStatus struct {
+	Running  bool            `json:"running"`
+	Indexing bool            `json:"indexing"`
+	Interval string          `json:"interval"`
+	Batch    int             `json:"batch"`
+	Index    EmbeddingStatus `json:"index"`
+}
+
+// GetStatus returns the worker state with the indexing coverage
+func (w *EmbeddingWorker) GetStatus(ctx context.Context) EmbeddingWorkerStatus {
+	w.mu.Lock()
+	status := EmbeddingWorkerStatus{
+		Running:  w.isRunning,
+		Indexing: w.isBusy,
+		Interval: w.interval.String(),
+		Batch:    w.batch,
+	}
+	w.mu.Unlock()
+	status.Index = w.embeddingService.GetStatus(ctx)
+	return status
+}
+
+// RunNow indexes pending texts in the background
+func (w *EmbeddingWorker) RunNow() {
+	go w.run()
+}
+
+func (w *EmbeddingWorker) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.run()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.run()
+		}
+	}
+}
+
+func (w *EmbeddingWorker) run() {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	if !w.embeddingService.Enabled(ctx) {
+		return
+	}
+
+	var totalNews, totalAnalyses int
+	for i := 0; i < w.maxBatches; i++ {
+		news, analyses, err := w.embeddingService.IndexPending(ctx, w.batch)
+		totalNews += news
+		totalAnalyses += analyses
+		if err != nil {
+			log.Printf("Embedding worker: %v", err)
+			break
+		}
+		if news < w.batch && analyses < w.batch {
+			break
+		}
+	}
+	if totalNews+totalAnalyses > 0 {
+		log.Printf("Embedding worker: embedded %d articles and %d analyses", totalNews, totalAnalyses)
+	}
+
+	// Retrieval documents are embedded one call per document, so one batch per run
+	docs, err := w.embeddingService.IndexRetrievalDocs(ctx, w.batch)
+	if err != nil {
+		log.Printf("Embedding worker: retrieval store: %v", err)
+	}
+	if docs > 0 {
+		log.Printf("Embedding worker: indexed %d documents for retrieval", docs)
+	}
+}
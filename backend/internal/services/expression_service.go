@@ -0,0 +1,209 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"psm-backend/internal/database"
+	"time"
+)
+
+// ExpressionService evaluates and stores custom indicator expressions
+type ExpressionService struct {
+	db        *database.DB
+	taService *TechnicalAnalysisService
+}
+
+func NewExpressionService(db *database.DB, taService *TechnicalAnalysisService) *ExpressionService {
+	return &ExpressionService{db: db, taService: taService}
+}
+
+// SavedExpression is a named, persisted indicator expression
+type SavedExpression struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Expression  string    `json:"expression"`
+	Description string    `json:"description,omitempty"`
+	IsBoolean   bool      `json:"is_boolean"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ExpressionPoint is a single evaluated value of an expression
+type ExpressionPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// ExpressionResult is the evaluated series of an expression for one symbol
+type ExpressionResult struct {
+	Symbol     string            `json:"symbol"`
+	Expression string            `json:"expression"`
+	IsBoolean  bool              `json:"is_boolean"`
+	Latest     *float64          `json:"latest,omitempty"`
+	Values     []ExpressionPoint `json:"values"`
+}
+
+// Minimum number of bars loaded in addition to the expression lookback
+const expressionWarmupBars = 100
+
+// Evaluate evaluates an expression against the stored bars of a symbol
+func (s *ExpressionService) Evaluate(ctx context.Context, symbol, expression string, limit int) (*ExpressionResult, error) {
+	parsed, err := ParseExpression(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	if limit <= 0 {
+		limit = 100
+	}
+
+	bars, err := s.taService.getOHLCVData(ctx, symbol, limit+parsed.Lookback()+expressionWarmupBars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OHLCV data: %w", err)
+	}
+	if len(bars) == 0 {
+		return nil, fmt.Errorf("no data available for %s", symbol)
+	}
+
+	values := parsed.Evaluate(bars)
+
+	result := &ExpressionResult{
+		Symbol:     symbol,
+		Expression: parsed.Source,
+		IsBoolean:  parsed.IsBoolean(),
+		Values:     []ExpressionPoint{},
+	}
+
+	start := len(bars) - limit
+	if start < 0 {
+		start = 0
+	}
+	for i := start; i < len(bars); i++ {
+		if isNaN(values[i]) {
+			continue
+		}
+		result.Values = append(result.Values, ExpressionPoint{
+			Timestamp: bars[i].Timestamp,
+			Value:     values[i],
+		})
+	}
+	result.Latest = lastValue(values)
+
+	return result, nil
+}
+
+// EvaluateByID evaluates a saved expression against the stored bars of a symbol
+func (s *ExpressionService) EvaluateByID(ctx context.Context, symbol, id string, limit int) (*ExpressionResult, error) {
+	saved, err := s.GetExpression(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Evaluate(ctx, symbol, saved.Expression, limit)
+}
+
+// MatchesLatest reports whether a boolean expression holds on the latest bar of a symbol
+func (s *ExpressionService) MatchesLatest(ctx context.Context, symbol string, parsed *ParsedExpression) (bool, error) {
+	bars, err := s.taService.getOHLCVData(ctx, symbol, parsed.Lookback()+expressionWarmupBars)
+	if err != nil {
+		return false, err
+	}
+	value := parsed.EvaluateLast(bars)
+	if isNaN(value) {
+		return false, nil
+	}
+	return value != 0, nil
+}
+
+// CreateExpression validates and stores a named expression
+func (s *ExpressionService) CreateExpression(ctx context.Context, name, expression, description string) (*SavedExpression, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	parsed, err := ParseExpression(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+
+	query := `
+		INSERT INTO indicator_expressions (name, expression, description, is_boolean)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at, updated_at
+	`
+
+	saved := &SavedExpression{
+		Name:        name,
+		Expression:  expression,
+		Description: description,
+		IsBoolean:   parsed.IsBoolean(),
+	}
+	err = s.db.QueryRowContext(ctx, query, name, expression, description, saved.IsBoolean).Scan(
+		&saved.ID, &saved.CreatedAt, &saved.UpdatedAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save expression: %w", err)
+	}
+
+	return saved, nil
+}
+
+// GetExpression returns a saved expression by ID
+func (s *ExpressionService) GetExpression(ctx context.Context, id string) (*SavedExpression, error) {
+	query := `
+		SELECT id, name, expression, COALESCE(description, ''), is_boolean, created_at, updated_at
+		FROM indicator_expressions
+		WHERE id = $1
+	`
+
+	var e SavedExpression
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&e.ID, &e.Name, &e.Expression, &e.Description, &e.IsBoolean, &e.CreatedAt, &e.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("expression not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &e, nil
+}
+
+// ListExpressions returns all saved expressions
+func (s *ExpressionService) ListExpressions(ctx context.Context) ([]SavedExpression, error) {
+	query := `
+		SELECT id, name, expression, COALESCE(description, ''), is_boolean, created_at, updated_at
+		FROM indicator_expressions
+		ORDER BY name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expressions := []SavedExpression{}
+	for rows.Next() {
+		var e SavedExpression
+		if err := rows.Scan(
+			&e.ID, &e.Name, &e.Expression, &e.Description, &e.IsBoolean, &e.CreatedAt, &e.UpdatedAt,
+		); err != nil {
+			continue
+		}
+		expressions = append(expressions, e)
+	}
+
+	return expressions, nil
+}
+
+// DeleteExpression removes a saved expression
+func (s *ExpressionService) DeleteExpression(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM indicator_expressions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("expression not found: %s", id)
+	}
+	return nil
+}
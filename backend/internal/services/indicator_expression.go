@@ -0,0 +1,788 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/markcheno/go-talib"
+)
+
+// Indicator expression DSL
+//
+// Expressions are evaluated bar-by-bar against OHLCV history, e.g.:
+//   close > MA(20) AND RSI(14) < 40
+//   (close - BB_LOWER(20, 2)) / close < 0.01
+//   MA(5) > MA(20) AND volume > 2 * VOL_MA(20)
+//
+// Fields:     open, high, low, close, volume
+// Functions:  MA/SMA(n), EMA(n), RSI(n), MACD(), MACD_SIGNAL(), MACD_HIST(),
+//             BB_UPPER(n, k), BB_MIDDLE(n, k), BB_LOWER(n, k),
+//             KDJ_K(n), KDJ_D(n), KDJ_J(n), VOL_MA(n), HIGHEST(n), LOWEST(n), REF(field, n)
+// Operators:  + - * /  > >= < <= == !=  AND OR NOT (also && || !)
+//
+// Boolean results are represented as 1 (true) / 0 (false).
+
+// ParsedExpression is a compiled indicator expression
+type ParsedExpression struct {
+	Source   string
+	root     exprNode
+	lookback int
+}
+
+// IsBoolean reports whether the expression yields a condition rather than a value
+func (p *ParsedExpression) IsBoolean() bool {
+	return p.root.isBoolean()
+}
+
+// Lookback returns the number of bars the expression needs to produce a value
+func (p *ParsedExpression) Lookback() int {
+	return p.lookback
+}
+
+// Evaluate evaluates the expression for every bar; NaN marks bars without a value
+func (p *ParsedExpression) Evaluate(bars []OHLCV) []float64 {
+	env := newExprEnv(bars)
+	values := make([]float64, len(bars))
+	for i := range bars {
+		values[i] = p.root.eval(i, env)
+	}
+	return values
+}
+
+// EvaluateLast evaluates the expression on the most recent bar only
+func (p *ParsedExpression) EvaluateLast(bars []OHLCV) float64 {
+	if len(bars) == 0 {
+		return math.NaN()
+	}
+	env := newExprEnv(bars)
+	return p.root.eval(len(bars)-1, env)
+}
+
+// ParseExpression compiles an expression string
+func ParseExpression(source string) (*ParsedExpression, error) {
+	tokens, err := tokenizeExpression(source)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("expression is empty")
+	}
+
+	p := &exprParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected token %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+
+	return &ParsedExpression{
+		Source:   source,
+		root:     root,
+		lookback: root.lookback(),
+	}, nil
+}
+
+// ---------------------------------------------------------------------------
+// Tokenizer
+// ---------------------------------------------------------------------------
+
+type exprTokenKind int
+
+const (
+	tokNumber exprTokenKind = iota
+	tokIdent
+	tokOperator
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type exprToken struct {
+	kind   exprTokenKind
+	text   string
+	offset int
+}
+
+func tokenizeExpression(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(source)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: string(runes[start:i]), offset: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: string(runes[start:i]), offset: start})
+		case r == '(':
+			tokens = append(tokens, exprToken{kind: tokLParen, text: "(", offset: i})
+			i++
+		case r == ')':
+			tokens = append(tokens, exprToken{kind: tokRParen, text: ")", offset: i})
+			i++
+		case r == ',':
+			tokens = append(tokens, exprToken{kind: tokComma, text: ",", offset: i})
+			i++
+		default:
+			// Two-character operators first
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				switch two {
+				case ">=", "<=", "==", "!=", "&&", "||":
+					tokens = append(tokens, exprToken{kind: tokOperator, text: two, offset: i})
+					i += 2
+					continue
+				}
+			}
+			switch r {
+			case '+', '-', '*', '/', '>', '<', '!':
+				tokens = append(tokens, exprToken{kind: tokOperator, text: string(r), offset: i})
+				i++
+			case '=':
+				// Allow a single '=' as equality for convenience
+				tokens = append(tokens, exprToken{kind: tokOperator, text: "==", offset: i})
+				i++
+			default:
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+		}
+	}
+
+	return tokens, nil
+}
+
+// ---------------------------------------------------------------------------
+// Parser
+// ---------------------------------------------------------------------------
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() *exprToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *exprParser) isKeyword(tok *exprToken, keywords ...string) bool {
+	if tok == nil {
+		return false
+	}
+	for _, kw := range keywords {
+		if tok.kind == tokOperator && tok.text == kw {
+			return true
+		}
+		if tok.kind == tokIdent && strings.EqualFold(tok.text, kw) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(p.peek(), "OR", "||") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "OR", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.isKeyword(p.peek(), "AND", "&&") {
+		p.pos++
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{op: "AND", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseNot() (exprNode, error) {
+	if p.isKeyword(p.peek(), "NOT", "!") {
+		p.pos++
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *exprParser) parseComparison() (exprNode, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	tok := p.peek()
+	if tok != nil && tok.kind == tokOperator {
+		switch tok.text {
+		case ">", ">=", "<", "<=", "==", "!=":
+			p.pos++
+			right, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			return &compareNode{op: tok.text, left: left, right: right}, nil
+		}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseAdditive() (exprNode, error) {
+	left, err := p.parseMultiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind != tokOperator || (tok.text != "+" && tok.text != "-") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseMultiplicative()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseMultiplicative() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind != tokOperator || (tok.text != "*" && tok.text != "/") {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &arithNode{op: tok.text, left: left, right: right}
+	}
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	tok := p.peek()
+	if tok != nil && tok.kind == tokOperator && tok.text == "-" {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &arithNode{op: "-", left: &numberNode{value: 0}, right: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	switch tok.kind {
+	case tokNumber:
+		p.pos++
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return &numberNode{value: value}, nil
+
+	case tokLParen:
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next := p.peek(); next == nil || next.kind != tokRParen {
+			return nil, fmt.Errorf("missing closing parenthesis for position %d", tok.offset)
+		}
+		p.pos++
+		return inner, nil
+
+	case tokIdent:
+		p.pos++
+		name := strings.ToUpper(tok.text)
+
+		// Function call
+		if next := p.peek(); next != nil && next.kind == tokLParen {
+			p.pos++
+			args, err := p.parseArgs()
+			if err != nil {
+				return nil, err
+			}
+			return newFuncNode(name, args)
+		}
+
+		// Field reference
+		field := strings.ToLower(tok.text)
+		switch field {
+		case "open", "high", "low", "close", "volume":
+			return &fieldNode{field: field}, nil
+		}
+		return nil, fmt.Errorf("unknown field %q", tok.text)
+	}
+
+	return nil, fmt.Errorf("unexpected token %q at position %d", tok.text, tok.offset)
+}
+
+// parseArgs parses function arguments; each argument is a number or a field name
+func (p *exprParser) parseArgs() ([]string, error) {
+	var args []string
+	if next := p.peek(); next != nil && next.kind == tokRParen {
+		p.pos++
+		return args, nil
+	}
+
+	for {
+		negative := false
+		tok := p.peek()
+		if tok != nil && tok.kind == tokOperator && tok.text == "-" {
+			negative = true
+			p.pos++
+			tok = p.peek()
+		}
+		if tok == nil || (tok.kind != tokNumber && tok.kind != tokIdent) {
+			return nil, fmt.Errorf("function arguments must be numbers or field names")
+		}
+		arg := tok.text
+		if negative {
+			arg = "-" + arg
+		}
+		args = append(args, arg)
+		p.pos++
+
+		next := p.peek()
+		if next == nil {
+			return nil, fmt.Errorf("missing closing parenthesis in function call")
+		}
+		if next.kind == tokRParen {
+			p.pos++
+			return args, nil
+		}
+		if next.kind != tokComma {
+			return nil, fmt.Errorf("unexpected token %q in function arguments", next.text)
+		}
+		p.pos++
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Evaluation
+// ---------------------------------------------------------------------------
+
+type exprEnv struct {
+	bars   []OHLCV
+	fields map[string][]float64
+	series map[string][]float64
+}
+
+func newExprEnv(bars []OHLCV) *exprEnv {
+	n := len(bars)
+	open := make([]float64, n)
+	high := make([]float64, n)
+	low := make([]float64, n)
+	closes := make([]float64, n)
+	volume := make([]float64, n)
+	for i, b := range bars {
+		open[i], _ = b.Open.Float64()
+		high[i], _ = b.High.Float64()
+		low[i], _ = b.Low.Float64()
+		closes[i], _ = b.Close.Float64()
+		volume[i] = float64(b.Volume)
+	}
+
+	return &exprEnv{
+		bars: bars,
+		fields: map[string][]float64{
+			"open":   open,
+			"high":   high,
+			"low":    low,
+			"close":  closes,
+			"volume": volume,
+		},
+		series: make(map[string][]float64),
+	}
+}
+
+type exprNode interface {
+	eval(i int, env *exprEnv) float64
+	isBoolean() bool
+	lookback() int
+}
+
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) eval(int, *exprEnv) float64 { return n.value }
+func (n *numberNode) isBoolean() bool            { return false }
+func (n *numberNode) lookback() int              { return 0 }
+
+type fieldNode struct {
+	field string
+}
+
+func (n *fieldNode) eval(i int, env *exprEnv) float64 { return env.fields[n.field][i] }
+func (n *fieldNode) isBoolean() bool                  { return false }
+func (n *fieldNode) lookback() int                    { return 1 }
+
+type arithNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *arithNode) eval(i int, env *exprEnv) float64 {
+	l, r := n.left.eval(i, env), n.right.eval(i, env)
+	switch n.op {
+	case "+":
+		return l + r
+	case "-":
+		return l - r
+	case "*":
+		return l * r
+	case "/":
+		if r == 0 {
+			return math.NaN()
+		}
+		return l / r
+	}
+	return math.NaN()
+}
+func (n *arithNode) isBoolean() bool { return false }
+func (n *arithNode) lookback() int   { return maxInt(n.left.lookback(), n.right.lookback()) }
+
+type compareNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *compareNode) eval(i int, env *exprEnv) float64 {
+	l, r := n.left.eval(i, env), n.right.eval(i, env)
+	if isNaN(l) || isNaN(r) {
+		return math.NaN()
+	}
+	var result bool
+	switch n.op {
+	case ">":
+		result = l > r
+	case ">=":
+		result = l >= r
+	case "<":
+		result = l < r
+	case "<=":
+		result = l <= r
+	case "==":
+		result = l == r
+	case "!=":
+		result = l != r
+	}
+	return boolToFloat(result)
+}
+func (n *compareNode) isBoolean() bool { return true }
+func (n *compareNode) lookback() int   { return maxInt(n.left.lookback(), n.right.lookback()) }
+
+type logicalNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *logicalNode) eval(i int, env *exprEnv) float64 {
+	l, r := n.left.eval(i, env), n.right.eval(i, env)
+	if isNaN(l) || isNaN(r) {
+		return math.NaN()
+	}
+	if n.op == "AND" {
+		return boolToFloat(l != 0 && r != 0)
+	}
+	return boolToFloat(l != 0 || r != 0)
+}
+func (n *logicalNode) isBoolean() bool { return true }
+func (n *logicalNode) lookback() int   { return maxInt(n.left.lookback(), n.right.lookback()) }
+
+type notNode struct {
+	operand exprNode
+}
+
+func (n *notNode) eval(i int, env *exprEnv) float64 {
+	v := n.operand.eval(i, env)
+	if isNaN(v) {
+		return math.NaN()
+	}
+	return boolToFloat(v == 0)
+}
+func (n *notNode) isBoolean() bool { return true }
+func (n *notNode) lookback() int   { return n.operand.lookback() }
+
+// funcNode evaluates an indicator function; the full series is computed once per environment
+type funcNode struct {
+	name     string
+	key      string
+	field    string
+	params   []float64
+	required int
+}
+
+// exprFunctions maps function names to their expected numeric parameter counts and defaults
+var exprFunctions = map[string][]float64{
+	"MA":          {20},
+	"SMA":         {20},
+	"EMA":         {20},
+	"RSI":         {14},
+	"MACD":        {12, 26, 9},
+	"MACD_SIGNAL": {12, 26, 9},
+	"MACD_HIST":   {12, 26, 9},
+	"BB_UPPER":    {20, 2},
+	"BB_MIDDLE":   {20, 2},
+	"BB_LOWER":    {20, 2},
+	"KDJ_K":       {9},
+	"KDJ_D":       {9},
+	"KDJ_J":       {9},
+	"VOL_MA":      {20},
+	"HIGHEST":     {20},
+	"LOWEST":      {20},
+	"REF":         {1},
+}
+
+func newFuncNode(name string, args []string) (exprNode, error) {
+	defaults, ok := exprFunctions[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s", name)
+	}
+
+	node := &funcNode{name: name, field: "close"}
+
+	// REF(field, n) takes a field name as first argument
+	if name == "REF" {
+		if len(args) == 0 || len(args) > 2 {
+			return nil, fmt.Errorf("REF expects (field, n)")
+		}
+		field := strings.ToLower(args[0])
+		switch field {
+		case "open", "high", "low", "close", "volume":
+			node.field = field
+		default:
+			return nil, fmt.Errorf("REF: unknown field %q", args[0])
+		}
+		args = args[1:]
+	}
+
+	if len(args) > len(defaults) {
+		return nil, fmt.Errorf("%s expects at most %d arguments, got %d", name, len(defaults), len(args))
+	}
+
+	params := append([]float64{}, defaults...)
+	for i, arg := range args {
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: argument %d must be a number", name, i+1)
+		}
+		params[i] = v
+	}
+
+	if err := validateFuncParams(name, params); err != nil {
+		return nil, err
+	}
+	period := int(params[0])
+
+	node.params = params
+	node.key = fmt.Sprintf("%s:%s:%v", name, node.field, params)
+
+	switch name {
+	case "MACD", "MACD_SIGNAL", "MACD_HIST":
+		node.required = int(params[1]) + int(params[2])
+	case "RSI":
+		node.required = period + 1
+	case "KDJ_K", "KDJ_D", "KDJ_J":
+		node.required = period + 6
+	default:
+		node.required = period
+	}
+	if name == "REF" {
+		node.required = period + 1
+	}
+
+	return node, nil
+}
+
+// validateFuncParams checks every parameter against the range talib accepts,
+// so a bad expression fails to parse instead of panicking during evaluation
+func validateFuncParams(name string, params []float64) error {
+	checkPeriod := func(label string, v float64, min int) error {
+		if v != math.Trunc(v) || v < float64(min) || v > 500 {
+			return fmt.Errorf("%s: %s must be a whole number between %d and 500", name, label, min)
+		}
+		return nil
+	}
+
+	switch name {
+	case "MACD", "MACD_SIGNAL", "MACD_HIST":
+		for i, label := range []string{"fast period", "slow period", "signal period"} {
+			if err := checkPeriod(label, params[i], 2); err != nil {
+				return err
+			}
+		}
+		if params[0] >= params[1] {
+			return fmt.Errorf("%s: fast period must be less than slow period", name)
+		}
+	case "BB_UPPER", "BB_MIDDLE", "BB_LOWER":
+		if err := checkPeriod("period", params[0], 2); err != nil {
+			return err
+		}
+		if math.IsNaN(params[1]) || params[1] <= 0 || params[1] > 10 {
+			return fmt.Errorf("%s: band width must be greater than 0 and at most 10", name)
+		}
+	case "RSI", "HIGHEST", "LOWEST":
+		// talib returns all zeros for a period below 2
+		return checkPeriod("period", params[0], 2)
+	case "REF":
+		return checkPeriod("offset", params[0], 0)
+	default:
+		return checkPeriod("period", params[0], 1)
+	}
+	return nil
+}
+
+func (n *funcNode) isBoolean() bool { return false }
+func (n *funcNode) lookback() int   { return n.required }
+
+func (n *funcNode) eval(i int, env *exprEnv) float64 {
+	series, ok := env.series[n.key]
+	if !ok {
+		series = n.compute(env)
+		env.series[n.key] = series
+	}
+	// talib pads the lookback window with zeros; treat those as missing
+	if i < n.required-1 || i >= len(series) {
+		return math.NaN()
+	}
+	return series[i]
+}
+
+func (n *funcNode) compute(env *exprEnv) []float64 {
+	bars := len(env.bars)
+	empty := make([]float64, bars)
+	for i := range empty {
+		empty[i] = math.NaN()
+	}
+	if bars < n.required {
+		return empty
+	}
+
+	closes := env.fields["close"]
+	period := int(n.params[0])
+
+	switch n.name {
+	case "MA", "SMA":
+		return talib.Sma(closes, period)
+	case "EMA":
+		return talib.Ema(closes, period)
+	case "RSI":
+		return talib.Rsi(closes, period)
+	case "MACD", "MACD_SIGNAL", "MACD_HIST":
+		macd, signal, hist := talib.Macd(closes, int(n.params[0]), int(n.params[1]), int(n.params[2]))
+		switch n.name {
+		case "MACD":
+			return macd
+		case "MACD_SIGNAL":
+			return signal
+		}
+		return hist
+	case "BB_UPPER", "BB_MIDDLE", "BB_LOWER":
+		upper, middle, lower := talib.BBands(closes, period, n.params[1], n.params[1], talib.SMA)
+		switch n.name {
+		case "BB_UPPER":
+			return upper
+		case "BB_MIDDLE":
+			return middle
+		}
+		return lower
+	case "KDJ_K", "KDJ_D", "KDJ_J":
+		k, d := talib.Stoch(env.fields["high"], env.fields["low"], closes, period, 3, talib.SMA, 3, talib.SMA)
+		switch n.name {
+		case "KDJ_K":
+			return k
+		case "KDJ_D":
+			return d
+		}
+		j := make([]float64, len(k))
+		for i := range k {
+			j[i] = 3*k[i] - 2*d[i]
+		}
+		return j
+	case "VOL_MA":
+		return talib.Sma(env.fields["volume"], period)
+	case "HIGHEST":
+		return talib.Max(env.fields["high"], period)
+	case "LOWEST":
+		return talib.Min(env.fields["low"], period)
+	case "REF":
+		source := env.fields[n.field]
+		out := make([]float64, bars)
+		for i := range out {
+			if i-period >= 0 {
+				out[i] = source[i-period]
+			} else {
+				out[i] = math.NaN()
+			}
+		}
+		return out
+	}
+
+	return empty
+}
+
+// Helper functions
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,99 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// testBars builds n daily bars with a zigzag close so every indicator has
+// both gains and losses to work with
+func testBars(n int) []OHLCV {
+	bars := make([]OHLCV, n)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := range bars {
+		c := 100 + 10*math.Sin(float64(i)/3) + float64(i%4)
+		bars[i] = OHLCV{
+			Symbol:    "2330",
+			Timestamp: start.AddDate(0, 0, i),
+			Open:      decimal.NewFromFloat(c - 0.5),
+			High:      decimal.NewFromFloat(c + 1),
+			Low:       decimal.NewFromFloat(c - 1),
+			Close:     decimal.NewFromFloat(c),
+			Volume:    int64(1000 + 100*(i%7)),
+		}
+	}
+	return bars
+}
+
+func TestExpressionFunctionBoundaryParams(t *testing.T) {
+	valid := []string{
+		"MA(1)", "MA(500)", "SMA(1)", "EMA(1)", "EMA(500)",
+		"RSI(2)", "RSI(500)",
+		"MACD(2, 3, 2)", "MACD(499, 500, 500)", "MACD_SIGNAL(2, 3, 2)", "MACD_HIST(2, 3, 2)",
+		"BB_UPPER(2, 0.1)", "BB_MIDDLE(500, 10)", "BB_LOWER(2, 10)",
+		"KDJ_K(1)", "KDJ_D(1)", "KDJ_J(500)",
+		"VOL_MA(1)", "HIGHEST(2)", "LOWEST(500)",
+		"REF(close, 0)", "REF(volume, 500)",
+	}
+	barCounts := []int{0, 1, 2, 3, 10, 60, 600}
+
+	for _, source := range valid {
+		expr, err := ParseExpression(source)
+		if err != nil {
+			t.Errorf("ParseExpression(%q) failed: %v", source, err)
+			continue
+		}
+		for _, n := range barCounts {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%s over %d bars panicked: %v", source, n, r)
+					}
+				}()
+				values := expr.Evaluate(testBars(n))
+				if len(values) != n {
+					t.Errorf("%s over %d bars returned %d values", source, n, len(values))
+				}
+				expr.EvaluateLast(testBars(n))
+			}()
+		}
+	}
+}
+
+func TestExpressionFunctionInvalidParams(t *testing.T) {
+	invalid := []string{
+		"MA(0)", "MA(501)", "MA(2.5)", "EMA(-1)",
+		"RSI(1)", "HIGHEST(1)", "LOWEST(0)",
+		"MACD(12, 26, 0)", "MACD(12, -5, 9)", "MACD_HIST(1, 1, 1)", "MACD(26, 12, 9)",
+		"MACD(12, 12, 9)", "MACD_SIGNAL(12, 26, 501)", "MACD(12.5, 26, 9)",
+		"BB_UPPER(1, 2)", "BB_LOWER(20, 0)", "BB_MIDDLE(20, -2)", "BB_UPPER(20, 11)",
+		"KDJ_K(0)", "VOL_MA(0)", "REF(close, -1)", "REF(close, 501)",
+	}
+	for _, source := range invalid {
+		if _, err := ParseExpression(source); err == nil {
+			t.Errorf("ParseExpression(%q) succeeded, want a parameter error", source)
+		}
+	}
+}
+
+func TestExpressionFunctionsDefaultParams(t *testing.T) {
+	for name := range exprFunctions {
+		source := fmt.Sprintf("%s()", name)
+		if name == "REF" {
+			source = "REF(close)"
+		}
+		expr, err := ParseExpression(source)
+		if err != nil {
+			t.Errorf("ParseExpression(%q) failed: %v", source, err)
+			continue
+		}
+		values := expr.Evaluate(testBars(120))
+		if last := values[len(values)-1]; math.IsNaN(last) {
+			t.Errorf("%s with defaults has no value after 120 bars", source)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"psm-backend/internal/database"
+)
+
+// IndicatorPrecomputeWorker warms the indicator cache and snapshots for all active
+// symbols once per trading day, after the daily market data sync has landed.
+type IndicatorPrecomputeWorker struct {
+	db            *database.DB
+	taService     *TechnicalAnalysisService
+	rateInterval  time.Duration // Minimum delay between symbols
+	runAfterHour  int           // Taipei local hour after which the nightly run may start
+	checkInterval time.Duration
+	mu            sync.Mutex
+	isRunning     bool // Scheduler loop started
+	isBusy        bool // A precompute run is in progress
+	stopChan      chan struct{}
+}
+
+// PrecomputeRun represents the progress of one precompute run
+type PrecomputeRun struct {
+	RunDate      time.Time  `json:"run_date"`
+	Status       string     `json:"status"` // running, completed, interrupted
+	TotalSymbols int        `json:"total_symbols"`
+	Processed    int        `json:"processed"`
+	Failed       int        `json:"failed"`
+	LastSymbol   string     `json:"last_symbol,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+func NewIndicatorPrecomputeWorker(db *database.DB, taService *TechnicalAnalysisService) *IndicatorPrecomputeWorker {
+	// INDICATOR_PRECOMPUTE_RATE: symbols per second (default 5)
+	rate := 5
+	if v, err := strconv.Atoi(os.Getenv("INDICATOR_PRECOMPUTE_RATE")); err == nil && v > 0 {
+		rate = v
+	}
+	// INDICATOR_PRECOMPUTE_HOUR: earliest Taipei hour for the nightly run (default 18)
+	hour := 18
+	if v, err := strconv.Atoi(os.Getenv("INDICATOR_PRECOMPUTE_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+
+	return &IndicatorPrecomputeWorker{
+		db:            db,
+		taService:     taService,
+		rateInterval:  time.Second / time.Duration(rate),
+		runAfterHour:  hour,
+		checkInterval: 10 * time.Minute,
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// Start launches the scheduler loop
+func (w *IndicatorPrecomputeWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Indicator precompute worker started (after %02d:00, %v between symbols)", w.runAfterHour, w.rateInterval)
+}
+
+// Stop stops the scheduler loop and interrupts any run in progress
+func (w *IndicatorPrecomputeWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+// RunNow starts a precompute run for the latest synced trading day in the background
+func (w *IndicatorPrecomputeWorker) RunNow() error {
+	runDate, err := w.latestTradingDate(context.Background())
+	if err != nil {
+		return err
+	}
+	if !w.tryAcquire() {
+		return fmt.Errorf("precompute is already running")
+	}
+
+	go func() {
+		defer w.release()
+		w.run(runDate)
+	}()
+	return nil
+}
+
+// GetStatus returns the most recent precompute run
+func (w *IndicatorPrecomputeWorker) GetStatus(ctx context.Context) (*PrecomputeRun, error) {
+	query := `
+		SELECT run_date, status, total_symbols, processed, failed, COALESCE(last_symbol, ''),
+		       started_at, updated_at, completed_at
+		FROM indicator_precompute_runs
+		ORDER BY run_date DESC
+		LIMIT 1
+	`
+
+	var run PrecomputeRun
+	err := w.db.QueryRowContext(ctx, query).Scan(
+		&run.RunDate, &run.Status, &run.TotalSymbols, &run.Processed, &run.Failed, &run.LastSymbol,
+		&run.StartedAt, &run.UpdatedAt, &run.CompletedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &run, nil
+}
+
+func (w *IndicatorPrecomputeWorker) loop() {
+	ticker := time.NewTicker(w.checkInterval)
+	defer ticker.Stop()
+
+	w.checkAndRun()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun starts a run when it is past the nightly hour and the latest trading day
+// has not been fully precomputed yet (this also resumes interrupted runs)
+func (w *IndicatorPrecomputeWorker) checkAndRun() {
+	loc, _ := time.LoadLocation("Asia/Taipei")
+	if loc == nil {
+		loc = time.FixedZone("CST", 8*3600)
+	}
+	if time.Now().In(loc).Hour() < w.runAfterHour {
+		return
+	}
+
+	ctx := context.Background()
+	runDate, err := w.latestTradingDate(ctx)
+	if err != nil {
+		return
+	}
+
+	var status string
+	err = w.db.QueryRowContext(ctx,
+		`SELECT status FROM indicator_precompute_runs WHERE run_date = $1`, runDate,
+	).Scan(&status)
+	if err == nil && status == "completed" {
+		return
+	}
+
+	if !w.tryAcquire() {
+		return
+	}
+	defer w.release()
+	w.run(runDate)
+}
+
+func (w *IndicatorPrecomputeWorker) run(runDate time.Time) {
+	ctx := context.Background()
+
+	// Create the run or pick up where an earlier attempt left off
+	var run PrecomputeRun
+	err := w.db.QueryRowContext(ctx, `
+		INSERT INTO indicator_precompute_runs (run_date, status, started_at, updated_at)
+		VALUES ($1, 'running', NOW(), NOW())
+		ON CONFLICT (run_date) DO UPDATE SET
+			-- A completed day is recomputed from scratch; anything else resumes
+			processed = CASE WHEN indicator_precompute_runs.status = 'completed' THEN 0 ELSE indicator_precompute_runs.processed END,
+			failed = CASE WHEN indicator_precompute_runs.status = 'completed' THEN 0 ELSE indicator_precompute_runs.failed END,
+			last_symbol = CASE WHEN indicator_precompute_runs.status = 'completed' THEN NULL ELSE indicator_precompute_runs.last_symbol END,
+			completed_at = NULL,
+			status = 'running',
+			updated_at = NOW()
+		RETURNING processed, failed, COALESCE(last_symbol, '')
+	`, runDate).Scan(&run.Processed, &run.Failed, &run.LastSymbol)
+	if err != nil {
+		log.Printf("Indicator precompute: failed to start run: %v", err)
+		return
+	}
+
+	var total int
+	if err := w.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM taiwan_stocks WHERE is_active = true`,
+	).Scan(&total); err != nil {
+		log.Printf("Indicator precompute: failed to count symbols: %v", err)
+		return
+	}
+
+	rows, err := w.db.QueryContext(ctx, `
+		SELECT symbol FROM taiwan_stocks
+		WHERE is_active = true AND symbol > $1
+		ORDER BY symbol
+	`, run.LastSymbol)
+	if err != nil {
+		log.Printf("Indicator precompute: failed to list symbols: %v", err)
+		return
+	}
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+	rows.Close()
+
+	if run.LastSymbol != "" {
+		log.Printf("Indicator precompute: resuming %s after %s (%d remaining)", runDate.Format("2006-01-02"), run.LastSymbol, len(symbols))
+	} else {
+		log.Printf("Indicator precompute: starting %s (%d symbols)", runDate.Format("2006-01-02"), len(symbols))
+	}
+
+	w.mu.Lock()
+	stop := w.stopChan
+	w.mu.Unlock()
+
+	limiter := time.NewTicker(w.rateInterval)
+	defer limiter.Stop()
+
+	for i, symbol := range symbols {
+		select {
+		case <-stop:
+			w.saveProgress(ctx, runDate, "interrupted", total, run.Processed, run.Failed, run.LastSymbol)
+			log.Printf("Indicator precompute: interrupted at %s", run.LastSymbol)
+			return
+		case <-limiter.C:
+		}
+
+		if err := w.precomputeSymbol(ctx, symbol); err != nil {
+			run.Failed++
+		}
+		run.Processed++
+		run.LastSymbol = symbol
+
+		// Persist the cursor periodically so a restart resumes close to here
+		if (i+1)%20 == 0 {
+			w.saveProgress(ctx, runDate, "running", total, run.Processed, run.Failed, run.LastSymbol)
+		}
+	}
+
+	w.saveProgress(ctx, runDate, "completed", total, run.Processed, run.Failed, run.LastSymbol)
+	log.Printf("Indicator precompute: completed %s (%d processed, %d failed)", runDate.Format("2006-01-02"), run.Processed, run.Failed)
+}
+
+// precomputeSymbol warms the Redis indicator cache and stores the daily snapshot
+func (w *IndicatorPrecomputeWorker) precomputeSymbol(ctx context.Context, symbol string) error {
+	if err := w.taService.WarmIndicatorCache(ctx, symbol); err != nil {
+		return err
+	}
+	snap, err := w.taService.ComputeSnapshot(ctx, symbol)
+	if err != nil {
+		return err
+	}
+	return w.taService.SaveSnapshot(ctx, snap)
+}
+
+func (w *IndicatorPrecomputeWorker) saveProgress(ctx context.Context, runDate time.Time, status string, total, processed, failed int, lastSymbol string) {
+	query := `
+		UPDATE indicator_precompute_runs
+		SET status = $2, total_symbols = $3, processed = $4, failed = $5, last_symbol = NULLIF($6, ''),
+		    updated_at = NOW(),
+		    completed_at = CASE WHEN $2 = 'completed' THEN NOW() ELSE completed_at END
+		WHERE run_date = $1
+	`
+	if _, err := w.db.ExecContext(ctx, query, runDate, status, total, processed, failed, lastSymbol); err != nil {
+		log.Printf("Indicator precompute: failed to save progress: %v", err)
+	}
+}
+
+// latestTradingDate returns the date of the most recent stock bar in stock_ohlcv
+func (w *IndicatorPrecomputeWorker) latestTradingDate(ctx context.Context) (time.Time, error) {
+	var latest sql.NullTime
+	if err := w.db.QueryRowContext(ctx, `SELECT MAX(timestamp)::date FROM stock_ohlcv WHERE `+notIndexSymbolSQL).Scan(&latest); err != nil {
+		return time.Time{}, err
+	}
+	if !latest.Valid {
+		return time.Time{}, fmt.Errorf("no market data available")
+	}
+	return latest.Time, nil
+}
+
+func (w *IndicatorPrecomputeWorker) tryAcquire() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.isBusy {
+		return false
+	}
+	w.isBusy = true
+	return true
+}
+
+func (w *IndicatorPrecomputeWorker) release() {
+	w.mu.Lock()
+	w.isBusy = false
+	w.mu.Unlock()
+}
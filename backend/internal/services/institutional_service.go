@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"psm-backend/internal/database"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InstitutionalService stores the daily net buying of the three major
+// institutional investors (三大法人) published after each trading day
+type InstitutionalService struct {
+	db *database.DB
+}
+
+// InstitutionalTrade is one symbol's institutional net buying for a day, in shares
+type InstitutionalTrade struct {
+	Symbol     string    `json:"symbol"`
+	TradeDate  string    `json:"trade_date"` // YYYY-MM-DD
+	ForeignNet int64     `json:"foreign_net"`
+	TrustNet   int64     `json:"trust_net"`
+	DealerNet  int64     `json:"dealer_net"`
+	TotalNet   int64     `json:"total_net"`
+	Market     string    `json:"market"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// InstitutionalFetchResult reports one fetch of a market's daily report
+type InstitutionalFetchResult struct {
+	Market  string `json:"market"`
+	Date    string `json:"date"`
+	Records int    `json:"records"`
+	Stored  int    `json:"stored"`
+	Error   string `json:"error,omitempty"`
+}
+
+// institutionalReport covers both the TWSE T86 report, which has its table at
+// the top level, and the TPEx report, which nests it under tables
+type institutionalReport struct {
+	Stat   string         `json:"stat"`
+	Fields []string       `json:"fields"`
+	Data   [][]string     `json:"data"`
+	Tables []MIIndexTable `json:"tables"`
+}
+
+func NewInstitutionalService(db *database.DB) *InstitutionalService {
+	return &InstitutionalService{db: db}
+}
+
+// FetchInstitutionalTrades downloads the TWSE and TPEx institutional trading
+// reports for a date and upserts them. Non-trading days store nothing.
+func (s *InstitutionalService) FetchInstitutionalTrades(ctx context.Context, date time.Time) ([]InstitutionalFetchResult, error) {
+	feeds := []struct {
+		market string
+		url    string
+	}{
+		{"TSE", "https://www.twse.com.tw/rwd/zh/fund/T86?response=json&selectType=ALLBUT0999&date=" + date.Format("20060102")},
+		{"OTC", "https://www.tpex.org.tw/www/zh-tw/insti/dailyTrade?type=Daily&sect=EW&response=json&date=" + url.QueryEscape(date.Format("2006/01/02"))},
+	}
+
+	results := make([]InstitutionalFetchResult, 0, len(feeds))
+	failed := 0
+	for _, feed := range feeds {
+		result := InstitutionalFetchResult{Market: feed.market, Date: date.Format("2006-01-02")}
+		body, err := fetchNewsBody(ctx, feed.url, map[string]string{"Accept": "application/json"})
+		if err == nil {
+			var report institutionalReport
+			if err = json.Unmarshal(body, &report); err != nil {
+				err = fmt.Errorf("failed to parse response: %w", err)
+			} else {
+				trades := parseInstitutionalReport(&report, feed.market, result.Date)
+				result.Records = len(trades)
+				result.Stored, err = s.storeTrades(ctx, trades)
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+	if failed == len(feeds) {
+		return results, fmt.Errorf("failed to fetch institutional trades: %s; %s", results[0].Error, results[1].Error)
+	}
+	return results, nil
+}
+
+// parseInstitutionalReport finds the net buying columns by name, since the
+// two exchanges order and label them differently
+func parseInstitutionalReport(report *institutionalReport, market, tradeDate string) []InstitutionalTrade {
+	if !strings.EqualFold(report.Stat, "OK") {
+		return nil // Non-trading day
+	}
+	tables := append([]MIIndexTable{{Fields: report.Fields, Data: report.Data}}, report.Tables...)
+
+	var trades []InstitutionalTrade
+	for _, table := range tables {
+		symbolCol, foreignCol, trustCol, dealerCol, totalCol := -1, -1, -1, -1, -1
+		for i, f := range table.Fields {
+			f = strings.ReplaceAll(f, " ", "")
+			net := strings.Contains(f, "買賣超")
+			switch {
+			case symbolCol < 0 && strings.Contains(f, "代號"):
+				symbolCol = i
+			case foreignCol < 0 && net && strings.Contains(f, "不含外資自營商"):
+				foreignCol = i
+			case trustCol < 0 && net && strings.Contains(f, "投信"):
+				trustCol = i
+			case dealerCol < 0 && net && strings.Contains(f, "自營商") && !strings.Contains(f, "外資") && !strings.Contains(f, "("):
+				dealerCol = i
+			case totalCol < 0 && net && strings.Contains(f, "三大法人"):
+				totalCol = i
+			}
+		}
+		if symbolCol < 0 || foreignCol < 0 || trustCol < 0 || dealerCol < 0 || totalCol < 0 {
+			continue
+		}
+
+		for _, row := range table.Data {
+			if len(row) <= symbolCol || len(row) <= foreignCol || len(row) <= trustCol || len(row) <= dealerCol || len(row) <= totalCol {
+				continue
+			}
+			symbol := strings.TrimSpace(row[symbolCol])
+			if symbol == "" {
+				continue
+			}
+			trades = append(trades, InstitutionalTrade{
+				Symbol:     symbol,
+				TradeDate:  tradeDate,
+				ForeignNet: parseInstitutionalShares(row[foreignCol]),
+				TrustNet:   parseInstitutionalShares(row[trustCol]),
+				DealerNet:  parseInstitutionalShares(row[dealerCol]),
+				TotalNet:   parseInstitutionalShares(row[totalCol]),
+				Market:     market,
+			})
+		}
+	}
+	return trades
+}
+
+func parseInstitutionalShares(s string) int64 {
+	v, _ := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 10, 64)
+	return v
+}
+
+// storeTrades upserts a day's trades in one transaction and returns how many were saved
+func (s *InstitutionalService) storeTrades(ctx context.Context, trades []InstitutionalTrade) (int, error) {
+	if len(trades) == 0 {
+		return 0, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO stock_institutional_trades (symbol, trade_date, foreign_net, trust_net, dealer_net, total_net, market)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (symbol, trade_date) DO UPDATE SET
+			foreign_net = EXCLUDED.foreign_net, trust_net = EXCLUDED.trust_net, dealer_net = EXCLUDED.dealer_net,
+			total_net = EXCLUDED.total_net, market = EXCLUDED.market
+	`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prepare institutional trades: %w", err)
+	}
+	defer stmt.Close()
+
+	stored := 0
+	for _, t := range trades {
+		if _, err := stmt.ExecContext(ctx, t.Symbol, t.TradeDate, t.ForeignNet, t.TrustNet, t.DealerNet, t.TotalNet, t.Market); err != nil {
+			continue
+		}
+		stored++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to store institutional trades: %w", err)
+	}
+	return stored, nil
+}
+
+// GetInstitutionalTrades returns a symbol's latest institutional trades, newest first
+func (s *InstitutionalService) GetInstitutionalTrades(ctx context.Context, symbol string, days int) ([]InstitutionalTrade, error) {
+	if days <= 0 || days > 250 {
+		days = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, TO_CHAR(trade_date, 'YYYY-MM-DD'), foreign_net, trust_net, dealer_net, total_net, market, updated_at
+		FROM stock_institutional_trades
+		WHERE symbol = $1
+		ORDER BY trade_date DESC
+		LIMIT $2
+	`, symbol, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query institutional trades: %w", err)
+	}
+	defer rows.Close()
+
+	trades := []InstitutionalTrade{}
+	for rows.Next() {
+		var t InstitutionalTrade
+		if err := rows.Scan(&t.Symbol, &t.TradeDate, &t.ForeignNet, &t.TrustNet, &t.DealerNet, &t.TotalNet,
+			&t.Market, &t.UpdatedAt); err != nil {
+			continue
+		}
+		trades = append(trades, t)
+	}
+	return trades, nil
+}
+
+// InstitutionalFetchWorker fetches the day's institutional trades once the
+// day's bars are synced and the exchanges have published their reports
+type InstitutionalFetchWorker struct {
+	institutionalService *InstitutionalService
+	runAfterHour         int // Earliest Taipei hour for the fetch
+	mu                   sync.Mutex
+	isRunning            bool
+	isBusy               bool
+	stopChan             chan struct{}
+}
+
+// NewInstitutionalFetchWorker reads INSTITUTIONAL_FETCH_HOUR (earliest Taipei hour, default 17)
+func NewInstitutionalFetchWorker(institutionalService *InstitutionalService) *InstitutionalFetchWorker {
+	hour := 17
+	if v, err := strconv.Atoi(os.Getenv("INSTITUTIONAL_FETCH_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+	return &InstitutionalFetchWorker{
+		institutionalService: institutionalService,
+		runAfterHour:         hour,
+		stopChan:             make(chan struct{}),
+	}
+}
+
+// Start launches the fetch loop
+func (w *InstitutionalFetchWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Institutional fetch worker started (after %02d:00)", w.runAfterHour)
+}
+
+// Stop stops the fetch loop
+func (w *InstitutionalFetchWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *InstitutionalFetchWorker) loop() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	w.checkAndRun()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun fetches today's reports when today is a trading day without
+// institutional trades yet
+func (w *InstitutionalFetchWorker) checkAndRun() {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	now := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600))
+	if now.Hour() < w.runAfterHour {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	today := now.Format("2006-01-02")
+	var due bool
+	if err := w.institutionalService.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+				SELECT 1 FROM stock_ohlcv
+				WHERE timestamp >= $1::date AND timestamp < $1::date + 1 AND `+notIndexSymbolSQL+`
+		   )
+		   AND NOT EXISTS (SELECT 1 FROM stock_institutional_trades WHERE trade_date = $1::date)
+	`, today).Scan(&due); err != nil || !due {
+		return
+	}
+
+	results, err := w.institutionalService.FetchInstitutionalTrades(ctx, now)
+	if err != nil {
+		log.Printf("Institutional fetch worker: %v", err)
+		return
+	}
+	for _, r := range results {
+		if r.Error != "" {
+			log.Printf("Institutional fetch worker: %s %s failed: %s", r.Market, r.Date, r.Error)
+		} else if r.Stored > 0 {
+			log.Printf("Institutional fetch worker: %s %s stored %d of %d symbols", r.Market, r.Date, r.Stored, r.Records)
+		}
+	}
+}
+
+// institutionalStreak counts the consecutive days, newest first, with net
+// buying (positive) or net selling (negative)
+func institutionalStreak(nets []int64) int {
+	if len(nets) == 0 || nets[0] == 0 {
+		return 0
+	}
+	streak := 0
+	for _, n := range nets {
+		if n == 0 || (n > 0) != (nets[0] > 0) {
+			break
+		}
+		streak++
+	}
+	if nets[0] < 0 {
+		return -streak
+	}
+	return streak
+}
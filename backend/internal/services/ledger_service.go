@@ -22,6 +22,26 @@ func NewLedgerService(db *database.DB) *LedgerService {
 
 // CreateEvent creates a new ledger event (transaction)
 func (s *LedgerService) CreateEvent(ctx context.Context, userID uuid.UUID, req models.CreateLedgerEventRequest) (*models.LedgerEvent, error) {
+	return s.createEvent(ctx, userID, req, "manual")
+}
+
+// createEvent records a ledger event with the given source ('manual', 'import', 'paper', ...)
+func (s *LedgerService) createEvent(ctx context.Context, userID uuid.UUID, req models.CreateLedgerEventRequest, source string) (*models.LedgerEvent, error) {
+	event := newLedgerEvent(userID, req, source)
+	if err := insertLedgerEvent(ctx, s.db, event); err != nil {
+		return nil, err
+	}
+
+	// Refresh positions materialized view
+	if err := s.RefreshPositions(ctx); err != nil {
+		return nil, fmt.Errorf("failed to refresh positions: %w", err)
+	}
+
+	return event, nil
+}
+
+// newLedgerEvent builds an event, with its total amount, from a request
+func newLedgerEvent(userID uuid.UUID, req models.CreateLedgerEventRequest, source string) *models.LedgerEvent {
 	// Calculate total amount
 	totalAmount := req.Quantity.Mul(req.Price)
 	
@@ -46,10 +66,20 @@ func (s *LedgerService) CreateEvent(ctx context.Context, userID uuid.UUID, req m
 		TotalAmount: totalAmount,
 		OccurredAt:  req.OccurredAt,
 		RecordedAt:  time.Now(),
-		Source:      "manual",
+		Source:      source,
 		Notes:       req.Notes,
 	}
+	return event
+}
+
+// ledgerQueryer is the database or a transaction
+type ledgerQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
 
+// insertLedgerEvent writes an event through q; the caller refreshes positions
+// once it is committed
+func insertLedgerEvent(ctx context.Context, q ledgerQueryer, event *models.LedgerEvent) error {
 	query := `
 		INSERT INTO ledger_events (
 			event_id, user_id, portfolio_id, event_type, symbol,
@@ -61,24 +91,19 @@ func (s *LedgerService) CreateEvent(ctx context.Context, userID uuid.UUID, req m
 		RETURNING event_id, recorded_at
 	`
 
-	err := s.db.QueryRowContext(ctx, query,
+	err := q.QueryRowContext(ctx, query,
 		event.EventID, event.UserID, event.PortfolioID, event.EventType, event.Symbol,
 		event.Quantity, event.Price, event.Fee, event.Tax, event.TotalAmount,
 		event.OccurredAt, event.RecordedAt, event.Source, event.Notes,
 	).Scan(&event.EventID, &event.RecordedAt)
 
 	if err != nil {
-		return nil, fmt.Errorf("failed to create ledger event: %w", err)
-	}
-
-	// Refresh positions materialized view
-	if err := s.RefreshPositions(ctx); err != nil {
-		return nil, fmt.Errorf("failed to refresh positions: %w", err)
+		return fmt.Errorf("failed to create ledger event: %w", err)
 	}
-
-	return event, nil
+	return nil
 }
 
+
 // GetEvents retrieves ledger events for a portfolio
 func (s *LedgerService) GetEvents(ctx context.Context, portfolioID uuid.UUID, limit int) ([]models.LedgerEvent, error) {
 	query := `
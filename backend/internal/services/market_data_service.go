@@ -137,6 +137,95 @@ func (s *MarketDataService) FetchDailyData(ctx context.Context, symbol string, s
 	return allData, nil
 }
 
+// IndexSymbolTAIEX is the symbol under which TAIEX daily bars are stored in stock_ohlcv
+const IndexSymbolTAIEX = "TAIEX"
+
+// Index bars share stock_ohlcv with stock bars. Queries that read the index
+// select it with indexSymbolSQL; queries that list symbols or find the latest
+// trading day leave it out with notIndexSymbolSQL.
+const (
+	indexSymbolSQL    = "'" + IndexSymbolTAIEX + "'"
+	notIndexSymbolSQL = "symbol <> " + indexSymbolSQL
+)
+
+// FetchIndexDaily fetches TAIEX (發行量加權股價指數) daily OHLC from TWSE, one month per request.
+// Index bars carry no volume; they are stored as symbol IndexSymbolTAIEX.
+func (s *MarketDataService) FetchIndexDaily(ctx context.Context, startDate, endDate time.Time) ([]OHLCV, error) {
+	// Format: https://www.twse.com.tw/indicesReport/MI_5MINS_HIST?response=json&date=20240101
+	var allData []OHLCV
+	current := time.Date(startDate.Year(), startDate.Month(), 1, 0, 0, 0, 0, time.UTC)
+
+	for current.Before(endDate) || current.Equal(endDate) {
+		dateStr := current.Format("20060102")
+		url := fmt.Sprintf("https://www.twse.com.tw/indicesReport/MI_5MINS_HIST?response=json&date=%s", dateStr)
+
+		resp, err := http.Get(url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch index data: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+		}
+
+		var result struct {
+			Stat   string     `json:"stat"`
+			Fields []string   `json:"fields"`
+			Data   [][]string `json:"data"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON: %w", err)
+		}
+
+		// Fields: 日期, 開盤指數, 最高指數, 最低指數, 收盤指數
+		for _, row := range result.Data {
+			if len(row) < 5 {
+				continue
+			}
+			timestamp, err := parseROCDate(row[0])
+			if err != nil || timestamp.Before(startDate) || timestamp.After(endDate) {
+				continue
+			}
+
+			ohlcv := OHLCV{
+				Symbol:    IndexSymbolTAIEX,
+				Timestamp: timestamp,
+			}
+			if open, err := parseDecimal(row[1]); err == nil {
+				ohlcv.Open = open
+			}
+			if high, err := parseDecimal(row[2]); err == nil {
+				ohlcv.High = high
+			}
+			if low, err := parseDecimal(row[3]); err == nil {
+				ohlcv.Low = low
+			}
+			if close, err := parseDecimal(row[4]); err == nil {
+				ohlcv.Close = close
+			}
+			if ohlcv.Close.IsZero() {
+				continue
+			}
+
+			allData = append(allData, ohlcv)
+		}
+
+		current = current.AddDate(0, 1, 0)
+
+		// Rate limiting - TWSE has request limits
+		if current.Before(endDate) || current.Equal(endDate) {
+			time.Sleep(3 * time.Second)
+		}
+	}
+
+	return allData, nil
+}
+
 // SaveOHLCV inserts OHLCV data into database
 func (s *MarketDataService) SaveOHLCV(ctx context.Context, data []OHLCV) error {
 	if len(data) == 0 {
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Market sentiment index weightings
+const (
+	MarketSentimentByArticles = "articles"
+	MarketSentimentByTurnover = "turnover"
+)
+
+// MarketSentimentPoint is one day of the market sentiment index, with the
+// TAIEX close of that day for chart overlays
+type MarketSentimentPoint struct {
+	Date          string   `json:"date"`
+	Weighting     string   `json:"weighting"`
+	Score         float64  `json:"score"`       // -1.0 to 1.0
+	IndexValue    float64  `json:"index_value"` // 0-100, 50 = neutral
+	Articles      int      `json:"articles"`
+	Symbols       int      `json:"symbols"`
+	PositiveCount int      `json:"positive_count"`
+	NegativeCount int      `json:"negative_count"`
+	NeutralCount  int      `json:"neutral_count"`
+	TAIEXClose    *float64 `json:"taiex_close,omitempty"`
+}
+
+// ComputeMarketSentimentIndex (re)computes the index for every Taipei date in
+// [from, to] under both weightings, returning the number of rows written.
+// Days without analysed articles are skipped.
+func (s *SentimentService) ComputeMarketSentimentIndex(ctx context.Context, from, to time.Time) (int, error) {
+	if to.Before(from) {
+		return 0, fmt.Errorf("to must not be before from")
+	}
+
+	// Each analysed article counts once, whether or not it is tagged to a stock
+	byArticles := `
+		INSERT INTO market_sentiment_index (
+			date, weighting, score, index_value, articles, symbols,
+			positive_count, negative_count, neutral_count, computed_at
+		)
+		SELECT d.day, $3, ROUND(AVG(d.sentiment_score), 4), ROUND((AVG(d.sentiment_score) + 1) * 50, 2),
+		       COUNT(*),
+		       (SELECT COUNT(DISTINCT a.symbol) FROM article_symbols a
+		        JOIN stock_news x ON x.id = a.article_id
+		        WHERE x.sentiment IS NOT NULL AND (x.published_at AT TIME ZONE 'Asia/Taipei')::date = d.day),
+		       COUNT(*) FILTER (WHERE d.sentiment = 'positive'),
+		       COUNT(*) FILTER (WHERE d.sentiment = 'negative'),
+		       COUNT(*) FILTER (WHERE d.sentiment = 'neutral'),
+		       NOW()
+		FROM (
+			SELECT (published_at AT TIME ZONE 'Asia/Taipei')::date AS day, sentiment, sentiment_score
+			FROM stock_news
+			WHERE sentiment IS NOT NULL AND sentiment_score IS NOT NULL
+			  AND (published_at AT TIME ZONE 'Asia/Taipei')::date BETWEEN $1::date AND $2::date
+		) d
+		GROUP BY d.day
+		ON CONFLICT (date, weighting) DO UPDATE SET
+			score = EXCLUDED.score, index_value = EXCLUDED.index_value,
+			articles = EXCLUDED.articles, symbols = EXCLUDED.symbols,
+			positive_count = EXCLUDED.positive_count, negative_count = EXCLUDED.negative_count,
+			neutral_count = EXCLUDED.neutral_count, computed_at = EXCLUDED.computed_at
+	`
+
+	// Per-stock daily average, weighted by the stock's 20-day average turnover
+	// before that day; stocks without price history are left out
+	byTurnover := `
+		INSERT INTO market_sentiment_index (
+			date, weighting, score, index_value, articles, symbols,
+			positive_count, negative_count, neutral_count, computed_at
+		)
+		WITH per_symbol AS (
+			SELECT (n.published_at AT TIME ZONE 'Asia/Taipei')::date AS day, a.symbol,
+			       AVG(n.sentiment_score) AS score,
+			       COUNT(*) AS articles,
+			       COUNT(*) FILTER (WHERE n.sentiment = 'positive') AS positive,
+			       COUNT(*) FILTER (WHERE n.sentiment = 'negative') AS negative,
+			       COUNT(*) FILTER (WHERE n.sentiment = 'neutral') AS neutral
+			FROM stock_news n
+			JOIN article_symbols a ON a.article_id = n.id
+			WHERE n.sentiment IS NOT NULL AND n.sentiment_score IS NOT NULL
+			  AND (n.published_at AT TIME ZONE 'Asia/Taipei')::date BETWEEN $1::date AND $2::date
+			GROUP BY 1, 2
+		),
+		weighted AS (
+			SELECT p.*, w.avg_turnover
+			FROM per_symbol p
+			CROSS JOIN LATERAL (
+				SELECT AVG(o.turnover) AS avg_turnover
+				FROM (
+					SELECT turnover FROM stock_ohlcv
+					WHERE symbol = p.symbol AND timestamp < p.day + 1 AND turnover > 0
+					ORDER BY timestamp DESC
+					LIMIT 20
+				) o
+			) w
+			WHERE w.avg_turnover > 0
+		)
+		SELECT day, $3,
+		       ROUND(SUM(score * avg_turnover) / SUM(avg_turnover), 4),
+		       ROUND((SUM(score * avg_turnover) / SUM(avg_turnover) + 1) * 50, 2),
+		       SUM(articles), COUNT(*), SUM(positive), SUM(negative), SUM(neutral), NOW()
+		FROM weighted
+		GROUP BY day
+		ON CONFLICT (date, weighting) DO UPDATE SET
+			score = EXCLUDED.score, index_value = EXCLUDED.index_value,
+			articles = EXCLUDED.articles, symbols = EXCLUDED.symbols,
+			positive_count = EXCLUDED.positive_count, negative_count = EXCLUDED.negative_count,
+			neutral_count = EXCLUDED.neutral_count, computed_at = EXCLUDED.computed_at
+	`
+
+	fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+	written := 0
+	for _, q := range []struct {
+		weighting, query string
+	}{
+		{MarketSentimentByArticles, byArticles},
+		{MarketSentimentByTurnover, byTurnover},
+	} {
+		res, err := s.db.ExecContext(ctx, q.query, fromDate, toDate, q.weighting)
+		if err != nil {
+			return written, fmt.Errorf("failed to compute %s-weighted index: %w", q.weighting, err)
+		}
+		n, _ := res.RowsAffected()
+		written += int(n)
+	}
+	return written, nil
+}
+
+// GetMarketSentimentIndex returns the stored series for [from, to], oldest first
+func (s *SentimentService) GetMarketSentimentIndex(ctx context.Context, from, to time.Time, weighting string) ([]MarketSentimentPoint, error) {
+	if weighting == "" {
+		weighting = MarketSentimentByArticles
+	}
+	if weighting != MarketSentimentByArticles && weighting != MarketSentimentByTurnover {
+		return nil, fmt.Errorf("weighting must be articles or turnover")
+	}
+
+	query := `
+		SELECT to_char(m.date, 'YYYY-MM-DD'), m.weighting, m.score, m.index_value, m.articles, m.symbols,
+		       m.positive_count, m.negative_count, m.neutral_count,
+		       (SELECT o.close FROM stock_ohlcv o
+		        WHERE o.symbol = $4 AND (o.timestamp AT TIME ZONE 'Asia/Taipei')::date = m.date
+		        ORDER BY o.timestamp DESC LIMIT 1)
+		FROM market_sentiment_index m
+		WHERE m.weighting = $3 AND m.date BETWEEN $1::date AND $2::date
+		ORDER BY m.date
+	`
+	rows, err := s.db.QueryContext(ctx, query, from.Format("2006-01-02"), to.Format("2006-01-02"), weighting, IndexSymbolTAIEX)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query market sentiment index: %w", err)
+	}
+	defer rows.Close()
+
+	points := []MarketSentimentPoint{}
+	for rows.Next() {
+		var p MarketSentimentPoint
+		if err := rows.Scan(
+			&p.Date, &p.Weighting, &p.Score, &p.IndexValue, &p.Articles, &p.Symbols,
+			&p.PositiveCount, &p.NegativeCount, &p.NeutralCount, &p.TAIEXClose,
+		); err != nil {
+			continue
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
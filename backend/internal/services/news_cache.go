@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// newsCacheGenKey holds a counter that is part of every news cache key. Bumping it
+// when articles are inserted or removed invalidates all cached pages at once; the
+// stale entries simply expire.
+const newsCacheGenKey = "news:cache:gen"
+
+// newsCacheTTL is NEWS_CACHE_TTL in seconds (default 60)
+func newsCacheTTL() time.Duration {
+	if v, err := strconv.Atoi(os.Getenv("NEWS_CACHE_TTL")); err == nil && v > 0 {
+		return time.Duration(v) * time.Second
+	}
+	return 60 * time.Second
+}
+
+// newsCacheKey builds a versioned cache key; ok is false when Redis is unavailable
+func (s *NewsService) newsCacheKey(ctx context.Context, kind string, params interface{}) (string, bool) {
+	if s.redisClient == nil {
+		return "", false
+	}
+	gen, err := s.redisClient.Get(ctx, newsCacheGenKey).Int64()
+	if err != nil && err != redis.Nil {
+		return "", false
+	}
+
+	raw, _ := json.Marshal(params)
+	sum := sha1.Sum(raw)
+	return fmt.Sprintf("news:%s:%d:%s", kind, gen, hex.EncodeToString(sum[:8])), true
+}
+
+func (s *NewsService) getNewsCache(ctx context.Context, key string, dst interface{}) bool {
+	data, err := s.redisClient.Get(ctx, key).Bytes()
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, dst) == nil
+}
+
+func (s *NewsService) setNewsCache(ctx context.Context, key string, v interface{}) {
+	if data, err := json.Marshal(v); err == nil {
+		s.redisClient.Set(ctx, key, data, newsCacheTTL())
+	}
+}
+
+// invalidateNewsCache drops every cached news page
+func (s *NewsService) invalidateNewsCache(ctx context.Context) {
+	if s.redisClient != nil {
+		s.redisClient.Incr(ctx, newsCacheGenKey)
+	}
+}
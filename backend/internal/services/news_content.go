@@ -0,0 +1,368 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+var (
+	contentNoiseRe   = regexp.MustCompile(`(?is)<(script|style|noscript|nav|header|footer|aside|figure)\b.*?</(script|style|noscript|nav|header|footer|aside|figure)>`)
+	contentArticleRe = regexp.MustCompile(`(?is)<article\b[^>]*>(.*?)</article>`)
+	contentParaRe    = regexp.MustCompile(`(?is)<p\b[^>]*>(.*?)</p>`)
+	cnyesNewsIDRe    = regexp.MustCompile(`news\.cnyes\.com/news/id/(\d+)`)
+)
+
+// articleContentFetcher retrieves full article text for items whose feed or API
+// content was truncated. Only allow-listed hosts are fetched, and robots.txt is honoured.
+type articleContentFetcher struct {
+	enabled       bool
+	allowedHosts  map[string]bool
+	respectRobots bool
+	minLength     int // Articles shorter than this (in runes) are considered truncated
+	client        *http.Client
+	mu            sync.Mutex
+	robots        map[string]*robotsRules
+}
+
+type robotsRules struct {
+	allow     []string
+	disallow  []string
+	fetchedAt time.Time
+}
+
+// newArticleContentFetcher reads NEWS_CONTENT_FETCH_ENABLED (default false),
+// NEWS_CONTENT_FETCH_HOSTS, NEWS_CONTENT_RESPECT_ROBOTS (default true) and
+// NEWS_CONTENT_MIN_LENGTH (default 300)
+func newArticleContentFetcher() *articleContentFetcher {
+	f := &articleContentFetcher{
+		enabled:       strings.EqualFold(os.Getenv("NEWS_CONTENT_FETCH_ENABLED"), "true"),
+		allowedHosts:  make(map[string]bool),
+		respectRobots: !strings.EqualFold(os.Getenv("NEWS_CONTENT_RESPECT_ROBOTS"), "false"),
+		minLength:     300,
+		client:        &http.Client{Timeout: 15 * time.Second},
+		robots:        make(map[string]*robotsRules),
+	}
+
+	hosts := os.Getenv("NEWS_CONTENT_FETCH_HOSTS")
+	if hosts == "" {
+		hosts = "news.cnyes.com,tw.stock.yahoo.com,money.udn.com"
+	}
+	for _, h := range strings.Split(hosts, ",") {
+		if h = strings.ToLower(strings.TrimSpace(h)); h != "" {
+			f.allowedHosts[h] = true
+		}
+	}
+	if v, err := strconv.Atoi(os.Getenv("NEWS_CONTENT_MIN_LENGTH")); err == nil && v > 0 {
+		f.minLength = v
+	}
+	return f
+}
+
+// permitted reports whether the article URL may be fetched under the host allow-list and robots.txt
+func (f *articleContentFetcher) permitted(ctx context.Context, rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	if !f.allowedHosts[strings.ToLower(u.Hostname())] {
+		return false
+	}
+	if !f.respectRobots {
+		return true
+	}
+
+	rules := f.robotsFor(ctx, u)
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	// Longest matching rule wins; Allow wins ties
+	allowLen, disallowLen := -1, -1
+	for _, p := range rules.allow {
+		if strings.HasPrefix(path, p) && len(p) > allowLen {
+			allowLen = len(p)
+		}
+	}
+	for _, p := range rules.disallow {
+		if strings.HasPrefix(path, p) && len(p) > disallowLen {
+			disallowLen = len(p)
+		}
+	}
+	return disallowLen < 0 || allowLen >= disallowLen
+}
+
+// robotsFor returns the cached robots.txt rules for the URL's host (refreshed daily)
+func (f *articleContentFetcher) robotsFor(ctx context.Context, u *url.URL) *robotsRules {
+	host := strings.ToLower(u.Host)
+
+	f.mu.Lock()
+	rules, ok := f.robots[host]
+	f.mu.Unlock()
+	if ok && time.Since(rules.fetchedAt) < 24*time.Hour {
+		return rules
+	}
+
+	rules = &robotsRules{fetchedAt: time.Now()}
+	req, err := http.NewRequestWithContext(ctx, "GET", u.Scheme+"://"+u.Host+"/robots.txt", nil)
+	if err == nil {
+		resp, err := f.client.Do(req)
+		if err != nil {
+			// Unreachable robots.txt: treat the site as closed until the next refresh
+			rules.disallow = []string{"/"}
+		} else {
+			defer resp.Body.Close()
+			switch {
+			case resp.StatusCode == http.StatusOK:
+				body, _ := io.ReadAll(io.LimitReader(resp.Body, 512*1024))
+				parseRobots(string(body), rules)
+			case resp.StatusCode >= 500:
+				rules.disallow = []string{"/"}
+			}
+			// Other 4xx: no robots.txt, everything allowed
+		}
+	}
+
+	f.mu.Lock()
+	f.robots[host] = rules
+	f.mu.Unlock()
+	return rules
+}
+
+// parseRobots collects the Allow/Disallow rules of the groups that apply to every agent
+func parseRobots(body string, rules *robotsRules) {
+	applies, inAgents := false, false
+	for _, line := range strings.Split(body, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				applies = false
+			}
+			inAgents = true
+			if value == "*" {
+				applies = true
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if !applies || value == "" {
+				continue
+			}
+			// Wildcards are not supported; keep the literal prefix before them
+			if i := strings.IndexAny(value, "*$"); i >= 0 {
+				value = value[:i]
+			}
+			if key == "allow" {
+				rules.allow = append(rules.allow, value)
+			} else {
+				rules.disallow = append(rules.disallow, value)
+			}
+		default:
+			inAgents = false
+		}
+	}
+}
+
+// fetch returns the cleaned full text of an article
+func (f *articleContentFetcher) fetch(ctx context.Context, rawURL string) (string, error) {
+	// Cnyes serves the full article body through its API
+	if m := cnyesNewsIDRe.FindStringSubmatch(rawURL); m != nil {
+		if text, err := f.fetchCnyes(ctx, m[1]); err == nil && text != "" {
+			return text, nil
+		}
+	}
+
+	body, err := fetchNewsBody(ctx, rawURL, map[string]string{"Accept": "text/html"})
+	if err != nil {
+		return "", err
+	}
+	return extractArticleText(string(body)), nil
+}
+
+func (f *articleContentFetcher) fetchCnyes(ctx context.Context, newsID string) (string, error) {
+	body, err := fetchNewsBody(ctx, "https://api.cnyes.com/media/api/v1/news/"+newsID, map[string]string{
+		"Accept":  "application/json",
+		"Origin":  "https://www.cnyes.com",
+		"Referer": "https://www.cnyes.com/",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var resp struct {
+		Items struct {
+			Content string `json:"content"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	// The API returns entity-escaped HTML
+	return extractArticleText(html.UnescapeString(resp.Items.Content)), nil
+}
+
+// extractArticleText keeps the paragraph text of the page's main article
+func extractArticleText(page string) string {
+	page = contentNoiseRe.ReplaceAllString(page, "")
+	if m := contentArticleRe.FindStringSubmatch(page); m != nil {
+		page = m[1]
+	}
+
+	var paragraphs []string
+	for _, m := range contentParaRe.FindAllStringSubmatch(page, -1) {
+		text := strings.TrimSpace(html.UnescapeString(cleanHTML(m[1])))
+		// Very short paragraphs are captions, bylines and share prompts
+		if utf8.RuneCountInString(text) >= 10 {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	if len(paragraphs) == 0 {
+		return strings.TrimSpace(html.UnescapeString(cleanHTML(page)))
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// ContentFetchEnabled reports whether full-content fetching is switched on
+func (s *NewsService) ContentFetchEnabled() bool {
+	return s.contentFetcher.enabled
+}
+
+// FetchFullContent fetches and stores the full text of one article. The sentiment is
+// cleared so the article is re-analysed on the complete text.
+func (s *NewsService) FetchFullContent(ctx context.Context, articleID string) (string, error) {
+	if !s.contentFetcher.enabled {
+		return "", fmt.Errorf("content fetching is disabled (set NEWS_CONTENT_FETCH_ENABLED=true)")
+	}
+
+	var article NewsArticle
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, COALESCE(symbol, ''), title, COALESCE(content, ''), source, COALESCE(source_url, '')
+		FROM stock_news WHERE id = $1
+	`, articleID).Scan(&article.ID, &article.Symbol, &article.Title, &article.Content, &article.Source, &article.SourceURL)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("article not found")
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query article: %w", err)
+	}
+
+	return s.fetchArticleContent(ctx, article)
+}
+
+// EnrichTruncatedArticles fetches full text for recent articles whose stored content
+// is shorter than the configured minimum. Each article is attempted once.
+func (s *NewsService) EnrichTruncatedArticles(ctx context.Context, limit int) (int, error) {
+	if !s.contentFetcher.enabled {
+		return 0, nil
+	}
+	if limit <= 0 {
+		limit = 20
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, COALESCE(symbol, ''), title, COALESCE(content, ''), source, COALESCE(source_url, '')
+		FROM stock_news
+		WHERE content_fetch_status IS NULL
+		  AND source_url IS NOT NULL
+		  AND char_length(COALESCE(content, '')) < $1
+		  AND published_at >= NOW() - INTERVAL '7 days'
+		ORDER BY published_at DESC
+		LIMIT $2
+	`, s.contentFetcher.minLength, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query truncated articles: %w", err)
+	}
+	var articles []NewsArticle
+	for rows.Next() {
+		var a NewsArticle
+		if err := rows.Scan(&a.ID, &a.Symbol, &a.Title, &a.Content, &a.Source, &a.SourceURL); err == nil {
+			articles = append(articles, a)
+		}
+	}
+	rows.Close()
+
+	enriched := 0
+	for _, article := range articles {
+		if err := ctx.Err(); err != nil {
+			return enriched, err
+		}
+		if _, err := s.fetchArticleContent(ctx, article); err == nil {
+			enriched++
+		}
+	}
+	return enriched, nil
+}
+
+func (s *NewsService) fetchArticleContent(ctx context.Context, article NewsArticle) (string, error) {
+	if !s.contentFetcher.permitted(ctx, article.SourceURL) {
+		s.setContentFetchStatus(ctx, article.ID, "blocked")
+		return "", fmt.Errorf("fetching %s is not permitted by the host allow-list or robots.txt", article.SourceURL)
+	}
+
+	text, err := s.contentFetcher.fetch(ctx, article.SourceURL)
+	if err != nil || utf8.RuneCountInString(text) <= utf8.RuneCountInString(article.Content) {
+		s.setContentFetchStatus(ctx, article.ID, "failed")
+		if err == nil {
+			err = fmt.Errorf("page has no more text than the stored content")
+		}
+		return "", err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		UPDATE stock_news SET
+			content = $2,
+			content_fetched_at = NOW(),
+			content_fetch_status = 'fetched',
+			sentiment = NULL,
+			sentiment_score = NULL,
+			sentiment_analyzed_at = NULL,
+			sentiment_method = NULL,
+			sentiment_confidence = NULL,
+			sentiment_dictionary_version = NULL,
+			sentiment_analyzer_version = NULL
+		WHERE id = $1
+	`, article.ID, text)
+	if err != nil {
+		return "", fmt.Errorf("failed to update article: %w", err)
+	}
+
+	// The full text may mention more stocks than the excerpt did
+	article.Content = text
+	s.tagArticleSymbols(ctx, article.ID, article)
+	s.invalidateNewsCache(ctx)
+	// Sentiment was cleared above; analyse the full text
+	s.sentimentQueue.Enqueue(article.ID)
+
+	return text, nil
+}
+
+func (s *NewsService) setContentFetchStatus(ctx context.Context, id, status string) {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE stock_news SET content_fetch_status = $2, content_fetched_at = NOW() WHERE id = $1
+	`, id, status); err != nil {
+		log.Printf("Failed to record content fetch status for %s: %v", id, err)
+	}
+}
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+const (
+	// Copies of a story are only matched against articles published this close to them
+	newsDedupWindow = 72 * time.Hour
+	// Minimum pg_trgm similarity for two headlines to count as the same story
+	newsDedupSimilarity = 0.6
+)
+
+// NewsDuplicate is a copy of a story from another outlet, linked to its canonical article
+type NewsDuplicate struct {
+	ID          string    `json:"id"`
+	CanonicalID string    `json:"canonical_id"`
+	Source      string    `json:"source"`
+	SourceURL   string    `json:"source_url"`
+	Title       string    `json:"title"`
+	PublishedAt time.Time `json:"published_at"`
+	MatchType   string    `json:"match_type"`
+	Similarity  *float64  `json:"similarity,omitempty"`
+	DetectedAt  time.Time `json:"detected_at"`
+}
+
+// articleKnown reports whether the source URL is already stored, either as an
+// article or as a recorded duplicate
+func (s *NewsService) articleKnown(ctx context.Context, article NewsArticle) bool {
+	var known bool
+	err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM stock_news WHERE source = $1 AND source_url = $2)
+		    OR EXISTS (SELECT 1 FROM news_duplicates WHERE source = $1 AND source_url = $2)
+	`, article.Source, article.SourceURL).Scan(&known)
+	return err == nil && known
+}
+
+// findCanonical looks for an already-stored article telling the same story: first an
+// exact normalized-title match, then the most similar headline above the threshold.
+// MOPS filings are never used as the canonical copy of a media story.
+func (s *NewsService) findCanonical(ctx context.Context, article NewsArticle) (id, matchType string, similarity *float64) {
+	from := article.PublishedAt.Add(-newsDedupWindow)
+	to := article.PublishedAt.Add(newsDedupWindow)
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id FROM stock_news
+		WHERE title_hash = md5(normalize_news_title($1))
+		  AND published_at BETWEEN $2 AND $3
+		  AND source <> 'mops'
+		ORDER BY published_at ASC
+		LIMIT 1
+	`, article.Title, from, to).Scan(&id)
+	if err == nil {
+		return id, "title_hash", nil
+	}
+
+	var sim float64
+	err = s.db.QueryRowContext(ctx, `
+		SELECT id, similarity(title, $1) AS sim FROM stock_news
+		WHERE published_at BETWEEN $2 AND $3
+		  AND source <> 'mops'
+		  AND title % $1
+		  AND similarity(title, $1) >= $4
+		ORDER BY sim DESC, published_at ASC
+		LIMIT 1
+	`, article.Title, from, to, newsDedupSimilarity).Scan(&id, &sim)
+	if err == nil {
+		sim = roundTo(sim, 4)
+		return id, "similarity", &sim
+	}
+
+	return "", "", nil
+}
+
+// recordDuplicate links an incoming article to its canonical copy
+func (s *NewsService) recordDuplicate(ctx context.Context, canonicalID, matchType string, similarity *float64, article NewsArticle) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO news_duplicates (canonical_id, source, source_url, title, published_at, match_type, similarity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (source, source_url) DO NOTHING
+	`, canonicalID, article.Source, article.SourceURL, article.Title, article.PublishedAt, matchType, similarity)
+	return err
+}
+
+// GetDuplicates returns the other outlets' copies of an article
+func (s *NewsService) GetDuplicates(ctx context.Context, articleID string) ([]NewsDuplicate, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM stock_news WHERE id = $1)`, articleID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to query article: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("article not found")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, canonical_id, source, source_url, title, published_at, match_type, similarity, detected_at
+		FROM news_duplicates
+		WHERE canonical_id = $1
+		ORDER BY published_at ASC
+	`, articleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query duplicates: %w", err)
+	}
+	defer rows.Close()
+
+	duplicates := []NewsDuplicate{}
+	for rows.Next() {
+		var d NewsDuplicate
+		var similarity sql.NullFloat64
+		if err := rows.Scan(
+			&d.ID, &d.CanonicalID, &d.Source, &d.SourceURL, &d.Title,
+			&d.PublishedAt, &d.MatchType, &similarity, &d.DetectedAt,
+		); err != nil {
+			continue
+		}
+		if similarity.Valid {
+			d.Similarity = &similarity.Float64
+		}
+		duplicates = append(duplicates, d)
+	}
+	return duplicates, nil
+}
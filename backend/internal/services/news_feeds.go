@@ -0,0 +1,344 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// NewsFeed is an admin-registered RSS/Atom feed
+type NewsFeed struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	Category      string     `json:"category,omitempty"`
+	DefaultSymbol string     `json:"default_symbol,omitempty"`
+	IsActive      bool       `json:"is_active"`
+	LastFetchedAt *time.Time `json:"last_fetched_at,omitempty"`
+	LastStatus    string     `json:"last_status,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NewsFeedRequest is the payload for registering or updating a feed
+type NewsFeedRequest struct {
+	Name          string `json:"name"`
+	URL           string `json:"url"`
+	Category      string `json:"category"`
+	DefaultSymbol string `json:"default_symbol"`
+	IsActive      *bool  `json:"is_active,omitempty"`
+}
+
+// feedSource adapts a registered feed to the NewsSource interface
+type feedSource struct {
+	feed    NewsFeed
+	matcher *stockNameMatcher
+}
+
+func (f *feedSource) Name() string { return "rss" }
+
+func (f *feedSource) SupportsSymbolSearch() bool { return false }
+
+func (f *feedSource) FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	return nil, nil
+}
+
+func (f *feedSource) FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error) {
+	items, err := fetchRSSItems(ctx, f.feed.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	category := f.feed.Category
+	if category == "" {
+		category = f.feed.Name
+	}
+	articles := rssToArticles(items, "rss", category, f.feed.DefaultSymbol, limit)
+
+	// Fall back to company-name matching when no stock code appears in the text
+	if f.feed.DefaultSymbol == "" && f.matcher != nil {
+		for i := range articles {
+			if articles[i].Symbol == "" {
+				articles[i].Symbol = f.matcher.match(ctx, articles[i].Title+" "+articles[i].Content)
+			}
+		}
+	}
+	return articles, nil
+}
+
+// stockNameMatcher finds listed company names in free text
+type stockNameMatcher struct {
+	db       *sql.DB
+	mu       sync.Mutex
+	names    []stockName // Longest names first, so 台積電 wins over shorter overlaps
+	loadedAt time.Time
+}
+
+type stockName struct {
+	name   string
+	symbol string
+	latin  bool // English names must match whole words ("ASE" is not in "BASE")
+}
+
+func (m *stockNameMatcher) match(ctx context.Context, text string) string {
+	if symbols := m.matchAll(ctx, text); len(symbols) > 0 {
+		return symbols[0]
+	}
+	return ""
+}
+
+// matchAll returns the symbol of every company named in the text, longest names first
+func (m *stockNameMatcher) matchAll(ctx context.Context, text string) []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if time.Since(m.loadedAt) > time.Hour {
+		m.load(ctx)
+	}
+
+	var symbols []string
+	seen := make(map[string]bool)
+	for _, n := range m.names {
+		if seen[n.symbol] {
+			continue
+		}
+		if (n.latin && containsWord(text, n.name)) || (!n.latin && strings.Contains(text, n.name)) {
+			seen[n.symbol] = true
+			symbols = append(symbols, n.symbol)
+		}
+	}
+	return symbols
+}
+
+func (m *stockNameMatcher) load(ctx context.Context) {
+	rows, err := m.db.QueryContext(ctx, `SELECT symbol, name, COALESCE(name_en, '') FROM taiwan_stocks WHERE is_active = true`)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var names []stockName
+	for rows.Next() {
+		var n stockName
+		var nameEn string
+		if err := rows.Scan(&n.symbol, &n.name, &nameEn); err != nil {
+			continue
+		}
+		// Single-character names match far too much text
+		if utf8.RuneCountInString(n.name) >= 2 {
+			names = append(names, n)
+		}
+		// English names let English-language articles be tagged too
+		if nameEn = trimCompanySuffix(nameEn); len(nameEn) >= 3 {
+			names = append(names, stockName{name: nameEn, symbol: n.symbol, latin: true})
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return utf8.RuneCountInString(names[i].name) > utf8.RuneCountInString(names[j].name)
+	})
+
+	m.names = names
+	m.loadedAt = time.Now()
+}
+
+// trimCompanySuffix drops legal suffixes ("Co., Ltd.", "Corp.") that articles omit
+func trimCompanySuffix(name string) string {
+	name = strings.TrimSpace(name)
+	for _, suffix := range []string{"Co., Ltd.", "Co.,Ltd.", "Co., Ltd", "Co. Ltd.", "Corporation", "Corp.", "Inc.", "Ltd.", "Co."} {
+		if strings.HasSuffix(name, suffix) {
+			name = strings.TrimSpace(strings.TrimSuffix(name, suffix))
+			name = strings.TrimSpace(strings.TrimSuffix(name, ","))
+			break
+		}
+	}
+	return name
+}
+
+// containsWord reports whether word occurs in text bounded by non-alphanumerics
+func containsWord(text, word string) bool {
+	for start := 0; ; {
+		i := strings.Index(text[start:], word)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(word)
+		before, _ := utf8.DecodeLastRuneInString(text[:i])
+		after, _ := utf8.DecodeRuneInString(text[end:])
+		if !isWordRune(before) && !isWordRune(after) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r))
+}
+
+// ListFeeds returns every registered feed
+func (s *NewsService) ListFeeds(ctx context.Context) ([]NewsFeed, error) {
+	return s.queryFeeds(ctx, false)
+}
+
+func (s *NewsService) queryFeeds(ctx context.Context, activeOnly bool) ([]NewsFeed, error) {
+	query := `
+		SELECT id, name, url, COALESCE(category, ''), COALESCE(default_symbol, ''), is_active,
+		       last_fetched_at, COALESCE(last_status, ''), COALESCE(last_error, ''), created_at
+		FROM news_feeds
+		WHERE ($1 = false OR is_active = true)
+		ORDER BY name
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, activeOnly)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query feeds: %w", err)
+	}
+	defer rows.Close()
+
+	feeds := []NewsFeed{}
+	for rows.Next() {
+		var f NewsFeed
+		if err := rows.Scan(
+			&f.ID, &f.Name, &f.URL, &f.Category, &f.DefaultSymbol, &f.IsActive,
+			&f.LastFetchedAt, &f.LastStatus, &f.LastError, &f.CreatedAt,
+		); err != nil {
+			continue
+		}
+		feeds = append(feeds, f)
+	}
+	return feeds, nil
+}
+
+// RegisterFeed validates a feed by fetching it once, then stores it
+func (s *NewsService) RegisterFeed(ctx context.Context, req NewsFeedRequest) (*NewsFeed, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	req.URL = strings.TrimSpace(req.URL)
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("url must be an http(s) URL")
+	}
+	if _, err := fetchRSSItems(ctx, req.URL); err != nil {
+		return nil, fmt.Errorf("feed could not be read: %v", err)
+	}
+
+	active := true
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+
+	feed := NewsFeed{
+		Name:          req.Name,
+		URL:           req.URL,
+		Category:      req.Category,
+		DefaultSymbol: strings.ToUpper(strings.TrimSpace(req.DefaultSymbol)),
+		IsActive:      active,
+	}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO news_feeds (name, url, category, default_symbol, is_active)
+		VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''), $5)
+		RETURNING id, created_at
+	`, feed.Name, feed.URL, feed.Category, feed.DefaultSymbol, feed.IsActive).Scan(&feed.ID, &feed.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("feed already registered: %s", req.URL)
+		}
+		return nil, fmt.Errorf("failed to register feed: %w", err)
+	}
+
+	return &feed, nil
+}
+
+// UpdateFeed changes a feed's name, category, default symbol or active flag
+func (s *NewsService) UpdateFeed(ctx context.Context, id string, req NewsFeedRequest) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE news_feeds SET
+			name = COALESCE(NULLIF($2, ''), name),
+			category = COALESCE(NULLIF($3, ''), category),
+			default_symbol = COALESCE(NULLIF($4, ''), default_symbol),
+			is_active = COALESCE($5, is_active)
+		WHERE id = $1
+	`, id, strings.TrimSpace(req.Name), req.Category, strings.ToUpper(strings.TrimSpace(req.DefaultSymbol)), req.IsActive)
+	if err != nil {
+		return fmt.Errorf("failed to update feed: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("feed not found")
+	}
+	return nil
+}
+
+// DeleteFeed unregisters a feed; articles already ingested are kept
+func (s *NewsService) DeleteFeed(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM news_feeds WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete feed: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("feed not found")
+	}
+	return nil
+}
+
+// FeedSources returns a NewsSource for every active registered feed
+func (s *NewsService) FeedSources(ctx context.Context) ([]NewsSource, error) {
+	feeds, err := s.queryFeeds(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make([]NewsSource, 0, len(feeds))
+	for _, feed := range feeds {
+		sources = append(sources, &feedSource{feed: feed, matcher: s.nameMatcher})
+	}
+	return sources, nil
+}
+
+// FetchFeed ingests one registered feed and records the outcome on the feed
+func (s *NewsService) FetchFeed(ctx context.Context, source NewsSource) ([]NewsArticle, error) {
+	fs, ok := source.(*feedSource)
+	if !ok {
+		return nil, fmt.Errorf("not a registered feed")
+	}
+
+	articles, err := s.FetchFromSource(ctx, fs, "", 100)
+	status, errMsg := "success", ""
+	if err != nil {
+		status, errMsg = "failed", err.Error()
+	}
+	s.db.ExecContext(ctx, `
+		UPDATE news_feeds SET last_fetched_at = NOW(), last_status = $2, last_error = NULLIF($3, '')
+		WHERE id = $1
+	`, fs.feed.ID, status, errMsg)
+
+	return articles, err
+}
+
+// FetchAllFeeds ingests every active registered feed
+func (s *NewsService) FetchAllFeeds(ctx context.Context) (int, error) {
+	sources, err := s.FeedSources(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, source := range sources {
+		articles, err := s.FetchFeed(ctx, source)
+		if err != nil {
+			log.Printf("News feed %s failed: %v", source.(*feedSource).feed.Name, err)
+			continue
+		}
+		total += len(articles)
+	}
+	return total, nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NewsFetchWorker periodically fetches general market news and per-symbol news for
+// every tracked symbol (portfolio holdings and watchlists)
+type NewsFetchWorker struct {
+	newsService      *NewsService
+	watchlistService *WatchlistService
+	generalInterval  time.Duration
+	symbolInterval   time.Duration
+	pacing           map[string]time.Duration // Minimum gap between requests per news source
+	defaultPacing    time.Duration
+	mu               sync.Mutex
+	lastRequest      map[string]time.Time
+	isRunning        bool
+	stopChan         chan struct{}
+	status           NewsFetchStatus
+}
+
+// NewsFetchStatus reports the worker's recent activity
+type NewsFetchStatus struct {
+	IsRunning          bool       `json:"is_running"`
+	GeneralInterval    string     `json:"general_interval"`
+	SymbolInterval     string     `json:"symbol_interval"`
+	LastGeneralFetch   *time.Time `json:"last_general_fetch,omitempty"`
+	LastSymbolFetch    *time.Time `json:"last_symbol_fetch,omitempty"`
+	SymbolFetchRunning bool       `json:"symbol_fetch_running"`
+	TrackedSymbols     int        `json:"tracked_symbols"`
+	LastArticlesFound  int        `json:"last_articles_found"`
+	LastErrors         int        `json:"last_errors"`
+}
+
+func NewNewsFetchWorker(newsService *NewsService, watchlistService *WatchlistService) *NewsFetchWorker {
+	// NEWS_FETCH_GENERAL_INTERVAL / NEWS_FETCH_SYMBOL_INTERVAL: minutes between runs
+	general := 15
+	if v, err := strconv.Atoi(os.Getenv("NEWS_FETCH_GENERAL_INTERVAL")); err == nil && v > 0 {
+		general = v
+	}
+	perSymbol := 60
+	if v, err := strconv.Atoi(os.Getenv("NEWS_FETCH_SYMBOL_INTERVAL")); err == nil && v > 0 {
+		perSymbol = v
+	}
+
+	w := &NewsFetchWorker{
+		newsService:      newsService,
+		watchlistService: watchlistService,
+		generalInterval:  time.Duration(general) * time.Minute,
+		symbolInterval:   time.Duration(perSymbol) * time.Minute,
+		pacing:           map[string]time.Duration{"cnyes": 2 * time.Second, "yahoo": 3 * time.Second, "udn": 3 * time.Second, "mops": 5 * time.Second, "taipeitimes": 3 * time.Second, "rss": time.Second},
+		defaultPacing:    2 * time.Second,
+		lastRequest:      make(map[string]time.Time),
+		stopChan:         make(chan struct{}),
+	}
+
+	// NEWS_FETCH_PACING: per-source request gap, e.g. "cnyes=2s,yahoo=5s"
+	for _, entry := range strings.Split(os.Getenv("NEWS_FETCH_PACING"), ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		if d, err := time.ParseDuration(parts[1]); err == nil && d >= 0 {
+			w.pacing[strings.ToLower(parts[0])] = d
+		}
+	}
+
+	w.status.GeneralInterval = w.generalInterval.String()
+	w.status.SymbolInterval = w.symbolInterval.String()
+	return w
+}
+
+// Start launches the fetch loops
+func (w *NewsFetchWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.status.IsRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("News fetch worker started (general every %v, tracked symbols every %v)", w.generalInterval, w.symbolInterval)
+}
+
+// Stop stops the fetch loops
+func (w *NewsFetchWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	w.status.IsRunning = false
+	close(w.stopChan)
+}
+
+// GetStatus returns the worker's recent activity
+func (w *NewsFetchWorker) GetStatus() NewsFetchStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.status
+}
+
+// RunNow triggers a general and a tracked-symbol fetch in the background
+func (w *NewsFetchWorker) RunNow() {
+	go func() {
+		w.fetchGeneral()
+		w.fetchTrackedSymbols()
+	}()
+}
+
+func (w *NewsFetchWorker) loop() {
+	generalTicker := time.NewTicker(w.generalInterval)
+	defer generalTicker.Stop()
+	symbolTicker := time.NewTicker(w.symbolInterval)
+	defer symbolTicker.Stop()
+
+	w.fetchGeneral()
+	go w.fetchTrackedSymbols()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-generalTicker.C:
+			w.fetchGeneral()
+		case <-symbolTicker.C:
+			// Per-symbol passes can outlast the general interval; run them alongside
+			go w.fetchTrackedSymbols()
+		}
+	}
+}
+
+func (w *NewsFetchWorker) fetchGeneral() {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	for _, source := range w.newsService.EnabledSources() {
+		w.wait(source.Name())
+		if _, err := w.newsService.FetchFromSource(ctx, source, "", 50); err != nil {
+			log.Printf("News fetch worker: general news failed: %v", err)
+		}
+	}
+
+	// Registered RSS/Atom feeds
+	if feeds, err := w.newsService.FeedSources(ctx); err == nil {
+		for _, feed := range feeds {
+			w.wait(feed.Name())
+			w.newsService.FetchFeed(ctx, feed)
+		}
+	}
+
+	// Full text for truncated items, so sentiment runs on the complete article
+	if w.newsService.ContentFetchEnabled() {
+		if n, err := w.newsService.EnrichTruncatedArticles(ctx, 20); err != nil {
+			log.Printf("News fetch worker: content fetch failed: %v", err)
+		} else if n > 0 {
+			log.Printf("News fetch worker: fetched full content for %d articles", n)
+		}
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	w.status.LastGeneralFetch = &now
+	w.mu.Unlock()
+}
+
+func (w *NewsFetchWorker) fetchTrackedSymbols() {
+	w.mu.Lock()
+	if w.status.SymbolFetchRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.status.SymbolFetchRunning = true
+	stop := w.stopChan
+	w.mu.Unlock()
+
+	found, failed := 0, 0
+	defer func() {
+		now := time.Now()
+		w.mu.Lock()
+		w.status.SymbolFetchRunning = false
+		w.status.LastSymbolFetch = &now
+		w.status.LastArticlesFound = found
+		w.status.LastErrors = failed
+		w.mu.Unlock()
+	}()
+
+	symbols, err := w.watchlistService.TrackedSymbols(context.Background())
+	if err != nil {
+		log.Printf("News fetch worker: %v", err)
+		return
+	}
+	w.mu.Lock()
+	w.status.TrackedSymbols = len(symbols)
+	w.mu.Unlock()
+
+	for _, symbol := range symbols {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		for _, source := range w.newsService.EnabledSources() {
+			if !source.SupportsSymbolSearch() {
+				continue
+			}
+			w.wait(source.Name())
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			articles, err := w.newsService.FetchFromSource(ctx, source, symbol, 20)
+			cancel()
+			if err != nil {
+				failed++
+				continue
+			}
+			found += len(articles)
+		}
+	}
+
+	log.Printf("News fetch worker: fetched %d articles for %d tracked symbols (%d errors)", found, len(symbols), failed)
+}
+
+// SymbolFetchResult summarises an on-demand fetch for one symbol
+type SymbolFetchResult struct {
+	Symbol   string   `json:"symbol"`
+	Articles int      `json:"articles"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+// FetchSymbols fetches news for each symbol from every enabled source that supports
+// symbol search, sharing the worker's per-source pacing with the scheduled loops
+func (w *NewsFetchWorker) FetchSymbols(ctx context.Context, symbols []string) []SymbolFetchResult {
+	results := make([]SymbolFetchResult, 0, len(symbols))
+	for _, symbol := range symbols {
+		result := SymbolFetchResult{Symbol: symbol}
+		for _, source := range w.newsService.EnabledSources() {
+			if !source.SupportsSymbolSearch() {
+				continue
+			}
+			if ctx.Err() != nil {
+				result.Errors = append(result.Errors, ctx.Err().Error())
+				break
+			}
+			w.wait(source.Name())
+			articles, err := w.newsService.FetchFromSource(ctx, source, symbol, 20)
+			if err != nil {
+				result.Errors = append(result.Errors, err.Error())
+				continue
+			}
+			result.Articles += len(articles)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// wait blocks until the per-source pacing gap since the last request has passed
+func (w *NewsFetchWorker) wait(source string) {
+	w.mu.Lock()
+	gap, ok := w.pacing[source]
+	if !ok {
+		gap = w.defaultPacing
+	}
+	next := w.lastRequest[source].Add(gap)
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	// Reserve the slot before sleeping so concurrent loops queue up behind it
+	w.lastRequest[source] = next
+	w.mu.Unlock()
+
+	time.Sleep(time.Until(next))
+}
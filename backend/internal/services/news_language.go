@@ -0,0 +1,38 @@
+package services
+
+import (
+	"unicode"
+)
+
+// Article languages stored in stock_news.language
+const (
+	NewsLanguageChinese = "zh-TW"
+	NewsLanguageEnglish = "en"
+)
+
+// detectLanguage classifies article text by script. Chinese financial news is
+// full of Latin tickers and acronyms (ETF, AI, TSMC), while English coverage
+// quotes the odd Chinese name, so Han characters are weighed against Latin
+// words: Chinese needs at least two Han characters per English word.
+// Returns "" when the text has no letters at all.
+func detectLanguage(text string) string {
+	han, words := 0, 0
+	inWord := false
+	for _, r := range text {
+		latin := r < unicode.MaxASCII && unicode.IsLetter(r)
+		switch {
+		case unicode.Is(unicode.Han, r):
+			han++
+		case latin && !inWord:
+			words++
+		}
+		inWord = latin
+	}
+	if han == 0 && words == 0 {
+		return ""
+	}
+	if han >= 2*words {
+		return NewsLanguageChinese
+	}
+	return NewsLanguageEnglish
+}
@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaterialAnnouncementCategory is the stock_news category of MOPS 重大訊息 filings
+const MaterialAnnouncementCategory = "重大訊息"
+
+// ================================================
+// 公開資訊觀測站 MOPS 每日重大訊息 (TWSE / TPEx open data)
+// ================================================
+
+type mopsSource struct{}
+
+func (m *mopsSource) Name() string { return "mops" }
+
+// SupportsSymbolSearch is false: the open data endpoints publish the whole day at once
+func (m *mopsSource) SupportsSymbolSearch() bool { return false }
+
+func (m *mopsSource) FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	return nil, nil
+}
+
+func (m *mopsSource) FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error) {
+	feeds := []struct {
+		market string
+		url    string
+	}{
+		{"TSE", "https://openapi.twse.com.tw/v1/opendata/t187ap04_L"},
+		{"OTC", "https://www.tpex.org.tw/openapi/v1/mopsfe_t187ap04_O"},
+	}
+
+	var articles []NewsArticle
+	var errs []string
+	for _, feed := range feeds {
+		body, err := fetchNewsBody(ctx, feed.url, map[string]string{"Accept": "application/json"})
+		if err != nil {
+			errs = append(errs, feed.market+": "+err.Error())
+			continue
+		}
+		var records []map[string]interface{}
+		if err := json.Unmarshal(body, &records); err != nil {
+			errs = append(errs, feed.market+": failed to parse response: "+err.Error())
+			continue
+		}
+		articles = append(articles, mopsToArticles(records)...)
+	}
+	if len(articles) == 0 && len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	// Newest first so a limit keeps the latest filings
+	sort.SliceStable(articles, func(i, j int) bool {
+		return articles[i].PublishedAt.After(articles[j].PublishedAt)
+	})
+	if limit > 0 && len(articles) > limit {
+		articles = articles[:limit]
+	}
+	return articles, nil
+}
+
+// mopsToArticles converts open data records. Field names carry stray spaces
+// ("主旨 ") and differ slightly between TWSE and TPEx, so keys are normalised.
+func mopsToArticles(records []map[string]interface{}) []NewsArticle {
+	articles := make([]NewsArticle, 0, len(records))
+	seq := make(map[string]int)
+
+	for _, raw := range records {
+		rec := make(map[string]string, len(raw))
+		for k, v := range raw {
+			rec[strings.ReplaceAll(strings.TrimSpace(k), " ", "")] = strings.TrimSpace(fmt.Sprint(v))
+		}
+
+		code := firstField(rec, "公司代號", "SecuritiesCompanyCode")
+		subject := firstField(rec, "主旨", "Subject")
+		if code == "" || subject == "" {
+			continue
+		}
+		name := firstField(rec, "公司名稱", "CompanyName")
+		date := firstField(rec, "發言日期", "DateOfAnnouncement")
+		clock := firstField(rec, "發言時間", "TimeOfAnnouncement")
+		description := firstField(rec, "說明", "Description")
+
+		publishedAt, err := parseMOPSTime(date, clock)
+		if err != nil {
+			publishedAt = time.Now()
+		}
+
+		// Several filings can share a company and timestamp; number them for a stable key
+		key := code + date + clock
+		seq[key]++
+
+		articles = append(articles, NewsArticle{
+			Symbol:  code,
+			Title:   fmt.Sprintf("【%s %s】%s", code, name, subject),
+			Summary: summarize(description, summaryLength()),
+			Content: description,
+			Source:  "mops",
+			SourceURL: fmt.Sprintf("https://mops.twse.com.tw/mops/web/t05sr01_1?co_id=%s&spoke_date=%s&spoke_time=%s&seq_no=%d",
+				code, date, clock, seq[key]),
+			PublishedAt: publishedAt,
+			FetchedAt:   time.Now(),
+			Category:    MaterialAnnouncementCategory,
+			Tags:        []string{MaterialAnnouncementCategory},
+		})
+	}
+	return articles
+}
+
+func firstField(rec map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := rec[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseMOPSTime parses a compact ROC date ("1131015") and time ("143005" or "14:30:05") in Taipei time
+func parseMOPSTime(date, clock string) (time.Time, error) {
+	date = strings.ReplaceAll(date, "/", "")
+	if len(date) < 7 {
+		return time.Time{}, fmt.Errorf("invalid ROC date %q", date)
+	}
+	year, err := strconv.Atoi(date[:len(date)-4])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ROC date %q", date)
+	}
+	month, _ := strconv.Atoi(date[len(date)-4 : len(date)-2])
+	day, _ := strconv.Atoi(date[len(date)-2:])
+
+	clock = strings.ReplaceAll(clock, ":", "")
+	for len(clock) < 6 {
+		clock = "0" + clock
+	}
+	hour, _ := strconv.Atoi(clock[0:2])
+	minute, _ := strconv.Atoi(clock[2:4])
+	second, _ := strconv.Atoi(clock[4:6])
+
+	taipei := time.FixedZone("Asia/Taipei", 8*3600)
+	return time.Date(year+1911, time.Month(month), day, hour, minute, second, 0, taipei), nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// newsCleanupBatch bounds how many articles are archived/deleted per transaction
+const newsCleanupBatch = 1000
+
+// NewsRetentionPolicy controls which articles the cleanup job removes
+type NewsRetentionPolicy struct {
+	RetentionMonths int  `json:"retention_months"`
+	KeepHeldSymbols bool `json:"keep_held_symbols"` // Never remove news tagged with a currently held symbol
+	Archive         bool `json:"archive"`           // Move to stock_news_archive instead of deleting outright
+	DryRun          bool `json:"dry_run,omitempty"`
+}
+
+// IsDryRun reports whether the policy only counts expired articles
+func (p NewsRetentionPolicy) IsDryRun() bool {
+	return p.DryRun
+}
+
+// NewsCleanupResult reports one cleanup run
+type NewsCleanupResult struct {
+	Policy          NewsRetentionPolicy `json:"policy"`
+	Cutoff          time.Time           `json:"cutoff"`
+	HeldSymbols     int                 `json:"held_symbols"`
+	Expired         int                 `json:"expired"`
+	Archived        int                 `json:"archived"`
+	Deleted         int                 `json:"deleted"`
+	FetchLogDeleted int                 `json:"fetch_log_deleted"`
+	StartedAt       time.Time           `json:"started_at"`
+	Duration        string              `json:"duration"`
+}
+
+// newsRetentionPolicyFromEnv reads NEWS_RETENTION_MONTHS (default 18),
+// NEWS_RETENTION_KEEP_HELD (default true) and NEWS_RETENTION_ARCHIVE (default
+// true, so nothing is lost unless deletion is asked for)
+func newsRetentionPolicyFromEnv() NewsRetentionPolicy {
+	policy := NewsRetentionPolicy{RetentionMonths: 18, KeepHeldSymbols: true, Archive: true}
+	if v, err := strconv.Atoi(os.Getenv("NEWS_RETENTION_MONTHS")); err == nil && v > 0 {
+		policy.RetentionMonths = v
+	}
+	if strings.EqualFold(os.Getenv("NEWS_RETENTION_KEEP_HELD"), "false") {
+		policy.KeepHeldSymbols = false
+	}
+	if strings.EqualFold(os.Getenv("NEWS_RETENTION_ARCHIVE"), "false") {
+		policy.Archive = false
+	}
+	return policy
+}
+
+// CleanupNews removes (or archives) articles published before the retention window
+func (s *NewsService) CleanupNews(ctx context.Context, policy NewsRetentionPolicy) (*NewsCleanupResult, error) {
+	if policy.RetentionMonths <= 0 {
+		return nil, fmt.Errorf("%w: retention_months must be positive", ErrInvalidRetentionPolicy)
+	}
+
+	result := &NewsCleanupResult{
+		Policy:    policy,
+		Cutoff:    time.Now().AddDate(0, -policy.RetentionMonths, 0),
+		StartedAt: time.Now(),
+	}
+
+	held := []string{}
+	if policy.KeepHeldSymbols {
+		rows, err := s.db.QueryContext(ctx, `
+			SELECT DISTINCT split_part(symbol, '.', 1) FROM positions_current WHERE total_quantity > 0
+		`)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query held symbols: %w", err)
+		}
+		for rows.Next() {
+			var symbol string
+			if err := rows.Scan(&symbol); err == nil {
+				held = append(held, symbol)
+			}
+		}
+		rows.Close()
+	}
+	result.HeldSymbols = len(held)
+
+	if policy.DryRun {
+		err := s.db.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM stock_news n
+			WHERE n.published_at < $1
+			  AND NOT EXISTS (
+				SELECT 1 FROM article_symbols s WHERE s.article_id = n.id AND s.symbol = ANY($2)
+			  )
+		`, result.Cutoff, pq.Array(held)).Scan(&result.Expired)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count expired news: %w", err)
+		}
+		result.Duration = time.Since(result.StartedAt).String()
+		return result, nil
+	}
+
+	batches := retentionBatches{
+		noun: "news",
+		selectQuery: `
+			SELECT n.id FROM stock_news n
+			WHERE n.published_at < $1
+			  AND NOT EXISTS (
+				SELECT 1 FROM article_symbols s WHERE s.article_id = n.id AND s.symbol = ANY($2)
+			  )
+			ORDER BY n.published_at
+			LIMIT $3
+		`,
+		selectArgs: []interface{}{result.Cutoff, pq.Array(held)},
+		archiveQuery: `
+			INSERT INTO stock_news_archive (
+				id, symbol, title, summary, content, source, source_url, published_at, fetched_at,
+				sentiment, sentiment_score, sentiment_analyzed_at, category, tags, symbols, language,
+				sentiment_method, sentiment_confidence, sentiment_dictionary_version, sentiment_analyzer_version
+			)
+			SELECT n.id, n.symbol, n.title, n.summary, n.content, n.source, n.source_url, n.published_at, n.fetched_at,
+			       n.sentiment, n.sentiment_score, n.sentiment_analyzed_at, n.category, n.tags,
+			       ARRAY(SELECT s.symbol FROM article_symbols s WHERE s.article_id = n.id ORDER BY s.symbol),
+			       n.language, n.sentiment_method, n.sentiment_confidence, n.sentiment_dictionary_version,
+			       n.sentiment_analyzer_version
+			FROM stock_news n
+			WHERE n.id = ANY($1::uuid[])
+			ON CONFLICT (id) DO NOTHING
+		`,
+		// Tagged symbols and duplicate links are removed with the article by cascade
+		deleteQuery: `DELETE FROM stock_news WHERE id = ANY($1::uuid[])`,
+		batchSize:   newsCleanupBatch,
+	}
+	var err error
+	result.Expired, result.Archived, result.Deleted, err = batches.run(ctx, s.db, policy.Archive)
+	if err != nil {
+		return result, err
+	}
+
+	if result.Deleted > 0 {
+		s.invalidateNewsCache(ctx)
+	}
+
+	if res, err := s.db.ExecContext(ctx, `DELETE FROM news_fetch_log WHERE fetched_at < $1`, result.Cutoff); err == nil {
+		n, _ := res.RowsAffected()
+		result.FetchLogDeleted = int(n)
+	}
+
+	result.Duration = time.Since(result.StartedAt).String()
+	return result, nil
+}
+
+// NewsRetentionWorker applies the news retention policy once a day
+type NewsRetentionWorker = RetentionWorker[NewsRetentionPolicy, NewsCleanupResult]
+
+// NewsRetentionStatus reports the news retention policy and the last run
+type NewsRetentionStatus = RetentionStatus[NewsRetentionPolicy, NewsCleanupResult]
+
+func NewNewsRetentionWorker(newsService *NewsService) *NewsRetentionWorker {
+	return newRetentionWorker("News retention", newsRetentionPolicyFromEnv(), newsService.CleanupNews,
+		func(r *NewsCleanupResult) string {
+			return fmt.Sprintf("removed %d articles older than %s (%d archived)",
+				r.Deleted, r.Cutoff.Format("2006-01-02"), r.Archived)
+		})
+}
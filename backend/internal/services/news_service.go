@@ -2,26 +2,39 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"encoding/base64"
 	"fmt"
-	"io"
-	"net/http"
+	"os"
 	"psm-backend/internal/database"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 )
 
 // NewsService handles news fetching and storage
 type NewsService struct {
-	db *database.DB
+	db          *database.DB
+	redisClient *redis.Client // Optional; caches the first page of news lists
+	sources     []NewsSource
+	nameMatcher *stockNameMatcher // Company-name symbol extraction for registered feeds
+	contentFetcher *articleContentFetcher
+	sentimentQueue *SentimentQueue // Optional; new articles are analysed in the background
 }
 
-func NewNewsService(db *database.DB) *NewsService {
-	return &NewsService{db: db}
+func NewNewsService(db *database.DB, redisClient *redis.Client, sentimentQueue *SentimentQueue) *NewsService {
+	return &NewsService{
+		db:          db,
+		redisClient: redisClient,
+		sources:     defaultNewsSources(),
+		nameMatcher: &stockNameMatcher{db: db.DB},
+		contentFetcher: newArticleContentFetcher(),
+		sentimentQueue: sentimentQueue,
+	}
 }
 
 // NewsArticle represents a news article
@@ -37,8 +50,13 @@ type NewsArticle struct {
 	FetchedAt    time.Time `json:"fetched_at"`
 	Sentiment    string    `json:"sentiment,omitempty"`
 	SentimentScore *float64 `json:"sentiment_score,omitempty"`
+	SentimentMethod string  `json:"sentiment_method,omitempty"` // keyword, gemini, openai
+	SentimentConfidence *float64 `json:"sentiment_confidence,omitempty"`
 	Category     string    `json:"category,omitempty"`
 	Tags         []string  `json:"tags,omitempty"`
+	DuplicateCount int     `json:"duplicate_count,omitempty"` // Copies of the story from other outlets
+	Symbols      []string  `json:"symbols,omitempty"`         // Every symbol the article mentions
+	Language     string    `json:"language,omitempty"`        // zh-TW or en; detected on save when the source does not set it
 }
 
 // CnyesNewsResponse represents the Cnyes API response (search endpoint)
@@ -62,10 +80,8 @@ type CnyesNewsItem struct {
 	Payment   int      `json:"payment"`   // 0 = free, 1 = paid
 }
 
-// FetchNewsForSymbol fetches news from Cnyes for a specific stock symbol
+// FetchNewsForSymbol fetches news for a specific stock symbol from every enabled source
 func (s *NewsService) FetchNewsForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
-	startTime := time.Now()
-	
 	if limit <= 0 {
 		limit = 20
 	}
@@ -73,181 +89,96 @@ func (s *NewsService) FetchNewsForSymbol(ctx context.Context, symbol string, lim
 		limit = 50
 	}
 
-	// Cnyes search API - search by stock code
-	url := fmt.Sprintf("https://api.cnyes.com/media/api/v1/search?q=%s&limit=%d", symbol, limit)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		s.logFetch("cnyes", &symbol, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Origin", "https://www.cnyes.com")
-	req.Header.Set("Referer", "https://www.cnyes.com/")
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		s.logFetch("cnyes", &symbol, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to fetch news: %w", err)
-	}
-	defer resp.Body.Close()
+	return s.fetchFromSources(ctx, symbol, limit)
+}
 
-	if resp.StatusCode != http.StatusOK {
-		s.logFetch("cnyes", &symbol, 0, 0, "failed", fmt.Sprintf("HTTP %d", resp.StatusCode), time.Since(startTime))
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+// FetchGeneralNews fetches general Taiwan stock market news from every enabled source
+func (s *NewsService) FetchGeneralNews(ctx context.Context, limit int) ([]NewsArticle, error) {
+	if limit <= 0 {
+		limit = 30
 	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		s.logFetch("cnyes", &symbol, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to read response: %w", err)
+	if limit > 100 {
+		limit = 100
 	}
 
-	var cnyesResp CnyesNewsResponse
-	if err := json.Unmarshal(body, &cnyesResp); err != nil {
-		s.logFetch("cnyes", &symbol, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
+	return s.fetchFromSources(ctx, "", limit)
+}
 
-	// Convert to NewsArticle
-	articles := make([]NewsArticle, 0, len(cnyesResp.Items.Data))
-	for _, item := range cnyesResp.Items.Data {
-		// Skip paid articles
-		if item.Payment == 1 {
+// fetchFromSources queries every enabled source; it only fails when all sources fail
+func (s *NewsService) fetchFromSources(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	var all []NewsArticle
+	var lastErr error
+	succeeded := 0
+	for _, source := range s.EnabledSources() {
+		if symbol != "" && !source.SupportsSymbolSearch() {
 			continue
 		}
-
-		// Build source URL
-		sourceURL := fmt.Sprintf("https://news.cnyes.com/news/id/%d", item.NewsID)
-
-		// Extract summary from content (first 200 chars)
-		summary := cleanHTML(item.Content)
-		if len(summary) > 200 {
-			summary = summary[:200] + "..."
-		}
-
-		article := NewsArticle{
-			Symbol:      symbol,
-			Title:       item.Title,
-			Summary:     summary,
-			Content:     cleanHTML(item.Content),
-			Source:      "cnyes",
-			SourceURL:   sourceURL,
-			PublishedAt: time.Unix(item.PublishAt, 0),
-			FetchedAt:   time.Now(),
-			Category:    "股票",
-			Tags:        item.Keyword,
+		articles, err := s.FetchFromSource(ctx, source, symbol, limit)
+		if err != nil {
+			lastErr = err
+			continue
 		}
-		articles = append(articles, article)
+		succeeded++
+		all = append(all, articles...)
 	}
 
-	// Save to database
-	newCount, err := s.SaveArticles(ctx, articles)
-	if err != nil {
-		s.logFetch("cnyes", &symbol, len(articles), 0, "partial", err.Error(), time.Since(startTime))
-		return articles, err
+	if succeeded == 0 && lastErr != nil {
+		return nil, lastErr
 	}
-
-	s.logFetch("cnyes", &symbol, len(articles), newCount, "success", "", time.Since(startTime))
-	return articles, nil
+	return all, nil
 }
 
-// FetchGeneralNews fetches general Taiwan stock market news
-func (s *NewsService) FetchGeneralNews(ctx context.Context, limit int) ([]NewsArticle, error) {
+// FetchFromSource fetches general news (empty symbol) or symbol news from one source,
+// saves it and records the fetch in news_fetch_log
+func (s *NewsService) FetchFromSource(ctx context.Context, source NewsSource, symbol string, limit int) ([]NewsArticle, error) {
 	startTime := time.Now()
-	
-	if limit <= 0 {
-		limit = 30
-	}
-	if limit > 100 {
-		limit = 100
-	}
 
-	// Cnyes general Taiwan stock news
-	url := fmt.Sprintf("https://api.cnyes.com/media/api/v1/newslist/category/tw_stock?limit=%d", limit)
-
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
-	if err != nil {
-		s.logFetch("cnyes", nil, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	var articles []NewsArticle
+	var err error
+	if symbol == "" {
+		articles, err = source.FetchGeneral(ctx, limit)
+	} else {
+		articles, err = source.FetchForSymbol(ctx, symbol, limit)
 	}
-
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Origin", "https://www.cnyes.com")
-	req.Header.Set("Referer", "https://www.cnyes.com/")
-
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Do(req)
 	if err != nil {
-		s.logFetch("cnyes", nil, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to fetch news: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		s.logFetch("cnyes", nil, 0, 0, "failed", fmt.Sprintf("HTTP %d", resp.StatusCode), time.Since(startTime))
-		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+		s.logFetch(source.Name(), &symbol, 0, 0, "failed", err.Error(), time.Since(startTime))
+		return nil, fmt.Errorf("%s: %w", source.Name(), err)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	// Save to database
+	newCount, err := s.SaveArticles(ctx, articles)
 	if err != nil {
-		s.logFetch("cnyes", nil, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to read response: %w", err)
-	}
-
-	var cnyesResp CnyesNewsResponse
-	if err := json.Unmarshal(body, &cnyesResp); err != nil {
-		s.logFetch("cnyes", nil, 0, 0, "failed", err.Error(), time.Since(startTime))
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+		s.logFetch(source.Name(), &symbol, len(articles), 0, "partial", err.Error(), time.Since(startTime))
+		return articles, err
 	}
 
-	// Convert to NewsArticle
-	articles := make([]NewsArticle, 0, len(cnyesResp.Items.Data))
-	for _, item := range cnyesResp.Items.Data {
-		// Skip paid articles
-		if item.Payment == 1 {
-			continue
-		}
-
-		// Try to extract stock symbol from title/content
-		symbol := extractStockSymbol(item.Title + " " + item.Content)
-
-		sourceURL := fmt.Sprintf("https://news.cnyes.com/news/id/%d", item.NewsID)
-
-		// Extract summary from content (first 200 chars)
-		summary := cleanHTML(item.Content)
-		if len(summary) > 200 {
-			summary = summary[:200] + "..."
-		}
+	s.logFetch(source.Name(), &symbol, len(articles), newCount, "success", "", time.Since(startTime))
+	return articles, nil
+}
 
-		article := NewsArticle{
-			Symbol:      symbol,
-			Title:       item.Title,
-			Summary:     summary,
-			Content:     cleanHTML(item.Content),
-			Source:      "cnyes",
-			SourceURL:   sourceURL,
-			PublishedAt: time.Unix(item.PublishAt, 0),
-			FetchedAt:   time.Now(),
-			Category:    "台股",
-			Tags:        item.Keyword,
+// EnabledSources returns the sources enabled via NEWS_SOURCE_<NAME>_ENABLED
+func (s *NewsService) EnabledSources() []NewsSource {
+	var enabled []NewsSource
+	for _, source := range s.sources {
+		if newsSourceEnabled(source.Name()) {
+			enabled = append(enabled, source)
 		}
-		articles = append(articles, article)
 	}
+	return enabled
+}
 
-	newCount, err := s.SaveArticles(ctx, articles)
-	if err != nil {
-		s.logFetch("cnyes", nil, len(articles), 0, "partial", err.Error(), time.Since(startTime))
-		return articles, err
+// ListSources describes every registered source
+func (s *NewsService) ListSources() []NewsSourceInfo {
+	infos := make([]NewsSourceInfo, 0, len(s.sources))
+	for _, source := range s.sources {
+		infos = append(infos, NewsSourceInfo{
+			Name:         source.Name(),
+			Enabled:      newsSourceEnabled(source.Name()),
+			SymbolSearch: source.SupportsSymbolSearch(),
+			EnableEnvVar: newsSourceEnvVar(source.Name()),
+		})
 	}
-
-	s.logFetch("cnyes", nil, len(articles), newCount, "success", "", time.Since(startTime))
-	return articles, nil
+	return infos
 }
 
 // SaveArticles saves articles to database, returning count of new articles
@@ -256,14 +187,34 @@ func (s *NewsService) SaveArticles(ctx context.Context, articles []NewsArticle)
 		return 0, nil
 	}
 
-	newCount := 0
+	newCount, linked := 0, 0
+	var newIDs []string
 	for _, article := range articles {
+		if s.articleKnown(ctx, article) {
+			continue
+		}
+
+		// The same story from another outlet is linked to the stored copy instead of saved again.
+		// Filings are exempt: different companies often file under identical subjects.
+		if article.Source != "mops" {
+			if canonicalID, matchType, similarity := s.findCanonical(ctx, article); canonicalID != "" {
+				s.recordDuplicate(ctx, canonicalID, matchType, similarity, article)
+				// The copy may mention stocks the canonical article does not
+				s.tagArticleSymbols(ctx, canonicalID, article)
+				linked++
+				continue
+			}
+		}
+
 		id := uuid.New().String()
+		if article.Language == "" {
+			article.Language = detectLanguage(article.Title + " " + article.Content)
+		}
 		
 		// Use INSERT ... ON CONFLICT
 		query := `
-			INSERT INTO stock_news (id, symbol, title, summary, content, source, source_url, published_at, fetched_at, category, tags)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			INSERT INTO stock_news (id, symbol, title, summary, content, source, source_url, published_at, fetched_at, category, tags, language)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, NULLIF($12, ''))
 			ON CONFLICT (source, source_url) DO NOTHING
 		`
 		
@@ -280,6 +231,7 @@ func (s *NewsService) SaveArticles(ctx context.Context, articles []NewsArticle)
 			article.FetchedAt,
 			article.Category,
 			pq.Array(article.Tags),
+			article.Language,
 		)
 		if err != nil {
 			// Log error but continue with other records
@@ -288,14 +240,23 @@ func (s *NewsService) SaveArticles(ctx context.Context, articles []NewsArticle)
 		rowsAffected, _ := result.RowsAffected()
 		if rowsAffected > 0 {
 			newCount++
+			newIDs = append(newIDs, id)
+			s.tagArticleSymbols(ctx, id, article)
 		}
 	}
 
+	if newCount > 0 || linked > 0 {
+		s.invalidateNewsCache(ctx)
+	}
+	s.sentimentQueue.Enqueue(newIDs...)
+
 	return newCount, nil
 }
 
-// GetNewsForSymbol retrieves news for a symbol from database
-func (s *NewsService) GetNewsForSymbol(ctx context.Context, symbol string, limit int, offset int) ([]NewsArticle, error) {
+// GetNewsForSymbol retrieves every article tagged with the symbol, including
+// multi-stock stories whose primary symbol is another ticker. An empty language
+// returns articles in every language.
+func (s *NewsService) GetNewsForSymbol(ctx context.Context, symbol, language string, limit int, offset int) ([]NewsArticle, error) {
 	if limit <= 0 {
 		limit = 20
 	}
@@ -303,16 +264,31 @@ func (s *NewsService) GetNewsForSymbol(ctx context.Context, symbol string, limit
 		limit = 100
 	}
 
+	// Only the first page is cached; deeper pages are rarely polled
+	cacheKey, cacheable := "", false
+	if offset == 0 {
+		cacheKey, cacheable = s.newsCacheKey(ctx, "symbol", []interface{}{symbol, language, limit})
+		var cached []NewsArticle
+		if cacheable && s.getNewsCache(ctx, cacheKey, &cached) {
+			return cached, nil
+		}
+	}
+
 	query := `
-		SELECT id, symbol, title, summary, source, source_url, published_at, fetched_at, 
-		       sentiment, sentiment_score, category, tags
-		FROM stock_news
-		WHERE symbol = $1
-		ORDER BY published_at DESC
-		LIMIT $2 OFFSET $3
+		SELECT n.id, COALESCE(n.symbol, ''), n.title, COALESCE(n.summary, ''), n.source, n.source_url, n.published_at, n.fetched_at,
+		       COALESCE(n.sentiment, ''), n.sentiment_score, COALESCE(n.category, ''), n.tags,
+		       (SELECT COUNT(*) FROM news_duplicates d WHERE d.canonical_id = n.id),
+		       ARRAY(SELECT s.symbol FROM article_symbols s WHERE s.article_id = n.id ORDER BY s.symbol),
+		       COALESCE(n.language, ''), COALESCE(n.sentiment_method, ''), n.sentiment_confidence
+		FROM article_symbols tagged
+		JOIN stock_news n ON n.id = tagged.article_id
+		WHERE tagged.symbol = $1
+		  AND ($2 = '' OR n.language = $2)
+		ORDER BY n.published_at DESC
+		LIMIT $3 OFFSET $4
 	`
 
-	rows, err := s.db.QueryContext(ctx, query, symbol, limit, offset)
+	rows, err := s.db.QueryContext(ctx, query, symbol, language, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -326,6 +302,7 @@ func (s *NewsService) GetNewsForSymbol(ctx context.Context, symbol string, limit
 		err := rows.Scan(
 			&a.ID, &a.Symbol, &a.Title, &a.Summary, &a.Source, &a.SourceURL,
 			&a.PublishedAt, &a.FetchedAt, &a.Sentiment, &sentimentScore, &a.Category, pq.Array(&a.Tags),
+			&a.DuplicateCount, pq.Array(&a.Symbols), &a.Language, &a.SentimentMethod, &a.SentimentConfidence,
 		)
 		if err != nil {
 			continue
@@ -334,33 +311,107 @@ func (s *NewsService) GetNewsForSymbol(ctx context.Context, symbol string, limit
 		articles = append(articles, a)
 	}
 
+	if cacheable {
+		s.setNewsCache(ctx, cacheKey, articles)
+	}
 	return articles, nil
 }
 
-// GetRecentNews retrieves recent news across all symbols
-func (s *NewsService) GetRecentNews(ctx context.Context, limit int) ([]NewsArticle, error) {
-	if limit <= 0 {
-		limit = 30
+// NewsFilter narrows and pages the news list. Cursor, when set, takes precedence
+// over Offset and continues after the last article of the previous page.
+type NewsFilter struct {
+	Source    string
+	Category  string
+	Sentiment string
+	Language  string
+	MinScore  *float64
+	From      *time.Time
+	To        *time.Time
+	Limit     int
+	Offset    int
+	Cursor    string
+}
+
+// NewsPage is one page of the news list
+type NewsPage struct {
+	Articles   []NewsArticle `json:"news"`
+	Total      int           `json:"total"`
+	Limit      int           `json:"limit"`
+	Offset     int           `json:"offset"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+// GetRecentNews retrieves recent news across all symbols, newest first
+func (s *NewsService) GetRecentNews(ctx context.Context, filter NewsFilter) (*NewsPage, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = 30
 	}
-	if limit > 100 {
-		limit = 100
+	if filter.Limit > 100 {
+		filter.Limit = 100
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	var cursorTime *time.Time
+	var cursorID string
+	if filter.Cursor != "" {
+		t, id, err := decodeNewsCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cursorTime, cursorID = &t, id
+		filter.Offset = 0
+	}
+
+	cacheKey, cacheable := "", false
+	if filter.Offset == 0 && filter.Cursor == "" {
+		cacheKey, cacheable = s.newsCacheKey(ctx, "list", filter)
+		var cached NewsPage
+		if cacheable && s.getNewsCache(ctx, cacheKey, &cached) {
+			return &cached, nil
+		}
+	}
+
+	// $1-$7 are shared by the count and page queries
+	where := `
+		WHERE ($1 = '' OR source = $1)
+		  AND ($2 = '' OR category = $2)
+		  AND ($3 = '' OR sentiment = $3)
+		  AND ($4::numeric IS NULL OR sentiment_score >= $4)
+		  AND ($5::timestamptz IS NULL OR published_at >= $5)
+		  AND ($6::timestamptz IS NULL OR published_at <= $6)
+		  AND ($7 = '' OR language = $7)
+	`
+	args := []interface{}{filter.Source, filter.Category, filter.Sentiment, filter.MinScore, filter.From, filter.To, filter.Language}
+
+	page := &NewsPage{Articles: []NewsArticle{}, Limit: filter.Limit, Offset: filter.Offset}
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_news`+where, args...).Scan(&page.Total); err != nil {
+		return nil, fmt.Errorf("failed to count news: %w", err)
 	}
 
 	query := `
-		SELECT id, symbol, title, summary, source, source_url, published_at, fetched_at,
-		       sentiment, sentiment_score, category, tags
-		FROM stock_news
-		ORDER BY published_at DESC
-		LIMIT $1
+		SELECT id, COALESCE(symbol, ''), title, COALESCE(summary, ''), source, source_url, published_at, fetched_at,
+		       COALESCE(sentiment, ''), sentiment_score, COALESCE(category, ''), tags,
+		       (SELECT COUNT(*) FROM news_duplicates d WHERE d.canonical_id = stock_news.id),
+		       ARRAY(SELECT s.symbol FROM article_symbols s WHERE s.article_id = stock_news.id ORDER BY s.symbol),
+		       COALESCE(language, ''), COALESCE(sentiment_method, ''), sentiment_confidence
+		FROM stock_news` + where + `
+		  AND ($8::timestamptz IS NULL OR (published_at, id) < ($8, $9::uuid))
+		ORDER BY published_at DESC, id DESC
+		LIMIT $10 OFFSET $11
 	`
+	if cursorID == "" {
+		cursorID = "00000000-0000-0000-0000-000000000000"
+	}
+	args = append(args, cursorTime, cursorID, filter.Limit, filter.Offset)
 
-	rows, err := s.db.QueryContext(ctx, query, limit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to query news: %w", err)
 	}
 	defer rows.Close()
 
-	var articles []NewsArticle
 	for rows.Next() {
 		var a NewsArticle
 		var sentimentScore *float64
@@ -368,15 +419,79 @@ func (s *NewsService) GetRecentNews(ctx context.Context, limit int) ([]NewsArtic
 		err := rows.Scan(
 			&a.ID, &a.Symbol, &a.Title, &a.Summary, &a.Source, &a.SourceURL,
 			&a.PublishedAt, &a.FetchedAt, &a.Sentiment, &sentimentScore, &a.Category, pq.Array(&a.Tags),
+			&a.DuplicateCount, pq.Array(&a.Symbols), &a.Language, &a.SentimentMethod, &a.SentimentConfidence,
 		)
 		if err != nil {
 			continue
 		}
 		a.SentimentScore = sentimentScore
-		articles = append(articles, a)
+		page.Articles = append(page.Articles, a)
 	}
 
-	return articles, nil
+	if len(page.Articles) == filter.Limit {
+		last := page.Articles[len(page.Articles)-1]
+		page.NextCursor = encodeNewsCursor(last.PublishedAt, last.ID)
+	}
+
+	if cacheable {
+		s.setNewsCache(ctx, cacheKey, page)
+	}
+	return page, nil
+}
+
+// encodeNewsCursor packs the sort key of the last article on a page
+func encodeNewsCursor(publishedAt time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(publishedAt.UTC().Format(time.RFC3339Nano) + "|" + id))
+}
+
+func decodeNewsCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	if _, err := uuid.Parse(parts[1]); err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	return t, parts[1], nil
+}
+
+// PortfolioSymbols returns the symbols currently held in a portfolio, without the
+// ledger's market suffix
+func (s *NewsService) PortfolioSymbols(ctx context.Context, portfolioID string) ([]string, error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM portfolios WHERE id = $1)`, portfolioID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to query portfolio: %w", err)
+	}
+	if !exists {
+		return nil, fmt.Errorf("portfolio not found")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT split_part(symbol, '.', 1) FROM positions_current
+		WHERE portfolio_id = $1 AND total_quantity > 0
+		ORDER BY 1
+	`, portfolioID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holdings: %w", err)
+	}
+	defer rows.Close()
+
+	symbols := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols, nil
 }
 
 // logFetch logs a fetch operation
@@ -396,6 +511,39 @@ func (s *NewsService) logFetch(source string, symbol *string, found, newCount in
 
 // Helper functions
 
+// summaryLength is the maximum summary length in runes: NEWS_SUMMARY_LENGTH (default 200)
+func summaryLength() int {
+	if v, err := strconv.Atoi(os.Getenv("NEWS_SUMMARY_LENGTH")); err == nil && v > 0 {
+		return v
+	}
+	return 200
+}
+
+// summarize shortens text to at most maxRunes runes. It prefers to end on a sentence
+// boundary (。！？!?；) in the second half of the limit, and otherwise cuts at the
+// limit and appends an ellipsis. Never splits a multi-byte character.
+func summarize(text string, maxRunes int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= maxRunes {
+		return text
+	}
+
+	cut := runes[:maxRunes]
+	for i := len(cut) - 1; i >= maxRunes/2; i-- {
+		switch cut[i] {
+		case '。', '！', '？', '；', '!', '?':
+			return string(cut[:i+1])
+		case '.':
+			// An ASCII period ends a sentence only before a space, not inside "3.5"
+			if i+1 < len(runes) && runes[i+1] == ' ' {
+				return string(cut[:i+1])
+			}
+		}
+	}
+	return strings.TrimSpace(string(cut)) + "…"
+}
+
 // cleanHTML removes HTML tags from text
 func cleanHTML(s string) string {
 	// Remove HTML tags
@@ -0,0 +1,382 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// NewsSource is a news provider adapter. Sources without per-symbol search only
+// contribute general news, with symbols extracted from the article text.
+type NewsSource interface {
+	Name() string
+	SupportsSymbolSearch() bool
+	FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error)
+	FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error)
+}
+
+// NewsSourceInfo describes a registered source
+type NewsSourceInfo struct {
+	Name         string `json:"name"`
+	Enabled      bool   `json:"enabled"`
+	SymbolSearch bool   `json:"symbol_search"`
+	EnableEnvVar string `json:"enable_env_var"`
+}
+
+// defaultNewsSources returns every built-in adapter
+func defaultNewsSources() []NewsSource {
+	return []NewsSource{
+		&cnyesSource{},
+		&yahooSource{},
+		&udnSource{},
+		&mopsSource{},
+		&taipeiTimesSource{},
+	}
+}
+
+// newsSourceEnabled reads NEWS_SOURCE_<NAME>_ENABLED (default true)
+func newsSourceEnabled(name string) bool {
+	return !strings.EqualFold(os.Getenv(newsSourceEnvVar(name)), "false")
+}
+
+func newsSourceEnvVar(name string) string {
+	return "NEWS_SOURCE_" + strings.ToUpper(name) + "_ENABLED"
+}
+
+// fetchNewsBody performs a GET with browser-like headers and returns the body
+func fetchNewsBody(ctx context.Context, rawURL string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	client := &http.Client{Timeout: 15 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch news: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return body, nil
+}
+
+// ================================================
+// Cnyes (鉅亨網)
+// ================================================
+
+type cnyesSource struct{}
+
+func (c *cnyesSource) Name() string { return "cnyes" }
+
+func (c *cnyesSource) SupportsSymbolSearch() bool { return true }
+
+func (c *cnyesSource) FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	// Cnyes search API - search by stock code
+	apiURL := fmt.Sprintf("https://api.cnyes.com/media/api/v1/search?q=%s&limit=%d", symbol, limit)
+	items, err := c.fetch(ctx, apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	articles := make([]NewsArticle, 0, len(items))
+	for _, item := range items {
+		articles = append(articles, c.toArticle(item, symbol, "股票"))
+	}
+	return articles, nil
+}
+
+func (c *cnyesSource) FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error) {
+	// Cnyes general Taiwan stock news
+	apiURL := fmt.Sprintf("https://api.cnyes.com/media/api/v1/newslist/category/tw_stock?limit=%d", limit)
+	items, err := c.fetch(ctx, apiURL)
+	if err != nil {
+		return nil, err
+	}
+
+	articles := make([]NewsArticle, 0, len(items))
+	for _, item := range items {
+		// Try to extract stock symbol from title/content
+		symbol := extractStockSymbol(item.Title + " " + item.Content)
+		articles = append(articles, c.toArticle(item, symbol, "台股"))
+	}
+	return articles, nil
+}
+
+// fetch calls a Cnyes endpoint and returns its free (non-paid) items
+func (c *cnyesSource) fetch(ctx context.Context, apiURL string) ([]CnyesNewsItem, error) {
+	body, err := fetchNewsBody(ctx, apiURL, map[string]string{
+		"Accept":  "application/json",
+		"Origin":  "https://www.cnyes.com",
+		"Referer": "https://www.cnyes.com/",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var cnyesResp CnyesNewsResponse
+	if err := json.Unmarshal(body, &cnyesResp); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	items := make([]CnyesNewsItem, 0, len(cnyesResp.Items.Data))
+	for _, item := range cnyesResp.Items.Data {
+		// Skip paid articles
+		if item.Payment == 1 {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (c *cnyesSource) toArticle(item CnyesNewsItem, symbol, category string) NewsArticle {
+	content := cleanHTML(item.Content)
+
+	return NewsArticle{
+		Symbol:      symbol,
+		Title:       item.Title,
+		Summary:     summarize(content, summaryLength()),
+		Content:     content,
+		Source:      "cnyes",
+		SourceURL:   fmt.Sprintf("https://news.cnyes.com/news/id/%d", item.NewsID),
+		PublishedAt: time.Unix(item.PublishAt, 0),
+		FetchedAt:   time.Now(),
+		Category:    category,
+		Tags:        item.Keyword,
+	}
+}
+
+// ================================================
+// Yahoo奇摩股市 (RSS)
+// ================================================
+
+type yahooSource struct{}
+
+func (y *yahooSource) Name() string { return "yahoo" }
+
+func (y *yahooSource) SupportsSymbolSearch() bool { return true }
+
+func (y *yahooSource) FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	feedURL := "https://tw.stock.yahoo.com/rss?s=" + url.QueryEscape(symbol)
+	items, err := fetchRSSItems(ctx, feedURL)
+	if err != nil {
+		return nil, err
+	}
+	return rssToArticles(items, "yahoo", "股票", symbol, limit), nil
+}
+
+func (y *yahooSource) FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error) {
+	items, err := fetchRSSItems(ctx, "https://tw.stock.yahoo.com/rss?category=tw-market")
+	if err != nil {
+		return nil, err
+	}
+	return rssToArticles(items, "yahoo", "台股", "", limit), nil
+}
+
+// ================================================
+// 經濟日報 money.udn.com (RSS)
+// ================================================
+
+type udnSource struct{}
+
+func (u *udnSource) Name() string { return "udn" }
+
+// SupportsSymbolSearch is false: UDN only publishes section feeds
+func (u *udnSource) SupportsSymbolSearch() bool { return false }
+
+func (u *udnSource) FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	return nil, nil
+}
+
+func (u *udnSource) FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error) {
+	// 證券 > 台股 section
+	items, err := fetchRSSItems(ctx, "https://money.udn.com/rssfeed/news/1001/5590?ch=money")
+	if err != nil {
+		return nil, err
+	}
+	return rssToArticles(items, "udn", "台股", "", limit), nil
+}
+
+// ================================================
+// Taipei Times business (RSS, English)
+// ================================================
+
+type taipeiTimesSource struct{}
+
+func (t *taipeiTimesSource) Name() string { return "taipeitimes" }
+
+// SupportsSymbolSearch is false: only the business section feed is published
+func (t *taipeiTimesSource) SupportsSymbolSearch() bool { return false }
+
+func (t *taipeiTimesSource) FetchForSymbol(ctx context.Context, symbol string, limit int) ([]NewsArticle, error) {
+	return nil, nil
+}
+
+func (t *taipeiTimesSource) FetchGeneral(ctx context.Context, limit int) ([]NewsArticle, error) {
+	items, err := fetchRSSItems(ctx, "https://www.taipeitimes.com/xml/biz.rss")
+	if err != nil {
+		return nil, err
+	}
+	articles := rssToArticles(items, "taipeitimes", "International", "", limit)
+	for i := range articles {
+		articles[i].Language = NewsLanguageEnglish
+	}
+	return articles, nil
+}
+
+// ================================================
+// RSS helpers
+// ================================================
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Description string   `xml:"description"`
+	PubDate     string   `xml:"pubDate"`
+	Categories  []string `xml:"category"`
+}
+
+type rssDocument struct {
+	Channel struct {
+		Items []rssItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type atomDocument struct {
+	Entries []struct {
+		Title string `xml:"title"`
+		Links []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+		} `xml:"link"`
+		Summary    string `xml:"summary"`
+		Content    string `xml:"content"`
+		Published  string `xml:"published"`
+		Updated    string `xml:"updated"`
+		Categories []struct {
+			Term string `xml:"term,attr"`
+		} `xml:"category"`
+	} `xml:"entry"`
+}
+
+// fetchRSSItems fetches an RSS 2.0 or Atom feed and normalises its entries to rssItem
+func fetchRSSItems(ctx context.Context, feedURL string) ([]rssItem, error) {
+	body, err := fetchNewsBody(ctx, feedURL, map[string]string{
+		"Accept": "application/rss+xml, application/atom+xml, application/xml, text/xml",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return parseFeed(body)
+}
+
+func parseFeed(body []byte) ([]rssItem, error) {
+	var doc rssDocument
+	if err := xml.Unmarshal(body, &doc); err == nil && len(doc.Channel.Items) > 0 {
+		return doc.Channel.Items, nil
+	}
+
+	var atom atomDocument
+	if err := xml.Unmarshal(body, &atom); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	items := make([]rssItem, 0, len(atom.Entries))
+	for _, entry := range atom.Entries {
+		item := rssItem{
+			Title:       entry.Title,
+			Description: entry.Summary,
+			PubDate:     entry.Published,
+		}
+		if item.Description == "" {
+			item.Description = entry.Content
+		}
+		if item.PubDate == "" {
+			item.PubDate = entry.Updated
+		}
+		for _, link := range entry.Links {
+			if link.Rel == "" || link.Rel == "alternate" {
+				item.Link = link.Href
+				break
+			}
+		}
+		for _, c := range entry.Categories {
+			item.Categories = append(item.Categories, c.Term)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// rssToArticles converts feed items; when symbol is empty it is extracted from the text
+func rssToArticles(items []rssItem, source, category, symbol string, limit int) []NewsArticle {
+	articles := make([]NewsArticle, 0, len(items))
+	for _, item := range items {
+		if limit > 0 && len(articles) >= limit {
+			break
+		}
+		link := strings.TrimSpace(item.Link)
+		if link == "" || strings.TrimSpace(item.Title) == "" {
+			continue
+		}
+
+		content := cleanHTML(item.Description)
+
+		articleSymbol := symbol
+		if articleSymbol == "" {
+			articleSymbol = extractStockSymbol(item.Title + " " + content)
+		}
+
+		articles = append(articles, NewsArticle{
+			Symbol:      articleSymbol,
+			Title:       strings.TrimSpace(item.Title),
+			Summary:     summarize(content, summaryLength()),
+			Content:     content,
+			Source:      source,
+			SourceURL:   link,
+			PublishedAt: parseFeedTime(item.PubDate),
+			FetchedAt:   time.Now(),
+			Category:    category,
+			Tags:        item.Categories,
+		})
+	}
+	return articles
+}
+
+// parseFeedTime parses RSS/Atom timestamps, falling back to now
+func parseFeedTime(value string) time.Time {
+	value = strings.TrimSpace(value)
+	layouts := []string{
+		time.RFC1123Z,
+		time.RFC1123,
+		time.RFC3339,
+		"Mon, 2 Jan 2006 15:04:05 -0700",
+		"Mon, 2 Jan 2006 15:04:05 MST",
+		"2006-01-02 15:04:05",
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// NewsSourceStats summarises news_fetch_log for one source
+type NewsSourceStats struct {
+	Source              string     `json:"source"`
+	Fetches             int        `json:"fetches"`
+	Succeeded           int        `json:"succeeded"`
+	Failed              int        `json:"failed"`
+	Partial             int        `json:"partial"`
+	SuccessRate         float64    `json:"success_rate"` // Percent
+	ArticlesFound       int        `json:"articles_found"`
+	ArticlesNew         int        `json:"articles_new"`
+	AvgDurationMs       float64    `json:"avg_duration_ms"`
+	LastFetchAt         *time.Time `json:"last_fetch_at,omitempty"`
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty"`
+	LastNewArticleAt    *time.Time `json:"last_new_article_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	Health              string     `json:"health"` // healthy, degraded, failing, idle
+}
+
+// GetSourceStats aggregates fetch outcomes per source over the window. A source whose
+// recent fetches all fail is "failing"; one that succeeds but has found nothing new in
+// a day is "degraded", which is how a silently broken scraper usually shows up.
+func (s *NewsService) GetSourceStats(ctx context.Context, window time.Duration) ([]NewsSourceStats, error) {
+	query := `
+		WITH windowed AS (
+			SELECT * FROM news_fetch_log WHERE fetched_at >= $1
+		),
+		last_ok AS (
+			SELECT source, MAX(fetched_at) AS at FROM news_fetch_log WHERE status = 'success' GROUP BY source
+		)
+		SELECT w.source,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE w.status = 'success'),
+		       COUNT(*) FILTER (WHERE w.status = 'failed'),
+		       COUNT(*) FILTER (WHERE w.status = 'partial'),
+		       COALESCE(SUM(w.articles_found), 0),
+		       COALESCE(SUM(w.articles_new), 0),
+		       COALESCE(AVG(w.duration_ms), 0),
+		       MAX(w.fetched_at),
+		       MAX(lo.at),
+		       MAX(w.fetched_at) FILTER (WHERE w.articles_new > 0),
+		       (ARRAY_AGG(w.error_message ORDER BY w.fetched_at DESC) FILTER (WHERE w.error_message IS NOT NULL AND w.error_message <> ''))[1],
+		       MAX(w.fetched_at) FILTER (WHERE w.error_message IS NOT NULL AND w.error_message <> ''),
+		       (SELECT COUNT(*) FROM news_fetch_log f
+		        WHERE f.source = w.source AND f.status = 'failed'
+		          AND f.fetched_at > COALESCE(MAX(lo.at), '-infinity'::timestamptz))
+		FROM windowed w
+		LEFT JOIN last_ok lo ON lo.source = w.source
+		GROUP BY w.source
+		ORDER BY w.source
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, time.Now().Add(-window))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query fetch log: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []NewsSourceStats{}
+	for rows.Next() {
+		var st NewsSourceStats
+		var lastError *string
+		if err := rows.Scan(
+			&st.Source, &st.Fetches, &st.Succeeded, &st.Failed, &st.Partial,
+			&st.ArticlesFound, &st.ArticlesNew, &st.AvgDurationMs,
+			&st.LastFetchAt, &st.LastSuccessAt, &st.LastNewArticleAt,
+			&lastError, &st.LastErrorAt, &st.ConsecutiveFailures,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan source stats: %w", err)
+		}
+		if lastError != nil {
+			st.LastError = *lastError
+		}
+		if st.Fetches > 0 {
+			st.SuccessRate = roundTo(float64(st.Succeeded)/float64(st.Fetches)*100, 1)
+		}
+		st.AvgDurationMs = roundTo(st.AvgDurationMs, 0)
+		st.Health = sourceHealth(st)
+		stats = append(stats, st)
+	}
+	return stats, nil
+}
+
+func sourceHealth(st NewsSourceStats) string {
+	switch {
+	case st.Fetches == 0:
+		return "idle"
+	case st.ConsecutiveFailures >= 3:
+		return "failing"
+	case st.LastNewArticleAt == nil || time.Since(*st.LastNewArticleAt) > 24*time.Hour:
+		return "degraded"
+	case st.SuccessRate < 80:
+		return "degraded"
+	default:
+		return "healthy"
+	}
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+)
+
+// tagArticleSymbols links an article to its primary symbol and to every listed stock
+// it mentions, by code (ExtractStockMentions) or by company name
+func (s *NewsService) tagArticleSymbols(ctx context.Context, articleID string, article NewsArticle) {
+	if article.Symbol != "" {
+		s.db.ExecContext(ctx, `
+			INSERT INTO article_symbols (article_id, symbol, match_type) VALUES ($1, $2, 'primary')
+			ON CONFLICT DO NOTHING
+		`, articleID, article.Symbol)
+	}
+
+	text := article.Title + " " + article.Content
+	if codes := ExtractStockMentions(text); len(codes) > 0 {
+		// Four-digit numbers are often years or amounts; keep only listed codes
+		s.db.ExecContext(ctx, `
+			INSERT INTO article_symbols (article_id, symbol, match_type)
+			SELECT $1, symbol, 'code' FROM taiwan_stocks WHERE symbol = ANY($2)
+			ON CONFLICT DO NOTHING
+		`, articleID, pq.Array(codes))
+	}
+
+	if s.nameMatcher != nil {
+		if names := s.nameMatcher.matchAll(ctx, text); len(names) > 0 {
+			s.db.ExecContext(ctx, `
+				INSERT INTO article_symbols (article_id, symbol, match_type)
+				SELECT $1, unnest($2::text[]), 'name'
+				ON CONFLICT DO NOTHING
+			`, articleID, pq.Array(names))
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// TrendingTopic is a tag/keyword ranked by how many articles carried it
+type TrendingTopic struct {
+	Keyword       string  `json:"keyword"`
+	Articles      int     `json:"articles"`
+	PreviousCount int     `json:"previous_count"` // Same-length window immediately before
+	Growth        float64 `json:"growth"`         // Articles / previous count; 0 when new
+}
+
+// TrendingSymbol is a stock ranked by how many articles mentioned it
+type TrendingSymbol struct {
+	Symbol        string     `json:"symbol"`
+	Name          string     `json:"name,omitempty"`
+	Articles      int        `json:"articles"`
+	PreviousCount int        `json:"previous_count"`
+	AvgSentiment  *float64   `json:"avg_sentiment,omitempty"`
+	PositiveCount int        `json:"positive_count"`
+	NegativeCount int        `json:"negative_count"`
+	LatestArticle string     `json:"latest_article,omitempty"`
+	LatestAt      *time.Time `json:"latest_at,omitempty"`
+}
+
+// NewsTrending is the trending overview for one window
+type NewsTrending struct {
+	Window        string           `json:"window"`
+	Since         time.Time        `json:"since"`
+	TotalArticles int              `json:"total_articles"`
+	Topics        []TrendingTopic  `json:"topics"`
+	Symbols       []TrendingSymbol `json:"symbols"`
+}
+
+// GetTrending aggregates article tags and symbol mentions over the window
+// (typically 24h or 7d), compared with the window before it
+func (s *NewsService) GetTrending(ctx context.Context, window time.Duration, limit int) (*NewsTrending, error) {
+	if window <= 0 {
+		return nil, fmt.Errorf("window must be positive")
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	now := time.Now()
+	result := &NewsTrending{
+		Window:  formatWindow(window),
+		Since:   now.Add(-window),
+		Topics:  []TrendingTopic{},
+		Symbols: []TrendingSymbol{},
+	}
+	prevSince := now.Add(-2 * window)
+
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM stock_news WHERE published_at >= $1
+	`, result.Since).Scan(&result.TotalArticles); err != nil {
+		return nil, fmt.Errorf("failed to count articles: %w", err)
+	}
+
+	topicQuery := `
+		WITH tagged AS (
+			SELECT DISTINCT n.id, btrim(t.tag) AS keyword, n.published_at >= $1 AS is_current
+			FROM stock_news n
+			CROSS JOIN LATERAL unnest(n.tags) AS t(tag)
+			WHERE n.published_at >= $2 AND btrim(t.tag) <> ''
+		)
+		SELECT keyword,
+		       COUNT(*) FILTER (WHERE is_current) AS articles,
+		       COUNT(*) FILTER (WHERE NOT is_current) AS previous
+		FROM tagged
+		GROUP BY keyword
+		HAVING COUNT(*) FILTER (WHERE is_current) > 0
+		ORDER BY articles DESC, keyword
+		LIMIT $3
+	`
+	rows, err := s.db.QueryContext(ctx, topicQuery, result.Since, prevSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trending topics: %w", err)
+	}
+	for rows.Next() {
+		var t TrendingTopic
+		if err := rows.Scan(&t.Keyword, &t.Articles, &t.PreviousCount); err != nil {
+			continue
+		}
+		if t.PreviousCount > 0 {
+			t.Growth = roundTo(float64(t.Articles)/float64(t.PreviousCount), 2)
+		}
+		result.Topics = append(result.Topics, t)
+	}
+	rows.Close()
+
+	symbolQuery := `
+		WITH mentions AS (
+			SELECT a.symbol, n.id, n.title, n.published_at, n.sentiment, n.sentiment_score,
+			       n.published_at >= $1 AS is_current
+			FROM article_symbols a
+			JOIN stock_news n ON n.id = a.article_id
+			WHERE n.published_at >= $2
+		)
+		SELECT m.symbol, COALESCE(ts.name, ''),
+		       COUNT(*) FILTER (WHERE m.is_current) AS articles,
+		       COUNT(*) FILTER (WHERE NOT m.is_current) AS previous,
+		       AVG(m.sentiment_score) FILTER (WHERE m.is_current),
+		       COUNT(*) FILTER (WHERE m.is_current AND m.sentiment = 'positive'),
+		       COUNT(*) FILTER (WHERE m.is_current AND m.sentiment = 'negative'),
+		       (ARRAY_AGG(m.title ORDER BY m.published_at DESC) FILTER (WHERE m.is_current))[1],
+		       MAX(m.published_at) FILTER (WHERE m.is_current)
+		FROM mentions m
+		LEFT JOIN taiwan_stocks ts ON ts.symbol = m.symbol
+		GROUP BY m.symbol, ts.name
+		HAVING COUNT(*) FILTER (WHERE m.is_current) > 0
+		ORDER BY articles DESC, m.symbol
+		LIMIT $3
+	`
+	rows, err = s.db.QueryContext(ctx, symbolQuery, result.Since, prevSince, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trending symbols: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t TrendingSymbol
+		var latest *string
+		if err := rows.Scan(
+			&t.Symbol, &t.Name, &t.Articles, &t.PreviousCount, &t.AvgSentiment,
+			&t.PositiveCount, &t.NegativeCount, &latest, &t.LatestAt,
+		); err != nil {
+			continue
+		}
+		if latest != nil {
+			t.LatestArticle = *latest
+		}
+		if t.AvgSentiment != nil {
+			v := roundTo(*t.AvgSentiment, 4)
+			t.AvgSentiment = &v
+		}
+		result.Symbols = append(result.Symbols, t)
+	}
+
+	return result, nil
+}
+
+// formatWindow renders whole-day windows as "7d" and others as hours, e.g. "24h"
+func formatWindow(d time.Duration) string {
+	if d >= 48*time.Hour && d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", int(d/(24*time.Hour)))
+	}
+	return fmt.Sprintf("%dh", int(d/time.Hour))
+}
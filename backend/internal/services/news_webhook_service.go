@@ -0,0 +1,521 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	// Articles are delivered once they are this old, so symbol tagging has finished
+	newsWebhookSettle = 30 * time.Second
+	// A webhook is switched off after this many failed deliveries in a row
+	newsWebhookMaxFailures = 10
+)
+
+// NewsWebhookService delivers new articles for a user's held and watched symbols
+// to the webhook URLs they registered
+type NewsWebhookService struct {
+	db               *database.DB
+	sentimentService *SentimentService
+	watchlistService *WatchlistService
+	client           *http.Client
+	interval         time.Duration
+	mu               sync.Mutex
+	isRunning        bool
+	stopChan         chan struct{}
+}
+
+func NewNewsWebhookService(db *database.DB, sentimentService *SentimentService, watchlistService *WatchlistService) *NewsWebhookService {
+	return &NewsWebhookService{
+		db:               db,
+		sentimentService: sentimentService,
+		watchlistService: watchlistService,
+		client:           &http.Client{Timeout: 10 * time.Second},
+		interval:         time.Minute,
+		stopChan:         make(chan struct{}),
+	}
+}
+
+// NewsWebhook is a registered delivery endpoint
+type NewsWebhook struct {
+	ID                  string     `json:"id"`
+	UserID              uuid.UUID  `json:"user_id"`
+	URL                 string     `json:"url"`
+	HasSecret           bool       `json:"has_secret"`
+	Scope               string     `json:"scope"`
+	MinAbsSentiment     *float64   `json:"min_abs_sentiment,omitempty"`
+	IsActive            bool       `json:"is_active"`
+	LastArticleAt       time.Time  `json:"last_article_at"`
+	LastDeliveryAt      *time.Time `json:"last_delivery_at,omitempty"`
+	LastStatus          string     `json:"last_status,omitempty"`
+	LastError           string     `json:"last_error,omitempty"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	CreatedAt           time.Time  `json:"created_at"`
+
+	secret string
+}
+
+// NewsWebhookRequest is the payload for registering or updating a webhook
+type NewsWebhookRequest struct {
+	URL             string   `json:"url"`
+	Secret          *string  `json:"secret,omitempty"`
+	Scope           string   `json:"scope"`
+	MinAbsSentiment *float64 `json:"min_abs_sentiment,omitempty"`
+	IsActive        *bool    `json:"is_active,omitempty"`
+}
+
+// NewsWebhookPayload is the JSON body POSTed for each article
+type NewsWebhookPayload struct {
+	Event          string             `json:"event"`
+	WebhookID      string             `json:"webhook_id"`
+	MatchedSymbols []string           `json:"matched_symbols"`
+	Article        NewsWebhookArticle `json:"article"`
+	SentAt         time.Time          `json:"sent_at"`
+}
+
+// NewsWebhookEvent is the JSON body POSTed for events other than articles,
+// such as the AI daily digest
+type NewsWebhookEvent struct {
+	Event     string      `json:"event"`
+	WebhookID string      `json:"webhook_id"`
+	Data      interface{} `json:"data"`
+	SentAt    time.Time   `json:"sent_at"`
+}
+
+// NewsWebhookArticle is the article as delivered, including its sentiment
+type NewsWebhookArticle struct {
+	ID             string    `json:"id"`
+	Title          string    `json:"title"`
+	Summary        string    `json:"summary"`
+	Source         string    `json:"source"`
+	SourceURL      string    `json:"source_url"`
+	PublishedAt    time.Time `json:"published_at"`
+	Symbols        []string  `json:"symbols"`
+	Sentiment      string    `json:"sentiment"`
+	SentimentScore float64   `json:"sentiment_score"`
+
+	fetchedAt time.Time
+}
+
+func validateWebhookScope(scope string) (string, error) {
+	if scope == "" {
+		return "all", nil
+	}
+	switch scope {
+	case "all", "portfolio", "watchlist":
+		return scope, nil
+	}
+	return "", fmt.Errorf("scope must be all, portfolio or watchlist")
+}
+
+func validateWebhookURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http(s) URL")
+	}
+	return nil
+}
+
+// ListWebhooks returns a user's news webhooks
+func (s *NewsWebhookService) ListWebhooks(ctx context.Context, userID uuid.UUID) ([]NewsWebhook, error) {
+	return s.queryWebhooks(ctx, `WHERE user_id = $1 ORDER BY created_at`, userID)
+}
+
+// GetWebhook returns a single webhook
+func (s *NewsWebhookService) GetWebhook(ctx context.Context, id string) (*NewsWebhook, error) {
+	hooks, err := s.queryWebhooks(ctx, `WHERE id = $1`, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(hooks) == 0 {
+		return nil, fmt.Errorf("webhook not found")
+	}
+	return &hooks[0], nil
+}
+
+func (s *NewsWebhookService) queryWebhooks(ctx context.Context, where string, args ...interface{}) ([]NewsWebhook, error) {
+	query := `
+		SELECT id, user_id, url, COALESCE(secret, ''), scope, min_abs_sentiment, is_active,
+		       last_article_at, last_delivery_at, COALESCE(last_status, ''), COALESCE(last_error, ''),
+		       consecutive_failures, created_at
+		FROM news_webhooks
+	` + where
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []NewsWebhook{}
+	for rows.Next() {
+		var h NewsWebhook
+		var minAbs sql.NullFloat64
+		if err := rows.Scan(
+			&h.ID, &h.UserID, &h.URL, &h.secret, &h.Scope, &minAbs, &h.IsActive,
+			&h.LastArticleAt, &h.LastDeliveryAt, &h.LastStatus, &h.LastError,
+			&h.ConsecutiveFailures, &h.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		h.HasSecret = h.secret != ""
+		if minAbs.Valid {
+			h.MinAbsSentiment = &minAbs.Float64
+		}
+		hooks = append(hooks, h)
+	}
+	return hooks, nil
+}
+
+// CreateWebhook registers a webhook; delivery starts with articles stored from now on
+func (s *NewsWebhookService) CreateWebhook(ctx context.Context, userID uuid.UUID, req NewsWebhookRequest) (*NewsWebhook, error) {
+	req.URL = strings.TrimSpace(req.URL)
+	if err := validateWebhookURL(req.URL); err != nil {
+		return nil, err
+	}
+	scope, err := validateWebhookScope(req.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := ""
+	if req.Secret != nil {
+		secret = *req.Secret
+	}
+	active := true
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+
+	var id string
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO news_webhooks (user_id, url, secret, scope, min_abs_sentiment, is_active)
+		VALUES ($1, $2, NULLIF($3, ''), $4, $5, $6)
+		RETURNING id
+	`, userID, req.URL, secret, scope, req.MinAbsSentiment, active).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("webhook already exists: %s", req.URL)
+		}
+		return nil, fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return s.GetWebhook(ctx, id)
+}
+
+// UpdateWebhook changes a webhook's settings. Re-activating it clears its failure count.
+func (s *NewsWebhookService) UpdateWebhook(ctx context.Context, id string, req NewsWebhookRequest) (*NewsWebhook, error) {
+	req.URL = strings.TrimSpace(req.URL)
+	if req.URL != "" {
+		if err := validateWebhookURL(req.URL); err != nil {
+			return nil, err
+		}
+	}
+	if req.Scope != "" {
+		if _, err := validateWebhookScope(req.Scope); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE news_webhooks SET
+			url = COALESCE(NULLIF($2, ''), url),
+			secret = CASE WHEN $3::text IS NULL THEN secret ELSE NULLIF($3, '') END,
+			scope = COALESCE(NULLIF($4, ''), scope),
+			min_abs_sentiment = COALESCE($5, min_abs_sentiment),
+			is_active = COALESCE($6, is_active),
+			consecutive_failures = CASE WHEN $6 = true THEN 0 ELSE consecutive_failures END
+		WHERE id = $1
+	`, id, req.URL, req.Secret, req.Scope, req.MinAbsSentiment, req.IsActive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("webhook not found")
+	}
+
+	return s.GetWebhook(ctx, id)
+}
+
+// DeleteWebhook removes a webhook
+func (s *NewsWebhookService) DeleteWebhook(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM news_webhooks WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("webhook not found")
+	}
+	return nil
+}
+
+// TestWebhook sends a sample "ping" payload so integrations can be checked
+func (s *NewsWebhookService) TestWebhook(ctx context.Context, id string) error {
+	hook, err := s.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	payload := NewsWebhookPayload{
+		Event:          "ping",
+		WebhookID:      hook.ID,
+		MatchedSymbols: []string{"2330"},
+		Article: NewsWebhookArticle{
+			Title:     "Webhook test",
+			Source:    "psm",
+			Symbols:   []string{"2330"},
+			Sentiment: "neutral",
+		},
+		SentAt: time.Now(),
+	}
+	return s.post(ctx, hook, payload.Event, payload)
+}
+
+// NotifyUser delivers an event to every active webhook of a user, returning
+// how many deliveries succeeded. Failures count towards disabling the webhook.
+func (s *NewsWebhookService) NotifyUser(ctx context.Context, userID uuid.UUID, event string, data interface{}) (int, error) {
+	hooks, err := s.queryWebhooks(ctx, `WHERE user_id = $1 AND is_active = true`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	var lastErr error
+	for i := range hooks {
+		payload := NewsWebhookEvent{
+			Event:     event,
+			WebhookID: hooks[i].ID,
+			Data:      data,
+			SentAt:    time.Now(),
+		}
+		if err := s.post(ctx, &hooks[i], event, payload); err != nil {
+			s.recordFailure(ctx, &hooks[i], err)
+			lastErr = err
+			continue
+		}
+		delivered++
+	}
+	if delivered == 0 && lastErr != nil {
+		return 0, lastErr
+	}
+	return delivered, nil
+}
+
+// Start launches the delivery loop
+func (s *NewsWebhookService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	s.mu.Unlock()
+
+	go s.loop()
+	log.Printf("News webhook dispatcher started (every %v)", s.interval)
+}
+
+// Stop stops the delivery loop
+func (s *NewsWebhookService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+}
+
+func (s *NewsWebhookService) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+			s.DispatchPending(ctx)
+			cancel()
+		}
+	}
+}
+
+// DispatchPending delivers every undelivered article to each active webhook.
+// A failed delivery stops that webhook's batch; it is retried on the next pass.
+func (s *NewsWebhookService) DispatchPending(ctx context.Context) (int, error) {
+	hooks, err := s.queryWebhooks(ctx, `WHERE is_active = true`)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i := range hooks {
+		n, err := s.dispatchWebhook(ctx, &hooks[i])
+		delivered += n
+		if err != nil {
+			log.Printf("News webhook %s: %v", hooks[i].ID, err)
+		}
+	}
+	return delivered, nil
+}
+
+func (s *NewsWebhookService) dispatchWebhook(ctx context.Context, hook *NewsWebhook) (int, error) {
+	symbols, err := s.watchlistService.UserTrackedSymbols(ctx, hook.UserID, hook.Scope)
+	if err != nil || len(symbols) == 0 {
+		return 0, err
+	}
+
+	articles, matched, err := s.pendingArticles(ctx, hook, symbols)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for i, article := range articles {
+		// Sentiment is part of the payload; score articles the analyzer hasn't reached yet
+		if article.Sentiment == "" {
+			result, err := s.sentimentService.AnalyzeNewsArticle(ctx, article.ID)
+			if err == nil {
+				article.Sentiment = result.Sentiment
+				article.SentimentScore = result.Score
+			}
+		}
+
+		if hook.MinAbsSentiment == nil || abs(article.SentimentScore) >= *hook.MinAbsSentiment {
+			payload := NewsWebhookPayload{
+				Event:          "news.article",
+				WebhookID:      hook.ID,
+				MatchedSymbols: matched[i],
+				Article:        article,
+				SentAt:         time.Now(),
+			}
+			if err := s.post(ctx, hook, payload.Event, payload); err != nil {
+				s.recordFailure(ctx, hook, err)
+				return delivered, err
+			}
+			delivered++
+		}
+
+		// Advance past the article whether it was sent or filtered out
+		s.db.ExecContext(ctx, `
+			UPDATE news_webhooks SET last_article_at = $2, last_delivery_at = NOW(),
+				last_status = 'success', last_error = NULL, consecutive_failures = 0
+			WHERE id = $1
+		`, hook.ID, article.fetchedAt)
+	}
+	return delivered, nil
+}
+
+// pendingArticles returns articles stored after the webhook's watermark that mention
+// any of the symbols, oldest first, with the subset of symbols each one matched
+func (s *NewsWebhookService) pendingArticles(ctx context.Context, hook *NewsWebhook, symbols []string) ([]NewsWebhookArticle, [][]string, error) {
+	query := `
+		SELECT n.id, n.title, COALESCE(n.summary, ''), n.source, COALESCE(n.source_url, ''),
+		       n.published_at, n.fetched_at, COALESCE(n.sentiment, ''), COALESCE(n.sentiment_score, 0),
+		       ARRAY(SELECT s.symbol FROM article_symbols s WHERE s.article_id = n.id ORDER BY s.symbol),
+		       ARRAY(SELECT s.symbol FROM article_symbols s WHERE s.article_id = n.id AND s.symbol = ANY($2) ORDER BY s.symbol)
+		FROM stock_news n
+		WHERE n.fetched_at > $1
+		  AND n.fetched_at <= $3
+		  AND EXISTS (SELECT 1 FROM article_symbols s WHERE s.article_id = n.id AND s.symbol = ANY($2))
+		ORDER BY n.fetched_at ASC
+		LIMIT 50
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, hook.LastArticleAt, pq.Array(symbols), time.Now().Add(-newsWebhookSettle))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query pending articles: %w", err)
+	}
+	defer rows.Close()
+
+	var articles []NewsWebhookArticle
+	var matched [][]string
+	for rows.Next() {
+		var a NewsWebhookArticle
+		var m []string
+		if err := rows.Scan(
+			&a.ID, &a.Title, &a.Summary, &a.Source, &a.SourceURL,
+			&a.PublishedAt, &a.fetchedAt, &a.Sentiment, &a.SentimentScore,
+			pq.Array(&a.Symbols), pq.Array(&m),
+		); err != nil {
+			continue
+		}
+		articles = append(articles, a)
+		matched = append(matched, m)
+	}
+	return articles, matched, nil
+}
+
+// post sends the payload, signing it with HMAC-SHA256 when the webhook has a secret
+func (s *NewsWebhookService) post(ctx context.Context, hook *NewsWebhook, event string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "PSM-Webhook/1.0")
+	req.Header.Set("X-PSM-Event", event)
+	if hook.secret != "" {
+		req.Header.Set("X-PSM-Signature", signWebhookBody(hook.secret, body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("delivery failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (s *NewsWebhookService) recordFailure(ctx context.Context, hook *NewsWebhook, deliveryErr error) {
+	s.db.ExecContext(ctx, `
+		UPDATE news_webhooks SET
+			last_delivery_at = NOW(),
+			last_status = 'failed',
+			last_error = $2,
+			consecutive_failures = consecutive_failures + 1,
+			is_active = consecutive_failures + 1 < $3
+		WHERE id = $1
+	`, hook.ID, deliveryErr.Error(), newsWebhookMaxFailures)
+
+	if hook.ConsecutiveFailures+1 >= newsWebhookMaxFailures {
+		log.Printf("News webhook %s disabled after %d consecutive failures", hook.ID, newsWebhookMaxFailures)
+	}
+}
+
+// signWebhookBody returns the X-PSM-Signature header value: "sha256=" and the
+// hex HMAC-SHA256 of the body keyed with the webhook's secret
+func signWebhookBody(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,774 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"psm-backend/internal/database"
+	"psm-backend/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	paperBoardLot      = 1000
+	paperFeeRate       = 0.001425 // Broker commission
+	paperMinFee        = 20       // Minimum commission per order (TWD)
+	paperSellTaxRate   = 0.003    // Securities transaction tax, charged on sells
+	paperMatchInterval = 30 * time.Second
+)
+
+// paperSymbolPattern is what the ledger's valid_symbol constraint accepts
+// before the market suffix; ETFs like 00878, TDRs and preferred shares fail it
+var paperSymbolPattern = regexp.MustCompile(`^[0-9]{4}$`)
+
+// PaperTradingService runs simulated portfolios whose virtual orders are filled
+// against realtime quotes and recorded in the ledger
+type PaperTradingService struct {
+	db              *database.DB
+	ledgerService   *LedgerService
+	realtimeService *RealtimeService
+	orderMu         sync.Mutex // Serialises fills so cash and holdings checks stay consistent
+	mu              sync.Mutex
+	isRunning       bool
+	stopChan        chan struct{}
+}
+
+func NewPaperTradingService(db *database.DB, ledgerService *LedgerService, realtimeService *RealtimeService) *PaperTradingService {
+	return &PaperTradingService{
+		db:              db,
+		ledgerService:   ledgerService,
+		realtimeService: realtimeService,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// PaperPortfolio is a simulated portfolio
+type PaperPortfolio struct {
+	ID          uuid.UUID       `json:"id"`
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	InitialCash decimal.Decimal `json:"initial_cash"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+// CreatePaperPortfolioRequest is the payload for creating a paper portfolio
+type CreatePaperPortfolioRequest struct {
+	Name        string          `json:"name"`
+	Description *string         `json:"description,omitempty"`
+	InitialCash decimal.Decimal `json:"initial_cash"` // Default: 1,000,000
+}
+
+// PaperOrderRequest is the payload for placing a virtual order
+type PaperOrderRequest struct {
+	Symbol     string          `json:"symbol"`
+	Side       string          `json:"side"`       // BUY, SELL
+	OrderType  string          `json:"order_type"` // market (default), limit
+	Quantity   int64           `json:"quantity"`   // Shares; multiples of 1000 unless odd_lot
+	LimitPrice decimal.Decimal `json:"limit_price"`
+	OddLot     bool            `json:"odd_lot"` // 零股: 1-999 shares
+}
+
+// PaperOrder is a virtual order and its outcome
+type PaperOrder struct {
+	ID            uuid.UUID        `json:"id"`
+	PortfolioID   uuid.UUID        `json:"portfolio_id"`
+	Symbol        string           `json:"symbol"`
+	Side          string           `json:"side"`
+	OrderType     string           `json:"order_type"`
+	Quantity      int64            `json:"quantity"`
+	LimitPrice    *decimal.Decimal `json:"limit_price,omitempty"`
+	Status        string           `json:"status"` // pending, filled, rejected, cancelled, expired
+	FillPrice     *decimal.Decimal `json:"fill_price,omitempty"`
+	Fee           *decimal.Decimal `json:"fee,omitempty"`
+	Tax           *decimal.Decimal `json:"tax,omitempty"`
+	LedgerEventID *uuid.UUID       `json:"ledger_event_id,omitempty"`
+	Reason        string           `json:"reason,omitempty"`
+	ExpiresAt     time.Time        `json:"expires_at"`
+	CreatedAt     time.Time        `json:"created_at"`
+	UpdatedAt     time.Time        `json:"updated_at"`
+}
+
+// PaperHolding is a position valued at the latest quote
+type PaperHolding struct {
+	Symbol        string          `json:"symbol"`
+	Quantity      decimal.Decimal `json:"quantity"`
+	AvgCost       decimal.Decimal `json:"avg_cost"`
+	CostBasis     decimal.Decimal `json:"cost_basis"`
+	CurrentPrice  decimal.Decimal `json:"current_price"`
+	MarketValue   decimal.Decimal `json:"market_value"`
+	UnrealizedPnL decimal.Decimal `json:"unrealized_pnl"`
+}
+
+// PaperAccount summarises a paper portfolio's cash, holdings and performance
+type PaperAccount struct {
+	Portfolio     PaperPortfolio  `json:"portfolio"`
+	Cash          decimal.Decimal `json:"cash"`
+	ReservedCash  decimal.Decimal `json:"reserved_cash"` // Held for pending buy orders
+	AvailableCash decimal.Decimal `json:"available_cash"`
+	MarketValue   decimal.Decimal `json:"market_value"`
+	TotalEquity   decimal.Decimal `json:"total_equity"`
+	TotalReturn   decimal.Decimal `json:"total_return"` // Percent vs initial cash
+	Holdings      []PaperHolding  `json:"holdings"`
+	PendingOrders int             `json:"pending_orders"`
+}
+
+// CreatePortfolio creates a paper trading portfolio
+func (s *PaperTradingService) CreatePortfolio(ctx context.Context, userID uuid.UUID, req CreatePaperPortfolioRequest) (*PaperPortfolio, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.InitialCash.IsZero() {
+		req.InitialCash = decimal.NewFromInt(1000000)
+	}
+	if !req.InitialCash.IsPositive() {
+		return nil, fmt.Errorf("initial_cash must be positive")
+	}
+
+	p := &PaperPortfolio{
+		Name:        req.Name,
+		Description: req.Description,
+		InitialCash: req.InitialCash,
+	}
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO portfolios (user_id, name, description, is_paper, initial_cash)
+		VALUES ($1, $2, $3, true, $4)
+		RETURNING id, created_at
+	`, userID, req.Name, req.Description, req.InitialCash).Scan(&p.ID, &p.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create paper portfolio: %w", err)
+	}
+
+	return p, nil
+}
+
+// ListPortfolios returns a user's paper portfolios
+func (s *PaperTradingService) ListPortfolios(ctx context.Context, userID uuid.UUID) ([]PaperPortfolio, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, name, description, initial_cash, created_at
+		FROM portfolios
+		WHERE user_id = $1 AND is_paper = true
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query paper portfolios: %w", err)
+	}
+	defer rows.Close()
+
+	portfolios := make([]PaperPortfolio, 0)
+	for rows.Next() {
+		var p PaperPortfolio
+		if err := rows.Scan(&p.ID, &p.Name, &p.Description, &p.InitialCash, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan paper portfolio: %w", err)
+		}
+		portfolios = append(portfolios, p)
+	}
+
+	return portfolios, nil
+}
+
+// GetAccount returns cash, holdings valued at realtime quotes, and overall performance
+func (s *PaperTradingService) GetAccount(ctx context.Context, portfolioID uuid.UUID) (*PaperAccount, error) {
+	portfolio, err := s.getPortfolio(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	cash, err := s.cashBalance(ctx, portfolio)
+	if err != nil {
+		return nil, err
+	}
+	reserved, pending, err := s.reservedCash(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	positions, err := s.ledgerService.GetPositions(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	account := &PaperAccount{
+		Portfolio:     *portfolio,
+		Cash:          cash,
+		ReservedCash:  reserved,
+		AvailableCash: cash.Sub(reserved),
+		Holdings:      make([]PaperHolding, 0, len(positions)),
+		PendingOrders: pending,
+	}
+
+	symbols := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		symbols = append(symbols, stripLedgerSuffix(pos.Symbol))
+	}
+	prices := make(map[string]decimal.Decimal)
+	if len(symbols) > 0 {
+		if quotes, err := s.realtimeService.FetchMultipleQuotes(ctx, symbols); err == nil {
+			for _, q := range quotes {
+				if q != nil && q.Price.IsPositive() {
+					prices[q.Symbol] = q.Price
+				}
+			}
+		}
+	}
+
+	for _, pos := range positions {
+		symbol := stripLedgerSuffix(pos.Symbol)
+		price, ok := prices[symbol]
+		if !ok {
+			// Fall back to cost when no quote is available
+			price = pos.AvgCostPerShare
+		}
+		holding := PaperHolding{
+			Symbol:       symbol,
+			Quantity:     pos.TotalQuantity,
+			AvgCost:      pos.AvgCostPerShare.Round(2),
+			CostBasis:    pos.TotalCost,
+			CurrentPrice: price,
+			MarketValue:  pos.TotalQuantity.Mul(price).Round(2),
+		}
+		holding.UnrealizedPnL = holding.MarketValue.Sub(holding.CostBasis).Round(2)
+		account.MarketValue = account.MarketValue.Add(holding.MarketValue)
+		account.Holdings = append(account.Holdings, holding)
+	}
+
+	account.TotalEquity = account.Cash.Add(account.MarketValue)
+	if portfolio.InitialCash.IsPositive() {
+		account.TotalReturn = account.TotalEquity.Sub(portfolio.InitialCash).
+			Div(portfolio.InitialCash).Mul(decimal.NewFromInt(100)).Round(2)
+	}
+
+	return account, nil
+}
+
+// PlaceOrder validates a virtual order and fills it immediately when the market is open
+// and the order is marketable; otherwise it stays pending until it fills or expires
+func (s *PaperTradingService) PlaceOrder(ctx context.Context, portfolioID uuid.UUID, req PaperOrderRequest) (*PaperOrder, error) {
+	portfolio, err := s.getPortfolio(ctx, portfolioID)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	req.Side = strings.ToUpper(req.Side)
+	req.OrderType = strings.ToLower(req.OrderType)
+	if req.OrderType == "" {
+		req.OrderType = "market"
+	}
+	if err := validatePaperOrder(&req); err != nil {
+		return nil, err
+	}
+	if _, err := s.ledgerSymbol(ctx, req.Symbol); err != nil {
+		return nil, err
+	}
+
+	order := &PaperOrder{
+		PortfolioID: portfolioID,
+		Symbol:      req.Symbol,
+		Side:        req.Side,
+		OrderType:   req.OrderType,
+		Quantity:    req.Quantity,
+		Status:      "pending",
+		ExpiresAt:   s.sessionClose(),
+	}
+	if req.OrderType == "limit" {
+		limit := req.LimitPrice
+		order.LimitPrice = &limit
+	}
+
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO paper_orders (portfolio_id, symbol, side, order_type, quantity, limit_price, status, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, 'pending', $7)
+		RETURNING id, created_at, updated_at
+	`, portfolioID, order.Symbol, order.Side, order.OrderType, order.Quantity, order.LimitPrice, order.ExpiresAt,
+	).Scan(&order.ID, &order.CreatedAt, &order.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create paper order: %w", err)
+	}
+
+	if s.realtimeService.GetMarketStatus().IsOpen {
+		s.tryFill(ctx, portfolio, order)
+	}
+
+	return order, nil
+}
+
+// CancelOrder cancels a pending order
+func (s *PaperTradingService) CancelOrder(ctx context.Context, portfolioID, orderID uuid.UUID) error {
+	s.orderMu.Lock()
+	defer s.orderMu.Unlock()
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE paper_orders SET status = 'cancelled'
+		WHERE id = $1 AND portfolio_id = $2 AND status = 'pending'
+	`, orderID, portfolioID)
+	if err != nil {
+		return fmt.Errorf("failed to cancel order: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("pending order not found")
+	}
+	return nil
+}
+
+// ListOrders returns a portfolio's orders, optionally filtered by status
+func (s *PaperTradingService) ListOrders(ctx context.Context, portfolioID uuid.UUID, status string, limit int) ([]PaperOrder, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, portfolio_id, symbol, side, order_type, quantity, limit_price, status,
+		       fill_price, fee, tax, ledger_event_id, COALESCE(reason, ''), expires_at, created_at, updated_at
+		FROM paper_orders
+		WHERE portfolio_id = $1 AND ($2 = '' OR status = $2)
+		ORDER BY created_at DESC
+		LIMIT $3
+	`, portfolioID, status, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	return scanPaperOrders(rows)
+}
+
+// Start launches the background loop that matches pending orders during trading hours
+func (s *PaperTradingService) Start() {
+	s.mu.Lock()
+	if s.isRunning {
+		s.mu.Unlock()
+		return
+	}
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(paperMatchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopChan:
+				return
+			case <-ticker.C:
+				s.MatchPendingOrders(context.Background())
+			}
+		}
+	}()
+	log.Printf("Paper trading order matcher started (every %v)", paperMatchInterval)
+}
+
+// Stop stops the order matching loop
+func (s *PaperTradingService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.isRunning {
+		return
+	}
+	s.isRunning = false
+	close(s.stopChan)
+}
+
+// MatchPendingOrders expires stale day orders and, while the market is open,
+// tries to fill every pending order against the latest quotes
+func (s *PaperTradingService) MatchPendingOrders(ctx context.Context) {
+	if _, err := s.db.ExecContext(ctx, `
+		UPDATE paper_orders SET status = 'expired', reason = '當日有效委託已過期'
+		WHERE status = 'pending' AND expires_at <= NOW()
+	`); err != nil {
+		log.Printf("Paper trading: failed to expire orders: %v", err)
+	}
+
+	if !s.realtimeService.GetMarketStatus().IsOpen {
+		return
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, portfolio_id, symbol, side, order_type, quantity, limit_price, status,
+		       fill_price, fee, tax, ledger_event_id, COALESCE(reason, ''), expires_at, created_at, updated_at
+		FROM paper_orders
+		WHERE status = 'pending'
+		ORDER BY created_at ASC
+	`)
+	if err != nil {
+		log.Printf("Paper trading: failed to load pending orders: %v", err)
+		return
+	}
+	orders, err := scanPaperOrders(rows)
+	rows.Close()
+	if err != nil || len(orders) == 0 {
+		return
+	}
+
+	portfolios := make(map[uuid.UUID]*PaperPortfolio)
+	for i := range orders {
+		order := &orders[i]
+		portfolio, ok := portfolios[order.PortfolioID]
+		if !ok {
+			if portfolio, err = s.getPortfolio(ctx, order.PortfolioID); err != nil {
+				continue
+			}
+			portfolios[order.PortfolioID] = portfolio
+		}
+		s.tryFill(ctx, portfolio, order)
+	}
+}
+
+// tryFill executes an order against the current quote. Orders that cannot trade yet
+// (not marketable, or the price is locked at limit up/down) stay pending; orders that
+// can never fill (insufficient cash or shares) are rejected.
+func (s *PaperTradingService) tryFill(ctx context.Context, portfolio *PaperPortfolio, order *PaperOrder) {
+	s.orderMu.Lock()
+	defer s.orderMu.Unlock()
+
+	quote, err := s.realtimeService.FetchRealtimeQuote(ctx, order.Symbol)
+	if err != nil || !quote.Price.IsPositive() {
+		return
+	}
+
+	price, ok := paperFillPrice(order, quote)
+	if !ok {
+		return
+	}
+
+	qty := decimal.NewFromInt(order.Quantity)
+	amount := qty.Mul(price)
+	fee := decimal.Max(amount.Mul(decimal.NewFromFloat(paperFeeRate)).Floor(), decimal.NewFromInt(paperMinFee))
+	tax := decimal.Zero
+	if order.Side == "SELL" {
+		tax = amount.Mul(decimal.NewFromFloat(paperSellTaxRate)).Floor()
+	}
+
+	ledgerSymbol, err := s.ledgerSymbol(ctx, order.Symbol)
+	if err != nil {
+		s.rejectOrder(ctx, order, err.Error())
+		return
+	}
+
+	if order.Side == "BUY" {
+		cash, err := s.cashBalance(ctx, portfolio)
+		if err != nil {
+			return
+		}
+		if cash.LessThan(amount.Add(fee)) {
+			s.rejectOrder(ctx, order, fmt.Sprintf("可用資金不足 (需要 %s, 現金 %s)", amount.Add(fee).StringFixed(0), cash.StringFixed(0)))
+			return
+		}
+	} else {
+		held := decimal.Zero
+		if pos, err := s.ledgerService.GetPosition(ctx, portfolio.ID, ledgerSymbol); err == nil {
+			held = pos.TotalQuantity
+		}
+		if held.LessThan(qty) {
+			s.rejectOrder(ctx, order, fmt.Sprintf("庫存不足 (持有 %s 股)", held.String()))
+			return
+		}
+	}
+
+	var userID uuid.UUID
+	if err := s.db.QueryRowContext(ctx, `SELECT user_id FROM portfolios WHERE id = $1`, portfolio.ID).Scan(&userID); err != nil {
+		return
+	}
+
+	notes := fmt.Sprintf("Paper order %s", order.ID)
+	event := newLedgerEvent(userID, models.CreateLedgerEventRequest{
+		PortfolioID: portfolio.ID,
+		EventType:   models.EventType(order.Side),
+		Symbol:      ledgerSymbol,
+		Quantity:    qty,
+		Price:       price,
+		Fee:         fee,
+		Tax:         tax,
+		OccurredAt:  time.Now(),
+		Notes:       &notes,
+	}, "paper")
+
+	filled, err := s.recordFill(ctx, order, event, price, fee, tax)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Class() == "23" {
+		// The ledger refuses the event, e.g. a symbol outside valid_symbol;
+		// retrying cannot succeed
+		s.rejectOrder(ctx, order, "帳本拒絕此筆成交: "+pqErr.Message)
+		return
+	}
+	if err != nil {
+		log.Printf("Paper trading: failed to record fill for order %s: %v", order.ID, err)
+		return
+	}
+	if !filled {
+		return // Cancelled, expired or filled by another matcher meanwhile
+	}
+	if err := s.ledgerService.RefreshPositions(ctx); err != nil {
+		log.Printf("Paper trading: failed to refresh positions after order %s: %v", order.ID, err)
+	}
+
+	order.Status = "filled"
+	order.FillPrice = &price
+	order.Fee = &fee
+	order.Tax = &tax
+	order.LedgerEventID = &event.EventID
+}
+
+// recordFill claims a still pending order and writes its ledger event and fill
+// in one transaction. It reports false, writing nothing, when the order is no
+// longer pending.
+func (s *PaperTradingService) recordFill(ctx context.Context, order *PaperOrder, event *models.LedgerEvent, price, fee, tax decimal.Decimal) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE paper_orders SET status = 'filling' WHERE id = $1 AND status = 'pending'
+	`, order.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim order: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	if err := insertLedgerEvent(ctx, tx, event); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE paper_orders
+		SET status = 'filled', fill_price = $2, fee = $3, tax = $4, ledger_event_id = $5
+		WHERE id = $1
+	`, order.ID, price, fee, tax, event.EventID); err != nil {
+		return false, fmt.Errorf("failed to mark order filled: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit fill: %w", err)
+	}
+	return true, nil
+}
+
+// rejectOrder rejects an order unless it has left the pending state meanwhile
+func (s *PaperTradingService) rejectOrder(ctx context.Context, order *PaperOrder, reason string) {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE paper_orders SET status = 'rejected', reason = $2 WHERE id = $1 AND status = 'pending'
+	`, order.ID, reason)
+	if err != nil {
+		log.Printf("Paper trading: failed to reject order %s: %v", order.ID, err)
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return
+	}
+	order.Status = "rejected"
+	order.Reason = reason
+}
+
+// paperFillPrice returns the price an order would trade at, or false when it cannot trade now
+func paperFillPrice(order *PaperOrder, quote *RealtimeQuote) (decimal.Decimal, bool) {
+	var price decimal.Decimal
+	if order.Side == "BUY" {
+		// Locked limit up: no sellers left at any price
+		if quote.LimitUp.IsPositive() && quote.Price.GreaterThanOrEqual(quote.LimitUp) && !quote.AskPrice.IsPositive() {
+			return decimal.Zero, false
+		}
+		price = quote.AskPrice
+	} else {
+		// Locked limit down: no buyers left at any price
+		if quote.LimitDown.IsPositive() && quote.Price.LessThanOrEqual(quote.LimitDown) && !quote.BidPrice.IsPositive() {
+			return decimal.Zero, false
+		}
+		price = quote.BidPrice
+	}
+	if !price.IsPositive() {
+		price = quote.Price
+	}
+
+	// Trades can never print outside the daily price band
+	if quote.LimitUp.IsPositive() && price.GreaterThan(quote.LimitUp) {
+		price = quote.LimitUp
+	}
+	if quote.LimitDown.IsPositive() && price.LessThan(quote.LimitDown) {
+		price = quote.LimitDown
+	}
+
+	if order.OrderType == "limit" && order.LimitPrice != nil {
+		if order.Side == "BUY" && price.GreaterThan(*order.LimitPrice) {
+			return decimal.Zero, false
+		}
+		if order.Side == "SELL" && price.LessThan(*order.LimitPrice) {
+			return decimal.Zero, false
+		}
+	}
+
+	return price, true
+}
+
+func validatePaperOrder(req *PaperOrderRequest) error {
+	if req.Symbol == "" {
+		return fmt.Errorf("symbol is required")
+	}
+	if !paperSymbolPattern.MatchString(req.Symbol) {
+		return fmt.Errorf("paper trading supports 4-digit stock codes only, not %s", req.Symbol)
+	}
+	if req.Side != "BUY" && req.Side != "SELL" {
+		return fmt.Errorf("side must be BUY or SELL")
+	}
+	if req.OrderType != "market" && req.OrderType != "limit" {
+		return fmt.Errorf("order_type must be market or limit")
+	}
+	if req.OrderType == "limit" && !req.LimitPrice.IsPositive() {
+		return fmt.Errorf("limit_price is required for limit orders")
+	}
+	if req.Quantity <= 0 {
+		return fmt.Errorf("quantity must be positive")
+	}
+	if req.OddLot {
+		if req.Quantity >= paperBoardLot {
+			return fmt.Errorf("odd lot orders must be between 1 and %d shares", paperBoardLot-1)
+		}
+	} else if req.Quantity%paperBoardLot != 0 {
+		return fmt.Errorf("quantity must be a multiple of %d shares (set odd_lot for 零股)", paperBoardLot)
+	}
+	return nil
+}
+
+// sessionClose returns when a day order placed now expires: the close of today's
+// session while the market is open or before it opens, otherwise the next session's close
+func (s *PaperTradingService) sessionClose() time.Time {
+	status := s.realtimeService.GetMarketStatus()
+	day := status.ServerTime
+	if !status.IsOpen && status.Status != "pre_market" {
+		if !status.NextOpenTime.IsZero() {
+			day = status.NextOpenTime
+		} else {
+			// After-hours session: next weekday
+			day = day.AddDate(0, 0, 1)
+			for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+				day = day.AddDate(0, 0, 1)
+			}
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), 13, 30, 0, 0, day.Location())
+}
+
+// getPortfolio loads a portfolio and checks that it is a paper portfolio
+func (s *PaperTradingService) getPortfolio(ctx context.Context, portfolioID uuid.UUID) (*PaperPortfolio, error) {
+	var p PaperPortfolio
+	var isPaper bool
+	var initialCash sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT id, name, description, COALESCE(is_paper, false), initial_cash, created_at
+		FROM portfolios
+		WHERE id = $1
+	`, portfolioID).Scan(&p.ID, &p.Name, &p.Description, &isPaper, &initialCash, &p.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("portfolio not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query portfolio: %w", err)
+	}
+	if !isPaper {
+		return nil, fmt.Errorf("portfolio is not a paper trading portfolio")
+	}
+	if initialCash.Valid {
+		p.InitialCash, _ = decimal.NewFromString(initialCash.String)
+	}
+	return &p, nil
+}
+
+// cashBalance derives cash from the initial deposit and the portfolio's ledger
+func (s *PaperTradingService) cashBalance(ctx context.Context, portfolio *PaperPortfolio) (decimal.Decimal, error) {
+	var flow decimal.Decimal
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN event_type = 'SELL' THEN total_amount
+		                         WHEN event_type = 'BUY' THEN -total_amount
+		                         ELSE 0 END), 0)
+		FROM ledger_events
+		WHERE portfolio_id = $1
+	`, portfolio.ID).Scan(&flow)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("failed to calculate cash: %w", err)
+	}
+	return portfolio.InitialCash.Add(flow), nil
+}
+
+// reservedCash estimates cash held by pending buy orders (limit price, or nothing for market orders)
+func (s *PaperTradingService) reservedCash(ctx context.Context, portfolioID uuid.UUID) (decimal.Decimal, int, error) {
+	var reserved decimal.Decimal
+	var pending int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(CASE WHEN side = 'BUY' THEN quantity * COALESCE(limit_price, 0) ELSE 0 END), 0),
+		       COUNT(*)
+		FROM paper_orders
+		WHERE portfolio_id = $1 AND status = 'pending'
+	`, portfolioID).Scan(&reserved, &pending)
+	if err != nil {
+		return decimal.Zero, 0, fmt.Errorf("failed to calculate reserved cash: %w", err)
+	}
+	return reserved, pending, nil
+}
+
+// ledgerSymbol converts a stock code to the ledger's '2330.TW' / '6488.TWO' format
+func (s *PaperTradingService) ledgerSymbol(ctx context.Context, symbol string) (string, error) {
+	var market string
+	err := s.db.QueryRowContext(ctx, `SELECT market FROM taiwan_stocks WHERE symbol = $1`, symbol).Scan(&market)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("unknown symbol: %s", symbol)
+	}
+	if err != nil {
+		return "", err
+	}
+	if market == "OTC" {
+		return symbol + ".TWO", nil
+	}
+	return symbol + ".TW", nil
+}
+
+func stripLedgerSuffix(symbol string) string {
+	if i := strings.Index(symbol, "."); i >= 0 {
+		return symbol[:i]
+	}
+	return symbol
+}
+
+func scanPaperOrders(rows *sql.Rows) ([]PaperOrder, error) {
+	orders := make([]PaperOrder, 0)
+	for rows.Next() {
+		var o PaperOrder
+		var limitPrice, fillPrice, fee, tax decimal.NullDecimal
+		var ledgerEventID uuid.NullUUID
+		if err := rows.Scan(
+			&o.ID, &o.PortfolioID, &o.Symbol, &o.Side, &o.OrderType, &o.Quantity, &limitPrice, &o.Status,
+			&fillPrice, &fee, &tax, &ledgerEventID, &o.Reason, &o.ExpiresAt, &o.CreatedAt, &o.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		if limitPrice.Valid {
+			o.LimitPrice = &limitPrice.Decimal
+		}
+		if fillPrice.Valid {
+			o.FillPrice = &fillPrice.Decimal
+		}
+		if fee.Valid {
+			o.Fee = &fee.Decimal
+		}
+		if tax.Valid {
+			o.Tax = &tax.Decimal
+		}
+		if ledgerEventID.Valid {
+			o.LedgerEventID = &ledgerEventID.UUID
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
@@ -48,6 +48,8 @@ type RealtimeQuote struct {
 	UpdatedAt     time.Time       `json:"updated_at"`
 	// 5-level order book
 	OrderBook     *OrderBook      `json:"order_book,omitempty"`
+	// Pivot levels, only populated when requested
+	Pivots        *PivotPoints    `json:"pivots,omitempty"`
 }
 
 // OrderBookLevel represents a single price level in the order book
@@ -157,9 +159,13 @@ func (s *RealtimeService) FetchRealtimeQuote(ctx context.Context, symbol string)
 	// Determine exchange (TSE or OTC)
 	exchange := "tse"
 	
-	// Check if it's OTC stock from database
+	// Check if it's OTC stock from database; delisted stocks have no quotes
 	var market string
-	err := s.db.QueryRow("SELECT market FROM taiwan_stocks WHERE symbol = $1", symbol).Scan(&market)
+	var delisted bool
+	err := s.db.QueryRow("SELECT market, delisted_at IS NOT NULL FROM taiwan_stocks WHERE symbol = $1", symbol).Scan(&market, &delisted)
+	if err == nil && delisted {
+		return nil, fmt.Errorf("symbol %s is delisted", symbol)
+	}
 	if err == nil && market == "OTC" {
 		exchange = "otc"
 	}
@@ -294,15 +300,22 @@ func (s *RealtimeService) FetchMultipleQuotes(ctx context.Context, symbols []str
 	// Build ex_ch parameter for multiple stocks
 	var exChParts []string
 	for _, symbol := range symbols {
-		// Check market
+		// Check market, skipping delisted stocks
 		var market string
+		var delisted bool
 		exchange := "tse"
-		err := s.db.QueryRow("SELECT market FROM taiwan_stocks WHERE symbol = $1", symbol).Scan(&market)
+		err := s.db.QueryRow("SELECT market, delisted_at IS NOT NULL FROM taiwan_stocks WHERE symbol = $1", symbol).Scan(&market, &delisted)
+		if err == nil && delisted {
+			continue
+		}
 		if err == nil && market == "OTC" {
 			exchange = "otc"
 		}
 		exChParts = append(exChParts, fmt.Sprintf("%s_%s.tw", exchange, symbol))
 	}
+	if len(exChParts) == 0 {
+		return []*RealtimeQuote{}, nil
+	}
 
 	url := fmt.Sprintf("https://mis.twse.com.tw/stock/api/getStockInfo.jsp?ex_ch=%s", strings.Join(exChParts, "|"))
 	
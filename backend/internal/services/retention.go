@@ -0,0 +1,240 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrCleanupRunning is returned by RunNow while a cleanup is in progress
+	ErrCleanupRunning = errors.New("cleanup is already running")
+	// ErrInvalidRetentionPolicy wraps a policy the cleanup rejects
+	ErrInvalidRetentionPolicy = errors.New("invalid retention policy")
+)
+
+// RetentionPolicy is a cleanup policy a RetentionWorker applies
+type RetentionPolicy interface {
+	IsDryRun() bool
+}
+
+// RetentionWorker applies a retention policy on an interval, first at start,
+// and on demand through RunNow. Dry runs are not kept as the last result.
+type RetentionWorker[P RetentionPolicy, R any] struct {
+	name       string // For logs, e.g. "News retention"
+	cleanup    func(context.Context, P) (*R, error)
+	describe   func(*R) string // Summarizes a scheduled run for the log
+	policy     P
+	interval   time.Duration
+	mu         sync.Mutex
+	isRunning  bool
+	isBusy     bool
+	lastResult *R
+	lastError  string
+	stopChan   chan struct{}
+}
+
+// RetentionStatus reports the configured policy and the last run
+type RetentionStatus[P RetentionPolicy, R any] struct {
+	IsRunning  bool   `json:"is_running"`
+	IsBusy     bool   `json:"is_busy"`
+	Policy     P      `json:"policy"`
+	Interval   string `json:"interval"`
+	LastResult *R     `json:"last_result,omitempty"`
+	LastError  string `json:"last_error,omitempty"`
+}
+
+func newRetentionWorker[P RetentionPolicy, R any](name string, policy P, cleanup func(context.Context, P) (*R, error), describe func(*R) string) *RetentionWorker[P, R] {
+	return &RetentionWorker[P, R]{
+		name:     name,
+		cleanup:  cleanup,
+		describe: describe,
+		policy:   policy,
+		interval: 24 * time.Hour,
+		stopChan: make(chan struct{}),
+	}
+}
+
+// Start launches the cleanup loop
+func (w *RetentionWorker[P, R]) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("%s worker started (policy %+v)", w.name, w.policy)
+}
+
+// Stop stops the cleanup loop
+func (w *RetentionWorker[P, R]) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+// Policy returns the configured retention policy
+func (w *RetentionWorker[P, R]) Policy() P {
+	return w.policy
+}
+
+// GetStatus returns the policy and the last cleanup run
+func (w *RetentionWorker[P, R]) GetStatus() RetentionStatus[P, R] {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return RetentionStatus[P, R]{
+		IsRunning:  w.isRunning,
+		IsBusy:     w.isBusy,
+		Policy:     w.policy,
+		Interval:   w.interval.String(),
+		LastResult: w.lastResult,
+		LastError:  w.lastError,
+	}
+}
+
+// RunNow applies the given policy immediately and waits for the result
+func (w *RetentionWorker[P, R]) RunNow(ctx context.Context, policy P) (*R, error) {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return nil, ErrCleanupRunning
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+
+	result, err := w.cleanup(ctx, policy)
+
+	w.mu.Lock()
+	w.isBusy = false
+	if !policy.IsDryRun() {
+		w.lastResult = result
+		w.lastError = ""
+		if err != nil {
+			w.lastError = err.Error()
+		}
+	}
+	w.mu.Unlock()
+
+	return result, err
+}
+
+func (w *RetentionWorker[P, R]) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.runScheduled()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.runScheduled()
+		}
+	}
+}
+
+func (w *RetentionWorker[P, R]) runScheduled() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	result, err := w.RunNow(ctx, w.policy)
+	if err != nil {
+		log.Printf("%s: cleanup failed: %v", w.name, err)
+		return
+	}
+	log.Printf("%s: %s", w.name, w.describe(result))
+}
+
+// retentionBatches removes expired rows of one table in batches, each archived
+// (optionally) and deleted in its own transaction
+type retentionBatches struct {
+	noun         string        // Plural for errors, e.g. "news"
+	selectQuery  string        // Expired ids, oldest first; the batch size is the last parameter
+	selectArgs   []interface{} // Parameters before the batch size
+	archiveQuery string        // Copies the rows with ids $1 to the archive table
+	deleteQuery  string        // Deletes the rows with ids $1
+	batchSize    int
+}
+
+// run removes every expired row and returns how many expired, were archived
+// and were deleted; on error the counts cover the batches already committed
+func (b retentionBatches) run(ctx context.Context, db *database.DB, archive bool) (expired, archived, deleted int, err error) {
+	for {
+		if err := ctx.Err(); err != nil {
+			return expired, archived, deleted, err
+		}
+
+		var ids []string
+		rows, err := db.QueryContext(ctx, b.selectQuery, append(b.selectArgs, b.batchSize)...)
+		if err != nil {
+			return expired, archived, deleted, fmt.Errorf("failed to query expired %s: %w", b.noun, err)
+		}
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err == nil {
+				ids = append(ids, id)
+			}
+		}
+		rows.Close()
+		if len(ids) == 0 {
+			return expired, archived, deleted, nil
+		}
+		expired += len(ids)
+
+		a, d, err := b.remove(ctx, db, ids, archive)
+		if err != nil {
+			return expired, archived, deleted, err
+		}
+		archived += a
+		deleted += d
+
+		if len(ids) < b.batchSize {
+			return expired, archived, deleted, nil
+		}
+	}
+}
+
+// remove archives (optionally) and deletes one batch in a transaction
+func (b retentionBatches) remove(ctx context.Context, db *database.DB, ids []string, archive bool) (int, int, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	archived := 0
+	if archive {
+		res, err := tx.ExecContext(ctx, b.archiveQuery, pq.Array(ids))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to archive %s: %w", b.noun, err)
+		}
+		n, _ := res.RowsAffected()
+		archived = int(n)
+	}
+
+	res, err := tx.ExecContext(ctx, b.deleteQuery, pq.Array(ids))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to delete %s: %w", b.noun, err)
+	}
+	deleted, _ := res.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to commit cleanup: %w", err)
+	}
+	return archived, int(deleted), nil
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"psm-backend/internal/database"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RevenueService stores monthly revenue reports (月營收) published by listed
+// companies by the 10th of each month
+type RevenueService struct {
+	db *database.DB
+}
+
+// MonthlyRevenue is one company's revenue report for a month, in thousand NTD
+type MonthlyRevenue struct {
+	Symbol            string    `json:"symbol"`
+	Month             string    `json:"month"` // YYYY-MM
+	Revenue           int64     `json:"revenue"`
+	PrevMonthRevenue  int64     `json:"prev_month_revenue"`
+	LastYearRevenue   int64     `json:"last_year_revenue"`
+	MoMPercent        float64   `json:"mom_pct"`
+	YoYPercent        float64   `json:"yoy_pct"`
+	CumulativeRevenue int64     `json:"cumulative_revenue"`
+	CumulativeYoY     float64   `json:"cumulative_yoy_pct"`
+	Note              string    `json:"note,omitempty"`
+	Market            string    `json:"market"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// RevenueFetchResult reports one fetch of the open data
+type RevenueFetchResult struct {
+	Market  string `json:"market"`
+	Month   string `json:"month,omitempty"` // Latest month in the data
+	Records int    `json:"records"`
+	Stored  int    `json:"stored"` // New or changed reports
+	Error   string `json:"error,omitempty"`
+}
+
+func NewRevenueService(db *database.DB) *RevenueService {
+	return &RevenueService{db: db}
+}
+
+// FetchMonthlyRevenue downloads the latest monthly revenue of all TWSE and
+// TPEx listed companies and upserts it
+func (s *RevenueService) FetchMonthlyRevenue(ctx context.Context) ([]RevenueFetchResult, error) {
+	feeds := []struct {
+		market string
+		url    string
+	}{
+		{"TSE", "https://openapi.twse.com.tw/v1/opendata/t187ap05_L"},
+		{"OTC", "https://www.tpex.org.tw/openapi/v1/mopsfe_t187ap05_O"},
+	}
+
+	results := make([]RevenueFetchResult, 0, len(feeds))
+	failed := 0
+	for _, feed := range feeds {
+		result := RevenueFetchResult{Market: feed.market}
+		body, err := fetchNewsBody(ctx, feed.url, map[string]string{"Accept": "application/json"})
+		if err == nil {
+			var records []map[string]interface{}
+			if err = json.Unmarshal(body, &records); err != nil {
+				err = fmt.Errorf("failed to parse response: %w", err)
+			} else {
+				result.Records = len(records)
+				result.Month, result.Stored, err = s.storeRevenue(ctx, feed.market, records)
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+	if failed == len(feeds) {
+		return results, fmt.Errorf("failed to fetch monthly revenue: %s; %s", results[0].Error, results[1].Error)
+	}
+	return results, nil
+}
+
+// storeRevenue upserts open data records. Field names carry stray spaces and
+// differ slightly between TWSE and TPEx, so keys are normalised as for MOPS filings.
+func (s *RevenueService) storeRevenue(ctx context.Context, market string, records []map[string]interface{}) (string, int, error) {
+	query := `
+		INSERT INTO stock_monthly_revenue (symbol, revenue_month, revenue, prev_month_revenue, last_year_revenue,
+		                                   mom_pct, yoy_pct, cumulative_revenue, cumulative_yoy_pct, note, market)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NULLIF($10, ''), $11)
+		ON CONFLICT (symbol, revenue_month) DO UPDATE SET
+			revenue = EXCLUDED.revenue, prev_month_revenue = EXCLUDED.prev_month_revenue,
+			last_year_revenue = EXCLUDED.last_year_revenue, mom_pct = EXCLUDED.mom_pct, yoy_pct = EXCLUDED.yoy_pct,
+			cumulative_revenue = EXCLUDED.cumulative_revenue, cumulative_yoy_pct = EXCLUDED.cumulative_yoy_pct,
+			note = EXCLUDED.note, market = EXCLUDED.market
+		WHERE (stock_monthly_revenue.revenue, stock_monthly_revenue.cumulative_revenue, COALESCE(stock_monthly_revenue.note, ''))
+		      IS DISTINCT FROM (EXCLUDED.revenue, EXCLUDED.cumulative_revenue, COALESCE(EXCLUDED.note, ''))
+	`
+
+	latest, stored := "", 0
+	for _, raw := range records {
+		rec := make(map[string]string, len(raw))
+		for k, v := range raw {
+			rec[strings.ReplaceAll(strings.TrimSpace(k), " ", "")] = strings.TrimSpace(fmt.Sprint(v))
+		}
+
+		symbol := firstField(rec, "公司代號")
+		month, err := parseROCMonth(firstField(rec, "資料年月"))
+		revenue, ok := parseRevenueInt(firstField(rec, "營業收入-當月營收"))
+		if symbol == "" || err != nil || !ok {
+			continue
+		}
+		if m := month.Format("2006-01"); m > latest {
+			latest = m
+		}
+
+		prev, _ := parseRevenueInt(firstField(rec, "營業收入-上月營收"))
+		lastYear, _ := parseRevenueInt(firstField(rec, "營業收入-去年當月營收"))
+		cumulative, _ := parseRevenueInt(firstField(rec, "累計營業收入-當月累計營收"))
+		note := firstField(rec, "備註")
+		if note == "-" {
+			note = ""
+		}
+
+		res, err := s.db.ExecContext(ctx, query, symbol, month, revenue, prev, lastYear,
+			parseRevenuePercent(firstField(rec, "營業收入-上月比較增減(%)")),
+			parseRevenuePercent(firstField(rec, "營業收入-去年同月增減(%)")),
+			cumulative,
+			parseRevenuePercent(firstField(rec, "累計營業收入-前期比較增減(%)")),
+			note, market)
+		if err != nil {
+			return latest, stored, fmt.Errorf("failed to store revenue for %s: %w", symbol, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			stored++
+		}
+	}
+	return latest, stored, nil
+}
+
+// parseROCMonth parses a ROC year and month such as "11308" (2024-08)
+func parseROCMonth(s string) (time.Time, error) {
+	s = strings.ReplaceAll(s, "/", "")
+	if len(s) < 4 {
+		return time.Time{}, fmt.Errorf("invalid ROC month %q", s)
+	}
+	year, err := strconv.Atoi(s[:len(s)-2])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid ROC month %q", s)
+	}
+	month, err := strconv.Atoi(s[len(s)-2:])
+	if err != nil || month < 1 || month > 12 {
+		return time.Time{}, fmt.Errorf("invalid ROC month %q", s)
+	}
+	return time.Date(year+1911, time.Month(month), 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+func parseRevenueInt(s string) (int64, bool) {
+	v, err := strconv.ParseInt(strings.ReplaceAll(s, ",", ""), 10, 64)
+	return v, err == nil
+}
+
+// parseRevenuePercent returns nil for the blanks and dashes the open data uses
+// when a change cannot be computed
+func parseRevenuePercent(s string) interface{} {
+	v, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	if err != nil {
+		return nil
+	}
+	return roundTo(v, 2)
+}
+
+// GetMonthlyRevenue returns a symbol's latest monthly revenue reports, newest first
+func (s *RevenueService) GetMonthlyRevenue(ctx context.Context, symbol string, months int) ([]MonthlyRevenue, error) {
+	return queryMonthlyRevenue(ctx, s.db, symbol, months)
+}
+
+func queryMonthlyRevenue(ctx context.Context, db *database.DB, symbol string, months int) ([]MonthlyRevenue, error) {
+	if months <= 0 || months > 120 {
+		months = 12
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT symbol, TO_CHAR(revenue_month, 'YYYY-MM'), revenue, COALESCE(prev_month_revenue, 0), COALESCE(last_year_revenue, 0),
+		       mom_pct::float8, yoy_pct::float8, COALESCE(cumulative_revenue, 0), cumulative_yoy_pct::float8,
+		       COALESCE(note, ''), market, updated_at
+		FROM stock_monthly_revenue
+		WHERE symbol = $1
+		ORDER BY revenue_month DESC
+		LIMIT $2
+	`, symbol, months)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query monthly revenue: %w", err)
+	}
+	defer rows.Close()
+
+	reports := []MonthlyRevenue{}
+	for rows.Next() {
+		var r MonthlyRevenue
+		var mom, yoy, cumYoY sql.NullFloat64
+		if err := rows.Scan(&r.Symbol, &r.Month, &r.Revenue, &r.PrevMonthRevenue, &r.LastYearRevenue,
+			&mom, &yoy, &r.CumulativeRevenue, &cumYoY, &r.Note, &r.Market, &r.UpdatedAt); err != nil {
+			continue
+		}
+		r.MoMPercent, r.YoYPercent, r.CumulativeYoY = mom.Float64, yoy.Float64, cumYoY.Float64
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// formatRevenueReport renders a revenue report as one line of prompt text
+func formatRevenueReport(r MonthlyRevenue) string {
+	line := fmt.Sprintf("%s 月營收 %.2f 億元，月增 %.2f%%，年增 %.2f%%，累計營收 %.2f 億元，累計年增 %.2f%%",
+		r.Month, float64(r.Revenue)/1e5, r.MoMPercent, r.YoYPercent, float64(r.CumulativeRevenue)/1e5, r.CumulativeYoY)
+	if r.Note != "" {
+		line += "。說明: " + r.Note
+	}
+	return line
+}
+
+// RevenueFetchWorker fetches monthly revenue in the background. Companies
+// report by the 10th, so the data changes daily early in the month.
+type RevenueFetchWorker struct {
+	revenueService *RevenueService
+	interval       time.Duration
+	mu             sync.Mutex
+	isRunning      bool
+	stopChan       chan struct{}
+	status         RevenueFetchStatus
+}
+
+// RevenueFetchStatus is the worker state and the outcome of the last fetch
+type RevenueFetchStatus struct {
+	Running  bool                 `json:"running"`
+	Interval string               `json:"interval"`
+	LastRun  *time.Time           `json:"last_run,omitempty"`
+	Results  []RevenueFetchResult `json:"results"`
+}
+
+// NewRevenueFetchWorker reads REVENUE_FETCH_INTERVAL (hours, default 6)
+func NewRevenueFetchWorker(revenueService *RevenueService) *RevenueFetchWorker {
+	interval := 6
+	if v, err := strconv.Atoi(os.Getenv("REVENUE_FETCH_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+	return &RevenueFetchWorker{
+		revenueService: revenueService,
+		interval:       time.Duration(interval) * time.Hour,
+		stopChan:       make(chan struct{}),
+	}
+}
+
+// Start launches the fetch loop
+func (w *RevenueFetchWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Revenue fetch worker started (every %v)", w.interval)
+}
+
+// Stop stops the fetch loop
+func (w *RevenueFetchWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+// RunNow fetches monthly revenue in the background
+func (w *RevenueFetchWorker) RunNow() {
+	go w.run()
+}
+
+// GetStatus returns the worker state and the outcome of the last fetch
+func (w *RevenueFetchWorker) GetStatus() RevenueFetchStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	status.Running = w.isRunning
+	status.Interval = w.interval.String()
+	if status.Results == nil {
+		status.Results = []RevenueFetchResult{}
+	}
+	return status
+}
+
+func (w *RevenueFetchWorker) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.run()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.run()
+		}
+	}
+}
+
+func (w *RevenueFetchWorker) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	results, err := w.revenueService.FetchMonthlyRevenue(ctx)
+	if err != nil {
+		log.Printf("Revenue fetch worker: %v", err)
+	}
+	for _, r := range results {
+		if r.Stored > 0 {
+			log.Printf("Revenue fetch worker: %s %s stored %d of %d reports", r.Market, r.Month, r.Stored, r.Records)
+		}
+	}
+
+	now := time.Now()
+	w.mu.Lock()
+	w.status.LastRun = &now
+	w.status.Results = results
+	w.mu.Unlock()
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ways CombineScreens merges the matches of its screens
+const (
+	CombineIntersection = "intersection" // Stocks matched by every screen
+	CombineUnion        = "union"        // Stocks matched by any screen
+)
+
+// maxCombinedScreens caps how many screens one request combines
+const maxCombinedScreens = 5
+
+// CombinedScreenRef picks one screen to combine: a preset by name or a saved screen by ID
+type CombinedScreenRef struct {
+	Preset   string `json:"preset"`
+	ScreenID string `json:"screen_id"`
+}
+
+// CombinedScreenRequest runs several screens and combines their matches
+type CombinedScreenRequest struct {
+	Screens   []CombinedScreenRef `json:"screens"`
+	Operation string              `json:"operation"` // intersection (default) or union
+	SortBy    string              `json:"sort_by"`   // As in ScreenerCriteria, default score descending
+	SortDesc  bool                `json:"sort_desc"`
+	Limit     int                 `json:"limit"`
+	Offset    int                 `json:"offset"`
+}
+
+// CombinedScreenResult is a combined match annotated with the screens it matched
+type CombinedScreenResult struct {
+	ScreenerResult
+	MatchedScreens []string `json:"matched_screens"`
+}
+
+// CombinedScreenPage is one page of combined screening results
+type CombinedScreenPage struct {
+	Screens   []string               `json:"screens"` // Preset or saved screen names, in request order
+	Operation string                 `json:"operation"`
+	Results   []CombinedScreenResult `json:"results"`
+	Total     int                    `json:"total"`
+	Offset    int                    `json:"offset"`
+	Limit     int                    `json:"limit"`
+	HasMore   bool                   `json:"has_more"`
+}
+
+// CombineScreens runs presets and the user's saved screens over one load of
+// candidates and returns the intersection or union of their matches. A stock
+// keeps the metrics and score of the first screen it matched and the matched
+// criteria of all of them; union results rank stocks matched by more screens first.
+func (s *ScreenerService) CombineScreens(ctx context.Context, userID uuid.UUID, req *CombinedScreenRequest) (*CombinedScreenPage, error) {
+	op := strings.ToLower(strings.TrimSpace(req.Operation))
+	if op == "" {
+		op = CombineIntersection
+	}
+	if op != CombineIntersection && op != CombineUnion {
+		return nil, fmt.Errorf("invalid operation: must be intersection or union")
+	}
+	if len(req.Screens) < 2 || len(req.Screens) > maxCombinedScreens {
+		return nil, fmt.Errorf("screens must list between 2 and %d screens", maxCombinedScreens)
+	}
+	if req.Offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.SortBy == "" {
+		req.SortBy = "score"
+		req.SortDesc = true
+	}
+
+	labels := make([]string, len(req.Screens))
+	criteria := make([]ScreenerCriteria, len(req.Screens))
+	expressions := make([]*ParsedExpression, len(req.Screens))
+	for i, ref := range req.Screens {
+		switch {
+		case ref.Preset != "" && ref.ScreenID != "":
+			return nil, fmt.Errorf("screen %d: set either preset or screen_id", i+1)
+		case ref.Preset != "":
+			preset, ok := s.findPreset(ref.Preset)
+			if !ok {
+				return nil, fmt.Errorf("preset not found: %s", ref.Preset)
+			}
+			labels[i], criteria[i] = preset.Name, preset.Criteria
+		case ref.ScreenID != "":
+			sc, err := s.GetSavedScreen(ctx, userID, ref.ScreenID)
+			if err != nil {
+				return nil, err
+			}
+			labels[i], criteria[i] = sc.Name, sc.Criteria
+		default:
+			return nil, fmt.Errorf("screen %d: preset or screen_id is required", i+1)
+		}
+
+		expression, err := s.prepareCriteria(ctx, &criteria[i])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", labels[i], err)
+		}
+		expressions[i] = expression
+	}
+
+	// Universe and price bounds differ per screen, so load every stock and let
+	// matchesCriteria apply them
+	candidates, err := s.fetchCandidates(ctx, time.Now(), nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := map[string][]string{}
+	first := map[string]ScreenerResult{}
+	var symbols []string // In order of first match, so ties keep a stable order across pages
+	for i := range criteria {
+		for _, r := range s.screenCandidates(ctx, candidates, &criteria[i], expressions[i]) {
+			if kept, ok := first[r.Symbol]; ok {
+				for _, label := range r.MatchedCriteria {
+					if !slices.Contains(kept.MatchedCriteria, label) {
+						kept.MatchedCriteria = append(kept.MatchedCriteria, label)
+					}
+				}
+				first[r.Symbol] = kept
+			} else {
+				first[r.Symbol] = r
+				symbols = append(symbols, r.Symbol)
+			}
+			matched[r.Symbol] = append(matched[r.Symbol], labels[i])
+		}
+	}
+
+	combined := []ScreenerResult{}
+	for _, symbol := range symbols {
+		if op == CombineIntersection && len(matched[symbol]) < len(labels) {
+			continue
+		}
+		combined = append(combined, first[symbol])
+	}
+	s.sortResults(combined, &ScreenerCriteria{SortBy: req.SortBy, SortDesc: req.SortDesc})
+	if op == CombineUnion {
+		sort.SliceStable(combined, func(i, j int) bool {
+			return len(matched[combined[i].Symbol]) > len(matched[combined[j].Symbol])
+		})
+	}
+
+	page := &CombinedScreenPage{
+		Screens:   labels,
+		Operation: op,
+		Results:   []CombinedScreenResult{},
+		Total:     len(combined),
+		Offset:    req.Offset,
+		Limit:     req.Limit,
+	}
+	if req.Offset < len(combined) {
+		combined = combined[req.Offset:]
+	} else {
+		combined = nil
+	}
+	if len(combined) > req.Limit {
+		combined = combined[:req.Limit]
+	}
+	for _, r := range combined {
+		page.Results = append(page.Results, CombinedScreenResult{ScreenerResult: r, MatchedScreens: matched[r.Symbol]})
+	}
+	page.HasMore = page.Offset+len(page.Results) < page.Total
+	return page, nil
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// screenerDailyRetention is how many days of screener_daily are kept
+const screenerDailyRetention = 30
+
+// RefreshDailyMetrics materializes the screener metrics of the latest trading
+// day into screener_daily, replacing that day's rows, and returns the number
+// of symbols written
+func (s *ScreenerService) RefreshDailyMetrics(ctx context.Context) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var tradeDate time.Time
+	if err := tx.QueryRowContext(ctx, `SELECT MAX(timestamp)::date FROM stock_ohlcv WHERE `+notIndexSymbolSQL).Scan(&tradeDate); err != nil {
+		return 0, fmt.Errorf("failed to find latest trading day: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM screener_daily WHERE trade_date = $1`, tradeDate); err != nil {
+		return 0, fmt.Errorf("failed to clear screener metrics: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO screener_daily (
+			trade_date, symbol, current_price, prev_close, day_high, day_low, volume, avg_volume, turnover, avg_turnover,
+			ma5, ma20, ma60, high_52, low_52, sentiment, sentiment_score, beta, correlation, rs_20d, rs_60d
+		)
+		WITH `+screenerMetricsCTEs+`
+		SELECT $2::date, symbol, current_price, prev_close, day_high, day_low, volume, avg_volume, turnover, avg_turnover,
+		       ma5, ma20, ma60, high_52, low_52, sentiment, sentiment_score, beta, correlation, rs_20d, rs_60d
+		FROM metrics
+	`, time.Now(), tradeDate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute screener metrics: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM screener_daily WHERE trade_date < $1::date - $2::int
+	`, tradeDate, screenerDailyRetention); err != nil {
+		return 0, fmt.Errorf("failed to prune screener metrics: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit screener metrics: %w", err)
+	}
+
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+// ScreenerDailyWorker refreshes screener_daily once the day's bars are synced,
+// and again if more bars land after the last refresh
+type ScreenerDailyWorker struct {
+	screenerService *ScreenerService
+	runAfterHour    int // Earliest Taipei hour for the refresh
+	mu              sync.Mutex
+	isRunning       bool
+	isBusy          bool
+	stopChan        chan struct{}
+}
+
+// NewScreenerDailyWorker reads SCREENER_DAILY_HOUR (earliest Taipei hour, default 15)
+func NewScreenerDailyWorker(screenerService *ScreenerService) *ScreenerDailyWorker {
+	hour := 15
+	if v, err := strconv.Atoi(os.Getenv("SCREENER_DAILY_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+	return &ScreenerDailyWorker{
+		screenerService: screenerService,
+		runAfterHour:    hour,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// Start launches the refresh loop
+func (w *ScreenerDailyWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Screener daily worker started (after %02d:00)", w.runAfterHour)
+}
+
+// Stop stops the refresh loop
+func (w *ScreenerDailyWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *ScreenerDailyWorker) loop() {
+	ticker := time.NewTicker(10 * time.Minute)
+	defer ticker.Stop()
+
+	w.checkAndRun()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun refreshes when the latest trading day has no metrics yet or
+// has bars newer than them
+func (w *ScreenerDailyWorker) checkAndRun() {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	if time.Now().In(time.FixedZone("Asia/Taipei", 8*3600)).Hour() < w.runAfterHour {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	var stale bool
+	if err := w.screenerService.db.QueryRowContext(ctx, `
+		WITH latest AS (SELECT MAX(timestamp)::date AS d FROM stock_ohlcv WHERE `+notIndexSymbolSQL+`)
+		SELECT d IS NOT NULL AND COALESCE(
+			(SELECT MAX(o.created_at) FROM stock_ohlcv o WHERE o.timestamp >= d AND o.timestamp < d + 1 AND o.`+notIndexSymbolSQL+`)
+			> (SELECT MAX(sd.computed_at) FROM screener_daily sd WHERE sd.trade_date = d),
+			true)
+		FROM latest
+	`).Scan(&stale); err != nil || !stale {
+		return
+	}
+
+	start := time.Now()
+	n, err := w.screenerService.RefreshDailyMetrics(ctx)
+	if err != nil {
+		log.Printf("Screener daily worker: %v", err)
+		return
+	}
+	log.Printf("Screener daily worker: materialized %d symbols in %v", n, time.Since(start).Round(time.Second))
+}
@@ -0,0 +1,352 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const maxPresetEvaluationDates = 120
+
+// PresetEvaluationRequest configures a historical replay of screener presets
+type PresetEvaluationRequest struct {
+	Presets   []string `json:"presets"`    // Preset names (default: all presets)
+	StartDate string   `json:"start_date"` // YYYY-MM-DD (default: one year ago)
+	EndDate   string   `json:"end_date"`   // YYYY-MM-DD (default: today)
+	Step      int      `json:"step"`       // Replay every N trading days (default: 5)
+	Horizons  []int    `json:"horizons"`   // Forward return horizons in trading days (default: 5, 20, 60)
+}
+
+// HorizonStats summarises forward returns of preset matches over one horizon
+type HorizonStats struct {
+	Horizon         int     `json:"horizon"`
+	Samples         int     `json:"samples"`
+	AvgReturn       float64 `json:"avg_return"`        // Percent
+	MedianReturn    float64 `json:"median_return"`     // Percent
+	WinRate         float64 `json:"win_rate"`          // Percent of samples with a positive return
+	AvgExcessReturn float64 `json:"avg_excess_return"` // Percent above TAIEX over the same horizon
+}
+
+// PresetEvaluation is the replay outcome of a single preset
+type PresetEvaluation struct {
+	Name           string         `json:"name"`
+	Description    string         `json:"description"`
+	DatesEvaluated int            `json:"dates_evaluated"`
+	DatesMatched   int            `json:"dates_matched"`
+	AvgMatches     float64        `json:"avg_matches"` // Average matched stocks per replayed date
+	Horizons       []HorizonStats `json:"horizons"`
+}
+
+// PresetEvaluationResult is the outcome of a preset replay
+type PresetEvaluationResult struct {
+	StartDate string             `json:"start_date"`
+	EndDate   string             `json:"end_date"`
+	Step      int                `json:"step"`
+	Dates     int                `json:"dates"`
+	Presets   []PresetEvaluation `json:"presets"`
+}
+
+// EvaluatePresets replays screener presets on historical trading dates and measures
+// the forward returns of the stocks each preset would have returned on those dates.
+// Indicator-based criteria only match on dates covered by indicator snapshots.
+func (s *ScreenerService) EvaluatePresets(ctx context.Context, req *PresetEvaluationRequest) (*PresetEvaluationResult, error) {
+	presets, err := s.selectPresets(req.Presets)
+	if err != nil {
+		return nil, err
+	}
+
+	end := time.Now().UTC().Truncate(24 * time.Hour)
+	if req.EndDate != "" {
+		if end, err = time.Parse("2006-01-02", req.EndDate); err != nil {
+			return nil, fmt.Errorf("invalid end_date: %s", req.EndDate)
+		}
+	}
+	start := end.AddDate(-1, 0, 0)
+	if req.StartDate != "" {
+		if start, err = time.Parse("2006-01-02", req.StartDate); err != nil {
+			return nil, fmt.Errorf("invalid start_date: %s", req.StartDate)
+		}
+	}
+	if start.After(end) {
+		return nil, fmt.Errorf("start_date must be before end_date")
+	}
+	if req.Step <= 0 {
+		req.Step = 5
+	}
+	if len(req.Horizons) == 0 {
+		req.Horizons = []int{5, 20, 60}
+	}
+	for _, h := range req.Horizons {
+		if h <= 0 || h > 250 {
+			return nil, fmt.Errorf("horizons must be between 1 and 250 trading days")
+		}
+	}
+
+	dates, err := s.sampleTradingDates(ctx, start, end, req.Step)
+	if err != nil {
+		return nil, err
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("no trading dates with market data between %s and %s", start.Format("2006-01-02"), end.Format("2006-01-02"))
+	}
+
+	// returns[preset][horizon index] collects per-stock forward and excess returns
+	type sample struct{ ret, excess float64 }
+	returns := make([][][]sample, len(presets))
+	for i := range returns {
+		returns[i] = make([][]sample, len(req.Horizons))
+	}
+	evaluations := make([]PresetEvaluation, len(presets))
+	totalMatches := make([]int, len(presets))
+	for i, p := range presets {
+		evaluations[i] = PresetEvaluation{Name: p.Name, Description: p.Description}
+	}
+
+	for _, date := range dates {
+		candidates, err := s.fetchCandidates(ctx, date, nil, false)
+		if err != nil {
+			return nil, err
+		}
+
+		matchesByPreset := make([][]ScreenerResult, len(presets))
+		symbolSet := map[string]bool{IndexSymbolTAIEX: true}
+		for i, p := range presets {
+			matchesByPreset[i] = s.applyCriteria(candidates, &p.Criteria)
+			for _, m := range matchesByPreset[i] {
+				symbolSet[m.Symbol] = true
+			}
+		}
+
+		symbols := make([]string, 0, len(symbolSet))
+		for symbol := range symbolSet {
+			symbols = append(symbols, symbol)
+		}
+		forward, err := s.forwardReturns(ctx, symbols, date, req.Horizons)
+		if err != nil {
+			return nil, err
+		}
+		index := forward[IndexSymbolTAIEX]
+
+		for i := range presets {
+			evaluations[i].DatesEvaluated++
+			if len(matchesByPreset[i]) > 0 {
+				evaluations[i].DatesMatched++
+			}
+			totalMatches[i] += len(matchesByPreset[i])
+
+			for _, m := range matchesByPreset[i] {
+				stockReturns, ok := forward[m.Symbol]
+				if !ok {
+					continue
+				}
+				for hi := range req.Horizons {
+					if math.IsNaN(stockReturns[hi]) {
+						continue
+					}
+					excess := math.NaN()
+					if index != nil && !math.IsNaN(index[hi]) {
+						excess = stockReturns[hi] - index[hi]
+					}
+					returns[i][hi] = append(returns[i][hi], sample{ret: stockReturns[hi], excess: excess})
+				}
+			}
+		}
+	}
+
+	for i := range evaluations {
+		if evaluations[i].DatesEvaluated > 0 {
+			evaluations[i].AvgMatches = roundTo(float64(totalMatches[i])/float64(evaluations[i].DatesEvaluated), 2)
+		}
+		for hi, h := range req.Horizons {
+			stats := HorizonStats{Horizon: h, Samples: len(returns[i][hi])}
+			if stats.Samples > 0 {
+				values := make([]float64, 0, stats.Samples)
+				var sum, excessSum float64
+				var wins, excessCount int
+				for _, smp := range returns[i][hi] {
+					values = append(values, smp.ret)
+					sum += smp.ret
+					if smp.ret > 0 {
+						wins++
+					}
+					if !math.IsNaN(smp.excess) {
+						excessSum += smp.excess
+						excessCount++
+					}
+				}
+				sort.Float64s(values)
+				median := values[len(values)/2]
+				if len(values)%2 == 0 {
+					median = (values[len(values)/2-1] + values[len(values)/2]) / 2
+				}
+
+				stats.AvgReturn = roundTo(sum/float64(stats.Samples), 2)
+				stats.MedianReturn = roundTo(median, 2)
+				stats.WinRate = roundTo(float64(wins)/float64(stats.Samples)*100, 2)
+				if excessCount > 0 {
+					stats.AvgExcessReturn = roundTo(excessSum/float64(excessCount), 2)
+				}
+			}
+			evaluations[i].Horizons = append(evaluations[i].Horizons, stats)
+		}
+	}
+
+	return &PresetEvaluationResult{
+		StartDate: dates[0].Format("2006-01-02"),
+		EndDate:   dates[len(dates)-1].Format("2006-01-02"),
+		Step:      req.Step,
+		Dates:     len(dates),
+		Presets:   evaluations,
+	}, nil
+}
+
+// applyCriteria filters, scores, sorts and limits candidates the way ScreenStocks does.
+// Custom expressions are not applied because they are evaluated against live data.
+func (s *ScreenerService) applyCriteria(candidates []ScreenerResult, criteria *ScreenerCriteria) []ScreenerResult {
+	var results []ScreenerResult
+	for _, c := range candidates {
+		r := c
+		if !s.matchesCriteria(&r, criteria) {
+			continue
+		}
+		r.Score = s.calculateScore(&r, criteria)
+		results = append(results, r)
+	}
+
+	s.sortResults(results, criteria)
+
+	limit := criteria.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// selectPresets resolves preset names, defaulting to all presets
+func (s *ScreenerService) selectPresets(names []string) ([]PresetScreen, error) {
+	all := s.GetPresets()
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	var selected []PresetScreen
+	for _, name := range names {
+		found := false
+		for _, p := range all {
+			if p.Name == name {
+				selected = append(selected, p)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("preset not found: %s", name)
+		}
+	}
+	return selected, nil
+}
+
+// sampleTradingDates returns every step-th synced trading date in [start, end],
+// keeping the most recent dates when there are more than maxPresetEvaluationDates
+func (s *ScreenerService) sampleTradingDates(ctx context.Context, start, end time.Time, step int) ([]time.Time, error) {
+	query := `
+		SELECT timestamp
+		FROM stock_ohlcv
+		WHERE timestamp >= $1 AND timestamp < $2
+		GROUP BY timestamp
+		HAVING COUNT(*) > 1000
+		ORDER BY timestamp ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trading dates: %w", err)
+	}
+	defer rows.Close()
+
+	var all []time.Time
+	for rows.Next() {
+		var ts time.Time
+		if err := rows.Scan(&ts); err != nil {
+			return nil, err
+		}
+		all = append(all, ts)
+	}
+
+	var dates []time.Time
+	for i := 0; i < len(all); i += step {
+		dates = append(dates, all[i])
+	}
+	if len(dates) > maxPresetEvaluationDates {
+		dates = dates[len(dates)-maxPresetEvaluationDates:]
+	}
+	return dates, nil
+}
+
+// forwardReturns returns, per symbol, the percent return from the close on date to the
+// close h trading days later for each horizon (NaN when that bar does not exist yet).
+// Symbols that did not trade on date are omitted.
+func (s *ScreenerService) forwardReturns(ctx context.Context, symbols []string, date time.Time, horizons []int) (map[string][]float64, error) {
+	maxHorizon := 0
+	offsets := []int64{1}
+	for _, h := range horizons {
+		maxHorizon = maxInt(maxHorizon, h)
+		offsets = append(offsets, int64(h+1))
+	}
+
+	query := `
+		SELECT symbol, rn, close FROM (
+			SELECT symbol, timestamp, close,
+			       ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp ASC) AS rn
+			FROM stock_ohlcv
+			WHERE symbol = ANY($1) AND timestamp >= $2 AND timestamp < $3
+		) bars
+		WHERE rn = ANY($4) AND (rn > 1 OR timestamp = $2)
+	`
+
+	// Allow roughly 1.6 calendar days per trading day to cover weekends and holidays
+	until := date.AddDate(0, 0, maxHorizon*8/5+10)
+	rows, err := s.db.QueryContext(ctx, query, pq.Array(symbols), date, until, pq.Array(offsets))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load forward returns: %w", err)
+	}
+	defer rows.Close()
+
+	closes := make(map[string]map[int64]float64)
+	for rows.Next() {
+		var symbol string
+		var rn int64
+		var close float64
+		if err := rows.Scan(&symbol, &rn, &close); err != nil {
+			return nil, err
+		}
+		if closes[symbol] == nil {
+			closes[symbol] = make(map[int64]float64)
+		}
+		closes[symbol][rn] = close
+	}
+
+	result := make(map[string][]float64, len(closes))
+	for symbol, bars := range closes {
+		base, ok := bars[1]
+		if !ok || base <= 0 {
+			continue
+		}
+		values := make([]float64, len(horizons))
+		for i, h := range horizons {
+			values[i] = math.NaN()
+			if exit, ok := bars[int64(h+1)]; ok {
+				values[i] = (exit - base) / base * 100
+			}
+		}
+		result[symbol] = values
+	}
+	return result, nil
+}
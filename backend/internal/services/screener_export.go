@@ -0,0 +1,106 @@
+package services
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MaxScreenerExportRows caps a CSV export, which covers every match of a
+// screen rather than one page
+const MaxScreenerExportRows = 5000
+
+// screenerCSVHeader names the columns WriteScreenerCSV writes
+var screenerCSVHeader = []string{
+	"代號", "名稱", "產業", "市場", "收盤價", "漲跌", "漲跌幅(%)", "成交量", "量比",
+	"成交金額", "20日均成交金額",
+	"MA5", "MA20", "MA60", "52週高", "52週低", "RSI", "MACD柱狀", "K", "D", "Beta",
+	"20日相對強度", "60日相對強度",
+	"漲跌停", "外資買賣超(張)", "投信買賣超(張)", "外資連買日", "投信連買日", "AI立場",
+	"總分", "量能分", "趨勢分", "動能分", "情緒分", "52週位置分", "符合條件",
+}
+
+// WriteScreenerCSV writes results as CSV with the matched criteria and score
+// breakdown, prefixed with a UTF-8 BOM so Excel detects the encoding. Values a
+// result lacks, such as RSI without an indicator snapshot, are left blank.
+func WriteScreenerCSV(w io.Writer, results []ScreenerResult) error {
+	return writeScreenerCSV(w, results, nil)
+}
+
+// WriteCombinedScreenCSV writes combined results like WriteScreenerCSV, with
+// the screens each stock matched in a last column
+func WriteCombinedScreenCSV(w io.Writer, results []CombinedScreenResult) error {
+	plain := make([]ScreenerResult, len(results))
+	screens := make([][]string, len(results))
+	for i, r := range results {
+		plain[i], screens[i] = r.ScreenerResult, r.MatchedScreens
+	}
+	return writeScreenerCSV(w, plain, screens)
+}
+
+// writeScreenerCSV adds a matched screens column when screens is not nil
+func writeScreenerCSV(w io.Writer, results []ScreenerResult, screens [][]string) error {
+	if _, err := io.WriteString(w, "\uFEFF"); err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(w)
+	header := screenerCSVHeader
+	if screens != nil {
+		header = append(header[:len(header):len(header)], "符合篩選")
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i, r := range results {
+		var rsi, macdHist, kdjK, kdjD, beta, rs20, rs60 string
+		if r.HasRSI {
+			rsi = csvFloat(r.RSI)
+		}
+		if r.HasIndicators {
+			macdHist = csvFloat(r.MACDHistogram)
+			kdjK, kdjD = csvFloat(r.KDJK), csvFloat(r.KDJD)
+		}
+		if r.Beta != nil {
+			beta = csvFloat(*r.Beta)
+		}
+		if r.RelativeStrength20 != nil {
+			rs20 = csvFloat(*r.RelativeStrength20)
+		}
+		if r.RelativeStrength60 != nil {
+			rs60 = csvFloat(*r.RelativeStrength60)
+		}
+		var foreignNet, trustNet, foreignStreak, trustStreak string
+		if r.HasInstitutional {
+			foreignNet = strconv.FormatInt(r.ForeignNetLots, 10)
+			trustNet = strconv.FormatInt(r.TrustNetLots, 10)
+			foreignStreak, trustStreak = strconv.Itoa(r.ForeignStreak), strconv.Itoa(r.TrustStreak)
+		}
+
+		row := []string{
+			r.Symbol, r.Name, r.Industry, r.Market,
+			csvFloat(r.CurrentPrice), csvFloat(r.Change), csvFloat(r.ChangePercent),
+			strconv.FormatInt(r.Volume, 10), csvFloat(r.VolumeRatio),
+			csvFloat(r.Turnover), csvFloat(r.AvgTurnover),
+			csvFloat(r.MA5), csvFloat(r.MA20), csvFloat(r.MA60), csvFloat(r.High52Week), csvFloat(r.Low52Week),
+			rsi, macdHist, kdjK, kdjD, beta, rs20, rs60,
+			limitHitLabels[r.LimitHit], foreignNet, trustNet, foreignStreak, trustStreak, r.AIStance,
+			csvFloat(r.Score), csvFloat(r.ScoreBreakdown.Volume), csvFloat(r.ScoreBreakdown.Trend),
+			csvFloat(r.ScoreBreakdown.Momentum), csvFloat(r.ScoreBreakdown.Sentiment), csvFloat(r.ScoreBreakdown.Position52),
+			strings.Join(r.MatchedCriteria, "、"),
+		}
+		if screens != nil {
+			row = append(row, strings.Join(screens[i], "、"))
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func csvFloat(v float64) string {
+	return strconv.FormatFloat(roundTo(v, 2), 'f', -1, 64)
+}
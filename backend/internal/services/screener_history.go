@@ -0,0 +1,476 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// screenerHitRetention is how many days of screener hits are kept
+const screenerHitRetention = 400
+
+// screenerHitHorizons are the trading-day horizons past matches are followed for
+var screenerHitHorizons = []int{1, 5, 20}
+
+// ScreenerHitRun summarizes one day's recording of screener hits
+type ScreenerHitRun struct {
+	TradeDate string `json:"trade_date"`
+	Screens   int    `json:"screens"`
+	Hits      int    `json:"hits"`
+}
+
+// ScreenerHit is one symbol a screen matched on a trading day
+type ScreenerHit struct {
+	Symbol        string  `json:"symbol"`
+	Name          string  `json:"name"`
+	Rank          int     `json:"rank"`
+	Price         float64 `json:"price"`
+	ChangePercent float64 `json:"change_percent"`
+	Score         float64 `json:"score"`
+	IsNew         bool    `json:"is_new"` // Not matched on the previous recorded day
+}
+
+// ScreenerHitDay is a screen's matches on one trading day
+type ScreenerHitDay struct {
+	TradeDate string        `json:"trade_date"`
+	Count     int           `json:"count"`
+	NewCount  int           `json:"new_count"`
+	Hits      []ScreenerHit `json:"hits"`
+}
+
+// ScreenerHitDiff compares a day's matches with the previous recorded day's
+type ScreenerHitDiff struct {
+	TradeDate    string        `json:"trade_date"`
+	PreviousDate string        `json:"previous_date,omitempty"`
+	New          []ScreenerHit `json:"new"`     // Matched on trade_date only
+	Dropped      []ScreenerHit `json:"dropped"` // Matched on previous_date only, as of that day
+	Kept         []ScreenerHit `json:"kept"`
+}
+
+// ScreenerHitHorizon is how past matches did over one horizon
+type ScreenerHitHorizon struct {
+	Days         int      `json:"days"`    // Trading days after the match
+	Samples      int      `json:"samples"` // Matches old enough to measure
+	AvgReturn    float64  `json:"avg_return"`
+	MedianReturn float64  `json:"median_return"`
+	WinRate      float64  `json:"win_rate"`             // Percent of matches with a positive return
+	AvgExcess    *float64 `json:"avg_excess,omitempty"` // Average return minus TAIEX's, percentage points
+}
+
+// ScreenerHitPerformance is the forward performance of a screen's past matches
+type ScreenerHitPerformance struct {
+	Days     int                  `json:"days"` // Calendar days of matches covered
+	Hits     int                  `json:"hits"`
+	Horizons []ScreenerHitHorizon `json:"horizons"`
+}
+
+// RecordDailyHits runs every preset and saved screen against the metrics of
+// tradeDate (YYYY-MM-DD) and stores their matches. It returns nil if the day
+// has already been recorded.
+func (s *ScreenerService) RecordDailyHits(ctx context.Context, tradeDate string) (*ScreenerHitRun, error) {
+	var recorded bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM screener_hit_runs WHERE trade_date = $1::date)
+	`, tradeDate).Scan(&recorded); err != nil {
+		return nil, fmt.Errorf("failed to check screener hit runs: %w", err)
+	}
+	if recorded {
+		return nil, nil
+	}
+
+	type screen struct {
+		ref      CombinedScreenRef
+		criteria ScreenerCriteria
+	}
+	var screens []screen
+	for _, p := range s.GetPresets() {
+		screens = append(screens, screen{CombinedScreenRef{Preset: p.Name}, p.Criteria})
+	}
+	rows, err := s.db.QueryContext(ctx, `SELECT id, criteria FROM saved_screens`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved screens: %w", err)
+	}
+	for rows.Next() {
+		var sc screen
+		var raw []byte
+		if err := rows.Scan(&sc.ref.ScreenID, &raw); err != nil || json.Unmarshal(raw, &sc.criteria) != nil {
+			continue
+		}
+		if sc.criteria.SortBy == "" {
+			sc.criteria.SortBy = "score"
+			sc.criteria.SortDesc = true
+		}
+		screens = append(screens, sc)
+	}
+	rows.Close()
+
+	// Load every stock once; matchesCriteria applies each screen's universe
+	candidates, err := s.fetchCandidates(ctx, time.Now(), nil, true)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM screener_hits WHERE trade_date = $1::date`, tradeDate); err != nil {
+		return nil, fmt.Errorf("failed to clear screener hits: %w", err)
+	}
+	run := &ScreenerHitRun{TradeDate: tradeDate}
+	for _, sc := range screens {
+		sc.criteria.Realtime = false
+		expression, err := s.prepareCriteria(ctx, &sc.criteria)
+		if err != nil {
+			continue // A saved screen whose expression no longer compiles
+		}
+		results := s.screenCandidates(ctx, candidates, &sc.criteria, expression)
+		run.Screens++
+		if len(results) == 0 {
+			continue
+		}
+
+		symbols := make([]string, len(results))
+		ranks := make([]int64, len(results))
+		prices := make([]float64, len(results))
+		changes := make([]float64, len(results))
+		scores := make([]float64, len(results))
+		for i, r := range results {
+			symbols[i], ranks[i], prices[i] = r.Symbol, int64(i+1), r.CurrentPrice
+			changes[i], scores[i] = roundTo(r.ChangePercent, 4), roundTo(r.Score, 4)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO screener_hits (trade_date, preset, screen_id, symbol, rank, price, change_percent, score)
+			SELECT $1::date, NULLIF($2, ''), NULLIF($3, '')::uuid,
+			       UNNEST($4::text[]), UNNEST($5::int[]), UNNEST($6::numeric[]), UNNEST($7::float8[]), UNNEST($8::float8[])
+		`, tradeDate, sc.ref.Preset, sc.ref.ScreenID, pq.Array(symbols), pq.Array(ranks), pq.Array(prices),
+			pq.Array(changes), pq.Array(scores)); err != nil {
+			return nil, fmt.Errorf("failed to store screener hits: %w", err)
+		}
+		run.Hits += len(results)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO screener_hit_runs (trade_date, screens, hits) VALUES ($1::date, $2, $3)
+		ON CONFLICT (trade_date) DO UPDATE SET screens = EXCLUDED.screens, hits = EXCLUDED.hits, recorded_at = NOW()
+	`, tradeDate, run.Screens, run.Hits); err != nil {
+		return nil, fmt.Errorf("failed to record screener hit run: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM screener_hits WHERE trade_date < $1::date - $2::int
+	`, tradeDate, screenerHitRetention); err != nil {
+		return nil, fmt.Errorf("failed to prune screener hits: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM screener_hit_runs WHERE trade_date < $1::date - $2::int
+	`, tradeDate, screenerHitRetention); err != nil {
+		return nil, fmt.Errorf("failed to prune screener hit runs: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit screener hits: %w", err)
+	}
+	return run, nil
+}
+
+// checkHitRef validates a preset or saved screen reference; saved screen
+// ownership is checked by the caller
+func (s *ScreenerService) checkHitRef(ref CombinedScreenRef) error {
+	if (ref.Preset == "") == (ref.ScreenID == "") {
+		return fmt.Errorf("set either preset or screen_id")
+	}
+	if ref.Preset != "" {
+		if _, ok := s.findPreset(ref.Preset); !ok {
+			return fmt.Errorf("preset not found: %s", ref.Preset)
+		}
+	}
+	return nil
+}
+
+// loadHitDays returns a screen's matches on the latest `days` recorded days on
+// or before `before` (YYYY-MM-DD, empty for no bound), oldest first. Recorded
+// days on which the screen matched nothing are included with no hits.
+func (s *ScreenerService) loadHitDays(ctx context.Context, ref CombinedScreenRef, days int, before string) ([]ScreenerHitDay, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT TO_CHAR(trade_date, 'YYYY-MM-DD') FROM screener_hit_runs
+		WHERE trade_date <= COALESCE(NULLIF($1, '')::date, trade_date)
+		ORDER BY trade_date DESC
+		LIMIT $2
+	`, before, days)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query screener hit runs: %w", err)
+	}
+	var dates []string
+	for rows.Next() {
+		var date string
+		if err := rows.Scan(&date); err == nil {
+			dates = append(dates, date)
+		}
+	}
+	rows.Close()
+
+	result := make([]ScreenerHitDay, len(dates))
+	index := make(map[string]int, len(dates))
+	for i, date := range dates {
+		n := len(dates) - 1 - i
+		result[n] = ScreenerHitDay{TradeDate: date, Hits: []ScreenerHit{}}
+		index[date] = n
+	}
+	if len(dates) == 0 {
+		return result, nil
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT TO_CHAR(h.trade_date, 'YYYY-MM-DD'), h.symbol, COALESCE(st.name, st.name_en, h.symbol),
+		       h.rank, h.price::float8, h.change_percent, h.score
+		FROM screener_hits h
+		LEFT JOIN taiwan_stocks st ON st.symbol = h.symbol
+		WHERE (h.preset = NULLIF($1, '') OR h.screen_id = NULLIF($2, '')::uuid)
+		  AND h.trade_date BETWEEN $3::date AND $4::date
+		ORDER BY h.trade_date, h.rank
+	`, ref.Preset, ref.ScreenID, dates[len(dates)-1], dates[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to query screener hits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var date string
+		var h ScreenerHit
+		if err := rows.Scan(&date, &h.Symbol, &h.Name, &h.Rank, &h.Price, &h.ChangePercent, &h.Score); err != nil {
+			continue
+		}
+		if n, ok := index[date]; ok {
+			result[n].Hits = append(result[n].Hits, h)
+			result[n].Count++
+		}
+	}
+	return result, nil
+}
+
+// HitHistory returns a screen's matches over its latest `days` recorded days,
+// newest first, flagging the symbols not matched on the day before
+func (s *ScreenerService) HitHistory(ctx context.Context, ref CombinedScreenRef, days int) ([]ScreenerHitDay, error) {
+	if err := s.checkHitRef(ref); err != nil {
+		return nil, err
+	}
+	if days <= 0 {
+		days = 20
+	}
+	if days > 250 {
+		return nil, fmt.Errorf("days must be between 1 and 250")
+	}
+
+	// One extra day so the oldest day returned can be compared too
+	loaded, err := s.loadHitDays(ctx, ref, days+1, "")
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i < len(loaded); i++ {
+		markNewHits(&loaded[i], loaded[i-1])
+	}
+	if len(loaded) > days {
+		loaded = loaded[1:]
+	}
+
+	history := make([]ScreenerHitDay, 0, len(loaded))
+	for i := len(loaded) - 1; i >= 0; i-- {
+		history = append(history, loaded[i])
+	}
+	return history, nil
+}
+
+// markNewHits flags day's hits that are missing from prev
+func markNewHits(day *ScreenerHitDay, prev ScreenerHitDay) {
+	before := make(map[string]bool, len(prev.Hits))
+	for _, h := range prev.Hits {
+		before[h.Symbol] = true
+	}
+	for i := range day.Hits {
+		if !before[day.Hits[i].Symbol] {
+			day.Hits[i].IsNew = true
+			day.NewCount++
+		}
+	}
+}
+
+// HitDiff compares a screen's matches on date (YYYY-MM-DD, default the latest
+// recorded day) with the recorded day before it
+func (s *ScreenerService) HitDiff(ctx context.Context, ref CombinedScreenRef, date string) (*ScreenerHitDiff, error) {
+	if err := s.checkHitRef(ref); err != nil {
+		return nil, err
+	}
+	if date != "" {
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return nil, fmt.Errorf("invalid date, expected YYYY-MM-DD")
+		}
+	} else if err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(TO_CHAR(MAX(trade_date), 'YYYY-MM-DD'), '') FROM screener_hit_runs
+	`).Scan(&date); err != nil {
+		return nil, fmt.Errorf("failed to query screener hit runs: %w", err)
+	}
+	if date == "" {
+		return nil, fmt.Errorf("screener hits not found: none recorded yet")
+	}
+
+	loaded, err := s.loadHitDays(ctx, ref, 2, date)
+	if err != nil {
+		return nil, err
+	}
+	if len(loaded) == 0 || loaded[len(loaded)-1].TradeDate != date {
+		return nil, fmt.Errorf("screener hits not found for %s", date)
+	}
+	today := loaded[len(loaded)-1]
+	var prev ScreenerHitDay
+	if len(loaded) == 2 {
+		prev = loaded[0]
+	}
+
+	diff := &ScreenerHitDiff{
+		TradeDate:    date,
+		PreviousDate: prev.TradeDate,
+		New:          []ScreenerHit{},
+		Dropped:      []ScreenerHit{},
+		Kept:         []ScreenerHit{},
+	}
+	markNewHits(&today, prev)
+	matched := map[string]bool{}
+	for _, h := range today.Hits {
+		matched[h.Symbol] = true
+		if h.IsNew {
+			diff.New = append(diff.New, h)
+		} else {
+			diff.Kept = append(diff.Kept, h)
+		}
+	}
+	for _, h := range prev.Hits {
+		if !matched[h.Symbol] {
+			diff.Dropped = append(diff.Dropped, h)
+		}
+	}
+	return diff, nil
+}
+
+// HitPerformance measures the returns of a screen's matches over the past
+// `days` calendar days 1, 5 and 20 trading days after each match, from the
+// match day's close, against TAIEX over the same days
+func (s *ScreenerService) HitPerformance(ctx context.Context, ref CombinedScreenRef, days int) (*ScreenerHitPerformance, error) {
+	if err := s.checkHitRef(ref); err != nil {
+		return nil, err
+	}
+	if days <= 0 {
+		days = 60
+	}
+	if days > screenerHitRetention {
+		return nil, fmt.Errorf("days must be between 1 and %d", screenerHitRetention)
+	}
+	maxHorizon := screenerHitHorizons[len(screenerHitHorizons)-1]
+
+	// TAIEX close on each match day and the closes after it
+	benchmark := map[string][]float64{}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT TO_CHAR(d.trade_date, 'YYYY-MM-DD'),
+		       (SELECT close::float8 FROM stock_ohlcv
+		        WHERE symbol = `+indexSymbolSQL+` AND timestamp >= d.trade_date AND timestamp < d.trade_date + 1 LIMIT 1),
+		       ARRAY(SELECT close::float8 FROM stock_ohlcv
+		             WHERE symbol = `+indexSymbolSQL+` AND timestamp >= d.trade_date + 1 ORDER BY timestamp LIMIT $4)
+		FROM (
+			SELECT DISTINCT trade_date FROM screener_hits
+			WHERE (preset = NULLIF($1, '') OR screen_id = NULLIF($2, '')::uuid)
+			  AND trade_date >= CURRENT_DATE - $3::int
+		) d
+	`, ref.Preset, ref.ScreenID, days, maxHorizon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query TAIEX returns: %w", err)
+	}
+	for rows.Next() {
+		var date string
+		var base sql.NullFloat64
+		var closes pq.Float64Array
+		if err := rows.Scan(&date, &base, &closes); err != nil || !base.Valid || base.Float64 <= 0 {
+			continue
+		}
+		benchmark[date] = append([]float64{base.Float64}, closes...)
+	}
+	rows.Close()
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT TO_CHAR(h.trade_date, 'YYYY-MM-DD'), h.price::float8,
+		       ARRAY(SELECT o.close::float8 FROM stock_ohlcv o
+		             WHERE o.symbol = h.symbol AND o.timestamp >= h.trade_date + 1 ORDER BY o.timestamp LIMIT $4)
+		FROM screener_hits h
+		WHERE (h.preset = NULLIF($1, '') OR h.screen_id = NULLIF($2, '')::uuid)
+		  AND h.trade_date >= CURRENT_DATE - $3::int
+	`, ref.Preset, ref.ScreenID, days, maxHorizon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query screener hits: %w", err)
+	}
+	defer rows.Close()
+
+	perf := &ScreenerHitPerformance{Days: days}
+	returns := make([][]float64, len(screenerHitHorizons))
+	excess := make([][]float64, len(screenerHitHorizons))
+	for rows.Next() {
+		var date string
+		var price float64
+		var closes pq.Float64Array
+		if err := rows.Scan(&date, &price, &closes); err != nil || price <= 0 {
+			continue
+		}
+		perf.Hits++
+		index := benchmark[date]
+		for i, h := range screenerHitHorizons {
+			if len(closes) < h {
+				continue // Too recent to measure
+			}
+			ret := (closes[h-1]/price - 1) * 100
+			returns[i] = append(returns[i], ret)
+			if len(index) > h {
+				excess[i] = append(excess[i], ret-(index[h]/index[0]-1)*100)
+			}
+		}
+	}
+
+	for i, h := range screenerHitHorizons {
+		horizon := ScreenerHitHorizon{Days: h, Samples: len(returns[i])}
+		if horizon.Samples > 0 {
+			sorted := append([]float64(nil), returns[i]...)
+			sort.Float64s(sorted)
+			sum, wins := 0.0, 0
+			for _, r := range sorted {
+				sum += r
+				if r > 0 {
+					wins++
+				}
+			}
+			horizon.AvgReturn = roundTo(sum/float64(len(sorted)), 2)
+			horizon.MedianReturn = roundTo(medianSorted(sorted), 2)
+			horizon.WinRate = roundTo(float64(wins)/float64(len(sorted))*100, 2)
+		}
+		if len(excess[i]) > 0 {
+			sum := 0.0
+			for _, e := range excess[i] {
+				sum += e
+			}
+			avg := roundTo(sum/float64(len(excess[i])), 2)
+			horizon.AvgExcess = &avg
+		}
+		perf.Horizons = append(perf.Horizons, horizon)
+	}
+	return perf, nil
+}
+
+// medianSorted returns the median of an ascending, non-empty slice
+func medianSorted(values []float64) float64 {
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
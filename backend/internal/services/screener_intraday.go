@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// intradaySnapshot is the screener universe overlaid with realtime quotes
+type intradaySnapshot struct {
+	candidates []ScreenerResult
+	universe   int // Symbols requested, before those without a trade were dropped
+	updatedAt  time.Time
+}
+
+// IntradayStatus describes the realtime snapshot that realtime screens run against
+type IntradayStatus struct {
+	Available bool       `json:"available"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Universe  int        `json:"universe"` // Top symbols by the last session's turnover
+	Symbols   int        `json:"symbols"`  // Of those, symbols with a live trade
+}
+
+// RefreshIntraday rebuilds the realtime snapshot: the top universeSize symbols
+// by the last session's turnover, with today's price, range, volume and turnover taken
+// from their realtime quotes. Indicators and averages stay at the last close.
+func (s *ScreenerService) RefreshIntraday(ctx context.Context, universeSize int, fetch func(context.Context, []string) ([]*RealtimeQuote, error)) (int, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol FROM stock_ohlcv
+		WHERE timestamp >= (SELECT MAX(timestamp)::date FROM stock_ohlcv WHERE `+notIndexSymbolSQL+`)
+		  AND `+notIndexSymbolSQL+`
+		ORDER BY turnover DESC NULLS LAST
+		LIMIT $1
+	`, universeSize)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query intraday universe: %w", err)
+	}
+	var symbols []string
+	universe := map[string]bool{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil && !universe[symbol] {
+			universe[symbol] = true
+			symbols = append(symbols, symbol)
+		}
+	}
+	rows.Close()
+	if len(symbols) == 0 {
+		return 0, fmt.Errorf("no daily bars to pick the intraday universe from")
+	}
+
+	quotes, err := fetch(ctx, symbols)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch realtime quotes: %w", err)
+	}
+	bySymbol := make(map[string]*RealtimeQuote, len(quotes))
+	for _, q := range quotes {
+		if q != nil {
+			bySymbol[q.Symbol] = q
+		}
+	}
+
+	base, err := s.fetchCandidates(ctx, time.Now(), &ScreenerCriteria{}, true)
+	if err != nil {
+		return 0, err
+	}
+	candidates := make([]ScreenerResult, 0, len(symbols))
+	for _, r := range base {
+		q, ok := bySymbol[r.Symbol]
+		if !ok || !universe[r.Symbol] || !q.Price.IsPositive() {
+			continue // Outside the universe, or no trade yet today
+		}
+		r.applyQuote(q)
+		candidates = append(candidates, r)
+	}
+
+	s.intradayMu.Lock()
+	s.intraday = &intradaySnapshot{candidates: candidates, universe: len(symbols), updatedAt: time.Now()}
+	s.intradayMu.Unlock()
+	return len(candidates), nil
+}
+
+// applyQuote replaces the last close's session values with a realtime quote's
+func (r *ScreenerResult) applyQuote(q *RealtimeQuote) {
+	r.CurrentPrice = q.Price.InexactFloat64()
+	if q.PrevClose.IsPositive() {
+		r.PreviousClose = q.PrevClose.InexactFloat64()
+	}
+	r.DayHigh = q.High.InexactFloat64()
+	r.DayLow = q.Low.InexactFloat64()
+	r.Volume = q.Volume
+	// Quotes carry no trade value; price times volume stands in for it
+	r.Turnover = r.CurrentPrice * float64(q.Volume)
+	if r.DayHigh > r.High52Week {
+		r.High52Week = r.DayHigh
+	}
+	if r.DayLow > 0 && r.DayLow < r.Low52Week {
+		r.Low52Week = r.DayLow
+	}
+
+	r.Change, r.ChangePercent, r.VolumeRatio = 0, 0, 0
+	if r.PreviousClose > 0 {
+		r.Change = r.CurrentPrice - r.PreviousClose
+		r.ChangePercent = r.Change / r.PreviousClose * 100
+	}
+	if r.AvgVolume > 0 {
+		r.VolumeRatio = float64(r.Volume) / float64(r.AvgVolume)
+	}
+	r.LimitUpPrice, r.LimitDownPrice, r.LimitHit = 0, 0, ""
+	r.setLimitHit()
+}
+
+// intradayCandidates returns a copy of the realtime snapshot's candidates
+func (s *ScreenerService) intradayCandidates() ([]ScreenerResult, time.Time, error) {
+	s.intradayMu.RLock()
+	defer s.intradayMu.RUnlock()
+	if s.intraday == nil {
+		return nil, time.Time{}, fmt.Errorf("realtime screening is not available yet: no realtime snapshot has been taken since startup")
+	}
+	candidates := make([]ScreenerResult, len(s.intraday.candidates))
+	copy(candidates, s.intraday.candidates)
+	return candidates, s.intraday.updatedAt, nil
+}
+
+// IntradayStatus reports the realtime snapshot's age and size
+func (s *ScreenerService) IntradayStatus() IntradayStatus {
+	s.intradayMu.RLock()
+	defer s.intradayMu.RUnlock()
+	if s.intraday == nil {
+		return IntradayStatus{}
+	}
+	updatedAt := s.intraday.updatedAt
+	return IntradayStatus{
+		Available: true,
+		UpdatedAt: &updatedAt,
+		Universe:  s.intraday.universe,
+		Symbols:   len(s.intraday.candidates),
+	}
+}
+
+// ScreenerIntradayWorker refreshes the realtime screener snapshot every few
+// minutes while the market is open
+type ScreenerIntradayWorker struct {
+	screenerService *ScreenerService
+	realtimeService *RealtimeService
+	interval        time.Duration
+	universeSize    int
+	mu              sync.Mutex
+	isRunning       bool
+	isBusy          bool
+	stopChan        chan struct{}
+}
+
+// NewScreenerIntradayWorker reads SCREENER_INTRADAY_INTERVAL (minutes,
+// default 3) and SCREENER_INTRADAY_UNIVERSE (top symbols by turnover, default 300)
+func NewScreenerIntradayWorker(screenerService *ScreenerService, realtimeService *RealtimeService) *ScreenerIntradayWorker {
+	interval := 3
+	if v, err := strconv.Atoi(os.Getenv("SCREENER_INTRADAY_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+	universe := 300
+	if v, err := strconv.Atoi(os.Getenv("SCREENER_INTRADAY_UNIVERSE")); err == nil && v > 0 {
+		universe = v
+	}
+	return &ScreenerIntradayWorker{
+		screenerService: screenerService,
+		realtimeService: realtimeService,
+		interval:        time.Duration(interval) * time.Minute,
+		universeSize:    universe,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// Start launches the refresh loop
+func (w *ScreenerIntradayWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Screener intraday worker started (top %d by turnover, every %v)", w.universeSize, w.interval)
+}
+
+// Stop stops the refresh loop
+func (w *ScreenerIntradayWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *ScreenerIntradayWorker) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.checkAndRun()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun refreshes the snapshot while the market is open; after the
+// close the last snapshot stays available
+func (w *ScreenerIntradayWorker) checkAndRun() {
+	if !w.realtimeService.GetMarketStatus().IsOpen {
+		return
+	}
+
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	if _, err := w.screenerService.RefreshIntraday(ctx, w.universeSize, w.fetchQuotes); err != nil {
+		log.Printf("Screener intraday worker: %v", err)
+	}
+}
+
+func (w *ScreenerIntradayWorker) fetchQuotes(ctx context.Context, symbols []string) ([]*RealtimeQuote, error) {
+	var quotes []*RealtimeQuote
+	for start := 0; start < len(symbols); start += alertScanQuoteBatch {
+		end := start + alertScanQuoteBatch
+		if end > len(symbols) {
+			end = len(symbols)
+		}
+		batch, err := w.realtimeService.FetchMultipleQuotes(ctx, symbols[start:end])
+		if err != nil {
+			return nil, err
+		}
+		quotes = append(quotes, batch...)
+	}
+	return quotes, nil
+}
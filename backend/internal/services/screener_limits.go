@@ -0,0 +1,71 @@
+package services
+
+import "math"
+
+// Where a stock's latest bar stands against its daily price limits
+const (
+	LimitHitUp          = "limit_up"     // Closed at limit up
+	LimitHitDown        = "limit_down"   // Closed at limit down
+	LimitHitTouchedUp   = "touched_up"   // Traded at limit up but closed below it
+	LimitHitTouchedDown = "touched_down" // Traded at limit down but closed above it
+)
+
+var limitHitLabels = map[string]string{
+	LimitHitUp:          "收盤漲停",
+	LimitHitDown:        "收盤跌停",
+	LimitHitTouchedUp:   "盤中觸及漲停",
+	LimitHitTouchedDown: "盤中觸及跌停",
+}
+
+// twLimitPercent is the TWSE/TPEx daily price limit
+const twLimitPercent = 0.10
+
+// twTickSize returns the TWSE/TPEx stock tick size at a price
+func twTickSize(price float64) float64 {
+	switch {
+	case price < 10:
+		return 0.01
+	case price < 50:
+		return 0.05
+	case price < 100:
+		return 0.1
+	case price < 500:
+		return 0.5
+	case price < 1000:
+		return 1
+	}
+	return 5
+}
+
+// twLimitPrices returns the limit up and limit down prices for a previous
+// close: 10% either way, rounded inwards to the tick size at the limit
+func twLimitPrices(prevClose float64) (up, down float64) {
+	raw := prevClose * (1 + twLimitPercent)
+	tick := twTickSize(raw)
+	up = math.Floor(raw/tick+1e-9) * tick
+
+	raw = prevClose * (1 - twLimitPercent)
+	tick = twTickSize(raw)
+	down = math.Ceil(raw/tick-1e-9) * tick
+	return roundTo(up, 2), roundTo(down, 2)
+}
+
+// setLimitHit fills in the limit prices and whether the latest bar reached them
+func (r *ScreenerResult) setLimitHit() {
+	if r.PreviousClose <= 0 || r.PreviousClose == r.CurrentPrice && r.DayHigh == r.DayLow {
+		return // No previous bar, or a halted day
+	}
+	r.LimitUpPrice, r.LimitDownPrice = twLimitPrices(r.PreviousClose)
+
+	const eps = 1e-6
+	switch {
+	case r.CurrentPrice >= r.LimitUpPrice-eps:
+		r.LimitHit = LimitHitUp
+	case r.CurrentPrice <= r.LimitDownPrice+eps:
+		r.LimitHit = LimitHitDown
+	case r.DayHigh >= r.LimitUpPrice-eps:
+		r.LimitHit = LimitHitTouchedUp
+	case r.DayLow > 0 && r.DayLow <= r.LimitDownPrice+eps:
+		r.LimitHit = LimitHitTouchedDown
+	}
+}
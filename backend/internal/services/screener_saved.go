@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Bounds for saved screens
+const (
+	savedScreenMaxNameRunes = 100
+	savedScreenMaxLimit     = 200
+)
+
+// SavedScreen is a user's named ScreenerCriteria. ShareToken is only shown to
+// the owner; anyone holding it can view and run the screen but not change it.
+// A scheduled screen runs after every daily sync, see ScreenScheduleWorker.
+type SavedScreen struct {
+	ID             string           `json:"id"`
+	Name           string           `json:"name"`
+	Description    string           `json:"description"`
+	Criteria       ScreenerCriteria `json:"criteria"`
+	Shared         bool             `json:"shared"`
+	ShareToken     string           `json:"share_token,omitempty"`
+	Scheduled      bool             `json:"scheduled"`
+	LastRunDate    *time.Time       `json:"last_run_date,omitempty"`
+	LastRunMatches *int             `json:"last_run_matches,omitempty"`
+	CreatedAt      time.Time        `json:"created_at"`
+	UpdatedAt      time.Time        `json:"updated_at"`
+}
+
+// SavedScreenRequest creates or replaces a saved screen
+type SavedScreenRequest struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Criteria    ScreenerCriteria `json:"criteria"`
+}
+
+const savedScreenColumns = `id, name, COALESCE(description, ''), criteria, COALESCE(share_token, ''),
+	scheduled, last_run_date, last_run_matches, created_at, updated_at`
+
+func scanSavedScreen(scanner interface{ Scan(...interface{}) error }) (*SavedScreen, error) {
+	var sc SavedScreen
+	var criteria []byte
+	var matches sql.NullInt64
+	if err := scanner.Scan(&sc.ID, &sc.Name, &sc.Description, &criteria, &sc.ShareToken,
+		&sc.Scheduled, &sc.LastRunDate, &matches, &sc.CreatedAt, &sc.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(criteria, &sc.Criteria); err != nil {
+		return nil, fmt.Errorf("invalid criteria: %w", err)
+	}
+	sc.Shared = sc.ShareToken != ""
+	if matches.Valid {
+		n := int(matches.Int64)
+		sc.LastRunMatches = &n
+	}
+	return &sc, nil
+}
+
+// normalizeSavedScreen validates a saved screen before it is stored
+func (s *ScreenerService) normalizeSavedScreen(ctx context.Context, req *SavedScreenRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	if req.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if len([]rune(req.Name)) > savedScreenMaxNameRunes {
+		return fmt.Errorf("name must be at most %d characters", savedScreenMaxNameRunes)
+	}
+
+	c := &req.Criteria
+	if err := c.normalizeUniverse(); err != nil {
+		return err
+	}
+	if c.AIStance != "" && normalizeStance(c.AIStance) != c.AIStance {
+		return fmt.Errorf("invalid ai_stance: must be bullish, neutral or bearish")
+	}
+	if c.Limit < 0 || c.Limit > savedScreenMaxLimit {
+		return fmt.Errorf("limit must be between 1 and %d", savedScreenMaxLimit)
+	}
+	c.Offset = 0 // Paging is chosen per run
+	if c.Expression != "" {
+		parsed, err := ParseExpression(c.Expression)
+		if err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+		if !parsed.IsBoolean() {
+			return fmt.Errorf("invalid expression: screener expressions must be conditions (e.g. close > MA(20))")
+		}
+	} else if c.ExpressionID != "" {
+		if _, err := s.expressionService.GetExpression(ctx, c.ExpressionID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSavedScreens returns a user's saved screens by name
+func (s *ScreenerService) ListSavedScreens(ctx context.Context, userID uuid.UUID) ([]SavedScreen, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+savedScreenColumns+`
+		FROM saved_screens
+		WHERE user_id = $1
+		ORDER BY name
+	`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved screens: %w", err)
+	}
+	defer rows.Close()
+
+	screens := []SavedScreen{}
+	for rows.Next() {
+		sc, err := scanSavedScreen(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan saved screen: %w", err)
+		}
+		screens = append(screens, *sc)
+	}
+	return screens, rows.Err()
+}
+
+// GetSavedScreen returns one of a user's saved screens
+func (s *ScreenerService) GetSavedScreen(ctx context.Context, userID uuid.UUID, id string) (*SavedScreen, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	sc, err := scanSavedScreen(s.db.QueryRowContext(ctx, `
+		SELECT `+savedScreenColumns+` FROM saved_screens WHERE id = $1 AND user_id = $2
+	`, id, userID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved screen: %w", err)
+	}
+	return sc, nil
+}
+
+// CreateSavedScreen validates and stores a new screen for userID
+func (s *ScreenerService) CreateSavedScreen(ctx context.Context, userID uuid.UUID, req SavedScreenRequest) (*SavedScreen, error) {
+	if err := s.normalizeSavedScreen(ctx, &req); err != nil {
+		return nil, err
+	}
+	criteria, _ := json.Marshal(req.Criteria)
+
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO saved_screens (user_id, name, description, criteria)
+		VALUES ($1, $2, NULLIF($3, ''), $4::jsonb)
+		RETURNING id
+	`, userID, req.Name, req.Description, string(criteria)).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("saved screen already exists: %s", req.Name)
+		}
+		return nil, fmt.Errorf("failed to create saved screen: %w", err)
+	}
+	return s.GetSavedScreen(ctx, userID, id)
+}
+
+// UpdateSavedScreen replaces a screen's name, description and criteria; its
+// share link is kept
+func (s *ScreenerService) UpdateSavedScreen(ctx context.Context, userID uuid.UUID, id string, req SavedScreenRequest) (*SavedScreen, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	if err := s.normalizeSavedScreen(ctx, &req); err != nil {
+		return nil, err
+	}
+	criteria, _ := json.Marshal(req.Criteria)
+
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE saved_screens
+		SET name = $3, description = NULLIF($4, ''), criteria = $5::jsonb
+		WHERE id = $1 AND user_id = $2
+	`, id, userID, req.Name, req.Description, string(criteria))
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("saved screen already exists: %s", req.Name)
+		}
+		return nil, fmt.Errorf("failed to update saved screen: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	return s.GetSavedScreen(ctx, userID, id)
+}
+
+// DeleteSavedScreen removes a saved screen, invalidating its share link
+func (s *ScreenerService) DeleteSavedScreen(ctx context.Context, userID uuid.UUID, id string) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return fmt.Errorf("saved screen not found")
+	}
+	result, err := s.db.ExecContext(ctx, `DELETE FROM saved_screens WHERE id = $1 AND user_id = $2`, id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete saved screen: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("saved screen not found")
+	}
+	return nil
+}
+
+// SetSavedScreenSharing turns a screen's share link on or off. Turning it on
+// keeps an existing token; turning it off revokes the token for good.
+func (s *ScreenerService) SetSavedScreenSharing(ctx context.Context, userID uuid.UUID, id string, shared bool) (*SavedScreen, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+
+	var token interface{}
+	if shared {
+		b := make([]byte, 16)
+		rand.Read(b)
+		token = hex.EncodeToString(b)
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE saved_screens
+		SET share_token = CASE WHEN $3::text IS NULL THEN NULL ELSE COALESCE(share_token, $3) END
+		WHERE id = $1 AND user_id = $2
+	`, id, userID, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update saved screen: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	return s.GetSavedScreen(ctx, userID, id)
+}
+
+// SetSavedScreenScheduled turns a screen's daily run on or off
+func (s *ScreenerService) SetSavedScreenScheduled(ctx context.Context, userID uuid.UUID, id string, scheduled bool) (*SavedScreen, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE saved_screens SET scheduled = $3 WHERE id = $1 AND user_id = $2
+	`, id, userID, scheduled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update saved screen: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	return s.GetSavedScreen(ctx, userID, id)
+}
+
+// GetSharedScreen returns the screen behind a share token, without the token
+func (s *ScreenerService) GetSharedScreen(ctx context.Context, token string) (*SavedScreen, error) {
+	if token == "" {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	sc, err := scanSavedScreen(s.db.QueryRowContext(ctx, `
+		SELECT `+savedScreenColumns+` FROM saved_screens WHERE share_token = $1
+	`, token))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("saved screen not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query saved screen: %w", err)
+	}
+	sc.ShareToken = ""
+	sc.Scheduled, sc.LastRunDate, sc.LastRunMatches = false, nil, nil
+	return sc, nil
+}
+
+// RunSavedScreen runs a saved screen's criteria, returning the page at offset;
+// a positive limit overrides the saved page size, and realtime runs it against
+// the realtime snapshot even if not saved that way
+func (s *ScreenerService) RunSavedScreen(ctx context.Context, sc *SavedScreen, offset, limit int, realtime bool) (*ScreenerPage, error) {
+	criteria := sc.Criteria
+	if criteria.SortBy == "" {
+		criteria.SortBy = "score"
+		criteria.SortDesc = true
+	}
+	criteria.Offset = offset
+	if limit > 0 {
+		criteria.Limit = limit
+	}
+	criteria.Realtime = criteria.Realtime || realtime
+	return s.ScreenStocksPage(ctx, &criteria)
+}
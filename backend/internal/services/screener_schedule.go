@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AlertTypeScreenMatch marks the daily results of a scheduled saved screen
+const AlertTypeScreenMatch AlertType = "screen_match"
+
+// screenMatchListed is how many matches a screen_match alert lists
+const screenMatchListed = 10
+
+// ScreenScheduleWorker runs scheduled saved screens once per trading day,
+// after the day's bars are synced and the indicator snapshots are precomputed,
+// and raises a screen_match alert for the owner when a screen has matches.
+// Before that it records the day's matches of every preset and saved screen.
+type ScreenScheduleWorker struct {
+	screenerService *ScreenerService
+	alertService    *AlertService
+	latestHour      int // Taipei hour after which screens run without waiting for the precompute
+	mu              sync.Mutex
+	isRunning       bool
+	isBusy          bool
+	stopChan        chan struct{}
+}
+
+// NewScreenScheduleWorker reads SCREEN_SCHEDULE_LATEST_HOUR (Taipei hour after
+// which screens run even if the indicator precompute has not finished, default 21)
+func NewScreenScheduleWorker(screenerService *ScreenerService, alertService *AlertService) *ScreenScheduleWorker {
+	hour := 21
+	if v, err := strconv.Atoi(os.Getenv("SCREEN_SCHEDULE_LATEST_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+	return &ScreenScheduleWorker{
+		screenerService: screenerService,
+		alertService:    alertService,
+		latestHour:      hour,
+		stopChan:        make(chan struct{}),
+	}
+}
+
+// Start launches the schedule loop
+func (w *ScreenScheduleWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Screen schedule worker started (after the daily precompute, at the latest %02d:00)", w.latestHour)
+}
+
+// Stop stops the schedule loop
+func (w *ScreenScheduleWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *ScreenScheduleWorker) loop() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun runs the screens due today once today's data is complete
+func (w *ScreenScheduleWorker) checkAndRun() {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	now := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600))
+	today := now.Format("2006-01-02")
+	var ready bool
+	if err := w.screenerService.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+				SELECT 1 FROM stock_ohlcv
+				WHERE timestamp >= $1::date AND timestamp < $1::date + 1 AND `+notIndexSymbolSQL+`
+		   )
+		   AND ($2 OR EXISTS (
+				SELECT 1 FROM indicator_precompute_runs WHERE run_date = $1::date AND status = 'completed'
+		   ))
+	`, today, now.Hour() >= w.latestHour).Scan(&ready); err != nil || !ready {
+		return
+	}
+
+	if run, err := w.screenerService.RecordDailyHits(ctx, today); err != nil {
+		log.Printf("Screen schedule worker: %v", err)
+	} else if run != nil {
+		log.Printf("Screen schedule worker: recorded %d matches of %d screens", run.Hits, run.Screens)
+	}
+
+	if n, err := w.RunDue(ctx, today); err != nil {
+		log.Printf("Screen schedule worker: %v", err)
+	} else if n > 0 {
+		log.Printf("Screen schedule worker: ran %d scheduled screens", n)
+	}
+}
+
+// RunDue runs every scheduled screen not yet run for tradingDate (YYYY-MM-DD)
+// and returns how many ran
+func (w *ScreenScheduleWorker) RunDue(ctx context.Context, tradingDate string) (int, error) {
+	rows, err := w.screenerService.db.QueryContext(ctx, `
+		SELECT id, user_id, last_run_date FROM saved_screens
+		WHERE scheduled AND (last_run_date IS NULL OR last_run_date < $1::date)
+	`, tradingDate)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query scheduled screens: %w", err)
+	}
+	type dueScreen struct {
+		userID  uuid.UUID
+		lastRun sql.NullTime
+	}
+	due := map[string]dueScreen{}
+	for rows.Next() {
+		var id string
+		var d dueScreen
+		if err := rows.Scan(&id, &d.userID, &d.lastRun); err == nil {
+			due[id] = d
+		}
+	}
+	rows.Close()
+
+	ran := 0
+	for id, d := range due {
+		userID := d.userID
+		// Claim the run so a restart or a slow run cannot repeat it
+		result, err := w.screenerService.db.ExecContext(ctx, `
+			UPDATE saved_screens SET last_run_date = $2::date
+			WHERE id = $1 AND scheduled AND (last_run_date IS NULL OR last_run_date < $2::date)
+		`, id, tradingDate)
+		if err != nil {
+			return ran, fmt.Errorf("failed to claim screen run: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			continue
+		}
+
+		sc, err := w.screenerService.GetSavedScreen(ctx, userID, id)
+		if err != nil {
+			log.Printf("Screen schedule worker: screen %s failed: %v", id, err)
+			w.releaseRun(ctx, id, tradingDate, d.lastRun)
+			continue
+		}
+		// Scheduled runs report the day's close, not the last realtime snapshot
+		sc.Criteria.Realtime = false
+		page, err := w.screenerService.RunSavedScreen(ctx, sc, 0, 0, false)
+		if err != nil {
+			log.Printf("Screen schedule worker: screen %s failed: %v", id, err)
+			w.releaseRun(ctx, id, tradingDate, d.lastRun)
+			continue
+		}
+		ran++
+		w.screenerService.db.ExecContext(ctx, `UPDATE saved_screens SET last_run_matches = $2 WHERE id = $1`, id, page.Total)
+		if len(page.Results) == 0 {
+			continue
+		}
+		if err := w.alertService.CreateAlert(ctx, screenMatchAlert(userID, sc, tradingDate, page)); err != nil && err != ErrAlertDuplicate {
+			log.Printf("Screen schedule worker: failed to create alert for screen %s: %v", id, err)
+		}
+	}
+	return ran, nil
+}
+
+// releaseRun gives back a claimed run that failed, restoring the screen's
+// previous last_run_date so the next check retries it
+func (w *ScreenScheduleWorker) releaseRun(ctx context.Context, id, tradingDate string, lastRun sql.NullTime) {
+	if _, err := w.screenerService.db.ExecContext(ctx, `
+		UPDATE saved_screens SET last_run_date = $3 WHERE id = $1 AND last_run_date = $2::date
+	`, id, tradingDate, lastRun); err != nil {
+		log.Printf("Screen schedule worker: failed to release screen %s: %v", id, err)
+	}
+}
+
+// screenMatchAlert builds the owner's alert for a scheduled run with matches;
+// the top match stands in as the alert's symbol
+func screenMatchAlert(userID uuid.UUID, sc *SavedScreen, tradingDate string, page *ScreenerPage) *StockAlert {
+	listed := page.Results
+	if len(listed) > screenMatchListed {
+		listed = listed[:screenMatchListed]
+	}
+
+	var lines []string
+	matches := make([]map[string]interface{}, 0, len(listed))
+	for _, r := range listed {
+		lines = append(lines, fmt.Sprintf("%s %s %.2f (%+.2f%%)", r.Symbol, r.Name, r.CurrentPrice, r.ChangePercent))
+		matches = append(matches, map[string]interface{}{
+			"symbol":         r.Symbol,
+			"name":           r.Name,
+			"price":          r.CurrentPrice,
+			"change_percent": roundTo(r.ChangePercent, 2),
+			"score":          roundTo(r.Score, 2),
+		})
+	}
+	if page.Total > len(listed) {
+		lines = append(lines, fmt.Sprintf("…另有 %d 檔", page.Total-len(listed)))
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"user_id":      userID,
+		"screen_id":    sc.ID,
+		"screen_name":  sc.Name,
+		"trading_date": tradingDate,
+		"count":        page.Total,
+		"matches":      matches,
+	})
+	return &StockAlert{
+		Symbol:         listed[0].Symbol,
+		AlertType:      AlertTypeScreenMatch,
+		Severity:       AlertSeverityWarning,
+		Title:          fmt.Sprintf("自訂篩選「%s」%s 共 %d 檔符合", sc.Name, tradingDate, page.Total),
+		Message:        strings.Join(lines, "\n"),
+		Data:           data,
+		ReferencePrice: listed[0].CurrentPrice,
+		DedupKey:       "screen:" + sc.ID + ":" + tradingDate,
+	}
+}
@@ -0,0 +1,53 @@
+package services
+
+import "strings"
+
+// Security types told apart by TWSE/TPEx code conventions
+const (
+	SecurityStock     = "stock"
+	SecurityETF       = "etf"       // 00xx(x)
+	SecurityETN       = "etn"       // 020xxx
+	SecurityWarrant   = "warrant"   // 6-character 03-08xxxx (TWSE) and 70-73xxxx (TPEx)
+	SecurityTDR       = "tdr"       // 91xxxx
+	SecurityPreferred = "preferred" // Common stock code plus a letter, e.g. 2881A
+	SecurityOther     = "other"
+)
+
+// IsSecurityType reports whether t is one of the Security constants
+func IsSecurityType(t string) bool {
+	switch t {
+	case SecurityStock, SecurityETF, SecurityETN, SecurityWarrant, SecurityTDR, SecurityPreferred, SecurityOther:
+		return true
+	}
+	return false
+}
+
+// securityType classifies a symbol by its code
+func securityType(symbol string) string {
+	n := len(symbol)
+	switch {
+	case strings.HasPrefix(symbol, "00"):
+		return SecurityETF
+	case strings.HasPrefix(symbol, "020"):
+		return SecurityETN
+	case n == 6 && symbol[0] == '0' && symbol[1] >= '3' && symbol[1] <= '8',
+		n == 6 && symbol[0] == '7' && symbol[1] >= '0' && symbol[1] <= '3':
+		return SecurityWarrant
+	case n == 6 && strings.HasPrefix(symbol, "91"):
+		return SecurityTDR
+	case n == 4 && isDigits(symbol):
+		return SecurityStock
+	case n == 5 && isDigits(symbol[:4]) && symbol[4] >= 'A' && symbol[4] <= 'Z':
+		return SecurityPreferred
+	}
+	return SecurityOther
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
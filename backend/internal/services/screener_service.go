@@ -2,22 +2,42 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"psm-backend/internal/database"
 	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
 )
 
 // ScreenerService handles stock screening and recommendations
 type ScreenerService struct {
-	db *database.DB
+	db                *database.DB
+	expressionService *ExpressionService
+	intradayMu        sync.RWMutex
+	intraday          *intradaySnapshot // See RefreshIntraday
 }
 
-func NewScreenerService(db *database.DB) *ScreenerService {
-	return &ScreenerService{db: db}
+func NewScreenerService(db *database.DB, expressionService *ExpressionService) *ScreenerService {
+	return &ScreenerService{db: db, expressionService: expressionService}
 }
 
 // ScreenerCriteria defines screening criteria
 type ScreenerCriteria struct {
+	// Universe criteria
+	Industry                string   `json:"industry"`                   // taiwan_stocks industry, e.g. 半導體
+	Industries              []string `json:"industries"`                 // Any of these industries (combined with industry)
+	Market                  string   `json:"market"`                     // TSE (上市) or OTC (上櫃)
+	ExcludeInactive         bool     `json:"exclude_inactive"`           // Skip stocks marked inactive in taiwan_stocks
+	ExcludeETFs             bool     `json:"exclude_etfs"`               // Skip ETFs and ETNs
+	ExcludeWarrants         bool     `json:"exclude_warrants"`           // Skip warrants (權證)
+	ExcludeFullCashDelivery bool     `json:"exclude_full_cash_delivery"` // Skip full-cash-delivery stocks (全額交割)
+	ExcludeDisposition      bool     `json:"exclude_disposition"`        // Skip stocks under disposition (處置)
+	SecurityType            string   `json:"security_type"`              // Only this type, e.g. etf; see the Security constants
+
 	// Price criteria
 	MinPrice     float64 `json:"min_price"`
 	MaxPrice     float64 `json:"max_price"`
@@ -25,6 +45,8 @@ type ScreenerCriteria struct {
 	// Volume criteria
 	MinVolume         int64   `json:"min_volume"`
 	MinVolumeRatio    float64 `json:"min_volume_ratio"`    // vs 20-day avg
+	MinTurnover       float64 `json:"min_turnover"`        // Latest day's turnover (成交金額), TWD
+	MinAvgTurnover    float64 `json:"min_avg_turnover"`    // 20-day average turnover, TWD
 	
 	// Technical criteria
 	AboveMA20         bool    `json:"above_ma20"`
@@ -32,66 +54,321 @@ type ScreenerCriteria struct {
 	RSIMin            float64 `json:"rsi_min"`
 	RSIMax            float64 `json:"rsi_max"`
 	GoldenCross       bool    `json:"golden_cross"`        // MA5 > MA20 recently
+	BBSqueeze         bool    `json:"bb_squeeze"`          // Bollinger band width in the bottom percentile
+	BBSqueezePercentile float64 `json:"bb_squeeze_percentile"` // Squeeze threshold percentile (default 10)
+	MACDTurnPositive  bool    `json:"macd_turn_positive"`  // MACD histogram crossed above zero on the latest snapshot
+	KDJGoldenCross    bool    `json:"kdj_golden_cross"`    // K crossed above D on the latest snapshot
+	TouchLowerBB      bool    `json:"touch_lower_bb"`      // Price at or within 1% above the lower Bollinger band
+	LimitUp           bool    `json:"limit_up"`            // Closed at limit up (漲停)
+	LimitDown         bool    `json:"limit_down"`          // Closed at limit down (跌停)
+	LimitIntraday     bool    `json:"limit_intraday"`      // With limit_up/limit_down, touching the limit during the day is enough
+	ExcludeLimitUp    bool    `json:"exclude_limit_up"`    // Skip stocks that closed at limit up, e.g. to avoid chasing
+	Expression        string  `json:"expression"`          // Custom condition, e.g. "close > MA(20) AND RSI(14) < 40"
+	ExpressionID      string  `json:"expression_id"`       // Saved expression (used when Expression is empty)
 	
 	// Performance criteria
 	MinChangePercent  float64 `json:"min_change_percent"`
 	MaxChangePercent  float64 `json:"max_change_percent"`
 	Near52WeekHigh    bool    `json:"near_52_week_high"`
 	Near52WeekLow     bool    `json:"near_52_week_low"`
+	OutperformTAIEX   bool    `json:"outperform_taiex"`   // Return over rs_days beats TAIEX by more than rs_margin
+	RSDays            int     `json:"rs_days"`            // 20 or 60 (default 20)
+	RSMargin          float64 `json:"rs_margin"`          // Percentage points above TAIEX's return (default 0)
+	
+	// Institutional criteria (三大法人), on the latest day with institutional data
+	ForeignBuyDays    int     `json:"foreign_buy_days"`     // Foreign investors net-bought at least this many consecutive days
+	TrustBuyDays      int     `json:"trust_buy_days"`       // Investment trusts net-bought at least this many consecutive days
+	MinForeignNetLots int64   `json:"min_foreign_net_lots"` // Foreign net buy of at least this many lots (張)
+	MinTrustNetLots   int64   `json:"min_trust_net_lots"`   // Investment trust net buy of at least this many lots
 	
 	// Sentiment criteria
 	PositiveSentiment bool    `json:"positive_sentiment"`
+	AIStance          string  `json:"ai_stance"`         // bullish, neutral or bearish (latest AI analysis within 7 days)
+	MinAIConfidence   float64 `json:"min_ai_confidence"` // 0 to 1, with ai_stance
 	
 	// Sorting and limits
-	SortBy            string  `json:"sort_by"` // volume_ratio, change_percent, rsi
+	SortBy            string  `json:"sort_by"` // volume_ratio, turnover, change_percent, rsi, bb_bandwidth_pct, beta, relative_strength, foreign_streak, trust_streak
 	SortDesc          bool    `json:"sort_desc"`
 	Limit             int     `json:"limit"`
+	Offset            int     `json:"offset"` // Matches to skip, for the next page
+	Realtime          bool    `json:"realtime"` // Screen the realtime snapshot (top turnover stocks, live quotes) instead of the last close
 }
 
 // ScreenerResult represents a single screening result
 type ScreenerResult struct {
 	Symbol           string   `json:"symbol"`
 	Name             string   `json:"name"`
+	Industry         string   `json:"industry,omitempty"`
+	Market           string   `json:"market,omitempty"`
+	SecurityType     string   `json:"security_type"` // See the Security constants
+	FullCashDelivery bool     `json:"full_cash_delivery,omitempty"`
+	Disposition      bool     `json:"disposition,omitempty"`
 	CurrentPrice     float64  `json:"current_price"`
 	PreviousClose    float64  `json:"previous_close"`
+	DayHigh          float64  `json:"day_high"`
+	DayLow           float64  `json:"day_low"`
+	LimitUpPrice     float64  `json:"limit_up_price,omitempty"`
+	LimitDownPrice   float64  `json:"limit_down_price,omitempty"`
+	LimitHit         string   `json:"limit_hit,omitempty"` // See the LimitHit constants
 	Change           float64  `json:"change"`
 	ChangePercent    float64  `json:"change_percent"`
 	Volume           int64    `json:"volume"`
 	AvgVolume        int64    `json:"avg_volume"`
 	VolumeRatio      float64  `json:"volume_ratio"`
+	Turnover         float64  `json:"turnover"`     // 成交金額, TWD
+	AvgTurnover      float64  `json:"avg_turnover"` // 20-day average, TWD
 	High52Week       float64  `json:"high_52_week"`
 	Low52Week        float64  `json:"low_52_week"`
 	MA5              float64  `json:"ma5"`
 	MA20             float64  `json:"ma20"`
 	MA60             float64  `json:"ma60"`
 	RSI              float64  `json:"rsi"`
+	MACD             float64  `json:"macd"`
+	MACDSignal       float64  `json:"macd_signal"`
+	MACDHistogram    float64  `json:"macd_histogram"`
+	BBBandwidth      float64  `json:"bb_bandwidth"`
+	BBBandwidthPct   float64  `json:"bb_bandwidth_pct"`
+	BBUpper          float64  `json:"bb_upper"`
+	BBLower          float64  `json:"bb_lower"`
+	KDJK             float64  `json:"kdj_k"`
+	KDJD             float64  `json:"kdj_d"`
+	Beta             *float64 `json:"beta,omitempty"`        // ~60-day beta vs TAIEX
+	Correlation      *float64 `json:"correlation,omitempty"` // ~60-day return correlation vs TAIEX
+	RelativeStrength20 *float64 `json:"rs_20d,omitempty"` // 20-day return minus TAIEX's, percentage points
+	RelativeStrength60 *float64 `json:"rs_60d,omitempty"` // 60-day return minus TAIEX's, percentage points
+	HasIndicators    bool     `json:"has_indicators"` // Whether a persisted indicator snapshot was found
+	HasRSI           bool     `json:"has_rsi"`        // Whether the snapshot carries an RSI value
+	ForeignNetLots   int64    `json:"foreign_net_lots"` // Latest institutional day, lots (張)
+	TrustNetLots     int64    `json:"trust_net_lots"`
+	DealerNetLots    int64    `json:"dealer_net_lots"`
+	ForeignStreak    int      `json:"foreign_streak"` // Consecutive net buying days, negative for net selling
+	TrustStreak      int      `json:"trust_streak"`
+	HasInstitutional bool     `json:"has_institutional"` // Whether institutional trades were found
 	Sentiment        string   `json:"sentiment"`
 	SentimentScore   float64  `json:"sentiment_score"`
+	AIStance         string   `json:"ai_stance,omitempty"`
+	AIConfidence     float64  `json:"ai_confidence,omitempty"`
 	Score            float64  `json:"score"`  // Composite score
+	ScoreBreakdown   ScoreBreakdown `json:"score_breakdown"`
 	MatchedCriteria  []string `json:"matched_criteria"`
+
+	active bool // taiwan_stocks.is_active; true for symbols missing from it
+
+	// Values of the snapshot before the latest, for crossovers
+	prevMACDHistogram sql.NullFloat64
+	prevKDJK          sql.NullFloat64
+	prevKDJD          sql.NullFloat64
+}
+
+// bbLowerTouchTolerance is how far above the lower band still counts as touching it
+const bbLowerTouchTolerance = 0.01
+
+// ScoreBreakdown is each factor's contribution to a result's composite score
+type ScoreBreakdown struct {
+	Volume     float64 `json:"volume"`       // 0-20, volume vs 20-day average
+	Trend      float64 `json:"trend"`        // 0-30, above MA20, above MA60, MA5 above MA20
+	Momentum   float64 `json:"momentum"`     // 0-20, today's gain
+	Sentiment  float64 `json:"sentiment"`    // 0-20, 7-day news sentiment
+	Position52 float64 `json:"position_52w"` // 0-10, close to the 52-week high
+}
+// Markets a ScreenerCriteria can be restricted to
+var screenerMarkets = map[string]bool{"TSE": true, "OTC": true}
+
+// normalizeUniverse validates the universe criteria and merges industry into industries
+func (c *ScreenerCriteria) normalizeUniverse() error {
+	seen := map[string]bool{}
+	var industries []string
+	for _, industry := range append([]string{c.Industry}, c.Industries...) {
+		if industry = strings.TrimSpace(industry); industry != "" && !seen[industry] {
+			seen[industry] = true
+			industries = append(industries, industry)
+		}
+	}
+	c.Industry = ""
+	c.Industries = industries
+	if len(industries) == 1 {
+		c.Industry, c.Industries = industries[0], nil
+	}
+
+	c.Market = strings.ToUpper(strings.TrimSpace(c.Market))
+	if c.Market != "" && !screenerMarkets[c.Market] {
+		return fmt.Errorf("invalid market: must be TSE or OTC")
+	}
+	return nil
+}
+
+// industryList returns every industry the criteria accept; empty means any
+func (c *ScreenerCriteria) industryList() []string {
+	if c.Industry == "" {
+		return c.Industries
+	}
+	return append([]string{c.Industry}, c.Industries...)
+}
+
+// rsDays returns the relative strength period, 60 or the default 20
+func (c *ScreenerCriteria) rsDays() int {
+	if c.RSDays == 60 {
+		return 60
+	}
+	return 20
+}
+
+// relativeStrength returns the result's relative strength over 20 or 60 days
+func (r *ScreenerResult) relativeStrength(days int) *float64 {
+	if days == 60 {
+		return r.RelativeStrength60
+	}
+	return r.RelativeStrength20
 }
 
-// ScreenStocks screens stocks based on criteria
+// ScreenerPage is one page of screening results
+type ScreenerPage struct {
+	Results    []ScreenerResult `json:"results"`
+	Total      int              `json:"total"` // Matches across all pages
+	Offset     int              `json:"offset"`
+	Limit      int              `json:"limit"`
+	HasMore    bool             `json:"has_more"`
+	RealtimeAt *time.Time       `json:"realtime_at,omitempty"` // Snapshot time of a realtime screen
+}
+
+// ScreenStocks screens stocks based on criteria and returns the requested page
 func (s *ScreenerService) ScreenStocks(ctx context.Context, criteria *ScreenerCriteria) ([]ScreenerResult, error) {
+	page, err := s.ScreenStocksPage(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
+	return page.Results, nil
+}
+
+// ScreenStocksPage screens stocks based on criteria, returning the page at
+// criteria.Offset along with the total number of matches
+func (s *ScreenerService) ScreenStocksPage(ctx context.Context, criteria *ScreenerCriteria) (*ScreenerPage, error) {
 	if criteria.Limit <= 0 {
 		criteria.Limit = 50
 	}
+	if criteria.Offset < 0 {
+		return nil, fmt.Errorf("offset must not be negative")
+	}
+	expression, err := s.prepareCriteria(ctx, criteria)
+	if err != nil {
+		return nil, err
+	}
 
-	// Get all stocks with recent data and calculate metrics
-	query := `
-		WITH recent_prices AS (
+	var candidates []ScreenerResult
+	var realtimeAt *time.Time
+	if criteria.Realtime {
+		var at time.Time
+		candidates, at, err = s.intradayCandidates()
+		realtimeAt = &at
+	} else {
+		candidates, err = s.fetchCandidates(ctx, time.Now(), criteria, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := s.screenCandidates(ctx, candidates, criteria, expression)
+
+	// Apply offset and limit
+	page := &ScreenerPage{Total: len(results), Offset: criteria.Offset, Limit: criteria.Limit, RealtimeAt: realtimeAt}
+	if criteria.Offset < len(results) {
+		results = results[criteria.Offset:]
+	} else {
+		results = []ScreenerResult{}
+	}
+	if len(results) > criteria.Limit {
+		results = results[:criteria.Limit]
+	}
+	page.Results = results
+	page.HasMore = page.Offset+len(results) < page.Total
+
+	return page, nil
+}
+
+// prepareCriteria validates criteria, normalizes the universe and compiles the
+// custom expression, if any
+func (s *ScreenerService) prepareCriteria(ctx context.Context, criteria *ScreenerCriteria) (*ParsedExpression, error) {
+	if criteria.AIStance != "" && normalizeStance(criteria.AIStance) != criteria.AIStance {
+		return nil, fmt.Errorf("invalid ai_stance: must be bullish, neutral or bearish")
+	}
+	if criteria.RSDays != 0 && criteria.RSDays != 20 && criteria.RSDays != 60 {
+		return nil, fmt.Errorf("invalid rs_days: must be 20 or 60")
+	}
+	if criteria.SecurityType != "" && !IsSecurityType(criteria.SecurityType) {
+		return nil, fmt.Errorf("invalid security_type: must be stock, etf, etn, warrant, tdr, preferred or other")
+	}
+	if err := criteria.normalizeUniverse(); err != nil {
+		return nil, err
+	}
+
+	// Compile the custom expression once; it is evaluated per candidate after the cheap filters
+	if criteria.Expression == "" && criteria.ExpressionID != "" {
+		saved, err := s.expressionService.GetExpression(ctx, criteria.ExpressionID)
+		if err != nil {
+			return nil, err
+		}
+		criteria.Expression = saved.Expression
+	}
+	if criteria.Expression == "" {
+		return nil, nil
+	}
+	parsed, err := ParseExpression(criteria.Expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression: %w", err)
+	}
+	if !parsed.IsBoolean() {
+		return nil, fmt.Errorf("invalid expression: screener expressions must be conditions (e.g. close > MA(20))")
+	}
+	return parsed, nil
+}
+
+// screenCandidates returns the candidates matching criteria, scored and sorted
+func (s *ScreenerService) screenCandidates(ctx context.Context, candidates []ScreenerResult, criteria *ScreenerCriteria, expression *ParsedExpression) []ScreenerResult {
+	results := []ScreenerResult{}
+	for _, r := range candidates {
+		// Apply filters
+		if !s.matchesCriteria(&r, criteria) {
+			continue
+		}
+		if expression != nil {
+			matched, err := s.expressionService.MatchesLatest(ctx, r.Symbol, expression)
+			if err != nil || !matched {
+				continue
+			}
+			r.MatchedCriteria = append(r.MatchedCriteria, "自訂條件")
+		}
+
+		// Calculate composite score
+		r.Score = s.calculateScore(&r, criteria)
+
+		results = append(results, r)
+	}
+
+	// Sort results
+	s.sortResults(results, criteria)
+	return results
+}
+
+// screenerMetricsCTEs compute the per-symbol price, moving average, 52-week,
+// sentiment and beta metrics as of $1 into a metrics CTE. They scan the
+// OHLCV history, so ScreenStocks reads the nightly copy in screener_daily
+// when it is current; see RefreshDailyMetrics.
+const screenerMetricsCTEs = `
+		recent_prices AS (
 			SELECT 
 				symbol,
 				close as current_price,
+				high,
+				low,
 				volume,
+				turnover,
 				timestamp,
 				LAG(close) OVER (PARTITION BY symbol ORDER BY timestamp) as prev_close,
 				ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp DESC) as rn
 			FROM stock_ohlcv
-			WHERE timestamp >= NOW() - INTERVAL '2 days'
+			WHERE timestamp >= $1::timestamptz - INTERVAL '2 days' AND timestamp <= $1 AND ` + notIndexSymbolSQL + `
 		),
 		latest_prices AS (
-			SELECT symbol, current_price, volume, prev_close
+			SELECT symbol, current_price, high, low, volume, turnover, prev_close
 			FROM recent_prices
 			WHERE rn = 1
 		),
@@ -101,12 +378,13 @@ func (s *ScreenerService) ScreenStocks(ctx context.Context, criteria *ScreenerCr
 				AVG(CASE WHEN rn <= 5 THEN close END) as ma5,
 				AVG(CASE WHEN rn <= 20 THEN close END) as ma20,
 				AVG(CASE WHEN rn <= 60 THEN close END) as ma60,
-				AVG(CASE WHEN rn <= 20 THEN volume END)::bigint as avg_volume
+				AVG(CASE WHEN rn <= 20 THEN volume END)::bigint as avg_volume,
+				AVG(CASE WHEN rn <= 20 THEN turnover END) as avg_turnover
 			FROM (
-				SELECT symbol, close, volume,
+				SELECT symbol, close, volume, turnover,
 					   ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY timestamp DESC) as rn
 				FROM stock_ohlcv
-				WHERE timestamp >= NOW() - INTERVAL '90 days'
+				WHERE timestamp >= $1::timestamptz - INTERVAL '90 days' AND timestamp <= $1
 			) sub
 			GROUP BY symbol
 		),
@@ -116,7 +394,7 @@ func (s *ScreenerService) ScreenStocks(ctx context.Context, criteria *ScreenerCr
 				MAX(high) as high_52,
 				MIN(low) as low_52
 			FROM stock_ohlcv
-			WHERE timestamp >= NOW() - INTERVAL '365 days'
+			WHERE timestamp >= $1::timestamptz - INTERVAL '365 days' AND timestamp <= $1
 			GROUP BY symbol
 		),
 		sentiment_data AS (
@@ -129,32 +407,234 @@ func (s *ScreenerService) ScreenStocks(ctx context.Context, criteria *ScreenerCr
 				END as sentiment,
 				COALESCE(AVG(sentiment_score), 0) as sentiment_score
 			FROM stock_news
-			WHERE published_at >= NOW() - INTERVAL '7 days' AND sentiment_score IS NOT NULL
+			WHERE published_at >= $1::timestamptz - INTERVAL '7 days' AND published_at <= $1 AND sentiment_score IS NOT NULL
+			GROUP BY symbol
+		),
+		index_returns AS (
+			SELECT DATE(timestamp) as d,
+				(close / NULLIF(LAG(close) OVER (ORDER BY timestamp), 0) - 1)::float8 as ret
+			FROM stock_ohlcv
+			WHERE symbol = ` + indexSymbolSQL + ` AND timestamp >= $1::timestamptz - INTERVAL '100 days' AND timestamp <= $1
+		),
+		stock_returns AS (
+			SELECT symbol, DATE(timestamp) as d,
+				(close / NULLIF(LAG(close) OVER (PARTITION BY symbol ORDER BY timestamp), 0) - 1)::float8 as ret
+			FROM stock_ohlcv
+			WHERE timestamp >= $1::timestamptz - INTERVAL '100 days' AND timestamp <= $1 AND ` + notIndexSymbolSQL + `
+		),
+		beta_data AS (
+			-- ~60 trading-day beta/correlation of daily returns vs TAIEX
+			SELECT sr.symbol,
+				regr_slope(sr.ret, ir.ret) as beta,
+				corr(sr.ret, ir.ret) as correlation
+			FROM stock_returns sr
+			JOIN index_returns ir ON sr.d = ir.d
+			WHERE sr.ret IS NOT NULL AND ir.ret IS NOT NULL
+			GROUP BY sr.symbol
+			HAVING COUNT(*) >= 20
+		),
+		relative_strength AS (
+			-- Compounded return over the latest 20 and 60 TAIEX trading days minus
+			-- TAIEX's own, in percentage points; NULL when the stock missed too many days
+			SELECT sr.symbol,
+				CASE WHEN COUNT(*) FILTER (WHERE ir.rn <= 20) >= 18 THEN
+					(EXP(SUM(LN(1 + sr.ret)) FILTER (WHERE ir.rn <= 20)) - EXP(SUM(LN(1 + ir.ret)) FILTER (WHERE ir.rn <= 20))) * 100
+				END as rs_20d,
+				CASE WHEN COUNT(*) >= 54 THEN
+					(EXP(SUM(LN(1 + sr.ret))) - EXP(SUM(LN(1 + ir.ret)))) * 100
+				END as rs_60d
+			FROM stock_returns sr
+			JOIN (
+				SELECT d, ret, ROW_NUMBER() OVER (ORDER BY d DESC) as rn
+				FROM index_returns
+				WHERE ret > -1
+			) ir ON sr.d = ir.d
+			WHERE sr.ret > -1 AND ir.rn <= 60
+			GROUP BY sr.symbol
+		),
+		metrics AS (
+			SELECT
+				lp.symbol,
+				lp.current_price,
+				COALESCE(lp.prev_close, lp.current_price) as prev_close,
+				lp.high as day_high,
+				lp.low as day_low,
+				lp.volume,
+				COALESCE(ma.avg_volume, 0) as avg_volume,
+				COALESCE(lp.turnover, 0) as turnover,
+				COALESCE(ma.avg_turnover, 0) as avg_turnover,
+				COALESCE(ma.ma5, 0) as ma5,
+				COALESCE(ma.ma20, 0) as ma20,
+				COALESCE(ma.ma60, 0) as ma60,
+				COALESCE(yr.high_52, 0) as high_52,
+				COALESCE(yr.low_52, 0) as low_52,
+				COALESCE(sd.sentiment, 'unknown') as sentiment,
+				COALESCE(sd.sentiment_score, 0) as sentiment_score,
+				bd.beta,
+				bd.correlation,
+				rs.rs_20d,
+				rs.rs_60d
+			FROM latest_prices lp
+			LEFT JOIN moving_averages ma ON lp.symbol = ma.symbol
+			LEFT JOIN yearly_range yr ON lp.symbol = yr.symbol
+			LEFT JOIN sentiment_data sd ON lp.symbol = sd.symbol
+			LEFT JOIN beta_data bd ON lp.symbol = bd.symbol
+			LEFT JOIN relative_strength rs ON lp.symbol = rs.symbol
+			WHERE lp.current_price > 0
+		)`
+
+// screenerDailyMetrics reads the metrics CTE from the latest screener_daily
+// day on or before $1
+const screenerDailyMetrics = `
+		metrics AS (
+			SELECT symbol, current_price, prev_close,
+			       COALESCE(day_high, current_price) as day_high, COALESCE(day_low, current_price) as day_low,
+			       volume, avg_volume, ma5, ma20, ma60, high_52, low_52, sentiment, sentiment_score, beta, correlation,
+			       rs_20d, rs_60d, COALESCE(turnover, 0) as turnover, COALESCE(avg_turnover, 0) as avg_turnover
+			FROM screener_daily
+			WHERE trade_date = (SELECT MAX(trade_date) FROM screener_daily WHERE trade_date <= $1::date)
+		)`
+
+// fetchCandidates loads stock metrics as of the given time. Only the universe criteria
+// (industries, market, inactive stocks) and the price and volume bounds of the given
+// criteria are applied; pass nil to load every stock. Only data up to asOf is used, so
+// historical dates can be replayed without look-ahead. With useDaily the OHLCV-derived
+// metrics come from screener_daily if it covers the latest bars up to asOf.
+func (s *ScreenerService) fetchCandidates(ctx context.Context, asOf time.Time, universe *ScreenerCriteria, useDaily bool) ([]ScreenerResult, error) {
+	if universe == nil {
+		universe = &ScreenerCriteria{}
+	}
+
+	metrics := screenerMetricsCTEs
+	if useDaily {
+		var current bool
+		if err := s.db.QueryRowContext(ctx, `
+			SELECT COALESCE((SELECT MAX(trade_date) FROM screener_daily WHERE trade_date <= $1::date)
+				>= (SELECT MAX(timestamp)::date FROM stock_ohlcv WHERE timestamp <= $1 AND ` + notIndexSymbolSQL + `), false)
+		`, asOf).Scan(&current); err == nil && current {
+			metrics = screenerDailyMetrics
+		}
+	}
+
+	query := `
+		WITH ` + metrics + `,
+		indicator_data AS (
+			-- Latest snapshot within 7 days, with the one before it for crossovers
+			SELECT symbol, rsi14, macd, macd_signal, macd_histogram, bb_bandwidth, bb_bandwidth_pct,
+				bb_upper, bb_lower, kdj_k, kdj_d, prev_macd_histogram, prev_kdj_k, prev_kdj_d
+			FROM (
+				SELECT *,
+					LAG(macd_histogram) OVER w as prev_macd_histogram,
+					LAG(kdj_k) OVER w as prev_kdj_k,
+					LAG(kdj_d) OVER w as prev_kdj_d,
+					ROW_NUMBER() OVER (PARTITION BY symbol ORDER BY snapshot_date DESC) as rn
+				FROM indicator_snapshots
+				WHERE snapshot_date >= $1::date - INTERVAL '14 days' AND snapshot_date <= $1::date
+				WINDOW w AS (PARTITION BY symbol ORDER BY snapshot_date)
+			) snaps
+			WHERE rn = 1 AND snapshot_date >= $1::date - INTERVAL '7 days'
+		),
+		institutional_dates AS (
+			-- Latest 20 days with institutional trades
+			SELECT DISTINCT trade_date FROM stock_institutional_trades
+			WHERE trade_date >= $1::date - INTERVAL '45 days' AND trade_date <= $1::date
+			ORDER BY trade_date DESC
+			LIMIT 20
+		),
+		institutional_data AS (
+			-- Net shares per day, newest first, 0 on days a symbol is missing from the report
+			SELECT s.symbol,
+				ARRAY_AGG(COALESCE(t.foreign_net, 0) ORDER BY d.trade_date DESC) as foreign_nets,
+				ARRAY_AGG(COALESCE(t.trust_net, 0) ORDER BY d.trade_date DESC) as trust_nets,
+				ARRAY_AGG(COALESCE(t.dealer_net, 0) ORDER BY d.trade_date DESC) as dealer_nets
+			FROM (
+				SELECT DISTINCT symbol FROM stock_institutional_trades
+				WHERE trade_date IN (SELECT trade_date FROM institutional_dates)
+			) s
+			CROSS JOIN institutional_dates d
+			LEFT JOIN stock_institutional_trades t ON t.symbol = s.symbol AND t.trade_date = d.trade_date
+			GROUP BY s.symbol
+		),
+		restriction_data AS (
+			SELECT symbol,
+				BOOL_OR(restriction = 'full_cash_delivery') as full_cash_delivery,
+				BOOL_OR(restriction = 'disposition') as disposition
+			FROM stock_trading_restrictions
+			WHERE start_date <= $1::date AND (end_date IS NULL OR end_date >= $1::date)
 			GROUP BY symbol
+		),
+		ai_stance_data AS (
+			-- Latest structured AI analysis per symbol
+			SELECT DISTINCT ON (symbol) symbol, stance, COALESCE(confidence, 0)::float8 as confidence
+			FROM ai_analysis_cache
+			WHERE stance IS NOT NULL AND analysis_date >= $1::date - INTERVAL '7 days' AND analysis_date <= $1::date
+			ORDER BY symbol, analysis_date DESC, created_at DESC
 		)
 		SELECT 
-			lp.symbol,
-			COALESCE(st.name, st.name_en, lp.symbol) as name,
-			lp.current_price,
-			COALESCE(lp.prev_close, lp.current_price) as prev_close,
-			lp.volume,
-			COALESCE(ma.avg_volume, 0) as avg_volume,
-			COALESCE(ma.ma5, 0) as ma5,
-			COALESCE(ma.ma20, 0) as ma20,
-			COALESCE(ma.ma60, 0) as ma60,
-			COALESCE(yr.high_52, 0) as high_52,
-			COALESCE(yr.low_52, 0) as low_52,
-			COALESCE(sd.sentiment, 'unknown') as sentiment,
-			COALESCE(sd.sentiment_score, 0) as sentiment_score
-		FROM latest_prices lp
-		LEFT JOIN moving_averages ma ON lp.symbol = ma.symbol
-		LEFT JOIN yearly_range yr ON lp.symbol = yr.symbol
-		LEFT JOIN sentiment_data sd ON lp.symbol = sd.symbol
-		LEFT JOIN taiwan_stocks st ON lp.symbol = st.symbol
-		WHERE lp.current_price > 0
+			m.symbol,
+			COALESCE(st.name, st.name_en, m.symbol) as name,
+			COALESCE(st.industry, '') as industry,
+			COALESCE(st.market, '') as market,
+			COALESCE(st.is_active, true) as is_active,
+			COALESCE(st.security_type, '') as security_type,
+			m.current_price,
+			m.prev_close,
+			m.day_high,
+			m.day_low,
+			m.volume,
+			m.avg_volume,
+			m.turnover,
+			m.avg_turnover,
+			m.ma5,
+			m.ma20,
+			m.ma60,
+			m.high_52,
+			m.low_52,
+			m.sentiment,
+			m.sentiment_score,
+			id.rsi14,
+			id.macd,
+			id.macd_signal,
+			id.macd_histogram,
+			id.bb_bandwidth,
+			id.bb_bandwidth_pct,
+			id.bb_upper,
+			id.bb_lower,
+			id.kdj_k,
+			id.kdj_d,
+			id.prev_macd_histogram,
+			id.prev_kdj_k,
+			id.prev_kdj_d,
+			m.beta,
+			m.correlation,
+			m.rs_20d,
+			m.rs_60d,
+			inst.foreign_nets,
+			inst.trust_nets,
+			inst.dealer_nets,
+			COALESCE(rd.full_cash_delivery, false),
+			COALESCE(rd.disposition, false),
+			COALESCE(ai.stance, ''),
+			COALESCE(ai.confidence, 0)
+		FROM metrics m
+		LEFT JOIN indicator_data id ON m.symbol = id.symbol
+		LEFT JOIN institutional_data inst ON m.symbol = inst.symbol
+		LEFT JOIN restriction_data rd ON m.symbol = rd.symbol
+		LEFT JOIN ai_stance_data ai ON m.symbol = ai.symbol
+		LEFT JOIN taiwan_stocks st ON m.symbol = st.symbol
+		WHERE (cardinality($2::text[]) = 0 OR st.industry = ANY($2))
+		  AND ($3 = '' OR st.market = $3)
+		  AND (NOT $4 OR COALESCE(st.is_active, true))
+		  AND (st.delisted_at IS NULL OR st.delisted_at > $1::date)
+		  AND ($5 <= 0 OR m.current_price >= $5)
+		  AND ($6 <= 0 OR m.current_price <= $6)
+		  AND ($7 <= 0 OR m.volume >= $7)
+		  AND ($8 <= 0 OR m.turnover >= $8)
+		  AND ($9 <= 0 OR m.avg_turnover >= $9)
 	`
 
-	rows, err := s.db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, asOf, pq.Array(universe.industryList()), universe.Market, universe.ExcludeInactive,
+		universe.MinPrice, universe.MaxPrice, universe.MinVolume, universe.MinTurnover, universe.MinAvgTurnover)
 	if err != nil {
 		return nil, fmt.Errorf("failed to screen stocks: %w", err)
 	}
@@ -163,14 +643,69 @@ func (s *ScreenerService) ScreenStocks(ctx context.Context, criteria *ScreenerCr
 	var results []ScreenerResult
 	for rows.Next() {
 		var r ScreenerResult
+		var rsi, macd, macdSignal, macdHist, bandwidth, bandwidthPct, bbUpper, bbLower, kdjK, kdjD, beta, correlation sql.NullFloat64
+		var rs20, rs60 sql.NullFloat64
+		var foreignNets, trustNets, dealerNets pq.Int64Array
 		if err := rows.Scan(
-			&r.Symbol, &r.Name, &r.CurrentPrice, &r.PreviousClose, &r.Volume,
-			&r.AvgVolume, &r.MA5, &r.MA20, &r.MA60, &r.High52Week, &r.Low52Week,
+			&r.Symbol, &r.Name, &r.Industry, &r.Market, &r.active, &r.SecurityType, &r.CurrentPrice, &r.PreviousClose,
+			&r.DayHigh, &r.DayLow, &r.Volume,
+			&r.AvgVolume, &r.Turnover, &r.AvgTurnover, &r.MA5, &r.MA20, &r.MA60, &r.High52Week, &r.Low52Week,
 			&r.Sentiment, &r.SentimentScore,
+			&rsi, &macd, &macdSignal, &macdHist, &bandwidth, &bandwidthPct,
+			&bbUpper, &bbLower, &kdjK, &kdjD, &r.prevMACDHistogram, &r.prevKDJK, &r.prevKDJD,
+			&beta, &correlation, &rs20, &rs60,
+			&foreignNets, &trustNets, &dealerNets,
+			&r.FullCashDelivery, &r.Disposition,
+			&r.AIStance, &r.AIConfidence,
 		); err != nil {
 			continue
 		}
 
+		// Symbols missing from taiwan_stocks, e.g. warrants, are classified by code
+		if r.SecurityType == "" {
+			r.SecurityType = securityType(r.Symbol)
+		}
+
+		// Indicator values come from the persisted daily snapshot
+		if rsi.Valid {
+			r.HasIndicators = true
+			r.HasRSI = true
+			r.RSI = rsi.Float64
+		}
+		if macd.Valid && macdSignal.Valid && macdHist.Valid {
+			r.HasIndicators = true
+			r.MACD = macd.Float64
+			r.MACDSignal = macdSignal.Float64
+			r.MACDHistogram = macdHist.Float64
+		}
+		if bandwidth.Valid && bandwidthPct.Valid {
+			r.HasIndicators = true
+			r.BBBandwidth = bandwidth.Float64
+			r.BBBandwidthPct = bandwidthPct.Float64
+		}
+		if bbUpper.Valid && bbLower.Valid {
+			r.HasIndicators = true
+			r.BBUpper = bbUpper.Float64
+			r.BBLower = bbLower.Float64
+		}
+		if kdjK.Valid && kdjD.Valid {
+			r.HasIndicators = true
+			r.KDJK = kdjK.Float64
+			r.KDJD = kdjD.Float64
+		}
+		if beta.Valid && correlation.Valid {
+			r.Beta = &beta.Float64
+			r.Correlation = &correlation.Float64
+		}
+		if rs20.Valid {
+			v := roundTo(rs20.Float64, 2)
+			r.RelativeStrength20 = &v
+		}
+		if rs60.Valid {
+			v := roundTo(rs60.Float64, 2)
+			r.RelativeStrength60 = &v
+		}
+
 		// Calculate derived metrics
 		if r.PreviousClose > 0 {
 			r.Change = r.CurrentPrice - r.PreviousClose
@@ -179,32 +714,60 @@ func (s *ScreenerService) ScreenStocks(ctx context.Context, criteria *ScreenerCr
 		if r.AvgVolume > 0 {
 			r.VolumeRatio = float64(r.Volume) / float64(r.AvgVolume)
 		}
-
-		// Apply filters
-		if !s.matchesCriteria(&r, criteria) {
-			continue
+		r.setLimitHit()
+		if len(foreignNets) > 0 {
+			r.HasInstitutional = true
+			r.ForeignNetLots = foreignNets[0] / 1000
+			r.TrustNetLots = trustNets[0] / 1000
+			r.DealerNetLots = dealerNets[0] / 1000
+			r.ForeignStreak = institutionalStreak(foreignNets)
+			r.TrustStreak = institutionalStreak(trustNets)
 		}
 
-		// Calculate composite score
-		r.Score = s.calculateScore(&r, criteria)
-
 		results = append(results, r)
 	}
 
-	// Sort results
-	s.sortResults(results, criteria)
-
-	// Apply limit
-	if len(results) > criteria.Limit {
-		results = results[:criteria.Limit]
-	}
-
 	return results, nil
 }
 
 func (s *ScreenerService) matchesCriteria(r *ScreenerResult, c *ScreenerCriteria) bool {
 	r.MatchedCriteria = []string{}
 
+	// Universe filters (already applied in SQL by ScreenStocks, needed when replaying)
+	if industries := c.industryList(); len(industries) > 0 {
+		matched := false
+		for _, industry := range industries {
+			if r.Industry == industry {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	if c.Market != "" && r.Market != c.Market {
+		return false
+	}
+	if c.ExcludeInactive && !r.active {
+		return false
+	}
+	if c.SecurityType != "" && r.SecurityType != c.SecurityType {
+		return false
+	}
+	if c.ExcludeETFs && (r.SecurityType == SecurityETF || r.SecurityType == SecurityETN) {
+		return false
+	}
+	if c.ExcludeWarrants && r.SecurityType == SecurityWarrant {
+		return false
+	}
+	if c.ExcludeFullCashDelivery && r.FullCashDelivery {
+		return false
+	}
+	if c.ExcludeDisposition && r.Disposition {
+		return false
+	}
+
 	// Price filters
 	if c.MinPrice > 0 && r.CurrentPrice < c.MinPrice {
 		return false
@@ -224,6 +787,14 @@ func (s *ScreenerService) matchesCriteria(r *ScreenerResult, c *ScreenerCriteria
 		r.MatchedCriteria = append(r.MatchedCriteria, "成交量放大")
 	}
 
+	// Liquidity filters
+	if c.MinTurnover > 0 && r.Turnover < c.MinTurnover {
+		return false
+	}
+	if c.MinAvgTurnover > 0 && r.AvgTurnover < c.MinAvgTurnover {
+		return false
+	}
+
 	// MA filters
 	if c.AboveMA20 && r.MA20 > 0 && r.CurrentPrice <= r.MA20 {
 		return false
@@ -247,6 +818,111 @@ func (s *ScreenerService) matchesCriteria(r *ScreenerResult, c *ScreenerCriteria
 		r.MatchedCriteria = append(r.MatchedCriteria, "黃金交叉")
 	}
 
+	// RSI filters (require a persisted RSI value)
+	if (c.RSIMin > 0 || c.RSIMax > 0) && !r.HasRSI {
+		return false
+	}
+	if c.RSIMin > 0 && r.RSI < c.RSIMin {
+		return false
+	}
+	if c.RSIMax > 0 && r.RSI > c.RSIMax {
+		return false
+	}
+	if c.RSIMin > 0 || c.RSIMax > 0 {
+		r.MatchedCriteria = append(r.MatchedCriteria, "RSI符合區間")
+	}
+
+	// Bollinger squeeze filter (requires a persisted snapshot with band width)
+	if c.BBSqueeze {
+		threshold := c.BBSqueezePercentile
+		if threshold <= 0 {
+			threshold = 10
+		}
+		if r.BBBandwidth == 0 || r.BBBandwidthPct > threshold {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, "布林通道壓縮")
+	}
+
+	// MACD histogram turning positive (previous snapshot at or below zero)
+	if c.MACDTurnPositive {
+		if !r.prevMACDHistogram.Valid || r.MACDHistogram <= 0 || r.prevMACDHistogram.Float64 > 0 {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, "MACD柱狀翻正")
+	}
+
+	// KDJ golden cross (K above D now, at or below it on the previous snapshot)
+	if c.KDJGoldenCross {
+		if !r.prevKDJK.Valid || !r.prevKDJD.Valid || r.KDJK == 0 || r.KDJK <= r.KDJD || r.prevKDJK.Float64 > r.prevKDJD.Float64 {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, "KD黃金交叉")
+	}
+
+	// Lower Bollinger band touch
+	if c.TouchLowerBB {
+		if r.BBLower <= 0 || r.CurrentPrice > r.BBLower*(1+bbLowerTouchTolerance) {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, "觸及布林下軌")
+	}
+
+	// Limit up/down filters
+	if c.LimitUp || c.LimitDown {
+		matched := false
+		if c.LimitUp && (r.LimitHit == LimitHitUp || c.LimitIntraday && r.LimitHit == LimitHitTouchedUp) {
+			matched = true
+			r.MatchedCriteria = append(r.MatchedCriteria, limitHitLabels[r.LimitHit])
+		}
+		if c.LimitDown && (r.LimitHit == LimitHitDown || c.LimitIntraday && r.LimitHit == LimitHitTouchedDown) {
+			matched = true
+			r.MatchedCriteria = append(r.MatchedCriteria, limitHitLabels[r.LimitHit])
+		}
+		if !matched {
+			return false
+		}
+	}
+	if c.ExcludeLimitUp && r.LimitHit == LimitHitUp {
+		return false
+	}
+
+	// Relative strength vs TAIEX
+	if c.OutperformTAIEX {
+		days := c.rsDays()
+		rs := r.relativeStrength(days)
+		if rs == nil || *rs <= c.RSMargin {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, fmt.Sprintf("%d日強於大盤%+.1f%%", days, *rs))
+	}
+
+	// Institutional buying filters
+	if c.ForeignBuyDays > 0 {
+		if r.ForeignStreak < c.ForeignBuyDays {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, fmt.Sprintf("外資連買%d日", r.ForeignStreak))
+	}
+	if c.TrustBuyDays > 0 {
+		if r.TrustStreak < c.TrustBuyDays {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, fmt.Sprintf("投信連買%d日", r.TrustStreak))
+	}
+	if c.MinForeignNetLots > 0 {
+		if !r.HasInstitutional || r.ForeignNetLots < c.MinForeignNetLots {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, fmt.Sprintf("外資買超%d張", r.ForeignNetLots))
+	}
+	if c.MinTrustNetLots > 0 {
+		if !r.HasInstitutional || r.TrustNetLots < c.MinTrustNetLots {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, fmt.Sprintf("投信買超%d張", r.TrustNetLots))
+	}
+
 	// Change percent filters
 	if c.MinChangePercent != 0 && r.ChangePercent < c.MinChangePercent {
 		return false
@@ -279,61 +955,105 @@ func (s *ScreenerService) matchesCriteria(r *ScreenerResult, c *ScreenerCriteria
 		r.MatchedCriteria = append(r.MatchedCriteria, "正面情緒")
 	}
 
+	// AI stance filter
+	if c.AIStance != "" {
+		if r.AIStance != c.AIStance || r.AIConfidence < c.MinAIConfidence {
+			return false
+		}
+		r.MatchedCriteria = append(r.MatchedCriteria, aiStanceLabels[c.AIStance])
+	}
+
 	return true
 }
 
+var aiStanceLabels = map[string]string{
+	AIStanceBullish: "AI看多",
+	AIStanceNeutral: "AI中性",
+	AIStanceBearish: "AI看空",
+}
+
+// calculateScore returns the composite score and records each factor's share in r.ScoreBreakdown
 func (s *ScreenerService) calculateScore(r *ScreenerResult, c *ScreenerCriteria) float64 {
-	score := 0.0
+	var b ScoreBreakdown
 
 	// Volume factor (0-20 points)
 	if r.VolumeRatio > 1 {
-		score += min(r.VolumeRatio*5, 20)
+		b.Volume = min(r.VolumeRatio*5, 20)
 	}
 
 	// Trend factor (0-30 points)
 	if r.CurrentPrice > r.MA20 && r.MA20 > 0 {
-		score += 10
+		b.Trend += 10
 	}
 	if r.CurrentPrice > r.MA60 && r.MA60 > 0 {
-		score += 10
+		b.Trend += 10
 	}
 	if r.MA5 > r.MA20 && r.MA5 > 0 && r.MA20 > 0 {
-		score += 10
+		b.Trend += 10
 	}
 
 	// Momentum factor (0-20 points)
 	if r.ChangePercent > 0 {
-		score += min(r.ChangePercent*2, 20)
+		b.Momentum = min(r.ChangePercent*2, 20)
 	}
 
 	// Sentiment factor (0-20 points)
 	if r.Sentiment == "positive" {
-		score += 10 + r.SentimentScore*10
+		b.Sentiment = 10 + r.SentimentScore*10
 	} else if r.Sentiment == "neutral" {
-		score += 5
+		b.Sentiment = 5
 	}
 
 	// 52-week position factor (0-10 points)
 	if r.High52Week > 0 && r.Low52Week > 0 {
 		position := (r.CurrentPrice - r.Low52Week) / (r.High52Week - r.Low52Week)
 		if position > 0.8 {
-			score += 10 // Near high is bullish
+			b.Position52 = 10 // Near high is bullish
 		}
 	}
 
-	return score
+	r.ScoreBreakdown = b
+	return b.Volume + b.Trend + b.Momentum + b.Sentiment + b.Position52
 }
 
 func (s *ScreenerService) sortResults(results []ScreenerResult, c *ScreenerCriteria) {
 	sort.Slice(results, func(i, j int) bool {
+		// Stocks without a persisted RSI value have nothing to rank by; keep them last
+		if c.SortBy == "rsi" && results[i].HasRSI != results[j].HasRSI {
+			return results[i].HasRSI
+		}
 		var vi, vj float64
 		switch c.SortBy {
 		case "volume_ratio":
 			vi, vj = results[i].VolumeRatio, results[j].VolumeRatio
+		case "turnover":
+			vi, vj = results[i].Turnover, results[j].Turnover
 		case "change_percent":
 			vi, vj = results[i].ChangePercent, results[j].ChangePercent
 		case "sentiment_score":
 			vi, vj = results[i].SentimentScore, results[j].SentimentScore
+		case "rsi":
+			vi, vj = results[i].RSI, results[j].RSI
+		case "bb_bandwidth_pct":
+			vi, vj = results[i].BBBandwidthPct, results[j].BBBandwidthPct
+		case "relative_strength":
+			if rs := results[i].relativeStrength(c.rsDays()); rs != nil {
+				vi = *rs
+			}
+			if rs := results[j].relativeStrength(c.rsDays()); rs != nil {
+				vj = *rs
+			}
+		case "foreign_streak":
+			vi, vj = float64(results[i].ForeignStreak), float64(results[j].ForeignStreak)
+		case "trust_streak":
+			vi, vj = float64(results[i].TrustStreak), float64(results[j].TrustStreak)
+		case "beta":
+			if results[i].Beta != nil {
+				vi = *results[i].Beta
+			}
+			if results[j].Beta != nil {
+				vj = *results[j].Beta
+			}
 		default: // score
 			vi, vj = results[i].Score, results[j].Score
 		}
@@ -358,29 +1078,36 @@ func (s *ScreenerService) GetPresets() []PresetScreen {
 			Name:        "volume_breakout",
 			Description: "成交量突破 - 成交量達2倍以上均量的股票",
 			Criteria: ScreenerCriteria{
-				MinVolumeRatio: 2.0,
-				MinPrice:       10,
-				SortBy:         "volume_ratio",
-				SortDesc:       true,
-				Limit:          20,
+				ExcludeInactive:         true,
+				ExcludeETFs:             true,
+				ExcludeWarrants:         true,
+				ExcludeFullCashDelivery: true,
+				ExcludeDisposition:      true,
+				MinVolumeRatio:          2.0,
+				MinPrice:                10,
+				SortBy:                  "volume_ratio",
+				SortDesc:                true,
+				Limit:                   20,
 			},
 		},
 		{
 			Name:        "golden_cross",
 			Description: "黃金交叉 - MA5突破MA20的股票",
 			Criteria: ScreenerCriteria{
-				GoldenCross: true,
-				AboveMA20:   true,
-				MinPrice:    10,
-				SortBy:      "score",
-				SortDesc:    true,
-				Limit:       20,
+				ExcludeInactive: true,
+				GoldenCross:     true,
+				AboveMA20:       true,
+				MinPrice:        10,
+				SortBy:          "score",
+				SortDesc:        true,
+				Limit:           20,
 			},
 		},
 		{
 			Name:        "trend_following",
 			Description: "趨勢跟蹤 - 站上60日均線且正向動能",
 			Criteria: ScreenerCriteria{
+				ExcludeInactive:  true,
 				AboveMA60:        true,
 				MinChangePercent: 0,
 				MinPrice:         10,
@@ -393,6 +1120,7 @@ func (s *ScreenerService) GetPresets() []PresetScreen {
 			Name:        "positive_sentiment",
 			Description: "正面情緒 - 近期新聞情緒正面的股票",
 			Criteria: ScreenerCriteria{
+				ExcludeInactive:   true,
 				PositiveSentiment: true,
 				MinPrice:          10,
 				SortBy:            "sentiment_score",
@@ -404,36 +1132,202 @@ func (s *ScreenerService) GetPresets() []PresetScreen {
 			Name:        "52_week_high",
 			Description: "52週新高 - 接近52週最高價的股票",
 			Criteria: ScreenerCriteria{
-				Near52WeekHigh: true,
-				MinPrice:       10,
-				SortBy:         "change_percent",
-				SortDesc:       true,
-				Limit:          20,
+				ExcludeInactive: true,
+				Near52WeekHigh:  true,
+				MinPrice:        10,
+				SortBy:          "change_percent",
+				SortDesc:        true,
+				Limit:           20,
 			},
 		},
 		{
-			Name:        "value_hunting",
-			Description: "價值獵手 - 接近52週低點的潛在反彈股",
+			Name:        "bb_squeeze",
+			Description: "布林壓縮 - 布林通道寬度處於近120日低檔，留意波動突破",
 			Criteria: ScreenerCriteria{
-				Near52WeekLow:    true,
-				MinVolumeRatio:   1.2,
-				MinChangePercent: 0,
+				ExcludeInactive:     true,
+				BBSqueeze:           true,
+				BBSqueezePercentile: 10,
+				MinPrice:            10,
+				SortBy:              "bb_bandwidth_pct",
+				SortDesc:            false,
+				Limit:               20,
+			},
+		},
+		{
+			Name:        "relative_strength",
+			Description: "強於大盤 - 近60日報酬超越加權指數10個百分點以上，依相對強度排序",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive: true,
+				MinVolume:       500000,
+				OutperformTAIEX: true,
+				RSDays:          60,
+				RSMargin:        10,
+				SortBy:          "relative_strength",
+				SortDesc:        true,
+				Limit:           50,
+			},
+		},
+		{
+			Name:        "foreign_buy_streak",
+			Description: "外資連買 - 外資連續3日以上買超，依連買天數排序",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive: true,
+				MinVolume:       500000,
+				ForeignBuyDays:  3,
+				SortBy:          "foreign_streak",
+				SortDesc:        true,
+				Limit:           50,
+			},
+		},
+		{
+			Name:        "trust_buying",
+			Description: "投信認養 - 投信連續3日以上買超且當日買超100張以上",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive: true,
+				TrustBuyDays:    3,
+				MinTrustNetLots: 100,
+				SortBy:          "trust_streak",
+				SortDesc:        true,
+				Limit:           50,
+			},
+		},
+		{
+			Name:        "limit_up",
+			Description: "漲停股 - 收盤鎖漲停的股票，依量能排序",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive:         true,
+				ExcludeETFs:             true,
+				ExcludeWarrants:         true,
+				ExcludeFullCashDelivery: true,
+				ExcludeDisposition:      true,
+				LimitUp:                 true,
+				SortBy:                  "volume_ratio",
+				SortDesc:                true,
+				Limit:                   50,
+			},
+		},
+		{
+			Name:        "macd_turn_positive",
+			Description: "MACD翻多 - MACD柱狀體由負轉正且站上20日均線",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive:  true,
+				MACDTurnPositive: true,
+				AboveMA20:        true,
 				MinPrice:         10,
 				SortBy:           "volume_ratio",
 				SortDesc:         true,
 				Limit:            20,
 			},
 		},
+		{
+			Name:        "kdj_golden_cross",
+			Description: "KD黃金交叉 - K值由下往上穿越D值，RSI未過熱",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive: true,
+				KDJGoldenCross:  true,
+				RSIMax:          70,
+				MinPrice:        10,
+				SortBy:          "score",
+				SortDesc:        true,
+				Limit:           20,
+			},
+		},
+		{
+			Name:        "bb_lower_touch",
+			Description: "布林下軌 - 股價觸及布林通道下軌的超跌股",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive: true,
+				TouchLowerBB:    true,
+				MinVolume:       500000,
+				MinPrice:        10,
+				SortBy:          "rsi",
+				SortDesc:        false,
+				Limit:           20,
+			},
+		},
+		{
+			Name:        "value_hunting",
+			Description: "價值獵手 - 接近52週低點的潛在反彈股",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive:         true,
+				ExcludeETFs:             true,
+				ExcludeWarrants:         true,
+				ExcludeFullCashDelivery: true,
+				ExcludeDisposition:      true,
+				Near52WeekLow:           true,
+				MinVolumeRatio:          1.2,
+				MinChangePercent:        0,
+				MinPrice:                10,
+				SortBy:                  "volume_ratio",
+				SortDesc:                true,
+				Limit:                   20,
+			},
+		},
+		{
+			Name:        "otc_volume_breakout",
+			Description: "上櫃量能突破 - 上櫃股成交量達2倍以上均量且站上20日均線",
+			Criteria: ScreenerCriteria{
+				ExcludeInactive:         true,
+				ExcludeETFs:             true,
+				ExcludeWarrants:         true,
+				ExcludeFullCashDelivery: true,
+				ExcludeDisposition:      true,
+				Market:                  "OTC",
+				MinVolumeRatio:          2.0,
+				AboveMA20:               true,
+				MinPrice:                10,
+				SortBy:                  "volume_ratio",
+				SortDesc:                true,
+				Limit:                   20,
+			},
+		},
+	}
+}
+// RunPreset runs a preset screening, returning the page at offset; a positive
+// limit overrides the preset's page size, and realtime runs it against the
+// realtime snapshot
+func (s *ScreenerService) RunPreset(ctx context.Context, presetName string, offset, limit int, realtime bool) (*ScreenerPage, error) {
+	p, ok := s.findPreset(presetName)
+	if !ok {
+		return nil, fmt.Errorf("preset not found: %s", presetName)
+	}
+	p.Criteria.Offset = offset
+	if limit > 0 {
+		p.Criteria.Limit = limit
 	}
+	p.Criteria.Realtime = realtime
+	return s.ScreenStocksPage(ctx, &p.Criteria)
 }
 
-// RunPreset runs a preset screening
-func (s *ScreenerService) RunPreset(ctx context.Context, presetName string) ([]ScreenerResult, error) {
-	presets := s.GetPresets()
-	for _, p := range presets {
-		if p.Name == presetName {
-			return s.ScreenStocks(ctx, &p.Criteria)
+// findPreset returns the preset with the given name
+func (s *ScreenerService) findPreset(name string) (PresetScreen, bool) {
+	for _, p := range s.GetPresets() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PresetScreen{}, false
+}
+
+// Industries returns the distinct industries of active stocks, usable as the industry criterion
+func (s *ScreenerService) Industries(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT DISTINCT industry FROM taiwan_stocks
+		WHERE is_active AND industry IS NOT NULL AND industry <> ''
+		ORDER BY industry
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list industries: %w", err)
+	}
+	defer rows.Close()
+
+	industries := []string{}
+	for rows.Next() {
+		var industry string
+		if err := rows.Scan(&industry); err != nil {
+			return nil, err
 		}
+		industries = append(industries, industry)
 	}
-	return nil, fmt.Errorf("preset not found: %s", presetName)
+	return industries, rows.Err()
 }
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// sentimentAspect is a topic whose sentiment is tracked separately from the article's
+type sentimentAspect struct {
+	name  string
+	label string
+	terms []string
+}
+
+// sentimentAspects are matched against individual sentences
+var sentimentAspects = []sentimentAspect{
+	{"revenue", "營收獲利", []string{"營收", "獲利", "毛利", "淨利", "EPS", "每股盈餘", "財報", "營益率", "財測"}},
+	{"orders", "訂單需求", []string{"訂單", "接單", "砍單", "急單", "追單", "拉貨", "出貨", "需求", "產能利用率"}},
+	{"lawsuits", "訴訟法規", []string{"訴訟", "官司", "侵權", "專利", "判決", "起訴", "罰款", "裁罰", "調查", "違規"}},
+	{"management", "經營團隊", []string{"董事長", "總經理", "執行長", "經營層", "董事會", "接班", "人事", "經營權", "股東會"}},
+	{"supply_chain", "供應鏈", []string{"供應鏈", "供應商", "缺料", "斷料", "原物料", "產能", "擴產", "停工", "庫存", "交期"}},
+}
+
+// AspectSentiment is the sentiment of one aspect within an article
+type AspectSentiment struct {
+	Aspect    string  `json:"aspect"`
+	Label     string  `json:"label"`
+	Sentiment string  `json:"sentiment"`
+	Score     float64 `json:"score"`
+	Mentions  int     `json:"mentions"` // Sentences mentioning the aspect
+	Snippet   string  `json:"snippet,omitempty"`
+}
+
+// AspectSummary aggregates one aspect over a symbol's recent articles
+type AspectSummary struct {
+	Aspect        string     `json:"aspect"`
+	Label         string     `json:"label"`
+	Articles      int        `json:"articles"`
+	PositiveCount int        `json:"positive_count"`
+	NegativeCount int        `json:"negative_count"`
+	NeutralCount  int        `json:"neutral_count"`
+	AverageScore  float64    `json:"average_score"`
+	Sentiment     string     `json:"sentiment"`
+	LatestSnippet string     `json:"latest_snippet,omitempty"`
+	LatestAt      *time.Time `json:"latest_at,omitempty"`
+}
+
+// ExtractAspects scores each aspect on the sentences that mention it, using the
+// keyword dictionary. Aspects the text does not mention are omitted.
+func (s *SentimentService) ExtractAspects(text string) []AspectSentiment {
+	sentences := splitSentences(text)
+	aspects := []AspectSentiment{}
+
+	for _, aspect := range sentimentAspects {
+		var total, strongest float64
+		mentions := 0
+		snippet := ""
+		for _, sentence := range sentences {
+			if !containsAny(sentence, aspect.terms) {
+				continue
+			}
+			mentions++
+			result := s.AnalyzeSentiment(sentence)
+			total += result.Score
+			if snippet == "" || abs(result.Score) > strongest {
+				strongest, snippet = abs(result.Score), sentence
+			}
+		}
+		if mentions == 0 {
+			continue
+		}
+
+		score := roundTo(total/float64(mentions), 4)
+		sentiment := "neutral"
+		if score > 0.2 {
+			sentiment = "positive"
+		} else if score < -0.2 {
+			sentiment = "negative"
+		}
+		if utf8.RuneCountInString(snippet) > 120 {
+			snippet = string([]rune(snippet)[:120]) + "…"
+		}
+
+		aspects = append(aspects, AspectSentiment{
+			Aspect:    aspect.name,
+			Label:     aspect.label,
+			Sentiment: sentiment,
+			Score:     score,
+			Mentions:  mentions,
+			Snippet:   snippet,
+		})
+	}
+	return aspects
+}
+
+// saveArticleAspects replaces the article's aspect rows
+func (s *SentimentService) saveArticleAspects(ctx context.Context, articleID string, aspects []AspectSentiment) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM article_aspect_sentiment WHERE article_id = $1`, articleID); err != nil {
+		return fmt.Errorf("failed to clear aspects: %w", err)
+	}
+	for _, a := range aspects {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO article_aspect_sentiment (article_id, aspect, sentiment, score, mentions, snippet)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, articleID, a.Aspect, a.Sentiment, a.Score, a.Mentions, a.Snippet); err != nil {
+			return fmt.Errorf("failed to save aspect: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetAspectBreakdown aggregates aspect sentiment over a symbol's articles of the last days
+func (s *SentimentService) GetAspectBreakdown(ctx context.Context, symbol string, days int) ([]AspectSummary, error) {
+	if days <= 0 {
+		days = 30
+	}
+
+	query := `
+		SELECT a.aspect,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE a.sentiment = 'positive'),
+		       COUNT(*) FILTER (WHERE a.sentiment = 'negative'),
+		       COUNT(*) FILTER (WHERE a.sentiment = 'neutral'),
+		       COALESCE(AVG(a.score), 0),
+		       (ARRAY_AGG(a.snippet ORDER BY n.published_at DESC))[1],
+		       MAX(n.published_at)
+		FROM article_aspect_sentiment a
+		JOIN stock_news n ON n.id = a.article_id
+		WHERE a.article_id IN (SELECT article_id FROM article_symbols WHERE symbol = $1)
+		  AND n.published_at >= NOW() - $2::interval
+		GROUP BY a.aspect
+	`
+	interval := (time.Duration(days) * 24 * time.Hour).String()
+	rows, err := s.db.QueryContext(ctx, query, symbol, interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query aspect sentiment: %w", err)
+	}
+	defer rows.Close()
+
+	found := make(map[string]AspectSummary)
+	for rows.Next() {
+		var a AspectSummary
+		var snippet *string
+		if err := rows.Scan(
+			&a.Aspect, &a.Articles, &a.PositiveCount, &a.NegativeCount, &a.NeutralCount,
+			&a.AverageScore, &snippet, &a.LatestAt,
+		); err != nil {
+			continue
+		}
+		if snippet != nil {
+			a.LatestSnippet = *snippet
+		}
+		a.AverageScore = roundTo(a.AverageScore, 4)
+		found[a.Aspect] = a
+	}
+
+	// Keep the fixed aspect order so clients can render a stable breakdown
+	summaries := []AspectSummary{}
+	for _, aspect := range sentimentAspects {
+		a, ok := found[aspect.name]
+		if !ok {
+			continue
+		}
+		a.Label = aspect.label
+		switch {
+		case a.AverageScore > 0.15:
+			a.Sentiment = "positive"
+		case a.AverageScore < -0.15:
+			a.Sentiment = "negative"
+		default:
+			a.Sentiment = "neutral"
+		}
+		summaries = append(summaries, a)
+	}
+	return summaries, nil
+}
+
+// splitSentences splits on Chinese and Western sentence punctuation, dropping
+// repeats (callers weight the title by including it twice)
+func splitSentences(text string) []string {
+	parts := strings.FieldsFunc(text, func(r rune) bool {
+		switch r {
+		case '。', '！', '？', '；', '!', '?', ';', '\n':
+			return true
+		}
+		return false
+	})
+	seen := make(map[string]bool, len(parts))
+	sentences := make([]string, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" || seen[p] {
+			continue
+		}
+		seen[p] = true
+		sentences = append(sentences, p)
+	}
+	return sentences
+}
+
+func containsAny(text string, terms []string) bool {
+	for _, t := range terms {
+		if strings.Contains(text, t) {
+			return true
+		}
+	}
+	return false
+}
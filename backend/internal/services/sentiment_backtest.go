@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// SentimentBacktest reports how well daily news sentiment predicted forward returns
+type SentimentBacktest struct {
+	Symbol       string                  `json:"symbol,omitempty"` // Empty = pooled across all symbols
+	Days         int                     `json:"days"`
+	Horizon      int                     `json:"horizon"` // Trading days
+	Observations int                     `json:"observations"`
+	Symbols      int                     `json:"symbols"`
+	Pearson      *float64                `json:"pearson,omitempty"`
+	IC           *float64                `json:"ic,omitempty"`     // Spearman rank correlation
+	TStat        *float64                `json:"t_stat,omitempty"` // Of the IC
+	HitRate      *float64                `json:"hit_rate,omitempty"`
+	Buckets      []SentimentReturnBucket `json:"buckets"`
+	Note         string                  `json:"note,omitempty"`
+}
+
+// SentimentReturnBucket is the average forward return after days of one sentiment
+type SentimentReturnBucket struct {
+	Sentiment    string  `json:"sentiment"` // positive (> 0.2), neutral, negative (< -0.2)
+	Observations int     `json:"observations"`
+	AvgReturn    float64 `json:"avg_return"` // Percent
+	WinRate      float64 `json:"win_rate"`   // Share of observations with a positive return
+}
+
+// BacktestSentiment joins each (symbol, day) average sentiment over the last days
+// with the return from that day's close (the next trading day's, for news on
+// non-trading days) to the close horizon trading days later. An empty symbol
+// pools every symbol with price history.
+func (s *SentimentService) BacktestSentiment(ctx context.Context, symbol string, days, horizon int) (*SentimentBacktest, error) {
+	if days <= 0 {
+		days = 365
+	}
+	if horizon <= 0 || horizon > 60 {
+		return nil, fmt.Errorf("horizon must be between 1 and 60 trading days")
+	}
+
+	query := `
+		WITH daily AS (
+			SELECT a.symbol, (n.published_at AT TIME ZONE 'Asia/Taipei')::date AS day,
+			       AVG(n.sentiment_score)::float8 AS score
+			FROM stock_news n
+			JOIN article_symbols a ON a.article_id = n.id
+			WHERE n.sentiment_score IS NOT NULL
+			  AND n.published_at >= NOW() - $2::interval
+			  AND ($1 = '' OR a.symbol = $1)
+			GROUP BY 1, 2
+		),
+		prices AS (
+			SELECT symbol, (timestamp AT TIME ZONE 'Asia/Taipei')::date AS day, close,
+			       LEAD(close, $3) OVER (PARTITION BY symbol ORDER BY timestamp) AS fwd_close
+			FROM stock_ohlcv
+			WHERE symbol IN (SELECT DISTINCT symbol FROM daily)
+			  AND timestamp >= NOW() - $2::interval - INTERVAL '7 days'
+		)
+		SELECT d.symbol, d.score, ((p.fwd_close / p.close - 1) * 100)::float8
+		FROM daily d
+		CROSS JOIN LATERAL (
+			SELECT close, fwd_close FROM prices p
+			WHERE p.symbol = d.symbol AND p.day >= d.day
+			ORDER BY p.day
+			LIMIT 1
+		) p
+		WHERE p.fwd_close IS NOT NULL AND p.close > 0
+	`
+	interval := (time.Duration(days) * 24 * time.Hour).String()
+	rows, err := s.db.QueryContext(ctx, query, symbol, interval, horizon)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sentiment history: %w", err)
+	}
+	defer rows.Close()
+
+	var scores, returns []float64
+	symbols := make(map[string]bool)
+	for rows.Next() {
+		var sym string
+		var score, ret float64
+		if err := rows.Scan(&sym, &score, &ret); err != nil {
+			continue
+		}
+		symbols[sym] = true
+		scores = append(scores, score)
+		returns = append(returns, ret)
+	}
+
+	result := &SentimentBacktest{
+		Symbol:       symbol,
+		Days:         days,
+		Horizon:      horizon,
+		Observations: len(scores),
+		Symbols:      len(symbols),
+		Buckets:      sentimentReturnBuckets(scores, returns),
+	}
+	if len(scores) < 10 {
+		result.Note = "fewer than 10 observations; correlation not reported"
+		return result, nil
+	}
+
+	if _, pearson, ok := betaAndCorrelation(returns, scores); ok {
+		v := roundTo(pearson, 4)
+		result.Pearson = &v
+	}
+	if _, ic, ok := betaAndCorrelation(rankValues(returns), rankValues(scores)); ok {
+		v := roundTo(ic, 4)
+		result.IC = &v
+		if ic*ic < 1 {
+			t := roundTo(ic*math.Sqrt(float64(len(scores)-2)/(1-ic*ic)), 2)
+			result.TStat = &t
+		}
+	}
+
+	// Direction agreement on days with a non-neutral sentiment
+	hits, calls := 0, 0
+	for i := range scores {
+		if abs(scores[i]) <= 0.2 || returns[i] == 0 {
+			continue
+		}
+		calls++
+		if (scores[i] > 0) == (returns[i] > 0) {
+			hits++
+		}
+	}
+	if calls > 0 {
+		v := roundTo(float64(hits)/float64(calls), 4)
+		result.HitRate = &v
+	}
+
+	return result, nil
+}
+
+func sentimentReturnBuckets(scores, returns []float64) []SentimentReturnBucket {
+	buckets := []SentimentReturnBucket{{Sentiment: "positive"}, {Sentiment: "neutral"}, {Sentiment: "negative"}}
+	wins := make([]int, len(buckets))
+	for i, score := range scores {
+		b := 1
+		if score > 0.2 {
+			b = 0
+		} else if score < -0.2 {
+			b = 2
+		}
+		buckets[b].Observations++
+		buckets[b].AvgReturn += returns[i]
+		if returns[i] > 0 {
+			wins[b]++
+		}
+	}
+	for i := range buckets {
+		if n := buckets[i].Observations; n > 0 {
+			buckets[i].AvgReturn = roundTo(buckets[i].AvgReturn/float64(n), 4)
+			buckets[i].WinRate = roundTo(float64(wins[i])/float64(n), 4)
+		}
+	}
+	return buckets
+}
+
+// rankValues returns 1-based ranks, averaging ties
+func rankValues(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.Slice(idx, func(a, b int) bool { return values[idx[a]] < values[idx[b]] })
+
+	ranks := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		avg := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			ranks[idx[k]] = avg
+		}
+		i = j + 1
+	}
+	return ranks
+}
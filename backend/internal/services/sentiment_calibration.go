@@ -0,0 +1,103 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// sourceBiasWindowDays is the history used to estimate each source's bias
+const sourceBiasWindowDays = 90
+
+// sourceBiasPrior shrinks the bias of sources with few articles towards zero:
+// a source with sourceBiasPrior articles keeps half of its raw bias
+const sourceBiasPrior = 20
+
+// SourceSentimentBias is how much more bullish or bearish one news source
+// scores than all sources together
+type SourceSentimentBias struct {
+	Source        string  `json:"source"`
+	Articles      int     `json:"articles"`
+	AverageScore  float64 `json:"average_score"`
+	RawBias       float64 `json:"raw_bias"` // AverageScore minus the all-source average
+	Bias          float64 `json:"bias"`     // RawBias shrunk by article count; subtracted when normalising
+	StdDev        float64 `json:"std_dev"`
+	PositiveShare float64 `json:"positive_share"`
+	NegativeShare float64 `json:"negative_share"`
+}
+
+// GetSourceBias compares the average article score of each source over the
+// last days with the average across every source. Sources with fewer than
+// minArticles analysed articles are left out.
+func (s *SentimentService) GetSourceBias(ctx context.Context, days, minArticles int) ([]SourceSentimentBias, error) {
+	if days <= 0 {
+		days = sourceBiasWindowDays
+	}
+	if minArticles <= 0 {
+		minArticles = 1
+	}
+
+	query := `
+		WITH scored AS (
+			SELECT source, sentiment, sentiment_score::float8 AS score
+			FROM stock_news
+			WHERE published_at >= NOW() - $1::interval
+			  AND sentiment IS NOT NULL AND sentiment_score IS NOT NULL
+		),
+		overall AS (
+			SELECT AVG(score) AS avg_score FROM scored
+		)
+		SELECT s.source, COUNT(*), AVG(s.score), AVG(s.score) - o.avg_score,
+		       COALESCE(STDDEV_SAMP(s.score), 0),
+		       COUNT(*) FILTER (WHERE s.sentiment = 'positive')::float8 / COUNT(*),
+		       COUNT(*) FILTER (WHERE s.sentiment = 'negative')::float8 / COUNT(*)
+		FROM scored s
+		CROSS JOIN overall o
+		GROUP BY s.source, o.avg_score
+		HAVING COUNT(*) >= $2
+		ORDER BY 4 DESC
+	`
+
+	interval := (time.Duration(days) * 24 * time.Hour).String()
+	rows, err := s.db.QueryContext(ctx, query, interval, minArticles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query source bias: %w", err)
+	}
+	defer rows.Close()
+
+	sources := []SourceSentimentBias{}
+	for rows.Next() {
+		var b SourceSentimentBias
+		if err := rows.Scan(
+			&b.Source, &b.Articles, &b.AverageScore, &b.RawBias,
+			&b.StdDev, &b.PositiveShare, &b.NegativeShare,
+		); err != nil {
+			continue
+		}
+		shrink := float64(b.Articles) / float64(b.Articles+sourceBiasPrior)
+		b.Bias = roundTo(b.RawBias*shrink, 4)
+		b.AverageScore = roundTo(b.AverageScore, 4)
+		b.RawBias = roundTo(b.RawBias, 4)
+		b.StdDev = roundTo(b.StdDev, 4)
+		b.PositiveShare = roundTo(b.PositiveShare, 4)
+		b.NegativeShare = roundTo(b.NegativeShare, 4)
+		sources = append(sources, b)
+	}
+	return sources, nil
+}
+
+// sourceBiasArrays returns the current source biases as parallel arrays for
+// joining against stock_news.source with unnest
+func (s *SentimentService) sourceBiasArrays(ctx context.Context) ([]string, []float64, error) {
+	biases, err := s.GetSourceBias(ctx, sourceBiasWindowDays, 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	sources := make([]string, 0, len(biases))
+	values := make([]float64, 0, len(biases))
+	for _, b := range biases {
+		sources = append(sources, b.Source)
+		values = append(values, b.Bias)
+	}
+	return sources, values, nil
+}
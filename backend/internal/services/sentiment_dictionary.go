@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Sentiment keyword kinds
+const (
+	SentimentKindPositive    = "positive"
+	SentimentKindNegative    = "negative"
+	SentimentKindIntensifier = "intensifier"
+)
+
+// sentimentDictionaryCheckInterval is how often the stored version is polled,
+// so edits made through another instance are picked up without a restart
+const sentimentDictionaryCheckInterval = 30 * time.Second
+
+// SentimentKeyword is one editable dictionary entry
+type SentimentKeyword struct {
+	ID        string    `json:"id"`
+	Term      string    `json:"term"`
+	Kind      string    `json:"kind"`   // positive, negative, intensifier
+	Weight    float64   `json:"weight"` // Term weight; multiplier for intensifiers
+	IsActive  bool      `json:"is_active"`
+	Note      string    `json:"note,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SentimentKeywordRequest creates or updates a dictionary entry
+type SentimentKeywordRequest struct {
+	Term     string   `json:"term"`
+	Kind     string   `json:"kind"`
+	Weight   *float64 `json:"weight"`
+	IsActive *bool    `json:"is_active"`
+	Note     *string  `json:"note"`
+}
+
+// SentimentDictionaryInfo describes the dictionary currently in use
+type SentimentDictionaryInfo struct {
+	Version      int       `json:"version"` // 0 = built-in lists (table unavailable)
+	Positive     int       `json:"positive"`
+	Negative     int       `json:"negative"`
+	Intensifiers int       `json:"intensifiers"`
+	LoadedAt     time.Time `json:"loaded_at"`
+}
+
+type weightedTerm struct {
+	term   string
+	weight float64
+}
+
+// sentimentDictionary is an immutable snapshot; reloads swap the pointer
+type sentimentDictionary struct {
+	version      int
+	positive     []weightedTerm
+	negative     []weightedTerm
+	intensifiers map[string]float64
+	loadedAt     time.Time
+}
+
+// builtinSentimentDictionary is used until the table is loaded, and to seed it
+func builtinSentimentDictionary() *sentimentDictionary {
+	d := &sentimentDictionary{intensifiers: make(map[string]float64), loadedAt: time.Now()}
+	for _, k := range positiveKeywords {
+		d.positive = append(d.positive, weightedTerm{k, 1})
+	}
+	for _, k := range negativeKeywords {
+		d.negative = append(d.negative, weightedTerm{k, 1})
+	}
+	for k, v := range intensifiers {
+		d.intensifiers[k] = v
+	}
+	return d
+}
+
+func (d *sentimentDictionary) info() SentimentDictionaryInfo {
+	return SentimentDictionaryInfo{
+		Version:      d.version,
+		Positive:     len(d.positive),
+		Negative:     len(d.negative),
+		Intensifiers: len(d.intensifiers),
+		LoadedAt:     d.loadedAt,
+	}
+}
+
+// dictionary returns the snapshot currently used by AnalyzeSentiment
+func (s *SentimentService) dictionary() *sentimentDictionary {
+	s.dictMu.RLock()
+	defer s.dictMu.RUnlock()
+	return s.dict
+}
+
+// refreshDictionary reloads the dictionary when the stored version changed.
+// Without force, the version is checked at most every 30 seconds.
+func (s *SentimentService) refreshDictionary(ctx context.Context, force bool) error {
+	s.dictMu.Lock()
+	if !force && time.Since(s.dictCheckedAt) < sentimentDictionaryCheckInterval {
+		s.dictMu.Unlock()
+		return nil
+	}
+	s.dictCheckedAt = time.Now()
+	current := s.dict.version
+	s.dictMu.Unlock()
+
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT version FROM sentiment_dictionary_version WHERE id = 1`).Scan(&version); err != nil {
+		return fmt.Errorf("failed to read dictionary version: %w", err)
+	}
+	if version == current && current > 0 && !force {
+		return nil
+	}
+
+	dict, err := s.loadDictionary(ctx)
+	if err != nil {
+		return err
+	}
+	if dict == nil {
+		// Empty table: seed it from the built-in lists, then load the result
+		if err := s.seedDictionary(ctx); err != nil {
+			return err
+		}
+		if dict, err = s.loadDictionary(ctx); err != nil || dict == nil {
+			return err
+		}
+	}
+
+	s.dictMu.Lock()
+	s.dict = dict
+	s.dictMu.Unlock()
+	if dict.version != current {
+		log.Printf("Sentiment: dictionary v%d loaded (%d positive, %d negative, %d intensifiers)",
+			dict.version, len(dict.positive), len(dict.negative), len(dict.intensifiers))
+	}
+	return nil
+}
+
+// loadDictionary reads the active terms; returns nil when the table is empty
+func (s *SentimentService) loadDictionary(ctx context.Context) (*sentimentDictionary, error) {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true, Isolation: sql.LevelRepeatableRead})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var total int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM sentiment_keywords`).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count dictionary terms: %w", err)
+	}
+	if total == 0 {
+		return nil, nil
+	}
+
+	dict := &sentimentDictionary{intensifiers: make(map[string]float64), loadedAt: time.Now()}
+	if err := tx.QueryRowContext(ctx, `SELECT version FROM sentiment_dictionary_version WHERE id = 1`).Scan(&dict.version); err != nil {
+		return nil, fmt.Errorf("failed to read dictionary version: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT term, kind, weight FROM sentiment_keywords WHERE is_active = true ORDER BY kind, term
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load dictionary: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var term, kind string
+		var weight float64
+		if err := rows.Scan(&term, &kind, &weight); err != nil {
+			continue
+		}
+		switch kind {
+		case SentimentKindPositive:
+			dict.positive = append(dict.positive, weightedTerm{term, weight})
+		case SentimentKindNegative:
+			dict.negative = append(dict.negative, weightedTerm{term, weight})
+		case SentimentKindIntensifier:
+			dict.intensifiers[term] = weight
+		}
+	}
+	return dict, nil
+}
+
+// seedDictionary copies the built-in lists into the table in one statement (one version bump)
+func (s *SentimentService) seedDictionary(ctx context.Context) error {
+	builtin := builtinSentimentDictionary()
+	var terms, kinds []string
+	var weights []float64
+	for _, t := range builtin.positive {
+		terms, kinds, weights = append(terms, t.term), append(kinds, SentimentKindPositive), append(weights, t.weight)
+	}
+	for _, t := range builtin.negative {
+		terms, kinds, weights = append(terms, t.term), append(kinds, SentimentKindNegative), append(weights, t.weight)
+	}
+	for term, weight := range builtin.intensifiers {
+		terms, kinds, weights = append(terms, term), append(kinds, SentimentKindIntensifier), append(weights, weight)
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sentiment_keywords (term, kind, weight, note)
+		SELECT t.term, t.kind, t.weight, 'built-in'
+		FROM unnest($1::text[], $2::text[], $3::numeric[]) AS t(term, kind, weight)
+		ON CONFLICT (term, kind) DO NOTHING
+	`, pq.Array(terms), pq.Array(kinds), pq.Array(weights))
+	if err != nil {
+		return fmt.Errorf("failed to seed dictionary: %w", err)
+	}
+	return nil
+}
+
+// GetDictionaryInfo returns the version and size of the dictionary in use
+func (s *SentimentService) GetDictionaryInfo(ctx context.Context) SentimentDictionaryInfo {
+	if err := s.refreshDictionary(ctx, false); err != nil {
+		log.Printf("Sentiment: %v", err)
+	}
+	return s.dictionary().info()
+}
+
+// ReloadDictionary forces a reload from the table
+func (s *SentimentService) ReloadDictionary(ctx context.Context) (SentimentDictionaryInfo, error) {
+	if err := s.refreshDictionary(ctx, true); err != nil {
+		return SentimentDictionaryInfo{}, err
+	}
+	return s.dictionary().info(), nil
+}
+
+// ListKeywords returns dictionary entries, optionally of one kind
+func (s *SentimentService) ListKeywords(ctx context.Context, kind string) ([]SentimentKeyword, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, term, kind, weight, is_active, COALESCE(note, ''), created_at, updated_at
+		FROM sentiment_keywords
+		WHERE ($1 = '' OR kind = $1)
+		ORDER BY kind, term
+	`, kind)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query keywords: %w", err)
+	}
+	defer rows.Close()
+
+	keywords := []SentimentKeyword{}
+	for rows.Next() {
+		var k SentimentKeyword
+		if err := rows.Scan(&k.ID, &k.Term, &k.Kind, &k.Weight, &k.IsActive, &k.Note, &k.CreatedAt, &k.UpdatedAt); err != nil {
+			continue
+		}
+		keywords = append(keywords, k)
+	}
+	return keywords, nil
+}
+
+// CreateKeyword adds a term and reloads the dictionary
+func (s *SentimentService) CreateKeyword(ctx context.Context, req SentimentKeywordRequest) (*SentimentKeyword, error) {
+	req.Term = strings.TrimSpace(req.Term)
+	if req.Term == "" {
+		return nil, fmt.Errorf("term is required")
+	}
+	if err := validateKeywordKind(req.Kind); err != nil {
+		return nil, err
+	}
+	weight := 1.0
+	if req.Weight != nil {
+		weight = *req.Weight
+	}
+	if weight <= 0 || weight > 10 {
+		return nil, fmt.Errorf("weight must be between 0 and 10")
+	}
+	active := true
+	if req.IsActive != nil {
+		active = *req.IsActive
+	}
+	note := ""
+	if req.Note != nil {
+		note = *req.Note
+	}
+
+	var k SentimentKeyword
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO sentiment_keywords (term, kind, weight, is_active, note)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (term, kind) DO NOTHING
+		RETURNING id, term, kind, weight, is_active, COALESCE(note, ''), created_at, updated_at
+	`, req.Term, req.Kind, weight, active, note).Scan(
+		&k.ID, &k.Term, &k.Kind, &k.Weight, &k.IsActive, &k.Note, &k.CreatedAt, &k.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%s keyword %q already exists", req.Kind, req.Term)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create keyword: %w", err)
+	}
+
+	s.reloadAfterEdit(ctx)
+	return &k, nil
+}
+
+// UpdateKeyword changes a term's weight, active flag or note and reloads the dictionary
+func (s *SentimentService) UpdateKeyword(ctx context.Context, id string, req SentimentKeywordRequest) (*SentimentKeyword, error) {
+	if req.Weight != nil && (*req.Weight <= 0 || *req.Weight > 10) {
+		return nil, fmt.Errorf("weight must be between 0 and 10")
+	}
+
+	var k SentimentKeyword
+	err := s.db.QueryRowContext(ctx, `
+		UPDATE sentiment_keywords SET
+			weight = COALESCE($2, weight),
+			is_active = COALESCE($3, is_active),
+			note = COALESCE($4, note)
+		WHERE id = $1
+		RETURNING id, term, kind, weight, is_active, COALESCE(note, ''), created_at, updated_at
+	`, id, req.Weight, req.IsActive, req.Note).Scan(
+		&k.ID, &k.Term, &k.Kind, &k.Weight, &k.IsActive, &k.Note, &k.CreatedAt, &k.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("keyword not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update keyword: %w", err)
+	}
+
+	s.reloadAfterEdit(ctx)
+	return &k, nil
+}
+
+// DeleteKeyword removes a term and reloads the dictionary
+func (s *SentimentService) DeleteKeyword(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM sentiment_keywords WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete keyword: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("keyword not found")
+	}
+
+	s.reloadAfterEdit(ctx)
+	return nil
+}
+
+func (s *SentimentService) reloadAfterEdit(ctx context.Context) {
+	if err := s.refreshDictionary(ctx, true); err != nil {
+		log.Printf("Sentiment: dictionary reload failed: %v", err)
+	}
+}
+
+func validateKeywordKind(kind string) error {
+	switch kind {
+	case SentimentKindPositive, SentimentKindNegative, SentimentKindIntensifier:
+		return nil
+	default:
+		return fmt.Errorf("kind must be positive, negative or intensifier")
+	}
+}
@@ -0,0 +1,255 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Sentiment methods recorded in stock_news.sentiment_method
+const (
+	SentimentMethodKeyword = "keyword"
+	SentimentMethodGemini  = "gemini"
+	SentimentMethodOpenAI  = "openai"
+)
+
+// llmSentimentMaxRunes bounds how much article text is sent to the provider
+const llmSentimentMaxRunes = 2000
+
+// llmSentimentPromptRevision must be bumped whenever llmSentimentPrompt changes,
+// so articles scored with the old prompt are picked up for re-analysis
+const llmSentimentPromptRevision = 1
+
+const llmSentimentPrompt = `你是台灣股市新聞的情緒分析器。判斷文章對相關個股或台股的市場情緒。
+只回傳 JSON，格式如下，不要有其他文字：
+{"sentiment": "positive|negative|neutral", "score": -1.0 到 1.0, "confidence": 0.0 到 1.0, "keywords": ["影響判斷的關鍵詞", ...]}`
+
+// sentimentLLM classifies text with Gemini or OpenAI when the keyword
+// dictionary is not confident enough
+type sentimentLLM struct {
+	provider   string // gemini, openai
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+// newSentimentLLM reads SENTIMENT_LLM_PROVIDER (gemini, openai or none; by
+// default the first provider with an API key: GEMINI_API_KEY, then
+// OPENAI_API_KEY) and SENTIMENT_LLM_MODEL. Returns nil when no provider is usable.
+func newSentimentLLM() *sentimentLLM {
+	provider := strings.ToLower(strings.TrimSpace(os.Getenv("SENTIMENT_LLM_PROVIDER")))
+	if provider == "" {
+		switch {
+		case os.Getenv("GEMINI_API_KEY") != "":
+			provider = SentimentMethodGemini
+		case os.Getenv("OPENAI_API_KEY") != "":
+			provider = SentimentMethodOpenAI
+		default:
+			return nil
+		}
+	}
+
+	llm := &sentimentLLM{
+		provider:   provider,
+		model:      os.Getenv("SENTIMENT_LLM_MODEL"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	switch provider {
+	case SentimentMethodGemini:
+		llm.apiKey = os.Getenv("GEMINI_API_KEY")
+		if llm.model == "" {
+			llm.model = os.Getenv("GEMINI_MODEL")
+		}
+		if llm.model == "" {
+			llm.model = "gemini-2.0-flash-exp"
+		}
+	case SentimentMethodOpenAI:
+		llm.apiKey = os.Getenv("OPENAI_API_KEY")
+		if llm.model == "" {
+			llm.model = "gpt-4o-mini"
+		}
+	default:
+		return nil
+	}
+	if llm.apiKey == "" {
+		return nil
+	}
+	return llm
+}
+
+// sentimentLLMThreshold reads SENTIMENT_LLM_THRESHOLD: keyword results below
+// this confidence are re-analysed by the LLM (default 0.5)
+func sentimentLLMThreshold() float64 {
+	if v, err := strconv.ParseFloat(os.Getenv("SENTIMENT_LLM_THRESHOLD"), 64); err == nil && v >= 0 && v <= 1 {
+		return v
+	}
+	return 0.5
+}
+
+// analyze asks the provider for a sentiment classification
+func (l *sentimentLLM) analyze(ctx context.Context, text string) (*SentimentResult, error) {
+	if utf8.RuneCountInString(text) > llmSentimentMaxRunes {
+		text = string([]rune(text)[:llmSentimentMaxRunes])
+	}
+
+	var content string
+	var err error
+	switch l.provider {
+	case SentimentMethodGemini:
+		content, err = l.callGemini(ctx, text)
+	case SentimentMethodOpenAI:
+		content, err = l.callOpenAI(ctx, text)
+	default:
+		err = fmt.Errorf("unsupported sentiment provider %q", l.provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed struct {
+		Sentiment  string   `json:"sentiment"`
+		Score      float64  `json:"score"`
+		Confidence float64  `json:"confidence"`
+		Keywords   []string `json:"keywords"`
+	}
+	// Models sometimes wrap JSON in a markdown fence
+	content = strings.TrimSpace(content)
+	if start, end := strings.Index(content, "{"), strings.LastIndex(content, "}"); start >= 0 && end > start {
+		content = content[start : end+1]
+	}
+	if err := json.Unmarshal([]byte(content), &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse %s sentiment response: %w", l.provider, err)
+	}
+
+	switch parsed.Sentiment {
+	case "positive", "negative", "neutral":
+	default:
+		return nil, fmt.Errorf("%s returned unknown sentiment %q", l.provider, parsed.Sentiment)
+	}
+	parsed.Score = clampFloat(parsed.Score, -1, 1)
+	parsed.Confidence = clampFloat(parsed.Confidence, 0, 1)
+	if parsed.Keywords == nil {
+		parsed.Keywords = []string{}
+	}
+	if len(parsed.Keywords) > 10 {
+		parsed.Keywords = parsed.Keywords[:10]
+	}
+
+	return &SentimentResult{
+		Sentiment:  parsed.Sentiment,
+		Score:      parsed.Score,
+		Confidence: parsed.Confidence,
+		Keywords:   parsed.Keywords,
+		Method:     l.provider,
+	}, nil
+}
+
+func (l *sentimentLLM) callGemini(ctx context.Context, text string) (string, error) {
+	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", l.model, l.apiKey)
+
+	reqBody := geminiRequest{
+		SystemInstruction: &geminiContent{
+			Parts: []geminiPart{{Text: llmSentimentPrompt}},
+		},
+		Contents: []geminiContent{
+			{Role: "user", Parts: []geminiPart{{Text: text}}},
+		},
+		GenerationConfig: &geminiGenerationConfig{
+			Temperature:      0.1,
+			MaxOutputTokens:  256,
+			ResponseMimeType: "application/json",
+		},
+	}
+
+	body, err := l.post(ctx, apiURL, reqBody, nil)
+	if err != nil {
+		return "", err
+	}
+
+	var resp geminiResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.Error != nil {
+		return "", fmt.Errorf("Gemini API error: %s (code: %d)", resp.Error.Message, resp.Error.Code)
+	}
+	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		return "", fmt.Errorf("no response from Gemini")
+	}
+	return resp.Candidates[0].Content.Parts[0].Text, nil
+}
+
+func (l *sentimentLLM) callOpenAI(ctx context.Context, text string) (string, error) {
+	reqBody := openAIChatRequest{
+		Model: l.model,
+		Messages: []openAIChatMessage{
+			{Role: "system", Content: llmSentimentPrompt},
+			{Role: "user", Content: text},
+		},
+		Temperature: 0.1,
+		MaxTokens:   256,
+		ResponseFormat: &struct {
+			Type string `json:"type"`
+		}{Type: "json_object"},
+	}
+
+	body, err := l.post(ctx, "https://api.openai.com/v1/chat/completions", reqBody,
+		map[string]string{"Authorization": "Bearer " + l.apiKey})
+	if err != nil {
+		return "", err
+	}
+
+	var resp openAIChatResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if resp.Error != nil {
+		return "", fmt.Errorf("OpenAI API error: %s (%s)", resp.Error.Message, resp.Error.Type)
+	}
+	if len(resp.Choices) == 0 {
+		return "", fmt.Errorf("no response from OpenAI")
+	}
+	return resp.Choices[0].Message.Content, nil
+}
+
+func (l *sentimentLLM) post(ctx context.Context, apiURL string, payload interface{}, headers map[string]string) ([]byte, error) {
+	jsonBody, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewReader(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call %s API: %w", l.provider, err)
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+func clampFloat(v, lo, hi float64) float64 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
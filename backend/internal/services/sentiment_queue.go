@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SentimentQueue analyses newly saved articles in the background, so sentiment
+// summaries and filters do not wait for POST /sentiment/analyze. Articles that
+// miss the queue (full, or saved while stopped) are picked up by a periodic sweep,
+// which also re-analyses a batch of recent articles scored by an outdated analyzer
+// version and refreshes today's and yesterday's market sentiment index.
+type SentimentQueue struct {
+	sentimentService *SentimentService
+	queue            chan string
+	workers          int
+	sweepInterval    time.Duration
+	sweepLimit       int
+	reanalyzeBatch   int
+
+	mu         sync.Mutex
+	isRunning  bool
+	stopChan   chan struct{}
+	wg         sync.WaitGroup
+	processed  int
+	failed     int
+	dropped    int
+	swept      int
+	reanalyzed int
+	lastError  string
+}
+
+// SentimentQueueStatus reports queue depth and counters since start
+type SentimentQueueStatus struct {
+	IsRunning     bool   `json:"is_running"`
+	Workers       int    `json:"workers"`
+	Pending       int    `json:"pending"`
+	Capacity      int    `json:"capacity"`
+	Processed     int    `json:"processed"`
+	Failed        int    `json:"failed"`
+	Dropped       int    `json:"dropped"`    // Queue was full; left for the sweep
+	Swept         int    `json:"swept"`      // Analysed by the periodic sweep
+	Reanalyzed    int    `json:"reanalyzed"` // Outdated analyzer version, re-scored by the sweep
+	SweepInterval string `json:"sweep_interval"`
+	LastError     string `json:"last_error,omitempty"`
+}
+
+// NewSentimentQueue reads SENTIMENT_QUEUE_SIZE (default 1000), SENTIMENT_WORKERS
+// (default 2), SENTIMENT_SWEEP_MINUTES (default 5) and SENTIMENT_REANALYZE_BATCH
+// (outdated articles re-scored per sweep, default 50; 0 disables)
+func NewSentimentQueue(sentimentService *SentimentService) *SentimentQueue {
+	size, workers, sweep, reanalyze := 1000, 2, 5, 50
+	if v, err := strconv.Atoi(os.Getenv("SENTIMENT_QUEUE_SIZE")); err == nil && v > 0 {
+		size = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SENTIMENT_WORKERS")); err == nil && v > 0 {
+		workers = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SENTIMENT_SWEEP_MINUTES")); err == nil && v > 0 {
+		sweep = v
+	}
+	if v, err := strconv.Atoi(os.Getenv("SENTIMENT_REANALYZE_BATCH")); err == nil && v >= 0 {
+		reanalyze = v
+	}
+
+	return &SentimentQueue{
+		sentimentService: sentimentService,
+		queue:            make(chan string, size),
+		workers:          workers,
+		sweepInterval:    time.Duration(sweep) * time.Minute,
+		sweepLimit:       200,
+		reanalyzeBatch:   reanalyze,
+		stopChan:         make(chan struct{}),
+	}
+}
+
+// Enqueue schedules articles for analysis without blocking. It is safe to call
+// on a nil or stopped queue.
+func (q *SentimentQueue) Enqueue(articleIDs ...string) {
+	if q == nil {
+		return
+	}
+	for _, id := range articleIDs {
+		select {
+		case q.queue <- id:
+		default:
+			q.mu.Lock()
+			q.dropped++
+			q.mu.Unlock()
+		}
+	}
+}
+
+// Start launches the workers and the sweep loop
+func (q *SentimentQueue) Start() {
+	q.mu.Lock()
+	if q.isRunning {
+		q.mu.Unlock()
+		return
+	}
+	q.isRunning = true
+	q.stopChan = make(chan struct{})
+	q.mu.Unlock()
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+	q.wg.Add(1)
+	go q.sweepLoop()
+
+	log.Printf("Sentiment queue started (%d workers, capacity %d, sweep every %s)", q.workers, cap(q.queue), q.sweepInterval)
+}
+
+// Stop stops the workers after their current article; queued IDs are left for the next sweep
+func (q *SentimentQueue) Stop() {
+	q.mu.Lock()
+	if !q.isRunning {
+		q.mu.Unlock()
+		return
+	}
+	q.isRunning = false
+	close(q.stopChan)
+	q.mu.Unlock()
+
+	q.wg.Wait()
+}
+
+// GetStatus returns queue depth and counters
+func (q *SentimentQueue) GetStatus() SentimentQueueStatus {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return SentimentQueueStatus{
+		IsRunning:     q.isRunning,
+		Workers:       q.workers,
+		Pending:       len(q.queue),
+		Capacity:      cap(q.queue),
+		Processed:     q.processed,
+		Failed:        q.failed,
+		Dropped:       q.dropped,
+		Swept:         q.swept,
+		Reanalyzed:    q.reanalyzed,
+		SweepInterval: q.sweepInterval.String(),
+		LastError:     q.lastError,
+	}
+}
+
+func (q *SentimentQueue) work() {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-q.stopChan:
+			return
+		case id := <-q.queue:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			_, err := q.sentimentService.AnalyzeNewsArticle(ctx, id)
+			cancel()
+
+			q.mu.Lock()
+			if err != nil {
+				q.failed++
+				q.lastError = err.Error()
+			} else {
+				q.processed++
+			}
+			q.mu.Unlock()
+		}
+	}
+}
+
+func (q *SentimentQueue) sweepLoop() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.sweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-q.stopChan:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+			// Leave fresh articles to the workers
+			n, reanalyzed := 0, 0
+			var err error
+			if len(q.queue) == 0 {
+				n, err = q.sentimentService.AnalyzeUnanalyzedNews(ctx, q.sweepLimit)
+			}
+			if len(q.queue) == 0 && q.reanalyzeBatch > 0 && err == nil {
+				var res *SentimentReanalysisResult
+				res, err = q.sentimentService.ReanalyzeOutdated(ctx, SentimentReanalysisOptions{Days: 30, Limit: q.reanalyzeBatch})
+				if res != nil {
+					reanalyzed = res.Reanalyzed
+				}
+			}
+			today := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600))
+			if _, indexErr := q.sentimentService.ComputeMarketSentimentIndex(ctx, today.AddDate(0, 0, -1), today); indexErr != nil && err == nil {
+				err = indexErr
+			}
+			cancel()
+
+			q.mu.Lock()
+			q.swept += n
+			q.reanalyzed += reanalyzed
+			if err != nil {
+				q.lastError = err.Error()
+			}
+			q.mu.Unlock()
+			if n > 0 {
+				log.Printf("Sentiment queue: sweep analysed %d articles", n)
+			}
+		}
+	}
+}
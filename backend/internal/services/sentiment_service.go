@@ -2,24 +2,48 @@ package services
 
 import (
 	"context"
-	"os"
+	"fmt"
+	"log"
 	"psm-backend/internal/database"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/lib/pq"
 )
 
-// SentimentService handles sentiment analysis for news articles
+// SentimentService handles sentiment analysis for news articles. The keyword
+// dictionary runs first; low-confidence results go to the LLM when configured.
 type SentimentService struct {
-	db         *database.DB
-	openaiKey  string
+	db           *database.DB
+	llm          *sentimentLLM // nil when no provider is configured
+	llmThreshold float64
+
+	dictMu        sync.RWMutex
+	dict          *sentimentDictionary // Keyword dictionary from sentiment_keywords
+	dictCheckedAt time.Time
 }
 
 func NewSentimentService(db *database.DB) *SentimentService {
-	return &SentimentService{
-		db:        db,
-		openaiKey: os.Getenv("OPENAI_API_KEY"),
+	s := &SentimentService{
+		db:           db,
+		llm:          newSentimentLLM(),
+		llmThreshold: sentimentLLMThreshold(),
+		dict:         builtinSentimentDictionary(),
+	}
+	if s.llm != nil {
+		log.Printf("Sentiment: %s (%s) used below keyword confidence %.2f", s.llm.provider, s.llm.model, s.llmThreshold)
 	}
+	return s
+}
+
+// LLMProvider returns the configured LLM provider, or "" for keyword-only analysis
+func (s *SentimentService) LLMProvider() string {
+	if s.llm == nil {
+		return ""
+	}
+	return s.llm.provider
 }
 
 // SentimentResult represents the result of sentiment analysis
@@ -28,10 +52,13 @@ type SentimentResult struct {
 	Score          float64  `json:"score"`           // -1.0 to 1.0
 	Confidence     float64  `json:"confidence"`      // 0.0 to 1.0
 	Keywords       []string `json:"keywords"`        // Key terms that influenced the decision
-	Method         string   `json:"method"`          // keyword, openai
+	Method         string   `json:"method"`          // keyword, gemini, openai
+	DictionaryVersion int   `json:"dictionary_version"` // Keyword dictionary version used
+	AnalyzerVersion string  `json:"analyzer_version,omitempty"`
 }
 
-// Keyword-based sentiment analysis dictionaries (Traditional Chinese financial terms)
+// Built-in keyword dictionaries (Traditional Chinese financial terms). They seed
+// the editable sentiment_keywords table and are used until it has been loaded.
 var (
 	positiveKeywords = []string{
 		// 上漲相關
@@ -85,8 +112,11 @@ var (
 	}
 )
 
-// AnalyzeSentiment performs keyword-based sentiment analysis on text
+// AnalyzeSentiment performs keyword-based sentiment analysis on text with the
+// currently loaded dictionary
 func (s *SentimentService) AnalyzeSentiment(text string) SentimentResult {
+	dict := s.dictionary()
+
 	// Combine title and content for analysis
 	text = strings.ToLower(text)
 	
@@ -96,34 +126,34 @@ func (s *SentimentService) AnalyzeSentiment(text string) SentimentResult {
 	negativeMatches := []string{}
 
 	// Check for positive keywords
-	for _, keyword := range positiveKeywords {
-		if strings.Contains(text, keyword) {
-			score := 1.0
+	for _, keyword := range dict.positive {
+		if strings.Contains(text, keyword.term) {
+			score := keyword.weight
 			// Check for intensifiers
-			for intensifier, multiplier := range intensifiers {
-				if strings.Contains(text, intensifier+keyword) {
+			for intensifier, multiplier := range dict.intensifiers {
+				if strings.Contains(text, intensifier+keyword.term) {
 					score *= multiplier
 					break
 				}
 			}
 			positiveScore += score
-			positiveMatches = append(positiveMatches, keyword)
+			positiveMatches = append(positiveMatches, keyword.term)
 		}
 	}
 
 	// Check for negative keywords
-	for _, keyword := range negativeKeywords {
-		if strings.Contains(text, keyword) {
-			score := 1.0
+	for _, keyword := range dict.negative {
+		if strings.Contains(text, keyword.term) {
+			score := keyword.weight
 			// Check for intensifiers
-			for intensifier, multiplier := range intensifiers {
-				if strings.Contains(text, intensifier+keyword) {
+			for intensifier, multiplier := range dict.intensifiers {
+				if strings.Contains(text, intensifier+keyword.term) {
 					score *= multiplier
 					break
 				}
 			}
 			negativeScore += score
-			negativeMatches = append(negativeMatches, keyword)
+			negativeMatches = append(negativeMatches, keyword.term)
 		}
 	}
 
@@ -166,10 +196,46 @@ func (s *SentimentService) AnalyzeSentiment(text string) SentimentResult {
 		Score:      score,
 		Confidence: confidence,
 		Keywords:   allKeywords,
-		Method:     "keyword",
+		Method:     SentimentMethodKeyword,
+		DictionaryVersion: dict.version,
 	}
 }
 
+// Analyze runs the keyword analysis and, when its confidence is below the
+// threshold, asks the LLM. LLM failures fall back to the keyword result.
+func (s *SentimentService) Analyze(ctx context.Context, text string) SentimentResult {
+	// Pick up dictionary edits; on error the loaded (or built-in) dictionary is kept
+	s.refreshDictionary(ctx, false)
+
+	result := s.AnalyzeSentiment(text)
+	result.AnalyzerVersion = s.analyzerVersion(result.DictionaryVersion)
+	if s.llm == nil || result.Confidence >= s.llmThreshold {
+		return result
+	}
+
+	llmResult, err := s.llm.analyze(ctx, text)
+	if err != nil {
+		log.Printf("Sentiment: %s analysis failed, using keyword result: %v", s.llm.provider, err)
+		return result
+	}
+	llmResult.DictionaryVersion = result.DictionaryVersion
+	llmResult.AnalyzerVersion = result.AnalyzerVersion
+	return *llmResult
+}
+
+// saveArticleSentiment stores the result with its method and confidence
+func (s *SentimentService) saveArticleSentiment(ctx context.Context, articleID string, result SentimentResult) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE stock_news
+		SET sentiment = $1, sentiment_score = $2, sentiment_analyzed_at = $3,
+		    sentiment_method = $4, sentiment_confidence = $5, sentiment_dictionary_version = NULLIF($6, 0),
+		    sentiment_analyzer_version = $7
+		WHERE id = $8
+	`, result.Sentiment, result.Score, time.Now(), result.Method, result.Confidence, result.DictionaryVersion,
+		result.AnalyzerVersion, articleID)
+	return err
+}
+
 // AnalyzeNewsArticle analyzes a single news article and updates the database
 func (s *SentimentService) AnalyzeNewsArticle(ctx context.Context, articleID string) (*SentimentResult, error) {
 	// Get article from database
@@ -184,18 +250,13 @@ func (s *SentimentService) AnalyzeNewsArticle(ctx context.Context, articleID str
 	text := title + " " + title + " " + summary + " " + content
 
 	// Perform analysis
-	result := s.AnalyzeSentiment(text)
+	result := s.Analyze(ctx, text)
 
 	// Update database
-	updateQuery := `
-		UPDATE stock_news 
-		SET sentiment = $1, sentiment_score = $2, sentiment_analyzed_at = $3
-		WHERE id = $4
-	`
-	_, err = s.db.ExecContext(ctx, updateQuery, result.Sentiment, result.Score, time.Now(), articleID)
-	if err != nil {
+	if err := s.saveArticleSentiment(ctx, articleID, result); err != nil {
 		return nil, err
 	}
+	s.saveArticleAspects(ctx, articleID, s.ExtractAspects(title+"\n"+summary+"\n"+content))
 
 	return &result, nil
 }
@@ -219,78 +280,101 @@ func (s *SentimentService) AnalyzeUnanalyzedNews(ctx context.Context, limit int)
 	if err != nil {
 		return 0, err
 	}
-	defer rows.Close()
 
-	analyzedCount := 0
+	// Read the batch first: LLM calls are slow and should not hold the connection
+	type pending struct{ id, text, aspectText string }
+	var batch []pending
 	for rows.Next() {
 		var id, title, summary, content string
 		if err := rows.Scan(&id, &title, &summary, &content); err != nil {
 			continue
 		}
-
 		// Combine text for analysis
-		text := title + " " + title + " " + summary + " " + content
-		result := s.AnalyzeSentiment(text)
-
-		// Update database
-		updateQuery := `
-			UPDATE stock_news 
-			SET sentiment = $1, sentiment_score = $2, sentiment_analyzed_at = $3
-			WHERE id = $4
-		`
-		_, err = s.db.ExecContext(ctx, updateQuery, result.Sentiment, result.Score, time.Now(), id)
-		if err != nil {
+		batch = append(batch, pending{
+			id:         id,
+			text:       title + " " + title + " " + summary + " " + content,
+			aspectText: title + "\n" + summary + "\n" + content,
+		})
+	}
+	rows.Close()
+
+	analyzedCount := 0
+	for _, p := range batch {
+		if ctx.Err() != nil {
+			break
+		}
+		result := s.Analyze(ctx, p.text)
+		if err := s.saveArticleSentiment(ctx, p.id, result); err != nil {
 			continue
 		}
+		s.saveArticleAspects(ctx, p.id, s.ExtractAspects(p.aspectText))
 		analyzedCount++
 	}
 
 	return analyzedCount, nil
 }
 
-// GetSentimentSummary returns sentiment summary for a symbol
-func (s *SentimentService) GetSentimentSummary(ctx context.Context, symbol string, days int) (*SentimentSummary, error) {
+// defaultSentimentConfidence is assumed for articles analysed before confidence was recorded
+const defaultSentimentConfidence = 0.5
+
+// GetSentimentSummary returns sentiment summary for a symbol. Scores are weighted
+// by analysis confidence, so weak keyword matches count less than confident
+// results; articles below minConfidence are left out entirely. With
+// normalizeSource, each score has its source's bias (see GetSourceBias)
+// subtracted first, so a prolific bullish outlet does not lift the average.
+// Positive/negative counts always reflect the stored labels.
+func (s *SentimentService) GetSentimentSummary(ctx context.Context, symbol string, days int, minConfidence float64, normalizeSource bool) (*SentimentSummary, error) {
 	if days <= 0 {
 		days = 7
 	}
 
+	biasSources, biasValues := []string{}, []float64{}
+	if normalizeSource {
+		var err error
+		if biasSources, biasValues, err = s.sourceBiasArrays(ctx); err != nil {
+			return nil, err
+		}
+	}
+
 	query := `
+		WITH scored AS (
+			SELECT n.sentiment,
+			       GREATEST(-1, LEAST(1, n.sentiment_score - COALESCE(b.bias, 0))) AS score,
+			       COALESCE(n.sentiment_confidence, $4) AS confidence
+			FROM stock_news n
+			LEFT JOIN unnest($5::text[], $6::float8[]) AS b(source, bias) ON b.source = n.source
+			WHERE n.id IN (SELECT article_id FROM article_symbols WHERE symbol = $1)
+			  AND n.published_at >= NOW() - $2::interval
+			  AND n.sentiment IS NOT NULL
+		)
 		SELECT 
 			COUNT(*) as total,
 			COUNT(CASE WHEN sentiment = 'positive' THEN 1 END) as positive_count,
 			COUNT(CASE WHEN sentiment = 'negative' THEN 1 END) as negative_count,
 			COUNT(CASE WHEN sentiment = 'neutral' THEN 1 END) as neutral_count,
-			COALESCE(AVG(sentiment_score), 0) as avg_score
-		FROM stock_news
-		WHERE symbol = $1 
-		  AND published_at >= NOW() - INTERVAL '%d days'
-		  AND sentiment IS NOT NULL
-	`
-	query = strings.Replace(query, "%d", string(rune('0'+days)), 1)
-	// Fix: use proper formatting
-	query = `
-		SELECT 
-			COUNT(*) as total,
-			COUNT(CASE WHEN sentiment = 'positive' THEN 1 END) as positive_count,
-			COUNT(CASE WHEN sentiment = 'negative' THEN 1 END) as negative_count,
-			COUNT(CASE WHEN sentiment = 'neutral' THEN 1 END) as neutral_count,
-			COALESCE(AVG(sentiment_score), 0) as avg_score
-		FROM stock_news
-		WHERE symbol = $1 
-		  AND published_at >= NOW() - $2::interval
-		  AND sentiment IS NOT NULL
+			COALESCE(AVG(score), 0) as avg_score,
+			COALESCE(SUM(score * confidence) / NULLIF(SUM(confidence), 0), 0) as weighted_score,
+			COALESCE(AVG(confidence), 0) as avg_confidence,
+			percentile_cont(ARRAY[0.1, 0.25, 0.5, 0.75, 0.9]) WITHIN GROUP (ORDER BY score::float8)
+		FROM scored
+		WHERE confidence >= $3
 	`
 
 	var summary SentimentSummary
 	interval := time.Duration(days) * 24 * time.Hour
 	intervalStr := interval.String()
+	var percentiles []float64
 	
-	err := s.db.QueryRowContext(ctx, query, symbol, intervalStr).Scan(
+	err := s.db.QueryRowContext(ctx, query, symbol, intervalStr, minConfidence, defaultSentimentConfidence,
+		pq.Array(biasSources), pq.Array(biasValues)).Scan(
 		&summary.TotalArticles,
 		&summary.PositiveCount,
 		&summary.NegativeCount,
 		&summary.NeutralCount,
 		&summary.AverageScore,
+		&summary.WeightedScore,
+		&summary.AvgConfidence,
+		pq.Array(&percentiles),
 	)
 	if err != nil {
 		return nil, err
@@ -298,13 +382,27 @@ func (s *SentimentService) GetSentimentSummary(ctx context.Context, symbol strin
 
 	summary.Symbol = symbol
 	summary.Days = days
+	summary.MinConfidence = minConfidence
+	summary.SourceNormalized = normalizeSource
+	summary.AverageScore = roundTo(summary.AverageScore, 4)
+	summary.WeightedScore = roundTo(summary.WeightedScore, 4)
+	summary.AvgConfidence = roundTo(summary.AvgConfidence, 4)
+	if len(percentiles) == 5 {
+		summary.Distribution = &ScoreDistribution{
+			P10: roundTo(percentiles[0], 4),
+			P25: roundTo(percentiles[1], 4),
+			P50: roundTo(percentiles[2], 4),
+			P75: roundTo(percentiles[3], 4),
+			P90: roundTo(percentiles[4], 4),
+		}
+	}
 
-	// Calculate overall sentiment
+	// Calculate overall sentiment from the confidence-weighted score
 	if summary.TotalArticles == 0 {
 		summary.OverallSentiment = "unknown"
-	} else if summary.AverageScore > 0.15 {
+	} else if summary.WeightedScore > 0.15 {
 		summary.OverallSentiment = "positive"
-	} else if summary.AverageScore < -0.15 {
+	} else if summary.WeightedScore < -0.15 {
 		summary.OverallSentiment = "negative"
 	} else {
 		summary.OverallSentiment = "neutral"
@@ -317,14 +415,118 @@ func (s *SentimentService) GetSentimentSummary(ctx context.Context, symbol strin
 type SentimentSummary struct {
 	Symbol           string  `json:"symbol"`
 	Days             int     `json:"days"`
+	MinConfidence    float64 `json:"min_confidence"`
+	SourceNormalized bool    `json:"source_normalized"` // Scores adjusted by per-source bias
 	TotalArticles    int     `json:"total_articles"`
 	PositiveCount    int     `json:"positive_count"`
 	NegativeCount    int     `json:"negative_count"`
 	NeutralCount     int     `json:"neutral_count"`
-	AverageScore     float64 `json:"average_score"`
+	AverageScore     float64 `json:"average_score"`  // Unweighted mean
+	WeightedScore    float64 `json:"weighted_score"` // Confidence-weighted mean
+	AvgConfidence    float64 `json:"avg_confidence"`
+	Distribution     *ScoreDistribution `json:"distribution,omitempty"`
 	OverallSentiment string  `json:"overall_sentiment"`
 }
 
+// ScoreDistribution holds percentiles of the article scores
+type ScoreDistribution struct {
+	P10 float64 `json:"p10"`
+	P25 float64 `json:"p25"`
+	P50 float64 `json:"p50"`
+	P75 float64 `json:"p75"`
+	P90 float64 `json:"p90"`
+}
+
+// IndustrySentiment aggregates article sentiment across the stocks of one industry
+type IndustrySentiment struct {
+	Industry         string   `json:"industry"`
+	Symbols          int      `json:"symbols"` // Stocks with at least one analysed article
+	TotalArticles    int      `json:"total_articles"`
+	PositiveCount    int      `json:"positive_count"`
+	NegativeCount    int      `json:"negative_count"`
+	NeutralCount     int      `json:"neutral_count"`
+	AverageScore     float64  `json:"average_score"`
+	PreviousScore    *float64 `json:"previous_score,omitempty"` // Same-length period immediately before
+	ScoreChange      *float64 `json:"score_change,omitempty"`
+	OverallSentiment string   `json:"overall_sentiment"`
+}
+
+// GetIndustrySentiment aggregates sentiment by taiwan_stocks.industry over the last
+// days, compared with the period before so sector-wide mood shifts stand out.
+// A multi-stock article counts once per industry it mentions.
+func (s *SentimentService) GetIndustrySentiment(ctx context.Context, days int, minArticles int) ([]IndustrySentiment, error) {
+	if days <= 0 {
+		days = 7
+	}
+	if minArticles <= 0 {
+		minArticles = 1
+	}
+
+	query := `
+		WITH tagged AS (
+			SELECT DISTINCT ts.industry, n.id, a.symbol, n.sentiment, n.sentiment_score,
+			       n.published_at >= NOW() - $1::interval AS is_current
+			FROM stock_news n
+			JOIN article_symbols a ON a.article_id = n.id
+			JOIN taiwan_stocks ts ON ts.symbol = a.symbol
+			WHERE n.published_at >= NOW() - 2 * $1::interval
+			  AND n.sentiment IS NOT NULL
+			  AND COALESCE(ts.industry, '') <> ''
+		),
+		per_article AS (
+			SELECT industry, id, sentiment, sentiment_score, is_current, COUNT(DISTINCT symbol) AS symbols
+			FROM tagged
+			GROUP BY industry, id, sentiment, sentiment_score, is_current
+		)
+		SELECT p.industry,
+		       (SELECT COUNT(DISTINCT t.symbol) FROM tagged t WHERE t.industry = p.industry AND t.is_current),
+		       COUNT(*) FILTER (WHERE p.is_current),
+		       COUNT(*) FILTER (WHERE p.is_current AND p.sentiment = 'positive'),
+		       COUNT(*) FILTER (WHERE p.is_current AND p.sentiment = 'negative'),
+		       COUNT(*) FILTER (WHERE p.is_current AND p.sentiment = 'neutral'),
+		       COALESCE(AVG(p.sentiment_score) FILTER (WHERE p.is_current), 0),
+		       AVG(p.sentiment_score) FILTER (WHERE NOT p.is_current)
+		FROM per_article p
+		GROUP BY p.industry
+		HAVING COUNT(*) FILTER (WHERE p.is_current) >= $2
+		ORDER BY 7 ASC, 3 DESC
+	`
+
+	interval := (time.Duration(days) * 24 * time.Hour).String()
+	rows, err := s.db.QueryContext(ctx, query, interval, minArticles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query industry sentiment: %w", err)
+	}
+	defer rows.Close()
+
+	industries := []IndustrySentiment{}
+	for rows.Next() {
+		var ind IndustrySentiment
+		if err := rows.Scan(
+			&ind.Industry, &ind.Symbols, &ind.TotalArticles, &ind.PositiveCount,
+			&ind.NegativeCount, &ind.NeutralCount, &ind.AverageScore, &ind.PreviousScore,
+		); err != nil {
+			continue
+		}
+		ind.AverageScore = roundTo(ind.AverageScore, 4)
+		if ind.PreviousScore != nil {
+			prev := roundTo(*ind.PreviousScore, 4)
+			change := roundTo(ind.AverageScore-prev, 4)
+			ind.PreviousScore, ind.ScoreChange = &prev, &change
+		}
+		switch {
+		case ind.AverageScore > 0.15:
+			ind.OverallSentiment = "positive"
+		case ind.AverageScore < -0.15:
+			ind.OverallSentiment = "negative"
+		default:
+			ind.OverallSentiment = "neutral"
+		}
+		industries = append(industries, ind)
+	}
+	return industries, nil
+}
+
 // Helper functions
 func min(a, b float64) float64 {
 	if a < b {
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// sentimentKeywordRevision must be bumped whenever AnalyzeSentiment's scoring
+// logic changes (dictionary edits are tracked separately by version number)
+const sentimentKeywordRevision = 1
+
+// SentimentReanalysisOptions selects outdated articles to re-analyse
+type SentimentReanalysisOptions struct {
+	Days   int  `json:"days"`  // Only articles published within the last days (0 = all)
+	Limit  int  `json:"limit"` // Articles per run
+	DryRun bool `json:"dry_run,omitempty"`
+}
+
+// SentimentReanalysisResult reports one re-analysis run
+type SentimentReanalysisResult struct {
+	CurrentVersion string `json:"current_version"`
+	Outdated       int    `json:"outdated"` // Matching articles before this run
+	Reanalyzed     int    `json:"reanalyzed"`
+	Failed         int    `json:"failed"`
+	Remaining      int    `json:"remaining"`
+	Duration       string `json:"duration"`
+}
+
+// AnalyzerVersionCount is the number of articles scored by one analyzer version
+type AnalyzerVersionCount struct {
+	Version  string     `json:"version"` // "" = analysed before versions were recorded
+	Current  bool       `json:"current"`
+	Articles int        `json:"articles"`
+	Latest   *time.Time `json:"latest_analyzed_at,omitempty"`
+}
+
+// analyzerVersion describes the pipeline configuration: keyword revision and
+// dictionary version, plus the LLM provider, model, prompt and threshold when
+// one is configured, since the threshold decides which results come from it
+func (s *SentimentService) analyzerVersion(dictVersion int) string {
+	version := fmt.Sprintf("kw%d.dict%d", sentimentKeywordRevision, dictVersion)
+	if s.llm != nil {
+		version += fmt.Sprintf("+%s:%s.p%d@%.2f", s.llm.provider, s.llm.model, llmSentimentPromptRevision, s.llmThreshold)
+	}
+	return version
+}
+
+// CurrentAnalyzerVersion returns the version new analyses are recorded with
+func (s *SentimentService) CurrentAnalyzerVersion(ctx context.Context) string {
+	s.refreshDictionary(ctx, false)
+	return s.analyzerVersion(s.dictionary().version)
+}
+
+// GetAnalyzerVersions counts analysed articles per analyzer version
+func (s *SentimentService) GetAnalyzerVersions(ctx context.Context) ([]AnalyzerVersionCount, error) {
+	current := s.CurrentAnalyzerVersion(ctx)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT COALESCE(sentiment_analyzer_version, ''), COUNT(*), MAX(sentiment_analyzed_at)
+		FROM stock_news
+		WHERE sentiment IS NOT NULL
+		GROUP BY 1
+		ORDER BY 2 DESC
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query analyzer versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := []AnalyzerVersionCount{}
+	for rows.Next() {
+		var v AnalyzerVersionCount
+		if err := rows.Scan(&v.Version, &v.Articles, &v.Latest); err != nil {
+			continue
+		}
+		v.Current = v.Version == current
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// ReanalyzeOutdated re-scores articles whose analyzer version differs from the
+// current one, newest first
+func (s *SentimentService) ReanalyzeOutdated(ctx context.Context, opts SentimentReanalysisOptions) (*SentimentReanalysisResult, error) {
+	if opts.Limit <= 0 {
+		opts.Limit = 200
+	}
+	if opts.Limit > 5000 {
+		opts.Limit = 5000
+	}
+
+	started := time.Now()
+	result := &SentimentReanalysisResult{CurrentVersion: s.CurrentAnalyzerVersion(ctx)}
+
+	var since *time.Time
+	if opts.Days > 0 {
+		t := time.Now().AddDate(0, 0, -opts.Days)
+		since = &t
+	}
+
+	where := `
+		WHERE sentiment IS NOT NULL
+		  AND sentiment_analyzer_version IS DISTINCT FROM $1
+		  AND ($2::timestamptz IS NULL OR published_at >= $2)
+	`
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM stock_news`+where, result.CurrentVersion, since).Scan(&result.Outdated); err != nil {
+		return nil, fmt.Errorf("failed to count outdated articles: %w", err)
+	}
+	if opts.DryRun || result.Outdated == 0 {
+		result.Remaining = result.Outdated
+		result.Duration = time.Since(started).String()
+		return result, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT id FROM stock_news`+where+` ORDER BY published_at DESC LIMIT $3`,
+		result.CurrentVersion, since, opts.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query outdated articles: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := s.AnalyzeNewsArticle(ctx, id); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Reanalyzed++
+	}
+
+	result.Remaining = result.Outdated - result.Reanalyzed
+	result.Duration = time.Since(started).String()
+	return result, nil
+}
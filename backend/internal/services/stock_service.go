@@ -6,8 +6,12 @@ import (
 	"fmt"
 	"psm-backend/internal/database"
 	"psm-backend/internal/models"
+	"strings"
 )
 
+// likeEscaper escapes LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 type StockService struct {
 	db *database.DB
 }
@@ -16,25 +20,50 @@ func NewStockService(db *database.DB) *StockService {
 	return &StockService{db: db}
 }
 
-// SearchStocks searches for Taiwan stocks by symbol or name
-// Supports partial matching for autocomplete functionality
-// Prioritizes symbol search for better reliability
-func (s *StockService) SearchStocks(ctx context.Context, query string, limit int) ([]models.TaiwanStock, error) {
+// SearchStocks searches for Taiwan stocks by symbol, Chinese or English name,
+// or a common alias, for autocomplete, optionally only one security type
+// (stock, etf, ...). Exact and prefix symbol matches rank first, then name
+// prefix and substring matches, then pg_trgm similarity.
+func (s *StockService) SearchStocks(ctx context.Context, query, securityType string, limit int) ([]models.TaiwanStock, error) {
 	if limit <= 0 || limit > 50 {
 		limit = 20 // Default limit
 	}
+	query = strings.TrimSpace(query)
+	pattern := likeEscaper.Replace(query)
 
-	// Primary search: symbol prefix match (most common use case)
 	sqlQuery := `
-		SELECT symbol, name, name_en, market, industry, is_active, created_at, updated_at
-		FROM taiwan_stocks
-		WHERE is_active = true 
-		  AND symbol LIKE $1 || '%'
-		ORDER BY symbol
-		LIMIT $2
+		WITH alias_matches AS (
+			SELECT symbol, MAX(CASE WHEN alias ILIKE $2 THEN 1 ELSE similarity(alias, $1) END) as sim
+			FROM taiwan_stock_aliases
+			WHERE alias ILIKE '%' || $2 || '%' OR alias % $1
+			GROUP BY symbol
+		)
+		SELECT s.symbol, s.name, s.name_en, s.market, s.industry, s.security_type, s.is_active, s.created_at, s.updated_at
+		FROM taiwan_stocks s
+		LEFT JOIN alias_matches a ON a.symbol = s.symbol
+		WHERE s.is_active = true
+		  AND ($4 = '' OR s.security_type = $4)
+		  AND (s.symbol LIKE $2 || '%'
+		       OR s.name ILIKE '%' || $2 || '%'
+		       OR s.name_en ILIKE '%' || $2 || '%'
+		       OR s.name % $1
+		       OR s.name_en % $1
+		       OR a.symbol IS NOT NULL)
+		ORDER BY
+			CASE
+				WHEN s.symbol = $1 THEN 0
+				WHEN s.symbol LIKE $2 || '%' THEN 1
+				WHEN s.name = $1 OR a.sim = 1 THEN 2
+				WHEN s.name ILIKE $2 || '%' OR s.name_en ILIKE $2 || '%' THEN 3
+				WHEN s.name ILIKE '%' || $2 || '%' OR s.name_en ILIKE '%' || $2 || '%' THEN 4
+				ELSE 5
+			END,
+			GREATEST(similarity(s.name, $1), similarity(COALESCE(s.name_en, ''), $1), COALESCE(a.sim, 0)) DESC,
+			s.symbol
+		LIMIT $3
 	`
 
-	rows, err := s.db.QueryContext(ctx, sqlQuery, query, limit)
+	rows, err := s.db.QueryContext(ctx, sqlQuery, query, pattern, limit, securityType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search stocks: %w", err)
 	}
@@ -49,6 +78,7 @@ func (s *StockService) SearchStocks(ctx context.Context, query string, limit int
 			&stock.NameEn,
 			&stock.Market,
 			&stock.Industry,
+			&stock.SecurityType,
 			&stock.IsActive,
 			&stock.CreatedAt,
 			&stock.UpdatedAt,
@@ -66,10 +96,12 @@ func (s *StockService) SearchStocks(ctx context.Context, query string, limit int
 	return stocks, nil
 }
 
-// GetStockBySymbol retrieves a single stock by exact symbol match
+// GetStockBySymbol retrieves a single stock by exact symbol match, with its
+// company profile
 func (s *StockService) GetStockBySymbol(ctx context.Context, symbol string) (*models.TaiwanStock, error) {
 	sqlQuery := `
-		SELECT symbol, name, name_en, market, industry, is_active, created_at, updated_at
+		SELECT symbol, name, name_en, market, industry, security_type, is_active, delisted_at, created_at, updated_at,
+		       paid_in_capital, listing_date, chairman, website, address
 		FROM taiwan_stocks
 		WHERE symbol = $1
 	`
@@ -81,9 +113,16 @@ func (s *StockService) GetStockBySymbol(ctx context.Context, symbol string) (*mo
 		&stock.NameEn,
 		&stock.Market,
 		&stock.Industry,
+		&stock.SecurityType,
 		&stock.IsActive,
+		&stock.DelistedAt,
 		&stock.CreatedAt,
 		&stock.UpdatedAt,
+		&stock.PaidInCapital,
+		&stock.ListingDate,
+		&stock.Chairman,
+		&stock.Website,
+		&stock.Address,
 	)
 
 	if err == sql.ErrNoRows {
@@ -95,3 +134,82 @@ func (s *StockService) GetStockBySymbol(ctx context.Context, symbol string) (*mo
 
 	return &stock, nil
 }
+
+// IndustrySummary is one industry of active securities with its counts
+type IndustrySummary struct {
+	Industry string `json:"industry"`
+	Count    int    `json:"count"`
+	TSECount int    `json:"tse_count"` // 上市
+	OTCCount int    `json:"otc_count"` // 上櫃
+}
+
+// ListIndustries returns the distinct industries of active securities with
+// how many belong to each, optionally counting only one market (TSE or OTC)
+func (s *StockService) ListIndustries(ctx context.Context, market string) ([]IndustrySummary, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT industry, COUNT(*),
+		       COUNT(*) FILTER (WHERE market = 'TSE'), COUNT(*) FILTER (WHERE market = 'OTC')
+		FROM taiwan_stocks
+		WHERE is_active AND industry IS NOT NULL AND industry <> ''
+		  AND ($1 = '' OR market = $1)
+		GROUP BY industry
+		ORDER BY COUNT(*) DESC, industry
+	`, market)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list industries: %w", err)
+	}
+	defer rows.Close()
+
+	industries := []IndustrySummary{}
+	for rows.Next() {
+		var ind IndustrySummary
+		if err := rows.Scan(&ind.Industry, &ind.Count, &ind.TSECount, &ind.OTCCount); err != nil {
+			return nil, fmt.Errorf("failed to scan industry: %w", err)
+		}
+		industries = append(industries, ind)
+	}
+	return industries, rows.Err()
+}
+
+// ListStocksByIndustry returns the active securities of an industry ordered
+// by symbol, optionally only one market
+func (s *StockService) ListStocksByIndustry(ctx context.Context, industry, market string) ([]models.TaiwanStock, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, name, name_en, market, industry, security_type, is_active, created_at, updated_at
+		FROM taiwan_stocks
+		WHERE is_active AND industry = $1
+		  AND ($2 = '' OR market = $2)
+		ORDER BY symbol
+	`, industry, market)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list industry stocks: %w", err)
+	}
+	defer rows.Close()
+
+	stocks := make([]models.TaiwanStock, 0)
+	for rows.Next() {
+		var stock models.TaiwanStock
+		if err := rows.Scan(
+			&stock.Symbol,
+			&stock.Name,
+			&stock.NameEn,
+			&stock.Market,
+			&stock.Industry,
+			&stock.SecurityType,
+			&stock.IsActive,
+			&stock.CreatedAt,
+			&stock.UpdatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+		stocks = append(stocks, stock)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating stocks: %w", err)
+	}
+
+	if len(stocks) == 0 && market == "" {
+		return nil, fmt.Errorf("industry not found: %s", industry)
+	}
+	return stocks, nil
+}
@@ -0,0 +1,250 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// StockSyncRun is one stock list sync as recorded in stock_sync_log
+type StockSyncRun struct {
+	ID                 uuid.UUID `json:"id"`
+	TriggeredBy        string    `json:"triggered_by"` // manual, scheduled or cli
+	Status             string    `json:"status"`       // success or failed
+	TSECount           int       `json:"tse_count"`
+	OTCCount           int       `json:"otc_count"`
+	ETFCount           int       `json:"etf_count"`
+	TotalCount         int       `json:"total_count"`
+	NewSymbols         []string  `json:"new_symbols"`
+	UpdatedSymbols     []string  `json:"updated_symbols"` // Name, market, industry, type or active flag changed
+	DeactivatedSymbols []string  `json:"deactivated_symbols"`
+	Errors             []string  `json:"errors"`
+	StartedAt          time.Time `json:"started_at"`
+	FinishedAt         time.Time `json:"finished_at"`
+}
+
+// loadSyncSnapshot returns the synced fields of every taiwan_stocks row,
+// joined into one comparable string per symbol
+func (s *StockSyncService) loadSyncSnapshot(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, concat_ws('|', name, market, COALESCE(industry, ''), security_type, COALESCE(is_active, true)::text)
+		FROM taiwan_stocks
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stock list: %w", err)
+	}
+	defer rows.Close()
+
+	snapshot := make(map[string]string)
+	for rows.Next() {
+		var symbol, fields string
+		if err := rows.Scan(&symbol, &fields); err != nil {
+			return nil, fmt.Errorf("failed to scan stock: %w", err)
+		}
+		snapshot[symbol] = fields
+	}
+	return snapshot, rows.Err()
+}
+
+// diffSyncSnapshots returns the sorted symbols added between two snapshots
+// and those whose fields changed, leaving out the deactivated ones
+func diffSyncSnapshots(before, after map[string]string, deactivated []string) (added, updated []string) {
+	skip := make(map[string]bool, len(deactivated))
+	for _, symbol := range deactivated {
+		skip[symbol] = true
+	}
+
+	added, updated = []string{}, []string{}
+	for symbol, fields := range after {
+		prev, ok := before[symbol]
+		switch {
+		case !ok:
+			added = append(added, symbol)
+		case prev != fields && !skip[symbol]:
+			updated = append(updated, symbol)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(updated)
+	return added, updated
+}
+
+// recordRun inserts a finished sync into stock_sync_log
+func (s *StockSyncService) recordRun(ctx context.Context, run *StockSyncRun) error {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO stock_sync_log (triggered_by, status, tse_count, otc_count, etf_count, total_count,
+			new_symbols, updated_symbols, deactivated_symbols, errors, started_at, finished_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id
+	`, run.TriggeredBy, run.Status, run.TSECount, run.OTCCount, run.ETFCount, run.TotalCount,
+		pq.Array(nonNilStrings(run.NewSymbols)), pq.Array(nonNilStrings(run.UpdatedSymbols)),
+		pq.Array(nonNilStrings(run.DeactivatedSymbols)), pq.Array(nonNilStrings(run.Errors)),
+		run.StartedAt, run.FinishedAt,
+	).Scan(&run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to record stock sync: %w", err)
+	}
+	return nil
+}
+
+// nonNilStrings turns a nil slice into an empty one for NOT NULL array columns
+func nonNilStrings(v []string) []string {
+	if v == nil {
+		return []string{}
+	}
+	return v
+}
+
+// SyncHistory returns the most recent stock syncs, newest first
+func (s *StockSyncService) SyncHistory(ctx context.Context, limit int) ([]StockSyncRun, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, triggered_by, status, tse_count, otc_count, etf_count, total_count,
+		       new_symbols, updated_symbols, deactivated_symbols, errors, started_at, finished_at
+		FROM stock_sync_log
+		ORDER BY started_at DESC
+		LIMIT $1
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query stock sync history: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []StockSyncRun{}
+	for rows.Next() {
+		var run StockSyncRun
+		if err := rows.Scan(&run.ID, &run.TriggeredBy, &run.Status, &run.TSECount, &run.OTCCount, &run.ETFCount,
+			&run.TotalCount, pq.Array(&run.NewSymbols), pq.Array(&run.UpdatedSymbols),
+			pq.Array(&run.DeactivatedSymbols), pq.Array(&run.Errors), &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan stock sync: %w", err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// StockSyncWorker runs SyncAll once a week so new listings, renames and
+// delistings are picked up without a manual sync
+type StockSyncWorker struct {
+	syncService  *StockSyncService
+	weekday      time.Weekday // Taipei weekday of the run
+	runAfterHour int          // Earliest Taipei hour of the run
+	mu           sync.Mutex
+	isRunning    bool
+	isBusy       bool
+	stopChan     chan struct{}
+}
+
+// NewStockSyncWorker reads STOCK_SYNC_WEEKDAY (0 = Sunday, default 6 =
+// Saturday) and STOCK_SYNC_HOUR (earliest Taipei hour, default 6)
+func NewStockSyncWorker(syncService *StockSyncService) *StockSyncWorker {
+	weekday := time.Saturday
+	if v, err := strconv.Atoi(os.Getenv("STOCK_SYNC_WEEKDAY")); err == nil && v >= 0 && v < 7 {
+		weekday = time.Weekday(v)
+	}
+	hour := 6
+	if v, err := strconv.Atoi(os.Getenv("STOCK_SYNC_HOUR")); err == nil && v >= 0 && v < 24 {
+		hour = v
+	}
+	return &StockSyncWorker{
+		syncService:  syncService,
+		weekday:      weekday,
+		runAfterHour: hour,
+		stopChan:     make(chan struct{}),
+	}
+}
+
+// Start launches the schedule loop
+func (w *StockSyncWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Stock sync worker started (%s after %02d:00)", w.weekday, w.runAfterHour)
+}
+
+// Stop stops the schedule loop
+func (w *StockSyncWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *StockSyncWorker) loop() {
+	ticker := time.NewTicker(30 * time.Minute)
+	defer ticker.Stop()
+
+	w.checkAndRun()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.checkAndRun()
+		}
+	}
+}
+
+// checkAndRun syncs on the scheduled day unless a scheduled sync already
+// succeeded that day; a failed one is retried on the next tick
+func (w *StockSyncWorker) checkAndRun() {
+	w.mu.Lock()
+	if w.isBusy {
+		w.mu.Unlock()
+		return
+	}
+	w.isBusy = true
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.isBusy = false
+		w.mu.Unlock()
+	}()
+
+	now := time.Now().In(time.FixedZone("Asia/Taipei", 8*3600))
+	if now.Weekday() != w.weekday || now.Hour() < w.runAfterHour {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	defer cancel()
+
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	var done bool
+	if err := w.syncService.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM stock_sync_log
+			WHERE triggered_by = 'scheduled' AND status = 'success' AND started_at >= $1
+		)
+	`, dayStart).Scan(&done); err != nil || done {
+		return
+	}
+
+	result, err := w.syncService.SyncAll(ctx, "scheduled")
+	if err != nil {
+		log.Printf("Stock sync worker: %v", err)
+		return
+	}
+	log.Printf("Stock sync worker: synced %d symbols (%d new, %d updated, %d delisted)",
+		result["total"], result["new"], result["updated"], result["deactivated"])
+}
@@ -7,15 +7,24 @@ import (
 	"io"
 	"net/http"
 	"psm-backend/internal/database"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // TWSE API response structure
 type TWSeStock struct {
-	CompanyCode string `json:"公司代號"`
-	CompanyName string `json:"公司名稱"`
-	ShortName   string `json:"公司簡稱"`
-	Industry    string `json:"產業別"`
+	CompanyCode   string `json:"公司代號"`
+	CompanyName   string `json:"公司名稱"`
+	ShortName     string `json:"公司簡稱"`
+	Industry      string `json:"產業別"`
+	Address       string `json:"住址"`
+	Chairman      string `json:"董事長"`
+	ListingDate   string `json:"上市日期"` // YYYYMMDD
+	PaidInCapital string `json:"實收資本額"`
+	Website       string `json:"網址"`
 }
 
 // TPEx API response structure (for OTC stocks)
@@ -24,6 +33,80 @@ type TPExStock struct {
 	Name string `json:"CompanyName"`
 }
 
+// TPEx company basic info response structure (上櫃公司基本資料)
+type TPExCompany struct {
+	Code          string `json:"SecuritiesCompanyCode"`
+	Name          string `json:"CompanyName"`
+	ShortName     string `json:"CompanyAbbreviation"`
+	Industry      string `json:"SecuritiesIndustryCode"` // Same 產業別 codes as TWSE
+	Address       string `json:"Address"`
+	Chairman      string `json:"Chairman"`
+	ListingDate   string `json:"DateOfListing"` // YYYYMMDD
+	PaidInCapital string `json:"Paidin.Capital.NTDollars"`
+	Website       string `json:"WebAddress"`
+}
+
+// toTWSeStock maps a TPEx company onto the TWSE company fields
+func (c TPExCompany) toTWSeStock() TWSeStock {
+	return TWSeStock{
+		CompanyCode:   strings.TrimSpace(c.Code),
+		CompanyName:   c.Name,
+		ShortName:     strings.TrimSpace(c.ShortName),
+		Industry:      strings.TrimSpace(c.Industry),
+		Address:       c.Address,
+		Chairman:      c.Chairman,
+		ListingDate:   c.ListingDate,
+		PaidInCapital: c.PaidInCapital,
+		Website:       c.Website,
+	}
+}
+
+// TWSE daily trading response structure, covering every listed security
+type TWSeSecurity struct {
+	Code string `json:"Code"`
+	Name string `json:"Name"`
+}
+
+// industryNames maps the 產業別 codes that TWSE and TPEx company data share
+// to industry names
+var industryNames = map[string]string{
+	"01":   "水泥",
+	"02":   "食品",
+	"03":   "塑膠",
+	"04":   "紡織",
+	"05":   "電機",
+	"06":   "電器電纜",
+	"08":   "玻璃陶瓷",
+	"09":   "造紙",
+	"10":   "鋼鐵",
+	"11":   "橡膠",
+	"12":   "汽車",
+	"14":   "建材營造",
+	"15":   "航運",
+	"16":   "觀光餐旅",
+	"17":   "金融保險",
+	"18":   "貿易百貨",
+	"20":   "其他",
+	"21":   "化學生技醫療",
+	"22":   "半導體",
+	"23":   "電腦週邊",
+	"24":   "光電",
+	"25":   "通信網路",
+	"26":   "電子零組件",
+	"27":   "電子通路",
+	"28":   "資訊服務",
+	"29":   "其他電子",
+	"30":   "文化創意",
+	"31":   "農業科技",
+	"32":   "電子商務",
+	"33":   "綠能環保",
+	"34":   "數位雲端",
+	"35":   "運動休閒",
+	"36":   "居家生活",
+	"80":   "管理股票",
+	"9299": "存託憑證",
+}
+
 type StockSyncService struct {
 	db *database.DB
 }
@@ -32,8 +115,9 @@ func NewStockSyncService(db *database.DB) *StockSyncService {
 	return &StockSyncService{db: db}
 }
 
-// SyncFromTWSE fetches all listed stocks from TWSE Open API and syncs to database
-func (s *StockSyncService) SyncFromTWSE(ctx context.Context) (int, error) {
+// SyncFromTWSE fetches all listed stocks from TWSE Open API and syncs to database.
+// Every symbol in the list is added to seen when it is non-nil.
+func (s *StockSyncService) SyncFromTWSE(ctx context.Context, seen map[string]bool) (int, error) {
 	// TWSE Open API endpoint for listed companies
 	url := "https://openapi.twse.com.tw/v1/opendata/t187ap03_L"
 
@@ -57,81 +141,57 @@ func (s *StockSyncService) SyncFromTWSE(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
-	// Industry mapping (產業別代碼 -> 產業名稱)
-	industryMap := map[string]string{
-		"01": "水泥",
-		"02": "食品",
-		"03": "塑膠",
-		"04": "紡織",
-		"05": "電機",
-		"06": "電器電纜",
-		"08": "玻璃陶瓷",
-		"09": "造紙",
-		"10": "鋼鐵",
-		"11": "橡膠",
-		"12": "汽車",
-		"14": "建材營造",
-		"15": "航運",
-		"16": "觀光餐旅",
-		"17": "金融保險",
-		"18": "貿易百貨",
-		"20": "其他",
-		"21": "化學生技醫療",
-		"22": "半導體",
-		"23": "電腦週邊",
-		"24": "光電",
-		"25": "通信網路",
-		"26": "電子零組件",
-		"27": "電子通路",
-		"28": "資訊服務",
-		"29": "其他電子",
-		"30": "文化創意",
-		"31": "農業科技",
-		"32": "電子商務",
-		"33": "綠能環保",
-		"34": "數位雲端",
-		"35": "運動休閒",
-		"36": "居家生活",
-		"80": "管理股票",
-		"9299": "存託憑證",
-	}
-
 	// Prepare batch insert
 	count := 0
 	for _, stock := range stocks {
 		if stock.CompanyCode == "" || stock.ShortName == "" {
 			continue
 		}
+		if seen != nil {
+			seen[stock.CompanyCode] = true
+		}
 
-		industry := industryMap[stock.Industry]
-		if industry == "" {
-			industry = "其他"
+		if err := s.upsertCompany(ctx, stock, "TSE"); err != nil {
+			// Log error but continue
+			fmt.Printf("Failed to insert stock %s: %v\n", stock.CompanyCode, err)
+			continue
 		}
+		count++
+	}
 
-		// Upsert stock (insert or update)
-		query := `
-			INSERT INTO taiwan_stocks (symbol, name, market, industry, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
-			ON CONFLICT (symbol) 
-			DO UPDATE SET 
-				name = EXCLUDED.name,
-				market = EXCLUDED.market,
-				industry = EXCLUDED.industry,
-				updated_at = EXCLUDED.updated_at
-		`
+	return count, nil
+}
 
-		_, err := s.db.ExecContext(ctx, query,
-			stock.CompanyCode,
-			stock.ShortName,
-			"TSE",
-			industry,
-			time.Now(),
-			time.Now(),
-		)
+// SyncFromTPEx fetches OTC companies from the TPEx company basic info open
+// data, with their industries and profiles, adding them to seen when non-nil.
+// When that source is unavailable it falls back to the mainboard PE ratio list.
+func (s *StockSyncService) SyncFromTPEx(ctx context.Context, seen map[string]bool) (int, error) {
+	body, err := fetchNewsBody(ctx, "https://www.tpex.org.tw/openapi/v1/mopsfe_company_basic_info",
+		map[string]string{"Accept": "application/json"})
+	var companies []TPExCompany
+	if err == nil {
+		err = json.Unmarshal(body, &companies)
+	}
+	if err == nil && len(companies) == 0 {
+		err = fmt.Errorf("empty company list")
+	}
+	if err != nil {
+		fmt.Printf("TPEx company basic info unavailable, falling back to PE ratio list: %v\n", err)
+		return s.syncFromTPExPERatio(ctx, seen)
+	}
 
-		if err != nil {
-			// Log error but continue
-			fmt.Printf("Failed to insert stock %s: %v\n", stock.CompanyCode, err)
+	count := 0
+	for _, company := range companies {
+		stock := company.toTWSeStock()
+		if stock.CompanyCode == "" || stock.ShortName == "" {
+			continue
+		}
+		if seen != nil {
+			seen[stock.CompanyCode] = true
+		}
+
+		if err := s.upsertCompany(ctx, stock, "OTC"); err != nil {
+			fmt.Printf("Failed to insert OTC stock %s: %v\n", stock.CompanyCode, err)
 			continue
 		}
 		count++
@@ -140,8 +200,59 @@ func (s *StockSyncService) SyncFromTWSE(ctx context.Context) (int, error) {
 	return count, nil
 }
 
-// SyncFromTPEx fetches OTC stocks from TPEx API
-func (s *StockSyncService) SyncFromTPEx(ctx context.Context) (int, error) {
+// upsertCompany inserts or updates a company from either market's company
+// data, keeping profile fields the source left blank
+func (s *StockSyncService) upsertCompany(ctx context.Context, stock TWSeStock, market string) error {
+	industry := industryNames[stock.Industry]
+	if industry == "" {
+		industry = "其他"
+	}
+	secType := securityType(stock.CompanyCode)
+	if stock.Industry == "9299" {
+		secType = SecurityTDR
+	}
+
+	query := `
+		INSERT INTO taiwan_stocks (symbol, name, market, industry, security_type,
+			paid_in_capital, listing_date, chairman, website, address, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NULLIF($8, ''), NULLIF($9, ''), NULLIF($10, ''), $11, $12)
+		ON CONFLICT (symbol) 
+		DO UPDATE SET 
+			name = EXCLUDED.name,
+			market = EXCLUDED.market,
+			industry = EXCLUDED.industry,
+			security_type = EXCLUDED.security_type,
+			paid_in_capital = COALESCE(EXCLUDED.paid_in_capital, taiwan_stocks.paid_in_capital),
+			listing_date = COALESCE(EXCLUDED.listing_date, taiwan_stocks.listing_date),
+			chairman = COALESCE(EXCLUDED.chairman, taiwan_stocks.chairman),
+			website = COALESCE(EXCLUDED.website, taiwan_stocks.website),
+			address = COALESCE(EXCLUDED.address, taiwan_stocks.address),
+			is_active = true,
+			delisted_at = NULL,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		stock.CompanyCode,
+		strings.TrimSpace(stock.ShortName),
+		market,
+		industry,
+		secType,
+		parsePaidInCapital(stock.PaidInCapital),
+		parseProfileDate(stock.ListingDate),
+		strings.TrimSpace(stock.Chairman),
+		strings.TrimSpace(stock.Website),
+		strings.TrimSpace(stock.Address),
+		time.Now(),
+		time.Now(),
+	)
+	return err
+}
+
+// syncFromTPExPERatio is the SyncFromTPEx fallback: the mainboard PE ratio
+// list has no industries and leaves some securities out, so new symbols get
+// the placeholder industry 上櫃 and OTC symbols are never deactivated by it
+func (s *StockSyncService) syncFromTPExPERatio(ctx context.Context, seen map[string]bool) (int, error) {
 	// TPEx API endpoint
 	url := "https://www.tpex.org.tw/openapi/v1/tpex_mainboard_peratio_analysis"
 
@@ -165,24 +276,44 @@ func (s *StockSyncService) SyncFromTPEx(ctx context.Context) (int, error) {
 		return 0, fmt.Errorf("failed to parse JSON: %w", err)
 	}
 
+	if seen != nil {
+		rows, err := s.db.QueryContext(ctx, `SELECT symbol FROM taiwan_stocks WHERE market = 'OTC' AND is_active`)
+		if err != nil {
+			return 0, fmt.Errorf("failed to load OTC stocks: %w", err)
+		}
+		for rows.Next() {
+			var symbol string
+			if err := rows.Scan(&symbol); err == nil {
+				seen[symbol] = true
+			}
+		}
+		rows.Close()
+	}
+
 	count := 0
 	for _, stock := range stocks {
 		if stock.Code == "" || stock.Name == "" {
 			continue
 		}
 
-		// Skip ETF and bonds (usually start with 00 or 9)
-		if len(stock.Code) >= 2 && (stock.Code[:2] == "00" || stock.Code[:1] == "9") {
+		// Skip bonds (start with 9); ETFs come from SyncETFs
+		if stock.Code[:1] == "9" {
 			continue
 		}
+		if seen != nil {
+			seen[stock.Code] = true
+		}
 
 		query := `
-			INSERT INTO taiwan_stocks (symbol, name, market, industry, created_at, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6)
+			INSERT INTO taiwan_stocks (symbol, name, market, industry, security_type, created_at, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
 			ON CONFLICT (symbol) 
 			DO UPDATE SET 
 				name = EXCLUDED.name,
 				market = EXCLUDED.market,
+				security_type = EXCLUDED.security_type,
+				is_active = true,
+				delisted_at = NULL,
 				updated_at = EXCLUDED.updated_at
 		`
 
@@ -191,6 +322,7 @@ func (s *StockSyncService) SyncFromTPEx(ctx context.Context) (int, error) {
 			stock.Name,
 			"OTC",
 			"上櫃",
+			securityType(stock.Code),
 			time.Now(),
 			time.Now(),
 		)
@@ -205,23 +337,223 @@ func (s *StockSyncService) SyncFromTPEx(ctx context.Context) (int, error) {
 	return count, nil
 }
 
-// SyncAll syncs both TSE and OTC stocks
-func (s *StockSyncService) SyncAll(ctx context.Context) (map[string]int, error) {
+// SyncETFs adds the ETFs and ETNs traded on TWSE and TPEx, which the company
+// lists leave out, from the daily trading reports of both markets. They are
+// added to seen when it is non-nil.
+func (s *StockSyncService) SyncETFs(ctx context.Context, seen map[string]bool) (int, error) {
+	feeds := []struct {
+		market string
+		url    string
+	}{
+		{"TSE", "https://openapi.twse.com.tw/v1/exchangeReport/STOCK_DAY_ALL"},
+		{"OTC", "https://www.tpex.org.tw/openapi/v1/tpex_mainboard_daily_close_quotes"},
+	}
+
+	query := `
+		INSERT INTO taiwan_stocks (symbol, name, market, industry, security_type, created_at, updated_at)
+		VALUES ($1, $2, $3, 'ETF', $4, NOW(), NOW())
+		ON CONFLICT (symbol)
+		DO UPDATE SET
+			name = EXCLUDED.name,
+			market = EXCLUDED.market,
+			industry = EXCLUDED.industry,
+			security_type = EXCLUDED.security_type,
+			is_active = true,
+			delisted_at = NULL,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	count := 0
+	for _, feed := range feeds {
+		body, err := fetchNewsBody(ctx, feed.url, map[string]string{"Accept": "application/json"})
+		if err != nil {
+			return count, fmt.Errorf("failed to fetch %s securities: %w", feed.market, err)
+		}
+
+		var securities []TWSeSecurity
+		if feed.market == "OTC" {
+			var otc []TPExStock
+			if err := json.Unmarshal(body, &otc); err != nil {
+				return count, fmt.Errorf("failed to parse %s securities: %w", feed.market, err)
+			}
+			for _, o := range otc {
+				securities = append(securities, TWSeSecurity{Code: o.Code, Name: o.Name})
+			}
+		} else if err := json.Unmarshal(body, &securities); err != nil {
+			return count, fmt.Errorf("failed to parse %s securities: %w", feed.market, err)
+		}
+
+		for _, sec := range securities {
+			code, name := strings.TrimSpace(sec.Code), strings.TrimSpace(sec.Name)
+			secType := securityType(code)
+			if name == "" || (secType != SecurityETF && secType != SecurityETN) {
+				continue
+			}
+			if seen != nil {
+				seen[code] = true
+			}
+			if _, err := s.db.ExecContext(ctx, query, code, name, feed.market, secType); err != nil {
+				fmt.Printf("Failed to insert ETF %s: %v\n", code, err)
+				continue
+			}
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// SyncAll syncs TSE and OTC stocks and ETFs, then deactivates the symbols
+// none of the sources listed. The run is recorded in stock_sync_log under
+// trigger (manual, scheduled or cli).
+func (s *StockSyncService) SyncAll(ctx context.Context, trigger string) (map[string]int, error) {
+	run := &StockSyncRun{TriggeredBy: trigger, Status: "success", StartedAt: time.Now()}
+
+	result, err := s.syncAll(ctx, run)
+	if err != nil {
+		run.Status = "failed"
+		run.Errors = append(run.Errors, err.Error())
+	}
+	run.FinishedAt = time.Now()
+	if logErr := s.recordRun(ctx, run); logErr != nil {
+		fmt.Printf("Failed to record stock sync: %v\n", logErr)
+	}
+	return result, err
+}
+
+func (s *StockSyncService) syncAll(ctx context.Context, run *StockSyncRun) (map[string]int, error) {
 	result := make(map[string]int)
+	seen := make(map[string]bool)
+
+	before, err := s.loadSyncSnapshot(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	tseCount, err := s.SyncFromTWSE(ctx)
+	tseCount, err := s.SyncFromTWSE(ctx, seen)
 	if err != nil {
 		return nil, fmt.Errorf("TSE sync failed: %w", err)
 	}
 	result["tse"] = tseCount
+	run.TSECount = tseCount
 
-	otcCount, err := s.SyncFromTPEx(ctx)
+	otcCount, err := s.SyncFromTPEx(ctx, seen)
 	if err != nil {
 		return nil, fmt.Errorf("OTC sync failed: %w", err)
 	}
 	result["otc"] = otcCount
+	run.OTCCount = otcCount
+
+	etfCount, err := s.SyncETFs(ctx, seen)
+	if err != nil {
+		return nil, fmt.Errorf("ETF sync failed: %w", err)
+	}
+	result["etf"] = etfCount
+	run.ETFCount = etfCount
+
+	result["total"] = tseCount + otcCount + etfCount
+	run.TotalCount = result["total"]
 
-	result["total"] = tseCount + otcCount
+	// A failed delisting check leaves the upserted list in place
+	deactivated, err := s.DeactivateMissing(ctx, seen)
+	if err != nil {
+		fmt.Printf("Skipped delisting check: %v\n", err)
+		run.Errors = append(run.Errors, "delisting check skipped: "+err.Error())
+	}
+	run.DeactivatedSymbols = deactivated
+	result["deactivated"] = len(deactivated)
+
+	after, err := s.loadSyncSnapshot(ctx)
+	if err != nil {
+		return result, err
+	}
+	run.NewSymbols, run.UpdatedSymbols = diffSyncSnapshots(before, after, deactivated)
+	result["new"] = len(run.NewSymbols)
+	result["updated"] = len(run.UpdatedSymbols)
 
 	return result, nil
 }
+
+// parsePaidInCapital parses a profile amount such as "259,303,804,580",
+// returning nil when it is missing or malformed
+func parsePaidInCapital(s string) *int64 {
+	v, err := strconv.ParseInt(strings.ReplaceAll(strings.TrimSpace(s), ",", ""), 10, 64)
+	if err != nil || v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// parseProfileDate parses a profile date, either AD "19940905" or ROC
+// "0830905" / "83/09/05", returning nil when it is missing or malformed
+func parseProfileDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	var t time.Time
+	var err error
+	switch {
+	case strings.Contains(s, "/"):
+		t, err = parseROCDate(s)
+	case len(s) == 8:
+		t, err = time.Parse("20060102", s)
+	case len(s) == 7:
+		var rocYear int
+		rocYear, err = strconv.Atoi(s[:3])
+		if err == nil {
+			t, err = time.Parse("20060102", fmt.Sprintf("%04d%s", rocYear+1911, s[3:]))
+		}
+	default:
+		return nil
+	}
+	if err != nil || t.Year() < 1950 {
+		return nil
+	}
+	return &t
+}
+
+// minSyncCoverage is the share of active symbols a sync must have seen before
+// the rest are deactivated; a smaller list means a truncated source response
+const minSyncCoverage = 0.8
+
+// DeactivateMissing marks the active stocks, ETFs, ETNs and TDRs that are not
+// in seen as inactive, delisted today, and returns their symbols. Warrants,
+// preferred shares and other types the sync does not list are left alone.
+func (s *StockSyncService) DeactivateMissing(ctx context.Context, seen map[string]bool) ([]string, error) {
+	symbols := make([]string, 0, len(seen))
+	for symbol := range seen {
+		symbols = append(symbols, symbol)
+	}
+
+	var active int
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM taiwan_stocks
+		WHERE is_active AND security_type IN ('stock', 'etf', 'etn', 'tdr')
+	`).Scan(&active); err != nil {
+		return nil, fmt.Errorf("failed to count active stocks: %w", err)
+	}
+	if float64(len(symbols)) < float64(active)*minSyncCoverage {
+		return nil, fmt.Errorf("sources listed %d symbols against %d active, refusing to deactivate", len(symbols), active)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		UPDATE taiwan_stocks
+		SET is_active = false, delisted_at = CURRENT_DATE, updated_at = NOW()
+		WHERE is_active
+		  AND security_type IN ('stock', 'etf', 'etn', 'tdr')
+		  AND NOT (symbol = ANY($1))
+		RETURNING symbol
+	`, pq.Array(symbols))
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate missing stocks: %w", err)
+	}
+	defer rows.Close()
+
+	deactivated := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err != nil {
+			return nil, fmt.Errorf("failed to scan deactivated stock: %w", err)
+		}
+		deactivated = append(deactivated, symbol)
+	}
+	return deactivated, rows.Err()
+}
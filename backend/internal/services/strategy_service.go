@@ -0,0 +1,340 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+)
+
+// StrategyService stores named, versioned strategy definitions
+type StrategyService struct {
+	db *database.DB
+}
+
+func NewStrategyService(db *database.DB) *StrategyService {
+	return &StrategyService{db: db}
+}
+
+// StrategyUniverse is the set of stocks a strategy targets
+type StrategyUniverse struct {
+	Symbols []string `json:"symbols,omitempty"` // Explicit symbol list
+	Preset  string   `json:"preset,omitempty"`  // Or a screener preset evaluated at run time
+}
+
+// StrategyDefinition is the versioned body of a strategy
+type StrategyDefinition struct {
+	Entry           BacktestRule       `json:"entry"`
+	Exit            *BacktestRule      `json:"exit,omitempty"`
+	StopLossPct     float64            `json:"stop_loss_pct,omitempty"`
+	TakeProfitPct   float64            `json:"take_profit_pct,omitempty"`
+	PositionSizePct float64            `json:"position_size_pct,omitempty"`
+	Universe        StrategyUniverse   `json:"universe"`
+	Parameters      map[string]float64 `json:"parameters,omitempty"` // Free-form tunables, recorded for reference
+}
+
+// Strategy is a named strategy with its current definition
+type Strategy struct {
+	ID          string             `json:"id"`
+	UserID      uuid.UUID          `json:"user_id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description,omitempty"`
+	Version     int                `json:"version"`
+	Definition  StrategyDefinition `json:"definition"`
+	IsArchived  bool               `json:"is_archived"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// StrategyVersion is one immutable revision of a strategy
+type StrategyVersion struct {
+	Version    int                `json:"version"`
+	Definition StrategyDefinition `json:"definition"`
+	ChangeNote string             `json:"change_note,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
+// SaveStrategyRequest is the payload for creating or updating a strategy
+type SaveStrategyRequest struct {
+	Name        string              `json:"name"`
+	Description *string             `json:"description,omitempty"`
+	Definition  *StrategyDefinition `json:"definition,omitempty"`
+	ChangeNote  string              `json:"change_note,omitempty"`
+}
+
+// Validate checks the rules the same way a backtest would
+func (d *StrategyDefinition) Validate() error {
+	if _, err := ruleLookback(d.Entry); err != nil {
+		return fmt.Errorf("invalid entry rule: %w", err)
+	}
+	if d.Exit != nil {
+		if _, err := ruleLookback(*d.Exit); err != nil {
+			return fmt.Errorf("invalid exit rule: %w", err)
+		}
+	} else if d.StopLossPct <= 0 && d.TakeProfitPct <= 0 {
+		return fmt.Errorf("an exit rule, stop_loss_pct or take_profit_pct is required")
+	}
+	for i, symbol := range d.Universe.Symbols {
+		d.Universe.Symbols[i] = strings.ToUpper(strings.TrimSpace(symbol))
+	}
+	return nil
+}
+
+// CreateStrategy stores a new strategy as version 1
+func (s *StrategyService) CreateStrategy(ctx context.Context, userID uuid.UUID, req SaveStrategyRequest) (*Strategy, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if req.Definition == nil {
+		return nil, fmt.Errorf("definition is required")
+	}
+	if err := req.Definition.Validate(); err != nil {
+		return nil, err
+	}
+	definitionJSON, err := json.Marshal(req.Definition)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id string
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO strategies (user_id, name, description, current_version)
+		VALUES ($1, $2, $3, 1)
+		RETURNING id
+	`, userID, req.Name, req.Description).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("strategy name already exists: %s", req.Name)
+		}
+		return nil, fmt.Errorf("failed to create strategy: %w", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO strategy_versions (strategy_id, version, definition, change_note)
+		VALUES ($1, 1, $2, NULLIF($3, ''))
+	`, id, definitionJSON, req.ChangeNote); err != nil {
+		return nil, fmt.Errorf("failed to create strategy version: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to create strategy: %w", err)
+	}
+
+	return s.GetStrategy(ctx, id)
+}
+
+// UpdateStrategy renames or re-describes a strategy in place; a changed definition
+// is stored as a new version and becomes current
+func (s *StrategyService) UpdateStrategy(ctx context.Context, id string, req SaveStrategyRequest) (*Strategy, error) {
+	current, err := s.GetStrategy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.IsArchived {
+		return nil, fmt.Errorf("strategy is archived")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if name := strings.TrimSpace(req.Name); name != "" || req.Description != nil {
+		if name == "" {
+			name = current.Name
+		}
+		description := current.Description
+		if req.Description != nil {
+			description = *req.Description
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE strategies SET name = $2, description = NULLIF($3, '') WHERE id = $1
+		`, id, name, description); err != nil {
+			if strings.Contains(err.Error(), "duplicate key") {
+				return nil, fmt.Errorf("strategy name already exists: %s", name)
+			}
+			return nil, fmt.Errorf("failed to update strategy: %w", err)
+		}
+	}
+
+	if req.Definition != nil {
+		if err := req.Definition.Validate(); err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(*req.Definition, current.Definition) {
+			definitionJSON, err := json.Marshal(req.Definition)
+			if err != nil {
+				return nil, err
+			}
+			next := current.Version + 1
+			if _, err := tx.ExecContext(ctx, `
+				INSERT INTO strategy_versions (strategy_id, version, definition, change_note)
+				VALUES ($1, $2, $3, NULLIF($4, ''))
+			`, id, next, definitionJSON, req.ChangeNote); err != nil {
+				return nil, fmt.Errorf("failed to create strategy version: %w", err)
+			}
+			if _, err := tx.ExecContext(ctx, `
+				UPDATE strategies SET current_version = $2 WHERE id = $1
+			`, id, next); err != nil {
+				return nil, fmt.Errorf("failed to update strategy: %w", err)
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to update strategy: %w", err)
+	}
+
+	return s.GetStrategy(ctx, id)
+}
+
+// GetStrategy returns a strategy with its current definition
+func (s *StrategyService) GetStrategy(ctx context.Context, id string) (*Strategy, error) {
+	query := `
+		SELECT s.id, s.user_id, s.name, COALESCE(s.description, ''), s.current_version, v.definition,
+		       s.is_archived, s.created_at, s.updated_at
+		FROM strategies s
+		JOIN strategy_versions v ON v.strategy_id = s.id AND v.version = s.current_version
+		WHERE s.id = $1
+	`
+
+	var st Strategy
+	var definitionJSON []byte
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&st.ID, &st.UserID, &st.Name, &st.Description, &st.Version, &definitionJSON,
+		&st.IsArchived, &st.CreatedAt, &st.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("strategy not found: %s", id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definitionJSON, &st.Definition); err != nil {
+		return nil, fmt.Errorf("failed to decode strategy definition: %w", err)
+	}
+
+	return &st, nil
+}
+
+// ListStrategies returns a user's strategies with their current definitions
+func (s *StrategyService) ListStrategies(ctx context.Context, userID uuid.UUID, includeArchived bool) ([]Strategy, error) {
+	query := `
+		SELECT s.id, s.user_id, s.name, COALESCE(s.description, ''), s.current_version, v.definition,
+		       s.is_archived, s.created_at, s.updated_at
+		FROM strategies s
+		JOIN strategy_versions v ON v.strategy_id = s.id AND v.version = s.current_version
+		WHERE s.user_id = $1 AND ($2 OR s.is_archived = FALSE)
+		ORDER BY s.updated_at DESC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, includeArchived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	strategies := []Strategy{}
+	for rows.Next() {
+		var st Strategy
+		var definitionJSON []byte
+		if err := rows.Scan(
+			&st.ID, &st.UserID, &st.Name, &st.Description, &st.Version, &definitionJSON,
+			&st.IsArchived, &st.CreatedAt, &st.UpdatedAt,
+		); err != nil {
+			continue
+		}
+		json.Unmarshal(definitionJSON, &st.Definition)
+		strategies = append(strategies, st)
+	}
+
+	return strategies, nil
+}
+
+// ListVersions returns every revision of a strategy, newest first
+func (s *StrategyService) ListVersions(ctx context.Context, id string) ([]StrategyVersion, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT version, definition, COALESCE(change_note, ''), created_at
+		FROM strategy_versions
+		WHERE strategy_id = $1
+		ORDER BY version DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []StrategyVersion{}
+	for rows.Next() {
+		var v StrategyVersion
+		var definitionJSON []byte
+		if err := rows.Scan(&v.Version, &definitionJSON, &v.ChangeNote, &v.CreatedAt); err != nil {
+			continue
+		}
+		json.Unmarshal(definitionJSON, &v.Definition)
+		versions = append(versions, v)
+	}
+
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("strategy not found: %s", id)
+	}
+	return versions, nil
+}
+
+// ErrStrategyVersionNotFound is returned by GetVersion for an unknown strategy or revision
+var ErrStrategyVersionNotFound = errors.New("strategy version not found")
+
+// GetVersion returns a specific revision; version 0 means the current one
+func (s *StrategyService) GetVersion(ctx context.Context, id string, version int) (*StrategyVersion, error) {
+	query := `
+		SELECT v.version, v.definition, COALESCE(v.change_note, ''), v.created_at
+		FROM strategy_versions v
+		JOIN strategies s ON s.id = v.strategy_id
+		WHERE v.strategy_id = $1 AND v.version = CASE WHEN $2 > 0 THEN $2 ELSE s.current_version END
+	`
+
+	var v StrategyVersion
+	var definitionJSON []byte
+	err := s.db.QueryRowContext(ctx, query, id, version).Scan(&v.Version, &definitionJSON, &v.ChangeNote, &v.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %s v%d", ErrStrategyVersionNotFound, id, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(definitionJSON, &v.Definition); err != nil {
+		return nil, fmt.Errorf("failed to decode strategy definition: %w", err)
+	}
+
+	return &v, nil
+}
+
+// ArchiveStrategy hides a strategy while keeping its versions for linked backtests
+func (s *StrategyService) ArchiveStrategy(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `
+		UPDATE strategies SET is_archived = TRUE WHERE id = $1 AND is_archived = FALSE
+	`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("strategy not found: %s", id)
+	}
+	return nil
+}
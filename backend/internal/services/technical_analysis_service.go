@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"psm-backend/internal/database"
+	"sync"
 	"time"
 
 	"github.com/markcheno/go-talib"
@@ -341,6 +343,747 @@ func (s *TechnicalAnalysisService) CalculateKDJ(ctx context.Context, symbol stri
 	return results, nil
 }
 
+// BatchIndicatorParams holds the tunable parameters for a batch indicator request
+type BatchIndicatorParams struct {
+	MAPeriod  int    `json:"ma_period"`
+	MAType    string `json:"ma_type"`
+	RSIPeriod int    `json:"rsi_period"`
+	BBPeriod  int    `json:"bb_period"`
+	KDJPeriod int    `json:"kdj_period"`
+}
+
+// SymbolIndicators holds the batch indicator results for one symbol
+type SymbolIndicators struct {
+	Symbol string                 `json:"symbol"`
+	Data   map[string]interface{} `json:"data"`
+	Errors map[string]string      `json:"errors,omitempty"`
+}
+
+// Upper bound on concurrent symbol calculations in CalculateMultiSymbolIndicators
+const maxBatchIndicatorWorkers = 8
+
+// CalculateIndicatorSet calculates several indicators for one symbol.
+// Supported indicators: MA, RSI, MACD, BB, KDJ. Unknown names are reported in the errors map.
+func (s *TechnicalAnalysisService) CalculateIndicatorSet(ctx context.Context, symbol string, indicators []string, params BatchIndicatorParams, limit int) *SymbolIndicators {
+	if params.MAPeriod <= 0 {
+		params.MAPeriod = 20
+	}
+	if params.MAType == "" {
+		params.MAType = "SMA"
+	}
+	if params.RSIPeriod <= 0 {
+		params.RSIPeriod = 14
+	}
+	if params.BBPeriod <= 0 {
+		params.BBPeriod = 20
+	}
+	if params.KDJPeriod <= 0 {
+		params.KDJPeriod = 9
+	}
+
+	result := &SymbolIndicators{
+		Symbol: symbol,
+		Data:   make(map[string]interface{}),
+		Errors: make(map[string]string),
+	}
+
+	for _, indicator := range indicators {
+		var data interface{}
+		var err error
+
+		switch indicator {
+		case "MA":
+			data, err = s.CalculateMA(ctx, symbol, params.MAPeriod, params.MAType, limit)
+		case "RSI":
+			data, err = s.CalculateRSI(ctx, symbol, params.RSIPeriod, limit)
+		case "MACD":
+			data, err = s.CalculateMACD(ctx, symbol, 12, 26, 9, limit)
+		case "BB":
+			data, err = s.CalculateBollingerBands(ctx, symbol, params.BBPeriod, 2.0, limit)
+		case "KDJ":
+			data, err = s.CalculateKDJ(ctx, symbol, params.KDJPeriod, limit)
+		default:
+			err = fmt.Errorf("unsupported indicator: %s", indicator)
+		}
+
+		if err != nil {
+			result.Errors[indicator] = err.Error()
+			continue
+		}
+		result.Data[indicator] = data
+	}
+
+	if len(result.Errors) == 0 {
+		result.Errors = nil
+	}
+
+	return result
+}
+
+// CalculateMultiSymbolIndicators calculates the same indicator set for many symbols
+// concurrently using a bounded worker pool. Results keep the order of the input symbols.
+func (s *TechnicalAnalysisService) CalculateMultiSymbolIndicators(ctx context.Context, symbols []string, indicators []string, params BatchIndicatorParams, limit int) []SymbolIndicators {
+	results := make([]SymbolIndicators, len(symbols))
+
+	workers := maxBatchIndicatorWorkers
+	if len(symbols) < workers {
+		workers = len(symbols)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = *s.CalculateIndicatorSet(ctx, symbols[i], indicators, params, limit)
+			}
+		}()
+	}
+
+	for i := range symbols {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = SymbolIndicators{
+				Symbol: symbols[i],
+				Errors: map[string]string{"*": ctx.Err().Error()},
+			}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// PivotPoints represents pivot levels derived from the previous completed period
+type PivotPoints struct {
+	Symbol      string          `json:"symbol"`
+	Method      string          `json:"method"`    // classic, fibonacci
+	Timeframe   string          `json:"timeframe"` // daily, weekly
+	PeriodStart time.Time       `json:"period_start"`
+	PeriodEnd   time.Time       `json:"period_end"`
+	High        decimal.Decimal `json:"high"`
+	Low         decimal.Decimal `json:"low"`
+	Close       decimal.Decimal `json:"close"`
+	Pivot       decimal.Decimal `json:"pivot"`
+	R1          decimal.Decimal `json:"r1"`
+	R2          decimal.Decimal `json:"r2"`
+	R3          decimal.Decimal `json:"r3"`
+	S1          decimal.Decimal `json:"s1"`
+	S2          decimal.Decimal `json:"s2"`
+	S3          decimal.Decimal `json:"s3"`
+}
+
+// CalculatePivotPoints calculates classic or Fibonacci pivot points for the next session.
+// Daily pivots use the last completed trading day; weekly pivots use the last completed week.
+func (s *TechnicalAnalysisService) CalculatePivotPoints(ctx context.Context, symbol string, method string, timeframe string) (*PivotPoints, error) {
+	if method != "classic" && method != "fibonacci" {
+		return nil, fmt.Errorf("unsupported pivot method: %s", method)
+	}
+	if timeframe != "daily" && timeframe != "weekly" {
+		return nil, fmt.Errorf("unsupported pivot timeframe: %s", timeframe)
+	}
+
+	loc, _ := time.LoadLocation("Asia/Taipei")
+	if loc == nil {
+		loc = time.FixedZone("CST", 8*3600)
+	}
+	today := time.Now().In(loc).Format("2006-01-02")
+
+	// Levels only change when a period completes, so key the cache by trading date
+	cacheKey := fmt.Sprintf("indicator:%s:PIVOT:%s:%s:%s", symbol, method, timeframe, today)
+	if cached, err := s.getCache(ctx, cacheKey); err == nil && cached != nil {
+		var pivots PivotPoints
+		if err := json.Unmarshal(cached, &pivots); err == nil {
+			return &pivots, nil
+		}
+	}
+
+	ohlcv, err := s.getOHLCVData(ctx, symbol, 15)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OHLCV data: %w", err)
+	}
+	if len(ohlcv) == 0 {
+		return nil, fmt.Errorf("no data available for %s", symbol)
+	}
+
+	// Drop the bar of the session in progress so levels come from a completed period
+	completed := ohlcv
+	if completed[len(completed)-1].Timestamp.In(loc).Format("2006-01-02") == today {
+		completed = completed[:len(completed)-1]
+	}
+	if len(completed) == 0 {
+		return nil, fmt.Errorf("no completed period available for %s", symbol)
+	}
+
+	var period []OHLCV
+	if timeframe == "daily" {
+		period = completed[len(completed)-1:]
+	} else {
+		// Take the most recent ISO week that has ended before the current one
+		curYear, curWeek := time.Now().In(loc).ISOWeek()
+		for i := len(completed) - 1; i >= 0; i-- {
+			year, week := completed[i].Timestamp.In(loc).ISOWeek()
+			if year == curYear && week == curWeek {
+				continue
+			}
+			if len(period) > 0 {
+				pYear, pWeek := period[0].Timestamp.In(loc).ISOWeek()
+				if year != pYear || week != pWeek {
+					break
+				}
+			}
+			period = append([]OHLCV{completed[i]}, period...)
+		}
+		if len(period) == 0 {
+			return nil, fmt.Errorf("no completed week available for %s", symbol)
+		}
+	}
+
+	high := period[0].High
+	low := period[0].Low
+	for _, bar := range period[1:] {
+		if bar.High.GreaterThan(high) {
+			high = bar.High
+		}
+		if bar.Low.LessThan(low) {
+			low = bar.Low
+		}
+	}
+	closePrice := period[len(period)-1].Close
+
+	pivots := buildPivotPoints(high, low, closePrice, method)
+	pivots.Symbol = symbol
+	pivots.Timeframe = timeframe
+	pivots.PeriodStart = period[0].Timestamp
+	pivots.PeriodEnd = period[len(period)-1].Timestamp
+
+	if data, err := json.Marshal(pivots); err == nil {
+		s.setCache(ctx, cacheKey, data, 1*time.Hour)
+	}
+
+	return pivots, nil
+}
+
+// buildPivotPoints applies the classic or Fibonacci pivot formulas
+func buildPivotPoints(high, low, closePrice decimal.Decimal, method string) *PivotPoints {
+	three := decimal.NewFromInt(3)
+	two := decimal.NewFromInt(2)
+	pivot := high.Add(low).Add(closePrice).Div(three)
+	span := high.Sub(low)
+
+	p := &PivotPoints{
+		Method: method,
+		High:   high,
+		Low:    low,
+		Close:  closePrice,
+		Pivot:  pivot.Round(2),
+	}
+
+	if method == "fibonacci" {
+		levels := []decimal.Decimal{
+			decimal.NewFromFloat(0.382),
+			decimal.NewFromFloat(0.618),
+			decimal.NewFromInt(1),
+		}
+		p.R1 = pivot.Add(span.Mul(levels[0])).Round(2)
+		p.R2 = pivot.Add(span.Mul(levels[1])).Round(2)
+		p.R3 = pivot.Add(span.Mul(levels[2])).Round(2)
+		p.S1 = pivot.Sub(span.Mul(levels[0])).Round(2)
+		p.S2 = pivot.Sub(span.Mul(levels[1])).Round(2)
+		p.S3 = pivot.Sub(span.Mul(levels[2])).Round(2)
+		return p
+	}
+
+	// Classic (floor trader) pivots
+	p.R1 = two.Mul(pivot).Sub(low).Round(2)
+	p.S1 = two.Mul(pivot).Sub(high).Round(2)
+	p.R2 = pivot.Add(span).Round(2)
+	p.S2 = pivot.Sub(span).Round(2)
+	p.R3 = high.Add(two.Mul(pivot.Sub(low))).Round(2)
+	p.S3 = low.Sub(two.Mul(high.Sub(pivot))).Round(2)
+	return p
+}
+
+// BBSqueezeResult represents Bollinger band width and squeeze state for one bar
+type BBSqueezeResult struct {
+	Timestamp  time.Time       `json:"timestamp"`
+	Bandwidth  decimal.Decimal `json:"bandwidth"`  // (upper - lower) / middle * 100
+	Percentile decimal.Decimal `json:"percentile"` // Rank of bandwidth within the lookback window (0-100)
+	IsSqueeze  bool            `json:"is_squeeze"`
+}
+
+// Default lookback (bars) used to rank band width for squeeze detection
+const squeezeLookback = 120
+
+// CalculateBBSqueeze calculates Bollinger band width and flags squeezes, i.e. bars whose
+// band width ranks at or below the threshold percentile within the lookback window.
+func (s *TechnicalAnalysisService) CalculateBBSqueeze(ctx context.Context, symbol string, period int, stdDev float64, lookback int, threshold float64, limit int) ([]BBSqueezeResult, error) {
+	ohlcv, err := s.getOHLCVData(ctx, symbol, limit+lookback+period)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OHLCV data: %w", err)
+	}
+
+	if len(ohlcv) < period {
+		return nil, fmt.Errorf("insufficient data: need at least %d bars, got %d", period, len(ohlcv))
+	}
+
+	closes := make([]float64, len(ohlcv))
+	for i, candle := range ohlcv {
+		closes[i], _ = candle.Close.Float64()
+	}
+
+	upper, middle, lower := talib.BBands(closes, period, stdDev, stdDev, talib.SMA)
+	bandwidth := bbBandwidthSeries(upper, middle, lower, period)
+
+	results := make([]BBSqueezeResult, 0, len(ohlcv))
+	for i := range ohlcv {
+		if isNaN(bandwidth[i]) {
+			continue
+		}
+		pct := bandwidthPercentile(bandwidth, i, lookback)
+		if isNaN(pct) {
+			continue
+		}
+		results = append(results, BBSqueezeResult{
+			Timestamp:  ohlcv[i].Timestamp,
+			Bandwidth:  decimal.NewFromFloat(bandwidth[i]).Round(4),
+			Percentile: decimal.NewFromFloat(pct).Round(2),
+			IsSqueeze:  pct <= threshold,
+		})
+	}
+
+	if len(results) > limit {
+		results = results[len(results)-limit:]
+	}
+
+	return results, nil
+}
+
+// bbBandwidthSeries converts Bollinger bands into band width percentages (NaN during warm-up)
+func bbBandwidthSeries(upper, middle, lower []float64, period int) []float64 {
+	bandwidth := make([]float64, len(middle))
+	for i := range middle {
+		if i < period-1 || middle[i] == 0 {
+			bandwidth[i] = math.NaN()
+			continue
+		}
+		bandwidth[i] = (upper[i] - lower[i]) / middle[i] * 100
+	}
+	return bandwidth
+}
+
+// bandwidthPercentile returns the percentile rank (0-100) of values[i] among the valid values
+// in the preceding lookback window. NaN when fewer than 20 values are available.
+func bandwidthPercentile(values []float64, i int, lookback int) float64 {
+	start := i - lookback + 1
+	if start < 0 {
+		start = 0
+	}
+
+	current := values[i]
+	count, below := 0, 0
+	for j := start; j <= i; j++ {
+		if isNaN(values[j]) {
+			continue
+		}
+		count++
+		if values[j] < current {
+			below++
+		}
+	}
+
+	if count < 20 {
+		return math.NaN()
+	}
+	return float64(below) / float64(count-1) * 100
+}
+
+// BetaResult represents rolling beta and correlation against the TAIEX for one bar
+type BetaResult struct {
+	Timestamp   time.Time       `json:"timestamp"`
+	Beta        decimal.Decimal `json:"beta"`
+	Correlation decimal.Decimal `json:"correlation"`
+}
+
+// CalculateBeta calculates rolling beta and correlation of daily returns against the TAIEX.
+// Only days on which both the symbol and the index traded are used.
+func (s *TechnicalAnalysisService) CalculateBeta(ctx context.Context, symbol string, window int, limit int) ([]BetaResult, error) {
+	bars := limit + window + 1
+
+	stockBars, err := s.getOHLCVData(ctx, symbol, bars)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get OHLCV data: %w", err)
+	}
+	indexBars, err := s.getOHLCVData(ctx, IndexSymbolTAIEX, bars+20)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get index data: %w", err)
+	}
+	if len(indexBars) == 0 {
+		return nil, fmt.Errorf("no TAIEX data available, sync the index first")
+	}
+
+	indexClose := make(map[string]float64, len(indexBars))
+	for _, bar := range indexBars {
+		indexClose[bar.Timestamp.Format("2006-01-02")], _ = bar.Close.Float64()
+	}
+
+	// Align closes on common trading days
+	var timestamps []time.Time
+	var stockCloses, marketCloses []float64
+	for _, bar := range stockBars {
+		mc, ok := indexClose[bar.Timestamp.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		sc, _ := bar.Close.Float64()
+		timestamps = append(timestamps, bar.Timestamp)
+		stockCloses = append(stockCloses, sc)
+		marketCloses = append(marketCloses, mc)
+	}
+
+	if len(stockCloses) < window+1 {
+		return nil, fmt.Errorf("insufficient overlapping data: need %d days, got %d", window+1, len(stockCloses))
+	}
+
+	// Daily returns; returns[i] belongs to timestamps[i+1]
+	n := len(stockCloses) - 1
+	stockReturns := make([]float64, n)
+	marketReturns := make([]float64, n)
+	for i := 0; i < n; i++ {
+		if stockCloses[i] == 0 || marketCloses[i] == 0 {
+			continue
+		}
+		stockReturns[i] = stockCloses[i+1]/stockCloses[i] - 1
+		marketReturns[i] = marketCloses[i+1]/marketCloses[i] - 1
+	}
+
+	results := make([]BetaResult, 0, n-window+1)
+	for end := window; end <= n; end++ {
+		beta, corr, ok := betaAndCorrelation(stockReturns[end-window:end], marketReturns[end-window:end])
+		if !ok {
+			continue
+		}
+		results = append(results, BetaResult{
+			Timestamp:   timestamps[end],
+			Beta:        decimal.NewFromFloat(beta).Round(4),
+			Correlation: decimal.NewFromFloat(corr).Round(4),
+		})
+	}
+
+	if len(results) > limit {
+		results = results[len(results)-limit:]
+	}
+
+	return results, nil
+}
+
+// betaAndCorrelation computes beta (cov/var of market) and Pearson correlation of two return series
+func betaAndCorrelation(stock, market []float64) (float64, float64, bool) {
+	n := float64(len(stock))
+	if n < 2 {
+		return 0, 0, false
+	}
+
+	var meanS, meanM float64
+	for i := range stock {
+		meanS += stock[i]
+		meanM += market[i]
+	}
+	meanS /= n
+	meanM /= n
+
+	var cov, varS, varM float64
+	for i := range stock {
+		ds := stock[i] - meanS
+		dm := market[i] - meanM
+		cov += ds * dm
+		varS += ds * ds
+		varM += dm * dm
+	}
+	if varM == 0 || varS == 0 {
+		return 0, 0, false
+	}
+
+	return cov / varM, cov / math.Sqrt(varS*varM), true
+}
+
+// WarmIndicatorCache drops and recomputes the cached standard indicator set for a symbol
+// (SMA 5/20/60, RSI 14, MACD 12/26/9, BB 20/2, KDJ 9) so later requests hit Redis.
+func (s *TechnicalAnalysisService) WarmIndicatorCache(ctx context.Context, symbol string) error {
+	if s.redisClient == nil {
+		return nil
+	}
+
+	keys := []string{
+		fmt.Sprintf("indicator:%s:MA:SMA:5", symbol),
+		fmt.Sprintf("indicator:%s:MA:SMA:20", symbol),
+		fmt.Sprintf("indicator:%s:MA:SMA:60", symbol),
+		fmt.Sprintf("indicator:%s:RSI:14", symbol),
+		fmt.Sprintf("indicator:%s:MACD:12:26:9", symbol),
+		fmt.Sprintf("indicator:%s:BB:20:2.0", symbol),
+		fmt.Sprintf("indicator:%s:KDJ:9", symbol),
+	}
+	if err := s.redisClient.Del(ctx, keys...).Err(); err != nil {
+		return fmt.Errorf("failed to invalidate cache for %s: %w", symbol, err)
+	}
+
+	for _, period := range []int{5, 20, 60} {
+		if _, err := s.CalculateMA(ctx, symbol, period, "SMA", 1); err != nil {
+			return err
+		}
+	}
+	if _, err := s.CalculateRSI(ctx, symbol, 14, 1); err != nil {
+		return err
+	}
+	if _, err := s.CalculateMACD(ctx, symbol, 12, 26, 9, 1); err != nil {
+		return err
+	}
+	if _, err := s.CalculateBollingerBands(ctx, symbol, 20, 2.0, 1); err != nil {
+		return err
+	}
+	if _, err := s.CalculateKDJ(ctx, symbol, 9, 1); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// IndicatorSnapshot represents the standard indicator set for a symbol on a trading day
+type IndicatorSnapshot struct {
+	Symbol         string    `json:"symbol"`
+	SnapshotDate   time.Time `json:"snapshot_date"`
+	Close          float64   `json:"close"`
+	MA5            *float64  `json:"ma5,omitempty"`
+	MA20           *float64  `json:"ma20,omitempty"`
+	MA60           *float64  `json:"ma60,omitempty"`
+	RSI14          *float64  `json:"rsi14,omitempty"`
+	MACD           *float64  `json:"macd,omitempty"`
+	MACDSignal     *float64  `json:"macd_signal,omitempty"`
+	MACDHistogram  *float64  `json:"macd_histogram,omitempty"`
+	KDJK           *float64  `json:"kdj_k,omitempty"`
+	KDJD           *float64  `json:"kdj_d,omitempty"`
+	KDJJ           *float64  `json:"kdj_j,omitempty"`
+	BBUpper        *float64  `json:"bb_upper,omitempty"`
+	BBMiddle       *float64  `json:"bb_middle,omitempty"`
+	BBLower        *float64  `json:"bb_lower,omitempty"`
+	BBBandwidth    *float64  `json:"bb_bandwidth,omitempty"`
+	BBBandwidthPct *float64  `json:"bb_bandwidth_pct,omitempty"`
+	CalculatedAt   time.Time `json:"calculated_at"`
+}
+
+// SnapshotRefreshResult summarizes a snapshot refresh run
+type SnapshotRefreshResult struct {
+	TotalSymbols int       `json:"total_symbols"`
+	Saved        int       `json:"saved"`
+	Failed       int       `json:"failed"`
+	StartedAt    time.Time `json:"started_at"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+// ComputeSnapshot calculates the standard indicator set from the latest bars of a symbol
+func (s *TechnicalAnalysisService) ComputeSnapshot(ctx context.Context, symbol string) (*IndicatorSnapshot, error) {
+	// 250 bars is enough for MA60 and for MACD/RSI to stabilize
+	ohlcv, err := s.getOHLCVData(ctx, symbol, 250)
+	if err != nil {
+		return nil, err
+	}
+	if len(ohlcv) == 0 {
+		return nil, fmt.Errorf("no OHLCV data for %s", symbol)
+	}
+
+	return buildIndicatorSnapshot(symbol, ohlcv), nil
+}
+
+// SaveSnapshot upserts an indicator snapshot
+func (s *TechnicalAnalysisService) SaveSnapshot(ctx context.Context, snap *IndicatorSnapshot) error {
+	query := `
+		INSERT INTO indicator_snapshots (
+			symbol, snapshot_date, close, ma5, ma20, ma60, rsi14,
+			macd, macd_signal, macd_histogram, kdj_k, kdj_d, kdj_j,
+			bb_upper, bb_middle, bb_lower, bb_bandwidth, bb_bandwidth_pct, calculated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)
+		ON CONFLICT (symbol, snapshot_date)
+		DO UPDATE SET
+			close = EXCLUDED.close,
+			ma5 = EXCLUDED.ma5,
+			ma20 = EXCLUDED.ma20,
+			ma60 = EXCLUDED.ma60,
+			rsi14 = EXCLUDED.rsi14,
+			macd = EXCLUDED.macd,
+			macd_signal = EXCLUDED.macd_signal,
+			macd_histogram = EXCLUDED.macd_histogram,
+			kdj_k = EXCLUDED.kdj_k,
+			kdj_d = EXCLUDED.kdj_d,
+			kdj_j = EXCLUDED.kdj_j,
+			bb_upper = EXCLUDED.bb_upper,
+			bb_middle = EXCLUDED.bb_middle,
+			bb_lower = EXCLUDED.bb_lower,
+			bb_bandwidth = EXCLUDED.bb_bandwidth,
+			bb_bandwidth_pct = EXCLUDED.bb_bandwidth_pct,
+			calculated_at = EXCLUDED.calculated_at
+	`
+
+	_, err := s.db.ExecContext(ctx, query,
+		snap.Symbol, snap.SnapshotDate, snap.Close, snap.MA5, snap.MA20, snap.MA60, snap.RSI14,
+		snap.MACD, snap.MACDSignal, snap.MACDHistogram, snap.KDJK, snap.KDJD, snap.KDJJ,
+		snap.BBUpper, snap.BBMiddle, snap.BBLower, snap.BBBandwidth, snap.BBBandwidthPct, snap.CalculatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save snapshot for %s: %w", snap.Symbol, err)
+	}
+	return nil
+}
+
+// RefreshSnapshots recomputes snapshots for every symbol traded on the latest synced day.
+// Intended to run after a market data sync completes.
+func (s *TechnicalAnalysisService) RefreshSnapshots(ctx context.Context) (*SnapshotRefreshResult, error) {
+	result := &SnapshotRefreshResult{StartedAt: time.Now()}
+
+	query := `
+		SELECT DISTINCT symbol
+		FROM stock_ohlcv
+		WHERE timestamp >= (SELECT MAX(timestamp) FROM stock_ohlcv WHERE ` + notIndexSymbolSQL + `) - INTERVAL '1 day'
+		  AND ` + notIndexSymbolSQL + `
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list symbols: %w", err)
+	}
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+	rows.Close()
+
+	result.TotalSymbols = len(symbols)
+	for _, symbol := range symbols {
+		if ctx.Err() != nil {
+			break
+		}
+
+		snap, err := s.ComputeSnapshot(ctx, symbol)
+		if err != nil {
+			result.Failed++
+			continue
+		}
+		if err := s.SaveSnapshot(ctx, snap); err != nil {
+			result.Failed++
+			continue
+		}
+		result.Saved++
+	}
+
+	result.CompletedAt = time.Now()
+	return result, nil
+}
+
+// GetLatestSnapshot returns the most recent stored snapshot for a symbol
+func (s *TechnicalAnalysisService) GetLatestSnapshot(ctx context.Context, symbol string) (*IndicatorSnapshot, error) {
+	query := `
+		SELECT symbol, snapshot_date, close, ma5, ma20, ma60, rsi14,
+		       macd, macd_signal, macd_histogram, kdj_k, kdj_d, kdj_j,
+		       bb_upper, bb_middle, bb_lower, bb_bandwidth, bb_bandwidth_pct, calculated_at
+		FROM indicator_snapshots
+		WHERE symbol = $1
+		ORDER BY snapshot_date DESC
+		LIMIT 1
+	`
+
+	var snap IndicatorSnapshot
+	err := s.db.QueryRowContext(ctx, query, symbol).Scan(
+		&snap.Symbol, &snap.SnapshotDate, &snap.Close, &snap.MA5, &snap.MA20, &snap.MA60, &snap.RSI14,
+		&snap.MACD, &snap.MACDSignal, &snap.MACDHistogram, &snap.KDJK, &snap.KDJD, &snap.KDJJ,
+		&snap.BBUpper, &snap.BBMiddle, &snap.BBLower, &snap.BBBandwidth, &snap.BBBandwidthPct, &snap.CalculatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &snap, nil
+}
+
+// buildIndicatorSnapshot computes the snapshot values from chronological bars
+func buildIndicatorSnapshot(symbol string, ohlcv []OHLCV) *IndicatorSnapshot {
+	n := len(ohlcv)
+	highs := make([]float64, n)
+	lows := make([]float64, n)
+	closes := make([]float64, n)
+	for i, candle := range ohlcv {
+		highs[i], _ = candle.High.Float64()
+		lows[i], _ = candle.Low.Float64()
+		closes[i], _ = candle.Close.Float64()
+	}
+
+	last := ohlcv[n-1]
+	snap := &IndicatorSnapshot{
+		Symbol:       symbol,
+		SnapshotDate: last.Timestamp,
+		Close:        closes[n-1],
+		CalculatedAt: time.Now(),
+	}
+
+	if n >= 5 {
+		snap.MA5 = lastValue(talib.Sma(closes, 5))
+	}
+	if n >= 20 {
+		snap.MA20 = lastValue(talib.Sma(closes, 20))
+		upper, middle, lower := talib.BBands(closes, 20, 2.0, 2.0, talib.SMA)
+		snap.BBUpper = lastValue(upper)
+		snap.BBMiddle = lastValue(middle)
+		snap.BBLower = lastValue(lower)
+
+		bandwidth := bbBandwidthSeries(upper, middle, lower, 20)
+		snap.BBBandwidth = lastValue(bandwidth)
+		if pct := bandwidthPercentile(bandwidth, n-1, squeezeLookback); !isNaN(pct) {
+			snap.BBBandwidthPct = &pct
+		}
+	}
+	if n >= 60 {
+		snap.MA60 = lastValue(talib.Sma(closes, 60))
+	}
+	if n > 14 {
+		snap.RSI14 = lastValue(talib.Rsi(closes, 14))
+	}
+	if n >= 35 {
+		macd, signal, histogram := talib.Macd(closes, 12, 26, 9)
+		snap.MACD = lastValue(macd)
+		snap.MACDSignal = lastValue(signal)
+		snap.MACDHistogram = lastValue(histogram)
+	}
+	if n >= 15 {
+		k, d := talib.Stoch(highs, lows, closes, 9, 3, talib.SMA, 3, talib.SMA)
+		snap.KDJK = lastValue(k)
+		snap.KDJD = lastValue(d)
+		if snap.KDJK != nil && snap.KDJD != nil {
+			j := 3*(*snap.KDJK) - 2*(*snap.KDJD)
+			snap.KDJJ = &j
+		}
+	}
+
+	return snap
+}
+
+// Helper: Get the last value of an indicator series, nil if not computable
+func lastValue(values []float64) *float64 {
+	if len(values) == 0 {
+		return nil
+	}
+	v := values[len(values)-1]
+	if isNaN(v) {
+		return nil
+	}
+	return &v
+}
+
 // Helper: Get OHLCV data from database
 func (s *TechnicalAnalysisService) getOHLCVData(ctx context.Context, symbol string, limit int) ([]OHLCV, error) {
 	query := `
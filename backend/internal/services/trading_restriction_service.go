@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"psm-backend/internal/database"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Restrictions a symbol can trade under
+const (
+	RestrictionDisposition      = "disposition"        // 處置股票
+	RestrictionFullCashDelivery = "full_cash_delivery" // 全額交割股
+)
+
+// TradingRestrictionService stores disposition and full-cash-delivery periods
+type TradingRestrictionService struct {
+	db *database.DB
+}
+
+// TradingRestriction is one restricted trading period of a symbol
+type TradingRestriction struct {
+	Symbol      string    `json:"symbol"`
+	Restriction string    `json:"restriction"`
+	StartDate   string    `json:"start_date"`         // YYYY-MM-DD
+	EndDate     string    `json:"end_date,omitempty"` // Inclusive, empty while in effect
+	Reason      string    `json:"reason,omitempty"`
+	Source      string    `json:"source"` // TSE, OTC or manual
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TradingRestrictionRequest records a restriction by hand, e.g. a stock
+// changed to full-cash-delivery
+type TradingRestrictionRequest struct {
+	Symbol      string `json:"symbol"`
+	Restriction string `json:"restriction"`
+	StartDate   string `json:"start_date"`
+	EndDate     string `json:"end_date"`
+	Reason      string `json:"reason"`
+}
+
+// RestrictionFetchResult reports one fetch of a market's disposition announcements
+type RestrictionFetchResult struct {
+	Market  string `json:"market"`
+	Records int    `json:"records"`
+	Stored  int    `json:"stored"`
+	Error   string `json:"error,omitempty"`
+}
+
+func NewTradingRestrictionService(db *database.DB) *TradingRestrictionService {
+	return &TradingRestrictionService{db: db}
+}
+
+// FetchDispositions downloads the current TWSE and TPEx disposition
+// announcements (處置股票) and upserts their periods
+func (s *TradingRestrictionService) FetchDispositions(ctx context.Context) ([]RestrictionFetchResult, error) {
+	feeds := []struct {
+		market string
+		url    string
+	}{
+		{"TSE", "https://openapi.twse.com.tw/v1/announcement/punish"},
+		{"OTC", "https://www.tpex.org.tw/openapi/v1/tpex_disposal_information"},
+	}
+
+	results := make([]RestrictionFetchResult, 0, len(feeds))
+	failed := 0
+	for _, feed := range feeds {
+		result := RestrictionFetchResult{Market: feed.market}
+		body, err := fetchNewsBody(ctx, feed.url, map[string]string{"Accept": "application/json"})
+		if err == nil {
+			var records []map[string]interface{}
+			if err = json.Unmarshal(body, &records); err != nil {
+				err = fmt.Errorf("failed to parse response: %w", err)
+			} else {
+				result.Records = len(records)
+				result.Stored, err = s.storeDispositions(ctx, feed.market, records)
+			}
+		}
+		if err != nil {
+			result.Error = err.Error()
+			failed++
+		}
+		results = append(results, result)
+	}
+	if failed == len(feeds) {
+		return results, fmt.Errorf("failed to fetch dispositions: %s; %s", results[0].Error, results[1].Error)
+	}
+	return results, nil
+}
+
+// storeDispositions upserts announcement records. Field names differ between
+// TWSE and TPEx, so keys are normalised as for monthly revenue.
+func (s *TradingRestrictionService) storeDispositions(ctx context.Context, market string, records []map[string]interface{}) (int, error) {
+	query := `
+		INSERT INTO stock_trading_restrictions (symbol, restriction, start_date, end_date, reason, source)
+		VALUES ($1, 'disposition', $2, $3, NULLIF($4, ''), $5)
+		ON CONFLICT (symbol, restriction, start_date) DO UPDATE SET
+			end_date = EXCLUDED.end_date, reason = EXCLUDED.reason, source = EXCLUDED.source
+		WHERE (stock_trading_restrictions.end_date, COALESCE(stock_trading_restrictions.reason, ''))
+		      IS DISTINCT FROM (EXCLUDED.end_date, COALESCE(EXCLUDED.reason, ''))
+	`
+
+	stored := 0
+	for _, raw := range records {
+		rec := make(map[string]string, len(raw))
+		for k, v := range raw {
+			rec[strings.ReplaceAll(strings.TrimSpace(k), " ", "")] = strings.TrimSpace(fmt.Sprint(v))
+		}
+
+		symbol := firstField(rec, "Code", "SecuritiesCompanyCode", "證券代號")
+		start, end, ok := parseDispositionPeriod(firstField(rec, "DispositionPeriod", "DisposePeriod", "處置起迄時間"))
+		if symbol == "" || !ok {
+			continue
+		}
+		reason := firstField(rec, "ReasonsOfDisposition", "DispositionReasons", "DisposeCondition", "處置條件")
+
+		res, err := s.db.ExecContext(ctx, query, symbol, start, end, reason, market)
+		if err != nil {
+			return stored, fmt.Errorf("failed to store disposition for %s: %w", symbol, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			stored++
+		}
+	}
+	return stored, nil
+}
+
+// parseDispositionPeriod parses an ROC date range such as "113/01/03～113/01/16"
+func parseDispositionPeriod(s string) (start, end time.Time, ok bool) {
+	s = strings.NewReplacer("～", "~", "－", "~", " ", "").Replace(s)
+	parts := strings.Split(s, "~")
+	if len(parts) != 2 {
+		return start, end, false
+	}
+	start, err := parseMOPSTime(parts[0], "")
+	if err != nil {
+		return start, end, false
+	}
+	end, err = parseMOPSTime(parts[1], "")
+	if err != nil || end.Before(start) {
+		return start, end, false
+	}
+	return start, end, true
+}
+
+// ListRestrictions returns restrictions in effect on date (YYYY-MM-DD, default
+// today), optionally for one symbol
+func (s *TradingRestrictionService) ListRestrictions(ctx context.Context, symbol, date string) ([]TradingRestriction, error) {
+	if date == "" {
+		date = time.Now().In(time.FixedZone("Asia/Taipei", 8*3600)).Format("2006-01-02")
+	} else if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("invalid date, expected YYYY-MM-DD")
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT symbol, restriction, TO_CHAR(start_date, 'YYYY-MM-DD'), COALESCE(TO_CHAR(end_date, 'YYYY-MM-DD'), ''),
+		       COALESCE(reason, ''), source, updated_at
+		FROM stock_trading_restrictions
+		WHERE start_date <= $1::date AND (end_date IS NULL OR end_date >= $1::date)
+		  AND ($2 = '' OR symbol = $2)
+		ORDER BY symbol, restriction
+	`, date, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trading restrictions: %w", err)
+	}
+	defer rows.Close()
+
+	restrictions := []TradingRestriction{}
+	for rows.Next() {
+		var r TradingRestriction
+		if err := rows.Scan(&r.Symbol, &r.Restriction, &r.StartDate, &r.EndDate, &r.Reason, &r.Source, &r.UpdatedAt); err != nil {
+			continue
+		}
+		restrictions = append(restrictions, r)
+	}
+	return restrictions, nil
+}
+
+// SetRestriction records or updates a restriction by hand
+func (s *TradingRestrictionService) SetRestriction(ctx context.Context, req *TradingRestrictionRequest) (*TradingRestriction, error) {
+	req.Symbol = strings.ToUpper(strings.TrimSpace(req.Symbol))
+	if req.Symbol == "" {
+		return nil, fmt.Errorf("symbol is required")
+	}
+	if req.Restriction != RestrictionDisposition && req.Restriction != RestrictionFullCashDelivery {
+		return nil, fmt.Errorf("restriction must be disposition or full_cash_delivery")
+	}
+	start, err := time.Parse("2006-01-02", req.StartDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start_date, expected YYYY-MM-DD")
+	}
+	var end sql.NullTime
+	if req.EndDate != "" {
+		t, err := time.Parse("2006-01-02", req.EndDate)
+		if err != nil || t.Before(start) {
+			return nil, fmt.Errorf("invalid end_date, expected YYYY-MM-DD on or after start_date")
+		}
+		end = sql.NullTime{Time: t, Valid: true}
+	}
+
+	var r TradingRestriction
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO stock_trading_restrictions (symbol, restriction, start_date, end_date, reason, source)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), 'manual')
+		ON CONFLICT (symbol, restriction, start_date) DO UPDATE SET
+			end_date = EXCLUDED.end_date, reason = EXCLUDED.reason, source = EXCLUDED.source
+		RETURNING symbol, restriction, TO_CHAR(start_date, 'YYYY-MM-DD'), COALESCE(TO_CHAR(end_date, 'YYYY-MM-DD'), ''),
+		          COALESCE(reason, ''), source, updated_at
+	`, req.Symbol, req.Restriction, start, end, strings.TrimSpace(req.Reason)).Scan(
+		&r.Symbol, &r.Restriction, &r.StartDate, &r.EndDate, &r.Reason, &r.Source, &r.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save trading restriction: %w", err)
+	}
+	return &r, nil
+}
+
+// DeleteRestriction removes a restriction period
+func (s *TradingRestrictionService) DeleteRestriction(ctx context.Context, symbol, restriction, startDate string) error {
+	if _, err := time.Parse("2006-01-02", startDate); err != nil {
+		return fmt.Errorf("invalid start_date, expected YYYY-MM-DD")
+	}
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM stock_trading_restrictions WHERE symbol = $1 AND restriction = $2 AND start_date = $3::date
+	`, strings.ToUpper(symbol), restriction, startDate)
+	if err != nil {
+		return fmt.Errorf("failed to delete trading restriction: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("trading restriction not found")
+	}
+	return nil
+}
+
+// RestrictionFetchWorker fetches the disposition announcements periodically;
+// they are published after the close and take effect the next trading day
+type RestrictionFetchWorker struct {
+	restrictionService *TradingRestrictionService
+	interval           time.Duration
+	mu                 sync.Mutex
+	isRunning          bool
+	stopChan           chan struct{}
+}
+
+// NewRestrictionFetchWorker reads RESTRICTION_FETCH_INTERVAL (hours, default 3)
+func NewRestrictionFetchWorker(restrictionService *TradingRestrictionService) *RestrictionFetchWorker {
+	interval := 3
+	if v, err := strconv.Atoi(os.Getenv("RESTRICTION_FETCH_INTERVAL")); err == nil && v > 0 {
+		interval = v
+	}
+	return &RestrictionFetchWorker{
+		restrictionService: restrictionService,
+		interval:           time.Duration(interval) * time.Hour,
+		stopChan:           make(chan struct{}),
+	}
+}
+
+// Start launches the fetch loop
+func (w *RestrictionFetchWorker) Start() {
+	w.mu.Lock()
+	if w.isRunning {
+		w.mu.Unlock()
+		return
+	}
+	w.isRunning = true
+	w.stopChan = make(chan struct{})
+	w.mu.Unlock()
+
+	go w.loop()
+	log.Printf("Restriction fetch worker started (every %v)", w.interval)
+}
+
+// Stop stops the fetch loop
+func (w *RestrictionFetchWorker) Stop() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.isRunning {
+		return
+	}
+	w.isRunning = false
+	close(w.stopChan)
+}
+
+func (w *RestrictionFetchWorker) loop() {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.run()
+	for {
+		select {
+		case <-w.stopChan:
+			return
+		case <-ticker.C:
+			w.run()
+		}
+	}
+}
+
+func (w *RestrictionFetchWorker) run() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	results, err := w.restrictionService.FetchDispositions(ctx)
+	if err != nil {
+		log.Printf("Restriction fetch worker: %v", err)
+	}
+	for _, r := range results {
+		if r.Stored > 0 {
+			log.Printf("Restriction fetch worker: %s stored %d of %d dispositions", r.Market, r.Stored, r.Records)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"psm-backend/internal/database"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// WatchlistService manages user watchlists and the set of tracked symbols
+type WatchlistService struct {
+	db *database.DB
+}
+
+func NewWatchlistService(db *database.DB) *WatchlistService {
+	return &WatchlistService{db: db}
+}
+
+// Watchlist is a named list of symbols
+type Watchlist struct {
+	ID        string    `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+	Symbols   []string  `json:"symbols"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ListWatchlists returns a user's watchlists with their symbols
+func (s *WatchlistService) ListWatchlists(ctx context.Context, userID uuid.UUID) ([]Watchlist, error) {
+	query := `
+		SELECT w.id, w.user_id, w.name, w.created_at, w.updated_at,
+		       COALESCE(array_agg(i.symbol ORDER BY i.added_at) FILTER (WHERE i.symbol IS NOT NULL), '{}')
+		FROM watchlists w
+		LEFT JOIN watchlist_items i ON i.watchlist_id = w.id
+		WHERE w.user_id = $1
+		GROUP BY w.id
+		ORDER BY w.created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlists: %w", err)
+	}
+	defer rows.Close()
+
+	watchlists := []Watchlist{}
+	for rows.Next() {
+		var w Watchlist
+		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.CreatedAt, &w.UpdatedAt, pq.Array(&w.Symbols)); err != nil {
+			return nil, fmt.Errorf("failed to scan watchlist: %w", err)
+		}
+		watchlists = append(watchlists, w)
+	}
+
+	return watchlists, nil
+}
+
+// GetWatchlist returns a single watchlist with its symbols
+func (s *WatchlistService) GetWatchlist(ctx context.Context, id string) (*Watchlist, error) {
+	query := `
+		SELECT w.id, w.user_id, w.name, w.created_at, w.updated_at,
+		       COALESCE(array_agg(i.symbol ORDER BY i.added_at) FILTER (WHERE i.symbol IS NOT NULL), '{}')
+		FROM watchlists w
+		LEFT JOIN watchlist_items i ON i.watchlist_id = w.id
+		WHERE w.id = $1
+		GROUP BY w.id
+	`
+
+	var w Watchlist
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&w.ID, &w.UserID, &w.Name, &w.CreatedAt, &w.UpdatedAt, pq.Array(&w.Symbols))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("watchlist not found")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query watchlist: %w", err)
+	}
+
+	return &w, nil
+}
+
+// CreateWatchlist creates a watchlist, optionally seeded with symbols
+func (s *WatchlistService) CreateWatchlist(ctx context.Context, userID uuid.UUID, name string, symbols []string) (*Watchlist, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	var id string
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO watchlists (user_id, name) VALUES ($1, $2) RETURNING id
+	`, userID, name).Scan(&id)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key") {
+			return nil, fmt.Errorf("watchlist already exists: %s", name)
+		}
+		return nil, fmt.Errorf("failed to create watchlist: %w", err)
+	}
+
+	if len(symbols) > 0 {
+		if err := s.AddSymbols(ctx, id, symbols); err != nil {
+			return nil, err
+		}
+	}
+
+	return s.GetWatchlist(ctx, id)
+}
+
+// DeleteWatchlist removes a watchlist and its items
+func (s *WatchlistService) DeleteWatchlist(ctx context.Context, id string) error {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM watchlists WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete watchlist: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("watchlist not found")
+	}
+	return nil
+}
+
+// AddSymbols adds symbols to a watchlist, ignoring ones already present
+func (s *WatchlistService) AddSymbols(ctx context.Context, id string, symbols []string) error {
+	for _, symbol := range symbols {
+		symbol = strings.ToUpper(strings.TrimSpace(symbol))
+		if symbol == "" {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, `
+			INSERT INTO watchlist_items (watchlist_id, symbol) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING
+		`, id, symbol); err != nil {
+			if strings.Contains(err.Error(), "foreign key") {
+				return fmt.Errorf("watchlist not found")
+			}
+			return fmt.Errorf("failed to add symbol: %w", err)
+		}
+	}
+	s.db.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, id)
+	return nil
+}
+
+// RemoveSymbol removes a symbol from a watchlist
+func (s *WatchlistService) RemoveSymbol(ctx context.Context, id, symbol string) error {
+	result, err := s.db.ExecContext(ctx, `
+		DELETE FROM watchlist_items WHERE watchlist_id = $1 AND symbol = $2
+	`, id, strings.ToUpper(symbol))
+	if err != nil {
+		return fmt.Errorf("failed to remove symbol: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("symbol not found in watchlist")
+	}
+	s.db.ExecContext(ctx, `UPDATE watchlists SET updated_at = NOW() WHERE id = $1`, id)
+	return nil
+}
+
+// TrackedSymbols returns every symbol held in a portfolio or listed in a watchlist.
+// Ledger symbols ('2330.TW') are returned without their market suffix.
+func (s *WatchlistService) TrackedSymbols(ctx context.Context) ([]string, error) {
+	query := `
+		SELECT split_part(symbol, '.', 1) FROM positions_current WHERE total_quantity > 0
+		UNION
+		SELECT symbol FROM watchlist_items
+		ORDER BY 1
+	`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracked symbols: %w", err)
+	}
+	defer rows.Close()
+
+	var symbols []string
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols, nil
+}
+
+// UserTrackedSymbols returns the symbols a user holds in a real (non-paper) portfolio
+// and/or lists in a watchlist. scope is "portfolio", "watchlist" or "all".
+func (s *WatchlistService) UserTrackedSymbols(ctx context.Context, userID uuid.UUID, scope string) ([]string, error) {
+	query := `
+		SELECT split_part(pc.symbol, '.', 1)
+		FROM positions_current pc
+		JOIN portfolios p ON p.id = pc.portfolio_id
+		WHERE $2 IN ('all', 'portfolio')
+		  AND p.user_id = $1 AND COALESCE(p.is_paper, false) = false AND pc.total_quantity > 0
+		UNION
+		SELECT i.symbol
+		FROM watchlist_items i
+		JOIN watchlists w ON w.id = i.watchlist_id
+		WHERE $2 IN ('all', 'watchlist') AND w.user_id = $1
+		ORDER BY 1
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, userID, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracked symbols: %w", err)
+	}
+	defer rows.Close()
+
+	symbols := []string{}
+	for rows.Next() {
+		var symbol string
+		if err := rows.Scan(&symbol); err == nil {
+			symbols = append(symbols, symbol)
+		}
+	}
+
+	return symbols, nil
+}
@@ -0,0 +1,44 @@
+-- ============================================================================
+-- Migration 005: Daily Indicator Snapshots
+-- Persists the standard indicator set per symbol per trading day so that
+-- the screener can filter on RSI/MACD without recomputing from raw bars.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS indicator_snapshots (
+    symbol VARCHAR(10) NOT NULL,
+    snapshot_date DATE NOT NULL,           -- Trading day of the last bar used
+    close NUMERIC(12, 2) NOT NULL,
+
+    -- Moving averages
+    ma5 NUMERIC(12, 4),
+    ma20 NUMERIC(12, 4),
+    ma60 NUMERIC(12, 4),
+
+    -- Oscillators
+    rsi14 NUMERIC(8, 4),
+    macd NUMERIC(12, 4),
+    macd_signal NUMERIC(12, 4),
+    macd_histogram NUMERIC(12, 4),
+    kdj_k NUMERIC(8, 4),
+    kdj_d NUMERIC(8, 4),
+    kdj_j NUMERIC(8, 4),
+
+    -- Bollinger Bands (20, 2)
+    bb_upper NUMERIC(12, 4),
+    bb_middle NUMERIC(12, 4),
+    bb_lower NUMERIC(12, 4),
+
+    calculated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT pk_indicator_snapshots PRIMARY KEY (symbol, snapshot_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_indicator_snapshots_date
+    ON indicator_snapshots (snapshot_date DESC);
+
+CREATE INDEX IF NOT EXISTS idx_indicator_snapshots_rsi
+    ON indicator_snapshots (snapshot_date, rsi14);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON indicator_snapshots TO psm_user;
+
+COMMENT ON TABLE indicator_snapshots IS 'Daily per-symbol snapshot of standard technical indicators, written after market data sync';
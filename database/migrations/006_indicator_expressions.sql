@@ -0,0 +1,22 @@
+-- ============================================================================
+-- Migration 006: Saved Indicator Expressions
+-- User-defined formulas such as "close > MA(20) AND RSI(14) < 40" that can be
+-- evaluated as an indicator series or used as a screener criterion.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS indicator_expressions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL UNIQUE,
+    expression TEXT NOT NULL,
+    description TEXT,
+    is_boolean BOOLEAN NOT NULL DEFAULT FALSE,  -- Condition (true/false) vs numeric value
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_indicator_expressions_name
+    ON indicator_expressions (name);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON indicator_expressions TO psm_user;
+
+COMMENT ON TABLE indicator_expressions IS 'Saved custom indicator expressions usable by the indicator endpoints and the screener';
@@ -0,0 +1,14 @@
+-- ============================================================================
+-- Migration 007: Bollinger Band Squeeze
+-- Adds band width and its percentile rank over the lookback window to the
+-- daily indicator snapshot so the screener can find volatility squeezes.
+-- ============================================================================
+
+ALTER TABLE indicator_snapshots
+    ADD COLUMN IF NOT EXISTS bb_bandwidth NUMERIC(10, 4),      -- (upper - lower) / middle * 100
+    ADD COLUMN IF NOT EXISTS bb_bandwidth_pct NUMERIC(6, 2);   -- Percentile rank of bandwidth over 120 bars (0-100)
+
+CREATE INDEX IF NOT EXISTS idx_indicator_snapshots_bandwidth_pct
+    ON indicator_snapshots (snapshot_date, bb_bandwidth_pct);
+
+COMMENT ON COLUMN indicator_snapshots.bb_bandwidth_pct IS 'Percentile rank of the current Bollinger band width within the last 120 bars; low values indicate a squeeze';
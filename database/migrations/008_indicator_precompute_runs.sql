@@ -0,0 +1,21 @@
+-- ============================================================================
+-- Migration 008: Indicator Precompute Runs
+-- Tracks the nightly indicator precomputation so an interrupted run can be
+-- resumed from the last processed symbol instead of starting over.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS indicator_precompute_runs (
+    run_date DATE PRIMARY KEY,                    -- Trading day the indicators were computed for
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- running, completed, interrupted
+    total_symbols INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    failed INTEGER NOT NULL DEFAULT 0,
+    last_symbol VARCHAR(10),                      -- Resume cursor (symbols are processed in order)
+    started_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    completed_at TIMESTAMPTZ
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON indicator_precompute_runs TO psm_user;
+
+COMMENT ON TABLE indicator_precompute_runs IS 'Progress of the nightly indicator cache/snapshot precomputation, one row per trading day';
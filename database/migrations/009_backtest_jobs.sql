@@ -0,0 +1,24 @@
+-- ============================================================================
+-- Migration 009: Strategy Backtest Jobs
+-- Backtests run asynchronously; each submission is tracked here together
+-- with its request (rules, costs) and the resulting statistics.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS backtest_jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    symbol VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'queued',  -- queued, running, completed, failed
+    request JSONB NOT NULL,                        -- Entry/exit rules, date range, cost model
+    result JSONB,                                  -- Statistics, trades, equity curve
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_backtest_jobs_created ON backtest_jobs (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_backtest_jobs_symbol ON backtest_jobs (symbol, created_at DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON backtest_jobs TO psm_user;
+
+COMMENT ON TABLE backtest_jobs IS 'Asynchronous rule-based strategy backtests and their results';
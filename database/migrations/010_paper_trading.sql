@@ -0,0 +1,42 @@
+-- ============================================================================
+-- Migration 010: Paper Trading
+-- Simulated portfolios reuse the ledger: filled virtual orders are recorded
+-- as ledger events (source = 'paper'), so positions_current and the P&L
+-- functions work unchanged. Cash is derived from initial_cash and the ledger.
+-- ============================================================================
+
+ALTER TABLE portfolios ADD COLUMN IF NOT EXISTS is_paper BOOLEAN DEFAULT FALSE;
+ALTER TABLE portfolios ADD COLUMN IF NOT EXISTS initial_cash DECIMAL(15, 2);
+
+CREATE TABLE IF NOT EXISTS paper_orders (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,                  -- Stock code without suffix (e.g., '2330')
+    side VARCHAR(4) NOT NULL,                     -- BUY, SELL
+    order_type VARCHAR(10) NOT NULL,              -- market, limit
+    quantity BIGINT NOT NULL,                     -- Shares
+    limit_price DECIMAL(12, 2),
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- pending, filled, rejected, cancelled, expired
+    fill_price DECIMAL(12, 2),
+    fee DECIMAL(10, 2),
+    tax DECIMAL(10, 2),
+    ledger_event_id UUID REFERENCES ledger_events(event_id),
+    reason TEXT,                                  -- Rejection/expiry reason
+    expires_at TIMESTAMPTZ NOT NULL,              -- Day orders expire at the close of their session
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+
+    CONSTRAINT valid_paper_side CHECK (side IN ('BUY', 'SELL')),
+    CONSTRAINT valid_paper_order_type CHECK (order_type IN ('market', 'limit')),
+    CONSTRAINT positive_paper_quantity CHECK (quantity > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_paper_orders_portfolio ON paper_orders (portfolio_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_paper_orders_pending ON paper_orders (status) WHERE status = 'pending';
+
+CREATE TRIGGER update_paper_orders_updated_at BEFORE UPDATE ON paper_orders
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON paper_orders TO psm_user;
+
+COMMENT ON TABLE paper_orders IS 'Virtual orders for paper trading portfolios, filled against realtime quotes';
@@ -0,0 +1,43 @@
+-- ============================================================================
+-- Migration 011: Strategy Definitions
+-- Named strategies (rules, parameters, target universe) per user. Every change
+-- to a definition creates a new immutable version, and backtests record the
+-- version they ran so old results remain reproducible.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS strategies (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    current_version INTEGER NOT NULL DEFAULT 1,
+    is_archived BOOLEAN DEFAULT FALSE,            -- Deleted strategies are archived, keeping their versions
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_strategies_user_name ON strategies (user_id, name) WHERE is_archived = FALSE;
+
+CREATE TABLE IF NOT EXISTS strategy_versions (
+    strategy_id UUID NOT NULL REFERENCES strategies(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    definition JSONB NOT NULL,                    -- Entry/exit rules, risk parameters, universe
+    change_note TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (strategy_id, version)
+);
+
+CREATE TRIGGER update_strategies_updated_at BEFORE UPDATE ON strategies
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Link backtests to the exact strategy version they ran
+ALTER TABLE backtest_jobs ADD COLUMN IF NOT EXISTS strategy_id UUID REFERENCES strategies(id) ON DELETE SET NULL;
+ALTER TABLE backtest_jobs ADD COLUMN IF NOT EXISTS strategy_version INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_backtest_jobs_strategy ON backtest_jobs (strategy_id, created_at DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON strategies TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON strategy_versions TO psm_user;
+
+COMMENT ON TABLE strategies IS 'Named trading strategies per user';
+COMMENT ON TABLE strategy_versions IS 'Immutable strategy definitions, one row per revision';
@@ -0,0 +1,32 @@
+-- ============================================================================
+-- Migration 012: Watchlists
+-- Named lists of symbols per user. Together with portfolio holdings they
+-- define the set of tracked symbols that background workers keep fresh.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS watchlists (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS watchlist_items (
+    watchlist_id UUID NOT NULL REFERENCES watchlists(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,                  -- Stock code without suffix (e.g., '2330')
+    added_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (watchlist_id, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_watchlist_items_symbol ON watchlist_items (symbol);
+
+CREATE TRIGGER update_watchlists_updated_at BEFORE UPDATE ON watchlists
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON watchlists TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON watchlist_items TO psm_user;
+
+COMMENT ON TABLE watchlists IS 'User-defined lists of symbols to follow';
+COMMENT ON TABLE watchlist_items IS 'Symbols in each watchlist';
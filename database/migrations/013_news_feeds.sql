@@ -0,0 +1,30 @@
+-- ============================================================================
+-- Migration 013: Registered RSS/Atom Feeds
+-- Admin-registered feeds (company IR feeds, newspaper sections, ...) are
+-- ingested into stock_news alongside the built-in news sources. Articles
+-- from all feeds share source = 'rss', so the same link published by two
+-- feeds is stored once.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS news_feeds (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    url TEXT NOT NULL UNIQUE,
+    category VARCHAR(50),                         -- Stored as stock_news.category
+    default_symbol VARCHAR(10),                   -- For single-company feeds (e.g., IR news)
+    is_active BOOLEAN DEFAULT TRUE,
+    last_fetched_at TIMESTAMPTZ,
+    last_status VARCHAR(20),                      -- success, failed
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_news_feeds_active ON news_feeds (is_active);
+
+CREATE TRIGGER update_news_feeds_updated_at BEFORE UPDATE ON news_feeds
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON news_feeds TO psm_user;
+
+COMMENT ON TABLE news_feeds IS 'Admin-registered RSS/Atom feeds ingested into stock_news';
@@ -0,0 +1,42 @@
+-- ============================================================================
+-- Migration 014: Cross-source News Deduplication
+-- The same story published by several outlets is stored once in stock_news
+-- (the canonical article); later copies are recorded in news_duplicates and
+-- linked to it. Matching uses a normalized title hash first, then pg_trgm
+-- title similarity within a few days of publication.
+-- ============================================================================
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+-- Lowercase and strip whitespace and ASCII/CJK punctuation so cosmetic
+-- differences between outlets' headlines do not defeat the hash
+CREATE OR REPLACE FUNCTION normalize_news_title(title TEXT)
+RETURNS TEXT AS $$
+    SELECT regexp_replace(lower(coalesce(title, '')),
+        '[[:space:][:punct:]【】「」『』《》〈〉（）［］｛｝！？，。：；、．…—－～·’‘”“]', '', 'g');
+$$ LANGUAGE SQL IMMUTABLE;
+
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS title_hash TEXT
+    GENERATED ALWAYS AS (md5(normalize_news_title(title))) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_stock_news_title_hash ON stock_news (title_hash, published_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_news_title_trgm ON stock_news USING gin (title gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS news_duplicates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    canonical_id UUID NOT NULL REFERENCES stock_news(id) ON DELETE CASCADE,
+    source VARCHAR(50) NOT NULL,
+    source_url TEXT NOT NULL,
+    title TEXT NOT NULL,
+    published_at TIMESTAMPTZ NOT NULL,
+    match_type VARCHAR(20) NOT NULL,              -- title_hash, similarity
+    similarity DECIMAL(5, 4),
+    detected_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (source, source_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_news_duplicates_canonical ON news_duplicates (canonical_id);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON news_duplicates TO psm_user;
+
+COMMENT ON TABLE news_duplicates IS 'Copies of a story from other outlets, linked to the canonical stock_news row';
@@ -0,0 +1,33 @@
+-- ============================================================================
+-- Migration 015: Article-Symbol Tagging
+-- An article can mention several stocks; article_symbols links it to every
+-- ticker it mentions so per-symbol news includes multi-stock stories.
+-- stock_news.symbol remains the article's primary symbol.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS article_symbols (
+    article_id UUID NOT NULL REFERENCES stock_news(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,
+    match_type VARCHAR(20) NOT NULL,              -- primary, code, name
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (article_id, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_symbols_symbol ON article_symbols (symbol, article_id);
+
+-- Backfill: the existing primary symbol...
+INSERT INTO article_symbols (article_id, symbol, match_type)
+SELECT id, symbol, 'primary' FROM stock_news WHERE symbol <> ''
+ON CONFLICT DO NOTHING;
+
+-- ...plus every listed stock code mentioned in the title or body
+INSERT INTO article_symbols (article_id, symbol, match_type)
+SELECT DISTINCT n.id, t.symbol, 'code'
+FROM stock_news n
+CROSS JOIN LATERAL regexp_matches(n.title || ' ' || COALESCE(n.content, ''), '(?<![0-9])([0-9]{4})(?![0-9])', 'g') AS m(code)
+JOIN taiwan_stocks t ON t.symbol = m.code[1]
+ON CONFLICT DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON article_symbols TO psm_user;
+
+COMMENT ON TABLE article_symbols IS 'Every stock symbol an article mentions (many-to-many with stock_news)';
@@ -0,0 +1,32 @@
+-- ============================================================================
+-- Migration 016: News Retention
+-- Articles past the retention window are deleted by the retention job, or
+-- moved here first when archiving is enabled. The tagged symbols are kept
+-- as an array since article_symbols rows are removed with the article.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS stock_news_archive (
+    id UUID PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    title TEXT NOT NULL,
+    summary TEXT,
+    content TEXT,
+    source VARCHAR(50) NOT NULL,
+    source_url TEXT,
+    published_at TIMESTAMPTZ NOT NULL,
+    fetched_at TIMESTAMPTZ,
+    sentiment VARCHAR(20),
+    sentiment_score DECIMAL(5,4),
+    sentiment_analyzed_at TIMESTAMPTZ,
+    category VARCHAR(50),
+    tags TEXT[],
+    symbols TEXT[],
+    archived_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_news_archive_published ON stock_news_archive (published_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_news_archive_symbols ON stock_news_archive USING gin (symbols);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON stock_news_archive TO psm_user;
+
+COMMENT ON TABLE stock_news_archive IS 'Cold storage for news articles past the retention window';
@@ -0,0 +1,13 @@
+-- ============================================================================
+-- Migration 017: Full Article Content
+-- Tracks which truncated articles had their full text fetched from the
+-- article page, so each is attempted once.
+-- ============================================================================
+
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS content_fetched_at TIMESTAMPTZ;
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS content_fetch_status VARCHAR(20);  -- fetched, failed, blocked
+
+CREATE INDEX IF NOT EXISTS idx_stock_news_content_pending ON stock_news (published_at DESC)
+    WHERE content_fetch_status IS NULL;
+
+COMMENT ON COLUMN stock_news.content_fetch_status IS 'Full-content fetch outcome: fetched, failed, or blocked (host not allowed / robots.txt)';
@@ -0,0 +1,33 @@
+-- ============================================================================
+-- Migration 018: News Webhooks
+-- Users register URLs that receive a POST for every new article tagged with
+-- a symbol they hold or watch. Each webhook keeps its own fetched_at
+-- watermark so deliveries resume where they stopped.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS news_webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT,                                  -- HMAC-SHA256 signing key (optional)
+    scope VARCHAR(20) NOT NULL DEFAULT 'all',     -- all, portfolio, watchlist
+    min_abs_sentiment DECIMAL(5,4),               -- Only deliver articles with |score| >= this
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    last_article_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_delivery_at TIMESTAMPTZ,
+    last_status VARCHAR(20),                      -- success, failed
+    last_error TEXT,
+    consecutive_failures INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_news_webhooks_active ON news_webhooks (is_active) WHERE is_active = true;
+
+CREATE TRIGGER update_news_webhooks_updated_at BEFORE UPDATE ON news_webhooks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON news_webhooks TO psm_user;
+
+COMMENT ON TABLE news_webhooks IS 'Per-user webhook URLs notified of new articles for held/watched symbols';
@@ -0,0 +1,18 @@
+-- ============================================================================
+-- Migration 019: News Language
+-- Tags each article with its language (zh-TW, en) so English coverage of
+-- Taiwan stocks can be ingested alongside Chinese sources and filtered.
+-- ============================================================================
+
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS language VARCHAR(10);
+ALTER TABLE stock_news_archive ADD COLUMN IF NOT EXISTS language VARCHAR(10);
+
+-- Backfill: every source before this migration was Chinese, but user feeds may
+-- not be, so fall back to English only for titles without any CJK characters
+UPDATE stock_news
+SET language = CASE WHEN title ~ '[一-鿿]' THEN 'zh-TW' ELSE 'en' END
+WHERE language IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_stock_news_language ON stock_news (language, published_at DESC);
+
+COMMENT ON COLUMN stock_news.language IS 'Article language detected on ingest: zh-TW or en';
@@ -0,0 +1,16 @@
+-- ============================================================================
+-- Migration 020: Sentiment Method
+-- Records how each article's sentiment was produced (keyword dictionary or
+-- an LLM provider) and how confident the analysis was.
+-- ============================================================================
+
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS sentiment_method VARCHAR(20);         -- keyword, gemini, openai
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS sentiment_confidence DECIMAL(5,4);   -- 0.0 to 1.0
+ALTER TABLE stock_news_archive ADD COLUMN IF NOT EXISTS sentiment_method VARCHAR(20);
+ALTER TABLE stock_news_archive ADD COLUMN IF NOT EXISTS sentiment_confidence DECIMAL(5,4);
+
+-- Everything analysed so far came from the keyword dictionary
+UPDATE stock_news SET sentiment_method = 'keyword'
+WHERE sentiment IS NOT NULL AND sentiment_method IS NULL;
+
+COMMENT ON COLUMN stock_news.sentiment_method IS 'Sentiment analysis method: keyword, gemini, or openai';
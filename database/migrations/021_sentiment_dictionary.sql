@@ -0,0 +1,51 @@
+-- ============================================================================
+-- Migration 021: Editable Sentiment Dictionary
+-- The keyword sentiment dictionary (positive / negative terms and intensity
+-- modifiers) lives here so terms can be edited without a redeploy. Every
+-- change bumps a version number; analysed articles record the version used.
+-- The backend seeds the table from its built-in lists when it is empty.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS sentiment_keywords (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    term VARCHAR(50) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('positive', 'negative', 'intensifier')),
+    weight DECIMAL(6,3) NOT NULL DEFAULT 1.0,   -- Term weight; multiplier for intensifiers
+    is_active BOOLEAN DEFAULT TRUE,
+    note TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (term, kind)
+);
+
+CREATE TRIGGER update_sentiment_keywords_updated_at BEFORE UPDATE ON sentiment_keywords
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+-- Single-row version counter, bumped by any change to sentiment_keywords
+CREATE TABLE IF NOT EXISTS sentiment_dictionary_version (
+    id INTEGER PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    version INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO sentiment_dictionary_version (id, version) VALUES (1, 0) ON CONFLICT (id) DO NOTHING;
+
+CREATE OR REPLACE FUNCTION bump_sentiment_dictionary_version()
+RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE sentiment_dictionary_version SET version = version + 1, updated_at = NOW() WHERE id = 1;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER bump_sentiment_dictionary_version AFTER INSERT OR UPDATE OR DELETE ON sentiment_keywords
+    FOR EACH STATEMENT EXECUTE FUNCTION bump_sentiment_dictionary_version();
+
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS sentiment_dictionary_version INTEGER;
+ALTER TABLE stock_news_archive ADD COLUMN IF NOT EXISTS sentiment_dictionary_version INTEGER;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON sentiment_keywords TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON sentiment_dictionary_version TO psm_user;
+
+COMMENT ON TABLE sentiment_keywords IS 'Editable keyword sentiment dictionary (positive/negative terms, intensifiers)';
+COMMENT ON COLUMN stock_news.sentiment_dictionary_version IS 'sentiment_dictionary_version.version used by the keyword analysis';
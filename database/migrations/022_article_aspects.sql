@@ -0,0 +1,23 @@
+-- ============================================================================
+-- Migration 022: Aspect-Based Sentiment
+-- Per-article sentiment for specific aspects (revenue, orders, lawsuits,
+-- management, supply chain), extracted from the sentences that mention
+-- each aspect. Replaced whenever the article is re-analysed.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS article_aspect_sentiment (
+    article_id UUID NOT NULL REFERENCES stock_news(id) ON DELETE CASCADE,
+    aspect VARCHAR(30) NOT NULL,        -- revenue, orders, lawsuits, management, supply_chain
+    sentiment VARCHAR(20) NOT NULL,     -- positive, negative, neutral
+    score DECIMAL(5,4) NOT NULL,        -- -1.0 to 1.0
+    mentions INTEGER NOT NULL DEFAULT 1,
+    snippet TEXT,                       -- Strongest sentence for this aspect
+    analyzed_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (article_id, aspect)
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_aspect_sentiment_aspect ON article_aspect_sentiment (aspect, sentiment);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON article_aspect_sentiment TO psm_user;
+
+COMMENT ON TABLE article_aspect_sentiment IS 'Per-aspect sentiment extracted from each analysed article';
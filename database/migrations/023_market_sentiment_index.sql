@@ -0,0 +1,26 @@
+-- ============================================================================
+-- Migration 023: Market Sentiment Index
+-- Daily market-wide sentiment computed from all analysed articles, stored as
+-- a series for overlaying on the TAIEX chart. Two weightings are kept:
+--   articles - every analysed article counts once
+--   turnover - per-stock sentiment weighted by 20-day average turnover
+--              (a size proxy; shares outstanding are not stored)
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS market_sentiment_index (
+    date DATE NOT NULL,                       -- Asia/Taipei publication date
+    weighting VARCHAR(20) NOT NULL,           -- articles, turnover
+    score DECIMAL(6,4) NOT NULL,              -- -1.0 to 1.0
+    index_value DECIMAL(6,2) NOT NULL,        -- score rescaled to 0-100 (50 = neutral)
+    articles INTEGER NOT NULL DEFAULT 0,
+    symbols INTEGER NOT NULL DEFAULT 0,
+    positive_count INTEGER NOT NULL DEFAULT 0,
+    negative_count INTEGER NOT NULL DEFAULT 0,
+    neutral_count INTEGER NOT NULL DEFAULT 0,
+    computed_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (date, weighting)
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON market_sentiment_index TO psm_user;
+
+COMMENT ON TABLE market_sentiment_index IS 'Daily market-wide news sentiment index (articles / turnover weighted)';
@@ -0,0 +1,15 @@
+-- ============================================================================
+-- Migration 024: Sentiment Analyzer Version
+-- Each analysed article records the analyzer configuration that scored it
+-- (keyword analyzer revision, dictionary version, LLM provider/model/prompt),
+-- so articles scored by an older configuration can be re-analysed.
+-- Articles analysed before this migration have no version and count as outdated.
+-- ============================================================================
+
+ALTER TABLE stock_news ADD COLUMN IF NOT EXISTS sentiment_analyzer_version VARCHAR(100);
+ALTER TABLE stock_news_archive ADD COLUMN IF NOT EXISTS sentiment_analyzer_version VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_stock_news_analyzer_version ON stock_news (sentiment_analyzer_version, published_at DESC)
+    WHERE sentiment IS NOT NULL;
+
+COMMENT ON COLUMN stock_news.sentiment_analyzer_version IS 'Analyzer configuration that produced the sentiment, e.g. kw1.dict12+gemini:gemini-2.0-flash-exp.p1@0.50';
@@ -0,0 +1,29 @@
+-- ============================================================================
+-- Migration 025: AI Providers and Token Usage
+-- AI analysis can run on Gemini, OpenAI, Claude or a local Ollama model
+-- (AI_PROVIDER). Cached analyses record the provider that produced them, and
+-- token usage is accumulated per day, provider, model and feature.
+-- ============================================================================
+
+ALTER TABLE ai_analysis_cache ADD COLUMN IF NOT EXISTS provider VARCHAR(20);
+ALTER TABLE ai_analysis_cache ALTER COLUMN model TYPE VARCHAR(100);
+
+CREATE TABLE IF NOT EXISTS ai_token_usage (
+    usage_date DATE NOT NULL,                 -- Asia/Taipei date
+    provider VARCHAR(20) NOT NULL,            -- gemini, openai, claude, ollama
+    model VARCHAR(100) NOT NULL,
+    feature VARCHAR(50) NOT NULL,             -- analysis type, chat, digest, ...
+    requests INTEGER NOT NULL DEFAULT 0,
+    failures INTEGER NOT NULL DEFAULT 0,
+    input_tokens BIGINT NOT NULL DEFAULT 0,
+    output_tokens BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (usage_date, provider, model, feature)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_token_usage_provider ON ai_token_usage(provider, usage_date DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_token_usage TO psm_user;
+
+COMMENT ON TABLE ai_token_usage IS 'Daily LLM token usage per provider, model and feature';
+COMMENT ON COLUMN ai_analysis_cache.provider IS 'LLM provider that generated the analysis (NULL = gemini, before providers were configurable)';
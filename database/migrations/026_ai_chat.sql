@@ -0,0 +1,40 @@
+-- ============================================================================
+-- Migration 026: AI Chat
+-- Conversations with the AI analyst. Each session belongs to a user and may be
+-- about one symbol and/or one portfolio, whose current data is injected as
+-- grounding on every turn; earlier turns are replayed for follow-up questions.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS ai_chat_sessions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    title VARCHAR(200) NOT NULL,                  -- First question, truncated
+    symbol VARCHAR(10),                           -- Stock code without suffix
+    portfolio_id UUID REFERENCES portfolios(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS ai_chat_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    session_id UUID NOT NULL REFERENCES ai_chat_sessions(id) ON DELETE CASCADE,
+    role VARCHAR(20) NOT NULL CHECK (role IN ('user', 'assistant')),
+    content TEXT NOT NULL,
+    provider VARCHAR(20),                         -- Assistant messages only
+    model VARCHAR(100),
+    input_tokens INTEGER,
+    output_tokens INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_chat_sessions_user ON ai_chat_sessions (user_id, updated_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ai_chat_messages_session ON ai_chat_messages (session_id, created_at);
+
+CREATE TRIGGER update_ai_chat_sessions_updated_at BEFORE UPDATE ON ai_chat_sessions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_chat_sessions TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_chat_messages TO psm_user;
+
+COMMENT ON TABLE ai_chat_sessions IS 'AI chat conversations, optionally about a symbol or portfolio';
+COMMENT ON TABLE ai_chat_messages IS 'Turns of each AI chat conversation';
@@ -0,0 +1,29 @@
+-- ============================================================================
+-- Migration 027: AI Prompt Templates
+-- System and user prompts per analysis type, editable without a redeploy.
+-- Templates are immutable versions; editing creates the next version and at
+-- most one version per type is active. Without an active version the built-in
+-- prompts (version 0) are used. Cached analyses record the version used.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS ai_prompt_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    analysis_type VARCHAR(50) NOT NULL,           -- daily_summary, investment_advice, risk_assessment, news_digest
+    version INTEGER NOT NULL CHECK (version > 0),
+    system_prompt TEXT NOT NULL,
+    user_prompt TEXT NOT NULL,                    -- {{context}}, {{symbol}} and {{name}} are substituted
+    is_active BOOLEAN NOT NULL DEFAULT FALSE,
+    note TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (analysis_type, version)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_prompt_templates_active ON ai_prompt_templates (analysis_type)
+    WHERE is_active;
+
+ALTER TABLE ai_analysis_cache ADD COLUMN IF NOT EXISTS prompt_version INTEGER;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_prompt_templates TO psm_user;
+
+COMMENT ON TABLE ai_prompt_templates IS 'Versioned AI prompt templates per analysis type';
+COMMENT ON COLUMN ai_analysis_cache.prompt_version IS 'ai_prompt_templates.version used (NULL = built-in prompt)';
@@ -0,0 +1,32 @@
+-- ============================================================================
+-- Migration 028: AI Daily Digests
+-- One AI-written digest per user and trading day, generated after the close
+-- from the user's holdings and watchlists: price movers, news and alerts.
+-- The structured input is kept alongside the text for the UI and for audits.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS ai_daily_digests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    digest_date DATE NOT NULL,                    -- Asia/Taipei trading day
+    content TEXT NOT NULL,
+    data JSONB NOT NULL,                          -- Movers, news and alerts the digest was written from
+    provider VARCHAR(20) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    input_tokens INTEGER,
+    output_tokens INTEGER,
+    delivered_at TIMESTAMPTZ,                     -- Sent to the user's webhooks
+    delivery_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, digest_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_daily_digests_date ON ai_daily_digests (digest_date DESC);
+
+CREATE TRIGGER update_ai_daily_digests_updated_at BEFORE UPDATE ON ai_daily_digests
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_daily_digests TO psm_user;
+
+COMMENT ON TABLE ai_daily_digests IS 'AI-written post-close digest of each user''s holdings and watchlists';
@@ -0,0 +1,21 @@
+-- ============================================================================
+-- Migration 029: Structured AI Output
+-- Analyses end with a JSON block (stance, confidence, key price levels and
+-- risk factors) that is parsed and stored next to the free text, so the
+-- frontend can render badges and the screener can filter on AI stance.
+-- Rows generated before this migration, or whose block failed to parse,
+-- have a NULL stance.
+-- ============================================================================
+
+ALTER TABLE ai_analysis_cache
+    ADD COLUMN IF NOT EXISTS stance VARCHAR(10) CHECK (stance IN ('bullish', 'neutral', 'bearish')),
+    ADD COLUMN IF NOT EXISTS confidence NUMERIC(4,3) CHECK (confidence BETWEEN 0 AND 1),
+    ADD COLUMN IF NOT EXISTS support_levels NUMERIC(12,2)[],
+    ADD COLUMN IF NOT EXISTS resistance_levels NUMERIC(12,2)[],
+    ADD COLUMN IF NOT EXISTS risk_factors TEXT[];
+
+CREATE INDEX IF NOT EXISTS idx_ai_analysis_stance ON ai_analysis_cache (symbol, analysis_date DESC)
+    WHERE stance IS NOT NULL;
+
+COMMENT ON COLUMN ai_analysis_cache.stance IS 'Parsed AI stance: bullish, neutral or bearish (NULL = no structured output)';
+COMMENT ON COLUMN ai_analysis_cache.confidence IS 'Model-reported confidence in the stance, 0 to 1';
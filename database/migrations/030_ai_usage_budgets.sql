@@ -0,0 +1,60 @@
+-- ============================================================================
+-- Migration 030: AI Usage Accounting and Budgets
+-- Token usage is now also accounted per user (the nil UUID is background work
+-- not triggered by a user) with an estimated cost from per-model prices.
+-- Monthly budgets cap tokens and/or cost for one user or the whole
+-- installation (user_id NULL); requests over budget are refused.
+-- ============================================================================
+
+ALTER TABLE ai_token_usage
+    ADD COLUMN IF NOT EXISTS user_id UUID NOT NULL DEFAULT '00000000-0000-0000-0000-000000000000',
+    ADD COLUMN IF NOT EXISTS cost_usd NUMERIC(14,6) NOT NULL DEFAULT 0;
+
+ALTER TABLE ai_token_usage DROP CONSTRAINT IF EXISTS ai_token_usage_pkey;
+ALTER TABLE ai_token_usage ADD PRIMARY KEY (usage_date, user_id, provider, model, feature);
+
+CREATE INDEX IF NOT EXISTS idx_ai_token_usage_user ON ai_token_usage(user_id, usage_date DESC);
+
+-- Prices in USD per million tokens; model '*' is the provider's fallback
+CREATE TABLE IF NOT EXISTS ai_model_prices (
+    provider VARCHAR(20) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    input_per_million NUMERIC(10,4) NOT NULL,
+    output_per_million NUMERIC(10,4) NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (provider, model)
+);
+
+INSERT INTO ai_model_prices (provider, model, input_per_million, output_per_million) VALUES
+    ('gemini', '*', 0.10, 0.40),
+    ('openai', 'gpt-4o-mini', 0.15, 0.60),
+    ('openai', 'gpt-4o', 2.50, 10.00),
+    ('openai', '*', 2.50, 10.00),
+    ('claude', 'claude-3-5-haiku-latest', 0.80, 4.00),
+    ('claude', '*', 3.00, 15.00),
+    ('ollama', '*', 0, 0)
+ON CONFLICT (provider, model) DO NOTHING;
+
+CREATE TABLE IF NOT EXISTS ai_usage_budgets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE, -- NULL = whole installation
+    monthly_tokens BIGINT CHECK (monthly_tokens > 0),
+    monthly_cost_usd NUMERIC(12,2) CHECK (monthly_cost_usd > 0),
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (monthly_tokens IS NOT NULL OR monthly_cost_usd IS NOT NULL)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_usage_budgets_user
+    ON ai_usage_budgets ((COALESCE(user_id, '00000000-0000-0000-0000-000000000000'::uuid)));
+
+CREATE TRIGGER update_ai_usage_budgets_updated_at BEFORE UPDATE ON ai_usage_budgets
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_model_prices TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_usage_budgets TO psm_user;
+
+COMMENT ON COLUMN ai_token_usage.user_id IS 'User the request was made for (nil UUID = background work)';
+COMMENT ON COLUMN ai_token_usage.cost_usd IS 'Estimated cost from ai_model_prices at the time of the request';
+COMMENT ON TABLE ai_model_prices IS 'LLM prices in USD per million tokens, used for cost estimates';
+COMMENT ON TABLE ai_usage_budgets IS 'Monthly (Asia/Taipei calendar month) AI token and cost budgets';
@@ -0,0 +1,10 @@
+-- ============================================================================
+-- Migration 031: AI Model Fallback
+-- Rate-limited or failing provider calls are retried with backoff and then
+-- sent to a fallback model (AI_FALLBACK_MODEL). Cached analyses record
+-- whether the fallback model produced them; model holds the model used.
+-- ============================================================================
+
+ALTER TABLE ai_analysis_cache ADD COLUMN IF NOT EXISTS fallback_used BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN ai_analysis_cache.fallback_used IS 'Generated by the fallback model after the primary model failed';
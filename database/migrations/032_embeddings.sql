@@ -0,0 +1,50 @@
+-- ============================================================================
+-- Migration 032: Semantic Search Embeddings (pgvector)
+-- News articles and AI analyses are embedded (768 dimensions, see
+-- AI_EMBEDDING_PROVIDER) for semantic search and for picking the most
+-- relevant articles for AI prompts.
+-- Requires the pgvector extension (bundled with timescale/timescaledb-ha).
+-- Without it this migration only logs a notice and semantic search stays
+-- disabled; rerun it after installing pgvector.
+-- ============================================================================
+
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS vector;
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE 'pgvector is not available, skipping embedding tables: %', SQLERRM;
+END
+$$;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector') THEN
+        RETURN;
+    END IF;
+
+    CREATE TABLE IF NOT EXISTS news_embeddings (
+        article_id UUID PRIMARY KEY REFERENCES stock_news(id) ON DELETE CASCADE,
+        model VARCHAR(100) NOT NULL,
+        embedding vector(768) NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT NOW()
+    );
+
+    CREATE TABLE IF NOT EXISTS ai_analysis_embeddings (
+        analysis_id UUID PRIMARY KEY REFERENCES ai_analysis_cache(id) ON DELETE CASCADE,
+        model VARCHAR(100) NOT NULL,
+        embedding vector(768) NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT NOW()       -- Older than the analysis' created_at = stale
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_news_embeddings_hnsw ON news_embeddings
+        USING hnsw (embedding vector_cosine_ops);
+    CREATE INDEX IF NOT EXISTS idx_ai_analysis_embeddings_hnsw ON ai_analysis_embeddings
+        USING hnsw (embedding vector_cosine_ops);
+
+    GRANT SELECT, INSERT, UPDATE, DELETE ON news_embeddings TO psm_user;
+    GRANT SELECT, INSERT, UPDATE, DELETE ON ai_analysis_embeddings TO psm_user;
+
+    COMMENT ON TABLE news_embeddings IS 'Embedding of each article''s title and summary for semantic search';
+    COMMENT ON TABLE ai_analysis_embeddings IS 'Embedding of each cached AI analysis for semantic search';
+END
+$$;
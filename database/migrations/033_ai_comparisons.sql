@@ -0,0 +1,32 @@
+-- ============================================================================
+-- Migration 033: AI Stock Comparisons
+-- Head-to-head AI comparison of two symbols (valuation, momentum, sentiment),
+-- cached per pair and day like single-symbol analyses. The pair is stored in
+-- symbol order so 2330,2454 and 2454,2330 share one entry.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS ai_comparison_cache (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    symbol_a VARCHAR(20) NOT NULL,
+    symbol_b VARCHAR(20) NOT NULL,
+    comparison_date DATE NOT NULL,
+    content TEXT NOT NULL,
+    structured JSONB,                             -- Per-dimension winners; NULL when the model returned no valid block
+    metrics JSONB NOT NULL,                       -- Returns, 52-week range and sentiment the comparison was written from
+    provider VARCHAR(20) NOT NULL,
+    model VARCHAR(100) NOT NULL,
+    input_tokens INTEGER,
+    output_tokens INTEGER,
+    fallback_used BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    expires_at TIMESTAMPTZ,
+    UNIQUE (symbol_a, symbol_b, comparison_date),
+    CHECK (symbol_a < symbol_b)
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_comparison_symbol_b ON ai_comparison_cache (symbol_b);
+CREATE INDEX IF NOT EXISTS idx_ai_comparison_expires ON ai_comparison_cache (expires_at) WHERE expires_at IS NOT NULL;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON ai_comparison_cache TO psm_user;
+
+COMMENT ON TABLE ai_comparison_cache IS 'AI head-to-head comparisons of two symbols, one per pair and day';
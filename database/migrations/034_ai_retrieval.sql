@@ -0,0 +1,66 @@
+-- ============================================================================
+-- Migration 034: Monthly Revenue and AI Retrieval Store
+-- Monthly revenue reports (月營收) from the TWSE / TPEx open data, and a
+-- retrieval store of chunked, embedded documents - cached AI analyses,
+-- material announcements (重大訊息) and revenue reports - that AI prompts
+-- are augmented with, so analyses can refer back beyond the latest news.
+-- The retrieval store requires pgvector like migration 032 and is skipped
+-- without it; monthly revenue is still stored and used in prompts.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS stock_monthly_revenue (
+    symbol VARCHAR(10) NOT NULL,
+    revenue_month DATE NOT NULL,                  -- First day of the reported month
+    revenue BIGINT NOT NULL,                      -- Thousand NTD
+    prev_month_revenue BIGINT,
+    last_year_revenue BIGINT,                     -- Same month last year
+    mom_pct DECIMAL(12, 2),
+    yoy_pct DECIMAL(12, 2),
+    cumulative_revenue BIGINT,                    -- Year to date
+    cumulative_yoy_pct DECIMAL(12, 2),
+    note TEXT,                                    -- Company explanation of large changes
+    market VARCHAR(10) NOT NULL,                  -- TSE or OTC
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (symbol, revenue_month)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_monthly_revenue_month ON stock_monthly_revenue (revenue_month DESC);
+
+CREATE TRIGGER update_stock_monthly_revenue_updated_at BEFORE UPDATE ON stock_monthly_revenue
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON stock_monthly_revenue TO psm_user;
+
+COMMENT ON TABLE stock_monthly_revenue IS 'Monthly revenue reports (月營收) from the TWSE / TPEx open data, in thousand NTD';
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'vector') THEN
+        RAISE NOTICE 'pgvector is not available, skipping ai_retrieval_chunks';
+        RETURN;
+    END IF;
+
+    CREATE TABLE IF NOT EXISTS ai_retrieval_chunks (
+        id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+        source_type VARCHAR(20) NOT NULL,         -- analysis, filing or revenue
+        source_id VARCHAR(100) NOT NULL,          -- Analysis or article ID, or symbol:YYYY-MM for revenue
+        symbol VARCHAR(10) NOT NULL,
+        chunk_index INTEGER NOT NULL,
+        content TEXT NOT NULL,
+        published_at TIMESTAMPTZ NOT NULL,        -- When the source was written or reported
+        model VARCHAR(100) NOT NULL,
+        embedding vector(768) NOT NULL,
+        created_at TIMESTAMPTZ DEFAULT NOW(),     -- Older than the source's last change = stale
+        UNIQUE (source_type, source_id, chunk_index)
+    );
+
+    CREATE INDEX IF NOT EXISTS idx_ai_retrieval_chunks_symbol ON ai_retrieval_chunks (symbol, published_at DESC);
+    CREATE INDEX IF NOT EXISTS idx_ai_retrieval_chunks_hnsw ON ai_retrieval_chunks
+        USING hnsw (embedding vector_cosine_ops);
+
+    GRANT SELECT, INSERT, UPDATE, DELETE ON ai_retrieval_chunks TO psm_user;
+
+    COMMENT ON TABLE ai_retrieval_chunks IS 'Chunked, embedded analyses, filings and revenue reports retrieved into AI prompts';
+END
+$$;
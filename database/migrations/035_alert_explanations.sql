@@ -0,0 +1,13 @@
+-- ============================================================================
+-- Migration 035: AI Alert Explanations
+-- A short AI-written explanation of what likely caused an alert, generated on
+-- request from the alert payload, the price action around it and related
+-- news, and kept on the alert so it is only generated once.
+-- ============================================================================
+
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS ai_explanation TEXT;
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS ai_explanation_data JSONB;     -- Related news the explanation cites
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS ai_explanation_model VARCHAR(100);
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS ai_explained_at TIMESTAMPTZ;
+
+COMMENT ON COLUMN stock_alerts.ai_explanation IS 'AI explanation of the likely cause of the alert (POST /ai/alerts/:id/explain)';
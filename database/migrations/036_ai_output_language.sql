@@ -0,0 +1,22 @@
+-- ============================================================================
+-- Migration 036: AI Output Language
+-- Analyses, comparisons and alert explanations can be written in Traditional
+-- Chinese (zh-TW, the default) or English (en). The language is part of the
+-- cache key so both can be cached for the same symbol and day.
+-- ============================================================================
+
+ALTER TABLE ai_analysis_cache ADD COLUMN IF NOT EXISTS language VARCHAR(10) NOT NULL DEFAULT 'zh-TW';
+ALTER TABLE ai_analysis_cache DROP CONSTRAINT IF EXISTS ai_analysis_cache_symbol_analysis_type_analysis_date_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_analysis_cache_key
+    ON ai_analysis_cache (symbol, analysis_type, analysis_date, language);
+
+ALTER TABLE ai_comparison_cache ADD COLUMN IF NOT EXISTS language VARCHAR(10) NOT NULL DEFAULT 'zh-TW';
+ALTER TABLE ai_comparison_cache DROP CONSTRAINT IF EXISTS ai_comparison_cache_symbol_a_symbol_b_comparison_date_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ai_comparison_cache_key
+    ON ai_comparison_cache (symbol_a, symbol_b, comparison_date, language);
+
+-- Only the latest explanation is kept; asking in another language replaces it
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS ai_explanation_language VARCHAR(10);
+
+COMMENT ON COLUMN ai_analysis_cache.language IS 'Output language: zh-TW or en';
+COMMENT ON COLUMN ai_comparison_cache.language IS 'Output language: zh-TW or en';
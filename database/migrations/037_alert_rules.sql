@@ -0,0 +1,41 @@
+-- ============================================================================
+-- Migration 037: User-Defined Alert Rules
+-- Threshold rules such as "2330 price >= 1100", "daily change <= -5%" or
+-- "volume ratio >= 3", evaluated by the alert scanner next to the built-in
+-- detectors. A rule without a symbol applies to the owner's held and
+-- watched symbols. Each rule fires at most once per symbol and trading day.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    symbol VARCHAR(10),                            -- NULL = held and watched symbols
+    metric VARCHAR(20) NOT NULL,                   -- price, change_percent, volume, volume_ratio
+    operator VARCHAR(2) NOT NULL,                  -- >=, <=, >, <
+    threshold DECIMAL(18, 4) NOT NULL,
+    severity VARCHAR(20) NOT NULL DEFAULT 'warning',
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_triggered_at TIMESTAMPTZ,
+    trigger_count INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (metric IN ('price', 'change_percent', 'volume', 'volume_ratio')),
+    CHECK (operator IN ('>=', '<=', '>', '<')),
+    CHECK (severity IN ('info', 'warning', 'critical'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_user ON alert_rules (user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_alert_rules_enabled ON alert_rules (symbol) WHERE enabled;
+
+-- Looks up whether a rule already fired for a symbol and day
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_rule ON stock_alerts ((data->>'rule_id'), symbol)
+    WHERE alert_type = 'user_rule';
+
+CREATE TRIGGER update_alert_rules_updated_at BEFORE UPDATE ON alert_rules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_rules TO psm_user;
+
+COMMENT ON TABLE alert_rules IS 'User-defined threshold alert rules evaluated by the alert scanner';
+COMMENT ON COLUMN alert_rules.symbol IS 'Symbol the rule watches; NULL applies it to the owner''s held and watched symbols';
@@ -0,0 +1,28 @@
+-- ============================================================================
+-- Migration 038: Alert Scan Runs
+-- The alert scanner now runs in the background: intraday scans of the rule
+-- symbols against realtime quotes every few minutes while the market is
+-- open, and one full end-of-day scan once the day's bars have been synced.
+-- Each run is recorded here; the latest runs are reported by /alerts/stats
+-- and a completed end-of-day run keeps the day from being scanned twice.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_scan_runs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    kind VARCHAR(10) NOT NULL,                     -- intraday or eod
+    trading_date DATE NOT NULL,                    -- Asia/Taipei date scanned
+    status VARCHAR(20) NOT NULL DEFAULT 'running', -- running, completed, failed
+    symbols_scanned INTEGER NOT NULL DEFAULT 0,
+    alerts_generated INTEGER NOT NULL DEFAULT 0,
+    error TEXT,
+    started_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    CHECK (kind IN ('intraday', 'eod'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_scan_runs_kind ON alert_scan_runs (kind, started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_alert_scan_runs_date ON alert_scan_runs (trading_date, kind);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_scan_runs TO psm_user;
+
+COMMENT ON TABLE alert_scan_runs IS 'Background alert scanner runs (intraday quote scans and end-of-day full scans)';
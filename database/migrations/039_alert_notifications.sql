@@ -0,0 +1,42 @@
+-- ============================================================================
+-- Migration 039: Alert Notifications
+-- Triggered alerts are pushed to the users they concern (rule owners, and
+-- holders/watchers of the symbol) over notification channels, starting with
+-- email. Each channel keeps per-user settings with severity and alert type
+-- filters; alert_deliveries records every attempt so an alert is sent to a
+-- user over a channel once, and failed sends are retried a few times.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_email_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    email VARCHAR(255),                                        -- NULL = the account email
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    severities TEXT[] NOT NULL DEFAULT '{warning,critical}',
+    alert_types TEXT[] NOT NULL DEFAULT '{}',                  -- Empty = every type
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS alert_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    alert_id UUID NOT NULL REFERENCES stock_alerts(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,                              -- email, ...
+    status VARCHAR(20) NOT NULL,                               -- sent, failed
+    attempts INTEGER NOT NULL DEFAULT 1,
+    error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+    UNIQUE (alert_id, user_id, channel)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_user ON alert_deliveries (user_id, created_at DESC);
+
+CREATE TRIGGER update_alert_email_settings_updated_at BEFORE UPDATE ON alert_email_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_email_settings TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_deliveries TO psm_user;
+
+COMMENT ON TABLE alert_email_settings IS 'Per-user email notification settings for triggered alerts';
+COMMENT ON TABLE alert_deliveries IS 'Delivery attempts of triggered alerts per user and notification channel';
@@ -0,0 +1,25 @@
+-- ============================================================================
+-- Migration 040: LINE Notify
+-- Users register a LINE Notify personal access token to receive triggered
+-- alerts (filtered by severity and alert type) and their AI daily digest in
+-- LINE. Deliveries share alert_deliveries with the other channels.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_line_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    token TEXT NOT NULL,                                       -- LINE Notify access token
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    severities TEXT[] NOT NULL DEFAULT '{warning,critical}',
+    alert_types TEXT[] NOT NULL DEFAULT '{}',                  -- Empty = every type
+    digest BOOLEAN NOT NULL DEFAULT TRUE,                      -- Also send the AI daily digest
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TRIGGER update_alert_line_settings_updated_at BEFORE UPDATE ON alert_line_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_line_settings TO psm_user;
+
+COMMENT ON TABLE alert_line_settings IS 'Per-user LINE Notify tokens and filters for alert and digest notifications';
@@ -0,0 +1,25 @@
+-- ============================================================================
+-- Migration 041: Telegram Notifications
+-- Users link the Telegram chat the PSM bot should message (TELEGRAM_BOT_TOKEN)
+-- and choose which alert severities and types to receive there, plus the AI
+-- daily digest. Deliveries share alert_deliveries with the other channels.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_telegram_settings (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    chat_id VARCHAR(64) NOT NULL,                              -- Telegram chat, user or group ID
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    severities TEXT[] NOT NULL DEFAULT '{warning,critical}',
+    alert_types TEXT[] NOT NULL DEFAULT '{}',                  -- Empty = every type
+    digest BOOLEAN NOT NULL DEFAULT TRUE,                      -- Also send the AI daily digest
+    last_error TEXT,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TRIGGER update_alert_telegram_settings_updated_at BEFORE UPDATE ON alert_telegram_settings
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_telegram_settings TO psm_user;
+
+COMMENT ON TABLE alert_telegram_settings IS 'Per-user Telegram chats and filters for alert and digest notifications';
@@ -0,0 +1,52 @@
+-- ============================================================================
+-- Migration 042: Alert Webhooks
+-- Users register URLs that receive triggered alerts as signed JSON
+-- (X-PSM-Signature: sha256=HMAC of the body with the webhook's secret).
+-- Every delivery is queued in alert_webhook_deliveries with its payload, so
+-- failed deliveries are retried with exponential backoff and the log can be
+-- inspected when debugging an integration.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret TEXT NOT NULL,                                      -- HMAC-SHA256 signing key
+    severities TEXT[] NOT NULL DEFAULT '{info,warning,critical}',
+    alert_types TEXT[] NOT NULL DEFAULT '{}',                  -- Empty = every type
+    is_active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, url)
+);
+
+CREATE TABLE IF NOT EXISTS alert_webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    webhook_id UUID NOT NULL REFERENCES alert_webhooks(id) ON DELETE CASCADE,
+    alert_id UUID REFERENCES stock_alerts(id) ON DELETE CASCADE,  -- NULL for test pings
+    event VARCHAR(50) NOT NULL,                                -- alert.triggered, ping
+    payload JSONB NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',             -- pending, delivered, failed
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ DEFAULT NOW(),
+    response_status INTEGER,
+    response_body TEXT,                                        -- First 1 KB of the last response
+    error TEXT,
+    duration_ms INTEGER,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ,
+    UNIQUE (webhook_id, alert_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_webhook_deliveries_due ON alert_webhook_deliveries (next_attempt_at)
+    WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_alert_webhook_deliveries_webhook ON alert_webhook_deliveries (webhook_id, created_at DESC);
+
+CREATE TRIGGER update_alert_webhooks_updated_at BEFORE UPDATE ON alert_webhooks
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_webhooks TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_webhook_deliveries TO psm_user;
+
+COMMENT ON TABLE alert_webhooks IS 'Per-user webhook URLs that receive triggered alerts as signed JSON';
+COMMENT ON TABLE alert_webhook_deliveries IS 'Queued, retried and logged alert webhook deliveries';
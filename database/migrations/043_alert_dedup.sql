@@ -0,0 +1,14 @@
+-- ============================================================================
+-- Migration 043: Alert Deduplication
+-- Detector alerts carry a dedup key (symbol + type + trading day). An alert
+-- whose key was already raised within the dedup window is not stored again,
+-- so re-running the volume and 52-week detectors on the same bar does not
+-- repeat the alert.
+-- ============================================================================
+
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS dedup_key VARCHAR(100);
+
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_dedup ON stock_alerts(dedup_key, triggered_at DESC)
+    WHERE dedup_key IS NOT NULL;
+
+COMMENT ON COLUMN stock_alerts.dedup_key IS 'symbol:alert_type[:side]:trading_day; duplicates within ALERT_DEDUP_WINDOW_HOURS are suppressed';
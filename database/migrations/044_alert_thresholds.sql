@@ -0,0 +1,31 @@
+-- ============================================================================
+-- Migration 044: Alert Thresholds
+-- The detector cutoffs (volume ratio severities, 52-week proximity, RSI
+-- zones) as tunable settings, edited through PUT /alerts/thresholds. Missing
+-- keys fall back to the built-in defaults seeded here.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_thresholds (
+    key VARCHAR(50) PRIMARY KEY,
+    value NUMERIC(10,4) NOT NULL,
+    description TEXT,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+INSERT INTO alert_thresholds (key, value, description) VALUES
+    ('volume_spike_ratio', 2, 'Volume / 20-day average that counts as a spike (info)'),
+    ('volume_warning_ratio', 3, 'Volume ratio raising a spike to warning'),
+    ('volume_critical_ratio', 5, 'Volume ratio raising a spike to critical'),
+    ('price_52w_proximity_pct', 3, 'Distance (%) from the 52-week high/low that counts as near it'),
+    ('rsi_overbought', 70, 'RSI(14) above this is overbought'),
+    ('rsi_oversold', 30, 'RSI(14) below this is oversold'),
+    ('rsi_warning_overbought', 80, 'RSI(14) at or above this makes an overbought alert a warning'),
+    ('rsi_warning_oversold', 20, 'RSI(14) at or below this makes an oversold alert a warning')
+ON CONFLICT (key) DO NOTHING;
+
+CREATE TRIGGER update_alert_thresholds_updated_at BEFORE UPDATE ON alert_thresholds
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_thresholds TO psm_user;
+
+COMMENT ON TABLE alert_thresholds IS 'Per-deployment cutoffs of the alert detectors';
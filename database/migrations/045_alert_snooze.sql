@@ -0,0 +1,14 @@
+-- ============================================================================
+-- Migration 045: Alert Snooze
+-- A snoozed alert is hidden from the alert lists until snoozed_until. New
+-- alerts for the same symbol and type raised while a snooze is running
+-- inherit it, so a noisy symbol can be silenced for a while without
+-- acknowledging what it raises afterwards. Snoozed alerts are not pushed.
+-- ============================================================================
+
+ALTER TABLE stock_alerts ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_snoozed ON stock_alerts(symbol, alert_type, snoozed_until)
+    WHERE snoozed_until IS NOT NULL;
+
+COMMENT ON COLUMN stock_alerts.snoozed_until IS 'Hidden from alert lists and notifications until this time';
@@ -0,0 +1,31 @@
+-- ============================================================================
+-- Migration 046: Alert Retention
+-- Acknowledged alerts past the retention window are moved here (or deleted
+-- when archiving is off) by the alert retention job, keeping stock_alerts
+-- small. Alert volume stats read both tables.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS stock_alerts_archive (
+    id UUID PRIMARY KEY,
+    symbol VARCHAR(10) NOT NULL,
+    alert_type VARCHAR(50) NOT NULL,
+    severity VARCHAR(20) NOT NULL,
+    title TEXT NOT NULL,
+    message TEXT NOT NULL,
+    data JSONB,
+    triggered_at TIMESTAMPTZ NOT NULL,
+    acknowledged_at TIMESTAMPTZ,
+    reference_price DECIMAL(10,2),
+    reference_volume BIGINT,
+    threshold_value DECIMAL(10,4),
+    ai_explanation TEXT,
+    dedup_key VARCHAR(100),
+    archived_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_archive_triggered ON stock_alerts_archive (triggered_at DESC);
+CREATE INDEX IF NOT EXISTS idx_stock_alerts_archive_symbol ON stock_alerts_archive (symbol, triggered_at DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON stock_alerts_archive TO psm_user;
+
+COMMENT ON TABLE stock_alerts_archive IS 'Cold storage for acknowledged alerts past the retention window';
@@ -0,0 +1,18 @@
+-- ============================================================================
+-- Migration 047: Alert Delivery Retries
+-- A failed notification is retried with exponential backoff: it stays
+-- 'retrying' until next_attempt_at, and becomes 'failed' once the channel
+-- reports a permanent error (e.g. a revoked token) or the attempts run out.
+-- ============================================================================
+
+ALTER TABLE alert_deliveries ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMPTZ;
+
+-- Failures recorded before backoff existed were retried on the next pass
+UPDATE alert_deliveries SET status = 'retrying', next_attempt_at = NOW()
+WHERE status = 'failed' AND attempts < 3;
+
+CREATE INDEX IF NOT EXISTS idx_alert_deliveries_retrying ON alert_deliveries (next_attempt_at)
+    WHERE status = 'retrying';
+
+COMMENT ON COLUMN alert_deliveries.status IS 'sent, retrying (waiting for next_attempt_at) or failed (gave up)';
+COMMENT ON COLUMN alert_deliveries.next_attempt_at IS 'When a retrying delivery is attempted again';
@@ -0,0 +1,33 @@
+-- ============================================================================
+-- Migration 048: Position Alert Levels
+-- Stop-loss and take-profit prices attached to a position. The alert scanner
+-- checks them against realtime quotes every minute while the market is open
+-- and raises a critical alert, with the position's unrealized P&L, when a
+-- level is touched. Each level fires once until it is changed.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS position_alert_levels (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    portfolio_id UUID NOT NULL REFERENCES portfolios(id) ON DELETE CASCADE,
+    symbol VARCHAR(10) NOT NULL,                   -- Without the market suffix
+    stop_loss DECIMAL(12, 4),                      -- NULL = none
+    take_profit DECIMAL(12, 4),                    -- NULL = none
+    notify BOOLEAN NOT NULL DEFAULT TRUE,          -- Push over the user's notification channels
+    stop_loss_triggered_at TIMESTAMPTZ,
+    take_profit_triggered_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (portfolio_id, symbol),
+    CHECK (stop_loss IS NOT NULL OR take_profit IS NOT NULL),
+    CHECK (stop_loss IS NULL OR take_profit IS NULL OR stop_loss < take_profit)
+);
+
+CREATE INDEX IF NOT EXISTS idx_position_alert_levels_user ON position_alert_levels (user_id);
+
+CREATE TRIGGER update_position_alert_levels_updated_at BEFORE UPDATE ON position_alert_levels
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON position_alert_levels TO psm_user;
+
+COMMENT ON TABLE position_alert_levels IS 'Stop-loss and take-profit alert prices per position';
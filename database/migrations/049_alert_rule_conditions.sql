@@ -0,0 +1,24 @@
+-- ============================================================================
+-- Migration 049: Compound Alert Rule Conditions
+-- A rule can combine several comparisons with AND/OR, e.g. "change >= 3% AND
+-- volume ratio >= 2 AND above MA20", stored as a condition tree in
+-- conditions. Such rules leave metric, operator and threshold empty. Adds
+-- the MA distance and RSI metrics, read from the latest indicator snapshot.
+-- ============================================================================
+
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS conditions JSONB;
+
+ALTER TABLE alert_rules
+    ALTER COLUMN metric DROP NOT NULL,
+    ALTER COLUMN operator DROP NOT NULL,
+    ALTER COLUMN threshold DROP NOT NULL;
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rules_metric_check;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rules_metric_check
+    CHECK (metric IN ('price', 'change_percent', 'volume', 'volume_ratio', 'ma20_distance', 'ma60_distance', 'rsi14'));
+
+ALTER TABLE alert_rules DROP CONSTRAINT IF EXISTS alert_rules_condition_check;
+ALTER TABLE alert_rules ADD CONSTRAINT alert_rules_condition_check
+    CHECK (conditions IS NOT NULL OR (metric IS NOT NULL AND operator IS NOT NULL AND threshold IS NOT NULL));
+
+COMMENT ON COLUMN alert_rules.conditions IS 'Condition tree of a compound rule: {"op": "and"|"or", "conditions": [...]} with {"metric", "operator", "threshold"} leaves';
@@ -0,0 +1,26 @@
+-- ============================================================================
+-- Migration 050: Alert Notification Schedule
+-- Per-user delivery window for pushed alerts (e.g. 08:30-14:00 on trading
+-- days). Alerts raised outside the window are held and sent when it opens,
+-- as one digest message per channel when digest is on. Critical alerts can
+-- be let through at any time. Webhooks are not affected.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_notification_schedules (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    window_start TIME NOT NULL DEFAULT '08:30',    -- Taipei time
+    window_end TIME NOT NULL DEFAULT '14:00',      -- At or before window_start = runs past midnight
+    trading_days_only BOOLEAN NOT NULL DEFAULT TRUE,
+    allow_critical BOOLEAN NOT NULL DEFAULT TRUE,  -- Critical alerts are pushed outside the window
+    digest BOOLEAN NOT NULL DEFAULT TRUE,          -- Held alerts are sent as one message
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TRIGGER update_alert_notification_schedules_updated_at BEFORE UPDATE ON alert_notification_schedules
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_notification_schedules TO psm_user;
+
+COMMENT ON TABLE alert_notification_schedules IS 'Per-user time window for pushing alerts; alerts outside it are deferred';
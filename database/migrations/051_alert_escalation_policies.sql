@@ -0,0 +1,27 @@
+-- ============================================================================
+-- Migration 051: Alert Escalation Policies
+-- Per-user routing of pushed alerts by severity: immediate (every enabled
+-- channel at once), hourly (one digest per channel once the oldest alert in
+-- it is an hour old) or in_app (not pushed). An enabled policy replaces the
+-- channels' severity filters; their alert type filters still apply.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS alert_escalation_policies (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    critical VARCHAR(20) NOT NULL DEFAULT 'immediate',
+    warning VARCHAR(20) NOT NULL DEFAULT 'hourly',
+    info VARCHAR(20) NOT NULL DEFAULT 'in_app',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    CHECK (critical IN ('immediate', 'hourly', 'in_app')),
+    CHECK (warning IN ('immediate', 'hourly', 'in_app')),
+    CHECK (info IN ('immediate', 'hourly', 'in_app'))
+);
+
+CREATE TRIGGER update_alert_escalation_policies_updated_at BEFORE UPDATE ON alert_escalation_policies
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON alert_escalation_policies TO psm_user;
+
+COMMENT ON TABLE alert_escalation_policies IS 'Per-user delivery mode of pushed alerts by severity';
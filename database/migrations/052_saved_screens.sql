@@ -0,0 +1,25 @@
+-- ============================================================================
+-- Migration 052: Saved Screens
+-- Named screener criteria saved per user, listed alongside the built-in
+-- presets and run by id. A screen can be shared read-only through an
+-- unguessable link token, which is cleared when sharing is turned off.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS saved_screens (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    criteria JSONB NOT NULL,                       -- ScreenerCriteria
+    share_token VARCHAR(64) UNIQUE,                -- NULL = not shared
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (user_id, name)
+);
+
+CREATE TRIGGER update_saved_screens_updated_at BEFORE UPDATE ON saved_screens
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON saved_screens TO psm_user;
+
+COMMENT ON TABLE saved_screens IS 'User-defined screener criteria, optionally shared by link';
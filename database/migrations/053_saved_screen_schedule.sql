@@ -0,0 +1,13 @@
+-- ============================================================================
+-- Migration 053: Scheduled Saved Screens
+-- A saved screen can run once per trading day after the daily sync and
+-- indicator precompute. A run with matches raises a screen_match alert for
+-- the owner listing the top matches, which the notifier pushes like any other
+-- alert the user owns.
+-- ============================================================================
+
+ALTER TABLE saved_screens ADD COLUMN IF NOT EXISTS scheduled BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE saved_screens ADD COLUMN IF NOT EXISTS last_run_date DATE;      -- Trading day of the last scheduled run
+ALTER TABLE saved_screens ADD COLUMN IF NOT EXISTS last_run_matches INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_saved_screens_scheduled ON saved_screens (last_run_date) WHERE scheduled;
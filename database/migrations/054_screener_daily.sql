@@ -0,0 +1,34 @@
+-- ============================================================================
+-- Migration 054: Screener Daily Metrics
+-- Per-symbol price, moving average, 52-week range, news sentiment and beta
+-- metrics, materialized once per trading day after the daily sync so screens
+-- no longer scan the OHLCV history on every request. Indicator snapshots, AI
+-- stances and stock master data are still joined live.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS screener_daily (
+    trade_date DATE NOT NULL,
+    symbol VARCHAR(10) NOT NULL,
+    current_price NUMERIC(12, 2) NOT NULL,
+    prev_close NUMERIC(12, 2) NOT NULL,
+    volume BIGINT NOT NULL,
+    avg_volume BIGINT NOT NULL,                    -- 20-day average
+    ma5 NUMERIC(12, 4) NOT NULL,
+    ma20 NUMERIC(12, 4) NOT NULL,
+    ma60 NUMERIC(12, 4) NOT NULL,
+    high_52 NUMERIC(12, 2) NOT NULL,
+    low_52 NUMERIC(12, 2) NOT NULL,
+    sentiment VARCHAR(10) NOT NULL,                -- positive, neutral, negative or unknown (7-day news)
+    sentiment_score DOUBLE PRECISION NOT NULL,
+    beta DOUBLE PRECISION,                         -- ~60-day beta vs TAIEX, NULL with too little history
+    correlation DOUBLE PRECISION,
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (trade_date, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_screener_daily_price ON screener_daily (trade_date, current_price);
+CREATE INDEX IF NOT EXISTS idx_screener_daily_volume ON screener_daily (trade_date, volume);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON screener_daily TO psm_user;
+
+COMMENT ON TABLE screener_daily IS 'Nightly per-symbol screener metrics, see ScreenerService.RefreshDailyMetrics';
@@ -0,0 +1,12 @@
+-- ============================================================================
+-- Migration 055: Screener Daily Range
+-- Adds the latest bar's high and low to screener_daily so screens can tell
+-- stocks that traded at the limit price intraday from ones that closed there.
+-- Rows computed before this migration leave them NULL.
+-- ============================================================================
+
+ALTER TABLE screener_daily ADD COLUMN IF NOT EXISTS day_high NUMERIC(12, 2);
+ALTER TABLE screener_daily ADD COLUMN IF NOT EXISTS day_low NUMERIC(12, 2);
+
+COMMENT ON COLUMN screener_daily.day_high IS 'High of the trade_date bar';
+COMMENT ON COLUMN screener_daily.day_low IS 'Low of the trade_date bar';
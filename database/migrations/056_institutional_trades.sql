@@ -0,0 +1,28 @@
+-- ============================================================================
+-- Migration 056: Institutional Trades
+-- Daily net buying and selling by the three major institutional investors
+-- (三大法人) per symbol, from the TWSE T86 and TPEx daily trading reports.
+-- Used by the screener for institutional buying streaks.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS stock_institutional_trades (
+    symbol VARCHAR(10) NOT NULL,
+    trade_date DATE NOT NULL,
+    foreign_net BIGINT NOT NULL,                  -- Shares, foreign and mainland investors excluding foreign dealers
+    trust_net BIGINT NOT NULL,                    -- Shares, investment trusts (投信)
+    dealer_net BIGINT NOT NULL,                   -- Shares, dealers (自營商) incl. hedging
+    total_net BIGINT NOT NULL,                    -- Shares, all three
+    market VARCHAR(10) NOT NULL,                  -- TSE or OTC
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (symbol, trade_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_institutional_trades_date ON stock_institutional_trades (trade_date DESC);
+
+CREATE TRIGGER update_stock_institutional_trades_updated_at BEFORE UPDATE ON stock_institutional_trades
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON stock_institutional_trades TO psm_user;
+
+COMMENT ON TABLE stock_institutional_trades IS 'Daily institutional (三大法人) net buying per symbol from the TWSE / TPEx reports, in shares';
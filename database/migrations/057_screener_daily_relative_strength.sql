@@ -0,0 +1,12 @@
+-- ============================================================================
+-- Migration 057: Screener Daily Relative Strength
+-- Adds 20- and 60-day relative strength vs TAIEX (the stock's compounded
+-- return minus the index's, in percentage points) to screener_daily.
+-- Rows computed before this migration leave them NULL.
+-- ============================================================================
+
+ALTER TABLE screener_daily ADD COLUMN IF NOT EXISTS rs_20d DOUBLE PRECISION;
+ALTER TABLE screener_daily ADD COLUMN IF NOT EXISTS rs_60d DOUBLE PRECISION;
+
+COMMENT ON COLUMN screener_daily.rs_20d IS '20-day return minus TAIEX 20-day return, percentage points';
+COMMENT ON COLUMN screener_daily.rs_60d IS '60-day return minus TAIEX 60-day return, percentage points';
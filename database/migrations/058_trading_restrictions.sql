@@ -0,0 +1,27 @@
+-- ============================================================================
+-- Migration 058: Trading Restrictions
+-- Periods a symbol trades under restrictions: disposition (處置) from the
+-- TWSE / TPEx announcements, and full-cash-delivery (全額交割) maintained
+-- through the API. The screener can exclude restricted symbols.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS stock_trading_restrictions (
+    symbol VARCHAR(10) NOT NULL,
+    restriction VARCHAR(20) NOT NULL CHECK (restriction IN ('disposition', 'full_cash_delivery')),
+    start_date DATE NOT NULL,
+    end_date DATE,                                -- Inclusive, NULL while in effect with no announced end
+    reason TEXT,
+    source VARCHAR(10) NOT NULL,                  -- TSE, OTC or manual
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (symbol, restriction, start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_trading_restrictions_period ON stock_trading_restrictions (start_date, end_date);
+
+CREATE TRIGGER update_stock_trading_restrictions_updated_at BEFORE UPDATE ON stock_trading_restrictions
+    FOR EACH ROW EXECUTE FUNCTION update_updated_at_column();
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON stock_trading_restrictions TO psm_user;
+
+COMMENT ON TABLE stock_trading_restrictions IS 'Disposition (處置) and full-cash-delivery (全額交割) periods per symbol';
@@ -0,0 +1,12 @@
+-- ============================================================================
+-- Migration 059: Screener Daily Turnover
+-- Adds the latest bar's turnover (成交金額) and its 20-day average to
+-- screener_daily for the liquidity criteria. Rows computed before this
+-- migration leave them NULL.
+-- ============================================================================
+
+ALTER TABLE screener_daily ADD COLUMN IF NOT EXISTS turnover NUMERIC(20, 2);
+ALTER TABLE screener_daily ADD COLUMN IF NOT EXISTS avg_turnover NUMERIC(20, 2);
+
+COMMENT ON COLUMN screener_daily.turnover IS 'Turnover of the trade_date bar, TWD';
+COMMENT ON COLUMN screener_daily.avg_turnover IS '20-day average turnover, TWD';
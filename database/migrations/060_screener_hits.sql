@@ -0,0 +1,37 @@
+-- ============================================================================
+-- Migration 060: Screener Hits
+-- The symbols each preset and saved screen matched per trading day, recorded
+-- after the daily sync, so past matches can be browsed, compared day over
+-- day and followed up with their forward returns. A saved screen's history
+-- goes with the screen.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS screener_hits (
+    trade_date DATE NOT NULL,
+    preset VARCHAR(50),                           -- Built-in preset name, or
+    screen_id UUID REFERENCES saved_screens(id) ON DELETE CASCADE, -- the saved screen
+    symbol VARCHAR(10) NOT NULL,
+    rank INTEGER NOT NULL,                        -- 1-based, in the screen's sort order
+    price NUMERIC(12, 2) NOT NULL,                -- Close on trade_date
+    change_percent DOUBLE PRECISION NOT NULL,
+    score DOUBLE PRECISION NOT NULL,
+    CHECK ((preset IS NULL) <> (screen_id IS NULL))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_screener_hits_preset ON screener_hits (preset, trade_date, symbol) WHERE preset IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_screener_hits_screen ON screener_hits (screen_id, trade_date, symbol) WHERE screen_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_screener_hits_date ON screener_hits (trade_date);
+
+-- One row per trading day whose hits have been recorded
+CREATE TABLE IF NOT EXISTS screener_hit_runs (
+    trade_date DATE PRIMARY KEY,
+    screens INTEGER NOT NULL,
+    hits INTEGER NOT NULL,
+    recorded_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON screener_hits TO psm_user;
+GRANT SELECT, INSERT, UPDATE, DELETE ON screener_hit_runs TO psm_user;
+
+COMMENT ON TABLE screener_hits IS 'Daily matches of each preset and saved screen';
+COMMENT ON TABLE screener_hit_runs IS 'Trading days whose screener hits have been recorded';
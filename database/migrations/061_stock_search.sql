@@ -0,0 +1,56 @@
+-- ============================================================================
+-- Migration 061: Stock Name Search
+-- Trigram indexes so stock search can match Chinese and English names
+-- anywhere in the name (e.g. 台積 -> 台積電) and tolerate small typos, plus a
+-- table of common aliases (full company names and nicknames) that resolve
+-- to a symbol.
+-- ============================================================================
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+CREATE INDEX IF NOT EXISTS idx_stocks_name_trgm ON taiwan_stocks USING gin (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_stocks_name_en_trgm ON taiwan_stocks USING gin (name_en gin_trgm_ops);
+
+CREATE TABLE IF NOT EXISTS taiwan_stock_aliases (
+    alias VARCHAR(100) NOT NULL,
+    symbol VARCHAR(10) NOT NULL REFERENCES taiwan_stocks(symbol) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    PRIMARY KEY (alias, symbol)
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_aliases_trgm ON taiwan_stock_aliases USING gin (alias gin_trgm_ops);
+
+INSERT INTO taiwan_stock_aliases (alias, symbol)
+SELECT a.alias, a.symbol
+FROM (VALUES
+    ('台灣積體電路', '2330'),
+    ('護國神山', '2330'),
+    ('聯發科技', '2454'),
+    ('發哥', '2454'),
+    ('鴻海精密', '2317'),
+    ('富士康', '2317'),
+    ('台達電子', '2308'),
+    ('聯華電子', '2303'),
+    ('日月光', '3711'),
+    ('中華電信', '2412'),
+    ('台灣大哥大', '3045'),
+    ('遠傳電信', '4904'),
+    ('長榮海運', '2603'),
+    ('陽明海運', '2609'),
+    ('萬海航運', '2615'),
+    ('統一超商', '2912'),
+    ('7-11', '2912'),
+    ('台塑石化', '6505'),
+    ('中國鋼鐵', '2002'),
+    ('台灣水泥', '1101'),
+    ('亞洲水泥', '1102'),
+    ('廣達電腦', '2382'),
+    ('華碩電腦', '2357'),
+    ('大立光電', '3008')
+) AS a(alias, symbol)
+WHERE EXISTS (SELECT 1 FROM taiwan_stocks s WHERE s.symbol = a.symbol)
+ON CONFLICT DO NOTHING;
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON taiwan_stock_aliases TO psm_user;
+
+COMMENT ON TABLE taiwan_stock_aliases IS 'Alternate names (full company names, nicknames) matched by stock search';
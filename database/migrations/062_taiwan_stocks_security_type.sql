@@ -0,0 +1,22 @@
+-- ============================================================================
+-- Migration 062: Security Type
+-- taiwan_stocks now holds ETFs (and ETNs) besides common stocks. The
+-- security_type column tells them apart for search and screening; existing
+-- rows are classified by their code, as the stock sync does.
+-- ============================================================================
+
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS security_type VARCHAR(10) NOT NULL DEFAULT 'stock';
+
+UPDATE taiwan_stocks SET security_type = CASE
+    WHEN symbol LIKE '00%' THEN 'etf'
+    WHEN symbol LIKE '020%' THEN 'etn'
+    WHEN symbol ~ '^0[3-8][0-9A-Z]{4}$' OR symbol ~ '^7[0-3][0-9A-Z]{4}$' THEN 'warrant'
+    WHEN symbol ~ '^91[0-9]{4}$' OR industry = '存託憑證' THEN 'tdr'
+    WHEN symbol ~ '^[0-9]{4}[A-Z]$' THEN 'preferred'
+    WHEN symbol ~ '^[0-9]{4}$' THEN 'stock'
+    ELSE 'other'
+END;
+
+CREATE INDEX IF NOT EXISTS idx_stocks_security_type ON taiwan_stocks (security_type);
+
+COMMENT ON COLUMN taiwan_stocks.security_type IS 'stock, etf, etn, warrant, tdr, preferred or other';
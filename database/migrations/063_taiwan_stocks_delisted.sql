@@ -0,0 +1,12 @@
+-- ============================================================================
+-- Migration 063: Delisted Stocks
+-- The stock sync marks symbols that no longer appear in the TWSE/TPEx lists
+-- as inactive and records the day they disappeared; realtime quotes and the
+-- screener skip them from then on.
+-- ============================================================================
+
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS delisted_at DATE;
+
+CREATE INDEX IF NOT EXISTS idx_stocks_delisted ON taiwan_stocks (delisted_at) WHERE delisted_at IS NOT NULL;
+
+COMMENT ON COLUMN taiwan_stocks.delisted_at IS 'Day the stock sync first found the symbol missing from its source lists; NULL while listed';
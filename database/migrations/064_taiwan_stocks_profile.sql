@@ -0,0 +1,17 @@
+-- ============================================================================
+-- Migration 064: Company Profile
+-- Basic company data from the TWSE company profile open data (t187ap03_L),
+-- filled in by the stock sync and returned with the stock details.
+-- ============================================================================
+
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS paid_in_capital NUMERIC(20,0);
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS listing_date DATE;
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS chairman VARCHAR(100);
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS website TEXT;
+ALTER TABLE taiwan_stocks ADD COLUMN IF NOT EXISTS address TEXT;
+
+COMMENT ON COLUMN taiwan_stocks.paid_in_capital IS '實收資本額 (TWD)';
+COMMENT ON COLUMN taiwan_stocks.listing_date IS '上市日期';
+COMMENT ON COLUMN taiwan_stocks.chairman IS '董事長';
+COMMENT ON COLUMN taiwan_stocks.website IS '公司網址';
+COMMENT ON COLUMN taiwan_stocks.address IS '公司住址';
@@ -0,0 +1,27 @@
+-- ============================================================================
+-- Migration 065: Stock Sync Log
+-- One row per stock list sync (manual, scheduled weekly or from the CLI) with
+-- its counts, the symbols it added, changed or deactivated, and any errors.
+-- ============================================================================
+
+CREATE TABLE IF NOT EXISTS stock_sync_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    triggered_by VARCHAR(20) NOT NULL,             -- manual, scheduled or cli
+    status VARCHAR(10) NOT NULL,                   -- success or failed
+    tse_count INTEGER NOT NULL DEFAULT 0,
+    otc_count INTEGER NOT NULL DEFAULT 0,
+    etf_count INTEGER NOT NULL DEFAULT 0,
+    total_count INTEGER NOT NULL DEFAULT 0,
+    new_symbols TEXT[] NOT NULL DEFAULT '{}',
+    updated_symbols TEXT[] NOT NULL DEFAULT '{}',  -- Name, market, industry, type or active flag changed
+    deactivated_symbols TEXT[] NOT NULL DEFAULT '{}',
+    errors TEXT[] NOT NULL DEFAULT '{}',
+    started_at TIMESTAMPTZ NOT NULL,
+    finished_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_stock_sync_log_started ON stock_sync_log (started_at DESC);
+
+GRANT SELECT, INSERT, UPDATE, DELETE ON stock_sync_log TO psm_user;
+
+COMMENT ON TABLE stock_sync_log IS 'History of taiwan_stocks syncs from the TWSE/TPEx open data';
@@ -0,0 +1,13 @@
+-- ============================================================================
+-- Migration 066: Alert Rule Strategy Link
+-- An alert rule may be linked to the saved strategy it watches for, pinned at
+-- the strategy version it was written against.
+-- ============================================================================
+
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS strategy_id UUID REFERENCES strategies(id) ON DELETE SET NULL;
+ALTER TABLE alert_rules ADD COLUMN IF NOT EXISTS strategy_version INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_strategy ON alert_rules (strategy_id) WHERE strategy_id IS NOT NULL;
+
+COMMENT ON COLUMN alert_rules.strategy_id IS 'Saved strategy the rule belongs to, if any';
+COMMENT ON COLUMN alert_rules.strategy_version IS 'Strategy version the rule was written against';
@@ -21,6 +21,14 @@ export default function AnalysisPage() {
   const [selectedSymbol, setSelectedSymbol] = useState<string>('2330');
   const [config, setConfig] = useState<ChartConfig>(DEFAULT_CONFIG);
 
+  // Alert notifications link here with ?symbol=2330
+  useEffect(() => {
+    const symbol = new URLSearchParams(window.location.search).get('symbol');
+    if (symbol) {
+      setSelectedSymbol(symbol);
+    }
+  }, []);
+
   const handleSymbolSelect = (stock: TaiwanStock) => {
     setSelectedSymbol(stock.symbol);
   };